@@ -0,0 +1,32 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+#include <assert.h>
+#include "aes.h"
+
+bool AESCipher::ValidKeySize(size_t size) {
+  return size == 16 || size == 24 || size == 32;
+}
+
+AESCipher::AESCipher(const std::string& key) {
+  assert(ValidKeySize(key.size()));
+  const uint8_t* raw = reinterpret_cast<const uint8_t*>(key.data());
+  const int bits = 8 * key.size();
+  // Both calls can only fail on an invalid key size, which the caller
+  // has already ruled out.
+  AES_set_encrypt_key(raw, bits, &encrypt_key_);
+  AES_set_decrypt_key(raw, bits, &decrypt_key_);
+}
+
+void AESCipher::EncryptBlock(uint8_t* block) const {
+  AES_encrypt(block, block, &encrypt_key_);
+}
+
+void AESCipher::DecryptBlock(uint8_t* block) const {
+  AES_decrypt(block, block, &decrypt_key_);
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+#ifndef LIBROACHCCL_AES_H
+#define LIBROACHCCL_AES_H
+
+#include <stdint.h>
+#include <string>
+#include <openssl/aes.h>
+
+// AESCipher encrypts and decrypts single blocks with the AES block
+// cipher. It is a thin wrapper around OpenSSL's implementation. The
+// expanded keys are never modified after construction, so an AESCipher
+// can be used concurrently.
+class AESCipher {
+ public:
+  static const int kBlockSize = AES_BLOCK_SIZE;
+
+  // ValidKeySize returns true if size is a valid AES key size in bytes
+  // (16, 24 or 32 for AES-128, AES-192 and AES-256 respectively).
+  static bool ValidKeySize(size_t size);
+
+  // The key must have a valid size.
+  explicit AESCipher(const std::string& key);
+
+  // EncryptBlock encrypts a single kBlockSize block in place.
+  void EncryptBlock(uint8_t* block) const;
+  // DecryptBlock decrypts a single kBlockSize block in place.
+  void DecryptBlock(uint8_t* block) const;
+
+ private:
+  AES_KEY encrypt_key_;
+  AES_KEY decrypt_key_;
+};
+
+#endif // LIBROACHCCL_AES_H
@@ -13,9 +13,20 @@
 #include <rocksdb/utilities/write_batch_with_index.h>
 #include <libroachccl.h>
 #include "../db.h"
+#include "encrypted_env.h"
 
 const DBStatus kSuccess = { NULL, 0 };
 
+// DBOpenHook overrides the weak definition in db.cc, interpreting the
+// extra options as the encryption-at-rest configuration.
+rocksdb::Status DBOpenHook(const std::string& db_dir, const DBOptions db_opts,
+                           rocksdb::Env* base_env, std::unique_ptr<EncryptedEnv>* env) {
+  if (db_opts.extra_options.len == 0) {
+    return rocksdb::Status::OK();
+  }
+  return NewAESEncryptedEnv(db_dir, ToString(db_opts.extra_options), base_env, env);
+}
+
 DBStatus DBBatchReprVerify(
   DBSlice repr, DBKey start, DBKey end, int64_t now_nanos, MVCCStatsResult* stats
 ) {
@@ -0,0 +1,307 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+#include <map>
+#include <openssl/rand.h>
+#include <sstream>
+#include <string.h>
+#include <rocksdb/env_encryption.h>
+#include "aes.h"
+#include "encrypted_env.h"
+
+namespace {
+
+// Every encrypted file starts with a plaintext prefix of kPrefixLength
+// bytes laid out as:
+//
+//   magic (8) | key ID (8) | IV (16) | initial counter (8) | zero padding
+//
+// The prefix is a multiple of the page size so that the encrypted data
+// which follows it remains aligned.
+const char kMagic[] = "crdbenc1";
+const size_t kMagicLength = 8;
+const size_t kKeyIDLength = 8;
+const size_t kIVLength = AESCipher::kBlockSize;
+const size_t kCounterLength = 8;
+const size_t kHeaderLength = kMagicLength + kKeyIDLength + kIVLength + kCounterLength;
+const size_t kPrefixLength = 4096;
+
+uint64_t DecodeUint64LE(const char* buf) {
+  uint64_t v = 0;
+  for (int i = 7; i >= 0; i--) {
+    v = (v << 8) | uint8_t(buf[i]);
+  }
+  return v;
+}
+
+std::string HexKeyID(const std::string& raw) {
+  static const char kHex[] = "0123456789abcdef";
+  std::string s;
+  for (auto c : raw) {
+    s.push_back(kHex[uint8_t(c) >> 4]);
+    s.push_back(kHex[uint8_t(c) & 0xf]);
+  }
+  return s;
+}
+
+// AESBlockCipher adapts AESCipher to RocksDB's BlockCipher interface.
+class AESBlockCipher : public rocksdb::BlockCipher {
+ public:
+  explicit AESBlockCipher(const std::string& key)
+      : aes_(key) {
+    // The key ID is derived from the encryption of an all-zero block
+    // (i.e. a key check value), which identifies the key without
+    // revealing it.
+    char block[AESCipher::kBlockSize] = {0};
+    aes_.EncryptBlock(reinterpret_cast<uint8_t*>(block));
+    key_id_.assign(block, kKeyIDLength);
+  }
+
+  virtual size_t BlockSize() { return AESCipher::kBlockSize; }
+  virtual rocksdb::Status Encrypt(char* data) {
+    aes_.EncryptBlock(reinterpret_cast<uint8_t*>(data));
+    return rocksdb::Status::OK();
+  }
+  virtual rocksdb::Status Decrypt(char* data) {
+    aes_.DecryptBlock(reinterpret_cast<uint8_t*>(data));
+    return rocksdb::Status::OK();
+  }
+
+  const std::string& KeyID() const { return key_id_; }
+
+ private:
+  const AESCipher aes_;
+  std::string key_id_;
+};
+
+// KeyProvider is a RocksDB EncryptionProvider which records the ID of
+// the key each file was encrypted with in the file's prefix, allowing
+// files written under old keys to be read after the active key is
+// rotated.
+class KeyProvider : public rocksdb::EncryptionProvider {
+ public:
+  KeyProvider() { }
+  virtual ~KeyProvider() { }
+
+  // AddKey adds a key which can be used for decryption, making it the
+  // active key if active is true.
+  void AddKey(std::unique_ptr<AESBlockCipher> cipher, bool active) {
+    const std::string key_id = cipher->KeyID();
+    if (active) {
+      active_key_id_ = key_id;
+    }
+    ciphers_[key_id] = std::move(cipher);
+  }
+
+  const std::string& ActiveKeyID() const { return active_key_id_; }
+
+  virtual size_t GetPrefixLength() { return kPrefixLength; }
+
+  virtual rocksdb::Status CreateNewPrefix(const std::string& fname, char* prefix,
+                                          size_t prefix_length) {
+    if (prefix_length < kHeaderLength) {
+      return rocksdb::Status::InvalidArgument("encryption prefix too short");
+    }
+    memset(prefix, 0, prefix_length);
+    char* p = prefix;
+    memcpy(p, kMagic, kMagicLength);
+    p += kMagicLength;
+    memcpy(p, active_key_id_.data(), kKeyIDLength);
+    p += kKeyIDLength;
+    // The IV and initial counter are chosen at random by OpenSSL's CSPRNG.
+    // Every file gets its own, so the same key stream is never reused.
+    if (RAND_bytes(reinterpret_cast<unsigned char*>(p), kIVLength + kCounterLength) != 1) {
+      return rocksdb::Status::IOError(fname, "unable to generate random IV");
+    }
+    return rocksdb::Status::OK();
+  }
+
+  virtual rocksdb::Status CreateCipherStream(
+      const std::string& fname, const rocksdb::EnvOptions& options, rocksdb::Slice& prefix,
+      std::unique_ptr<rocksdb::BlockAccessCipherStream>* result) {
+    std::string key_id;
+    rocksdb::Status status = ParsePrefix(fname, prefix, &key_id);
+    if (!status.ok()) {
+      return status;
+    }
+    auto it = ciphers_.find(key_id);
+    if (it == ciphers_.end()) {
+      return rocksdb::Status::InvalidArgument(
+          fname, "encrypted with unknown key " + HexKeyID(key_id));
+    }
+    const char* iv = prefix.data() + kMagicLength + kKeyIDLength;
+    const uint64_t counter = DecodeUint64LE(iv + kIVLength);
+    result->reset(new rocksdb::CTRCipherStream(*it->second, iv, counter));
+    return rocksdb::Status::OK();
+  }
+
+  // ParsePrefix verifies that prefix is the prefix of an encrypted file
+  // and extracts the ID of the key the file was encrypted with.
+  static rocksdb::Status ParsePrefix(const std::string& fname, const rocksdb::Slice& prefix,
+                                     std::string* key_id) {
+    if (prefix.size() < kHeaderLength || memcmp(prefix.data(), kMagic, kMagicLength) != 0) {
+      return rocksdb::Status::Corruption(fname, "file is not encrypted");
+    }
+    key_id->assign(prefix.data() + kMagicLength, kKeyIDLength);
+    return rocksdb::Status::OK();
+  }
+
+ private:
+  std::map<std::string, std::unique_ptr<AESBlockCipher>> ciphers_;
+  std::string active_key_id_;
+};
+
+// AESEncryptedEnv wraps the Env returned by rocksdb::NewEncryptedEnv.
+class AESEncryptedEnv : public EncryptedEnv {
+ public:
+  // AESEncryptedEnv takes ownership of provider and encrypted.
+  AESEncryptedEnv(rocksdb::Env* base_env, KeyProvider* provider, rocksdb::Env* encrypted)
+      : EncryptedEnv(encrypted),
+        base_env_(base_env),
+        provider_(provider),
+        encrypted_(encrypted) {
+  }
+  virtual ~AESEncryptedEnv() { }
+
+  virtual std::string ActiveKeyID() {
+    return HexKeyID(provider_->ActiveKeyID());
+  }
+
+  virtual rocksdb::Status FileKeyID(const std::string& fname, std::string* key_id) {
+    std::string raw_id;
+    rocksdb::Status status = ReadKeyID(base_env_, fname, &raw_id);
+    if (!status.ok()) {
+      return status;
+    }
+    *key_id = HexKeyID(raw_id);
+    return rocksdb::Status::OK();
+  }
+
+  // The file sizes reported by the underlying Env include the
+  // encryption prefix, which is not part of the file's contents.
+  virtual rocksdb::Status GetFileSize(const std::string& fname, uint64_t* file_size) {
+    rocksdb::Status status = target()->GetFileSize(fname, file_size);
+    if (status.ok()) {
+      *file_size = *file_size > kPrefixLength ? *file_size - kPrefixLength : 0;
+    }
+    return status;
+  }
+
+  virtual rocksdb::Status GetChildrenFileAttributes(
+      const std::string& dir, std::vector<rocksdb::Env::FileAttributes>* result) {
+    rocksdb::Status status = target()->GetChildrenFileAttributes(dir, result);
+    if (status.ok()) {
+      for (auto& attr : *result) {
+        attr.size_bytes = attr.size_bytes > kPrefixLength ? attr.size_bytes - kPrefixLength : 0;
+      }
+    }
+    return status;
+  }
+
+  // ReadKeyID reads the ID of the key the specified file was encrypted
+  // with directly from the file's prefix.
+  static rocksdb::Status ReadKeyID(rocksdb::Env* env, const std::string& fname,
+                                   std::string* key_id) {
+    rocksdb::Status status = env->FileExists(fname);
+    if (!status.ok()) {
+      return status;
+    }
+    std::unique_ptr<rocksdb::SequentialFile> file;
+    status = env->NewSequentialFile(fname, &file, rocksdb::EnvOptions());
+    if (!status.ok()) {
+      return status;
+    }
+    char scratch[kHeaderLength];
+    rocksdb::Slice prefix;
+    status = file->Read(kHeaderLength, &prefix, scratch);
+    if (!status.ok()) {
+      return status;
+    }
+    return KeyProvider::ParsePrefix(fname, prefix, key_id);
+  }
+
+ private:
+  rocksdb::Env* const base_env_;
+  std::unique_ptr<KeyProvider> provider_;
+  std::unique_ptr<rocksdb::Env> encrypted_;
+};
+
+rocksdb::Status LoadKey(const std::string& path, std::unique_ptr<AESBlockCipher>* cipher) {
+  // Key files live on the real filesystem, even for in-memory Envs.
+  std::string key;
+  rocksdb::Status status = rocksdb::ReadFileToString(rocksdb::Env::Default(), path, &key);
+  if (!status.ok()) {
+    return status;
+  }
+  if (!AESCipher::ValidKeySize(key.size())) {
+    std::stringstream ss;
+    ss << "key file contains " << key.size() << " bytes; expected 16, 24 or 32";
+    return rocksdb::Status::InvalidArgument(path, ss.str());
+  }
+  cipher->reset(new AESBlockCipher(key));
+  return rocksdb::Status::OK();
+}
+
+}  // namespace
+
+rocksdb::Status NewAESEncryptedEnv(const std::string& db_dir, const std::string& options,
+                                   rocksdb::Env* base_env, std::unique_ptr<EncryptedEnv>* env) {
+  std::unique_ptr<KeyProvider> provider(new KeyProvider);
+  bool have_active_key = false;
+
+  std::istringstream lines(options);
+  std::string line;
+  while (std::getline(lines, line)) {
+    if (line.empty()) {
+      continue;
+    }
+    const size_t eq = line.find('=');
+    if (eq == std::string::npos) {
+      return rocksdb::Status::InvalidArgument("malformed encryption option", line);
+    }
+    const std::string name = line.substr(0, eq);
+    const std::string path = line.substr(eq + 1);
+    const bool active = name == "key";
+    if (!active && name != "old-key") {
+      return rocksdb::Status::InvalidArgument("unknown encryption option", name);
+    }
+    if (active && have_active_key) {
+      return rocksdb::Status::InvalidArgument("multiple active encryption keys specified");
+    }
+    std::unique_ptr<AESBlockCipher> cipher;
+    rocksdb::Status status = LoadKey(path, &cipher);
+    if (!status.ok()) {
+      return status;
+    }
+    provider->AddKey(std::move(cipher), active);
+    have_active_key = have_active_key || active;
+  }
+  if (!have_active_key) {
+    return rocksdb::Status::InvalidArgument("no active encryption key specified");
+  }
+
+  // Refuse to open an existing unencrypted database: we would be unable
+  // to read any of its files.
+  if (!db_dir.empty()) {
+    const std::string current = db_dir + "/CURRENT";
+    std::string key_id;
+    rocksdb::Status status = AESEncryptedEnv::ReadKeyID(base_env, current, &key_id);
+    if (status.IsCorruption()) {
+      return rocksdb::Status::InvalidArgument(
+          db_dir, "store was created without encryption; encryption at rest can "
+          "only be enabled on new stores");
+    }
+    if (!status.ok() && !status.IsNotFound()) {
+      return status;
+    }
+  }
+
+  KeyProvider* p = provider.release();
+  env->reset(new AESEncryptedEnv(base_env, p, rocksdb::NewEncryptedEnv(base_env, p)));
+  return rocksdb::Status::OK();
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+#ifndef LIBROACHCCL_ENCRYPTED_ENV_H
+#define LIBROACHCCL_ENCRYPTED_ENV_H
+
+#include <memory>
+#include <rocksdb/env.h>
+#include "../db.h"
+
+// NewAESEncryptedEnv returns an EncryptedEnv wrapping base_env which
+// encrypts files using AES in counter mode. The options are newline
+// separated "key=<path>" and "old-key=<path>" entries naming files
+// which contain raw 16, 24 or 32 byte AES keys. Exactly one "key" entry
+// is required; it is used to encrypt new files. Files written under any
+// of the "old-key" entries can still be read, which allows keys to be
+// rotated.
+//
+// Encryption cannot be enabled on an existing unencrypted store, so an
+// error is returned if db_dir contains a database which was not written
+// by an encrypted Env.
+rocksdb::Status NewAESEncryptedEnv(const std::string& db_dir, const std::string& options,
+                                   rocksdb::Env* base_env, std::unique_ptr<EncryptedEnv>* env);
+
+#endif // LIBROACHCCL_ENCRYPTED_ENV_H
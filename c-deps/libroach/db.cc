@@ -50,6 +50,20 @@ void __attribute__((weak)) rocksDBLog(char*, int) { die_missing_symbol(__func__)
 char* __attribute__((weak)) prettyPrintKey(DBKey) { die_missing_symbol(__func__); }
 }  // extern "C"
 
+// DBOpenHook is replaced by the implementation in ccl/db.cc when
+// linking a CCL binary. Note that the CCL implementation lives in an
+// object file which is always linked into CCL binaries so that it is
+// preferred over this "weak" definition.
+rocksdb::Status __attribute__((weak)) DBOpenHook(
+    const std::string& db_dir, const DBOptions db_opts,
+    rocksdb::Env* base_env, std::unique_ptr<EncryptedEnv>* env) {
+  if (db_opts.extra_options.len != 0) {
+    return rocksdb::Status::InvalidArgument(
+        "encryption at rest requires a CCL build");
+  }
+  return rocksdb::Status::OK();
+}
+
 #if defined(COMPILER_GCC) || defined(__clang__)
 #define WARN_UNUSED_RESULT __attribute__((warn_unused_result))
 #else
@@ -80,6 +94,9 @@ struct DBEngine {
   virtual DBStatus GetStats(DBStatsResult* stats) = 0;
   virtual DBString GetCompactionStats() = 0;
   virtual DBStatus EnvWriteFile(DBSlice path, DBSlice contents) = 0;
+  virtual DBStatus EnvReadFile(DBSlice path, DBString* contents) = 0;
+  virtual DBStatus GetEncryptionStatus(DBEncryptionStatus* status) = 0;
+  virtual DBStatus ReencryptFiles(int* rewritten) = 0;
 
   DBSSTable* GetSSTables(int* n);
   DBString GetUserProperties();
@@ -87,17 +104,20 @@ struct DBEngine {
 
 struct DBImpl : public DBEngine {
   std::unique_ptr<rocksdb::Env> memenv;
+  std::unique_ptr<EncryptedEnv> encrypted_env;
   std::unique_ptr<rocksdb::DB> rep_deleter;
   std::shared_ptr<rocksdb::Cache> block_cache;
   std::shared_ptr<DBEventListener> event_listener;
 
-  // Construct a new DBImpl from the specified DB and Env. Both the DB
-  // and Env will be deleted when the DBImpl is deleted. It is ok to
-  // pass NULL for the Env.
-  DBImpl(rocksdb::DB* r, rocksdb::Env* m, std::shared_ptr<rocksdb::Cache> bc,
+  // Construct a new DBImpl from the specified DB and Envs. The DB and
+  // Envs will be deleted when the DBImpl is deleted. It is ok to pass
+  // NULL for either Env.
+  DBImpl(rocksdb::DB* r, rocksdb::Env* m, EncryptedEnv* e,
+    std::shared_ptr<rocksdb::Cache> bc,
     std::shared_ptr<DBEventListener> event_listener)
       : DBEngine(r),
         memenv(m),
+        encrypted_env(e),
         rep_deleter(r),
         block_cache(bc),
         event_listener(event_listener) {
@@ -122,6 +142,9 @@ struct DBImpl : public DBEngine {
   virtual DBStatus GetStats(DBStatsResult* stats);
   virtual DBString GetCompactionStats();
   virtual DBStatus EnvWriteFile(DBSlice path, DBSlice contents);
+  virtual DBStatus EnvReadFile(DBSlice path, DBString* contents);
+  virtual DBStatus GetEncryptionStatus(DBEncryptionStatus* status);
+  virtual DBStatus ReencryptFiles(int* rewritten);
 };
 
 struct DBBatch : public DBEngine {
@@ -144,6 +167,9 @@ struct DBBatch : public DBEngine {
   virtual DBStatus GetStats(DBStatsResult* stats);
   virtual DBString GetCompactionStats();
   virtual DBStatus EnvWriteFile(DBSlice path, DBSlice contents);
+  virtual DBStatus EnvReadFile(DBSlice path, DBString* contents);
+  virtual DBStatus GetEncryptionStatus(DBEncryptionStatus* status);
+  virtual DBStatus ReencryptFiles(int* rewritten);
 };
 
 struct DBWriteOnlyBatch : public DBEngine {
@@ -166,6 +192,9 @@ struct DBWriteOnlyBatch : public DBEngine {
   virtual DBStatus GetStats(DBStatsResult* stats);
   virtual DBString GetCompactionStats();
   virtual DBStatus EnvWriteFile(DBSlice path, DBSlice contents);
+  virtual DBStatus EnvReadFile(DBSlice path, DBString* contents);
+  virtual DBStatus GetEncryptionStatus(DBEncryptionStatus* status);
+  virtual DBStatus ReencryptFiles(int* rewritten);
 };
 
 struct DBSnapshot : public DBEngine {
@@ -190,6 +219,9 @@ struct DBSnapshot : public DBEngine {
   virtual DBStatus GetStats(DBStatsResult* stats);
   virtual DBString GetCompactionStats();
   virtual DBStatus EnvWriteFile(DBSlice path, DBSlice contents);
+  virtual DBStatus EnvReadFile(DBSlice path, DBString* contents);
+  virtual DBStatus GetEncryptionStatus(DBEncryptionStatus* status);
+  virtual DBStatus ReencryptFiles(int* rewritten);
 };
 
 struct DBIterator {
@@ -1665,12 +1697,22 @@ DBStatus DBOpen(DBEngine **db, DBSlice dir, DBOptions db_opts) {
     options.env = memenv.get();
   }
 
+  // Give CCL code the chance to wrap the Env with encryption.
+  std::unique_ptr<EncryptedEnv> encrypted_env;
+  rocksdb::Status status = DBOpenHook(ToString(dir), db_opts, options.env, &encrypted_env);
+  if (!status.ok()) {
+    return ToDBStatus(status);
+  }
+  if (encrypted_env != nullptr) {
+    options.env = encrypted_env.get();
+  }
+
   rocksdb::DB *db_ptr;
-  rocksdb::Status status = rocksdb::DB::Open(options, ToString(dir), &db_ptr);
+  status = rocksdb::DB::Open(options, ToString(dir), &db_ptr);
   if (!status.ok()) {
     return ToDBStatus(status);
   }
-  *db = new DBImpl(db_ptr, memenv.release(),
+  *db = new DBImpl(db_ptr, memenv.release(), encrypted_env.release(),
       db_opts.cache != nullptr ? db_opts.cache->rep : nullptr,
       event_listener);
   return kSuccess;
@@ -2019,6 +2061,13 @@ DBStatus DBImpl::GetStats(DBStatsResult* stats) {
   std::string table_readers_mem_estimate;
   rep->GetProperty("rocksdb.estimate-table-readers-mem", &table_readers_mem_estimate);
 
+  uint64_t l0_file_count = 0;
+  rep->GetIntProperty("rocksdb.num-files-at-level0", &l0_file_count);
+
+  uint64_t pending_compaction_bytes_estimate = 0;
+  rep->GetIntProperty("rocksdb.estimate-pending-compaction-bytes",
+                      &pending_compaction_bytes_estimate);
+
   stats->block_cache_hits = (int64_t)s->getTickerCount(rocksdb::BLOCK_CACHE_HIT);
   stats->block_cache_misses = (int64_t)s->getTickerCount(rocksdb::BLOCK_CACHE_MISS);
   stats->block_cache_usage = (int64_t)block_cache->GetUsage();
@@ -2033,6 +2082,8 @@ DBStatus DBImpl::GetStats(DBStatsResult* stats) {
   stats->flushes = (int64_t)event_listener->GetFlushes();
   stats->compactions = (int64_t)event_listener->GetCompactions();
   stats->table_readers_mem_estimate = std::stoll(table_readers_mem_estimate);
+  stats->l0_file_count = (int64_t)l0_file_count;
+  stats->pending_compaction_bytes_estimate = (int64_t)pending_compaction_bytes_estimate;
   return kSuccess;
 }
 
@@ -2101,6 +2152,133 @@ DBStatus DBEnvWriteFile(DBEngine* db, DBSlice path, DBSlice contents) {
   return db->EnvWriteFile(path, contents);
 }
 
+// EnvReadFile reads the contents of the given "file" in the given engine.
+DBStatus DBImpl::EnvReadFile(DBSlice path, DBString* contents) {
+  std::string data;
+  rocksdb::Status s = rocksdb::ReadFileToString(this->rep->GetEnv(), ToString(path), &data);
+  if (!s.ok()) {
+    return ToDBStatus(s);
+  }
+  *contents = ToDBString(data);
+  return kSuccess;
+}
+
+DBStatus DBBatch::EnvReadFile(DBSlice path, DBString* contents) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBWriteOnlyBatch::EnvReadFile(DBSlice path, DBString* contents) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBSnapshot::EnvReadFile(DBSlice path, DBString* contents) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBEnvReadFile(DBEngine* db, DBSlice path, DBString* contents) {
+  return db->EnvReadFile(path, contents);
+}
+
+DBStatus DBImpl::GetEncryptionStatus(DBEncryptionStatus* status) {
+  memset(status, 0, sizeof(*status));
+  if (encrypted_env == nullptr) {
+    return kSuccess;
+  }
+  const std::string active_key_id = encrypted_env->ActiveKeyID();
+
+  std::vector<rocksdb::LiveFileMetaData> metadata;
+  rep->GetLiveFilesMetaData(&metadata);
+  for (auto& m : metadata) {
+    std::string key_id;
+    rocksdb::Status s = encrypted_env->FileKeyID(m.db_path + m.name, &key_id);
+    if (s.IsNotFound()) {
+      // The file was deleted after we retrieved the list of live files.
+      continue;
+    }
+    if (!s.ok()) {
+      return ToDBStatus(s);
+    }
+    status->total_files++;
+    if (key_id != active_key_id) {
+      status->old_key_files++;
+    }
+  }
+  status->active_key_id = ToDBString(active_key_id);
+  return kSuccess;
+}
+
+DBStatus DBBatch::GetEncryptionStatus(DBEncryptionStatus* status) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBWriteOnlyBatch::GetEncryptionStatus(DBEncryptionStatus* status) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBSnapshot::GetEncryptionStatus(DBEncryptionStatus* status) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBGetEncryptionStatus(DBEngine* db, DBEncryptionStatus* status) {
+  return db->GetEncryptionStatus(status);
+}
+
+DBStatus DBImpl::ReencryptFiles(int* rewritten) {
+  *rewritten = 0;
+  if (encrypted_env == nullptr) {
+    return kSuccess;
+  }
+  const std::string active_key_id = encrypted_env->ActiveKeyID();
+
+  std::vector<rocksdb::LiveFileMetaData> metadata;
+  rep->GetLiveFilesMetaData(&metadata);
+  for (auto& m : metadata) {
+    if (m.level == 0 || m.being_compacted) {
+      continue;
+    }
+    std::string key_id;
+    rocksdb::Status s = encrypted_env->FileKeyID(m.db_path + m.name, &key_id);
+    if (s.IsNotFound()) {
+      continue;
+    }
+    if (!s.ok()) {
+      return ToDBStatus(s);
+    }
+    if (key_id == active_key_id) {
+      continue;
+    }
+    // Compacting a single file into its own level rewrites it (under
+    // the active key) without otherwise changing the LSM structure.
+    s = rep->CompactFiles(rocksdb::CompactionOptions(), {m.name}, m.level);
+    if (s.IsInvalidArgument() || s.IsAborted()) {
+      // The file was picked up by a concurrent compaction, which will
+      // rewrite it for us.
+      continue;
+    }
+    if (!s.ok()) {
+      return ToDBStatus(s);
+    }
+    (*rewritten)++;
+  }
+  return kSuccess;
+}
+
+DBStatus DBBatch::ReencryptFiles(int* rewritten) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBWriteOnlyBatch::ReencryptFiles(int* rewritten) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBSnapshot::ReencryptFiles(int* rewritten) {
+  return FmtStatus("unsupported");
+}
+
+DBStatus DBReencryptFiles(DBEngine* db, int* rewritten) {
+  return db->ReencryptFiles(rewritten);
+}
+
 DBIterator* DBNewIter(DBEngine* db, bool prefix) {
   rocksdb::ReadOptions opts;
   opts.prefix_same_as_start = prefix;
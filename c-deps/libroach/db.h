@@ -14,6 +14,7 @@
 
 #include <rocksdb/iterator.h>
 #include <rocksdb/comparator.h>
+#include <rocksdb/env.h>
 #include <rocksdb/write_batch.h>
 #include <rocksdb/write_batch_base.h>
 #include <libroach.h>
@@ -44,3 +45,29 @@ const ::rocksdb::Comparator* CockroachComparator();
 // Stats are only computed for keys between the given range.
 MVCCStatsResult MVCCComputeStatsInternal(
     ::rocksdb::Iterator* const iter_rep, DBKey start, DBKey end, int64_t now_nanos);
+
+// EncryptedEnv is an Env which encrypts the files it writes. The
+// implementation is provided by CCL code (see ccl/encrypted_env.cc).
+class EncryptedEnv : public rocksdb::EnvWrapper {
+ public:
+  explicit EncryptedEnv(rocksdb::Env* target)
+      : rocksdb::EnvWrapper(target) {
+  }
+  virtual ~EncryptedEnv() { }
+
+  // ActiveKeyID returns the ID of the key used to encrypt newly written
+  // files.
+  virtual std::string ActiveKeyID() = 0;
+  // FileKeyID returns the ID of the key the specified file was
+  // encrypted with.
+  virtual rocksdb::Status FileKeyID(const std::string& fname, std::string* key_id) = 0;
+};
+
+// DBOpenHook is called when opening a database with the specified
+// options and base Env. It is given the opportunity to return an
+// EncryptedEnv wrapping the base Env in *env, which will be used for
+// all of the database's files. The default implementation returns an
+// error if any extra options were specified; CCL builds replace it with
+// one which interprets them.
+rocksdb::Status DBOpenHook(const std::string& db_dir, const DBOptions db_opts,
+                           rocksdb::Env* base_env, std::unique_ptr<EncryptedEnv>* env);
@@ -71,6 +71,10 @@ typedef struct {
   bool logging_enabled;
   int num_cpu;
   int max_open_files;
+  // extra_options holds options which are interpreted by CCL code, such
+  // as the encryption-at-rest configuration. It must be empty when using
+  // a non-CCL build.
+  DBSlice extra_options;
 } DBOptions;
 
 // Create a new cache with the specified size.
@@ -235,6 +239,8 @@ typedef struct {
   int64_t flushes;
   int64_t compactions;
   int64_t table_readers_mem_estimate;
+  int64_t l0_file_count;
+  int64_t pending_compaction_bytes_estimate;
 } DBStatsResult;
 
 DBStatus DBGetStats(DBEngine* db, DBStatsResult* stats);
@@ -289,6 +295,32 @@ void DBRunLDB(int argc, char** argv);
 // DBEnvWriteFile writes the given data as a new "file" in the given engine.
 DBStatus DBEnvWriteFile(DBEngine* db, DBSlice path, DBSlice contents);
 
+// DBEnvReadFile reads the contents of the given "file" in the given engine.
+DBStatus DBEnvReadFile(DBEngine* db, DBSlice path, DBString* contents);
+
+// DBEncryptionStatus describes the encryption state of the live
+// sstables in an engine.
+typedef struct {
+  // active_key_id is the ID of the key used to encrypt newly written
+  // files. It is empty if the engine is not encrypted.
+  DBString active_key_id;
+  int64_t total_files;
+  // old_key_files is the number of live sstables which are encrypted
+  // with a key other than the active key.
+  int64_t old_key_files;
+} DBEncryptionStatus;
+
+// DBGetEncryptionStatus retrieves the encryption status of the engine.
+// Note that status->active_key_id must be freed.
+DBStatus DBGetEncryptionStatus(DBEngine* db, DBEncryptionStatus* status);
+
+// DBReencryptFiles rewrites the live sstables which are encrypted with
+// a key other than the active key, storing the number of rewritten
+// files in *rewritten. Files in L0 and files which are being compacted
+// are skipped as they will be rewritten by regular compactions. It is a
+// no-op if the engine is not encrypted.
+DBStatus DBReencryptFiles(DBEngine* db, int* rewritten);
+
 #ifdef __cplusplus
 }  // extern "C"
 #endif
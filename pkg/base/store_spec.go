@@ -48,6 +48,13 @@ type StoreSpec struct {
 	SizePercent float64
 	InMemory    bool
 	Attributes  roachpb.Attributes
+	// SeparateRaftLog stores raft log entries in a separate append-only log
+	// instead of in RocksDB.
+	SeparateRaftLog bool
+	// ExtraOptions is a serialized configuration passed to the storage engine
+	// which is interpreted by CCL code. It is not part of the --store flag and
+	// is instead populated by CCL flags such as --enterprise-encryption.
+	ExtraOptions []byte
 }
 
 // String returns a fully parsable version of the store spec.
@@ -75,6 +82,9 @@ func (ss StoreSpec) String() string {
 		}
 		fmt.Fprintf(&buffer, ",")
 	}
+	if ss.SeparateRaftLog {
+		fmt.Fprint(&buffer, "raft-log=separate,")
+	}
 	// Trim the extra comma from the end if it exists.
 	if l := buffer.Len(); l > 0 {
 		buffer.Truncate(l - 1)
@@ -98,7 +108,7 @@ var fractionRegex = regexp.MustCompile(`^([0-9]+\.[0-9]*|[0-9]*\.[0-9]+|[0-9]+(\
 
 // NewStoreSpec parses the string passed into a --store flag and returns a
 // StoreSpec if it is correctly parsed.
-// There are four possible fields that can be passed in, comma separated:
+// There are five possible fields that can be passed in, comma separated:
 // - path=xxx The directory in which to the rocks db instance should be
 //   located, required unless using a in memory storage.
 // - type=mem This specifies that the store is an in memory storage instead of
@@ -112,6 +122,8 @@ var fractionRegex = regexp.MustCompile(`^([0-9]+\.[0-9]*|[0-9]*\.[0-9]+|[0-9]+(\
 //   - 20%             -> 20% of the available space
 //   - 0.2             -> 20% of the available space
 // - attrs=xxx:yyy:zzz A colon separated list of optional attributes.
+// - raft-log=xxx Where raft log entries are stored: "rocksdb" (the default)
+//   or "separate" for a dedicated append-only log in the store directory.
 // Note that commas are forbidden within any field name or value.
 func NewStoreSpec(value string) (StoreSpec, error) {
 	if len(value) == 0 {
@@ -206,6 +218,15 @@ func NewStoreSpec(value string) (StoreSpec, error) {
 			} else {
 				return StoreSpec{}, fmt.Errorf("%s is not a valid store type", value)
 			}
+		case "raft-log":
+			switch value {
+			case "separate":
+				ss.SeparateRaftLog = true
+			case "rocksdb":
+				ss.SeparateRaftLog = false
+			default:
+				return StoreSpec{}, fmt.Errorf("%s is not a valid raft log format", value)
+			}
 		default:
 			return StoreSpec{}, fmt.Errorf("%s is not a valid store field", field)
 		}
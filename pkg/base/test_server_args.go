@@ -17,6 +17,7 @@ package base
 import (
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/util/retry"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
@@ -59,6 +60,9 @@ type TestServerArgs struct {
 	// will be used.
 	StoreSpecs []StoreSpec
 
+	// Locality is the locality of the test server.
+	Locality roachpb.Locality
+
 	// Fields copied to the server.Config.
 	Insecure                 bool
 	RetryOptions             retry.Options
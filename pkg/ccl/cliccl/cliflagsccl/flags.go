@@ -0,0 +1,35 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package cliflagsccl
+
+import "github.com/cockroachdb/cockroach/pkg/cli/cliflags"
+
+// EnterpriseEncryption and others store the static information for CLI flags
+// which are only available in CCL builds.
+var (
+	EnterpriseEncryption = cliflags.FlagInfo{
+		Name: "enterprise-encryption",
+		Description: `
+*Valid for the start command only*
+Enables encryption at rest for a store. The "path" field must match the path
+of one of the --store flags, and the "key" field names a file containing the
+16, 24 or 32 byte AES key used to encrypt newly written files. When rotating
+keys, the previous keys must be specified with "old-key" fields until all of
+the store's files have been re-encrypted with the new key. This flag must be
+specified separately for each encrypted store, for example:
+<PRE>
+
+  --enterprise-encryption=path=/mnt/ssd01,key=/keys/current.key
+  --enterprise-encryption=path=/mnt/ssd02,key=/keys/new.key,old-key=/keys/current.key
+
+</PRE>
+Encryption can only be enabled when a store is first created. The temporary
+store under the first store's path is encrypted along with it.`,
+	}
+)
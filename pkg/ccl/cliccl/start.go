@@ -0,0 +1,177 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package cliccl
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/ccl/cliccl/cliflagsccl"
+	"github.com/cockroachdb/cockroach/pkg/cli"
+)
+
+// storeEncryptionSpec contains the details that can be specified in the cli
+// via the --enterprise-encryption flag.
+type storeEncryptionSpec struct {
+	Path        string
+	KeyPath     string
+	OldKeyPaths []string
+}
+
+// String returns a fully parsable version of the encryption spec.
+func (es storeEncryptionSpec) String() string {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "path=%s,key=%s", es.Path, es.KeyPath)
+	for _, p := range es.OldKeyPaths {
+		fmt.Fprintf(&buffer, ",old-key=%s", p)
+	}
+	return buffer.String()
+}
+
+// extraOptions returns the serialized configuration passed to the store's
+// engine, which is interpreted by libroachccl.
+func (es storeEncryptionSpec) extraOptions() []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "key=%s\n", es.KeyPath)
+	for _, p := range es.OldKeyPaths {
+		fmt.Fprintf(&buffer, "old-key=%s\n", p)
+	}
+	return buffer.Bytes()
+}
+
+// newStoreEncryptionSpec parses the string passed in an --enterprise-encryption
+// flag. It consists of comma-separated fields: "path" is the path of the store
+// to encrypt, which must match the path of one of the --store flags, "key" is
+// the file containing the key used to encrypt new files and "old-key" (which
+// may be repeated) is a file containing a previously used key.
+func newStoreEncryptionSpec(value string) (storeEncryptionSpec, error) {
+	const pathField = "path"
+	var es storeEncryptionSpec
+	for _, split := range strings.Split(value, ",") {
+		if len(split) == 0 {
+			continue
+		}
+		subSplits := strings.SplitN(split, "=", 2)
+		if len(subSplits) == 1 {
+			return storeEncryptionSpec{}, errors.Errorf("field not in the form <key>=<value>: %s", split)
+		}
+		field := strings.ToLower(subSplits[0])
+		value, err := filepath.Abs(subSplits[1])
+		if err != nil {
+			return storeEncryptionSpec{}, errors.Wrapf(err, "could not find absolute path for %s", subSplits[1])
+		}
+
+		switch field {
+		case pathField:
+			if len(es.Path) != 0 {
+				return storeEncryptionSpec{}, errors.Errorf("%s field was used twice in encryption definition", field)
+			}
+			es.Path = value
+		case "key":
+			if len(es.KeyPath) != 0 {
+				return storeEncryptionSpec{}, errors.Errorf("%s field was used twice in encryption definition", field)
+			}
+			es.KeyPath = value
+		case "old-key":
+			es.OldKeyPaths = append(es.OldKeyPaths, value)
+		default:
+			return storeEncryptionSpec{}, errors.Errorf("%s is not a valid enterprise-encryption field", field)
+		}
+	}
+
+	if len(es.Path) == 0 {
+		return storeEncryptionSpec{}, errors.Errorf("no path specified")
+	}
+	if len(es.KeyPath) == 0 {
+		return storeEncryptionSpec{}, errors.Errorf("no key specified")
+	}
+	return es, nil
+}
+
+// encryptionSpecList contains a slice of storeEncryptionSpecs that implements
+// pflag's value interface.
+type encryptionSpecList struct {
+	Specs []storeEncryptionSpec
+}
+
+var _ pflag.Value = &encryptionSpecList{}
+
+// String returns a string representation of all the storeEncryptionSpecs.
+// This is part of pflag's value interface.
+func (encl encryptionSpecList) String() string {
+	var buffer bytes.Buffer
+	for _, es := range encl.Specs {
+		fmt.Fprintf(&buffer, "--%s=%s ", cliflagsccl.EnterpriseEncryption.Name, es)
+	}
+	// Trim the extra space from the end if it exists.
+	if l := buffer.Len(); l > 0 {
+		buffer.Truncate(l - 1)
+	}
+	return buffer.String()
+}
+
+// Type returns the underlying type in string form. This is part of pflag's
+// value interface.
+func (encl *encryptionSpecList) Type() string {
+	return "EncryptionSpec"
+}
+
+// Set adds a new value to the encryptionSpecList. It is the important part of
+// pflag's value interface.
+func (encl *encryptionSpecList) Set(value string) error {
+	spec, err := newStoreEncryptionSpec(value)
+	if err != nil {
+		return err
+	}
+	for _, es := range encl.Specs {
+		if es.Path == spec.Path {
+			return errors.Errorf("duplicate encryption settings for store %s", spec.Path)
+		}
+	}
+	encl.Specs = append(encl.Specs, spec)
+	return nil
+}
+
+// populateStoreSpecsEncryption sets the ExtraOptions of each store spec which
+// has a matching encryption spec.
+func populateStoreSpecsEncryption(storeSpecs base.StoreSpecList, encl encryptionSpecList) error {
+	for _, es := range encl.Specs {
+		found := false
+		for i := range storeSpecs.Specs {
+			if storeSpecs.Specs[i].Path != es.Path {
+				continue
+			}
+			storeSpecs.Specs[i].ExtraOptions = es.extraOptions()
+			found = true
+			break
+		}
+		if !found {
+			return errors.Errorf("no store with path %s found for encryption settings: %s", es.Path, es)
+		}
+	}
+	return nil
+}
+
+func init() {
+	var storeEncryptionSpecs encryptionSpecList
+	cli.VarFlag(cli.StartCmd.Flags(), &storeEncryptionSpecs, cliflagsccl.EnterpriseEncryption)
+
+	cli.AddPersistentPreRunE(cli.StartCmd, func(cmd *cobra.Command, _ []string) error {
+		// The store specs share their backing array with the server config, so
+		// modifying them in place updates the server config.
+		return populateStoreSpecsEncryption(cli.GetServerCfgStores(), storeEncryptionSpecs)
+	})
+}
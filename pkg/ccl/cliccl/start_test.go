@@ -0,0 +1,113 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package cliccl
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestNewStoreEncryptionSpec(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		value       string
+		expectedErr string
+		expected    storeEncryptionSpec
+	}{
+		{"path=/data,key=/keys/a", "", storeEncryptionSpec{"/data", "/keys/a", nil}},
+		{",key=/keys/a,,path=/data,", "", storeEncryptionSpec{"/data", "/keys/a", nil}},
+		{"path=/data,key=/keys/b,old-key=/keys/a", "",
+			storeEncryptionSpec{"/data", "/keys/b", []string{"/keys/a"}}},
+		{"path=/data,old-key=/keys/a,key=/keys/c,old-key=/keys/b", "",
+			storeEncryptionSpec{"/data", "/keys/c", []string{"/keys/a", "/keys/b"}}},
+
+		// error cases
+		{"", "no path specified", storeEncryptionSpec{}},
+		{"key=/keys/a", "no path specified", storeEncryptionSpec{}},
+		{"path=/data", "no key specified", storeEncryptionSpec{}},
+		{"path=/data,old-key=/keys/a", "no key specified", storeEncryptionSpec{}},
+		{"path=/data,path=/other,key=/keys/a", "path field was used twice in encryption definition",
+			storeEncryptionSpec{}},
+		{"path=/data,key=/keys/a,key=/keys/b", "key field was used twice in encryption definition",
+			storeEncryptionSpec{}},
+		{"path=/data,key", "field not in the form <key>=<value>: key", storeEncryptionSpec{}},
+		{"path=/data,key=/keys/a,cipher=aes", "cipher is not a valid enterprise-encryption field",
+			storeEncryptionSpec{}},
+	}
+
+	for i, testCase := range testCases {
+		es, err := newStoreEncryptionSpec(testCase.value)
+		if err != nil {
+			if testCase.expectedErr != fmt.Sprint(err) {
+				t.Errorf("%d(%s): expected error \"%s\" does not match actual \"%s\"", i, testCase.value,
+					testCase.expectedErr, err)
+			}
+			continue
+		}
+		if len(testCase.expectedErr) > 0 {
+			t.Errorf("%d(%s): expected error %s but there was none", i, testCase.value, testCase.expectedErr)
+			continue
+		}
+		if !reflect.DeepEqual(testCase.expected, es) {
+			t.Errorf("%d(%s): actual doesn't match expected\nactual:   %+v\nexpected: %+v", i,
+				testCase.value, es, testCase.expected)
+		}
+
+		// Now test String() to make sure the result can be parsed.
+		es2, err := newStoreEncryptionSpec(es.String())
+		if err != nil {
+			t.Errorf("%d(%s): error parsing String() result: %s", i, testCase.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(es, es2) {
+			t.Errorf("%d(%s): actual doesn't match expected\nactual:   %+v\nexpected: %+v", i,
+				testCase.value, es2, es)
+		}
+	}
+}
+
+func TestPopulateStoreSpecsEncryption(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	storeSpecs := base.StoreSpecList{
+		Specs: []base.StoreSpec{{Path: "/data1"}, {Path: "/data2"}, {InMemory: true}},
+	}
+
+	var encl encryptionSpecList
+	if err := encl.Set("path=/data2,key=/keys/b,old-key=/keys/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := encl.Set("path=/data2,key=/keys/c"); err == nil {
+		t.Fatal("expected duplicate encryption settings to be rejected")
+	}
+	if err := populateStoreSpecsEncryption(storeSpecs, encl); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]byte{nil, []byte("key=/keys/b\nold-key=/keys/a\n"), nil}
+	for i, spec := range storeSpecs.Specs {
+		if !reflect.DeepEqual(expected[i], spec.ExtraOptions) {
+			t.Errorf("%d: expected extra options %q, got %q", i, expected[i], spec.ExtraOptions)
+		}
+	}
+
+	if err := encl.Set("path=/data3,key=/keys/b"); err != nil {
+		t.Fatal(err)
+	}
+	const expectedErr = "no store with path /data3 found for encryption settings: path=/data3,key=/keys/b"
+	if err := populateStoreSpecsEncryption(storeSpecs, encl); !testutils.IsError(err, expectedErr) {
+		t.Fatalf("expected error %q, got %v", expectedErr, err)
+	}
+}
@@ -11,6 +11,7 @@ package sqlccl
 import (
 	"bytes"
 	"io/ioutil"
+	"net/url"
 	"sort"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/privilege"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/util/encoding"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/interval"
@@ -47,8 +49,29 @@ const (
 	// BackupDescriptorCheckpointName is the file name used to store the
 	// serialized BackupDescriptor proto while the backup is in progress.
 	BackupDescriptorCheckpointName = "BACKUP-CHECKPOINT"
+	// BackupEncryptionInfoName is the file name used to store the serialized
+	// EncryptionInfo proto of an encrypted backup. Unlike the rest of the
+	// backup, it is not encrypted.
+	BackupEncryptionInfoName = "ENCRYPTION-INFO"
 	// BackupFormatInitialVersion is the first version of backup and its files.
 	BackupFormatInitialVersion uint32 = 0
+
+	// backupOptRevisionHistory makes a backup contain every revision of its
+	// keys, rather than only the latest, so it can be restored to any time it
+	// covers.
+	backupOptRevisionHistory = "revision_history"
+	// backupOptEncPassphrase encrypts the files of a backup, or decrypts them
+	// when it is restored or imported, with a key derived from the passphrase.
+	// Passphrases are the only source of keys: keys held in a KMS or read from
+	// key files are not implemented.
+	backupOptEncPassphrase = "encryption_passphrase"
+
+	// localityURLParam is the URI parameter that marks each location of a
+	// locality-aware backup with the locality tier ("key=value") whose files
+	// are written to it, or with defaultLocalityValue for the location of the
+	// backup's descriptor and of every file not matched by another tier.
+	localityURLParam     = "COCKROACH_LOCALITY"
+	defaultLocalityValue = "default"
 )
 
 // BackupCheckpointInterval is the interval at which backup progress is saved
@@ -72,16 +95,107 @@ func exportStorageFromURI(ctx context.Context, uri string) (storageccl.ExportSto
 	return storageccl.MakeExportStorage(ctx, conf)
 }
 
+// getURIsByLocalityKV splits the URIs of the locations of a backup into the
+// URI of its default location and, for a locality-aware backup, the URIs of
+// its other locations keyed by their locality tier. The locality parameter is
+// removed from every returned URI. A single URI without the parameter is that
+// of a backup which is not locality-aware.
+func getURIsByLocalityKV(uris []string) (string, map[string]string, error) {
+	var defaultURI string
+	var urisByLocalityKV map[string]string
+	for _, uri := range uris {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return "", nil, err
+		}
+		q := parsed.Query()
+		localityKV := q.Get(localityURLParam)
+		if localityKV == "" {
+			if len(uris) == 1 {
+				return uri, nil, nil
+			}
+			return "", nil, errors.Errorf(
+				"%s parameter required for each of multiple locations", localityURLParam)
+		}
+		q.Del(localityURLParam)
+		parsed.RawQuery = q.Encode()
+
+		if localityKV == defaultLocalityValue {
+			if defaultURI != "" {
+				return "", nil, errors.Errorf(
+					"multiple locations with %s=%s", localityURLParam, localityKV)
+			}
+			defaultURI = parsed.String()
+			continue
+		}
+		var tier roachpb.Tier
+		if err := tier.FromString(localityKV); err != nil {
+			return "", nil, errors.Wrapf(err, "parsing %s parameter", localityURLParam)
+		}
+		if _, ok := urisByLocalityKV[localityKV]; ok {
+			return "", nil, errors.Errorf(
+				"multiple locations with %s=%s", localityURLParam, localityKV)
+		}
+		if urisByLocalityKV == nil {
+			urisByLocalityKV = make(map[string]string)
+		}
+		urisByLocalityKV[localityKV] = parsed.String()
+	}
+	if defaultURI == "" {
+		return "", nil, errors.Errorf(
+			"no location with %s=%s", localityURLParam, defaultLocalityValue)
+	}
+	return defaultURI, urisByLocalityKV, nil
+}
+
+// sanitizeLocalityURI is like storageccl.SanitizeExportStorageURI, but keeps
+// the locality parameter of a location of a locality-aware backup.
+func sanitizeLocalityURI(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	sanitized, err := storageccl.SanitizeExportStorageURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if localityKV := parsed.Query().Get(localityURLParam); localityKV != "" {
+		sanitized += "?" + url.Values{localityURLParam: {localityKV}}.Encode()
+	}
+	return sanitized, nil
+}
+
+// exportStorageConfsByLocalityKV returns the ExportStorage configs for the
+// given URIs, keyed like them by locality tier.
+func exportStorageConfsByLocalityKV(
+	urisByLocalityKV map[string]string,
+) (map[string]*roachpb.ExportStorage, error) {
+	if len(urisByLocalityKV) == 0 {
+		return nil, nil
+	}
+	confs := make(map[string]*roachpb.ExportStorage, len(urisByLocalityKV))
+	for localityKV, uri := range urisByLocalityKV {
+		conf, err := storageccl.ExportStorageConfFromURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		confs[localityKV] = &conf
+	}
+	return confs, nil
+}
+
 // readBackupDescriptorFromURI creates an export store from the given URI, then
 // reads and unmarshals a BackupDescriptor at the standard location in the
 // export storage.
-func readBackupDescriptorFromURI(ctx context.Context, uri string) (BackupDescriptor, error) {
+func readBackupDescriptorFromURI(
+	ctx context.Context, uri string, encryption *roachpb.FileEncryptionOptions,
+) (BackupDescriptor, error) {
 	exportStore, err := exportStorageFromURI(ctx, uri)
 	if err != nil {
 		return BackupDescriptor{}, err
 	}
 	defer exportStore.Close()
-	backupDesc, err := readBackupDescriptor(ctx, exportStore, BackupDescriptorName)
+	backupDesc, err := readBackupDescriptor(ctx, exportStore, BackupDescriptorName, encryption)
 	if err != nil {
 		return BackupDescriptor{}, err
 	}
@@ -92,9 +206,12 @@ func readBackupDescriptorFromURI(ctx context.Context, uri string) (BackupDescrip
 }
 
 // readBackupDescriptor reads and unmarshals a BackupDescriptor from filename in
-// the provided export store.
+// the provided export store, decrypting it if encryption is set.
 func readBackupDescriptor(
-	ctx context.Context, exportStore storageccl.ExportStorage, filename string,
+	ctx context.Context,
+	exportStore storageccl.ExportStorage,
+	filename string,
+	encryption *roachpb.FileEncryptionOptions,
 ) (BackupDescriptor, error) {
 	r, err := exportStore.ReadFile(ctx, filename)
 	if err != nil {
@@ -105,6 +222,15 @@ func readBackupDescriptor(
 	if err != nil {
 		return BackupDescriptor{}, err
 	}
+	if encryption != nil {
+		descBytes, err = storageccl.DecryptFile(descBytes, encryption.Key)
+		if err != nil {
+			return BackupDescriptor{}, err
+		}
+	} else if storageccl.AppearsEncrypted(descBytes) {
+		return BackupDescriptor{}, errors.Errorf(
+			"%s appears to be encrypted, use the %q option", filename, backupOptEncPassphrase)
+	}
 	var backupDesc BackupDescriptor
 	if err := backupDesc.Unmarshal(descBytes); err != nil {
 		return BackupDescriptor{}, err
@@ -114,14 +240,16 @@ func readBackupDescriptor(
 
 // ValidatePreviousBackups checks that the timestamps of previous backups are
 // consistent. The most recently backed-up time is returned.
-func ValidatePreviousBackups(ctx context.Context, uris []string) (hlc.Timestamp, error) {
+func ValidatePreviousBackups(
+	ctx context.Context, uris []string, encryption *roachpb.FileEncryptionOptions,
+) (hlc.Timestamp, error) {
 	if len(uris) == 0 || len(uris) == 1 && uris[0] == "" {
 		// Full backup.
 		return hlc.Timestamp{}, nil
 	}
 	backups := make([]BackupDescriptor, len(uris))
 	for i, uri := range uris {
-		desc, err := readBackupDescriptorFromURI(ctx, uri)
+		desc, err := readBackupDescriptorFromURI(ctx, uri, encryption)
 		if err != nil {
 			return hlc.Timestamp{}, err
 		}
@@ -260,19 +388,21 @@ func splitAndFilterSpans(
 }
 
 func backupJobDescription(
-	backup *parser.Backup, to string, incrementalFrom []string,
+	backup *parser.Backup, to []string, incrementalFrom []string,
 ) (string, error) {
 	b := parser.Backup{
 		AsOf:    backup.AsOf,
-		Options: backup.Options,
+		Options: redactEncryptionPassphrase(backup.Options),
 		Targets: backup.Targets,
 	}
 
-	to, err := storageccl.SanitizeExportStorageURI(to)
-	if err != nil {
-		return "", err
+	for _, t := range to {
+		sanitizedTo, err := sanitizeLocalityURI(t)
+		if err != nil {
+			return "", err
+		}
+		b.To = append(b.To, parser.NewDString(sanitizedTo))
 	}
-	b.To = parser.NewDString(to)
 
 	for _, from := range incrementalFrom {
 		sanitizedFrom, err := storageccl.SanitizeExportStorageURI(from)
@@ -312,6 +442,7 @@ func writeBackupDescriptor(
 	exportStore storageccl.ExportStorage,
 	filename string,
 	desc *BackupDescriptor,
+	encryption *roachpb.FileEncryptionOptions,
 ) error {
 	sort.Sort(backupFileDescriptors(desc.Files))
 
@@ -319,6 +450,12 @@ func writeBackupDescriptor(
 	if err != nil {
 		return err
 	}
+	if encryption != nil {
+		descBuf, err = storageccl.EncryptFile(descBuf, encryption.Key)
+		if err != nil {
+			return err
+		}
+	}
 
 	if err := exportStore.WriteFile(ctx, filename, bytes.NewReader(descBuf)); err != nil {
 		return err
@@ -327,17 +464,77 @@ func writeBackupDescriptor(
 	return nil
 }
 
-func makeBackupDescriptor(
-	ctx context.Context,
-	p sql.PlanHookState,
-	startTime, endTime hlc.Timestamp,
-	targets parser.TargetList,
-) (BackupDescriptor, error) {
-	var err error
-	var sqlDescs []sqlbase.Descriptor
+// writeEncryptionInfo writes the EncryptionInfo of an encrypted backup to the
+// provided export store.
+func writeEncryptionInfo(
+	ctx context.Context, exportStore storageccl.ExportStorage, info *EncryptionInfo,
+) error {
+	infoBuf, err := info.Marshal()
+	if err != nil {
+		return err
+	}
+	return exportStore.WriteFile(ctx, BackupEncryptionInfoName, bytes.NewReader(infoBuf))
+}
 
-	db := p.ExecCfg().DB
+// readEncryptionInfoFromURI reads and unmarshals the EncryptionInfo of the
+// encrypted backup at the given URI.
+func readEncryptionInfoFromURI(ctx context.Context, uri string) (EncryptionInfo, error) {
+	exportStore, err := exportStorageFromURI(ctx, uri)
+	if err != nil {
+		return EncryptionInfo{}, err
+	}
+	defer exportStore.Close()
+	r, err := exportStore.ReadFile(ctx, BackupEncryptionInfoName)
+	if err != nil {
+		return EncryptionInfo{}, errors.Wrapf(err, "reading %s, is the backup encrypted?", BackupEncryptionInfoName)
+	}
+	defer r.Close()
+	infoBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return EncryptionInfo{}, err
+	}
+	var info EncryptionInfo
+	if err := info.Unmarshal(infoBytes); err != nil {
+		return EncryptionInfo{}, err
+	}
+	return info, nil
+}
+
+// encryptionOptionsFromURI derives the key used to encrypt the backup at the
+// given URI from the passphrase and the salt stored alongside the backup.
+func encryptionOptionsFromURI(
+	ctx context.Context, uri string, passphrase string,
+) (*roachpb.FileEncryptionOptions, error) {
+	info, err := readEncryptionInfoFromURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return &roachpb.FileEncryptionOptions{
+		Key: storageccl.GenerateKey([]byte(passphrase), info.Salt),
+	}, nil
+}
+
+// redactEncryptionPassphrase returns a copy of opts with the value of the
+// encryption passphrase, if present, redacted, so it can be used in job
+// descriptions.
+func redactEncryptionPassphrase(opts parser.KVOptions) parser.KVOptions {
+	var redacted parser.KVOptions
+	for _, opt := range opts {
+		if string(opt.Key) == backupOptEncPassphrase {
+			opt.Value = parser.NewDString("redacted")
+		}
+		redacted = append(redacted, opt)
+	}
+	return redacted
+}
 
+// backupTargetDescriptors returns the descriptors, as of endTime, to be backed
+// up for the given targets, sorted by ID. Without targets, those of the full
+// cluster are backed up.
+func backupTargetDescriptors(
+	ctx context.Context, db *client.DB, endTime hlc.Timestamp, targets parser.TargetList,
+) ([]sqlbase.Descriptor, error) {
+	var sqlDescs []sqlbase.Descriptor
 	{
 		txn := client.NewTxn(db)
 		opt := client.TxnExecOptions{AutoRetry: true, AutoCommit: true}
@@ -348,14 +545,20 @@ func makeBackupDescriptor(
 			return err
 		})
 		if err != nil {
-			return BackupDescriptor{}, err
+			return nil, err
 		}
 	}
 
-	// TODO(dan): Plumb the session database down.
-	sessionDatabase := ""
-	if sqlDescs, err = descriptorsMatchingTargets(sessionDatabase, sqlDescs, targets); err != nil {
-		return BackupDescriptor{}, err
+	if targets.Empty() {
+		sqlDescs = fullClusterDescriptors(sqlDescs)
+	} else {
+		// TODO(dan): Plumb the session database down.
+		sessionDatabase := ""
+		var err error
+		sqlDescs, err = descriptorsMatchingTargets(sessionDatabase, sqlDescs, targets)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	sqlDescs = append(sqlDescs, BackupImplicitSQLDescriptors...)
@@ -375,6 +578,19 @@ func makeBackupDescriptor(
 	// Ensure interleaved tables appear after their parent. Since parents must be
 	// created before their children, simply sorting by ID accomplishes this.
 	sort.Slice(sqlDescs, func(i, j int) bool { return sqlDescs[i].GetID() < sqlDescs[j].GetID() })
+	return sqlDescs, nil
+}
+
+func makeBackupDescriptor(
+	ctx context.Context,
+	p sql.PlanHookState,
+	startTime, endTime hlc.Timestamp,
+	targets parser.TargetList,
+) (BackupDescriptor, error) {
+	sqlDescs, err := backupTargetDescriptors(ctx, p.ExecCfg().DB, endTime, targets)
+	if err != nil {
+		return BackupDescriptor{}, err
+	}
 
 	for _, desc := range sqlDescs {
 		if dbDesc := desc.GetDatabase(); dbDesc != nil {
@@ -406,9 +622,104 @@ func makeBackupDescriptor(
 		BuildInfo:     build.GetInfo(),
 		NodeID:        p.ExecCfg().NodeID.Get(),
 		ClusterID:     p.ExecCfg().ClusterID(),
+		FullCluster:   targets.Empty(),
 	}, nil
 }
 
+// getDescriptorRevisions returns the revisions of the descriptors in
+// backupDesc written between its start and end times, ordered by time, and
+// the time after which the returned revisions are complete. The history of
+// the descriptor table is exported to exportStore and read back, and the
+// exported files are then deleted.
+func getDescriptorRevisions(
+	ctx context.Context,
+	db *client.DB,
+	exportStore storageccl.ExportStorage,
+	backupDesc *BackupDescriptor,
+	encryption *roachpb.FileEncryptionOptions,
+) ([]BackupDescriptor_DescriptorRevision, hlc.Timestamp, error) {
+	inBackup := make(map[sqlbase.ID]struct{}, len(backupDesc.Descriptors))
+	for _, desc := range backupDesc.Descriptors {
+		inBackup[desc.GetID()] = struct{}{}
+	}
+
+	descTableStart := keys.MakeTablePrefix(keys.DescriptorTableID)
+	req := &roachpb.ExportRequest{
+		Span:       roachpb.Span{Key: descTableStart, EndKey: descTableStart.PrefixEnd()},
+		Storage:    exportStore.Conf(),
+		StartTime:  backupDesc.StartTime,
+		MVCCFilter: roachpb.MVCCFilter_All,
+		Encryption: encryption,
+	}
+	header := roachpb.Header{Timestamp: backupDesc.EndTime}
+	res, pErr := client.SendWrappedWith(ctx, db.GetSender(), header, req)
+	if pErr != nil {
+		return nil, hlc.Timestamp{}, errors.Wrap(pErr.GoError(), "exporting descriptor history")
+	}
+	exportRes := res.(*roachpb.ExportResponse)
+
+	descsPrefix := sqlbase.MakeAllDescsMetadataKey()
+	var revisions []BackupDescriptor_DescriptorRevision
+	for _, file := range exportRes.Files {
+		if err := func() error {
+			defer func() {
+				if err := exportStore.Delete(ctx, file.Path); err != nil {
+					log.Warningf(ctx, "unable to delete descriptor history file %s: %+v", file.Path, err)
+				}
+			}()
+			r, err := exportStore.ReadFile(ctx, file.Path)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			contents, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if encryption != nil {
+				if contents, err = storageccl.DecryptFile(contents, encryption.Key); err != nil {
+					return err
+				}
+			}
+
+			sst := engine.MakeRocksDBSstFileReader()
+			defer sst.Close()
+			if err := sst.IngestExternalFile(contents); err != nil {
+				return err
+			}
+			start, end := engine.MVCCKey{Key: file.Span.Key}, engine.MVCCKey{Key: file.Span.EndKey}
+			return sst.Iterate(start, end, func(kv engine.MVCCKeyValue) (bool, error) {
+				if !bytes.HasPrefix(kv.Key.Key, descsPrefix) {
+					return false, nil
+				}
+				_, id, err := encoding.DecodeUvarintAscending(kv.Key.Key[len(descsPrefix):])
+				if err != nil {
+					return false, err
+				}
+				if _, ok := inBackup[sqlbase.ID(id)]; !ok {
+					return false, nil
+				}
+				rev := BackupDescriptor_DescriptorRevision{Time: kv.Key.Timestamp, ID: sqlbase.ID(id)}
+				// An empty value is a deletion of the descriptor.
+				if len(kv.Value) > 0 {
+					var desc sqlbase.Descriptor
+					if err := (roachpb.Value{RawBytes: kv.Value}).GetProto(&desc); err != nil {
+						return false, err
+					}
+					rev.Desc = &desc
+				}
+				revisions = append(revisions, rev)
+				return false, nil
+			})
+		}(); err != nil {
+			return nil, hlc.Timestamp{}, errors.Wrapf(err, "reading descriptor history %s", file.Path)
+		}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Time.Less(revisions[j].Time) })
+	return revisions, exportRes.StartTime, nil
+}
+
 // backup exports a snapshot of every kv entry into ranged sstables.
 //
 // The output is an sstable per range with files in the following locations:
@@ -421,9 +732,11 @@ func backup(
 	db *client.DB,
 	gossip *gossip.Gossip,
 	exportStore storageccl.ExportStorage,
+	storageByLocalityKV map[string]*roachpb.ExportStorage,
 	job *jobs.Job,
 	backupDesc *BackupDescriptor,
 	checkpointDesc *BackupDescriptor,
+	encryption *roachpb.FileEncryptionOptions,
 ) error {
 	// TODO(dan): Figure out how permissions should work. #6713 is tracking this
 	// for grpc.
@@ -434,6 +747,9 @@ func backup(
 		exported       roachpb.BulkOpSummary
 		lastCheckpoint time.Time
 		checkpointed   bool
+		// revisionStartTime is the latest time after which any of the exports
+		// has every revision.
+		revisionStartTime hlc.Timestamp
 	}{}
 
 	var checkpointMu syncutil.Mutex
@@ -458,6 +774,7 @@ func backup(
 		mu.checkpointed = true
 		mu.files = checkpointDesc.Files
 		mu.exported = checkpointDesc.EntryCounts
+		mu.revisionStartTime = checkpointDesc.RevisionStartTime
 		for _, file := range checkpointDesc.Files {
 			completedSpans = append(completedSpans, file.Span)
 		}
@@ -481,6 +798,17 @@ func backup(
 	if err := job.Started(ctx); err != nil {
 		return err
 	}
+	// Keep the data being exported from being GC'd if the backup runs for
+	// longer than the GC TTL. A revision history backup exports every version
+	// since StartTime, so it protects StartTime instead, which also keeps every
+	// later version.
+	protectedTS := backupDesc.EndTime
+	if backupDesc.MVCCFilter == roachpb.MVCCFilter_All && backupDesc.StartTime != (hlc.Timestamp{}) {
+		protectedTS = backupDesc.StartTime
+	}
+	if err := job.ProtectTimestamp(ctx, backupDesc.Spans, protectedTS); err != nil {
+		return err
+	}
 
 	// We're already limiting these on the server-side, but sending all the
 	// Export requests at once would fill up distsender/grpc/something and cause
@@ -515,9 +843,12 @@ func backup(
 			defer func() { <-exportsSem }()
 
 			req := &roachpb.ExportRequest{
-				Span:      span,
-				Storage:   exportStore.Conf(),
-				StartTime: backupDesc.StartTime,
+				Span:                span,
+				Storage:             exportStore.Conf(),
+				StorageByLocalityKV: storageByLocalityKV,
+				StartTime:           backupDesc.StartTime,
+				MVCCFilter:          backupDesc.MVCCFilter,
+				Encryption:          encryption,
 			}
 			res, pErr := client.SendWrappedWith(gCtx, db.GetSender(), header, req)
 			if pErr != nil {
@@ -525,12 +856,16 @@ func backup(
 			}
 
 			mu.Lock()
+			if startTime := res.(*roachpb.ExportResponse).StartTime; mu.revisionStartTime.Less(startTime) {
+				mu.revisionStartTime = startTime
+			}
 			for _, file := range res.(*roachpb.ExportResponse).Files {
 				mu.files = append(mu.files, BackupDescriptor_File{
-					Span:     file.Span,
-					Path:     file.Path,
-					Sha512:   file.Sha512,
-					DataSize: uint64(file.Exported.DataSize),
+					Span:       file.Span,
+					Path:       file.Path,
+					Sha512:     file.Sha512,
+					DataSize:   uint64(file.Exported.DataSize),
+					LocalityKV: file.LocalityKV,
 				})
 				mu.exported.Add(file.Exported)
 			}
@@ -550,8 +885,11 @@ func backup(
 			if checkpointFiles != nil {
 				checkpointMu.Lock()
 				backupDesc.Files = checkpointFiles
+				mu.Lock()
+				backupDesc.RevisionStartTime = mu.revisionStartTime
+				mu.Unlock()
 				err := writeBackupDescriptor(
-					ctx, exportStore, BackupDescriptorCheckpointName, backupDesc,
+					ctx, exportStore, BackupDescriptorCheckpointName, backupDesc, encryption,
 				)
 				checkpointMu.Unlock()
 				if err != nil {
@@ -571,10 +909,24 @@ func backup(
 
 	// No more concurrency, so no need to acquire locks below.
 
+	if backupDesc.MVCCFilter == roachpb.MVCCFilter_All {
+		revs, revsStartTime, err := getDescriptorRevisions(ctx, db, exportStore, backupDesc, encryption)
+		if err != nil {
+			return err
+		}
+		backupDesc.DescriptorChanges = revs
+		if mu.revisionStartTime.Less(revsStartTime) {
+			mu.revisionStartTime = revsStartTime
+		}
+	}
+
 	backupDesc.Files = mu.files
 	backupDesc.EntryCounts = mu.exported
+	backupDesc.RevisionStartTime = mu.revisionStartTime
 
-	if err := writeBackupDescriptor(ctx, exportStore, BackupDescriptorName, backupDesc); err != nil {
+	if err := writeBackupDescriptor(
+		ctx, exportStore, BackupDescriptorName, backupDesc, encryption,
+	); err != nil {
 		return err
 	}
 
@@ -605,7 +957,7 @@ func backupPlanHook(
 		return nil, nil, err
 	}
 
-	toFn, err := p.TypeAsString(backupStmt.To, "BACKUP")
+	toFn, err := p.TypeAsStringArray(backupStmt.To, "BACKUP")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -613,6 +965,10 @@ func backupPlanHook(
 	if err != nil {
 		return nil, nil, err
 	}
+	optsFn, err := p.TypeAsStringOpts(backupStmt.Options)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	header := sqlbase.ResultColumns{
 		{Name: "job_id", Typ: parser.TypeInt},
@@ -628,7 +984,15 @@ func backupPlanHook(
 		ctx, span := tracing.ChildSpan(ctx, stmt.StatementTag())
 		defer tracing.FinishSpan(span)
 
-		to, err := toFn()
+		toList, err := toFn()
+		if err != nil {
+			return err
+		}
+		to, urisByLocalityKV, err := getURIsByLocalityKV(toList)
+		if err != nil {
+			return err
+		}
+		storageByLocalityKV, err := exportStorageConfsByLocalityKV(urisByLocalityKV)
 		if err != nil {
 			return err
 		}
@@ -636,11 +1000,41 @@ func backupPlanHook(
 		if err != nil {
 			return err
 		}
+		opts, err := optsFn()
+		if err != nil {
+			return err
+		}
+
+		mvccFilter := roachpb.MVCCFilter_Latest
+		if empty, ok := opts[backupOptRevisionHistory]; ok {
+			if empty != "" {
+				return errors.Errorf("option %q does not take a value", backupOptRevisionHistory)
+			}
+			mvccFilter = roachpb.MVCCFilter_All
+		}
+
+		// An encrypted incremental backup reuses the salt of the backups it is
+		// incremental from, so a chain of backups shares a single key.
+		var encryption *roachpb.FileEncryptionOptions
+		var encryptionInfo EncryptionInfo
+		if passphrase, ok := opts[backupOptEncPassphrase]; ok {
+			if len(incrementalFrom) > 0 {
+				encryptionInfo, err = readEncryptionInfoFromURI(ctx, incrementalFrom[0])
+			} else {
+				encryptionInfo.Salt, err = storageccl.GenerateSalt()
+			}
+			if err != nil {
+				return err
+			}
+			encryption = &roachpb.FileEncryptionOptions{
+				Key: storageccl.GenerateKey([]byte(passphrase), encryptionInfo.Salt),
+			}
+		}
 
 		var startTime hlc.Timestamp
 		if backupStmt.IncrementalFrom != nil {
 			var err error
-			startTime, err = ValidatePreviousBackups(ctx, incrementalFrom)
+			startTime, err = ValidatePreviousBackups(ctx, incrementalFrom, encryption)
 			if err != nil {
 				return err
 			}
@@ -675,8 +1069,15 @@ func backupPlanHook(
 		if err != nil {
 			return err
 		}
+		backupDesc.MVCCFilter = mvccFilter
 
-		description, err := backupJobDescription(backupStmt, to, incrementalFrom)
+		if encryption != nil {
+			if err := writeEncryptionInfo(ctx, exportStore, &encryptionInfo); err != nil {
+				return err
+			}
+		}
+
+		description, err := backupJobDescription(backupStmt, toList, incrementalFrom)
 		if err != nil {
 			return err
 		}
@@ -690,9 +1091,13 @@ func backupPlanHook(
 				return sqlDescIDs
 			}(),
 			Details: jobs.BackupDetails{
-				StartTime: startTime,
-				EndTime:   endTime,
-				URI:       to,
+				StartTime:        startTime,
+				EndTime:          endTime,
+				URI:              to,
+				URIsByLocalityKV: urisByLocalityKV,
+				MVCCFilter:       mvccFilter,
+				Encryption:       encryption,
+				FullCluster:      backupDesc.FullCluster,
 			},
 		})
 		var checkpointDesc *BackupDescriptor
@@ -700,9 +1105,11 @@ func backupPlanHook(
 			p.ExecCfg().DB,
 			p.ExecCfg().Gossip,
 			exportStore,
+			storageByLocalityKV,
 			job,
 			&backupDesc,
 			checkpointDesc,
+			encryption,
 		)
 		if err := job.FinishedWith(ctx, backupErr); err != nil {
 			return err
@@ -767,6 +1174,8 @@ func backupResumeHook(typ jobs.Type) func(context.Context, *jobs.Job) error {
 			BuildInfo:     build.GetInfo(),
 			NodeID:        job.NodeID(),
 			ClusterID:     job.ClusterID(),
+			MVCCFilter:    details.MVCCFilter,
+			FullCluster:   details.FullCluster,
 		}
 		conf, err := storageccl.ExportStorageConfFromURI(details.URI)
 		if err != nil {
@@ -776,8 +1185,14 @@ func backupResumeHook(typ jobs.Type) func(context.Context, *jobs.Job) error {
 		if err != nil {
 			return nil
 		}
+		storageByLocalityKV, err := exportStorageConfsByLocalityKV(details.URIsByLocalityKV)
+		if err != nil {
+			return err
+		}
 		var checkpointDesc *BackupDescriptor
-		if desc, err := readBackupDescriptor(ctx, exportStore, BackupDescriptorCheckpointName); err == nil {
+		if desc, err := readBackupDescriptor(
+			ctx, exportStore, BackupDescriptorCheckpointName, details.Encryption,
+		); err == nil {
 			checkpointDesc = &desc
 		} else {
 			// TODO(benesch): distinguish between a missing checkpoint, which simply
@@ -787,7 +1202,10 @@ func backupResumeHook(typ jobs.Type) func(context.Context, *jobs.Job) error {
 			// implementations.
 			log.Warningf(ctx, "unable to load backup checkpoint while resuming job %d: %v", *job.ID(), err)
 		}
-		return backup(ctx, job.DB(), job.Gossip(), exportStore, job, &backupDesc, checkpointDesc)
+		return backup(
+			ctx, job.DB(), job.Gossip(), exportStore, storageByLocalityKV, job,
+			&backupDesc, checkpointDesc, details.Encryption,
+		)
 	}
 }
 
@@ -813,6 +1231,10 @@ func showBackupPlanHook(
 	if err != nil {
 		return nil, nil, err
 	}
+	optsFn, err := p.TypeAsStringOpts(backup.Options)
+	if err != nil {
+		return nil, nil, err
+	}
 	header := sqlbase.ResultColumns{
 		{Name: "database", Typ: parser.TypeString},
 		{Name: "table", Typ: parser.TypeString},
@@ -829,7 +1251,22 @@ func showBackupPlanHook(
 		if err != nil {
 			return err
 		}
-		desc, err := readBackupDescriptorFromURI(ctx, str)
+		opts, err := optsFn()
+		if err != nil {
+			return err
+		}
+		var encryption *roachpb.FileEncryptionOptions
+		for k, v := range opts {
+			switch k {
+			case backupOptEncPassphrase:
+				if encryption, err = encryptionOptionsFromURI(ctx, str, v); err != nil {
+					return err
+				}
+			default:
+				return errors.Errorf("unknown SHOW BACKUP option %q", k)
+			}
+		}
+		desc, err := readBackupDescriptorFromURI(ctx, str, encryption)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,374 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
+	"github.com/cockroachdb/cockroach/pkg/ccl/utilccl"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing"
+)
+
+const (
+	// backupScheduleOptRetention is how far back in time the backups of a
+	// schedule must be able to restore to. Older backups are deleted.
+	backupScheduleOptRetention = "retention"
+
+	// backupScheduleDirFormat is the format of the name of the directory,
+	// under the collection URI of a schedule, of each backup it takes.
+	backupScheduleDirFormat = "20060102-150405.00"
+)
+
+func backupScheduleDescription(
+	schedule *parser.CreateBackupSchedule, to string,
+) (string, error) {
+	s := *schedule
+	to, err := storageccl.SanitizeExportStorageURI(to)
+	if err != nil {
+		return "", err
+	}
+	s.To = parser.NewDString(to)
+	return s.String(), nil
+}
+
+func createBackupSchedulePlanHook(
+	stmt parser.Statement, p sql.PlanHookState,
+) (func(context.Context, chan<- parser.Datums) error, sqlbase.ResultColumns, error) {
+	scheduleStmt, ok := stmt.(*parser.CreateBackupSchedule)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	if err := utilccl.CheckEnterpriseEnabled(
+		p.ExecCfg().Settings, p.ExecCfg().ClusterID(), p.ExecCfg().Organization.Get(), "BACKUP",
+	); err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.RequireSuperUser("CREATE SCHEDULE"); err != nil {
+		return nil, nil, err
+	}
+
+	toFn, err := p.TypeAsString(scheduleStmt.To, "CREATE SCHEDULE")
+	if err != nil {
+		return nil, nil, err
+	}
+	recurrenceFn, err := p.TypeAsString(scheduleStmt.Recurrence, "CREATE SCHEDULE")
+	if err != nil {
+		return nil, nil, err
+	}
+	fullRecurrenceFn := func() (string, error) { return "", nil }
+	if scheduleStmt.FullBackupRecurrence != nil {
+		fullRecurrenceFn, err = p.TypeAsString(scheduleStmt.FullBackupRecurrence, "CREATE SCHEDULE")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	optsFn, err := p.TypeAsStringOpts(scheduleStmt.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := sqlbase.ResultColumns{
+		{Name: "schedule_id", Typ: parser.TypeInt},
+		{Name: "next_run", Typ: parser.TypeTimestamp},
+	}
+	fn := func(ctx context.Context, resultsCh chan<- parser.Datums) error {
+		ctx, span := tracing.ChildSpan(ctx, stmt.StatementTag())
+		defer tracing.FinishSpan(span)
+
+		to, err := toFn()
+		if err != nil {
+			return err
+		}
+		recurrence, err := recurrenceFn()
+		if err != nil {
+			return err
+		}
+		fullRecurrence, err := fullRecurrenceFn()
+		if err != nil {
+			return err
+		}
+		opts, err := optsFn()
+		if err != nil {
+			return err
+		}
+
+		var retention time.Duration
+		for k, v := range opts {
+			switch k {
+			case backupScheduleOptRetention:
+				d, err := parser.ParseDInterval(v)
+				if err != nil {
+					return errors.Wrapf(err, "invalid %s", k)
+				}
+				nanos, _, _, err := d.Duration.Encode()
+				if err != nil {
+					return errors.Wrapf(err, "invalid %s", k)
+				}
+				if nanos <= 0 {
+					return errors.Errorf("%s must be positive, got %s", k, v)
+				}
+				retention = time.Duration(nanos)
+			default:
+				return errors.Errorf("unknown CREATE SCHEDULE option %q", k)
+			}
+		}
+		if fullRecurrence != "" {
+			if _, err := jobs.ParseRecurrence(fullRecurrence); err != nil {
+				return err
+			}
+		}
+
+		// Fail fast on an unusable collection or targets, rather than in every
+		// run of the schedule.
+		exportStore, err := exportStorageFromURI(ctx, to)
+		if err != nil {
+			return err
+		}
+		if err := exportStore.Close(); err != nil {
+			return err
+		}
+		if _, err := makeBackupDescriptor(
+			ctx, p, hlc.Timestamp{}, p.ExecCfg().Clock.Now(), scheduleStmt.Targets,
+		); err != nil {
+			return err
+		}
+
+		description, err := backupScheduleDescription(scheduleStmt, to)
+		if err != nil {
+			return err
+		}
+		schedule := &jobs.Schedule{
+			Description: description,
+			Owner:       p.User(),
+			Recurrence:  recurrence,
+			Details: jobs.ScheduleDetails{
+				Backup: &jobs.BackupScheduleDetails{
+					Targets:              parser.AsString(scheduleStmt.Targets),
+					URI:                  to,
+					FullBackupRecurrence: fullRecurrence,
+					Retention:            retention,
+				},
+			},
+		}
+		if err := p.ExecCfg().JobRegistry.CreateSchedule(ctx, schedule); err != nil {
+			return err
+		}
+		resultsCh <- parser.Datums{
+			parser.NewDInt(parser.DInt(schedule.ID)),
+			parser.MakeDTimestamp(schedule.NextRun, time.Microsecond),
+		}
+		return nil
+	}
+	return fn, header, nil
+}
+
+func backupScheduleHook(details *jobs.ScheduleDetails) jobs.ScheduleRunFn {
+	if details.Backup == nil {
+		return nil
+	}
+	return runBackupSchedule
+}
+
+// runBackupSchedule starts the next backup of a schedule. Each backup is
+// written to its own directory under the collection URI of the schedule and is
+// either a full backup or incremental from the previous backup of the
+// schedule. Full backups and the incremental backups that follow them form
+// chains, and chains which are no longer needed to restore to any time within
+// the retention period are deleted.
+func runBackupSchedule(ctx context.Context, s *jobs.Schedule) error {
+	details := s.Details.Backup
+	now := s.Now()
+
+	// Resolve the outcome of the backups started by previous runs. As each
+	// backup is incremental from the previous one, the schedule waits for the
+	// previous backup to finish.
+	backups := details.Backups[:0]
+	for _, b := range details.Backups {
+		if !b.Succeeded {
+			status, err := s.JobStatus(ctx, b.JobID)
+			if err != nil {
+				return err
+			}
+			switch status {
+			case jobs.StatusSucceeded:
+				b.Succeeded = true
+			case jobs.StatusPending, jobs.StatusRunning, jobs.StatusPaused:
+				return errors.Errorf("backup job %d is still %s", b.JobID, status)
+			default:
+				log.Warningf(ctx, "schedule %d: dropping backup job %d, which is %s", s.ID, b.JobID, status)
+				continue
+			}
+		}
+		backups = append(backups, b)
+	}
+	var expired []jobs.BackupScheduleDetails_Backup
+	if details.Retention != 0 {
+		backups, expired = expireBackups(backups, now.GoTime().Add(-details.Retention))
+	}
+
+	full := true
+	var incrementalFrom []string
+	var startTime hlc.Timestamp
+	if len(backups) > 0 && details.FullBackupRecurrence != "" {
+		lastFull := -1
+		for i := range backups {
+			if backups[i].Full {
+				lastFull = i
+			}
+		}
+		if lastFull >= 0 {
+			recurrence, err := jobs.ParseRecurrence(details.FullBackupRecurrence)
+			if err != nil {
+				return err
+			}
+			full = !now.GoTime().Before(recurrence.Next(backups[lastFull].EndTime.GoTime()))
+			if !full {
+				for _, b := range backups[lastFull:] {
+					incrementalFrom = append(incrementalFrom, b.URI)
+				}
+				startTime = backups[len(backups)-1].EndTime
+			}
+		}
+	}
+
+	stmt, err := parser.ParseOne("BACKUP " + details.Targets + " TO ''")
+	if err != nil {
+		return errors.Wrap(err, "parsing schedule targets")
+	}
+	backupStmt := stmt.(*parser.Backup)
+	sqlDescs, err := backupTargetDescriptors(ctx, s.DB(), now, backupStmt.Targets)
+	if err != nil {
+		return err
+	}
+
+	collection, err := url.Parse(details.URI)
+	if err != nil {
+		return err
+	}
+	collection.Path = path.Join(collection.Path, now.GoTime().Format(backupScheduleDirFormat))
+	to := collection.String()
+
+	description, err := backupJobDescription(backupStmt, []string{to}, incrementalFrom)
+	if err != nil {
+		return err
+	}
+	var sqlDescIDs []sqlbase.ID
+	for _, sqlDesc := range sqlDescs {
+		sqlDescIDs = append(sqlDescIDs, sqlDesc.GetID())
+	}
+	backupDetails := jobs.BackupDetails{
+		StartTime: startTime,
+		EndTime:   now,
+		URI:       to,
+	}
+	jobID, err := s.StartJob(ctx, jobs.Record{
+		Description:   description,
+		Username:      s.Owner,
+		DescriptorIDs: sqlDescIDs,
+		Details:       backupDetails,
+	})
+	if err != nil {
+		return err
+	}
+
+	details.Backups = append(backups, jobs.BackupScheduleDetails_Backup{
+		URI:        to,
+		Full:       full,
+		EndTime:    now,
+		JobID:      jobID,
+		Encryption: backupDetails.Encryption,
+	})
+
+	// The expired backups are only deleted once the schedule no longer refers
+	// to them, and only if none of the remaining backups are stored in the
+	// same place.
+	if len(expired) > 0 {
+		retained := make(map[string]struct{}, len(details.Backups))
+		for _, b := range details.Backups {
+			retained[b.URI] = struct{}{}
+		}
+		s.AfterCommit(func(ctx context.Context) {
+			for _, b := range expired {
+				if _, ok := retained[b.URI]; ok {
+					continue
+				}
+				if err := deleteBackup(ctx, b.URI, b.Encryption); err != nil {
+					sanitized, _ := storageccl.SanitizeExportStorageURI(b.URI)
+					log.Warningf(ctx, "unable to delete expired backup %s: %+v", sanitized, err)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// expireBackups splits the backups into those which are needed to restore to
+// any time after cutoff and the chains of backups which are not, that is
+// those followed by a full backup taken before cutoff.
+func expireBackups(
+	backups []jobs.BackupScheduleDetails_Backup, cutoff time.Time,
+) (retained, expired []jobs.BackupScheduleDetails_Backup) {
+	keepFrom := 0
+	for i, b := range backups {
+		if b.Full && b.EndTime.GoTime().Before(cutoff) {
+			keepFrom = i
+		}
+	}
+	expired = append(expired, backups[:keepFrom]...)
+	return backups[keepFrom:], expired
+}
+
+// deleteBackup deletes the files of the backup at uri, and then its
+// descriptor and, if it is encrypted, its encryption info.
+func deleteBackup(
+	ctx context.Context, uri string, encryption *roachpb.FileEncryptionOptions,
+) error {
+	exportStore, err := exportStorageFromURI(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer exportStore.Close()
+
+	desc, err := readBackupDescriptor(ctx, exportStore, BackupDescriptorName, encryption)
+	if err != nil {
+		return err
+	}
+	for _, file := range desc.Files {
+		if err := exportStore.Delete(ctx, file.Path); err != nil {
+			return err
+		}
+	}
+	if err := exportStore.Delete(ctx, BackupDescriptorName); err != nil {
+		return err
+	}
+	if encryption != nil {
+		return exportStore.Delete(ctx, BackupEncryptionInfoName)
+	}
+	return nil
+}
+
+func init() {
+	sql.AddPlanHook(createBackupSchedulePlanHook)
+	jobs.AddScheduleHook(backupScheduleHook)
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestBackupSchedule(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	defer func(oldInterval time.Duration) {
+		jobs.DefaultScheduleInterval = oldInterval
+	}(jobs.DefaultScheduleInterval)
+	jobs.DefaultScheduleInterval = 100 * time.Millisecond
+
+	const numAccounts = 10
+	_, dir, _, sqlDB, cleanupFn := backupRestoreTestSetup(t, singleNode, numAccounts, initNone)
+	defer cleanupFn()
+
+	var scheduleID int64
+	var nextRun time.Time
+	sqlDB.QueryRow(
+		`CREATE SCHEDULE FOR BACKUP DATABASE data TO $1 RECURRING '@hourly' FULL BACKUP '@yearly'`, dir,
+	).Scan(&scheduleID, &nextRun)
+	if now := time.Now(); !nextRun.After(now) || nextRun.Sub(now) > time.Hour {
+		t.Fatalf("expected next run within the hour, got %s", nextRun)
+	}
+
+	// runSchedule forces a run of the schedule and waits for the backup it
+	// starts to succeed, returning the job's description.
+	var descriptions []string
+	runSchedule := func() string {
+		sqlDB.Exec(`UPDATE system.schedules SET next_run = now() WHERE id = $1`, scheduleID)
+		testutils.SucceedsSoon(t, func() error {
+			rows := sqlDB.Query(
+				`SELECT description, status FROM crdb_internal.jobs
+				 WHERE description LIKE 'BACKUP DATABASE data TO%' ORDER BY created`)
+			defer rows.Close()
+			var found []string
+			for rows.Next() {
+				var description, status string
+				if err := rows.Scan(&description, &status); err != nil {
+					t.Fatal(err)
+				}
+				if status != string(jobs.StatusSucceeded) {
+					return errors.Errorf("backup job %q is %s", description, status)
+				}
+				found = append(found, description)
+			}
+			if len(found) != len(descriptions)+1 {
+				return errors.Errorf("expected %d backup jobs, found %d", len(descriptions)+1, len(found))
+			}
+			descriptions = found
+			return nil
+		})
+		return descriptions[len(descriptions)-1]
+	}
+
+	if description := runSchedule(); strings.Contains(description, "INCREMENTAL") {
+		t.Fatalf("expected a full backup, got %s", description)
+	}
+	sqlDB.Exec(`INSERT INTO data.bank VALUES ($1, 0, 'new')`, numAccounts)
+	if description := runSchedule(); !strings.Contains(description, "INCREMENTAL FROM") {
+		t.Fatalf("expected an incremental backup, got %s", description)
+	}
+
+	// Each backup is written to its own directory under the collection.
+	entries, err := ioutil.ReadDir(strings.TrimPrefix(dir, "nodelocal://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backups []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			backups = append(backups, `'`+dir+`/`+entry.Name()+`'`)
+		}
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backup directories, found %v", backups)
+	}
+
+	sqlDB.Exec(`DROP DATABASE data CASCADE`)
+	sqlDB.Exec(`RESTORE DATABASE data FROM ` + strings.Join(backups, ", "))
+	var count int
+	sqlDB.QueryRow(`SELECT COUNT(*) FROM data.bank`).Scan(&count)
+	if count != numAccounts+1 {
+		t.Fatalf("expected %d rows, got %d", numAccounts+1, count)
+	}
+}
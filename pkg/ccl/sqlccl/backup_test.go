@@ -36,6 +36,7 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/ccl/sqlccl"
+	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
 	"github.com/cockroachdb/cockroach/pkg/ccl/utilccl/sampledataccl"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
@@ -1590,6 +1591,60 @@ func TestBackupAsOfSystemTime(t *testing.T) {
 	}
 }
 
+func TestRestoreAsOfSystemTime(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const numAccounts = 10
+
+	_, dir, _, sqlDB, cleanupFn := backupRestoreTestSetup(t, multiNode, numAccounts, initNone)
+	defer cleanupFn()
+
+	var ts [3]string
+	sqlDB.QueryRow(`SELECT cluster_logical_timestamp()`).Scan(&ts[0])
+	sqlDB.Exec(`DELETE FROM data.bank WHERE id >= $1`, numAccounts/2)
+	sqlDB.Exec(`CREATE TABLE data.other (a INT PRIMARY KEY)`)
+	sqlDB.QueryRow(`SELECT cluster_logical_timestamp()`).Scan(&ts[1])
+	sqlDB.Exec(`UPDATE data.bank SET balance = balance + 1`)
+
+	fullDir, incDir, latestDir := filepath.Join(dir, "full"), filepath.Join(dir, "inc"), filepath.Join(dir, "latest")
+	sqlDB.Exec(`BACKUP DATABASE data TO $1 WITH revision_history`, fullDir)
+	sqlDB.Exec(`INSERT INTO data.bank VALUES ($1, 0, '')`, numAccounts)
+	sqlDB.QueryRow(`SELECT cluster_logical_timestamp()`).Scan(&ts[2])
+	sqlDB.Exec(`BACKUP DATABASE data TO $1 INCREMENTAL FROM $2 WITH revision_history`, incDir, fullDir)
+	sqlDB.Exec(`BACKUP DATABASE data TO $1`, latestDir)
+
+	for i, expected := range []int{numAccounts, numAccounts / 2, numAccounts/2 + 1} {
+		sqlDB.Exec(`DROP TABLE IF EXISTS data.bank`)
+		sqlDB.Exec(`DROP TABLE IF EXISTS data.other`)
+		sqlDB.Exec(fmt.Sprintf(`RESTORE data.* FROM $1, $2 AS OF SYSTEM TIME %s`, ts[i]), fullDir, incDir)
+		var rowCount int
+		sqlDB.QueryRow(`SELECT COUNT(*) FROM data.bank`).Scan(&rowCount)
+		if rowCount != expected {
+			t.Fatalf("%d: expected %d rows but found %d", i, expected, rowCount)
+		}
+		// data.other was created after ts[0], so it is only restored as of later.
+		var otherCount int
+		sqlDB.QueryRow(
+			`SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'data' AND table_name = 'other'`,
+		).Scan(&otherCount)
+		expectedOther := 1
+		if i == 0 {
+			expectedOther = 0
+		}
+		if otherCount != expectedOther {
+			t.Fatalf("%d: expected %d tables named other but found %d", i, expectedOther, otherCount)
+		}
+	}
+
+	// Without revision history, only the end time of the backup is restorable.
+	sqlDB.Exec(`DROP TABLE data.bank`)
+	if _, err := sqlDB.DB.Exec(
+		fmt.Sprintf(`RESTORE data.* FROM $1 AS OF SYSTEM TIME %s`, ts[0]), latestDir,
+	); !testutils.IsError(err, "invalid RESTORE timestamp") {
+		t.Fatalf("expected invalid RESTORE timestamp error, got %v", err)
+	}
+}
+
 func TestAsOfSystemTimeOnRestoredData(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -1851,6 +1906,71 @@ func TestRestoreInto(t *testing.T) {
 	sqlDB.CheckQueryResults(`SELECT * FROM "data 2".bank`, expected)
 }
 
+func TestBackupRestoreFullCluster(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const numAccounts = 10
+	_, dir, _, sqlDB, cleanupFn := backupRestoreTestSetup(t, singleNode, numAccounts, initNone)
+	defer cleanupFn()
+	fullDir, dbDir := dir+"/full", dir+"/db"
+
+	sqlDB.Exec(`CREATE USER someone`)
+	sqlDB.Exec(`GRANT SELECT, INSERT ON data.bank TO someone`)
+	sqlDB.Exec(`CREATE DATABASE other`)
+	sqlDB.Exec(`CREATE TABLE other.t (a INT PRIMARY KEY REFERENCES data.bank (id))`)
+	sqlDB.Exec(`INSERT INTO other.t VALUES (1), (2)`)
+	sqlDB.Exec(`SET CLUSTER SETTING sql.defaults.distsql = 'on'`)
+	// Give data.bank the zone config of the default zone.
+	sqlDB.Exec(`INSERT INTO system.zones (id, config)
+		SELECT n.id, z.config FROM system.namespace n, system.zones z
+		WHERE n.name = 'bank' AND z.id = 0`)
+	// A finished job, which is restored as part of the jobs history.
+	sqlDB.Exec(`BACKUP DATABASE data TO $1`, dbDir)
+
+	sqlDB.Exec(`BACKUP TO $1`, fullDir)
+
+	const (
+		grantsQuery  = `SHOW GRANTS ON data.bank`
+		settingQuery = `SELECT value FROM system.settings WHERE name = 'sql.defaults.distsql'`
+		zoneQuery    = `SELECT z.config FROM system.zones z, system.namespace n
+			WHERE n.name = 'bank' AND z.id = n.id`
+		jobsQuery = `SELECT description FROM crdb_internal.jobs
+			WHERE status = 'succeeded' AND description LIKE 'BACKUP DATABASE%'`
+	)
+
+	tcRestore := testcluster.StartTestCluster(t, singleNode, base.TestClusterArgs{})
+	defer tcRestore.Stopper().Stop(context.TODO())
+	sqlDBRestore := sqlutils.MakeSQLRunner(t, tcRestore.Conns[0])
+
+	if _, err := sqlDBRestore.DB.Exec(`RESTORE FROM $1`, dbDir); !testutils.IsError(
+		err, "RESTORE without targets requires a full cluster backup",
+	) {
+		t.Fatalf("expected RESTORE of a database backup to fail, got %v", err)
+	}
+
+	sqlDBRestore.Exec(`RESTORE FROM $1`, fullDir)
+
+	for _, table := range []string{`data.bank`, `other.t`} {
+		query := fmt.Sprintf(`SELECT * FROM %s`, table)
+		sqlDBRestore.CheckQueryResults(query, sqlDB.QueryStr(query))
+	}
+	sqlDBRestore.CheckQueryResults(grantsQuery, sqlDB.QueryStr(grantsQuery))
+	sqlDBRestore.CheckQueryResults(`SELECT username FROM system.users`, [][]string{{"someone"}})
+	sqlDBRestore.CheckQueryResults(settingQuery, sqlDB.QueryStr(settingQuery))
+	sqlDBRestore.CheckQueryResults(zoneQuery, sqlDB.QueryStr(zoneQuery))
+	sqlDBRestore.CheckQueryResults(jobsQuery, sqlDB.QueryStr(jobsQuery))
+	// The database the system tables were restored into is dropped.
+	sqlDBRestore.CheckQueryResults(
+		`SELECT count(*) FROM system.namespace WHERE name = 'crdb_temp_system'`, [][]string{{"0"}},
+	)
+
+	if _, err := sqlDBRestore.DB.Exec(`RESTORE FROM $1`, fullDir); !testutils.IsError(
+		err, `a RESTORE without targets requires an empty cluster, but database "data" exists`,
+	) {
+		t.Fatalf("expected RESTORE into a non-empty cluster to fail, got %v", err)
+	}
+}
+
 func TestBackupRestorePermissions(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -1921,6 +2041,151 @@ func TestShowBackup(t *testing.T) {
 	}
 }
 
+func TestBackupRestoreEncrypted(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const numAccounts = 10
+	_, dir, _, sqlDB, cleanupFn := backupRestoreTestSetup(t, singleNode, numAccounts, initNone)
+	defer cleanupFn()
+
+	fullDir := filepath.Join(dir, "full")
+	incDir := filepath.Join(dir, "inc")
+	const opts = `WITH encryption_passphrase = 'abcdefg'`
+
+	sqlDB.Exec(`BACKUP DATABASE data TO $1 `+opts, fullDir)
+	sqlDB.Exec(`INSERT INTO data.bank VALUES ($1, 0, 'new')`, numAccounts)
+	sqlDB.Exec(`BACKUP DATABASE data TO $1 INCREMENTAL FROM $2 `+opts, incDir, fullDir)
+
+	// None of the files but the encryption info are readable without the key.
+	files, err := filepath.Glob(filepath.Join(strings.TrimPrefix(dir, "nodelocal://"), "full", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if filepath.Base(file) == sqlccl.BackupEncryptionInfoName {
+			continue
+		}
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !storageccl.AppearsEncrypted(contents) {
+			t.Errorf("expected %s to be encrypted", file)
+		}
+	}
+
+	var jobDescription string
+	sqlDB.QueryRow(
+		`SELECT description FROM crdb_internal.jobs WHERE type = 'BACKUP' ORDER BY created LIMIT 1`,
+	).Scan(&jobDescription)
+	if strings.Contains(jobDescription, "abcdefg") {
+		t.Errorf("expected passphrase to be redacted from job description: %s", jobDescription)
+	}
+
+	var tableName string
+	sqlDB.QueryRow(`SELECT "table" FROM [SHOW BACKUP $1 `+opts+`]`, fullDir).Scan(&tableName)
+	if tableName != "bank" {
+		t.Errorf("expected table bank, got %q", tableName)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{"show missing passphrase", `SHOW BACKUP $1`, "appears to be encrypted"},
+		{"show wrong passphrase", `SHOW BACKUP $1 WITH encryption_passphrase = 'wrong'`, "passphrase correct"},
+		{"restore missing passphrase", `RESTORE data.* FROM $1`, "appears to be encrypted"},
+		{"restore wrong passphrase", `RESTORE data.* FROM $1 WITH encryption_passphrase = 'wrong'`, "passphrase correct"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := sqlDB.DB.Exec(tc.query, fullDir); !testutils.IsError(err, tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	sqlDB.Exec(`DROP DATABASE data CASCADE`)
+	sqlDB.Exec(`RESTORE DATABASE data FROM $1, $2 `+opts, fullDir, incDir)
+	var count int
+	sqlDB.QueryRow(`SELECT COUNT(*) FROM data.bank`).Scan(&count)
+	if count != numAccounts+1 {
+		t.Fatalf("expected %d rows, got %d", numAccounts+1, count)
+	}
+}
+
+func TestBackupRestoreLocalityAware(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const numAccounts = 10
+	params := base.TestClusterArgs{}
+	params.ServerArgs.Locality = roachpb.Locality{
+		Tiers: []roachpb.Tier{{Key: "region", Value: "east"}},
+	}
+	_, dir, _, sqlDB, cleanupFn := backupRestoreTestSetupWithParams(
+		t, singleNode, numAccounts, initNone, params,
+	)
+	defer cleanupFn()
+
+	rawDir := strings.TrimPrefix(dir, "nodelocal://")
+	defaultURI := filepath.Join(dir, "default") + "?COCKROACH_LOCALITY=default"
+	eastURI := filepath.Join(dir, "east") + "?COCKROACH_LOCALITY=" + url.QueryEscape("region=east")
+	westURI := filepath.Join(dir, "west") + "?COCKROACH_LOCALITY=" + url.QueryEscape("region=west")
+
+	for _, tc := range []struct {
+		name string
+		uris []interface{}
+		err  string
+	}{
+		{"missing default", []interface{}{eastURI, westURI}, "no location with COCKROACH_LOCALITY=default"},
+		{"missing locality", []interface{}{defaultURI, filepath.Join(dir, "east")}, "parameter required"},
+		{"duplicate default", []interface{}{defaultURI, defaultURI}, "multiple locations"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := sqlDB.DB.Exec(
+				`BACKUP DATABASE data TO ($1, $2)`, tc.uris...,
+			); !testutils.IsError(err, tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	sqlDB.Exec(`BACKUP DATABASE data TO ($1, $2, $3)`, defaultURI, eastURI, westURI)
+
+	// The descriptor is in the default location and the data, all of whose
+	// leaseholders are in region=east, is in the matching one.
+	for _, tc := range []struct {
+		glob     string
+		expected bool
+	}{
+		{filepath.Join(rawDir, "default", sqlccl.BackupDescriptorName), true},
+		{filepath.Join(rawDir, "default", "*.sst"), false},
+		{filepath.Join(rawDir, "east", "*.sst"), true},
+		{filepath.Join(rawDir, "west", "*.sst"), false},
+	} {
+		files, err := filepath.Glob(tc.glob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := len(files) > 0; found != tc.expected {
+			t.Errorf("%s: expected files %t, found %d", tc.glob, tc.expected, len(files))
+		}
+	}
+
+	sqlDB.Exec(`DROP DATABASE data CASCADE`)
+	if _, err := sqlDB.DB.Exec(
+		`RESTORE DATABASE data FROM $1`, defaultURI,
+	); !testutils.IsError(err, "COCKROACH_LOCALITY=region=east, which was not given") {
+		t.Fatalf("expected missing location error, got %v", err)
+	}
+	sqlDB.Exec(`RESTORE DATABASE data FROM ($1, $2)`, defaultURI, eastURI)
+	var count int
+	sqlDB.QueryRow(`SELECT COUNT(*) FROM data.bank`).Scan(&count)
+	if count != numAccounts {
+		t.Fatalf("expected %d rows, got %d", numAccounts, count)
+	}
+}
+
 func TestBackupAzureAccountName(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -0,0 +1,748 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/cockroachdb/cockroach/pkg/ccl/utilccl"
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing"
+)
+
+const (
+	// changefeedOptCursor starts the changefeed from the given timestamp,
+	// instead of from the time the statement was run.
+	changefeedOptCursor = "cursor"
+	// changefeedOptResolved emits resolved timestamp messages to the sink.
+	changefeedOptResolved = "resolved"
+
+	// changefeedEventBufferSize is the number of range feed events buffered
+	// between the range feeds and the goroutine emitting them to the sink.
+	changefeedEventBufferSize = 1024
+)
+
+// changefeedCheckpointInterval is the minimum interval at which a changefeed
+// flushes its sink, emits a resolved timestamp message (if requested) and
+// saves its progress. Rows are only guaranteed to have been delivered to the
+// sink once a checkpoint covering them has been taken.
+var changefeedCheckpointInterval = time.Second
+
+// changefeedRowEnvelope is the JSON envelope in which each changed row is
+// emitted to the sink. Value is nil if the row was deleted.
+type changefeedRowEnvelope struct {
+	Table   string                 `json:"table"`
+	Key     []interface{}          `json:"key"`
+	Value   map[string]interface{} `json:"value"`
+	Updated string                 `json:"updated"`
+}
+
+// changefeedResolvedEnvelope is the JSON envelope of resolved timestamp
+// messages. No row changed at or before the resolved timestamp will be
+// emitted after the message.
+type changefeedResolvedEnvelope struct {
+	Resolved string `json:"resolved"`
+}
+
+func changefeedJobDescription(changefeed *parser.CreateChangefeed, sinkURI string) (string, error) {
+	c := parser.CreateChangefeed{
+		Targets: changefeed.Targets,
+		Options: changefeed.Options,
+	}
+	sinkURI, err := sanitizeChangefeedSinkURI(sinkURI)
+	if err != nil {
+		return "", err
+	}
+	c.SinkURI = parser.NewDString(sinkURI)
+	return c.String(), nil
+}
+
+// changefeedTargetTables resolves the tables watched by a changefeed as of the
+// given timestamp.
+func changefeedTargetTables(
+	ctx context.Context, p sql.PlanHookState, ts hlc.Timestamp, targets parser.TargetList,
+) ([]*sqlbase.TableDescriptor, error) {
+	if targets.Databases != nil {
+		return nil, errors.New("CHANGEFEED only supports TABLE targets")
+	}
+
+	var sqlDescs []sqlbase.Descriptor
+	{
+		txn := client.NewTxn(p.ExecCfg().DB)
+		opt := client.TxnExecOptions{AutoRetry: true, AutoCommit: true}
+		if err := txn.Exec(ctx, opt, func(ctx context.Context, txn *client.Txn, opt *client.TxnExecOptions) error {
+			var err error
+			txn.SetFixedTimestamp(ts)
+			sqlDescs, err = allSQLDescriptors(ctx, txn)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	sqlDescs, err := descriptorsMatchingTargets(p.EvalContext().Database, sqlDescs, targets)
+	if err != nil {
+		return nil, err
+	}
+	var tables []*sqlbase.TableDescriptor
+	for _, desc := range sqlDescs {
+		tableDesc := desc.GetTable()
+		if tableDesc == nil {
+			continue
+		}
+		if tableDesc.IsView() {
+			return nil, errors.Errorf("CHANGEFEED cannot target view %q", tableDesc.Name)
+		}
+		// Interleaved rows live in the span of their root table, so they
+		// can't be watched with a range feed on the primary index span.
+		if tableDesc.IsInterleaved() {
+			return nil, errors.Errorf("CHANGEFEED cannot target interleaved table %q", tableDesc.Name)
+		}
+		tables = append(tables, tableDesc)
+	}
+	return tables, nil
+}
+
+func createChangefeedPlanHook(
+	stmt parser.Statement, p sql.PlanHookState,
+) (func(context.Context, chan<- parser.Datums) error, sqlbase.ResultColumns, error) {
+	changefeedStmt, ok := stmt.(*parser.CreateChangefeed)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	if err := utilccl.CheckEnterpriseEnabled(
+		p.ExecCfg().Settings, p.ExecCfg().ClusterID(), p.ExecCfg().Organization.Get(), "CHANGEFEED",
+	); err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.RequireSuperUser("CHANGEFEED"); err != nil {
+		return nil, nil, err
+	}
+
+	sinkURIFn, err := p.TypeAsString(changefeedStmt.SinkURI, "CHANGEFEED")
+	if err != nil {
+		return nil, nil, err
+	}
+	optsFn, err := p.TypeAsStringOpts(changefeedStmt.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := sqlbase.ResultColumns{
+		{Name: "job_id", Typ: parser.TypeInt},
+	}
+	fn := func(ctx context.Context, resultsCh chan<- parser.Datums) error {
+		ctx, span := tracing.ChildSpan(ctx, stmt.StatementTag())
+		defer tracing.FinishSpan(span)
+
+		sinkURI, err := sinkURIFn()
+		if err != nil {
+			return err
+		}
+		opts, err := optsFn()
+		if err != nil {
+			return err
+		}
+		for k := range opts {
+			switch k {
+			case changefeedOptCursor, changefeedOptResolved:
+			default:
+				return errors.Errorf("unknown CHANGEFEED option %q", k)
+			}
+		}
+
+		highwater := p.ExecCfg().Clock.Now()
+		if cursor, ok := opts[changefeedOptCursor]; ok {
+			asOf := parser.AsOfClause{Expr: parser.NewDString(cursor)}
+			if highwater, err = sql.EvalAsOfTimestamp(nil, asOf, highwater); err != nil {
+				return err
+			}
+		}
+
+		tables, err := changefeedTargetTables(ctx, p, highwater, changefeedStmt.Targets)
+		if err != nil {
+			return err
+		}
+		var tableIDs []sqlbase.ID
+		var topics []string
+		for _, table := range tables {
+			tableIDs = append(tableIDs, table.ID)
+			topics = append(topics, table.Name)
+		}
+
+		// Fail fast on a misconfigured sink, rather than in the job.
+		sink, err := getChangefeedSink(ctx, sinkURI, topics)
+		if err != nil {
+			return err
+		}
+		if err := sink.Close(); err != nil {
+			return err
+		}
+
+		description, err := changefeedJobDescription(changefeedStmt, sinkURI)
+		if err != nil {
+			return err
+		}
+		job := p.ExecCfg().JobRegistry.NewJob(jobs.Record{
+			Description:   description,
+			Username:      p.User(),
+			DescriptorIDs: tableIDs,
+			Details: jobs.ChangefeedDetails{
+				TableIDs:  tableIDs,
+				SinkURI:   sinkURI,
+				Opts:      opts,
+				Highwater: highwater,
+			},
+		})
+
+		// A changefeed runs until it fails or is canceled, so unlike BACKUP and
+		// RESTORE the statement only creates the job and returns its ID.
+		stopper := p.ExecCfg().RPCContext.Stopper
+		feedCtx, cancel := context.WithCancel(
+			p.ExecCfg().AmbientCtx.AnnotateCtx(context.Background()))
+		if err := job.Created(ctx, cancel); err != nil {
+			cancel()
+			return err
+		}
+		if err := stopper.RunAsyncTask(feedCtx, "changefeed", func(ctx context.Context) {
+			defer cancel()
+			changefeedErr := runChangefeed(ctx, job)
+			if err := job.FinishedWith(ctx, changefeedErr); err != nil {
+				log.Warningf(ctx, "changefeed job %d: %s", *job.ID(), err)
+			}
+		}); err != nil {
+			cancel()
+			return job.FinishedWith(ctx, jobs.NewRetryJobError(err.Error()))
+		}
+
+		resultsCh <- parser.Datums{
+			parser.NewDInt(parser.DInt(*job.ID())),
+		}
+		return nil
+	}
+	return fn, header, nil
+}
+
+func changefeedResumeHook(typ jobs.Type) func(context.Context, *jobs.Job) error {
+	if typ != jobs.TypeChangefeed {
+		return nil
+	}
+
+	return func(ctx context.Context, job *jobs.Job) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if err := job.Created(ctx, cancel); err != nil {
+			return err
+		}
+		return runChangefeed(ctx, job)
+	}
+}
+
+// runChangefeed tails the primary indexes of the changefeed's tables from its
+// highwater timestamp, emitting changed rows to its sink, until the context
+// is canceled or an error occurs. The job must already have been Created.
+func runChangefeed(ctx context.Context, job *jobs.Job) error {
+	details := job.Record.Details.(jobs.ChangefeedDetails)
+	if err := job.Started(ctx); err != nil {
+		return err
+	}
+
+	var spans []roachpb.Span
+	var topics []string
+	{
+		txn := client.NewTxn(job.DB())
+		opt := client.TxnExecOptions{AutoRetry: true, AutoCommit: true}
+		if err := txn.Exec(ctx, opt, func(ctx context.Context, txn *client.Txn, opt *client.TxnExecOptions) error {
+			spans, topics = nil, nil
+			txn.SetFixedTimestamp(details.Highwater)
+			for _, tableID := range details.TableIDs {
+				desc := &sqlbase.Descriptor{}
+				if err := txn.GetProto(ctx, sqlbase.MakeDescMetadataKey(tableID), desc); err != nil {
+					return err
+				}
+				tableDesc := desc.GetTable()
+				if tableDesc == nil {
+					return errors.Errorf("descriptor %d is not a table", tableID)
+				}
+				spans = append(spans, tableDesc.PrimaryIndexSpan())
+				topics = append(topics, tableDesc.Name)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	sink, err := getChangefeedSink(ctx, details.SinkURI, topics)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := sink.Close(); err != nil {
+			log.Warningf(ctx, "error closing changefeed sink: %s", err)
+		}
+	}()
+
+	cf := &changefeed{
+		job:      job,
+		sink:     sink,
+		frontier: makeSpanFrontier(details.Highwater, spans...),
+		tables:   make(map[sqlbase.ID]*changefeedTable),
+	}
+	_, cf.emitResolved = details.Opts[changefeedOptResolved]
+
+	eventCh := make(chan *roachpb.RangeFeedEvent, changefeedEventBufferSize)
+	g, gCtx := errgroup.WithContext(ctx)
+	for _, span := range spans {
+		args := &roachpb.RangeFeedRequest{Span: span}
+		args.Timestamp = details.Highwater
+		g.Go(func() error {
+			pErr := job.DistSender().RangeFeed(gCtx, args, eventCh)
+			if _, ok := pErr.GetDetail().(*roachpb.NodeUnavailableError); ok {
+				return jobs.NewRetryJobError("changefeed interrupted by node shutdown")
+			}
+			return pErr.GoError()
+		})
+	}
+	g.Go(func() error {
+		return cf.run(gCtx, eventCh)
+	})
+	return g.Wait()
+}
+
+// changefeed emits the events of the range feeds watching its tables to its
+// sink.
+type changefeed struct {
+	job          *jobs.Job
+	sink         changefeedSink
+	frontier     *spanFrontier
+	emitResolved bool
+
+	alloc          sqlbase.DatumAlloc
+	tables         map[sqlbase.ID]*changefeedTable
+	lastCheckpoint time.Time
+	// lastRowKey and lastRowTS identify the last row emitted. The range feeds
+	// emit one event per column family, so consecutive events for the same
+	// row at the same timestamp are deduplicated.
+	lastRowKey roachpb.Key
+	lastRowTS  hlc.Timestamp
+}
+
+func (cf *changefeed) run(ctx context.Context, eventCh <-chan *roachpb.RangeFeedEvent) error {
+	for {
+		select {
+		case event := <-eventCh:
+			switch t := event.GetValue().(type) {
+			case *roachpb.RangeFeedValue:
+				if err := cf.emitRow(ctx, t.Key, t.Value); err != nil {
+					return err
+				}
+			case *roachpb.RangeFeedCheckpoint:
+				if cf.frontier.Forward(t.Span, t.ResolvedTS) {
+					if err := cf.maybeCheckpoint(ctx); err != nil {
+						return err
+					}
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// emitRow emits the row containing the given key, as of the timestamp of the
+// value written to it, to the sink.
+func (cf *changefeed) emitRow(ctx context.Context, key roachpb.Key, value roachpb.Value) error {
+	ts := value.Timestamp
+	rowKey, err := keys.EnsureSafeSplitKey(key)
+	if err != nil {
+		return err
+	}
+	if ts == cf.lastRowTS && bytes.Equal(rowKey, cf.lastRowKey) {
+		return nil
+	}
+
+	_, tableID, err := keys.DecodeTablePrefix(rowKey)
+	if err != nil {
+		return err
+	}
+	table, err := cf.tableAt(ctx, sqlbase.ID(tableID), ts)
+	if err != nil {
+		return err
+	}
+	tableDesc := table.desc
+	if tableDesc.Dropped() {
+		return errors.Errorf("table %q was dropped", tableDesc.Name)
+	}
+
+	var row parser.Datums
+	if len(tableDesc.Families) == 1 {
+		// The value holds the whole row; it is absent if the row was deleted.
+		if value.IsPresent() {
+			rf, err := table.rowFetcher(&cf.alloc)
+			if err != nil {
+				return err
+			}
+			if err := rf.StartScanFromKV(ctx, client.KeyValue{Key: key, Value: &value}); err != nil {
+				return err
+			}
+			if row, err = rf.NextRowDecoded(ctx, false /* traceKV */); err != nil {
+				return err
+			}
+		}
+	} else {
+		// The row is spread over one key per column family, so it has to be
+		// read back.
+		txn := client.NewTxn(cf.job.DB())
+		opt := client.TxnExecOptions{AutoRetry: true, AutoCommit: true}
+		if err := txn.Exec(ctx, opt, func(ctx context.Context, txn *client.Txn, opt *client.TxnExecOptions) error {
+			txn.SetFixedTimestamp(ts)
+			row, err = fetchChangefeedRow(ctx, txn, table, rowKey, &cf.alloc)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	env := changefeedRowEnvelope{
+		Table:   tableDesc.Name,
+		Updated: parser.TimestampToDecimal(ts).Decimal.String(),
+	}
+	colIDs, colDirs := tableDesc.PrimaryIndex.FullColumnIDs()
+	keyVals, err := sqlbase.MakeEncodedKeyVals(tableDesc, colIDs)
+	if err != nil {
+		return err
+	}
+	if _, ok, err := sqlbase.DecodeIndexKey(
+		&cf.alloc, tableDesc, tableDesc.PrimaryIndex.ID, keyVals, colDirs, rowKey,
+	); err != nil {
+		return err
+	} else if !ok {
+		return errors.Errorf("key %s is not in the primary index of table %q", key, tableDesc.Name)
+	}
+	for i := range keyVals {
+		if err := keyVals[i].EnsureDecoded(&cf.alloc); err != nil {
+			return err
+		}
+		env.Key = append(env.Key, changefeedJSONValue(keyVals[i].Datum))
+	}
+	if row != nil {
+		env.Value = make(map[string]interface{}, len(row))
+		for i, col := range tableDesc.Columns {
+			env.Value[col.Name] = changefeedJSONValue(row[i])
+		}
+	}
+
+	keyJSON, err := json.Marshal(env.Key)
+	if err != nil {
+		return err
+	}
+	valueJSON, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	if err := cf.sink.EmitRow(ctx, tableDesc.Name, keyJSON, valueJSON); err != nil {
+		return err
+	}
+	cf.lastRowKey, cf.lastRowTS = rowKey, ts
+	return nil
+}
+
+// maybeCheckpoint flushes the sink and saves the changefeed's progress if the
+// last checkpoint is more than changefeedCheckpointInterval old.
+func (cf *changefeed) maybeCheckpoint(ctx context.Context) error {
+	if timeutil.Since(cf.lastCheckpoint) < changefeedCheckpointInterval {
+		return nil
+	}
+	resolved := cf.frontier.Frontier()
+	if err := cf.sink.Flush(ctx); err != nil {
+		return err
+	}
+	if cf.emitResolved {
+		payload, err := json.Marshal(changefeedResolvedEnvelope{
+			Resolved: parser.TimestampToDecimal(resolved).Decimal.String(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := cf.sink.EmitResolvedTimestamp(ctx, payload); err != nil {
+			return err
+		}
+		if err := cf.sink.Flush(ctx); err != nil {
+			return err
+		}
+	}
+	// A changefeed never completes, so its fraction completed stays at zero.
+	if err := cf.job.Progressed(ctx, 0, func(ctx context.Context, details interface{}) {
+		switch d := details.(type) {
+		case *jobs.Payload_Changefeed:
+			d.Changefeed.Highwater = resolved
+		default:
+			log.Errorf(ctx, "job payload had unexpected type %T", d)
+		}
+	}); err != nil {
+		return err
+	}
+	cf.lastCheckpoint = timeutil.Now()
+	return nil
+}
+
+// changefeedTable is the descriptor of one of a changefeed's tables, along
+// with the interval of timestamps over which it is known to be current.
+type changefeedTable struct {
+	desc                  *sqlbase.TableDescriptor
+	raw                   []byte // the encoded descriptor
+	validFrom, validUntil hlc.Timestamp
+
+	fetcher     sqlbase.RowFetcher
+	fetcherInit bool
+}
+
+func (t *changefeedTable) covers(ts hlc.Timestamp) bool {
+	return !ts.Less(t.validFrom) && !t.validUntil.Less(ts)
+}
+
+// rowFetcher returns a fetcher of the table's rows from its primary index.
+func (t *changefeedTable) rowFetcher(alloc *sqlbase.DatumAlloc) (*sqlbase.RowFetcher, error) {
+	if t.fetcherInit {
+		return &t.fetcher, nil
+	}
+	tableDesc := t.desc
+	colIdxMap := make(map[sqlbase.ColumnID]int, len(tableDesc.Columns))
+	valNeededForCol := make([]bool, len(tableDesc.Columns))
+	for i, col := range tableDesc.Columns {
+		colIdxMap[col.ID] = i
+		valNeededForCol[i] = true
+	}
+	if err := t.fetcher.Init(
+		tableDesc, colIdxMap, &tableDesc.PrimaryIndex, false /* reverse */, false, /* isSecondaryIndex */
+		tableDesc.Columns, valNeededForCol, false /* returnRangeInfo */, alloc,
+	); err != nil {
+		return nil, err
+	}
+	t.fetcherInit = true
+	return &t.fetcher, nil
+}
+
+// tableAt returns the descriptor of the table as of the given timestamp.
+//
+// Descriptors are cached along with the interval of timestamps over which
+// they are known to be current, so they are usually only read again once the
+// changefeed's events move past that interval, and then only to extend it:
+// the latest descriptor is read, and if it is the same as the cached one, the
+// cached one is current up to the time of that read, as descriptors which
+// were changed never change back. Otherwise the descriptor is read at the
+// given timestamp and the cache starts over from there.
+func (cf *changefeed) tableAt(
+	ctx context.Context, tableID sqlbase.ID, ts hlc.Timestamp,
+) (*changefeedTable, error) {
+	cached := cf.tables[tableID]
+	if cached != nil && cached.covers(ts) {
+		return cached, nil
+	}
+
+	latest, err := readChangefeedTable(ctx, cf.job.DB(), tableID, hlc.Timestamp{})
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && bytes.Equal(cached.raw, latest.raw) && !latest.validUntil.Less(cached.validUntil) {
+		cached.validUntil = latest.validUntil
+		if cached.covers(ts) {
+			return cached, nil
+		}
+	}
+
+	table, err := readChangefeedTable(ctx, cf.job.DB(), tableID, ts)
+	if err != nil {
+		return nil, err
+	}
+	if !latest.validUntil.Less(ts) && bytes.Equal(table.raw, latest.raw) {
+		table.validUntil = latest.validUntil
+	}
+	cf.tables[tableID] = table
+	return table, nil
+}
+
+// readChangefeedTable reads the descriptor of the table as of the given
+// timestamp, or the latest one if it is zero. The returned table is only
+// known to be current at the time it was read.
+func readChangefeedTable(
+	ctx context.Context, db *client.DB, tableID sqlbase.ID, ts hlc.Timestamp,
+) (*changefeedTable, error) {
+	table := &changefeedTable{}
+	txn := client.NewTxn(db)
+	opt := client.TxnExecOptions{AutoRetry: true, AutoCommit: true}
+	if err := txn.Exec(ctx, opt, func(ctx context.Context, txn *client.Txn, opt *client.TxnExecOptions) error {
+		if ts != (hlc.Timestamp{}) {
+			txn.SetFixedTimestamp(ts)
+		}
+		kv, err := txn.Get(ctx, sqlbase.MakeDescMetadataKey(tableID))
+		if err != nil {
+			return err
+		}
+		if kv.Value == nil {
+			return errors.Errorf("descriptor %d does not exist", tableID)
+		}
+		raw, err := kv.Value.GetBytes()
+		if err != nil {
+			return err
+		}
+		desc := &sqlbase.Descriptor{}
+		if err := desc.Unmarshal(raw); err != nil {
+			return err
+		}
+		if table.desc = desc.GetTable(); table.desc == nil {
+			return errors.Errorf("descriptor %d is not a table", tableID)
+		}
+		table.raw = raw
+		table.validFrom = txn.OrigTimestamp()
+		table.validUntil = table.validFrom
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// fetchChangefeedRow reads the row with the given key prefix from the primary
+// index of the table, returning nil if it does not exist.
+func fetchChangefeedRow(
+	ctx context.Context,
+	txn *client.Txn,
+	table *changefeedTable,
+	rowKey roachpb.Key,
+	alloc *sqlbase.DatumAlloc,
+) (parser.Datums, error) {
+	rf, err := table.rowFetcher(alloc)
+	if err != nil {
+		return nil, err
+	}
+	span := roachpb.Span{Key: rowKey, EndKey: rowKey.PrefixEnd()}
+	if err := rf.StartScan(ctx, txn, roachpb.Spans{span}, false /* limitBatches */, 0 /* limitHint */); err != nil {
+		return nil, err
+	}
+	return rf.NextRowDecoded(ctx, false /* traceKV */)
+}
+
+// changefeedJSONValue converts a datum to a value that encoding/json
+// marshals naturally.
+func changefeedJSONValue(d parser.Datum) interface{} {
+	if d == parser.DNull {
+		return nil
+	}
+	switch t := d.(type) {
+	case *parser.DBool:
+		return bool(*t)
+	case *parser.DInt:
+		return int64(*t)
+	case *parser.DFloat:
+		if f := float64(*t); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case *parser.DString:
+		return string(*t)
+	}
+	return parser.AsStringWithFlags(d, parser.FmtBareStrings)
+}
+
+// spanFrontier tracks the minimum timestamp resolved over a set of spans.
+type spanFrontier struct {
+	// entries are sorted, non-overlapping and cover the tracked spans.
+	entries []spanFrontierEntry
+}
+
+type spanFrontierEntry struct {
+	span roachpb.Span
+	ts   hlc.Timestamp
+}
+
+func makeSpanFrontier(ts hlc.Timestamp, spans ...roachpb.Span) *spanFrontier {
+	f := &spanFrontier{}
+	for _, span := range spans {
+		f.entries = append(f.entries, spanFrontierEntry{span: span, ts: ts})
+	}
+	sort.Slice(f.entries, func(i, j int) bool {
+		return f.entries[i].span.Key.Compare(f.entries[j].span.Key) < 0
+	})
+	return f
+}
+
+// Frontier returns the minimum timestamp resolved over all of the spans.
+func (f *spanFrontier) Frontier() hlc.Timestamp {
+	var min hlc.Timestamp
+	for i, e := range f.entries {
+		if i == 0 || e.ts.Less(min) {
+			min = e.ts
+		}
+	}
+	return min
+}
+
+// Forward advances the timestamp resolved over the tracked portions of span
+// to ts, returning whether the frontier advanced as a result.
+func (f *spanFrontier) Forward(span roachpb.Span, ts hlc.Timestamp) bool {
+	prev := f.Frontier()
+	var entries []spanFrontierEntry
+	add := func(e spanFrontierEntry) {
+		if n := len(entries); n > 0 && entries[n-1].ts == e.ts &&
+			entries[n-1].span.EndKey.Equal(e.span.Key) {
+			entries[n-1].span.EndKey = e.span.EndKey
+			return
+		}
+		entries = append(entries, e)
+	}
+	for _, e := range f.entries {
+		if !e.span.Overlaps(span) {
+			add(e)
+			continue
+		}
+		if e.span.Key.Compare(span.Key) < 0 {
+			add(spanFrontierEntry{span: roachpb.Span{Key: e.span.Key, EndKey: span.Key}, ts: e.ts})
+		}
+		overlap := e.span
+		if overlap.Key.Compare(span.Key) < 0 {
+			overlap.Key = span.Key
+		}
+		if span.EndKey.Compare(overlap.EndKey) < 0 {
+			overlap.EndKey = span.EndKey
+		}
+		overlapTS := e.ts
+		overlapTS.Forward(ts)
+		add(spanFrontierEntry{span: overlap, ts: overlapTS})
+		if span.EndKey.Compare(e.span.EndKey) < 0 {
+			add(spanFrontierEntry{span: roachpb.Span{Key: span.EndKey, EndKey: e.span.EndKey}, ts: e.ts})
+		}
+	}
+	f.entries = entries
+	return prev.Less(f.Frontier())
+}
+
+func init() {
+	sql.AddPlanHook(createChangefeedPlanHook)
+	jobs.AddResumeHook(changefeedResumeHook)
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+const (
+	// changefeedSinkSchemeKafka is the URI scheme of kafka sinks. All other
+	// schemes are interpreted as ExportStorage URIs.
+	changefeedSinkSchemeKafka = "kafka"
+	// changefeedSinkParamTopicPrefix is prepended to the table name to form the
+	// topic written by kafka sinks.
+	changefeedSinkParamTopicPrefix = "topic_prefix"
+)
+
+// changefeedSink is the destination of a changefeed's messages.
+type changefeedSink interface {
+	// EmitRow enqueues a row message for delivery on the given topic.
+	EmitRow(ctx context.Context, topic string, key, value []byte) error
+	// EmitResolvedTimestamp enqueues a resolved timestamp message for delivery
+	// on every topic.
+	EmitResolvedTimestamp(ctx context.Context, payload []byte) error
+	// Flush blocks until every message enqueued has been delivered.
+	Flush(ctx context.Context) error
+	// Close releases the resources held by the sink, discarding any messages
+	// that have not been flushed.
+	Close() error
+}
+
+// getChangefeedSink returns the changefeedSink for the given URI, which
+// writes rows to the given topics.
+func getChangefeedSink(ctx context.Context, sinkURI string, topics []string) (changefeedSink, error) {
+	uri, err := url.Parse(sinkURI)
+	if err != nil {
+		return nil, err
+	}
+	if uri.Scheme == changefeedSinkSchemeKafka {
+		return makeKafkaSink(uri.Host, uri.Query().Get(changefeedSinkParamTopicPrefix), topics)
+	}
+	exportStore, err := exportStorageFromURI(ctx, sinkURI)
+	if err != nil {
+		return nil, err
+	}
+	return makeFileSink(exportStore), nil
+}
+
+// sanitizeChangefeedSinkURI returns the sink URI with sensitive credentials
+// stripped.
+func sanitizeChangefeedSinkURI(sinkURI string) (string, error) {
+	uri, err := url.Parse(sinkURI)
+	if err != nil {
+		return "", err
+	}
+	if uri.Scheme == changefeedSinkSchemeKafka {
+		// Kafka sinks have no credentials and their parameters are needed to
+		// identify the topics.
+		return sinkURI, nil
+	}
+	return storageccl.SanitizeExportStorageURI(sinkURI)
+}
+
+// fileSink is a changefeedSink that writes newline-delimited messages to
+// files in an ExportStorage. Each flush writes a new file, named such that
+// files sort in the order they were written.
+type fileSink struct {
+	exportStore storageccl.ExportStorage
+	// prefix distinguishes the files written by this sink from those written
+	// by earlier incarnations of the changefeed.
+	prefix int64
+	seq    int
+	buf    bytes.Buffer
+}
+
+func makeFileSink(exportStore storageccl.ExportStorage) *fileSink {
+	return &fileSink{exportStore: exportStore, prefix: timeutil.Now().UnixNano()}
+}
+
+// EmitRow implements the changefeedSink interface. The topic is not written;
+// the row's table is included in its value.
+func (s *fileSink) EmitRow(_ context.Context, _ string, _, value []byte) error {
+	s.buf.Write(value)
+	s.buf.WriteByte('\n')
+	return nil
+}
+
+// EmitResolvedTimestamp implements the changefeedSink interface.
+func (s *fileSink) EmitResolvedTimestamp(_ context.Context, payload []byte) error {
+	s.buf.Write(payload)
+	s.buf.WriteByte('\n')
+	return nil
+}
+
+// Flush implements the changefeedSink interface.
+func (s *fileSink) Flush(ctx context.Context) error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	name := fmt.Sprintf("%d-%08d.ndjson", s.prefix, s.seq)
+	if err := s.exportStore.WriteFile(ctx, name, bytes.NewReader(s.buf.Bytes())); err != nil {
+		return err
+	}
+	s.seq++
+	s.buf.Reset()
+	return nil
+}
+
+// Close implements the changefeedSink interface.
+func (s *fileSink) Close() error {
+	return s.exportStore.Close()
+}
+
+// kafkaSink is a changefeedSink that produces messages to a Kafka cluster,
+// one topic per table. Rows are partitioned by a hash of their primary key,
+// so all changes to a row are delivered in order on the same partition.
+// Resolved timestamp messages are delivered to every partition of every
+// topic.
+type kafkaSink struct {
+	client      sarama.Client
+	producer    sarama.SyncProducer
+	topicPrefix string
+	topics      []string
+	partitioner sarama.Partitioner
+	msgs        []*sarama.ProducerMessage
+}
+
+func makeKafkaSink(bootstrapServers string, topicPrefix string, topics []string) (*kafkaSink, error) {
+	if bootstrapServers == "" {
+		return nil, errors.New("kafka sink requires a bootstrap server address")
+	}
+	config := sarama.NewConfig()
+	config.ClientID = "CockroachDB"
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	// Partitions are picked by the sink; see kafkaSink.partition.
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+
+	client, err := sarama.NewClient([]string{bootstrapServers}, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting to kafka: %s", bootstrapServers)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	s := &kafkaSink{
+		client:      client,
+		producer:    producer,
+		topicPrefix: topicPrefix,
+		partitioner: sarama.NewHashPartitioner(""),
+	}
+	for _, topic := range topics {
+		s.topics = append(s.topics, topicPrefix+topic)
+	}
+	return s, nil
+}
+
+// EmitRow implements the changefeedSink interface.
+func (s *kafkaSink) EmitRow(_ context.Context, topic string, key, value []byte) error {
+	msg := &sarama.ProducerMessage{
+		Topic: s.topicPrefix + topic,
+		Key:   sarama.ByteEncoder(key),
+		Value: sarama.ByteEncoder(value),
+	}
+	partitions, err := s.client.Partitions(msg.Topic)
+	if err != nil {
+		return err
+	}
+	if msg.Partition, err = s.partitioner.Partition(msg, int32(len(partitions))); err != nil {
+		return err
+	}
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+// EmitResolvedTimestamp implements the changefeedSink interface.
+func (s *kafkaSink) EmitResolvedTimestamp(_ context.Context, payload []byte) error {
+	for _, topic := range s.topics {
+		partitions, err := s.client.Partitions(topic)
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			s.msgs = append(s.msgs, &sarama.ProducerMessage{
+				Topic:     topic,
+				Partition: partition,
+				Value:     sarama.ByteEncoder(payload),
+			})
+		}
+	}
+	return nil
+}
+
+// Flush implements the changefeedSink interface.
+func (s *kafkaSink) Flush(_ context.Context) error {
+	if len(s.msgs) == 0 {
+		return nil
+	}
+	if err := s.producer.SendMessages(s.msgs); err != nil {
+		return err
+	}
+	s.msgs = nil
+	return nil
+}
+
+// Close implements the changefeedSink interface.
+func (s *kafkaSink) Close() error {
+	if err := s.producer.Close(); err != nil {
+		_ = s.client.Close()
+		return err
+	}
+	return s.client.Close()
+}
@@ -0,0 +1,238 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestSpanFrontier(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	span := func(start, end string) roachpb.Span {
+		return roachpb.Span{Key: roachpb.Key(start), EndKey: roachpb.Key(end)}
+	}
+	ts := func(wallTime int64) hlc.Timestamp {
+		return hlc.Timestamp{WallTime: wallTime}
+	}
+
+	f := makeSpanFrontier(ts(1), span("c", "e"), span("a", "b"))
+	if actual := f.Frontier(); actual != ts(1) {
+		t.Fatalf("expected frontier %s, got %s", ts(1), actual)
+	}
+	testCases := []struct {
+		span     roachpb.Span
+		ts       hlc.Timestamp
+		advanced bool
+		frontier hlc.Timestamp
+	}{
+		// Only part of the tracked spans.
+		{span("a", "b"), ts(3), false, ts(1)},
+		{span("c", "d"), ts(2), false, ts(1)},
+		// Covers the rest, but [c,d) lags behind.
+		{span("d", "e"), ts(4), true, ts(2)},
+		// Regressions are ignored.
+		{span("a", "e"), ts(1), false, ts(2)},
+		// Untracked keys are ignored.
+		{span("b", "d"), ts(5), true, ts(3)},
+		{span("0", "z"), ts(6), true, ts(6)},
+	}
+	for i, tc := range testCases {
+		if advanced := f.Forward(tc.span, tc.ts); advanced != tc.advanced {
+			t.Errorf("%d: expected advanced=%t, got %t", i, tc.advanced, advanced)
+		}
+		if actual := f.Frontier(); actual != tc.frontier {
+			t.Errorf("%d: expected frontier %s, got %s", i, tc.frontier, actual)
+		}
+	}
+	if len(f.entries) != 2 {
+		t.Errorf("expected entries to be merged back into the 2 tracked spans, got %v", f.entries)
+	}
+}
+
+// readChangefeedFiles returns the messages written to dir by a changefeed's
+// file sink, in the order they were written.
+func readChangefeedFiles(dir string) ([]map[string]interface{}, error) {
+	// filepath.Glob returns the files sorted by name.
+	files, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+	var msgs []map[string]interface{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var msg map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				f.Close()
+				return nil, err
+			}
+			msgs = append(msgs, msg)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
+
+func TestChangefeedFileSink(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	defer func(oldInterval time.Duration) {
+		changefeedCheckpointInterval = oldInterval
+	}(changefeedCheckpointInterval)
+	changefeedCheckpointInterval = 10 * time.Millisecond
+
+	s, db, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(context.Background())
+	sqlDB := sqlutils.MakeSQLRunner(t, db)
+
+	dir, dirCleanup := testutils.TempDir(t)
+	defer dirCleanup()
+
+	sqlDB.Exec(`SET CLUSTER SETTING kv.rangefeed.checkpoint_interval = '10ms'`)
+	sqlDB.Exec(`SET CLUSTER SETTING kv.rangefeed.closed_timestamp_lag = '10ms'`)
+	sqlDB.Exec(`CREATE DATABASE d`)
+	sqlDB.Exec(`CREATE TABLE d.foo (a INT PRIMARY KEY, b STRING)`)
+	// Written before the changefeed is created, so not emitted.
+	sqlDB.Exec(`INSERT INTO d.foo VALUES (1, 'a')`)
+
+	var jobID int64
+	sqlDB.QueryRow(
+		`CREATE CHANGEFEED FOR d.foo INTO $1 WITH resolved`, `nodelocal://`+dir,
+	).Scan(&jobID)
+
+	sqlDB.Exec(`INSERT INTO d.foo VALUES (2, 'b')`)
+	sqlDB.Exec(`UPDATE d.foo SET b = 'c' WHERE a = 1`)
+	sqlDB.Exec(`DELETE FROM d.foo WHERE a = 2`)
+
+	// NB: encoding/json sorts the keys of the decoded messages.
+	expected := []string{
+		`{"key":[2],"table":"foo","value":{"a":2,"b":"b"}}`,
+		`{"key":[1],"table":"foo","value":{"a":1,"b":"c"}}`,
+		`{"key":[2],"table":"foo","value":null}`,
+	}
+	testutils.SucceedsSoon(t, func() error {
+		msgs, err := readChangefeedFiles(dir)
+		if err != nil {
+			return err
+		}
+		var rows []string
+		resolvedAfterRows := false
+		for _, msg := range msgs {
+			if _, ok := msg["resolved"]; ok {
+				resolvedAfterRows = len(rows) == len(expected)
+				continue
+			}
+			if _, ok := msg["updated"].(string); !ok {
+				return errors.Errorf("expected an updated timestamp in %v", msg)
+			}
+			delete(msg, "updated")
+			row, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, string(row))
+		}
+		if len(rows) > len(expected) || !reflect.DeepEqual(rows, expected[:len(rows)]) {
+			t.Fatalf("expected rows %v, got %v", expected, rows)
+		}
+		if !resolvedAfterRows {
+			return errors.Errorf("waiting for rows %v to be resolved, got %v", expected, rows)
+		}
+		return nil
+	})
+
+	sqlDB.Exec(fmt.Sprintf(`CANCEL JOB %d`, jobID))
+	testutils.SucceedsSoon(t, func() error {
+		var status string
+		sqlDB.QueryRow(`SELECT status FROM crdb_internal.jobs WHERE id = $1`, jobID).Scan(&status)
+		if status != "canceled" {
+			return errors.Errorf("expected job to be canceled, got %s", status)
+		}
+		return nil
+	})
+}
+
+func TestChangefeedKafkaSink(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	defer func(oldInterval time.Duration) {
+		changefeedCheckpointInterval = oldInterval
+	}(changefeedCheckpointInterval)
+	changefeedCheckpointInterval = 10 * time.Millisecond
+
+	// A stand-in for a Kafka cluster, which accepts every message produced to
+	// the changefeed's topic.
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("cdc_foo", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	s, db, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(context.Background())
+	sqlDB := sqlutils.MakeSQLRunner(t, db)
+
+	sqlDB.Exec(`SET CLUSTER SETTING kv.rangefeed.checkpoint_interval = '10ms'`)
+	sqlDB.Exec(`SET CLUSTER SETTING kv.rangefeed.closed_timestamp_lag = '10ms'`)
+	sqlDB.Exec(`CREATE DATABASE d`)
+	sqlDB.Exec(`CREATE TABLE d.foo (a INT PRIMARY KEY)`)
+
+	var jobID int64
+	sqlDB.QueryRow(
+		`CREATE CHANGEFEED FOR d.foo INTO $1`,
+		fmt.Sprintf(`kafka://%s?topic_prefix=cdc_`, broker.Addr()),
+	).Scan(&jobID)
+	sqlDB.Exec(`INSERT INTO d.foo VALUES (1)`)
+
+	testutils.SucceedsSoon(t, func() error {
+		var produced int
+		for _, rr := range broker.History() {
+			if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+				produced++
+			}
+		}
+		// Without the resolved option, only rows are produced.
+		if produced == 0 {
+			return errors.New("expected the row to be produced")
+		}
+		return nil
+	})
+
+	sqlDB.Exec(fmt.Sprintf(`CANCEL JOB %d`, jobID))
+}
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"os"
 	"runtime"
@@ -53,6 +54,12 @@ const (
 	importOptionTemp        = "temp"
 )
 
+const (
+	importFormatCSV       = "CSV"
+	importFormatPGDump    = "PGDUMP"
+	importFormatMySQLDump = "MYSQLDUMP"
+)
+
 // LoadCSV converts CSV files into enterprise backup format.
 func LoadCSV(
 	ctx context.Context,
@@ -92,6 +99,7 @@ func LoadCSV(
 
 	return doLocalCSVTransform(
 		ctx, parentID, tableDesc, dest, dataFiles, comma, comment, nullif, sstMaxSize,
+		nil /* encryption */, timeutil.Now().UnixNano(),
 	)
 }
 
@@ -104,15 +112,48 @@ func doLocalCSVTransform(
 	comma, comment rune,
 	nullif *string,
 	sstMaxSize int64,
+	encryption *roachpb.FileEncryptionOptions,
+	walltime int64,
 ) (csvCount, kvCount, sstCount int64, err error) {
 
+	reader := newCSVChunkReader(
+		dataFiles, make([]int64, len(dataFiles)), comma, comment, len(tableDesc.VisibleColumns()),
+	)
+	defer reader.close(ctx)
+	csvCount, kvCount, err = convertCSV(
+		ctx, reader, 0 /* maxRows */, tableDesc, nullif, sstMaxSize, walltime,
+		func(ctx context.Context, contentCh <-chan sstContent) error {
+			var err error
+			sstCount, err = makeBackup(ctx, parentID, tableDesc, dest, contentCh, encryption)
+			return err
+		},
+	)
+	return csvCount, kvCount, sstCount, err
+}
+
+// convertCSV converts up to maxRows records read from reader, or all of them
+// if maxRows is zero, into the KVs of tableDesc. The KVs are written, with
+// all keys at walltime, into sorted SSTs of up to sstMaxSize that are sent to
+// write, which runs concurrently with the conversion. It returns the number
+// of records read and of KVs written.
+func convertCSV(
+	ctx context.Context,
+	reader *csvChunkReader,
+	maxRows int64,
+	tableDesc *sqlbase.TableDescriptor,
+	nullif *string,
+	sstMaxSize int64,
+	walltime int64,
+	write func(context.Context, <-chan sstContent) error,
+) (csvCount, kvCount int64, err error) {
+
 	// Some channels are buffered because reads happen in bursts, so having lots
 	// of pre-computed data improves overall performance.
 	const chanSize = 10000
 
 	rocksdbDest, err := ioutil.TempDir("", "cockroach-csv-rocksdb")
 	if err != nil {
-		return 0, 0, 0, err
+		return 0, 0, err
 	}
 	defer func() {
 		if err := os.RemoveAll(rocksdbDest); err != nil {
@@ -127,7 +168,7 @@ func doLocalCSVTransform(
 	group.Go(func() error {
 		defer close(recordCh)
 		var err error
-		csvCount, err = readCSV(gCtx, comma, comment, len(tableDesc.VisibleColumns()), dataFiles, recordCh)
+		csvCount, err = reader.readChunk(gCtx, maxRows, recordCh)
 		return err
 	})
 	group.Go(func() error {
@@ -139,15 +180,13 @@ func doLocalCSVTransform(
 	group.Go(func() error {
 		defer close(contentCh)
 		var err error
-		kvCount, err = writeRocksDB(gCtx, kvCh, rocksdbDest, sstMaxSize, contentCh)
+		kvCount, err = writeRocksDB(gCtx, kvCh, rocksdbDest, sstMaxSize, walltime, contentCh)
 		return err
 	})
 	group.Go(func() error {
-		var err error
-		sstCount, err = makeBackup(gCtx, parentID, tableDesc, dest, contentCh)
-		return err
+		return write(gCtx, contentCh)
 	})
-	return csvCount, kvCount, sstCount, group.Wait()
+	return csvCount, kvCount, group.Wait()
 }
 
 const (
@@ -247,62 +286,103 @@ func readCSV(
 	dataFiles []string,
 	recordCh chan<- csvRecord,
 ) (int64, error) {
-	expectedColsExtra := expectedCols + 1
-	done := ctx.Done()
-	var count int64
+	reader := newCSVChunkReader(dataFiles, make([]int64, len(dataFiles)), comma, comment, expectedCols)
+	defer reader.close(ctx)
+	return reader.readChunk(ctx, 0 /* maxRows */, recordCh)
+}
+
+// csvChunkReader reads the records of a list of CSV files, in chunks of a
+// limited number of rows. It keeps track of the number of rows read from each
+// file, so that a later reader can resume from where it left off.
+type csvChunkReader struct {
+	files          []string
+	comma, comment rune
+	expectedCols   int
+	// pos is the number of rows read from each file, or math.MaxInt64 once
+	// all of its rows have been.
+	pos []int64
+
+	// The file currently being read, if any.
+	cur struct {
+		idx int
+		es  storageccl.ExportStorage
+		f   io.ReadCloser
+		cr  *csv.Reader
+	}
+}
+
+// newCSVChunkReader returns a reader of files that skips the first pos[i] rows
+// of the i-th file. pos is updated as the files are read.
+func newCSVChunkReader(
+	files []string, pos []int64, comma, comment rune, expectedCols int,
+) *csvChunkReader {
 	if comma == 0 {
 		comma = ','
 	}
-	for _, dataFile := range dataFiles {
+	return &csvChunkReader{
+		files:        files,
+		comma:        comma,
+		comment:      comment,
+		expectedCols: expectedCols,
+		pos:          pos,
+	}
+}
+
+// done returns whether all of the rows of all of the files have been read.
+func (r *csvChunkReader) done() bool {
+	for _, pos := range r.pos {
+		if pos != math.MaxInt64 {
+			return false
+		}
+	}
+	return true
+}
+
+// readChunk sends up to maxRows records, or all of the remaining ones if
+// maxRows is zero, on recordCh. It returns the number of rows read.
+func (r *csvChunkReader) readChunk(
+	ctx context.Context, maxRows int64, recordCh chan<- csvRecord,
+) (int64, error) {
+	done := ctx.Done()
+	var count int64
+	for i, dataFile := range r.files {
+		if r.pos[i] == math.MaxInt64 {
+			continue
+		}
 		select {
 		case <-done:
 			return 0, ctx.Err()
 		default:
 		}
 		err := func() error {
-			conf, err := storageccl.ExportStorageConfFromURI(dataFile)
-			if err != nil {
-				return err
-			}
-			es, err := storageccl.MakeExportStorage(ctx, conf)
-			if err != nil {
-				return err
-			}
-			defer es.Close()
-			f, err := es.ReadFile(ctx, "")
-			if err != nil {
-				return err
+			if r.cur.cr == nil || r.cur.idx != i {
+				if err := r.open(ctx, i); err != nil {
+					return err
+				}
 			}
-			cr := csv.NewReader(f)
-			cr.Comma = comma
-			cr.FieldsPerRecord = -1
-			cr.LazyQuotes = true
-			cr.Comment = comment
-			for i := 1; ; i++ {
-				record, err := cr.Read()
+			for maxRows == 0 || count < maxRows {
+				record, err := r.cur.cr.Read()
 				if err == io.EOF {
-					break
+					r.pos[i] = math.MaxInt64
+					return r.closeFile()
 				}
+				row := r.pos[i] + 1
 				if err != nil {
-					return errors.Wrapf(err, "row %d: reading CSV record", i)
+					return errors.Wrapf(err, "row %d: reading CSV record", row)
 				}
-				if len(record) == expectedCols {
-					// Expected number of columns.
-				} else if len(record) == expectedColsExtra && record[expectedCols] == "" {
-					// Line has the optional trailing comma, ignore the empty field.
-					record = record[:expectedCols]
-				} else {
-					return errors.Errorf("row %d: expected %d fields, got %d", i, expectedCols, len(record))
+				if record, err = r.checkRecord(record, row); err != nil {
+					return err
 				}
 				cr := csvRecord{
 					r:    record,
 					file: dataFile,
-					row:  i,
+					row:  int(row),
 				}
 				select {
 				case <-done:
 					return ctx.Err()
 				case recordCh <- cr:
+					r.pos[i]++
 					count++
 				}
 			}
@@ -311,10 +391,80 @@ func readCSV(
 		if err != nil {
 			return 0, errors.Wrapf(err, dataFile)
 		}
+		if maxRows != 0 && count >= maxRows {
+			break
+		}
 	}
 	return count, nil
 }
 
+// open starts reading the i-th file, skipping the rows already read from it.
+func (r *csvChunkReader) open(ctx context.Context, i int) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	conf, err := storageccl.ExportStorageConfFromURI(r.files[i])
+	if err != nil {
+		return err
+	}
+	es, err := storageccl.MakeExportStorage(ctx, conf)
+	if err != nil {
+		return err
+	}
+	f, err := es.ReadFile(ctx, "")
+	if err != nil {
+		es.Close()
+		return err
+	}
+	r.cur.idx, r.cur.es, r.cur.f = i, es, f
+	r.cur.cr = csv.NewReader(f)
+	r.cur.cr.Comma = r.comma
+	r.cur.cr.FieldsPerRecord = -1
+	r.cur.cr.LazyQuotes = true
+	r.cur.cr.Comment = r.comment
+	for row := int64(1); row <= r.pos[i]; row++ {
+		if _, err := r.cur.cr.Read(); err != nil {
+			return errors.Wrapf(err, "row %d: skipping already imported CSV record", row)
+		}
+	}
+	return nil
+}
+
+// checkRecord returns the fields of record, the row-th of its file, without
+// its optional trailing empty field, or an error if it doesn't have the
+// expected number of fields.
+func (r *csvChunkReader) checkRecord(record []string, row int64) ([]string, error) {
+	if len(record) == r.expectedCols {
+		// Expected number of columns.
+	} else if len(record) == r.expectedCols+1 && record[r.expectedCols] == "" {
+		// Line has the optional trailing comma, ignore the empty field.
+		record = record[:r.expectedCols]
+	} else {
+		return nil, errors.Errorf("row %d: expected %d fields, got %d", row, r.expectedCols, len(record))
+	}
+	return record, nil
+}
+
+// closeFile stops reading the current file, if any.
+func (r *csvChunkReader) closeFile() error {
+	if r.cur.cr == nil {
+		return nil
+	}
+	err := r.cur.f.Close()
+	if closeErr := r.cur.es.Close(); err == nil {
+		err = closeErr
+	}
+	r.cur.es, r.cur.f, r.cur.cr = nil, nil, nil
+	return err
+}
+
+// close releases the resources of the reader.
+func (r *csvChunkReader) close(ctx context.Context) {
+	if err := r.closeFile(); err != nil {
+		log.Warningf(ctx, "could not close CSV file: %s", err)
+	}
+}
+
 type csvRecord struct {
 	r    []string
 	file string
@@ -407,13 +557,15 @@ type sstContent struct {
 const errSSTCreationMaybeDuplicateTemplate = "SST creation error at %s; this can happen when a primary or unique index has duplicate keys"
 
 // writeRocksDB writes kvs to a RocksDB instance that is created at
-// rocksdbDir. After kvs is closed, sst files are created of size maxSize
-// and sent on contents. It returns the number of KV pairs created.
+// rocksdbDir. After kvs is closed, sst files are created of size maxSize,
+// with all keys at walltime, and sent on contents. It returns the number of
+// KV pairs created.
 func writeRocksDB(
 	ctx context.Context,
 	kvCh <-chan roachpb.KeyValue,
 	rocksdbDir string,
 	sstMaxSize int64,
+	walltime int64,
 	contentCh chan<- sstContent,
 ) (int64, error) {
 	const batchMaxSize = 1024 * 50
@@ -489,7 +641,6 @@ func writeRocksDB(
 
 	var kv engine.MVCCKeyValue
 	var firstKey, lastKey roachpb.Key
-	ts := timeutil.Now().UnixNano()
 	var count int64
 	for it.Seek(engine.MVCCKey{}); ; it.Next() {
 		if ok, err := it.Valid(); err != nil {
@@ -510,7 +661,7 @@ func writeRocksDB(
 		}
 
 		kv.Key = it.UnsafeKey()
-		kv.Key.Timestamp.WallTime = ts
+		kv.Key.Timestamp.WallTime = walltime
 		kv.Value = it.UnsafeValue()
 
 		if err := sst.Add(kv); err != nil {
@@ -539,13 +690,15 @@ func writeRocksDB(
 }
 
 // makeBackup writes sst files from contents to destDir and creates a backup
-// descriptor. It returns the number of SST files written.
+// descriptor, encrypting both if encryption is set. It returns the number of
+// SST files written.
 func makeBackup(
 	ctx context.Context,
 	parentID sqlbase.ID,
 	tableDesc *sqlbase.TableDescriptor,
 	destDir string,
 	contentCh <-chan sstContent,
+	encryption *roachpb.FileEncryptionOptions,
 ) (int64, error) {
 	backupDesc := BackupDescriptor{
 		FormatVersion: BackupFormatInitialVersion,
@@ -561,17 +714,39 @@ func makeBackup(
 	}
 	defer es.Close()
 
-	i := 0
+	if err := writeSSTs(ctx, es, contentCh, encryption, &backupDesc); err != nil {
+		return 0, err
+	}
+	err = finalizeCSVBackup(ctx, &backupDesc, parentID, tableDesc, es, encryption)
+	return int64(len(backupDesc.Files)), err
+}
+
+// writeSSTs writes the sst files from contentCh to es, encrypting them if
+// encryption is set, and adds them to backupDesc.
+func writeSSTs(
+	ctx context.Context,
+	es storageccl.ExportStorage,
+	contentCh <-chan sstContent,
+	encryption *roachpb.FileEncryptionOptions,
+	backupDesc *BackupDescriptor,
+) error {
+	i := len(backupDesc.Files)
 	for sst := range contentCh {
 		backupDesc.EntryCounts.DataSize += sst.size
+		if encryption != nil {
+			var err error
+			if sst.data, err = storageccl.EncryptFile(sst.data, encryption.Key); err != nil {
+				return err
+			}
+		}
 		checksum, err := storageccl.SHA512ChecksumData(sst.data)
 		if err != nil {
-			return 0, err
+			return err
 		}
 		i++
 		name := fmt.Sprintf("%d.sst", i)
 		if err := es.WriteFile(ctx, name, bytes.NewReader(sst.data)); err != nil {
-			return 0, err
+			return err
 		}
 
 		backupDesc.Files = append(backupDesc.Files, BackupDescriptor_File{
@@ -580,9 +755,7 @@ func makeBackup(
 			Sha512: checksum,
 		})
 	}
-
-	err = finalizeCSVBackup(ctx, &backupDesc, parentID, tableDesc, es)
-	return int64(len(backupDesc.Files)), err
+	return nil
 }
 
 const csvDatabaseName = "csv"
@@ -593,17 +766,20 @@ func finalizeCSVBackup(
 	parentID sqlbase.ID,
 	tableDesc *sqlbase.TableDescriptor,
 	es storageccl.ExportStorage,
+	encryption *roachpb.FileEncryptionOptions,
 ) error {
 	if len(backupDesc.Files) == 0 {
 		return errors.New("no files in backup")
 	}
 
 	sort.Sort(backupFileDescriptors(backupDesc.Files))
-	backupDesc.Spans = []roachpb.Span{
-		{
-			Key:    backupDesc.Files[0].Span.Key,
-			EndKey: backupDesc.Files[len(backupDesc.Files)-1].Span.EndKey,
-		},
+	if len(backupDesc.Spans) == 0 {
+		backupDesc.Spans = []roachpb.Span{
+			{
+				Key:    backupDesc.Files[0].Span.Key,
+				EndKey: backupDesc.Files[len(backupDesc.Files)-1].Span.EndKey,
+			},
+		}
 	}
 	backupDesc.Descriptors = []sqlbase.Descriptor{
 		*sqlbase.WrapDescriptor(&sqlbase.DatabaseDescriptor{
@@ -616,9 +792,62 @@ func finalizeCSVBackup(
 	if err != nil {
 		return err
 	}
+	if encryption != nil {
+		if descBuf, err = storageccl.EncryptFile(descBuf, encryption.Key); err != nil {
+			return err
+		}
+	}
 	return es.WriteFile(ctx, BackupDescriptorName, bytes.NewReader(descBuf))
 }
 
+// importOptions are the options of an IMPORT that control how its files are
+// read and converted.
+type importOptions struct {
+	comma, comment rune
+	nullif         *string
+	temp           string
+	sstSize        int64
+}
+
+// parseImportOptions returns the importOptions set by the options of an
+// IMPORT statement.
+func parseImportOptions(opts map[string]string) (importOptions, error) {
+	var res importOptions
+	var err error
+	if override, ok := opts[importOptionComma]; ok {
+		res.comma, err = util.GetSingleRune(override)
+		if err != nil {
+			return res, errors.Wrap(err, "invalid comma value")
+		}
+	}
+
+	if override, ok := opts[importOptionComment]; ok {
+		res.comment, err = util.GetSingleRune(override)
+		if err != nil {
+			return res, errors.Wrap(err, "invalid comment value")
+		}
+	}
+
+	if override, ok := opts[importOptionNullIf]; ok {
+		res.nullif = &override
+	}
+
+	if override, ok := opts[importOptionTemp]; ok {
+		res.temp = override
+	} else {
+		return res, errors.Errorf("must provide a temporary storage location")
+	}
+
+	res.sstSize = config.DefaultZoneConfig().RangeMaxBytes / 2
+	if override, ok := opts[importOptionSSTSize]; ok {
+		res.sstSize, err = humanizeutil.ParseBytes(override)
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
 func importPlanHook(
 	stmt parser.Statement, p sql.PlanHookState,
 ) (func(context.Context, chan<- parser.Datums) error, sqlbase.ResultColumns, error) {
@@ -637,14 +866,16 @@ func importPlanHook(
 	}
 
 	var createFileFn func() (string, error)
-	if importStmt.CreateDefs == nil {
+	if importStmt.CreateFile != nil {
 		createFileFn, err = p.TypeAsString(importStmt.CreateFile, "IMPORT")
 		if err != nil {
 			return nil, nil, err
 		}
 	}
 
-	if importStmt.FileFormat != "CSV" {
+	switch importStmt.FileFormat {
+	case importFormatCSV, importFormatPGDump, importFormatMySQLDump:
+	default:
 		// not possible with current parser rules.
 		return nil, nil, errors.Errorf("unsupported import format: %q", importStmt.FileFormat)
 	}
@@ -675,7 +906,14 @@ func importPlanHook(
 		}
 
 		var targetDB string
-		if override, ok := opts[restoreOptIntoDB]; !ok {
+		if importStmt.Into {
+			if importStmt.FileFormat != importFormatCSV {
+				return errors.Errorf("IMPORT INTO does not support %s files", importStmt.FileFormat)
+			}
+			if _, ok := opts[restoreOptIntoDB]; ok {
+				return errors.Errorf("option %q is not supported with IMPORT INTO", restoreOptIntoDB)
+			}
+		} else if override, ok := opts[restoreOptIntoDB]; !ok {
 			if session := p.EvalContext().Database; session != "" {
 				targetDB = session
 			} else {
@@ -686,41 +924,17 @@ func importPlanHook(
 			// TODO(dt): verify db exists
 		}
 
-		var comma rune
-		if override, ok := opts[importOptionComma]; ok {
-			comma, err = util.GetSingleRune(override)
-			if err != nil {
-				return errors.Wrap(err, "invalid comma value")
-			}
-		}
-
-		var comment rune
-		if override, ok := opts[importOptionComment]; ok {
-			comment, err = util.GetSingleRune(override)
-			if err != nil {
-				return errors.Wrap(err, "invalid comment value")
+		if importStmt.FileFormat != importFormatCSV {
+			for _, opt := range []string{importOptionComma, importOptionComment, importOptionNullIf} {
+				if _, ok := opts[opt]; ok {
+					return errors.Errorf("option %q is not supported with %s", opt, importStmt.FileFormat)
+				}
 			}
 		}
 
-		var nullif *string
-		if override, ok := opts[importOptionNullIf]; ok {
-			nullif = &override
-		}
-
-		var temp string
-		if override, ok := opts[importOptionTemp]; ok {
-			temp = override
-		} else {
-			return errors.Errorf("must provide a temporary storage location")
-		}
-
-		sstSize := config.DefaultZoneConfig().RangeMaxBytes / 2
-		if override, ok := opts[importOptionSSTSize]; ok {
-			sz, err := humanizeutil.ParseBytes(override)
-			if err != nil {
-				return err
-			}
-			sstSize = sz
+		csvOpts, err := parseImportOptions(opts)
+		if err != nil {
+			return err
 		}
 
 		distributed := false
@@ -731,60 +945,90 @@ func importPlanHook(
 			distributed = true
 		}
 
-		var create *parser.CreateTable
-		if importStmt.CreateDefs != nil {
-			normName := parser.NormalizableTableName{TableNameReference: importStmt.Table}
-			create = &parser.CreateTable{Table: normName, Defs: importStmt.CreateDefs}
-		} else {
-			filename, err := createFileFn()
+		// The SSTs written to the temporary storage location are encrypted
+		// like those of a backup, and decrypted when they are restored.
+		var encryption *roachpb.FileEncryptionOptions
+		passphrase, encrypted := opts[backupOptEncPassphrase]
+		if encrypted {
+			var info EncryptionInfo
+			if info.Salt, err = storageccl.GenerateSalt(); err != nil {
+				return err
+			}
+			es, err := exportStorageFromURI(ctx, csvOpts.temp)
 			if err != nil {
 				return err
 			}
-			create, err = readCreateTableFromStore(ctx, filename)
+			err = writeEncryptionInfo(ctx, es, &info)
+			es.Close()
 			if err != nil {
 				return err
 			}
-			if named, parsed := importStmt.Table.String(), create.Table.String(); parsed != named {
-				return errors.Errorf("importing table %q, but file specifies a schema for table %q", named, parsed)
+			encryption = &roachpb.FileEncryptionOptions{
+				Key: storageccl.GenerateKey([]byte(passphrase), info.Salt),
 			}
 		}
 
-		parentID := defaultCSVParentID
-		tableDesc, err := makeCSVTableDescriptor(ctx, create, parentID, defaultCSVTableID)
-		if err != nil {
-			return err
+		if importStmt.Into {
+			return importInto(ctx, p, importStmt, files, opts, encryption, resultsCh)
 		}
 
-		if distributed {
-			_, err = doDistributedCSVTransform(
-				ctx, files, p, tableDesc, temp,
-				comma, comment, nullif, walltime,
-			)
-			if err != nil {
+		if importStmt.Table == nil {
+			// Dumps define their own tables.
+			if err := importDump(
+				ctx, p, importStmt.FileFormat, files, csvOpts.temp, csvOpts.sstSize, distributed,
+				encryption, walltime,
+			); err != nil {
 				return err
 			}
 		} else {
-			_, _, _, err = doLocalCSVTransform(
-				ctx, parentID, tableDesc, temp, files,
-				comma, comment, nullif, sstSize,
-			)
+			var create *parser.CreateTable
+			if importStmt.CreateDefs != nil {
+				normName := parser.NormalizableTableName{TableNameReference: importStmt.Table}
+				create = &parser.CreateTable{Table: normName, Defs: importStmt.CreateDefs}
+			} else {
+				filename, err := createFileFn()
+				if err != nil {
+					return err
+				}
+				create, err = readCreateTableFromStore(ctx, filename)
+				if err != nil {
+					return err
+				}
+				if named, parsed := importStmt.Table.String(), create.Table.String(); parsed != named {
+					return errors.Errorf("importing table %q, but file specifies a schema for table %q", named, parsed)
+				}
+			}
+
+			tableDesc, err := makeCSVTableDescriptor(ctx, create, defaultCSVParentID, defaultCSVTableID)
 			if err != nil {
 				return err
 			}
+
+			return importTable(
+				ctx, p, importStmt, files, opts, tableDesc, targetDB, encryption, walltime, resultsCh,
+			)
 		}
+		// The tables of a dump are restored together by a RESTORE job.
 		restore := &parser.Restore{
 			Targets: parser.TargetList{
 				Tables: []parser.TablePattern{&parser.AllTablesSelector{Database: csvDatabaseName}},
 			},
-			From: parser.Exprs{parser.NewDString(temp)},
+			From: []parser.Exprs{{parser.NewDString(csvOpts.temp)}},
 		}
-		from := []string{temp}
+		from := [][]string{{csvOpts.temp}}
 		opts = map[string]string{restoreOptIntoDB: targetDB}
+		if encrypted {
+			opts[backupOptEncPassphrase] = passphrase
+		}
 		return doRestorePlan(ctx, restore, p, from, opts, resultsCh)
 	}
 	return fn, restoreHeader, nil
 }
 
+// doDistributedCSVTransform converts the rows of files into SSTs with a
+// distributed flow, writing them and a backup descriptor, based on backupDesc,
+// to temp. Nothing is written if the files have no rows. It returns the
+// number of SSTs written.
 func doDistributedCSVTransform(
 	ctx context.Context,
 	files []string,
@@ -794,6 +1038,8 @@ func doDistributedCSVTransform(
 	comma, comment rune,
 	nullif *string,
 	walltime int64,
+	encryption *roachpb.FileEncryptionOptions,
+	backupDesc BackupDescriptor,
 ) (int64, error) {
 	evalCtx := p.EvalContext()
 
@@ -834,14 +1080,15 @@ func doDistributedCSVTransform(
 		comma, comment,
 		nullif,
 		walltime,
+		encryption,
 	); err != nil {
 		return 0, err
 	}
 
-	backupDesc := BackupDescriptor{
-		FormatVersion: BackupFormatInitialVersion,
-	}
 	n := rows.Len()
+	if n == 0 {
+		return 0, nil
+	}
 	for i := 0; i < n; i++ {
 		row := rows.At(i)
 		name := row[0].(*parser.DString)
@@ -870,7 +1117,9 @@ func doDistributedCSVTransform(
 	}
 	defer es.Close()
 
-	if err := finalizeCSVBackup(ctx, &backupDesc, defaultCSVParentID, tableDesc, es); err != nil {
+	if err := finalizeCSVBackup(
+		ctx, &backupDesc, defaultCSVParentID, tableDesc, es, encryption,
+	); err != nil {
 		return 0, err
 	}
 	total := int64(len(backupDesc.Files))
@@ -1028,6 +1277,7 @@ func newSSTWriterProcessor(
 		uri:           spec.Destination,
 		name:          spec.Name,
 		walltimeNanos: spec.WalltimeNanos,
+		encryption:    spec.Encryption,
 		input:         input,
 		output:        output,
 		tempStorage:   flowCtx.TempStorage,
@@ -1042,6 +1292,7 @@ type sstWriter struct {
 	uri           string
 	name          string
 	walltimeNanos int64
+	encryption    *roachpb.FileEncryptionOptions
 	input         distsqlrun.RowSource
 	out           distsqlrun.ProcOutputHelper
 	output        distsqlrun.RowReceiver
@@ -1131,6 +1382,12 @@ func (sp *sstWriter) Run(ctx context.Context, wg *sync.WaitGroup) {
 		if err != nil {
 			return err
 		}
+		size := len(data)
+		if sp.encryption != nil {
+			if data, err = storageccl.EncryptFile(data, sp.encryption.Key); err != nil {
+				return err
+			}
+		}
 		checksum, err := storageccl.SHA512ChecksumData(data)
 		if err != nil {
 			return err
@@ -1155,7 +1412,7 @@ func (sp *sstWriter) Run(ctx context.Context, wg *sync.WaitGroup) {
 			),
 			sqlbase.DatumToEncDatum(
 				sqlbase.ColumnType{SemanticType: sqlbase.ColumnType_INT},
-				parser.NewDInt(parser.DInt(len(data))),
+				parser.NewDInt(parser.DInt(size)),
 			),
 			sqlbase.DatumToEncDatum(
 				sqlbase.ColumnType{SemanticType: sqlbase.ColumnType_BYTES},
@@ -458,6 +458,20 @@ func TestImportStmt(t *testing.T) {
 			filesWithOpts,
 			"",
 		},
+		{
+			"schema-in-query-encrypted",
+			`IMPORT TABLE t (a int primary key, b string, index (b), index (a, b)) CSV DATA (%s) WITH temp = $1, encryption_passphrase = 'abcdefg'`,
+			nil,
+			files,
+			"",
+		},
+		{
+			"schema-in-query-dist-encrypted",
+			`IMPORT TABLE t (a int primary key, b string, index (b), index (a, b)) CSV DATA (%s) WITH temp = $1, distributed, encryption_passphrase = 'abcdefg'`,
+			nil,
+			files,
+			"",
+		},
 		{
 			"missing-temp",
 			`IMPORT TABLE t (a int primary key, b string, index (b), index (a, b)) CSV DATA (%s)`,
@@ -0,0 +1,338 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
+	"github.com/cockroachdb/cockroach/pkg/ccl/utilccl"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/distsqlrun"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/util"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing"
+	"github.com/pkg/errors"
+)
+
+const (
+	exportOptionDelimiter = "delimiter"
+	exportOptionNullAs    = "nullas"
+	exportOptionChunkSize = "chunk_rows"
+
+	exportChunkSizeDefault = 100000
+	exportFilePatternPart  = "%part%"
+	exportFilePatternDef   = "export" + exportFilePatternPart + ".csv"
+)
+
+var exportOutputTypes = []sqlbase.ColumnType{
+	{SemanticType: sqlbase.ColumnType_STRING},
+	{SemanticType: sqlbase.ColumnType_INT},
+	{SemanticType: sqlbase.ColumnType_INT},
+}
+
+// exportPlanHook implements sql.PlanHookFn.
+func exportPlanHook(
+	stmt parser.Statement, p sql.PlanHookState,
+) (func(context.Context, chan<- parser.Datums) error, sqlbase.ResultColumns, error) {
+	exportStmt, ok := stmt.(*parser.Export)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	if err := utilccl.CheckEnterpriseEnabled(
+		p.ExecCfg().Settings, p.ExecCfg().ClusterID(), p.ExecCfg().Organization.Get(), "EXPORT",
+	); err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.RequireSuperUser("EXPORT"); err != nil {
+		return nil, nil, err
+	}
+
+	if exportStmt.FileFormat != importFormatCSV {
+		// not possible with current parser rules.
+		return nil, nil, errors.Errorf("unsupported export format: %q", exportStmt.FileFormat)
+	}
+
+	fileFn, err := p.TypeAsString(exportStmt.File, "EXPORT")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	optsFn, err := p.TypeAsStringOpts(exportStmt.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := sqlbase.ResultColumns{
+		{Name: "filename", Typ: parser.TypeString},
+		{Name: "rows", Typ: parser.TypeInt},
+		{Name: "bytes", Typ: parser.TypeInt},
+	}
+
+	fn := func(ctx context.Context, resultsCh chan<- parser.Datums) error {
+		ctx, span := tracing.ChildSpan(ctx, exportStmt.StatementTag())
+		defer tracing.FinishSpan(span)
+
+		file, err := fileFn()
+		if err != nil {
+			return err
+		}
+
+		opts, err := optsFn()
+		if err != nil {
+			return err
+		}
+
+		var delimiter rune
+		if override, ok := opts[exportOptionDelimiter]; ok {
+			delimiter, err = util.GetSingleRune(override)
+			if err != nil {
+				return errors.Wrap(err, "invalid delimiter value")
+			}
+		}
+
+		var nullAs *string
+		if override, ok := opts[exportOptionNullAs]; ok {
+			nullAs = &override
+		}
+
+		chunkSize := int64(exportChunkSizeDefault)
+		if override, ok := opts[exportOptionChunkSize]; ok {
+			chunkSize, err = strconv.ParseInt(override, 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s value", exportOptionChunkSize)
+			}
+			if chunkSize < 1 {
+				return errors.Errorf("%s must be at least 1", exportOptionChunkSize)
+			}
+		}
+
+		// Check the destination before running the query, so a bad URI is
+		// reported without first doing all of its work.
+		if _, err := storageccl.ExportStorageConfFromURI(file); err != nil {
+			return err
+		}
+
+		writer := distsqlrun.ProcessorCoreUnion{CSVWriter: &distsqlrun.CSVWriterSpec{
+			Destination:  file,
+			NamePattern:  exportFilePatternDef,
+			Delimiter:    delimiter,
+			NullEncoding: nullAs,
+			ChunkRows:    chunkSize,
+		}}
+
+		evalCtx := p.EvalContext()
+		ci := sqlbase.ColTypeInfoFromColTypes(exportOutputTypes)
+		rows := sqlbase.NewRowContainer(evalCtx.Mon.MakeBoundAccount(), ci, 0)
+		defer rows.Close(ctx)
+
+		if err := p.DistExport(
+			ctx, exportStmt.Query, writer, exportOutputTypes, sql.NewRowResultWriter(parser.Rows, rows),
+		); err != nil {
+			return err
+		}
+
+		for i, n := 0, rows.Len(); i < n; i++ {
+			row := rows.At(i)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case resultsCh <- parser.Datums{row[0], row[1], row[2]}:
+			}
+		}
+		return nil
+	}
+	return fn, header, nil
+}
+
+func newCSVWriterProcessor(
+	flowCtx *distsqlrun.FlowCtx,
+	spec distsqlrun.CSVWriterSpec,
+	input distsqlrun.RowSource,
+	output distsqlrun.RowReceiver,
+) (distsqlrun.Processor, error) {
+	sp := &csvWriter{
+		flowCtx: flowCtx,
+		spec:    spec,
+		input:   input,
+		output:  output,
+	}
+	if err := sp.out.Init(&distsqlrun.PostProcessSpec{}, exportOutputTypes, &flowCtx.EvalCtx, output); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// csvWriter is a processor that writes its input rows to CSV files in an
+// export store, starting a new file every spec.ChunkRows rows, and outputs a
+// row for each file written. Each file is buffered in memory before it is
+// written, which is accounted for against the memory monitor of the flow.
+type csvWriter struct {
+	flowCtx *distsqlrun.FlowCtx
+	spec    distsqlrun.CSVWriterSpec
+	input   distsqlrun.RowSource
+	out     distsqlrun.ProcOutputHelper
+	output  distsqlrun.RowReceiver
+}
+
+var _ distsqlrun.Processor = &csvWriter{}
+
+func (sp *csvWriter) OutputTypes() []sqlbase.ColumnType {
+	return exportOutputTypes
+}
+
+func (sp *csvWriter) Run(ctx context.Context, wg *sync.WaitGroup) {
+	if wg != nil {
+		defer wg.Done()
+	}
+
+	defer distsqlrun.DrainAndForwardMetadata(ctx, sp.input, sp.output)
+	err := func() error {
+		conf, err := storageccl.ExportStorageConfFromURI(sp.spec.Destination)
+		if err != nil {
+			return err
+		}
+		es, err := storageccl.MakeExportStorage(ctx, conf)
+		if err != nil {
+			return err
+		}
+		defer es.Close()
+
+		// Several writers of the same export can run on a node, so the file
+		// names include an ID that is unique to this one.
+		nodeID := sp.flowCtx.EvalCtx.NodeID
+		prefix := fmt.Sprintf("n%d.%d", nodeID, parser.GenerateUniqueInt(nodeID))
+
+		input := distsqlrun.MakeNoMetadataRowSource(sp.input, sp.output)
+		alloc := &sqlbase.DatumAlloc{}
+
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		if sp.spec.Delimiter != 0 {
+			writer.Comma = sp.spec.Delimiter
+		}
+		var nullAs string
+		if sp.spec.NullEncoding != nil {
+			nullAs = *sp.spec.NullEncoding
+		}
+
+		memAcc := sp.flowCtx.EvalCtx.Mon.MakeBoundAccount()
+		defer memAcc.Close(ctx)
+
+		var record []string
+		for chunk := 0; ; chunk++ {
+			buf.Reset()
+			memAcc.Clear(ctx)
+			rows := int64(0)
+			done := false
+			for sp.spec.ChunkRows == 0 || rows < sp.spec.ChunkRows {
+				row, err := input.NextRow()
+				if err != nil {
+					return err
+				}
+				if row == nil {
+					done = true
+					break
+				}
+				record = record[:0]
+				// The size of the row in the file, ignoring any quoting, is the
+				// size of its fields and a delimiter or newline after each.
+				rowSize := int64(len(row))
+				for _, ed := range row {
+					if err := ed.EnsureDecoded(alloc); err != nil {
+						return err
+					}
+					field := exportDatumString(ed.Datum, nullAs)
+					rowSize += int64(len(field))
+					record = append(record, field)
+				}
+				if err := memAcc.Grow(ctx, rowSize); err != nil {
+					return errors.Wrapf(err, "buffering export file (consider a smaller %s)", exportOptionChunkSize)
+				}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+				rows++
+			}
+			if rows == 0 {
+				break
+			}
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+
+			part := fmt.Sprintf("%s.%d", prefix, chunk)
+			filename := strings.Replace(sp.spec.NamePattern, exportFilePatternPart, part, -1)
+			size := buf.Len()
+			if err := es.WriteFile(ctx, filename, bytes.NewReader(buf.Bytes())); err != nil {
+				return err
+			}
+
+			res := sqlbase.EncDatumRow{
+				sqlbase.DatumToEncDatum(
+					sqlbase.ColumnType{SemanticType: sqlbase.ColumnType_STRING},
+					parser.NewDString(filename),
+				),
+				sqlbase.DatumToEncDatum(
+					sqlbase.ColumnType{SemanticType: sqlbase.ColumnType_INT},
+					parser.NewDInt(parser.DInt(rows)),
+				),
+				sqlbase.DatumToEncDatum(
+					sqlbase.ColumnType{SemanticType: sqlbase.ColumnType_INT},
+					parser.NewDInt(parser.DInt(size)),
+				),
+			}
+			cs, err := sp.out.EmitRow(ctx, res)
+			if err != nil {
+				return err
+			}
+			if cs != distsqlrun.NeedMoreRows {
+				return errors.New("unexpected closure of consumer")
+			}
+			if done {
+				break
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		distsqlrun.DrainAndClose(ctx, sp.output, err)
+		return
+	}
+
+	sp.out.Close()
+}
+
+// exportDatumString returns the CSV field for d. Strings are written as-is,
+// leaving any quoting to the CSV writer, and NULL is written as nullAs.
+func exportDatumString(d parser.Datum, nullAs string) string {
+	if d == parser.DNull {
+		return nullAs
+	}
+	if s, ok := d.(*parser.DString); ok {
+		return string(*s)
+	}
+	return parser.AsStringWithFlags(d, parser.FmtBareStrings)
+}
+
+func init() {
+	sql.AddPlanHook(exportPlanHook)
+	distsqlrun.NewCSVWriterProcessor = newCSVWriterProcessor
+}
@@ -0,0 +1,165 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/testcluster"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestExportStmt(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const (
+		nodes   = 3
+		numRows = 100
+	)
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, nodes, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+	sqlDB := sqlutils.MakeSQLRunner(t, tc.Conns[0])
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	sqlDB.Exec(`CREATE DATABASE d`)
+	sqlDB.Exec(`CREATE TABLE d.t (a INT PRIMARY KEY, b STRING, c INT)`)
+	sqlDB.Exec(`INSERT INTO d.t SELECT i, IF(i % 10 = 0, NULL, 'b,' || i::STRING), i * 2 FROM generate_series(1, $1) AS g(i)`, numRows)
+	// Split the table so that its rows are read, and written, by several
+	// processors on several nodes.
+	for i := 1; i < nodes; i++ {
+		sqlDB.Exec(`ALTER TABLE d.t SPLIT AT VALUES ($1)`, i*numRows/nodes)
+		sqlDB.Exec(fmt.Sprintf(`ALTER TABLE d.t TESTING_RELOCATE VALUES (ARRAY[%d], %d)`, i+1, i*numRows/nodes))
+	}
+
+	// readExport reads the CSV files listed in the results of an EXPORT into
+	// dest, checking the reported row counts and sizes.
+	readExport := func(t *testing.T, dest string, delimiter rune, res [][]string) [][]string {
+		var records [][]string
+		for _, r := range res {
+			filename, rows, size := r[0], r[1], r[2]
+			path := filepath.Join(dest, filename)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := fmt.Sprint(info.Size()); size != expected {
+				t.Errorf("%s: expected %s bytes, got %s", filename, expected, size)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := csv.NewReader(f)
+			reader.Comma = delimiter
+			fileRecords, err := reader.ReadAll()
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := fmt.Sprint(len(fileRecords)); rows != expected {
+				t.Errorf("%s: expected %s rows, got %s", filename, expected, rows)
+			}
+			records = append(records, fileRecords...)
+		}
+		sort.Slice(records, func(i, j int) bool {
+			return strings.Join(records[i], "|") < strings.Join(records[j], "|")
+		})
+		return records
+	}
+
+	expected := func(null string) [][]string {
+		var records [][]string
+		for i := 1; i <= numRows; i++ {
+			b := fmt.Sprintf("b,%d", i)
+			if i%10 == 0 {
+				b = null
+			}
+			records = append(records, []string{fmt.Sprint(i), b, fmt.Sprint(i * 2)})
+		}
+		sort.Slice(records, func(i, j int) bool {
+			return strings.Join(records[i], "|") < strings.Join(records[j], "|")
+		})
+		return records
+	}
+
+	t.Run("default", func(t *testing.T) {
+		dest := filepath.Join(dir, t.Name())
+		res := sqlDB.QueryStr(`EXPORT INTO CSV $1 FROM SELECT * FROM d.t`, fmt.Sprintf("nodelocal://%s", dest))
+		if len(res) < 1 {
+			t.Fatal("expected at least one file")
+		}
+		if got, expected := readExport(t, dest, ',', res), expected(""); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		dest := filepath.Join(dir, t.Name())
+		res := sqlDB.QueryStr(
+			`EXPORT INTO CSV $1 WITH delimiter = '|', nullas = 'NULL', chunk_rows = '7' FROM SELECT a, b, c FROM d.t`,
+			fmt.Sprintf("nodelocal://%s", dest),
+		)
+		if len(res) < numRows/7 {
+			t.Fatalf("expected at least %d files, got %d", numRows/7, len(res))
+		}
+		for _, r := range res {
+			if n, err := strconv.Atoi(r[1]); err != nil {
+				t.Fatal(err)
+			} else if n > 7 {
+				t.Errorf("%s: expected at most 7 rows, got %s", r[0], r[1])
+			}
+		}
+		if got, expected := readExport(t, dest, '|', res), expected("NULL"); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		dest := filepath.Join(dir, t.Name())
+		res := sqlDB.QueryStr(`EXPORT INTO CSV $1 FROM SELECT * FROM d.t WHERE a < 0`, fmt.Sprintf("nodelocal://%s", dest))
+		if len(res) != 0 {
+			t.Fatalf("expected no files, got %v", res)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dest := fmt.Sprintf("nodelocal://%s", filepath.Join(dir, t.Name()))
+		for _, tc := range []struct {
+			query string
+			err   string
+		}{
+			{`EXPORT INTO CSV $1 WITH chunk_rows = '0' FROM TABLE d.t`, "chunk_rows must be at least 1"},
+			{`EXPORT INTO CSV $1 WITH chunk_rows = 'a' FROM TABLE d.t`, "invalid chunk_rows value"},
+			{`EXPORT INTO CSV $1 WITH delimiter = '||' FROM TABLE d.t`, "invalid delimiter value"},
+			{`EXPORT INTO CSV $1 FROM SELECT * FROM d.nope`, `relation "d.nope" does not exist`},
+		} {
+			if _, err := sqlDB.DB.Exec(tc.query, dest); !testutils.IsError(err, tc.err) {
+				t.Errorf("%s: expected error %q, got %v", tc.query, tc.err, err)
+			}
+		}
+	})
+}
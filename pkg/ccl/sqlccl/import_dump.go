@@ -0,0 +1,1348 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/uuid"
+	"github.com/pkg/errors"
+)
+
+// makeDumpNullMarker returns the marker for NULLs in the CSV files the rows of
+// a dump are converted to. Values are not escaped in the CSV files, so rather
+// than COPY's `\N`, which a string can be equal to, the marker is made unique
+// to the import. A value equal to it is rejected rather than imported as NULL.
+func makeDumpNullMarker() string {
+	return `\N` + uuid.MakeV4().String()
+}
+
+// dumpPartSize is the size of the CSV files the rows of a table in a dump are
+// split into. The rows of a part are buffered in memory until it is written.
+const dumpPartSize = 64 << 20
+
+// importDump reads the tables defined in the dump files and converts their
+// rows to SSTs, using the same local or distributed transform as CSV files.
+// The rows are written to CSV files in temp as they are read. Each table is
+// then converted into its own subdirectory of temp, after which a backup
+// descriptor covering all of the tables is written to temp so they can be
+// restored together.
+//
+// Unlike the conversion of CSV files, which is checkpointed by an IMPORT job,
+// the conversion of a dump is not resumable: the tables and their rows are
+// only known once all of its files have been read. The tables are then
+// restored by a RESTORE job, which is.
+func importDump(
+	ctx context.Context,
+	p sql.PlanHookState,
+	format string,
+	files []string,
+	temp string,
+	sstSize int64,
+	distributed bool,
+	encryption *roachpb.FileEncryptionOptions,
+	walltime int64,
+) error {
+	es, err := exportStorageFromURI(ctx, temp)
+	if err != nil {
+		return err
+	}
+	defer es.Close()
+
+	d := newDumpReader(format, es)
+	defer d.close(ctx)
+	for _, file := range files {
+		if err := d.readFile(ctx, file); err != nil {
+			return errors.Wrapf(err, "reading %s", file)
+		}
+	}
+	if err := d.flush(ctx); err != nil {
+		return err
+	}
+	tables, err := d.makeTableDescs(ctx)
+	if err != nil {
+		return err
+	}
+
+	backupDesc := BackupDescriptor{
+		FormatVersion: BackupFormatInitialVersion,
+		Descriptors: []sqlbase.Descriptor{
+			*sqlbase.WrapDescriptor(&sqlbase.DatabaseDescriptor{
+				Name: csvDatabaseName,
+				ID:   defaultCSVParentID,
+			}),
+		},
+	}
+	descs := make([]*sqlbase.TableDescriptor, len(tables))
+	for i, table := range tables {
+		descs[i] = table.desc
+		backupDesc.Descriptors = append(backupDesc.Descriptors, *sqlbase.WrapDescriptor(table.desc))
+		if table.rows == 0 {
+			// There is nothing to convert, and the CSV transforms refuse to
+			// write a backup without any files.
+			continue
+		}
+
+		dir := strconv.Itoa(i)
+		dataURIs := make([]string, len(table.parts))
+		for j, part := range table.parts {
+			if dataURIs[j], err = joinURIPath(temp, part); err != nil {
+				return err
+			}
+		}
+		tableTemp, err := joinURIPath(temp, dir)
+		if err != nil {
+			return err
+		}
+
+		nullif := d.nullMarker
+		if distributed {
+			_, err = doDistributedCSVTransform(
+				ctx, dataURIs, p, table.desc, tableTemp,
+				',', 0 /* comment */, &nullif, walltime, encryption,
+				BackupDescriptor{FormatVersion: BackupFormatInitialVersion},
+			)
+		} else {
+			_, _, _, err = doLocalCSVTransform(
+				ctx, defaultCSVParentID, table.desc, tableTemp, dataURIs,
+				',', 0 /* comment */, &nullif, sstSize, encryption, walltime,
+			)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "table %q", table.desc.Name)
+		}
+		// Collect the files from the backup descriptor of the table.
+		tableStore, err := exportStorageFromURI(ctx, tableTemp)
+		if err != nil {
+			return err
+		}
+		tableBackup, err := readBackupDescriptor(ctx, tableStore, BackupDescriptorName, encryption)
+		tableStore.Close()
+		if err != nil {
+			return err
+		}
+		for _, f := range tableBackup.Files {
+			f.Path = path.Join(dir, f.Path)
+			backupDesc.Files = append(backupDesc.Files, f)
+		}
+		backupDesc.EntryCounts.DataSize += tableBackup.EntryCounts.DataSize
+	}
+	backupDesc.Spans = spansForAllTableIndexes(descs)
+
+	return writeBackupDescriptor(ctx, es, BackupDescriptorName, &backupDesc, encryption)
+}
+
+// joinURIPath returns uri with elem appended to its path.
+func joinURIPath(uri, elem string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, elem)
+	return u.String(), nil
+}
+
+// dumpTable is a table defined in a dump file.
+type dumpTable struct {
+	name   string
+	create *parser.CreateTable
+	// columns are the formatted names of the columns of the table, in the
+	// order their values are written to the CSV file.
+	columns []string
+	desc    *sqlbase.TableDescriptor
+
+	// parts are the names of the CSV files the rows of the table were written
+	// to, which are never encrypted.
+	parts []string
+	rows  int64
+	// num is the position of the table in the order it was defined in, which
+	// names its CSV files.
+	num int
+}
+
+// dumpReader accumulates the tables defined in dump files, along with any
+// indexes and constraints added to them by later statements, and writes their
+// rows to CSV files in an ExportStorage. Rows are buffered for one table at a
+// time, and written out whenever dumpPartSize is reached or rows for another
+// table are read.
+type dumpReader struct {
+	mysql bool
+	es    storageccl.ExportStorage
+	// nullMarker marks NULLs in the CSV files.
+	nullMarker string
+
+	tables map[string]*dumpTable
+	// order is the order in which tables were defined.
+	order []*dumpTable
+
+	// buffered is the table whose rows are in buf, if any.
+	buffered *dumpTable
+	buf      bytes.Buffer
+	csv      *csv.Writer
+}
+
+func newDumpReader(format string, es storageccl.ExportStorage) *dumpReader {
+	d := &dumpReader{
+		mysql:      format == importFormatMySQLDump,
+		es:         es,
+		nullMarker: makeDumpNullMarker(),
+		tables:     make(map[string]*dumpTable),
+	}
+	d.csv = csv.NewWriter(&d.buf)
+	return d
+}
+
+// close removes the CSV files of all tables.
+func (d *dumpReader) close(ctx context.Context) {
+	for _, t := range d.order {
+		for _, part := range t.parts {
+			if err := d.es.Delete(ctx, part); err != nil {
+				log.Infof(ctx, "could not remove %s: %s", part, err)
+			}
+		}
+	}
+}
+
+// flush writes the buffered rows to a new CSV file of their table.
+func (d *dumpReader) flush(ctx context.Context) error {
+	d.csv.Flush()
+	if err := d.csv.Error(); err != nil {
+		return err
+	}
+	t := d.buffered
+	if t == nil || d.buf.Len() == 0 {
+		return nil
+	}
+	part := fmt.Sprintf("dump-%d-%d.csv", t.num, len(t.parts))
+	if err := d.es.WriteFile(ctx, part, bytes.NewReader(d.buf.Bytes())); err != nil {
+		return err
+	}
+	t.parts = append(t.parts, part)
+	d.buf.Reset()
+	d.buffered = nil
+	return nil
+}
+
+func (d *dumpReader) readFile(ctx context.Context, uri string) error {
+	es, err := exportStorageFromURI(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer es.Close()
+	r, err := es.ReadFile(ctx, "")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	done := ctx.Done()
+	s := &dumpScanner{r: bufio.NewReader(r), mysql: d.mysql, nullMarker: d.nullMarker, line: 1}
+	for {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+		toks, err := s.scan()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "line %d", s.line)
+		}
+		line := s.line
+		if err := d.process(ctx, s, toks); err != nil {
+			return errors.Wrapf(err, "statement ending on line %d", line)
+		}
+	}
+}
+
+// process handles a single statement of a dump. Statements that do not define
+// tables, indexes, constraints or rows, such as those for sequences,
+// permissions or session settings, are ignored.
+func (d *dumpReader) process(ctx context.Context, s *dumpScanner, toks []dumpToken) error {
+	if d.mysql {
+		toks = dropCharsetIntroducers(toks)
+	} else {
+		toks = dropPublicSchema(toks)
+	}
+	switch {
+	case hasPrefixWords(toks, "CREATE", "TABLE"):
+		return d.createTable(toks)
+	case hasPrefixWords(toks, "CREATE", "INDEX"), hasPrefixWords(toks, "CREATE", "UNIQUE", "INDEX"):
+		return d.createIndex(toks)
+	case hasPrefixWords(toks, "ALTER", "TABLE"):
+		return d.alterTable(toks)
+	case hasPrefixWords(toks, "COPY") && !d.mysql:
+		return d.copy(ctx, s, toks)
+	case hasPrefixWords(toks, "INSERT", "INTO"):
+		return d.insert(ctx, toks)
+	default:
+		return nil
+	}
+}
+
+func (d *dumpReader) createTable(toks []dumpToken) error {
+	if d.mysql {
+		var err error
+		if toks, err = translateMySQLCreateTable(toks); err != nil {
+			return err
+		}
+	}
+	stmt, err := parseDumpStatement(toks)
+	if err != nil {
+		return err
+	}
+	create, ok := stmt.(*parser.CreateTable)
+	if !ok || create.AsSource != nil {
+		return errors.Errorf("unsupported statement: %s", stmt)
+	}
+	tn, err := create.Table.Normalize()
+	if err != nil {
+		return err
+	}
+	name := tn.Table()
+	if _, ok := d.tables[name]; ok {
+		return errors.Errorf("table %q is defined more than once", name)
+	}
+	// The tables are imported into a new database, where they cannot exist.
+	create.IfNotExists = false
+
+	t := &dumpTable{name: name, create: create, num: len(d.order)}
+	for _, def := range create.Defs {
+		col, ok := def.(*parser.ColumnTableDef)
+		if !ok {
+			continue
+		}
+		// Dumps include values for every column, so defaults, which usually
+		// reference sequences or functions that are not imported, are dropped.
+		col.DefaultExpr.Expr = nil
+		col.DefaultExpr.ConstraintName = ""
+		t.columns = append(t.columns, parser.AsString(col.Name))
+	}
+	d.tables[name] = t
+	d.order = append(d.order, t)
+	return nil
+}
+
+// createIndex adds the index created by a CREATE INDEX statement to the
+// definition of its table.
+func (d *dumpReader) createIndex(toks []dumpToken) error {
+	// Drop index methods, like USING btree, which only have one equivalent.
+	var filtered []dumpToken
+	for i := 0; i < len(toks); i++ {
+		if toks[i].is("USING") && i+1 < len(toks) && toks[i+1].kind != dumpPunct {
+			i++
+			continue
+		}
+		if toks[i].is("ONLY") && i > 0 && toks[i-1].is("ON") {
+			continue
+		}
+		filtered = append(filtered, toks[i])
+	}
+	stmt, err := parseDumpStatement(filtered)
+	if err != nil {
+		return err
+	}
+	ci, ok := stmt.(*parser.CreateIndex)
+	if !ok {
+		return errors.Errorf("unsupported statement: %s", stmt)
+	}
+	t, err := d.lookupTable(&ci.Table)
+	if err != nil {
+		return err
+	}
+	idx := parser.IndexTableDef{
+		Name:       ci.Name,
+		Columns:    ci.Columns,
+		Storing:    ci.Storing,
+		Interleave: ci.Interleave,
+	}
+	if ci.Unique {
+		t.create.Defs = append(t.create.Defs, &parser.UniqueConstraintTableDef{IndexTableDef: idx})
+	} else {
+		t.create.Defs = append(t.create.Defs, &idx)
+	}
+	return nil
+}
+
+// alterTable adds the constraints added by an ALTER TABLE statement to the
+// definition of its table. Other changes, like those to ownership, are
+// ignored.
+func (d *dumpReader) alterTable(toks []dumpToken) error {
+	addsConstraint := false
+	var filtered []dumpToken
+	for i, t := range toks {
+		if i == 2 && t.is("ONLY") {
+			continue
+		}
+		if t.is("ADD") && i+1 < len(toks) {
+			switch next := toks[i+1]; {
+			case next.is("CONSTRAINT"), next.is("PRIMARY"), next.is("UNIQUE"),
+				next.is("FOREIGN"), next.is("CHECK"):
+				addsConstraint = true
+			}
+		}
+		filtered = append(filtered, t)
+	}
+	if !addsConstraint {
+		return nil
+	}
+	stmt, err := parseDumpStatement(filtered)
+	if err != nil {
+		return err
+	}
+	alter, ok := stmt.(*parser.AlterTable)
+	if !ok {
+		return errors.Errorf("unsupported statement: %s", stmt)
+	}
+	t, err := d.lookupTable(&alter.Table)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range alter.Cmds {
+		add, ok := cmd.(*parser.AlterTableAddConstraint)
+		if !ok {
+			return errors.Errorf("unsupported statement: %s", stmt)
+		}
+		t.create.Defs = append(t.create.Defs, add.ConstraintDef)
+	}
+	return nil
+}
+
+// copy reads the rows following a COPY FROM stdin statement.
+func (d *dumpReader) copy(ctx context.Context, s *dumpScanner, toks []dumpToken) error {
+	stmt, err := parseDumpStatement(toks)
+	if err != nil {
+		return err
+	}
+	cp, ok := stmt.(*parser.CopyFrom)
+	if !ok || !cp.Stdin {
+		return errors.Errorf("unsupported statement: %s", stmt)
+	}
+	t, err := d.lookupTable(&cp.Table)
+	if err != nil {
+		return err
+	}
+	order, err := t.columnOrder(cp.Columns)
+	if err != nil {
+		return err
+	}
+	return s.scanCopyData(func(fields []string) error {
+		return d.writeRow(ctx, t, order, fields)
+	})
+}
+
+// insert reads the rows of an INSERT statement.
+func (d *dumpReader) insert(ctx context.Context, toks []dumpToken) error {
+	stmt, err := parseDumpStatement(toks)
+	if err != nil {
+		return err
+	}
+	ins, ok := stmt.(*parser.Insert)
+	if !ok || ins.OnConflict != nil || parser.HasReturningClause(ins.Returning) {
+		return errors.Errorf("unsupported statement: %s", stmt)
+	}
+	tableName, ok := ins.Table.(*parser.NormalizableTableName)
+	if !ok {
+		return errors.Errorf("unsupported statement: %s", stmt)
+	}
+	values, ok := ins.Rows.Select.(*parser.ValuesClause)
+	if !ok || ins.Rows.Limit != nil || ins.Rows.OrderBy != nil {
+		return errors.Errorf("expected VALUES clause: %s", stmt)
+	}
+	t, err := d.lookupTable(tableName)
+	if err != nil {
+		return err
+	}
+	order, err := t.columnOrder(ins.Columns)
+	if err != nil {
+		return err
+	}
+	var fields []string
+	for _, tuple := range values.Tuples {
+		fields = fields[:0]
+		for _, expr := range tuple.Exprs {
+			field, err := dumpValueString(expr, d.nullMarker)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, field)
+		}
+		if err := d.writeRow(ctx, t, order, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumpValueString returns the CSV field for a value in an INSERT statement,
+// which is nullMarker for NULL.
+func dumpValueString(expr parser.Expr, nullMarker string) (string, error) {
+	if expr == parser.DNull {
+		return nullMarker, nil
+	}
+	switch e := expr.(type) {
+	case *parser.StrVal:
+		d, err := e.ResolveAsType(nil, parser.TypeString)
+		if err != nil {
+			return "", err
+		}
+		if s := string(*d.(*parser.DString)); s != nullMarker {
+			return s, nil
+		}
+		return "", errors.Errorf("value %q conflicts with the NULL marker", nullMarker)
+	case *parser.NumVal, *parser.DBool:
+		return parser.AsString(e), nil
+	case *parser.UnaryExpr:
+		if _, ok := e.Expr.(*parser.NumVal); ok && e.Operator == parser.UnaryMinus {
+			return parser.AsString(e), nil
+		}
+	}
+	return "", errors.Errorf("unsupported value: %s", expr)
+}
+
+func (d *dumpReader) lookupTable(n *parser.NormalizableTableName) (*dumpTable, error) {
+	tn, err := n.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := d.tables[tn.Table()]
+	if !ok {
+		return nil, errors.Errorf("table %q is not defined", tn.Table())
+	}
+	return t, nil
+}
+
+// columnOrder returns, for each of the table's columns, the index of its value
+// in the rows of a statement listing the given columns. An empty list means
+// the rows have values for every column in order.
+func (t *dumpTable) columnOrder(names parser.UnresolvedNames) ([]int, error) {
+	order := make([]int, len(t.columns))
+	if len(names) == 0 {
+		for i := range order {
+			order[i] = i
+		}
+		return order, nil
+	}
+	if len(names) != len(t.columns) {
+		return nil, errors.Errorf("table %q: expected values for all %d columns, got %d",
+			t.name, len(t.columns), len(names))
+	}
+	for i, col := range t.columns {
+		order[i] = -1
+		for j, name := range names {
+			if parser.AsString(name) == col {
+				order[i] = j
+				break
+			}
+		}
+		if order[i] < 0 {
+			return nil, errors.Errorf("table %q: missing values for column %s", t.name, col)
+		}
+	}
+	return order, nil
+}
+
+// writeRow writes a row, whose values are ordered by order, to the CSV files
+// of the table.
+func (d *dumpReader) writeRow(
+	ctx context.Context, t *dumpTable, order []int, fields []string,
+) error {
+	if len(fields) != len(order) {
+		return errors.Errorf("table %q: expected %d values, got %d", t.name, len(order), len(fields))
+	}
+	if d.buffered != t {
+		if err := d.flush(ctx); err != nil {
+			return err
+		}
+		d.buffered = t
+	}
+	record := make([]string, len(order))
+	for i, j := range order {
+		record[i] = fields[j]
+	}
+	t.rows++
+	if err := d.csv.Write(record); err != nil {
+		return err
+	}
+	if d.buf.Len() >= dumpPartSize {
+		return d.flush(ctx)
+	}
+	return nil
+}
+
+// makeTableDescs creates the descriptors of the tables defined in the dump,
+// giving them consecutive IDs in an order where tables are created after the
+// tables they reference, so the references can be resolved. It returns the
+// tables in that order.
+func (d *dumpReader) makeTableDescs(ctx context.Context) ([]*dumpTable, error) {
+	if len(d.order) == 0 {
+		return nil, errors.New("no tables defined")
+	}
+	for _, t := range d.order {
+		// Move inline constraints, like REFERENCES, to the table level.
+		sql.HoistConstraints(t.create)
+	}
+	sorted, err := d.sortByReferences()
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(sql.TableDescsVirtualTabler, len(sorted))
+	affected := make(map[sqlbase.ID]*sqlbase.TableDescriptor)
+	for i, t := range sorted {
+		if t.create.Interleave != nil {
+			return nil, errors.Errorf("table %q: interleaved tables are not supported", t.name)
+		}
+		// TODO(mjibson): pass in an appropriate creation time #17526
+		desc, err := sql.MakeTableDesc(
+			ctx,
+			nil, /* txn */
+			resolved,
+			nil, /* SearchPath */
+			t.create,
+			defaultCSVParentID,
+			defaultCSVTableID+sqlbase.ID(i),
+			hlc.Timestamp{},
+			sqlbase.NewDefaultPrivilegeDescriptor(),
+			affected,
+			"",  /* sessionDB */
+			nil, /* EvalContext */
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "table %q", t.name)
+		}
+		// Tables with references to other tables are made in the ADD state,
+		// until the backreferences are visible, but all of the tables are
+		// restored at once.
+		desc.State = sqlbase.TableDescriptor_PUBLIC
+		t.desc = &desc
+		resolved[t.name] = t.desc
+	}
+	return sorted, nil
+}
+
+// sortByReferences orders the tables so that tables referenced by foreign keys
+// come before the tables referencing them, keeping the order they were defined
+// in otherwise.
+func (d *dumpReader) sortByReferences() ([]*dumpTable, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*dumpTable]int, len(d.order))
+	sorted := make([]*dumpTable, 0, len(d.order))
+	var visit func(t *dumpTable) error
+	visit = func(t *dumpTable) error {
+		switch state[t] {
+		case visiting:
+			return errors.Errorf("table %q: cyclic foreign key references are not supported", t.name)
+		case visited:
+			return nil
+		}
+		state[t] = visiting
+		for _, def := range t.create.Defs {
+			fk, ok := def.(*parser.ForeignKeyConstraintTableDef)
+			if !ok {
+				continue
+			}
+			tn, err := fk.Table.Normalize()
+			if err != nil {
+				return err
+			}
+			// References to tables not in the dump fail when the descriptor is
+			// made, with a better error.
+			if ref, ok := d.tables[tn.Table()]; ok && ref != t {
+				if err := visit(ref); err != nil {
+					return err
+				}
+			}
+		}
+		state[t] = visited
+		sorted = append(sorted, t)
+		return nil
+	}
+	for _, t := range d.order {
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// parseDumpStatement formats the tokens of a statement as CockroachDB SQL and
+// parses it.
+func parseDumpStatement(toks []dumpToken) (parser.Statement, error) {
+	var buf bytes.Buffer
+	for i, t := range toks {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		t.format(&buf)
+	}
+	return parser.ParseOne(buf.String())
+}
+
+func hasPrefixWords(toks []dumpToken, words ...string) bool {
+	if len(toks) < len(words) {
+		return false
+	}
+	for i, w := range words {
+		if toks[i].kind != dumpWord || !toks[i].is(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// dropPublicSchema removes the public schema from qualified names written by
+// pg_dump, like public.t, which would otherwise be read as database public.
+func dropPublicSchema(toks []dumpToken) []dumpToken {
+	out := toks[:0]
+	for i := 0; i < len(toks); i++ {
+		if (toks[i].kind == dumpWord || toks[i].kind == dumpIdent) && toks[i].s == "public" &&
+			i+2 < len(toks) && toks[i+1].is(".") && toks[i+2].kind != dumpPunct {
+			i++
+			continue
+		}
+		out = append(out, toks[i])
+	}
+	return out
+}
+
+// dropCharsetIntroducers removes the character set introducers, like _binary,
+// that mysqldump writes before some strings.
+func dropCharsetIntroducers(toks []dumpToken) []dumpToken {
+	out := toks[:0]
+	for i, t := range toks {
+		if t.kind == dumpWord && strings.HasPrefix(t.s, "_") &&
+			i+1 < len(toks) && toks[i+1].kind == dumpString {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// mysqlColumnTypes maps MySQL column types to their CockroachDB equivalents.
+// The arguments of mapped types, like the display width of int(11), are
+// dropped. Other types, like varchar(255) or decimal(10,2), are used as is.
+var mysqlColumnTypes = map[string]string{
+	"tinyint":    "SMALLINT",
+	"smallint":   "SMALLINT",
+	"mediumint":  "INT",
+	"int":        "INT",
+	"integer":    "INT",
+	"bigint":     "BIGINT",
+	"float":      "REAL",
+	"double":     "DOUBLE PRECISION",
+	"datetime":   "TIMESTAMP",
+	"timestamp":  "TIMESTAMP",
+	"year":       "INT",
+	"tinytext":   "STRING",
+	"text":       "STRING",
+	"mediumtext": "STRING",
+	"longtext":   "STRING",
+	"enum":       "STRING",
+	"set":        "STRING",
+	"json":       "STRING",
+	"tinyblob":   "BYTES",
+	"blob":       "BYTES",
+	"mediumblob": "BYTES",
+	"longblob":   "BYTES",
+	"binary":     "BYTES",
+	"varbinary":  "BYTES",
+}
+
+// translateMySQLCreateTable rewrites a CREATE TABLE statement written by
+// mysqldump as its CockroachDB equivalent. Table options, like ENGINE, and
+// FULLTEXT and SPATIAL indexes, which have no equivalent, are dropped.
+func translateMySQLCreateTable(toks []dumpToken) ([]dumpToken, error) {
+	open := -1
+	for i, t := range toks {
+		if t.is("(") {
+			open = i
+			break
+		}
+	}
+	if open < 0 {
+		return nil, errors.New("expected table definition")
+	}
+	out := append([]dumpToken(nil), toks[:open+1]...)
+	var elems [][]dumpToken
+	depth, start := 0, open+1
+	for i := open; i < len(toks) && depth >= 0; i++ {
+		switch t := toks[i]; {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+			if depth == 0 {
+				elems = append(elems, toks[start:i])
+				depth = -1
+			}
+		case t.is(",") && depth == 1:
+			elems = append(elems, toks[start:i])
+			start = i + 1
+		}
+	}
+	if depth >= 0 {
+		return nil, errors.New("unterminated table definition")
+	}
+	first := true
+	for _, elem := range elems {
+		elem, err := translateMySQLTableElem(elem)
+		if err != nil {
+			return nil, err
+		}
+		if elem == nil {
+			continue
+		}
+		if !first {
+			out = append(out, dumpToken{kind: dumpPunct, s: ","})
+		}
+		first = false
+		out = append(out, elem...)
+	}
+	return append(out, dumpToken{kind: dumpPunct, s: ")"}), nil
+}
+
+func translateMySQLTableElem(elem []dumpToken) ([]dumpToken, error) {
+	if len(elem) == 0 {
+		return nil, errors.New("empty table element")
+	}
+	switch first := elem[0]; {
+	case first.kind != dumpWord:
+		return translateMySQLColumn(elem)
+	case first.is("PRIMARY"):
+		return translateMySQLIndex(elem), nil
+	case first.is("UNIQUE"):
+		rest := elem[1:]
+		if len(rest) > 0 && (rest[0].is("KEY") || rest[0].is("INDEX")) {
+			rest = rest[1:]
+		}
+		return append([]dumpToken{
+			{kind: dumpWord, s: "UNIQUE"}, {kind: dumpWord, s: "INDEX"},
+		}, translateMySQLIndex(rest)...), nil
+	case first.is("KEY"), first.is("INDEX"):
+		return append([]dumpToken{{kind: dumpWord, s: "INDEX"}}, translateMySQLIndex(elem[1:])...), nil
+	case first.is("FULLTEXT"), first.is("SPATIAL"):
+		return nil, nil
+	case first.is("CONSTRAINT"), first.is("FOREIGN"), first.is("CHECK"):
+		return elem, nil
+	default:
+		return translateMySQLColumn(elem)
+	}
+}
+
+// translateMySQLIndex drops the index options, like USING BTREE, and the
+// prefix lengths of columns, like the 10 in (name(10)), from an index
+// definition.
+func translateMySQLIndex(toks []dumpToken) []dumpToken {
+	var out []dumpToken
+	depth := 0
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch {
+		case t.is("USING") && depth == 0:
+			i++
+			continue
+		case t.is("("):
+			if depth > 0 && i+2 < len(toks) && toks[i+1].kind == dumpNumber && toks[i+2].is(")") {
+				i += 2
+				continue
+			}
+			depth++
+		case t.is(")"):
+			depth--
+			if depth == 0 {
+				return append(out, t)
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func translateMySQLColumn(elem []dumpToken) ([]dumpToken, error) {
+	if len(elem) < 2 || elem[1].kind != dumpWord {
+		return nil, errors.New("expected column definition")
+	}
+	out := []dumpToken{elem[0]}
+	i := 2
+	// Find the arguments of the type, if any.
+	argsEnd := i
+	if i < len(elem) && elem[i].is("(") {
+		for argsEnd < len(elem) && !elem[argsEnd].is(")") {
+			argsEnd++
+		}
+		argsEnd++
+	}
+	if typ, ok := mysqlColumnTypes[strings.ToLower(elem[1].s)]; ok {
+		out = append(out, dumpToken{kind: dumpWord, s: typ})
+	} else {
+		out = append(out, elem[1:argsEnd]...)
+	}
+
+	for i = argsEnd; i < len(elem); i++ {
+		t := elem[i]
+		switch {
+		case t.is("NOT"), t.is("NULL"):
+			out = append(out, t)
+		case t.is("PRIMARY"), t.is("UNIQUE"):
+			out = append(out, t)
+			if i+1 < len(elem) && elem[i+1].is("KEY") {
+				i++
+				if t.is("PRIMARY") {
+					out = append(out, elem[i])
+				}
+			}
+		case t.is("UNSIGNED"), t.is("SIGNED"), t.is("ZEROFILL"), t.is("AUTO_INCREMENT"):
+		case t.is("CHARACTER"):
+			// CHARACTER SET <charset>
+			i += 2
+		case t.is("CHARSET"), t.is("COLLATE"), t.is("COMMENT"):
+			i++
+		case t.is("DEFAULT"):
+			// Like those of PostgreSQL dumps, defaults are dropped.
+			i = skipMySQLValue(elem, i+1)
+		case t.is("ON") && i+1 < len(elem) && elem[i+1].is("UPDATE"):
+			i = skipMySQLValue(elem, i+2)
+		default:
+			return nil, errors.Errorf("column %s: unsupported option %q", elem[0].s, t.s)
+		}
+	}
+	return out, nil
+}
+
+// skipMySQLValue returns the index of the last token of the value starting at
+// index i, like -1, 'a' or CURRENT_TIMESTAMP(6).
+func skipMySQLValue(toks []dumpToken, i int) int {
+	if i < len(toks) && (toks[i].is("-") || toks[i].is("+")) {
+		i++
+	}
+	if i+1 < len(toks) && toks[i+1].is("(") {
+		for i < len(toks) && !toks[i].is(")") {
+			i++
+		}
+	}
+	return i
+}
+
+type dumpTokenKind int
+
+const (
+	// dumpWord is a keyword or an unquoted identifier.
+	dumpWord dumpTokenKind = iota
+	// dumpIdent is a quoted identifier.
+	dumpIdent
+	dumpString
+	dumpNumber
+	dumpPunct
+)
+
+type dumpToken struct {
+	kind dumpTokenKind
+	// s is the text of the token, with quotes and escapes removed from
+	// identifiers and strings.
+	s string
+}
+
+// is returns true if t is the given keyword or punctuation, ignoring case.
+func (t dumpToken) is(s string) bool {
+	return (t.kind == dumpWord || t.kind == dumpPunct) && strings.EqualFold(t.s, s)
+}
+
+// format writes t to buf as CockroachDB SQL.
+func (t dumpToken) format(buf *bytes.Buffer) {
+	switch t.kind {
+	case dumpIdent:
+		parser.Name(t.s).Format(buf, parser.FmtSimple)
+	case dumpString:
+		parser.NewDString(t.s).Format(buf, parser.FmtSimple)
+	default:
+		buf.WriteString(t.s)
+	}
+}
+
+// dumpScanner splits a dump file into statements of tokens. It knows enough
+// about the quoting, escaping and comment syntax of PostgreSQL or MySQL to
+// find the ends of statements, and to read the data of COPY statements.
+type dumpScanner struct {
+	r     *bufio.Reader
+	mysql bool
+	// nullMarker replaces the `\N` of NULLs in COPY data.
+	nullMarker string
+	// line is the current line number, for error messages.
+	line int
+}
+
+func (s *dumpScanner) readByte() (byte, error) {
+	c, err := s.r.ReadByte()
+	if err == nil && c == '\n' {
+		s.line++
+	}
+	return c, err
+}
+
+func (s *dumpScanner) unreadByte(c byte) {
+	if c == '\n' {
+		s.line--
+	}
+	_ = s.r.UnreadByte()
+}
+
+func (s *dumpScanner) peek(c byte) bool {
+	b, err := s.r.Peek(1)
+	return err == nil && b[0] == c
+}
+
+// scan returns the tokens of the next statement, or io.EOF if there are no
+// more statements.
+func (s *dumpScanner) scan() ([]dumpToken, error) {
+	var toks []dumpToken
+	for {
+		c, err := s.readByte()
+		if err == io.EOF {
+			if len(toks) > 0 {
+				return nil, errors.New("unterminated statement")
+			}
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		var tok dumpToken
+		switch {
+		case c == ';':
+			if len(toks) > 0 {
+				return toks, nil
+			}
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			continue
+		case c == '-' && s.peek('-'), c == '#' && s.mysql:
+			_, err = s.r.ReadString('\n')
+			s.line++
+			if err == io.EOF {
+				err = nil
+			}
+		case c == '/' && s.peek('*'):
+			// MySQL's executable comments, like /*!40101 SET ... */, are
+			// ignored along with the rest.
+			err = s.skipBlockComment()
+		case c == '\'':
+			tok.kind = dumpString
+			tok.s, err = s.scanQuoted('\'', s.mysql)
+		case c == '"' && s.mysql:
+			tok.kind = dumpString
+			tok.s, err = s.scanQuoted('"', true)
+		case c == '"', c == '`' && s.mysql:
+			tok.kind = dumpIdent
+			tok.s, err = s.scanQuoted(c, false)
+		case c == '$' && !s.mysql:
+			tok.kind = dumpString
+			tok.s, err = s.scanDollarQuoted()
+		case isDumpDigit(c) || (c == '.' && s.peekDigit()):
+			tok.kind = dumpNumber
+			tok.s, err = s.scanNumber(c)
+		case isDumpIdentStart(c):
+			tok.kind = dumpWord
+			tok.s, err = s.scanWord(c)
+			if err == nil && !s.mysql && (tok.s == "E" || tok.s == "e") && s.peek('\'') {
+				// E'' strings have backslash escapes.
+				_, _ = s.readByte()
+				tok.kind = dumpString
+				tok.s, err = s.scanQuoted('\'', true)
+			}
+		default:
+			tok.kind = dumpPunct
+			tok.s = string(c)
+			if next, err := s.r.Peek(1); err == nil {
+				switch op := tok.s + string(next[0]); op {
+				case "::", "<=", ">=", "<>", "!=", "||":
+					_, _ = s.readByte()
+					tok.s = op
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tok.s != "" || tok.kind == dumpString || tok.kind == dumpIdent {
+			toks = append(toks, tok)
+		}
+	}
+}
+
+func (s *dumpScanner) peekDigit() bool {
+	b, err := s.r.Peek(1)
+	return err == nil && isDumpDigit(b[0])
+}
+
+func (s *dumpScanner) skipBlockComment() error {
+	if _, err := s.readByte(); err != nil {
+		return err
+	}
+	var prev byte
+	for {
+		c, err := s.readByte()
+		if err == io.EOF {
+			return errors.New("unterminated comment")
+		} else if err != nil {
+			return err
+		}
+		if prev == '*' && c == '/' {
+			return nil
+		}
+		prev = c
+	}
+}
+
+// scanQuoted returns the contents of a string or identifier after its opening
+// quote. A doubled quote stands for the quote itself and, if backslashes is
+// true, backslash escapes are decoded.
+func (s *dumpScanner) scanQuoted(quote byte, backslashes bool) (string, error) {
+	var buf []byte
+	for {
+		c, err := s.readByte()
+		if err == io.EOF {
+			return "", errors.New("unterminated quoted string")
+		} else if err != nil {
+			return "", err
+		}
+		switch {
+		case c == quote:
+			if !s.peek(quote) {
+				if backslashes {
+					return unescapeDump(string(buf), s.mysql), nil
+				}
+				return string(buf), nil
+			}
+			_, _ = s.readByte()
+			if backslashes {
+				buf = append(buf, '\\')
+			}
+			buf = append(buf, quote)
+		case c == '\\' && backslashes:
+			// Keep the escape for unescapeDump, but make sure an escaped
+			// quote does not end the string.
+			next, err := s.readByte()
+			if err != nil {
+				return "", errors.New("unterminated quoted string")
+			}
+			buf = append(buf, c, next)
+		default:
+			buf = append(buf, c)
+		}
+	}
+}
+
+// scanDollarQuoted returns the contents of a dollar-quoted string, like
+// $$...$$ or $body$...$body$, after its opening $.
+func (s *dumpScanner) scanDollarQuoted() (string, error) {
+	tag := []byte{'$'}
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return "", errors.New("unterminated dollar-quoted string")
+		}
+		tag = append(tag, c)
+		if c == '$' {
+			break
+		}
+		if !isDumpIdentStart(c) && !isDumpDigit(c) {
+			return "", errors.Errorf("unexpected character %q after $", c)
+		}
+	}
+	var buf []byte
+	for !bytes.HasSuffix(buf, tag) {
+		c, err := s.readByte()
+		if err != nil {
+			return "", errors.New("unterminated dollar-quoted string")
+		}
+		buf = append(buf, c)
+	}
+	return string(buf[:len(buf)-len(tag)]), nil
+}
+
+func (s *dumpScanner) scanNumber(first byte) (string, error) {
+	buf := []byte{first}
+	for {
+		c, err := s.readByte()
+		if err == io.EOF {
+			return string(buf), nil
+		} else if err != nil {
+			return "", err
+		}
+		last := buf[len(buf)-1]
+		if isDumpDigit(c) || c == '.' || isDumpLetter(c) ||
+			((c == '+' || c == '-') && (last == 'e' || last == 'E') && buf[0] != '0') {
+			buf = append(buf, c)
+			continue
+		}
+		s.unreadByte(c)
+		return string(buf), nil
+	}
+}
+
+func (s *dumpScanner) scanWord(first byte) (string, error) {
+	buf := []byte{first}
+	for {
+		c, err := s.readByte()
+		if err == io.EOF {
+			return string(buf), nil
+		} else if err != nil {
+			return "", err
+		}
+		if isDumpIdentStart(c) || isDumpDigit(c) || c == '$' {
+			buf = append(buf, c)
+			continue
+		}
+		s.unreadByte(c)
+		return string(buf), nil
+	}
+}
+
+// scanCopyData calls fn with the fields of each row of the text format data
+// following a COPY FROM stdin statement, up to the terminating `\.` line.
+func (s *dumpScanner) scanCopyData(fn func([]string) error) error {
+	// The data starts on the line after the statement.
+	if _, err := s.readLine(); err != nil {
+		return err
+	}
+	for {
+		line, err := s.readLine()
+		if err == io.EOF {
+			return errors.New(`unterminated COPY data, expected \.`)
+		} else if err != nil {
+			return err
+		}
+		if line == `\.` {
+			return nil
+		}
+		fields := strings.Split(line, "\t")
+		for i, f := range fields {
+			if f == `\N` {
+				fields[i] = s.nullMarker
+				continue
+			}
+			if fields[i] = unescapeDump(f, false /* mysql */); fields[i] == s.nullMarker {
+				return errors.Errorf("line %d: value %q conflicts with the NULL marker", s.line, f)
+			}
+		}
+		if err := fn(fields); err != nil {
+			return errors.Wrapf(err, "line %d", s.line)
+		}
+	}
+}
+
+func (s *dumpScanner) readLine() (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s.line++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// unescapeDump decodes the backslash escapes in s, which are those of
+// PostgreSQL's E'...' strings and COPY data or, if mysql is true, those of MySQL
+// strings.
+func unescapeDump(s string, mysql bool) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			buf = append(buf, c)
+			continue
+		}
+		i++
+		c = s[i]
+		switch {
+		case c == 'b':
+			buf = append(buf, '\b')
+		case c == 'f':
+			buf = append(buf, '\f')
+		case c == 'n':
+			buf = append(buf, '\n')
+		case c == 'r':
+			buf = append(buf, '\r')
+		case c == 't':
+			buf = append(buf, '\t')
+		case c == 'v' && !mysql:
+			buf = append(buf, '\v')
+		case c == '0' && mysql:
+			buf = append(buf, 0)
+		case c == 'Z' && mysql:
+			buf = append(buf, 26)
+		case c >= '0' && c <= '7' && !mysql:
+			// Up to three octal digits.
+			j := i + 1
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(s[i:j], 8, 8)
+			buf = append(buf, byte(v))
+			i = j - 1
+		case c == 'x' && !mysql && i+1 < len(s) && isDumpHexDigit(s[i+1]):
+			// Up to two hex digits.
+			j := i + 2
+			if j < len(s) && isDumpHexDigit(s[j]) {
+				j++
+			}
+			v, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			buf = append(buf, byte(v))
+			i = j - 1
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf)
+}
+
+func isDumpDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isDumpHexDigit(c byte) bool {
+	return isDumpDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isDumpLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDumpIdentStart(c byte) bool {
+	return isDumpLetter(c) || c == '_' || c >= 0x80
+}
@@ -0,0 +1,500 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/testcluster"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+const testPGDump = `
+--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SET client_encoding = 'UTF8';
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE FUNCTION public.noop() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$ BEGIN RETURN NEW; END; $$;
+
+CREATE TABLE public.customers (
+    id integer NOT NULL,
+    name character varying(255),
+    created timestamp without time zone DEFAULT now()
+);
+
+ALTER TABLE public.customers OWNER TO postgres;
+
+CREATE SEQUENCE public.customers_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER TABLE ONLY public.customers ALTER COLUMN id SET DEFAULT nextval('public.customers_id_seq'::regclass);
+
+CREATE TABLE public.orders (
+    id integer NOT NULL,
+    customer integer,
+    note text
+);
+
+COPY public.customers (id, name, created) FROM stdin;
+1	Alice	2017-01-01 00:00:00
+2	Bob\tby tab	\N
+3	Carol;	2017-01-03 00:00:00
+\.
+
+COPY public.orders (note, id, customer) FROM stdin;
+first	1	1
+line\nbreak	2	1
+\N	3	2
+\.
+
+SELECT pg_catalog.setval('public.customers_id_seq', 3, true);
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (id);
+
+CREATE INDEX customers_name_idx ON public.customers USING btree (name);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_customer_fkey FOREIGN KEY (customer) REFERENCES public.customers(id);
+`
+
+const testMySQLDump = "" +
+	"-- MySQL dump 10.13\n" +
+	"/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n" +
+	"DROP TABLE IF EXISTS `orders`;\n" +
+	"CREATE TABLE `orders` (\n" +
+	"  `id` int(11) NOT NULL AUTO_INCREMENT,\n" +
+	"  `customer` int(11) DEFAULT NULL,\n" +
+	"  `note` text CHARACTER SET utf8 COLLATE utf8_bin,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `customer` (`customer`),\n" +
+	"  CONSTRAINT `orders_ibfk_1` FOREIGN KEY (`customer`) REFERENCES `customers` (`id`)\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=4 DEFAULT CHARSET=latin1;\n" +
+	"LOCK TABLES `orders` WRITE;\n" +
+	"/*!40000 ALTER TABLE `orders` DISABLE KEYS */;\n" +
+	"INSERT INTO `orders` VALUES (1,1,'first'),(2,1,'line\\nbreak'),(3,2,NULL);\n" +
+	"UNLOCK TABLES;\n" +
+	"# customers\n" +
+	"DROP TABLE IF EXISTS `customers`;\n" +
+	"CREATE TABLE `customers` (\n" +
+	"  `id` int(10) unsigned NOT NULL,\n" +
+	"  `name` varchar(255) COLLATE utf8_bin DEFAULT '',\n" +
+	"  `created` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+	"  PRIMARY KEY (`id`) USING BTREE,\n" +
+	"  UNIQUE KEY `name` (`name`(10)),\n" +
+	"  FULLTEXT KEY `name_text` (`name`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='people';\n" +
+	"INSERT INTO `customers` VALUES (1,'Alice','2017-01-01 00:00:00'),(2,'Bob\\'s; \"x\"',NULL),(3,_binary 'Carol','2017-01-03 00:00:00');\n"
+
+func TestDumpReader(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	type table struct {
+		columns []string
+		indexes []string
+		refs    map[string]string
+		// rows maps the dump format to the expected CSV file.
+		rows map[string]string
+	}
+	expected := map[string]table{
+		"customers": {
+			columns: []string{"id", "name", "created"},
+			indexes: []string{"customers_name_idx"},
+			rows: map[string]string{
+				importFormatPGDump:    "1,Alice,2017-01-01 00:00:00\n2,Bob\tby tab,\\N\n3,Carol;,2017-01-03 00:00:00\n",
+				importFormatMySQLDump: "1,Alice,2017-01-01 00:00:00\n2,\"Bob's; \"\"x\"\"\",\\N\n3,Carol,2017-01-03 00:00:00\n",
+			},
+		},
+		"orders": {
+			columns: []string{"id", "customer", "note"},
+			indexes: []string{"orders_auto_index_orders_customer_fkey"},
+			refs:    map[string]string{"orders_customer_fkey": "customers"},
+			rows: map[string]string{
+				importFormatPGDump:    "1,1,first\n2,1,\"line\nbreak\"\n3,2,\\N\n",
+				importFormatMySQLDump: "1,1,first\n2,1,\"line\nbreak\"\n3,2,\\N\n",
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		format string
+		dump   string
+	}{
+		{importFormatPGDump, testPGDump},
+		{importFormatMySQLDump, testMySQLDump},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			path := filepath.Join(dir, tc.format)
+			if err := ioutil.WriteFile(path, []byte(tc.dump), 0666); err != nil {
+				t.Fatal(err)
+			}
+			es := makeDumpTestStorage(t, dir)
+			defer es.Close()
+			d := newDumpReader(tc.format, es)
+			defer d.close(context.TODO())
+			if err := d.readFile(context.TODO(), fmt.Sprintf("nodelocal://%s", path)); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.flush(context.TODO()); err != nil {
+				t.Fatal(err)
+			}
+			tables, err := d.makeTableDescs(context.TODO())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tables) != 2 || tables[0].name != "customers" || tables[1].name != "orders" {
+				t.Fatalf("expected customers to be created before orders, got %v", tables)
+			}
+			byID := make(map[sqlbase.ID]string)
+			for _, table := range tables {
+				byID[table.desc.ID] = table.name
+			}
+
+			for _, table := range tables {
+				exp := expected[table.name]
+				desc := table.desc
+
+				var columns []string
+				for _, col := range desc.VisibleColumns() {
+					columns = append(columns, col.Name)
+				}
+				if !reflect.DeepEqual(columns, exp.columns) {
+					t.Errorf("%s: expected columns %v, got %v", table.name, exp.columns, columns)
+				}
+				if pk := desc.PrimaryIndex.ColumnNames; !reflect.DeepEqual(pk, []string{"id"}) {
+					t.Errorf("%s: expected primary key on id, got %v", table.name, pk)
+				}
+
+				refs := make(map[string]string)
+				for _, idx := range desc.Indexes {
+					if idx.ForeignKey.IsSet() {
+						refs[idx.ForeignKey.Name] = byID[idx.ForeignKey.Table]
+					}
+				}
+				if tc.format == importFormatPGDump {
+					// MySQL names indexes and constraints differently.
+					var indexes []string
+					for _, idx := range desc.Indexes {
+						indexes = append(indexes, idx.Name)
+					}
+					if !reflect.DeepEqual(indexes, exp.indexes) {
+						t.Errorf("%s: expected indexes %v, got %v", table.name, exp.indexes, indexes)
+					}
+				} else if len(desc.Indexes) != 1 {
+					t.Errorf("%s: expected 1 index, got %d", table.name, len(desc.Indexes))
+				}
+				if len(refs) != len(exp.refs) {
+					t.Errorf("%s: expected references %v, got %v", table.name, exp.refs, refs)
+				}
+				for _, ref := range refs {
+					if ref != "customers" {
+						t.Errorf("%s: expected reference to customers, got %v", table.name, refs)
+					}
+				}
+
+				rows := readDumpRows(t, es, table)
+				expRows := strings.Replace(exp.rows[tc.format], `\N`, d.nullMarker, -1)
+				if string(rows) != expRows {
+					t.Errorf("%s: expected rows %q, got %q", table.name, expRows, rows)
+				}
+			}
+		})
+	}
+}
+
+func makeDumpTestStorage(t *testing.T, dir string) storageccl.ExportStorage {
+	es, err := exportStorageFromURI(context.TODO(), fmt.Sprintf("nodelocal://%s", dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+// readDumpRows returns the concatenated contents of the CSV files of a table.
+func readDumpRows(t *testing.T, es storageccl.ExportStorage, table *dumpTable) []byte {
+	var rows []byte
+	for _, part := range table.parts {
+		r, err := es.ReadFile(context.TODO(), part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, b...)
+	}
+	return rows
+}
+
+// TestDumpReaderParts verifies that the rows of a table are split into CSV
+// files whenever rows of another table are read, and that the files are
+// removed when the reader is closed.
+func TestDumpReaderParts(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "dump")
+	dump := "CREATE TABLE a (x INT);\nCREATE TABLE b (x INT);\n" +
+		"INSERT INTO a VALUES (1);\nINSERT INTO a VALUES (2);\n" +
+		"INSERT INTO b VALUES (3);\nINSERT INTO a VALUES (4);\n"
+	if err := ioutil.WriteFile(path, []byte(dump), 0666); err != nil {
+		t.Fatal(err)
+	}
+	es := makeDumpTestStorage(t, filepath.Join(dir, "temp"))
+	defer es.Close()
+	d := newDumpReader(importFormatPGDump, es)
+	if err := d.readFile(context.TODO(), fmt.Sprintf("nodelocal://%s", path)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.flush(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	a, b := d.tables["a"], d.tables["b"]
+	if expected := []string{"dump-0-0.csv", "dump-0-1.csv"}; !reflect.DeepEqual(a.parts, expected) {
+		t.Fatalf("expected parts %v, got %v", expected, a.parts)
+	}
+	if rows := string(readDumpRows(t, es, a)); rows != "1\n2\n4\n" {
+		t.Fatalf("unexpected rows %q", rows)
+	}
+	if rows := string(readDumpRows(t, es, b)); rows != "3\n" {
+		t.Fatalf("unexpected rows %q", rows)
+	}
+
+	d.close(context.TODO())
+	if files, err := ioutil.ReadDir(filepath.Join(dir, "temp")); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 0 {
+		t.Fatalf("expected CSV files to be removed, found %d", len(files))
+	}
+}
+
+func TestDumpReaderNulls(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	// A string that is exactly `\N` must not be confused with NULL.
+	for _, tc := range []struct {
+		format string
+		dump   string
+	}{
+		{importFormatPGDump, "CREATE TABLE t (a INT, b TEXT);\nCOPY t (a, b) FROM stdin;\n1\t\\\\N\n2\t\\N\n\\.\n"},
+		{importFormatMySQLDump, "CREATE TABLE `t` (`a` int(11), `b` text);\nINSERT INTO `t` VALUES (1,'\\\\N'),(2,NULL);\n"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			path := filepath.Join(dir, tc.format)
+			if err := ioutil.WriteFile(path, []byte(tc.dump), 0666); err != nil {
+				t.Fatal(err)
+			}
+			es := makeDumpTestStorage(t, dir)
+			defer es.Close()
+			d := newDumpReader(tc.format, es)
+			defer d.close(context.TODO())
+			if err := d.readFile(context.TODO(), fmt.Sprintf("nodelocal://%s", path)); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.flush(context.TODO()); err != nil {
+				t.Fatal(err)
+			}
+			tables, err := d.makeTableDescs(context.TODO())
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, err := csv.NewReader(bytes.NewReader(readDumpRows(t, es, tables[0]))).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := [][]string{{"1", `\N`}, {"2", d.nullMarker}}
+			if !reflect.DeepEqual(records, expected) {
+				t.Fatalf("expected %q, got %q", expected, records)
+			}
+		})
+	}
+}
+
+func TestDumpReaderErrors(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	for i, tc := range []struct {
+		format string
+		dump   string
+		err    string
+	}{
+		{importFormatPGDump, `SET x = 1;`, "no tables defined"},
+		{importFormatPGDump, `CREATE TABLE t (a INT)`, "unterminated statement"},
+		{importFormatPGDump, "CREATE TABLE t (a INT);\nCOPY t (a) FROM stdin;\n1\n", "unterminated COPY data"},
+		{importFormatPGDump, "CREATE TABLE t (a INT, b INT);\nCOPY t (a) FROM stdin;\n1\n\\.\n", "expected values for all 2 columns"},
+		{importFormatPGDump, "CREATE TABLE t (a INT);\nINSERT INTO u VALUES (1);", `table "u" is not defined`},
+		{importFormatPGDump, "CREATE TABLE t (a INT);\nCREATE TABLE t (a INT);", `table "t" is defined more than once`},
+		{importFormatPGDump, "CREATE TABLE t (a INT REFERENCES u (a));", `referenced table "u" not found`},
+		{importFormatPGDump,
+			"CREATE TABLE a (x INT PRIMARY KEY, y INT REFERENCES b (x));\nCREATE TABLE b (x INT PRIMARY KEY, y INT REFERENCES a (x));",
+			"cyclic foreign key references"},
+		{importFormatPGDump, "CREATE TABLE t (a INT);\nINSERT INTO t VALUES (now());", "unsupported value"},
+		{importFormatMySQLDump, "CREATE TABLE `t` (`a` int(11) GENERATED ALWAYS AS (1));", "unsupported option"},
+		{importFormatMySQLDump, "CREATE TABLE `t` (`a` int(11)", "unterminated"},
+		{importFormatMySQLDump, "CREATE TABLE `t` (`a` text);\nINSERT INTO `t` VALUES ('a);", "unterminated quoted string"},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("%d", i))
+			if err := ioutil.WriteFile(path, []byte(tc.dump), 0666); err != nil {
+				t.Fatal(err)
+			}
+			es := makeDumpTestStorage(t, dir)
+			defer es.Close()
+			d := newDumpReader(tc.format, es)
+			defer d.close(context.TODO())
+			err := d.readFile(context.TODO(), fmt.Sprintf("nodelocal://%s", path))
+			if err == nil {
+				_, err = d.makeTableDescs(context.TODO())
+			}
+			if !testutils.IsError(err, tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestUnescapeDump(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	for _, tc := range []struct {
+		in       string
+		mysql    bool
+		expected string
+	}{
+		{`plain`, false, `plain`},
+		{`a\tb\nc\\d`, false, "a\tb\nc\\d"},
+		{`\101\x42\x4`, false, "AB\x04"},
+		{`it\'s`, false, `it's`},
+		{`\0\Z`, true, "\x00\x1a"},
+		{`\101`, true, `101`},
+		{`trailing\`, false, `trailing\`},
+	} {
+		if actual := unescapeDump(tc.in, tc.mysql); actual != tc.expected {
+			t.Errorf("%q (mysql: %t): expected %q, got %q", tc.in, tc.mysql, tc.expected, actual)
+		}
+	}
+}
+
+func TestImportDumpStmt(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const nodes = 3
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, nodes, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+	sqlDB := sqlutils.MakeSQLRunner(t, tc.Conns[0])
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	for i, test := range []struct {
+		name   string
+		format string
+		dump   string
+		opts   string
+		err    string
+	}{
+		{"pgdump", importFormatPGDump, testPGDump, "", ""},
+		{"pgdump-dist", importFormatPGDump, testPGDump, ", distributed", ""},
+		{"pgdump-encrypted", importFormatPGDump, testPGDump, ", encryption_passphrase = 'abcdefg'", ""},
+		{"mysqldump", importFormatMySQLDump, testMySQLDump, "", ""},
+		{"mysqldump-dist", importFormatMySQLDump, testMySQLDump, ", distributed", ""},
+		{"csv-option", importFormatPGDump, testPGDump, ", nullif = ''", `option "nullif" is not supported with PGDUMP`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dumpPath := filepath.Join(dir, test.name)
+			if err := ioutil.WriteFile(dumpPath, []byte(test.dump), 0666); err != nil {
+				t.Fatal(err)
+			}
+			sqlDB.Exec(fmt.Sprintf(`CREATE DATABASE dump%d`, i))
+			sqlDB.Exec(fmt.Sprintf(`SET DATABASE = dump%d`, i))
+
+			var unused string
+			var restored struct {
+				rows, idx, sys, bytes int
+			}
+			if err := sqlDB.DB.QueryRow(
+				fmt.Sprintf(`IMPORT %s DATA ($1) WITH temp = $2%s`, test.format, test.opts),
+				fmt.Sprintf("nodelocal://%s", dumpPath),
+				fmt.Sprintf("nodelocal://%s", filepath.Join(dir, test.name+"-temp")),
+			).Scan(
+				&unused, &unused, &unused, &restored.rows, &restored.idx, &restored.sys, &restored.bytes,
+			); err != nil {
+				if !testutils.IsError(err, test.err) {
+					t.Fatal(err)
+				}
+				return
+			} else if test.err != "" {
+				t.Fatalf("expected error %q", test.err)
+			}
+
+			if expected := 6; restored.rows != expected {
+				t.Fatalf("expected %d rows, got %d", expected, restored.rows)
+			}
+
+			var nulls int
+			sqlDB.QueryRow(`SELECT count(*) FROM orders WHERE note IS NULL`).Scan(&nulls)
+			if nulls != 1 {
+				t.Fatalf("expected 1 NULL note, got %d", nulls)
+			}
+			var note string
+			sqlDB.QueryRow(`SELECT note FROM orders WHERE id = 2`).Scan(&note)
+			if note != "line\nbreak" {
+				t.Fatalf("expected escaped newline to be decoded, got %q", note)
+			}
+
+			// The foreign key between the tables is enforced.
+			if _, err := sqlDB.DB.Exec(`INSERT INTO orders VALUES (4, 100, 'x')`); !testutils.IsError(
+				err, "foreign key violation",
+			) {
+				t.Fatalf("expected foreign key violation, got %v", err)
+			}
+		})
+	}
+}
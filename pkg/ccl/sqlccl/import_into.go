@@ -0,0 +1,535 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bytes"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/pkg/errors"
+)
+
+// revertBatchSize is the number of keys read, and rewritten, at a time when
+// reverting the data of a failed IMPORT INTO.
+const revertBatchSize = 10000
+
+// importInto imports the CSV files into an existing table. The table is taken
+// offline, and its new rows are converted to SSTs in temp and then restored
+// into it by an IMPORT job. If the job fails, the table's data is reverted to
+// its state from before the import.
+// Either way, the table is back online when the job finishes, unless
+// reverting it fails.
+//
+// Before the table is brought back online, the imported rows are checked
+// against the existing ones: the import fails, and is reverted, if any of them
+// has the primary key or a unique secondary index value of an existing row,
+// or if its foreign keys reference a row that does not exist.
+func importInto(
+	ctx context.Context,
+	p sql.PlanHookState,
+	importStmt *parser.Import,
+	files []string,
+	opts map[string]string,
+	encryption *roachpb.FileEncryptionOptions,
+	resultsCh chan<- parser.Datums,
+) error {
+	db := p.ExecCfg().DB
+
+	tableDesc, err := resolveImportIntoTable(ctx, p, importStmt.Table)
+	if err != nil {
+		return err
+	}
+	description, err := importJobDescription(importStmt, files)
+	if err != nil {
+		return err
+	}
+
+	// Take the table offline and wait for the leases on its previous version
+	// to expire, after which nothing else reads or writes its data.
+	if _, err := p.ExecCfg().LeaseManager.Publish(ctx, tableDesc.ID, func(desc *sqlbase.TableDescriptor) error {
+		if err := checkImportIntoTable(desc); err != nil {
+			return err
+		}
+		desc.State = sqlbase.TableDescriptor_OFFLINE
+		return nil
+	}, nil /* logEvent */); err != nil {
+		return err
+	}
+	// Until the IMPORT job starts, a failure only needs to bring the table back
+	// online.
+	if _, err := p.ExecCfg().LeaseManager.WaitForOneVersion(
+		ctx, tableDesc.ID, base.DefaultRetryOptions(),
+	); err != nil {
+		if onlineErr := makeImportedTablesOnline(ctx, db, []sqlbase.ID{tableDesc.ID}); onlineErr != nil {
+			log.Errorf(ctx, "could not bring table %d back online: %s", tableDesc.ID, onlineErr)
+		}
+		return err
+	}
+
+	// The imported keys are written just after revertTime, so that reverting
+	// the table to its state as of revertTime removes all of them.
+	revertTime := p.ExecCfg().Clock.Now()
+	walltime := revertTime.WallTime + 1
+
+	// The rows are converted with a copy of the table's descriptor, using the
+	// IDs that IMPORT uses for new tables so that the SSTs can be restored
+	// into it like those of a new table. Its foreign key references are
+	// dropped since the tables they point to are not part of the import; they
+	// are instead checked by validateImportedTables once the rows are in.
+	importDesc := protoutil.Clone(tableDesc).(*sqlbase.TableDescriptor)
+	importDesc.ID = defaultCSVTableID
+	importDesc.ParentID = defaultCSVParentID
+	importDesc.PrimaryIndex.ForeignKey = sqlbase.ForeignKeyReference{}
+	importDesc.PrimaryIndex.ReferencedBy = nil
+	for i := range importDesc.Indexes {
+		importDesc.Indexes[i].ForeignKey = sqlbase.ForeignKeyReference{}
+		importDesc.Indexes[i].ReferencedBy = nil
+	}
+
+	tableRewrites := tableRewriteMap{
+		defaultCSVTableID: &jobs.RestoreDetails_TableRewrite{
+			TableID:  tableDesc.ID,
+			ParentID: tableDesc.ParentID,
+		},
+	}
+	// The IMPORT job reverts the table itself if it fails.
+	return runImportJob(
+		ctx, p, description, []sqlbase.ID{tableDesc.ID}, files, opts, importDesc, walltime,
+		jobs.RestoreDetails{
+			TableRewrites: tableRewrites,
+			Encryption:    encryption,
+			RevertTime:    revertTime,
+		},
+		resultsCh,
+	)
+}
+
+// resolveImportIntoTable returns the descriptor of the table named by an
+// IMPORT INTO statement, checking that it can be imported into.
+func resolveImportIntoTable(
+	ctx context.Context, p sql.PlanHookState, name parser.UnresolvedName,
+) (*sqlbase.TableDescriptor, error) {
+	var matched []sqlbase.Descriptor
+	if err := p.ExecCfg().DB.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		descs, err := allSQLDescriptors(ctx, txn)
+		if err != nil {
+			return err
+		}
+		matched, err = descriptorsMatchingTargets(
+			p.EvalContext().Database, descs, parser.TargetList{Tables: parser.TablePatterns{name}},
+		)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	for _, desc := range matched {
+		if tableDesc := desc.GetTable(); tableDesc != nil {
+			if tableDesc.IsView() {
+				return nil, errors.Errorf("cannot import into view %q", tableDesc.Name)
+			}
+			if tableDesc.IsInterleaved() {
+				// The interleaved rows of other tables are not covered by the
+				// import's spans, and so would not be reverted if it failed.
+				return nil, errors.Errorf("cannot import into interleaved table %q", tableDesc.Name)
+			}
+			if err := checkImportIntoTable(tableDesc); err != nil {
+				return nil, err
+			}
+			return tableDesc, nil
+		}
+	}
+	return nil, errors.Errorf("table %q does not exist", name)
+}
+
+// checkImportIntoTable returns an error if the table is not public or has a
+// schema change in progress.
+func checkImportIntoTable(desc *sqlbase.TableDescriptor) error {
+	if desc.State != sqlbase.TableDescriptor_PUBLIC {
+		return errors.Errorf("cannot import into table %q in state %s", desc.Name, desc.State)
+	}
+	if len(desc.Mutations) > 0 {
+		return errors.Errorf("cannot import into table %q while a schema change is in progress", desc.Name)
+	}
+	return nil
+}
+
+// importJobDescription returns the statement of an IMPORT as recorded in its
+// job, with any credentials in the URIs of its files, and its encryption
+// passphrase, redacted.
+func importJobDescription(importStmt *parser.Import, files []string) (string, error) {
+	i := *importStmt
+	i.Files = make(parser.Exprs, len(files))
+	for j, f := range files {
+		sf, err := storageccl.SanitizeExportStorageURI(f)
+		if err != nil {
+			return "", err
+		}
+		i.Files[j] = parser.NewDString(sf)
+	}
+	i.Options = redactEncryptionPassphrase(i.Options)
+	return i.String(), nil
+}
+
+// makeImportedTablesOnline makes the offline tables being imported into public
+// again. Since no leases are granted on an offline table, the descriptors are
+// written directly instead of waiting for leases on the previous version.
+func makeImportedTablesOnline(ctx context.Context, db *client.DB, ids []sqlbase.ID) error {
+	return db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		if err := txn.SetSystemConfigTrigger(); err != nil {
+			return err
+		}
+		b := txn.NewBatch()
+		for _, id := range ids {
+			descKey := sqlbase.MakeDescMetadataKey(id)
+			desc := &sqlbase.Descriptor{}
+			if err := txn.GetProto(ctx, descKey, desc); err != nil {
+				return err
+			}
+			tableDesc := desc.GetTable()
+			if tableDesc == nil {
+				return errors.Errorf("ID %d is not a table", id)
+			}
+			if !tableDesc.Offline() {
+				return errors.Errorf("table %q is not offline", tableDesc.Name)
+			}
+			tableDesc.State = sqlbase.TableDescriptor_PUBLIC
+			tableDesc.Version++
+			tableDesc.ModificationTime = txn.OrigTimestamp()
+			b.Put(descKey, desc)
+		}
+		return txn.Run(ctx, b)
+	})
+}
+
+// validateImportedTables checks the rows imported into the offline tables
+// since revertTime against their existing rows and constraints: an imported
+// row must not have the primary key, or the value of a unique secondary index,
+// of a row that existed as of revertTime, and the values of its foreign keys
+// must be found in the tables they reference. Duplicates among the imported
+// rows themselves are rejected when their SSTs are built.
+func validateImportedTables(
+	ctx context.Context, db *client.DB, ids []sqlbase.ID, revertTime hlc.Timestamp,
+) error {
+	for _, id := range ids {
+		var tableDesc *sqlbase.TableDescriptor
+		if err := db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+			var err error
+			tableDesc, err = sqlbase.GetTableDescFromID(ctx, txn, id)
+			return err
+		}); err != nil {
+			return err
+		}
+		indexes := append([]sqlbase.IndexDescriptor{tableDesc.PrimaryIndex}, tableDesc.Indexes...)
+		for i := range indexes {
+			if err := validateImportedIndex(ctx, db, tableDesc, &indexes[i], revertTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateImportedIndex checks the entries imported into an index since
+// revertTime: if the index is unique, none of them may have existed as of
+// revertTime, and if it has a foreign key, the values of its referencing
+// columns must be found in the referenced index.
+func validateImportedIndex(
+	ctx context.Context,
+	db *client.DB,
+	tableDesc *sqlbase.TableDescriptor,
+	index *sqlbase.IndexDescriptor,
+	revertTime hlc.Timestamp,
+) error {
+	unique := index.ID == tableDesc.PrimaryIndex.ID || index.Unique
+	if !unique && !index.ForeignKey.IsSet() {
+		return nil
+	}
+
+	vals, err := sqlbase.MakeEncodedKeyVals(tableDesc, index.ColumnIDs)
+	if err != nil {
+		return err
+	}
+	dirs := make([]encoding.Direction, len(index.ColumnIDs))
+	for i, dir := range index.ColumnDirections {
+		if dirs[i], err = dir.ToEncodingDirection(); err != nil {
+			return err
+		}
+	}
+	var alloc sqlbase.DatumAlloc
+	prefix := sqlbase.MakeIndexKeyPrefix(tableDesc, index.ID)
+	decode := func(key roachpb.Key) (parser.Datums, error) {
+		if _, err := sqlbase.DecodeKeyVals(vals, dirs, key[len(prefix):]); err != nil {
+			return nil, err
+		}
+		datums := make(parser.Datums, len(vals))
+		for i := range vals {
+			if err := vals[i].EnsureDecoded(&alloc); err != nil {
+				return nil, err
+			}
+			datums[i] = vals[i].Datum
+		}
+		return datums, nil
+	}
+
+	var fk *foreignKeyChecker
+	if index.ForeignKey.IsSet() {
+		if fk, err = makeForeignKeyChecker(ctx, db, index); err != nil {
+			return err
+		}
+	}
+
+	return scanImportedIndex(ctx, db, tableDesc.IndexSpan(index.ID), revertTime,
+		func(kv client.KeyValue, existed bool) error {
+			if !existed && fk == nil {
+				return nil
+			}
+			datums, err := decode(kv.Key)
+			if err != nil {
+				return err
+			}
+			if existed && unique {
+				return sqlbase.NewUniquenessConstraintViolationError(index, datums)
+			}
+			if fk != nil {
+				return fk.check(ctx, db, datums)
+			}
+			return nil
+		})
+}
+
+// foreignKeyChecker checks that the values of the referencing columns of an
+// index are found in the index its foreign key references.
+type foreignKeyChecker struct {
+	searchTable *sqlbase.TableDescriptor
+	searchIdx   *sqlbase.IndexDescriptor
+	prefixLen   int
+	colMap      map[sqlbase.ColumnID]int
+	// lastKey is the last key found in searchIdx, which is not looked up again
+	// for the following index entries with the same values.
+	lastKey roachpb.Key
+}
+
+func makeForeignKeyChecker(
+	ctx context.Context, db *client.DB, index *sqlbase.IndexDescriptor,
+) (*foreignKeyChecker, error) {
+	var searchTable *sqlbase.TableDescriptor
+	if err := db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		var err error
+		searchTable, err = sqlbase.GetTableDescFromID(ctx, txn, index.ForeignKey.Table)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	searchIdx, err := searchTable.FindIndexByID(index.ForeignKey.Index)
+	if err != nil {
+		return nil, err
+	}
+	prefixLen := len(searchIdx.ColumnIDs)
+	if len(index.ColumnIDs) < prefixLen {
+		prefixLen = len(index.ColumnIDs)
+	}
+	// The referencing columns are the first prefixLen columns of the index, in
+	// the order of the referenced ones.
+	colMap := make(map[sqlbase.ColumnID]int, prefixLen)
+	for i, id := range searchIdx.ColumnIDs[:prefixLen] {
+		colMap[id] = i
+	}
+	return &foreignKeyChecker{
+		searchTable: searchTable,
+		searchIdx:   searchIdx,
+		prefixLen:   prefixLen,
+		colMap:      colMap,
+	}, nil
+}
+
+// check returns a foreign key violation error if the values of the
+// referencing columns, the first ones of row, are not found in the referenced
+// index. As for an INSERT, a row whose referencing columns are all NULL is not
+// checked.
+func (fk *foreignKeyChecker) check(ctx context.Context, db *client.DB, row parser.Datums) error {
+	fkValues := row[:fk.prefixLen]
+	nulls := true
+	for _, d := range fkValues {
+		nulls = nulls && d == parser.DNull
+	}
+	if nulls {
+		return nil
+	}
+	key, _, err := sqlbase.EncodePartialIndexKey(
+		fk.searchTable, fk.searchIdx, fk.prefixLen, fk.colMap, fkValues,
+		sqlbase.MakeIndexKeyPrefix(fk.searchTable, fk.searchIdx.ID),
+	)
+	if err != nil {
+		return err
+	}
+	if fk.lastKey.Equal(key) {
+		return nil
+	}
+	found, err := db.Scan(ctx, key, roachpb.Key(key).PrefixEnd(), 1)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return pgerror.NewErrorf(pgerror.CodeForeignKeyViolationError,
+			"foreign key violation: value %s not found in %s@%s %s",
+			fkValues, fk.searchTable.Name, fk.searchIdx.Name, fk.searchIdx.ColumnNames[:fk.prefixLen])
+	}
+	fk.lastKey = key
+	return nil
+}
+
+// scanImportedIndex calls fn with each key in span written after revertTime,
+// along with whether the key already existed as of revertTime.
+func scanImportedIndex(
+	ctx context.Context,
+	db *client.DB,
+	span roachpb.Span,
+	revertTime hlc.Timestamp,
+	fn func(kv client.KeyValue, existed bool) error,
+) error {
+	start, end := span.Key, span.EndKey
+	for {
+		current, err := db.Scan(ctx, start, end, revertBatchSize)
+		if err != nil {
+			return err
+		}
+		batchEnd := end
+		if len(current) == revertBatchSize {
+			batchEnd = current[len(current)-1].Key.Next()
+		}
+		var previous []client.KeyValue
+		if err := db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+			txn.SetFixedTimestamp(revertTime)
+			var err error
+			previous, err = txn.Scan(ctx, start, batchEnd, 0 /* maxRows */)
+			return err
+		}); err != nil {
+			return err
+		}
+
+		j := 0
+		for _, kv := range current {
+			for j < len(previous) && previous[j].Key.Compare(kv.Key) < 0 {
+				j++
+			}
+			if !revertTime.Less(kv.Value.Timestamp) {
+				continue
+			}
+			existed := j < len(previous) && previous[j].Key.Equal(kv.Key)
+			if err := fn(kv, existed); err != nil {
+				return err
+			}
+		}
+		if batchEnd.Equal(end) {
+			return nil
+		}
+		start = batchEnd
+	}
+}
+
+// revertImportInto reverts the data of the tables of a failed IMPORT INTO to
+// their state as of revertTime and brings them back online. It returns
+// importErr, annotated with any error encountered while reverting.
+func revertImportInto(
+	ctx context.Context,
+	db *client.DB,
+	tableRewrites tableRewriteMap,
+	revertTime hlc.Timestamp,
+	importErr error,
+) error {
+	var ids []sqlbase.ID
+	for _, rewrite := range tableRewrites {
+		ids = append(ids, rewrite.TableID)
+	}
+	for _, id := range ids {
+		if err := revertTableData(ctx, db, id, revertTime); err != nil {
+			return errors.Wrapf(importErr, "reverting table %d failed, leaving it offline: %s", id, err)
+		}
+	}
+	if err := makeImportedTablesOnline(ctx, db, ids); err != nil {
+		return errors.Wrapf(importErr, "bringing tables back online failed: %s", err)
+	}
+	return importErr
+}
+
+// revertTableData reverts the data of a table to its state as of ts, by
+// rewriting the keys that have changed since and deleting those added since.
+// The table must be offline, so that nothing else writes to it, and ts must
+// be within its GC TTL.
+func revertTableData(ctx context.Context, db *client.DB, id sqlbase.ID, ts hlc.Timestamp) error {
+	prefix := roachpb.Key(keys.MakeTablePrefix(uint32(id)))
+	start, end := prefix, prefix.PrefixEnd()
+	for {
+		current, err := db.Scan(ctx, start, end, revertBatchSize)
+		if err != nil {
+			return err
+		}
+		// Nothing is deleted by an import, so the keys that existed as of ts
+		// in the span covered by this batch are a subset of the current ones.
+		batchEnd := end
+		if len(current) == revertBatchSize {
+			batchEnd = current[len(current)-1].Key.Next()
+		}
+		var previous []client.KeyValue
+		if err := db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+			txn.SetFixedTimestamp(ts)
+			var err error
+			previous, err = txn.Scan(ctx, start, batchEnd, 0 /* maxRows */)
+			return err
+		}); err != nil {
+			return err
+		}
+
+		b := &client.Batch{}
+		reverted := 0
+		for i, j := 0, 0; i < len(current) || j < len(previous); {
+			switch {
+			case j == len(previous) || (i < len(current) && current[i].Key.Compare(previous[j].Key) < 0):
+				b.Del(current[i].Key)
+				reverted++
+				i++
+			case i == len(current) || current[i].Key.Compare(previous[j].Key) > 0:
+				b.Put(previous[j].Key, &roachpb.Value{RawBytes: previous[j].Value.RawBytes})
+				reverted++
+				j++
+			default:
+				if !bytes.Equal(current[i].Value.RawBytes, previous[j].Value.RawBytes) {
+					b.Put(previous[j].Key, &roachpb.Value{RawBytes: previous[j].Value.RawBytes})
+					reverted++
+				}
+				i++
+				j++
+			}
+		}
+		if reverted > 0 {
+			if err := db.Run(ctx, b); err != nil {
+				return err
+			}
+		}
+		if batchEnd.Equal(end) {
+			return nil
+		}
+		start = batchEnd
+	}
+}
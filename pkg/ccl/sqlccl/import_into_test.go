@@ -0,0 +1,203 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/testcluster"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestImportIntoStmt(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const (
+		nodes       = 3
+		existing    = 100
+		rowsPerFile = 200
+	)
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, nodes, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+	sqlDB := sqlutils.MakeSQLRunner(t, tc.Conns[0])
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	sqlDB.Exec(`CREATE DATABASE d`)
+	sqlDB.Exec(`CREATE TABLE d.t (a INT PRIMARY KEY, b STRING, INDEX (b))`)
+	sqlDB.Exec(`INSERT INTO d.t SELECT i, 'existing' FROM generate_series(0, $1) AS g(i)`, existing-1)
+
+	// writeFiles writes CSV files with rowsPerFile rows each, starting at key
+	// start, and returns the list of their URIs to use in an IMPORT.
+	writeFiles := func(t *testing.T, name string, start, num int) string {
+		var files []string
+		for fn := 0; fn < num; fn++ {
+			var buf bytes.Buffer
+			for i := 0; i < rowsPerFile; i++ {
+				fmt.Fprintf(&buf, "%d,%s\n", start+fn*rowsPerFile+i, name)
+			}
+			path := filepath.Join(dir, fmt.Sprintf("%s-%d", name, fn))
+			if err := ioutil.WriteFile(path, []byte(buf.String()), 0666); err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, fmt.Sprintf(`'nodelocal://%s'`, path))
+		}
+		return strings.Join(files, ", ")
+	}
+
+	checkCount := func(t *testing.T, b string, expected int) {
+		var count int
+		sqlDB.QueryRow(`SELECT count(*) FROM d.t@t_b_idx WHERE b = $1`, b).Scan(&count)
+		if count != expected {
+			t.Fatalf("expected %d rows with b = %q, got %d", expected, b, count)
+		}
+	}
+
+	start := existing
+	for _, distributed := range []bool{false, true} {
+		name := "local"
+		opts := ""
+		if distributed {
+			name = "distributed"
+			opts = ", distributed"
+		}
+		t.Run(name, func(t *testing.T) {
+			files := writeFiles(t, name, start, nodes)
+			temp := fmt.Sprintf("nodelocal://%s", filepath.Join(dir, "temp-"+name))
+			sqlDB.Exec(fmt.Sprintf(`IMPORT INTO d.t CSV DATA (%s) WITH temp = $1%s`, files, opts), temp)
+			start += nodes * rowsPerFile
+
+			checkCount(t, "existing", existing)
+			checkCount(t, name, nodes*rowsPerFile)
+			// The table is online and writable again.
+			sqlDB.Exec(`UPSERT INTO d.t VALUES (-1, 'written')`)
+			checkCount(t, "written", 1)
+		})
+	}
+
+	t.Run("failed", func(t *testing.T) {
+		path := filepath.Join(dir, "malformed")
+		if err := ioutil.WriteFile(path, []byte("1000000,ok\nnot-an-int,bad\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		temp := fmt.Sprintf("nodelocal://%s", filepath.Join(dir, "temp-failed"))
+		if _, err := sqlDB.DB.Exec(
+			`IMPORT INTO d.t CSV DATA ($1) WITH temp = $2`, fmt.Sprintf("nodelocal://%s", path), temp,
+		); !testutils.IsError(err, `parse "a" as INT`) {
+			t.Fatalf("expected parse error, got %v", err)
+		}
+		checkCount(t, "existing", existing)
+		checkCount(t, "ok", 0)
+		desc := sqlbase.GetTableDescriptor(tc.Server(0).KVClient().(*client.DB), "d", "t")
+		if desc.State != sqlbase.TableDescriptor_PUBLIC {
+			t.Fatalf("expected table to be public, got %s", desc.State)
+		}
+	})
+
+	t.Run("revert", func(t *testing.T) {
+		kvDB := tc.Server(0).KVClient().(*client.DB)
+		sqlDB.Exec(`CREATE TABLE d.r (a INT PRIMARY KEY, b STRING, INDEX (b))`)
+		sqlDB.Exec(`INSERT INTO d.r SELECT i, 'before' FROM generate_series(1, 10) AS g(i)`)
+		ts := tc.Server(0).Clock().Now()
+		sqlDB.Exec(`UPSERT INTO d.r SELECT i, 'after' FROM generate_series(5, 20) AS g(i)`)
+
+		desc := sqlbase.GetTableDescriptor(kvDB, "d", "r")
+		if err := revertTableData(ctx, kvDB, desc.ID, ts); err != nil {
+			t.Fatal(err)
+		}
+		sqlDB.CheckQueryResults(
+			`SELECT b, count(*) FROM d.r@r_b_idx GROUP BY b`, [][]string{{"before", "10"}},
+		)
+		sqlDB.CheckQueryResults(
+			`SELECT b, count(*) FROM d.r@primary GROUP BY b`, [][]string{{"before", "10"}},
+		)
+	})
+
+	t.Run("constraints", func(t *testing.T) {
+		sqlDB.Exec(`CREATE TABLE d.ref (a INT PRIMARY KEY)`)
+		sqlDB.Exec(`INSERT INTO d.ref VALUES (1)`)
+		sqlDB.Exec(`CREATE TABLE d.c (a INT PRIMARY KEY, b STRING UNIQUE, r INT REFERENCES d.ref, INDEX (r))`)
+		sqlDB.Exec(`INSERT INTO d.c VALUES (1, 'existing', 1)`)
+
+		for i, tc := range []struct {
+			data string
+			err  string
+		}{
+			{"2,new,1\n1,new,1\n", `duplicate key value \(a\)=\(1\) violates unique constraint "primary"`},
+			{"2,existing,1\n", `duplicate key value \(b\)=\('existing'\) violates unique constraint "c_b_key"`},
+			{"2,new,1\n3,other,5\n", `foreign key violation: value \(5\) not found in ref@primary`},
+			{"2,new,1\n3,other,1\n", ``},
+		} {
+			path := filepath.Join(dir, fmt.Sprintf("constraints-%d", i))
+			if err := ioutil.WriteFile(path, []byte(tc.data), 0666); err != nil {
+				t.Fatal(err)
+			}
+			temp := fmt.Sprintf("nodelocal://%s", filepath.Join(dir, fmt.Sprintf("temp-constraints-%d", i)))
+			_, err := sqlDB.DB.Exec(
+				`IMPORT INTO d.c CSV DATA ($1) WITH temp = $2`, fmt.Sprintf("nodelocal://%s", path), temp,
+			)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			if !testutils.IsError(err, tc.err) {
+				t.Fatalf("%q: expected error %q, got %v", tc.data, tc.err, err)
+			}
+			// The failed import was reverted, leaving the existing row as is.
+			sqlDB.CheckQueryResults(`SELECT * FROM d.c`, [][]string{{"1", "existing", "1"}})
+			sqlDB.CheckQueryResults(`SELECT a FROM d.c@c_b_key`, [][]string{{"1"}})
+		}
+		sqlDB.CheckQueryResults(`SELECT a, b FROM d.c@c_b_key ORDER BY b`, [][]string{
+			{"1", "existing"}, {"2", "new"}, {"3", "other"},
+		})
+		desc := sqlbase.GetTableDescriptor(tc.Server(0).KVClient().(*client.DB), "d", "c")
+		if desc.State != sqlbase.TableDescriptor_PUBLIC {
+			t.Fatalf("expected table to be public, got %s", desc.State)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		sqlDB.Exec(`CREATE VIEW d.v AS SELECT a FROM d.t`)
+		sqlDB.Exec(`CREATE TABLE d.parent (a INT PRIMARY KEY)`)
+		sqlDB.Exec(`CREATE TABLE d.child (a INT PRIMARY KEY, b INT) INTERLEAVE IN PARENT d.parent (a)`)
+		files := writeFiles(t, "errors", 0, 1)
+		temp := fmt.Sprintf("nodelocal://%s", filepath.Join(dir, "temp-errors"))
+		for _, tc := range []struct {
+			query string
+			err   string
+		}{
+			{`IMPORT INTO d.nope CSV DATA (%s) WITH temp = $1`, `table "nope" does not exist`},
+			{`IMPORT INTO d.v CSV DATA (%s) WITH temp = $1`, `cannot import into view "v"`},
+			{`IMPORT INTO d.parent CSV DATA (%s) WITH temp = $1`, `cannot import into interleaved table "parent"`},
+			{`IMPORT INTO d.child CSV DATA (%s) WITH temp = $1`, `cannot import into interleaved table "child"`},
+			{`IMPORT INTO d.t CSV DATA (%s) WITH temp = $1, into_db = 'd'`, `option "into_db" is not supported with IMPORT INTO`},
+		} {
+			query := fmt.Sprintf(tc.query, files)
+			if _, err := sqlDB.DB.Exec(query, temp); !testutils.IsError(err, tc.err) {
+				t.Errorf("%s: expected error %q, got %v", query, tc.err, err)
+			}
+		}
+	})
+}
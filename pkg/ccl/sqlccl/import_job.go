@@ -0,0 +1,387 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"math"
+	"strconv"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/pkg/errors"
+)
+
+// importChunkRows is the number of CSV rows an IMPORT job converts locally
+// into each chunk. The job checkpoints its progress after each chunk, so a
+// resumed job redoes at most one chunk of conversion.
+var importChunkRows int64 = 1000000
+
+// importConvertFraction is the fraction of an IMPORT job's progress that is
+// reported for converting all of its files. The rest is for restoring them.
+const importConvertFraction = 0.5
+
+// importTable imports the CSV files into a new table, described by tableDesc,
+// in the database targetDB.
+func importTable(
+	ctx context.Context,
+	p sql.PlanHookState,
+	importStmt *parser.Import,
+	files []string,
+	opts map[string]string,
+	tableDesc *sqlbase.TableDescriptor,
+	targetDB string,
+	encryption *roachpb.FileEncryptionOptions,
+	walltime int64,
+	resultsCh chan<- parser.Datums,
+) error {
+	description, err := importJobDescription(importStmt, files)
+	if err != nil {
+		return err
+	}
+	tableRewrites, err := allocateTableRewrites(ctx, p, []sqlbase.Descriptor{
+		*sqlbase.WrapDescriptor(&sqlbase.DatabaseDescriptor{
+			Name: csvDatabaseName,
+			ID:   defaultCSVParentID,
+		}),
+		*sqlbase.WrapDescriptor(tableDesc),
+	}, map[string]string{restoreOptIntoDB: targetDB})
+	if err != nil {
+		return err
+	}
+	return runImportJob(
+		ctx, p, description, []sqlbase.ID{tableRewrites[tableDesc.ID].TableID}, files, opts,
+		tableDesc, walltime,
+		jobs.RestoreDetails{
+			TableRewrites: tableRewrites,
+			Encryption:    encryption,
+		},
+		resultsCh,
+	)
+}
+
+// runImportJob creates an IMPORT job that converts the CSV files into the
+// table described by tableDesc, and then restores them as described by
+// restoreDetails, and runs it.
+func runImportJob(
+	ctx context.Context,
+	p sql.PlanHookState,
+	description string,
+	descriptorIDs []sqlbase.ID,
+	files []string,
+	opts map[string]string,
+	tableDesc *sqlbase.TableDescriptor,
+	walltime int64,
+	restoreDetails jobs.RestoreDetails,
+	resultsCh chan<- parser.Datums,
+) error {
+	// The passphrase is not needed once the key has been derived from it, and
+	// is not written to the job.
+	jobOpts := make(map[string]string, len(opts))
+	for k, v := range opts {
+		if k != backupOptEncPassphrase {
+			jobOpts[k] = v
+		}
+	}
+	job := p.ExecCfg().JobRegistry.NewJob(jobs.Record{
+		Description:   description,
+		Username:      p.User(),
+		DescriptorIDs: descriptorIDs,
+		Details: jobs.ImportDetails{
+			URIs:      files,
+			Opts:      jobOpts,
+			TableDesc: *tableDesc,
+			Walltime:  walltime,
+			ResumePos: make([]int64, len(files)),
+			Restore:   restoreDetails,
+		},
+	})
+	res, importErr := runImport(ctx, p, job)
+	if err := job.FinishedWith(ctx, importErr); err != nil {
+		return err
+	}
+	if importErr != nil {
+		return importErr
+	}
+	resultsCh <- restoreResultRow(job, res)
+	return nil
+}
+
+// runImport runs an IMPORT job, either one just created by p or one resumed,
+// with a nil p, after the node running it failed. The rows of its files are
+// converted into chunks, each a backup in the temporary storage location,
+// which are then restored. Both steps checkpoint their progress in the job's
+// details, so that a resumed job picks up from the last chunk written, and
+// then from the last span restored.
+//
+// If the import into an existing table fails, the table's data is reverted
+// and it is brought back online.
+func runImport(
+	ctx context.Context, p sql.PlanHookState, job *jobs.Job,
+) (roachpb.BulkOpSummary, error) {
+	details := job.Record.Details.(jobs.ImportDetails)
+	res, err := func() (roachpb.BulkOpSummary, error) {
+		importCtx, cancel := context.WithCancel(ctx)
+		if err := job.Created(importCtx, cancel); err != nil {
+			return roachpb.BulkOpSummary{}, err
+		}
+		if err := job.Started(importCtx); err != nil {
+			return roachpb.BulkOpSummary{}, err
+		}
+		if len(details.Restore.URIs) == 0 {
+			if err := convertImportChunks(importCtx, p, job, &details); err != nil {
+				return roachpb.BulkOpSummary{}, err
+			}
+		}
+
+		backupDescs, err := loadBackupDescs(importCtx, details.Restore.URIs, details.Restore.Encryption)
+		if err != nil {
+			return roachpb.BulkOpSummary{}, err
+		}
+		// restore rewrites the descriptors it is given, so give it a copy.
+		tableDesc := protoutil.Clone(&details.TableDesc).(*sqlbase.TableDescriptor)
+		return restore(
+			importCtx,
+			job.DB(),
+			job.Gossip(),
+			backupDescs,
+			[]sqlbase.Descriptor{*sqlbase.WrapDescriptor(tableDesc)},
+			details.Restore.TableRewrites,
+			job,
+		)
+	}()
+	if err != nil && details.Restore.RevertTime != (hlc.Timestamp{}) {
+		// An IMPORT INTO must not leave its partially imported data behind.
+		err = revertImportInto(ctx, job.DB(), details.Restore.TableRewrites, details.Restore.RevertTime, err)
+	}
+	return res, err
+}
+
+// convertImportChunks converts the rows of an IMPORT job's files that have not
+// been yet into chunks, each a backup in its own directory of the temporary
+// storage location, and checkpoints details after each one. The keys of the
+// i-th chunk are at details.Walltime+i, and its backup covers the table's
+// spans from the end time of the previous chunk, so that the chunks restore
+// like a chain of incremental backups. Once all of the rows are converted,
+// the chunks are set as the URIs of details.Restore.
+//
+// Each chunk is only checked for duplicate keys on its own. A key written by
+// rows in two chunks has a version in each, which the restore rejects (see
+// roachpb.ImportRequest.DisallowShadowing).
+//
+// The files of a distributed IMPORT are converted by distributed flows run by
+// p. A resumed job has no planner to run them with, so it converts the files
+// left locally instead, a chunk of importChunkRows at a time like those of
+// any other IMPORT.
+func convertImportChunks(
+	ctx context.Context, p sql.PlanHookState, job *jobs.Job, details *jobs.ImportDetails,
+) error {
+	opts, err := parseImportOptions(details.Opts)
+	if err != nil {
+		return err
+	}
+	tableDesc := &details.TableDesc
+	if _, distributed := details.Opts[importOptionDistributed]; distributed && p != nil {
+		if err := convertImportFilesDistributed(ctx, p, job, details, opts); err != nil {
+			return err
+		}
+	}
+	reader := newCSVChunkReader(
+		details.URIs, details.ResumePos, opts.comma, opts.comment, len(tableDesc.VisibleColumns()),
+	)
+	defer reader.close(ctx)
+
+	for !reader.done() {
+		chunk := details.Chunks
+		dest, err := joinURIPath(opts.temp, strconv.Itoa(int(chunk)))
+		if err != nil {
+			return err
+		}
+		rows, err := convertImportChunk(ctx, reader, tableDesc, opts, details, dest)
+		if err != nil {
+			return errors.Wrapf(err, "converting chunk %d", chunk)
+		}
+		if rows > 0 {
+			details.Chunks++
+		}
+		if err := checkpointImportChunks(ctx, job, details); err != nil {
+			return err
+		}
+		log.Infof(ctx, "job %d: converted %d rows into chunk %d", *job.ID(), rows, chunk)
+	}
+	if details.Chunks == 0 {
+		return errors.New("no rows to import")
+	}
+
+	for i := 0; i < int(details.Chunks); i++ {
+		uri, err := joinURIPath(opts.temp, strconv.Itoa(i))
+		if err != nil {
+			return err
+		}
+		details.Restore.URIs = append(details.Restore.URIs, uri)
+	}
+	if err := job.Progressed(ctx, importConvertFraction, func(_ context.Context, d interface{}) {
+		d.(*jobs.Payload_Import).Import.Restore.URIs = details.Restore.URIs
+	}); err != nil {
+		return err
+	}
+	job.Record.Details = *details
+	return nil
+}
+
+// convertImportFilesDistributed converts the files of a distributed IMPORT job
+// that have not been read yet into chunks, each by a distributed flow reading
+// as many files as there are nodes, and checkpoints details after each one.
+func convertImportFilesDistributed(
+	ctx context.Context,
+	p sql.PlanHookState,
+	job *jobs.Job,
+	details *jobs.ImportDetails,
+	opts importOptions,
+) error {
+	resp, err := p.ExecCfg().StatusServer.Nodes(ctx, &serverpb.NodesRequest{})
+	if err != nil {
+		return err
+	}
+	filesPerChunk := len(resp.Nodes)
+	if filesPerChunk == 0 {
+		filesPerChunk = 1
+	}
+	var files []int
+	for i, pos := range details.ResumePos {
+		if pos == 0 {
+			files = append(files, i)
+		}
+	}
+
+	for len(files) > 0 {
+		n := filesPerChunk
+		if n > len(files) {
+			n = len(files)
+		}
+		uris := make([]string, n)
+		for i, f := range files[:n] {
+			uris[i] = details.URIs[f]
+		}
+
+		chunk := details.Chunks
+		dest, err := joinURIPath(opts.temp, strconv.Itoa(int(chunk)))
+		if err != nil {
+			return err
+		}
+		ssts, err := doDistributedCSVTransform(
+			ctx, uris, p, &details.TableDesc, dest, opts.comma, opts.comment, opts.nullif,
+			details.Walltime+int64(chunk), details.Restore.Encryption, importChunkBackup(details),
+		)
+		if err != nil {
+			return errors.Wrapf(err, "converting chunk %d", chunk)
+		}
+		if ssts > 0 {
+			details.Chunks++
+		}
+		for _, f := range files[:n] {
+			details.ResumePos[f] = math.MaxInt64
+		}
+		files = files[n:]
+
+		if err := checkpointImportChunks(ctx, job, details); err != nil {
+			return err
+		}
+		log.Infof(ctx, "job %d: converted %d files into chunk %d", *job.ID(), n, chunk)
+	}
+	return nil
+}
+
+// checkpointImportChunks records the chunks converted by an IMPORT job, and
+// the positions in its files to resume from, in the job's details.
+func checkpointImportChunks(ctx context.Context, job *jobs.Job, details *jobs.ImportDetails) error {
+	filesDone := 0
+	for _, pos := range details.ResumePos {
+		if pos == math.MaxInt64 {
+			filesDone++
+		}
+	}
+	fraction := importConvertFraction * float32(filesDone) / float32(len(details.ResumePos))
+	return job.Progressed(ctx, fraction, func(_ context.Context, d interface{}) {
+		importDetails := d.(*jobs.Payload_Import).Import
+		importDetails.ResumePos = append([]int64(nil), details.ResumePos...)
+		importDetails.Chunks = details.Chunks
+	})
+}
+
+// importChunkBackup returns the backup descriptor, without any files yet, of
+// the next chunk of the IMPORT job described by details.
+func importChunkBackup(details *jobs.ImportDetails) BackupDescriptor {
+	chunk := int64(details.Chunks)
+	backupDesc := BackupDescriptor{
+		FormatVersion: BackupFormatInitialVersion,
+		EndTime:       hlc.Timestamp{WallTime: details.Walltime + chunk + 1},
+		Spans:         spansForAllTableIndexes([]*sqlbase.TableDescriptor{&details.TableDesc}),
+	}
+	if chunk > 0 {
+		backupDesc.StartTime = hlc.Timestamp{WallTime: details.Walltime + chunk}
+	}
+	return backupDesc
+}
+
+// convertImportChunk converts the next importChunkRows rows read by reader
+// into a backup of the IMPORT job described by details in dest. Nothing is
+// written if there are no rows left. It returns the number of rows converted.
+func convertImportChunk(
+	ctx context.Context,
+	reader *csvChunkReader,
+	tableDesc *sqlbase.TableDescriptor,
+	opts importOptions,
+	details *jobs.ImportDetails,
+	dest string,
+) (int64, error) {
+	chunk := int64(details.Chunks)
+	backupDesc := importChunkBackup(details)
+
+	es, err := exportStorageFromURI(ctx, dest)
+	if err != nil {
+		return 0, err
+	}
+	defer es.Close()
+
+	encryption := details.Restore.Encryption
+	rows, _, err := convertCSV(
+		ctx, reader, importChunkRows, tableDesc, opts.nullif, opts.sstSize, details.Walltime+chunk,
+		func(ctx context.Context, contentCh <-chan sstContent) error {
+			return writeSSTs(ctx, es, contentCh, encryption, &backupDesc)
+		},
+	)
+	if err != nil || rows == 0 {
+		return rows, err
+	}
+	return rows, finalizeCSVBackup(ctx, &backupDesc, defaultCSVParentID, tableDesc, es, encryption)
+}
+
+func importResumeHook(typ jobs.Type) func(ctx context.Context, job *jobs.Job) error {
+	if typ != jobs.TypeImport {
+		return nil
+	}
+
+	return func(ctx context.Context, job *jobs.Job) error {
+		_, err := runImport(ctx, nil /* p */, job)
+		return err
+	}
+}
+
+func init() {
+	jobs.AddResumeHook(importResumeHook)
+}
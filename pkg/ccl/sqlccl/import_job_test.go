@@ -0,0 +1,360 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/security"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/pkg/errors"
+)
+
+func TestImportJobResume(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	defer func(oldInterval time.Duration) {
+		jobs.DefaultAdoptInterval = oldInterval
+	}(jobs.DefaultAdoptInterval)
+	jobs.DefaultAdoptInterval = 100 * time.Millisecond
+
+	defer func(oldChunkRows int64) {
+		importChunkRows = oldChunkRows
+	}(importChunkRows)
+	importChunkRows = 30
+
+	const (
+		numFiles    = 2
+		rowsPerFile = 100
+		numRows     = numFiles * rowsPerFile
+		// The rows are split into chunks across the files.
+		numChunks = (numRows + 29) / 30
+	)
+	ctx := context.Background()
+	s, db, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+	kvDB := s.KVClient().(*client.DB)
+	sqlDB := sqlutils.MakeSQLRunner(t, db)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	writeFile := func(name string, contents func(i int) string) string {
+		var buf bytes.Buffer
+		for i := 0; i < rowsPerFile; i++ {
+			buf.WriteString(contents(i))
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("nodelocal://%s", path)
+	}
+	var files []string
+	for fn := 0; fn < numFiles; fn++ {
+		fn := fn
+		files = append(files, writeFile(fmt.Sprintf("data-%d", fn), func(i int) string {
+			return fmt.Sprintf("%d,%d\n", fn*rowsPerFile+i, fn)
+		}))
+	}
+	temp := fmt.Sprintf("nodelocal://%s", filepath.Join(dir, "temp"))
+
+	readPayload := func(t *testing.T, jobID int64) *jobs.Payload {
+		var buf []byte
+		sqlDB.QueryRow(`SELECT payload FROM system.jobs WHERE id = $1`, jobID).Scan(&buf)
+		payload := &jobs.Payload{}
+		if err := protoutil.Unmarshal(buf, payload); err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+	checkRows := func(t *testing.T, table string, expected int) {
+		var count int
+		sqlDB.QueryRow(fmt.Sprintf(`SELECT count(*) FROM d.%s`, table)).Scan(&count)
+		if count != expected {
+			t.Fatalf("expected %d rows in %s, got %d", expected, table, count)
+		}
+	}
+
+	sqlDB.Exec(`CREATE DATABASE d`)
+
+	var details jobs.ImportDetails
+	t.Run("chunks", func(t *testing.T) {
+		var jobID int64
+		var unused interface{}
+		var rows int
+		sqlDB.QueryRow(
+			`IMPORT TABLE t (a INT PRIMARY KEY, b INT) CSV DATA ($1, $2) WITH temp = $3, into_db = 'd'`,
+			files[0], files[1], temp,
+		).Scan(&jobID, &unused, &unused, &rows, &unused, &unused, &unused)
+		if rows != numRows {
+			t.Fatalf("expected %d rows, got %d", numRows, rows)
+		}
+		checkRows(t, "t", numRows)
+
+		payload := readPayload(t, jobID)
+		details = *payload.GetImport()
+		if details.Chunks != numChunks {
+			t.Fatalf("expected %d chunks, got %d", numChunks, details.Chunks)
+		}
+		if expected := []int64{math.MaxInt64, math.MaxInt64}; !reflect.DeepEqual(details.ResumePos, expected) {
+			t.Fatalf("expected resume positions %v, got %v", expected, details.ResumePos)
+		}
+		if len(details.Restore.URIs) != numChunks {
+			t.Fatalf("expected %d chunks to restore, got %v", numChunks, details.Restore.URIs)
+		}
+	})
+
+	// Resume a job, as adopted from a node that died after converting the
+	// first chunk of the import above, into a new table. Its files are the
+	// same except for the rows of the first chunk, which are now malformed so
+	// that converting them again would fail.
+	t.Run("resume", func(t *testing.T) {
+		if details.Chunks == 0 {
+			t.Skip("the IMPORT failed")
+		}
+		malformed := writeFile("malformed-0", func(i int) string {
+			if int64(i) < importChunkRows {
+				return "not,a row,at all\n"
+			}
+			return fmt.Sprintf("%d,%d\n", i, 0)
+		})
+
+		var parentID sqlbase.ID
+		sqlDB.QueryRow(`SELECT id FROM system.namespace WHERE "parentID" = 0 AND name = 'd'`).Scan(&parentID)
+		tableID, err := sql.GenerateUniqueDescID(ctx, kvDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resumed := details
+		resumed.URIs = []string{malformed, files[1]}
+		resumed.TableDesc.Name = "resumed"
+		resumed.ResumePos = []int64{importChunkRows, 0}
+		resumed.Chunks = 1
+		resumed.Restore = jobs.RestoreDetails{
+			TableRewrites: tableRewriteMap{
+				defaultCSVTableID: &jobs.RestoreDetails_TableRewrite{TableID: tableID, ParentID: parentID},
+			},
+		}
+		payload, err := protoutil.Marshal(&jobs.Payload{
+			Description:   "IMPORT resumed",
+			Username:      security.RootUser,
+			DescriptorIDs: []sqlbase.ID{tableID},
+			Details:       jobs.WrapPayloadDetails(resumed),
+			Lease:         &jobs.Lease{NodeID: 1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var jobID int64
+		sqlDB.QueryRow(
+			`INSERT INTO system.jobs (status, payload) VALUES ($1, $2) RETURNING id`,
+			jobs.StatusRunning, payload,
+		).Scan(&jobID)
+
+		testutils.SucceedsSoon(t, func() error {
+			var status string
+			sqlDB.QueryRow(`SELECT status FROM system.jobs WHERE id = $1`, jobID).Scan(&status)
+			switch jobs.Status(status) {
+			case jobs.StatusSucceeded:
+				return nil
+			case jobs.StatusFailed:
+				t.Fatalf("job failed: %s", readPayload(t, jobID).Error)
+			}
+			return errors.Errorf("expected job to succeed, got %s", status)
+		})
+		checkRows(t, "resumed", numRows)
+		if chunks := readPayload(t, jobID).GetImport().Chunks; chunks != numChunks {
+			t.Fatalf("expected %d chunks, got %d", numChunks, chunks)
+		}
+	})
+
+	// A distributed IMPORT converts as many files as there are nodes, here
+	// one, into each chunk.
+	var distributedDetails jobs.ImportDetails
+	t.Run("distributed", func(t *testing.T) {
+		var jobID int64
+		var unused interface{}
+		sqlDB.QueryRow(
+			`IMPORT TABLE dist (a INT PRIMARY KEY, b INT) CSV DATA ($1, $2) WITH temp = $3, into_db = 'd', distributed`,
+			files[0], files[1], fmt.Sprintf("nodelocal://%s", filepath.Join(dir, "temp-distributed")),
+		).Scan(&jobID, &unused, &unused, &unused, &unused, &unused, &unused)
+		checkRows(t, "dist", numRows)
+
+		distributedDetails = *readPayload(t, jobID).GetImport()
+		if distributedDetails.Chunks != numFiles {
+			t.Fatalf("expected %d chunks, got %d", numFiles, distributedDetails.Chunks)
+		}
+		if expected := []int64{math.MaxInt64, math.MaxInt64}; !reflect.DeepEqual(distributedDetails.ResumePos, expected) {
+			t.Fatalf("expected resume positions %v, got %v", expected, distributedDetails.ResumePos)
+		}
+	})
+
+	// Resume a distributed job, as adopted from a node that died after
+	// converting its first file, into a new table. The first file is not read
+	// again, and the second is converted locally.
+	t.Run("resume-distributed", func(t *testing.T) {
+		if distributedDetails.Chunks == 0 {
+			t.Skip("the IMPORT failed")
+		}
+		malformed := writeFile("malformed-all", func(int) string {
+			return "not,a row,at all\n"
+		})
+
+		var parentID sqlbase.ID
+		sqlDB.QueryRow(`SELECT id FROM system.namespace WHERE "parentID" = 0 AND name = 'd'`).Scan(&parentID)
+		tableID, err := sql.GenerateUniqueDescID(ctx, kvDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resumed := distributedDetails
+		resumed.URIs = []string{malformed, files[1]}
+		resumed.TableDesc.Name = "resumed_dist"
+		resumed.ResumePos = []int64{math.MaxInt64, 0}
+		resumed.Chunks = 1
+		resumed.Restore = jobs.RestoreDetails{
+			TableRewrites: tableRewriteMap{
+				defaultCSVTableID: &jobs.RestoreDetails_TableRewrite{TableID: tableID, ParentID: parentID},
+			},
+		}
+		payload, err := protoutil.Marshal(&jobs.Payload{
+			Description:   "IMPORT resumed distributed",
+			Username:      security.RootUser,
+			DescriptorIDs: []sqlbase.ID{tableID},
+			Details:       jobs.WrapPayloadDetails(resumed),
+			Lease:         &jobs.Lease{NodeID: 1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var jobID int64
+		sqlDB.QueryRow(
+			`INSERT INTO system.jobs (status, payload) VALUES ($1, $2) RETURNING id`,
+			jobs.StatusRunning, payload,
+		).Scan(&jobID)
+
+		testutils.SucceedsSoon(t, func() error {
+			var status string
+			sqlDB.QueryRow(`SELECT status FROM system.jobs WHERE id = $1`, jobID).Scan(&status)
+			switch jobs.Status(status) {
+			case jobs.StatusSucceeded:
+				return nil
+			case jobs.StatusFailed:
+				t.Fatalf("job failed: %s", readPayload(t, jobID).Error)
+			}
+			return errors.Errorf("expected job to succeed, got %s", status)
+		})
+		checkRows(t, "resumed_dist", numRows)
+	})
+}
+
+// TestImportJobChunkDuplicates verifies that rows with the same primary key or
+// unique index values are rejected when they are converted into different
+// chunks, which are only compared when they are restored together.
+func TestImportJobChunkDuplicates(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	defer func(oldChunkRows int64) {
+		importChunkRows = oldChunkRows
+	}(importChunkRows)
+	importChunkRows = 2
+
+	ctx := context.Background()
+	s, db, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+	sqlDB := sqlutils.MakeSQLRunner(t, db)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	sqlDB.Exec(`CREATE DATABASE d`)
+	sqlDB.Exec(`CREATE TABLE d.existing (a INT PRIMARY KEY, b STRING UNIQUE)`)
+	sqlDB.Exec(`INSERT INTO d.existing VALUES (10, 'existing')`)
+
+	for i, tc := range []struct {
+		// files are the contents of the CSV files. Rows in different files, or
+		// more than importChunkRows apart, are converted into different chunks.
+		files []string
+		// into is the existing table to import into, if any.
+		into        string
+		distributed bool
+		err         string
+	}{
+		// The primary key is repeated in the second chunk.
+		{files: []string{"1,x\n2,y\n1,z\n"}, err: "duplicate key"},
+		// The unique index value is repeated in the second chunk.
+		{files: []string{"1,x\n2,y\n3,x\n"}, err: "duplicate key"},
+		// A distributed IMPORT converts each file into its own chunk here.
+		{files: []string{"1,x\n", "1,y\n"}, distributed: true, err: "duplicate key"},
+		{files: []string{"1,x\n2,y\n3,x\n"}, into: "d.existing", err: "duplicate key"},
+		{files: []string{"1,x\n2,y\n3,z\n"}},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			var uris []string
+			for j, contents := range tc.files {
+				path := filepath.Join(dir, fmt.Sprintf("data-%d-%d", i, j))
+				if err := ioutil.WriteFile(path, []byte(contents), 0666); err != nil {
+					t.Fatal(err)
+				}
+				uris = append(uris, fmt.Sprintf("'nodelocal://%s'", path))
+			}
+			var stmt string
+			if tc.into != "" {
+				stmt = fmt.Sprintf(`IMPORT INTO %s CSV DATA (%s) WITH temp = $1`,
+					tc.into, strings.Join(uris, ", "))
+			} else {
+				stmt = fmt.Sprintf(
+					`IMPORT TABLE t%d (a INT PRIMARY KEY, b STRING UNIQUE) CSV DATA (%s) WITH temp = $1, into_db = 'd'`,
+					i, strings.Join(uris, ", "))
+			}
+			if tc.distributed {
+				stmt += ", distributed"
+			}
+			temp := fmt.Sprintf("nodelocal://%s", filepath.Join(dir, fmt.Sprintf("temp-%d", i)))
+			_, err := sqlDB.DB.Exec(stmt, temp)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				sqlDB.CheckQueryResults(fmt.Sprintf(`SELECT count(*) FROM d.t%d`, i), [][]string{{"3"}})
+				return
+			}
+			if !testutils.IsError(err, tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// The failed IMPORT INTO was reverted, and the failed IMPORTs didn't create
+	// their tables.
+	sqlDB.CheckQueryResults(`SELECT * FROM d.existing`, [][]string{{"10", "existing"}})
+	sqlDB.CheckQueryResults(`SELECT a FROM d.existing@existing_b_key`, [][]string{{"10"}})
+	sqlDB.CheckQueryResults(`SHOW TABLES FROM d`, [][]string{{"existing"}, {"t4"}})
+}
@@ -44,11 +44,13 @@ const (
 	restoreOptSkipMissingFKs = "skip_missing_foreign_keys"
 )
 
-func loadBackupDescs(ctx context.Context, uris []string) ([]BackupDescriptor, error) {
+func loadBackupDescs(
+	ctx context.Context, uris []string, encryption *roachpb.FileEncryptionOptions,
+) ([]BackupDescriptor, error) {
 	backupDescs := make([]BackupDescriptor, len(uris))
 
 	for i, uri := range uris {
-		desc, err := readBackupDescriptorFromURI(ctx, uri)
+		desc, err := readBackupDescriptorFromURI(ctx, uri, encryption)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to read backup descriptor")
 		}
@@ -60,6 +62,121 @@ func loadBackupDescs(ctx context.Context, uris []string) ([]BackupDescriptor, er
 	return backupDescs, nil
 }
 
+// setLocalityDirs sets the DirByLocalityKV of each of backupDescs to its other
+// locations given in localityURIs, which is either empty or parallel to
+// backupDescs, and checks that every file of a locality-aware backup was
+// written to one of them.
+func setLocalityDirs(
+	backupDescs []BackupDescriptor, localityURIs []jobs.RestoreDetails_BackupLocalityURIs,
+) error {
+	for i := range backupDescs {
+		b := &backupDescs[i]
+		if len(localityURIs) > 0 {
+			dirs, err := exportStorageConfsByLocalityKV(localityURIs[i].URIsByLocalityKV)
+			if err != nil {
+				return err
+			}
+			b.DirByLocalityKV = dirs
+		}
+		for _, f := range b.Files {
+			if f.LocalityKV == "" {
+				continue
+			}
+			if _, ok := b.DirByLocalityKV[f.LocalityKV]; !ok {
+				return errors.Errorf(
+					"backup file %s is in the location with %s=%s, which was not given",
+					f.Path, localityURLParam, f.LocalityKV,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// backupsAsOf returns the backups, out of a chain of backups, needed to restore
+// as of endTime. This must either be the end time of one of the backups or be
+// covered by the revision history of one.
+func backupsAsOf(backupDescs []BackupDescriptor, endTime hlc.Timestamp) ([]BackupDescriptor, error) {
+	for i, b := range backupDescs {
+		if endTime == b.EndTime {
+			return backupDescs[:i+1], nil
+		}
+		if !b.StartTime.Less(endTime) || !endTime.Less(b.EndTime) {
+			continue
+		}
+		if b.MVCCFilter != roachpb.MVCCFilter_All {
+			return nil, errors.Errorf(
+				"invalid RESTORE timestamp: restoring to %s requires a backup with %s covering it, "+
+					"or one ending at exactly that time", endTime, backupOptRevisionHistory)
+		}
+		if endTime.Less(b.RevisionStartTime) {
+			return nil, errors.Errorf(
+				"invalid RESTORE timestamp: the revision history of the backup covering %s only starts at %s",
+				endTime, b.RevisionStartTime)
+		}
+		asOf := make([]BackupDescriptor, i+1)
+		copy(asOf, backupDescs[:i+1])
+		var prev *BackupDescriptor
+		if i > 0 {
+			prev = &backupDescs[i-1]
+		}
+		asOf[i].Descriptors = descriptorsAsOf(b, prev, endTime)
+		return asOf, nil
+	}
+	return nil, errors.Errorf(
+		"invalid RESTORE timestamp: %s is not covered by the backups, which end at %s",
+		endTime, backupDescs[len(backupDescs)-1].EndTime)
+}
+
+// descriptorsAsOf returns the descriptors in b as they were at asOf, which is
+// covered by the revision history of b, using the revisions of them recorded
+// in the backup. Those that did not exist at asOf are omitted. prev, if
+// non-nil, is the backup preceding b in its chain.
+func descriptorsAsOf(
+	b BackupDescriptor, prev *BackupDescriptor, asOf hlc.Timestamp,
+) []sqlbase.Descriptor {
+	// DescriptorChanges is ordered by time, so the last revision at or before
+	// asOf of each descriptor wins.
+	changed := make(map[sqlbase.ID]bool)
+	atAsOf := make(map[sqlbase.ID]*sqlbase.Descriptor)
+	for _, rev := range b.DescriptorChanges {
+		changed[rev.ID] = true
+		if asOf.Less(rev.Time) {
+			continue
+		}
+		atAsOf[rev.ID] = rev.Desc
+	}
+	var prevDescs map[sqlbase.ID]sqlbase.Descriptor
+	if prev != nil {
+		prevDescs = make(map[sqlbase.ID]sqlbase.Descriptor, len(prev.Descriptors))
+		for _, desc := range prev.Descriptors {
+			prevDescs[desc.GetID()] = desc
+		}
+	}
+
+	var descs []sqlbase.Descriptor
+	for _, desc := range b.Descriptors {
+		id := desc.GetID()
+		if !changed[id] {
+			descs = append(descs, desc)
+			continue
+		}
+		if revDesc, ok := atAsOf[id]; ok {
+			// A nil revision is a deletion.
+			if revDesc != nil {
+				descs = append(descs, *revDesc)
+			}
+			continue
+		}
+		// Every revision is after asOf, so the descriptor is as it was at the
+		// end of the previous backup, if it existed then.
+		if prevDesc, ok := prevDescs[id]; ok {
+			descs = append(descs, prevDesc)
+		}
+	}
+	return descs
+}
+
 func selectTargets(
 	backupDescs []BackupDescriptor, targets parser.TargetList,
 ) ([]sqlbase.Descriptor, error) {
@@ -447,13 +564,19 @@ func makeImportSpans(
 		backupCoverings = append(backupCoverings, backupSpanCovering)
 		var backupFileCovering intervalccl.Covering
 		for _, f := range b.Files {
+			// setLocalityDirs has checked that the files of a locality-aware
+			// backup are in one of its locations.
+			dir := b.Dir
+			if localityDir, ok := b.DirByLocalityKV[f.LocalityKV]; ok {
+				dir = *localityDir
+			}
 			backupFileCovering = append(backupFileCovering, intervalccl.Range{
 				Start: f.Span.Key,
 				End:   f.Span.EndKey,
 				Payload: importEntry{
 					Span:      f.Span,
 					entryType: backupFile,
-					dir:       b.Dir,
+					dir:       dir,
 					file:      f,
 				},
 			})
@@ -521,15 +644,44 @@ rangeLoop:
 // then flip (or initialize) the name -> ID entry so any new queries will use
 // the new one. The tables are assigned the permissions of their parent database
 // and the user must have CREATE permission on that database at the time this
-// function is called.
+// function is called. The databases, if any, are created first, and the tables
+// restored into them keep their own permissions.
 func restoreTableDescs(
-	ctx context.Context, db *client.DB, tables []*sqlbase.TableDescriptor, user string,
+	ctx context.Context,
+	db *client.DB,
+	databases []*sqlbase.DatabaseDescriptor,
+	tables []*sqlbase.TableDescriptor,
+	user string,
 ) error {
 	ctx, span := tracing.ChildSpan(ctx, "restoreTableDescs")
 	defer tracing.FinishSpan(span)
 	err := db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		newDatabases := make(map[sqlbase.ID]struct{}, len(databases))
+		if len(databases) > 0 {
+			if err := txn.SetSystemConfigTrigger(); err != nil {
+				return err
+			}
+			b := txn.NewBatch()
+			for _, database := range databases {
+				if err := database.Validate(); err != nil {
+					return err
+				}
+				b.CPut(sqlbase.MakeDescMetadataKey(database.ID), sqlbase.WrapDescriptor(database), nil)
+				b.CPut(sqlbase.MakeNameMetadataKey(keys.RootNamespaceID, database.Name), database.ID, nil)
+				newDatabases[database.ID] = struct{}{}
+			}
+			if err := txn.Run(ctx, b); err != nil {
+				return err
+			}
+		}
+
 		b := txn.NewBatch()
 		for _, table := range tables {
+			if _, ok := newDatabases[table.ParentID]; ok {
+				b.CPut(table.GetDescMetadataKey(), sqlbase.WrapDescriptor(table), nil)
+				b.CPut(table.GetNameMetadataKey(), table.ID, nil)
+				continue
+			}
 			parentDB, err := sqlbase.GetDatabaseDescFromID(ctx, txn, table.ParentID)
 			if err != nil {
 				return errors.Wrapf(err, "failed to lookup parent DB %d", table.ParentID)
@@ -558,20 +710,22 @@ func restoreTableDescs(
 	return errors.Wrap(err, "restoring table desc and namespace entries")
 }
 
-func restoreJobDescription(restore *parser.Restore, from []string) (string, error) {
+func restoreJobDescription(restore *parser.Restore, from [][]string) (string, error) {
 	r := parser.Restore{
 		AsOf:    restore.AsOf,
-		Options: restore.Options,
+		Options: redactEncryptionPassphrase(restore.Options),
 		Targets: restore.Targets,
-		From:    make(parser.Exprs, len(restore.From)),
+		From:    make([]parser.Exprs, len(from)),
 	}
 
-	for i, f := range from {
-		sf, err := storageccl.SanitizeExportStorageURI(f)
-		if err != nil {
-			return "", err
+	for i, uris := range from {
+		for _, f := range uris {
+			sf, err := sanitizeLocalityURI(f)
+			if err != nil {
+				return "", err
+			}
+			r.From[i] = append(r.From[i], parser.NewDString(sf))
 		}
-		r.From[i] = parser.NewDString(sf)
 	}
 
 	return r.String(), nil
@@ -593,13 +747,19 @@ func restore(
 	// out work get their individual contexts.
 
 	failed := roachpb.BulkOpSummary{}
+	details := restoreDetails(job)
+	_, isImport := job.Record.Details.(jobs.ImportDetails)
 
+	// Only a full cluster restore creates the databases it restores into.
+	var databases []*sqlbase.DatabaseDescriptor
 	var tables []*sqlbase.TableDescriptor
 	var oldTableIDs []sqlbase.ID
 	for _, desc := range sqlDescs {
 		if tableDesc := desc.GetTable(); tableDesc != nil {
 			tables = append(tables, tableDesc)
 			oldTableIDs = append(oldTableIDs, tableDesc.ID)
+		} else if dbDesc := desc.GetDatabase(); dbDesc != nil && details.FullCluster {
+			databases = append(databases, dbDesc)
 		}
 	}
 
@@ -614,6 +774,9 @@ func restore(
 	if err := rewriteTableDescs(tables, tableRewrites); err != nil {
 		return failed, err
 	}
+	if err := rewriteDatabaseDescs(databases, tables, tableRewrites); err != nil {
+		return failed, err
+	}
 
 	// Get TableRekeys to use when importing raw data.
 	var rekeys []roachpb.ImportRequest_TableRekey
@@ -634,7 +797,7 @@ func restore(
 
 	// Pivot the backups, which are grouped by time, into requests for import,
 	// which are grouped by keyrange.
-	lowWaterMark := job.Record.Details.(jobs.RestoreDetails).LowWaterMark
+	lowWaterMark := details.LowWaterMark
 	importSpans, _, err := makeImportSpans(spans, backupDescs, lowWaterMark)
 	if err != nil {
 		return failed, errors.Wrapf(err, "making import requests for %d backups", len(backupDescs))
@@ -670,6 +833,12 @@ func restore(
 					d.Restore.LowWaterMark = importSpans[mu.lowWaterMark].Key
 				}
 				mu.Unlock()
+			case *jobs.Payload_Import:
+				mu.Lock()
+				if mu.lowWaterMark >= 0 {
+					d.Import.Restore.LowWaterMark = importSpans[mu.lowWaterMark].Key
+				}
+				mu.Unlock()
 			default:
 				log.Errorf(progressedCtx, "job payload had unexpected type %T", d)
 			}
@@ -751,10 +920,15 @@ func restore(
 				// Import is a point request because we don't want DistSender to split
 				// it. Assume (but don't require) the entire post-rewrite span is on the
 				// same range.
-				Span:     roachpb.Span{Key: newSpan.Key},
-				DataSpan: importSpan.Span,
-				Files:    importSpan.files,
-				Rekeys:   rekeys,
+				Span:       roachpb.Span{Key: newSpan.Key},
+				DataSpan:   importSpan.Span,
+				Files:      importSpan.files,
+				Rekeys:     rekeys,
+				EndTime:    details.EndTime,
+				Encryption: details.Encryption,
+				// The chunks of an IMPORT are restored together, so a key
+				// written by rows in two of them has two versions.
+				DisallowShadowing: isImport,
 			}
 			select {
 			case <-gCtx.Done():
@@ -814,11 +988,34 @@ func restore(
 
 	log.Event(restoreCtx, "making tables live")
 
-	// Write the new TableDescriptors and flip the namespace entries over to
-	// them. After this call, any queries on a table will be served by the newly
-	// restored data.
-	if err := restoreTableDescs(restoreCtx, db, tables, job.Record.Username); err != nil {
-		return failed, errors.Wrapf(err, "restoring %d TableDescriptors", len(tables))
+	if details.RevertTime != (hlc.Timestamp{}) {
+		// The data was restored into existing tables, which are brought back
+		// online once the imported rows are checked against their existing
+		// ones and their constraints.
+		var ids []sqlbase.ID
+		for _, table := range tables {
+			ids = append(ids, table.ID)
+		}
+		if err := validateImportedTables(restoreCtx, db, ids, details.RevertTime); err != nil {
+			return failed, err
+		}
+		if err := makeImportedTablesOnline(restoreCtx, db, ids); err != nil {
+			return failed, errors.Wrapf(err, "bringing %d tables online", len(ids))
+		}
+	} else {
+		// Write the new TableDescriptors and flip the namespace entries over to
+		// them. After this call, any queries on a table will be served by the
+		// newly restored data.
+		if err := restoreTableDescs(restoreCtx, db, databases, tables, job.Record.Username); err != nil {
+			return failed, errors.Wrapf(err, "restoring %d TableDescriptors", len(tables))
+		}
+	}
+	if details.FullCluster {
+		if err := restoreSystemTables(
+			restoreCtx, db, job.InternalExecutor(), tables, tableRewrites,
+		); err != nil {
+			return failed, errors.Wrap(err, "restoring system tables")
+		}
 	}
 
 	// TODO(dan): Delete any old table data here. The first version of restore
@@ -833,6 +1030,15 @@ func restore(
 	return mu.res, nil
 }
 
+// restoreDetails returns the details of the restore run by job, which is
+// either a RESTORE job or an IMPORT job restoring the rows it converted.
+func restoreDetails(job *jobs.Job) jobs.RestoreDetails {
+	if details, ok := job.Record.Details.(jobs.ImportDetails); ok {
+		return details.Restore
+	}
+	return job.Record.Details.(jobs.RestoreDetails)
+}
+
 var restoreHeader = sqlbase.ResultColumns{
 	{Name: "job_id", Typ: parser.TypeInt},
 	{Name: "status", Typ: parser.TypeString},
@@ -860,7 +1066,11 @@ func restorePlanHook(
 		return nil, nil, err
 	}
 
-	fromFn, err := p.TypeAsStringArray(restoreStmt.From, "RESTORE")
+	var fromExprs parser.Exprs
+	for _, f := range restoreStmt.From {
+		fromExprs = append(fromExprs, f...)
+	}
+	fromFn, err := p.TypeAsStringArray(fromExprs, "RESTORE")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -875,10 +1085,15 @@ func restorePlanHook(
 		ctx, span := tracing.ChildSpan(ctx, stmt.StatementTag())
 		defer tracing.FinishSpan(span)
 
-		from, err := fromFn()
+		flatFrom, err := fromFn()
 		if err != nil {
 			return err
 		}
+		// Regroup the URIs into the locations of each backup.
+		from := make([][]string, len(restoreStmt.From))
+		for i, f := range restoreStmt.From {
+			from[i], flatFrom = flatFrom[:len(f)], flatFrom[len(f):]
+		}
 		opts, err := optsFn()
 		if err != nil {
 			return err
@@ -892,22 +1107,74 @@ func doRestorePlan(
 	ctx context.Context,
 	restoreStmt *parser.Restore,
 	p sql.PlanHookState,
-	from []string,
+	from [][]string,
 	opts map[string]string,
 	resultsCh chan<- parser.Datums,
 ) error {
-	backupDescs, err := loadBackupDescs(ctx, from)
-	if err != nil {
-		return err
+	// The descriptor of each backup is in its default location. The other
+	// locations of locality-aware backups are only kept if there are any.
+	var defaultURIs []string
+	var localityURIs []jobs.RestoreDetails_BackupLocalityURIs
+	var localityAware bool
+	for _, uris := range from {
+		defaultURI, urisByLocalityKV, err := getURIsByLocalityKV(uris)
+		if err != nil {
+			return err
+		}
+		defaultURIs = append(defaultURIs, defaultURI)
+		localityURIs = append(localityURIs, jobs.RestoreDetails_BackupLocalityURIs{
+			URIsByLocalityKV: urisByLocalityKV,
+		})
+		localityAware = localityAware || len(urisByLocalityKV) > 0
+	}
+	if !localityAware {
+		localityURIs = nil
+	}
+
+	// All the backups in a chain are encrypted with the same key, derived from
+	// the salt stored alongside the first.
+	var encryption *roachpb.FileEncryptionOptions
+	if passphrase, ok := opts[backupOptEncPassphrase]; ok && len(defaultURIs) > 0 {
+		var err error
+		if encryption, err = encryptionOptionsFromURI(ctx, defaultURIs[0], passphrase); err != nil {
+			return err
+		}
 	}
-	sqlDescs, err := selectTargets(backupDescs, restoreStmt.Targets)
+	backupDescs, err := loadBackupDescs(ctx, defaultURIs, encryption)
 	if err != nil {
 		return err
 	}
-	tableRewrites, err := allocateTableRewrites(ctx, p, sqlDescs, opts)
-	if err != nil {
+	if err := setLocalityDirs(backupDescs, localityURIs); err != nil {
 		return err
 	}
+	var endTime hlc.Timestamp
+	if restoreStmt.AsOf.Expr != nil {
+		endTime, err = sql.EvalAsOfTimestamp(nil, restoreStmt.AsOf, p.ExecCfg().Clock.Now())
+		if err != nil {
+			return err
+		}
+		if backupDescs, err = backupsAsOf(backupDescs, endTime); err != nil {
+			return err
+		}
+	}
+	fullCluster := restoreStmt.Targets.Empty()
+	var sqlDescs []sqlbase.Descriptor
+	var tableRewrites tableRewriteMap
+	if fullCluster {
+		if sqlDescs, err = selectFullClusterDescriptors(backupDescs); err != nil {
+			return err
+		}
+		if tableRewrites, err = allocateClusterRewrites(ctx, p, sqlDescs, opts); err != nil {
+			return err
+		}
+	} else {
+		if sqlDescs, err = selectTargets(backupDescs, restoreStmt.Targets); err != nil {
+			return err
+		}
+		if tableRewrites, err = allocateTableRewrites(ctx, p, sqlDescs, opts); err != nil {
+			return err
+		}
+	}
 	description, err := restoreJobDescription(restoreStmt, from)
 	if err != nil {
 		return err
@@ -923,7 +1190,11 @@ func doRestorePlan(
 		}(),
 		Details: jobs.RestoreDetails{
 			TableRewrites: tableRewrites,
-			URIs:          from,
+			URIs:          defaultURIs,
+			LocalityURIs:  localityURIs,
+			EndTime:       endTime,
+			Encryption:    encryption,
+			FullCluster:   fullCluster,
 		},
 	})
 	res, restoreErr := restore(
@@ -942,7 +1213,14 @@ func doRestorePlan(
 		return restoreErr
 	}
 	// TODO(benesch): emit periodic progress updates.
-	resultsCh <- parser.Datums{
+	resultsCh <- restoreResultRow(job, res)
+	return nil
+}
+
+// restoreResultRow returns the row, matching restoreHeader, that reports the
+// result of a successful restore job.
+func restoreResultRow(job *jobs.Job, res roachpb.BulkOpSummary) parser.Datums {
+	return parser.Datums{
 		parser.NewDInt(parser.DInt(*job.ID())),
 		parser.NewDString(string(jobs.StatusSucceeded)),
 		parser.NewDFloat(parser.DFloat(1.0)),
@@ -951,7 +1229,6 @@ func doRestorePlan(
 		parser.NewDInt(parser.DInt(res.SystemRecords)),
 		parser.NewDInt(parser.DInt(res.DataSize)),
 	}
-	return nil
 }
 
 func restoreResumeHook(typ jobs.Type) func(ctx context.Context, job *jobs.Job) error {
@@ -962,10 +1239,18 @@ func restoreResumeHook(typ jobs.Type) func(ctx context.Context, job *jobs.Job) e
 	return func(ctx context.Context, job *jobs.Job) error {
 		details := job.Record.Details.(jobs.RestoreDetails)
 
-		backupDescs, err := loadBackupDescs(ctx, details.URIs)
+		backupDescs, err := loadBackupDescs(ctx, details.URIs, details.Encryption)
 		if err != nil {
 			return err
 		}
+		if err := setLocalityDirs(backupDescs, details.LocalityURIs); err != nil {
+			return err
+		}
+		if details.EndTime != (hlc.Timestamp{}) {
+			if backupDescs, err = backupsAsOf(backupDescs, details.EndTime); err != nil {
+				return err
+			}
+		}
 		lastBackupDesc := backupDescs[len(backupDescs)-1]
 
 		var sqlDescs []sqlbase.Descriptor
@@ -984,6 +1269,10 @@ func restoreResumeHook(typ jobs.Type) func(ctx context.Context, job *jobs.Job) e
 			details.TableRewrites,
 			job,
 		)
+		if err != nil && details.RevertTime != (hlc.Timestamp{}) {
+			// An IMPORT INTO must not leave its partially imported data behind.
+			err = revertImportInto(ctx, job.DB(), details.TableRewrites, details.RevertTime, err)
+		}
 		return err
 	}
 }
@@ -0,0 +1,272 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package sqlccl
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/pkg/errors"
+)
+
+// tempSystemDatabaseName is the name of the database that a full cluster
+// RESTORE restores the system tables of the backup into, before copying their
+// rows into the cluster's own system tables.
+const tempSystemDatabaseName = "crdb_temp_system"
+
+// systemTableRestoreFns copy the rows of each of the fullClusterSystemTableIDs,
+// restored into tempSystemDatabaseName, into the cluster's system table.
+var systemTableRestoreFns = map[sqlbase.ID]func(
+	ctx context.Context, ex sqlutil.InternalExecutor, txn *client.Txn, tableRewrites tableRewriteMap,
+) error{
+	keys.UsersTableID: copySystemTable(`UPSERT INTO system.users SELECT * FROM %s.users`),
+	keys.ZonesTableID: restoreZones,
+	// The cluster version is that of the cluster being restored into.
+	keys.SettingsTableID: copySystemTable(
+		`UPSERT INTO system.settings SELECT * FROM %s.settings WHERE name != 'version'`,
+	),
+	keys.UITableID: copySystemTable(`UPSERT INTO system.ui SELECT * FROM %s.ui`),
+	// Only the history of the jobs is restored: jobs that had not finished
+	// belong to the backed up cluster and must not be resumed by this one.
+	keys.JobsTableID: copySystemTable(
+		`INSERT INTO system.jobs SELECT * FROM %s.jobs ` +
+			`WHERE status IN ('succeeded', 'failed', 'canceled') ON CONFLICT (id) DO NOTHING`,
+	),
+}
+
+// copySystemTable returns a function that runs query, with the name of the
+// database the system tables are restored into substituted for its %s.
+func copySystemTable(
+	query string,
+) func(context.Context, sqlutil.InternalExecutor, *client.Txn, tableRewriteMap) error {
+	return func(
+		ctx context.Context, ex sqlutil.InternalExecutor, txn *client.Txn, _ tableRewriteMap,
+	) error {
+		_, err := ex.ExecuteStatementInTransaction(
+			ctx, "restore-system-table", txn, fmt.Sprintf(query, tempSystemDatabaseName),
+		)
+		return err
+	}
+}
+
+// restoreZones copies the zone configs of the backup, rewriting the IDs of the
+// databases and tables they apply to. Those of the system ranges, databases
+// and tables keep their IDs, and those of objects that were not backed up are
+// dropped.
+func restoreZones(
+	ctx context.Context, ex sqlutil.InternalExecutor, txn *client.Txn, tableRewrites tableRewriteMap,
+) error {
+	rows, err := ex.QueryRowsInTransaction(
+		ctx, "restore-zones", txn, fmt.Sprintf(`SELECT id, config FROM %s.zones`, tempSystemDatabaseName),
+	)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		id := sqlbase.ID(parser.MustBeDInt(row[0]))
+		if !sqlbase.IsReservedID(id) {
+			rewrite, ok := tableRewrites[id]
+			if !ok {
+				continue
+			}
+			id = rewrite.TableID
+		}
+		if _, err := ex.ExecuteStatementInTransaction(
+			ctx, "restore-zones", txn, `UPSERT INTO system.zones (id, config) VALUES ($1, $2)`,
+			int(id), row[1],
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectFullClusterDescriptors returns the descriptors restored by a RESTORE
+// without targets: those of the last backup, which must be a full cluster
+// backup, except for the system tables not in fullClusterSystemTableIDs.
+func selectFullClusterDescriptors(backupDescs []BackupDescriptor) ([]sqlbase.Descriptor, error) {
+	lastBackupDesc := backupDescs[len(backupDescs)-1]
+	if !lastBackupDesc.FullCluster {
+		return nil, errors.Errorf("RESTORE without targets requires a full cluster backup")
+	}
+	var sqlDescs []sqlbase.Descriptor
+	for _, desc := range lastBackupDesc.Descriptors {
+		if tableDesc := desc.GetTable(); tableDesc != nil &&
+			tableDesc.ParentID == keys.SystemDatabaseID && !isFullClusterSystemTable(tableDesc.ID) {
+			continue
+		}
+		sqlDescs = append(sqlDescs, desc)
+	}
+	return sqlDescs, nil
+}
+
+// allocateClusterRewrites is the equivalent of allocateTableRewrites for a full
+// cluster RESTORE. The databases in sqlDescs are created by the restore, so
+// they are given new IDs too, and the cluster being restored into must not
+// have any databases of its own. The system database is restored as
+// tempSystemDatabaseName.
+func allocateClusterRewrites(
+	ctx context.Context, p sql.PlanHookState, sqlDescs []sqlbase.Descriptor, opts map[string]string,
+) (tableRewriteMap, error) {
+	if _, ok := opts[restoreOptIntoDB]; ok {
+		return nil, errors.Errorf("option %q is not supported by a RESTORE without targets", restoreOptIntoDB)
+	}
+
+	db := p.ExecCfg().DB
+	if err := db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		existing, err := allSQLDescriptors(ctx, txn)
+		if err != nil {
+			return err
+		}
+		for _, desc := range existing {
+			if dbDesc := desc.GetDatabase(); dbDesc != nil && dbDesc.ID != keys.SystemDatabaseID {
+				return errors.Errorf(
+					"a RESTORE without targets requires an empty cluster, but database %q exists", dbDesc.Name,
+				)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	tableRewrites := make(tableRewriteMap)
+	var tables []*sqlbase.TableDescriptor
+	for _, desc := range sqlDescs {
+		if dbDesc := desc.GetDatabase(); dbDesc != nil {
+			newID, err := sql.GenerateUniqueDescID(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			tableRewrites[dbDesc.ID] = &jobs.RestoreDetails_TableRewrite{TableID: newID}
+		} else if tableDesc := desc.GetTable(); tableDesc != nil {
+			tables = append(tables, tableDesc)
+		}
+	}
+
+	// NB: As in allocateTableRewrites, the new IDs of the tables must be
+	// ordered like the old ones.
+	sort.Sort(sqlbase.TableDescriptors(tables))
+	for _, table := range tables {
+		parentRewrite, ok := tableRewrites[table.ParentID]
+		if !ok {
+			return nil, errors.Errorf("no database with ID %d in backup for table %q",
+				table.ParentID, table.Name)
+		}
+		newID, err := sql.GenerateUniqueDescID(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		tableRewrites[table.ID] = &jobs.RestoreDetails_TableRewrite{
+			TableID:  newID,
+			ParentID: parentRewrite.TableID,
+		}
+	}
+	return tableRewrites, nil
+}
+
+// rewriteDatabaseDescs mutates the databases restored by a full cluster
+// RESTORE to match the IDs in tableRewrites. The system database becomes
+// tempSystemDatabaseName, and it and the system tables restored into it get
+// the privileges of a new database and table, as those of the system ones are
+// not valid for any other ID.
+func rewriteDatabaseDescs(
+	databases []*sqlbase.DatabaseDescriptor,
+	tables []*sqlbase.TableDescriptor,
+	tableRewrites tableRewriteMap,
+) error {
+	for _, database := range databases {
+		rewrite, ok := tableRewrites[database.ID]
+		if !ok {
+			return errors.Errorf("missing rewrite for database %d", database.ID)
+		}
+		if database.ID == keys.SystemDatabaseID {
+			database.Name = tempSystemDatabaseName
+			database.Privileges = sqlbase.NewDefaultPrivilegeDescriptor()
+			for _, table := range tables {
+				if table.ParentID == rewrite.TableID {
+					table.Privileges = sqlbase.NewDefaultPrivilegeDescriptor()
+				}
+			}
+		}
+		database.ID = rewrite.TableID
+	}
+	return nil
+}
+
+// restoreSystemTables copies the rows of the system tables restored by a full
+// cluster RESTORE into the cluster's own system tables, and then drops
+// tempSystemDatabaseName. The tables it holds are dropped like those of a
+// DROP DATABASE, and their data is deleted once their GC TTL has passed.
+func restoreSystemTables(
+	ctx context.Context,
+	db *client.DB,
+	ex sqlutil.InternalExecutor,
+	tables []*sqlbase.TableDescriptor,
+	tableRewrites tableRewriteMap,
+) error {
+	tempSystemDatabase, ok := tableRewrites[keys.SystemDatabaseID]
+	if !ok {
+		return errors.Errorf("missing rewrite for the system database")
+	}
+	if err := db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		// Zone configs and cluster settings are part of the system config.
+		if err := txn.SetSystemConfigTrigger(); err != nil {
+			return err
+		}
+		for _, id := range fullClusterSystemTableIDs {
+			if _, ok := tableRewrites[id]; !ok {
+				continue
+			}
+			if err := systemTableRestoreFns[id](ctx, ex, txn, tableRewrites); err != nil {
+				return errors.Wrapf(err, "restoring system table %d", id)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		if err := txn.SetSystemConfigTrigger(); err != nil {
+			return err
+		}
+		b := txn.NewBatch()
+		dropTime := timeutil.Now().UnixNano()
+		for _, table := range tables {
+			if table.ParentID != tempSystemDatabase.TableID {
+				continue
+			}
+			tableDesc, err := sqlbase.GetTableDescFromID(ctx, txn, table.ID)
+			if err != nil {
+				return err
+			}
+			if err := tableDesc.SetUpVersion(); err != nil {
+				return err
+			}
+			tableDesc.State = sqlbase.TableDescriptor_DROP
+			tableDesc.DropTime = dropTime
+			b.Put(tableDesc.GetDescMetadataKey(), sqlbase.WrapDescriptor(tableDesc))
+			b.Del(tableDesc.GetNameMetadataKey())
+		}
+		b.Del(sqlbase.MakeDescMetadataKey(tempSystemDatabase.TableID))
+		b.Del(sqlbase.MakeNameMetadataKey(keys.RootNamespaceID, tempSystemDatabaseName))
+		return txn.Run(ctx, b)
+	})
+}
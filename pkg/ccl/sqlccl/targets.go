@@ -9,6 +9,7 @@
 package sqlccl
 
 import (
+	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
 	"github.com/pkg/errors"
@@ -128,3 +129,44 @@ func descriptorsMatchingTargets(
 
 	return ret, nil
 }
+
+// fullClusterSystemTableIDs are the IDs of the system tables that a full
+// cluster backup contains, and a full cluster RESTORE restores, along with the
+// databases and tables of the cluster.
+var fullClusterSystemTableIDs = []sqlbase.ID{
+	keys.UsersTableID,
+	keys.ZonesTableID,
+	keys.SettingsTableID,
+	keys.UITableID,
+	keys.JobsTableID,
+}
+
+func isFullClusterSystemTable(id sqlbase.ID) bool {
+	for _, systemTableID := range fullClusterSystemTableIDs {
+		if id == systemTableID {
+			return true
+		}
+	}
+	return false
+}
+
+// fullClusterDescriptors returns the descriptors that a full cluster backup
+// contains: every database, every public table of the user databases and the
+// system tables in fullClusterSystemTableIDs.
+func fullClusterDescriptors(descriptors []sqlbase.Descriptor) []sqlbase.Descriptor {
+	var ret []sqlbase.Descriptor
+	for _, desc := range descriptors {
+		if dbDesc := desc.GetDatabase(); dbDesc != nil {
+			ret = append(ret, desc)
+		} else if tableDesc := desc.GetTable(); tableDesc != nil {
+			if tableDesc.ParentID == keys.SystemDatabaseID {
+				if isFullClusterSystemTable(tableDesc.ID) {
+					ret = append(ret, desc)
+				}
+			} else if tableDesc.State == sqlbase.TableDescriptor_PUBLIC {
+				ret = append(ret, desc)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package storageccl
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Encrypted files are the encryptionPreamble, followed by a version byte, the
+// nonce and the AES-GCM sealed contents, which includes an authentication tag.
+var encryptionPreamble = []byte("encrypt")
+
+const (
+	encryptionVersionGCM = 1
+
+	encryptionKeySize   = 32 // AES-256
+	encryptionNonceSize = 12 // GCM standard nonce size
+	encryptionSaltSize  = 16
+
+	// encryptionKeyIterations is the number of PBKDF2 iterations used to
+	// derive a key from a passphrase, to slow down brute force attacks.
+	encryptionKeyIterations = 64000
+)
+
+// GenerateSalt returns a random salt for use with GenerateKey.
+func GenerateSalt() ([]byte, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// GenerateKey derives the key used to encrypt files from a passphrase and a
+// salt.
+func GenerateKey(passphrase, salt []byte) []byte {
+	return pbkdf2.Key(passphrase, salt, encryptionKeyIterations, encryptionKeySize, sha256.New)
+}
+
+// AppearsEncrypted returns true if the contents of a file look like they were
+// written by EncryptFile.
+func AppearsEncrypted(contents []byte) bool {
+	return bytes.HasPrefix(contents, encryptionPreamble)
+}
+
+// EncryptFile encrypts the contents of a file with key, which must have been
+// returned by GenerateKey.
+func EncryptFile(plaintext, key []byte) ([]byte, error) {
+	gcm, err := aesGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptionPreamble)+1+encryptionNonceSize)
+	copy(header, encryptionPreamble)
+	header[len(encryptionPreamble)] = encryptionVersionGCM
+	nonce := header[len(encryptionPreamble)+1:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// DecryptFile decrypts the contents of a file written by EncryptFile with the
+// same key.
+func DecryptFile(ciphertext, key []byte) ([]byte, error) {
+	if !AppearsEncrypted(ciphertext) {
+		return nil, errors.New("file does not appear to be encrypted")
+	}
+	ciphertext = ciphertext[len(encryptionPreamble):]
+	if len(ciphertext) < 1+encryptionNonceSize {
+		return nil, errors.New("invalid encryption header")
+	}
+	if version := ciphertext[0]; version != encryptionVersionGCM {
+		return nil, errors.Errorf("unexpected encryption version %d", version)
+	}
+	nonce := ciphertext[1 : 1+encryptionNonceSize]
+	ciphertext = ciphertext[1+encryptionNonceSize:]
+
+	gcm, err := aesGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(ciphertext[:0], nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt (is the passphrase correct?)")
+	}
+	return plaintext, nil
+}
+
+func aesGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != encryptionKeySize {
+		return nil, errors.Errorf("expected a %d byte key, got %d bytes", encryptionKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, encryptionNonceSize)
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package storageccl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	salt, err := GenerateSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := GenerateKey([]byte("passphrase"), salt)
+	plaintext := []byte("hello world")
+
+	ciphertext, err := EncryptFile(plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !AppearsEncrypted(ciphertext) {
+		t.Fatal("expected ciphertext to appear encrypted")
+	}
+	if AppearsEncrypted(plaintext) {
+		t.Fatal("expected plaintext to not appear encrypted")
+	}
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Fatal("expected ciphertext to not contain the plaintext")
+	}
+
+	t.Run("roundtrip", func(t *testing.T) {
+		decrypted, err := DecryptFile(ciphertext, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("expected %q got %q", plaintext, decrypted)
+		}
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		wrongKey := GenerateKey([]byte("wrong"), salt)
+		if _, err := DecryptFile(ciphertext, wrongKey); !testutils.IsError(err, "is the passphrase correct") {
+			t.Fatalf("expected decryption error, got %v", err)
+		}
+	})
+
+	t.Run("wrong salt", func(t *testing.T) {
+		otherSalt, err := GenerateSalt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrongKey := GenerateKey([]byte("passphrase"), otherSalt)
+		if _, err := DecryptFile(ciphertext, wrongKey); !testutils.IsError(err, "is the passphrase correct") {
+			t.Fatalf("expected decryption error, got %v", err)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := DecryptFile(tampered, key); !testutils.IsError(err, "is the passphrase correct") {
+			t.Fatalf("expected decryption error, got %v", err)
+		}
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		if _, err := DecryptFile(plaintext, key); !testutils.IsError(err, "does not appear to be encrypted") {
+			t.Fatalf("expected error, got %v", err)
+		}
+	})
+}
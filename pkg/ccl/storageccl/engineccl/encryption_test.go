@@ -0,0 +1,215 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/LICENSE
+
+package engineccl
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+// The layout of the plaintext prefix of encrypted files; see
+// c-deps/libroach/ccl/encrypted_env.cc.
+const (
+	encryptedPrefixLength = 4096
+	encryptedMagic        = "crdbenc1"
+)
+
+func openEncryptedRocksDB(t *testing.T, dir string, key []byte) *engine.RocksDB {
+	keyPath := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(keyPath, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	dataDir := filepath.Join(dir, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	e, err := engine.NewRocksDB(
+		engine.RocksDBConfig{
+			RocksDBSettings: cluster.MakeTestingClusterSettings().RocksDBSettings,
+			Dir:             dataDir,
+			ExtraOptions:    []byte(fmt.Sprintf("key=%s\n", keyPath)),
+		},
+		engine.RocksDBCache{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// decryptFile decrypts the raw contents of an encrypted file in the same
+// way as RocksDB's CTRCipherStream, using Go's AES implementation: block i
+// of the file (counting the prefix) is XORed with the encryption of the
+// file's IV with its first 8 bytes replaced by the little-endian encoding
+// of the initial counter plus i.
+func decryptFile(t *testing.T, key []byte, raw []byte) []byte {
+	if len(raw) < encryptedPrefixLength || string(raw[:len(encryptedMagic)]) != encryptedMagic {
+		t.Fatalf("file does not start with an encryption prefix: %q", raw)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := raw[16 : 16+aes.BlockSize]
+	counter := binary.LittleEndian.Uint64(raw[16+aes.BlockSize:])
+	data := raw[encryptedPrefixLength:]
+	plaintext := make([]byte, len(data))
+	var keyStream [aes.BlockSize]byte
+	for off := 0; off < len(data); off += aes.BlockSize {
+		copy(keyStream[:], iv)
+		blockIndex := uint64((encryptedPrefixLength + off) / aes.BlockSize)
+		binary.LittleEndian.PutUint64(keyStream[:8], counter+blockIndex)
+		block.Encrypt(keyStream[:], keyStream[:])
+		for i := off; i < off+aes.BlockSize && i < len(data); i++ {
+			plaintext[i] = data[i] ^ keyStream[i-off]
+		}
+	}
+	return plaintext
+}
+
+// TestEncryptionKnownAnswer verifies the AES implementation used by
+// encrypted stores against known answers. The ID of the active key is the
+// encryption of an all-zero block, which can be checked directly.
+func TestEncryptionKnownAnswer(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	mustDecodeHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// The encryption of the all-zero block under Go's implementation, which
+	// is itself checked against the NIST test vectors.
+	goKeyID := func(key []byte) string {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf [aes.BlockSize]byte
+		block.Encrypt(buf[:], buf[:])
+		return hex.EncodeToString(buf[:8])
+	}
+
+	testCases := []struct {
+		key   []byte
+		keyID string
+	}{
+		// The encryption of the all-zero block under the all-zero key.
+		{make([]byte, 16), "66e94bd4ef8a2c3b"},
+		{make([]byte, 24), "aae06992acbf52a3"},
+		{make([]byte, 32), "dc95c078a2408989"},
+		// The keys from FIPS-197 appendix C.
+		{mustDecodeHex("000102030405060708090a0b0c0d0e0f"), ""},
+		{mustDecodeHex("000102030405060708090a0b0c0d0e0f1011121314151617"), ""},
+		{mustDecodeHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"), ""},
+	}
+	for _, c := range testCases {
+		t.Run(fmt.Sprintf("%x", c.key), func(t *testing.T) {
+			dir, cleanupFn := testutils.TempDir(t)
+			defer cleanupFn()
+
+			expected := c.keyID
+			if expected == "" {
+				expected = goKeyID(c.key)
+			}
+			e := openEncryptedRocksDB(t, dir, c.key)
+			defer e.Close()
+			status, err := e.GetEncryptionStatus()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.ActiveKeyID != expected {
+				t.Fatalf("expected key ID %s, got %s", expected, status.ActiveKeyID)
+			}
+		})
+	}
+}
+
+// TestEncryptionOnDisk verifies that the files of an encrypted store
+// contain ciphertext which decrypts to the data written.
+func TestEncryptionOnDisk(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	dir, cleanupFn := testutils.TempDir(t)
+	defer cleanupFn()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	e := openEncryptedRocksDB(t, dir, key)
+	defer e.Close()
+
+	// Use a plaintext which isn't a multiple of the block size so that the
+	// partial final block is covered.
+	plaintext := bytes.Repeat([]byte("plaintext marker "), 1000)
+	filename := filepath.Join(dir, "data", "sideloaded")
+	if err := e.WriteFile(filename, plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := e.ReadFile(filename); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(read, plaintext) {
+		t.Fatalf("read back unexpected contents %q", read)
+	}
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != encryptedPrefixLength+len(plaintext) {
+		t.Fatalf("expected %d bytes on disk, got %d", encryptedPrefixLength+len(plaintext), len(raw))
+	}
+	if bytes.Contains(raw, []byte("plaintext marker")) {
+		t.Fatal("plaintext found on disk")
+	}
+	if decrypted := decryptFile(t, key, raw); !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("decrypted file does not match the plaintext: %q", decrypted)
+	}
+
+	// Values written to the store must not appear in any of its files, be it
+	// the WAL, sstables or the manifest.
+	value := []byte("secret value")
+	for i := 0; i < 100; i++ {
+		k := engine.MakeMVCCMetadataKey(roachpb.Key(fmt.Sprintf("key%03d", i)))
+		if err := e.Put(k, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := ioutil.ReadDir(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(dir, "data", info.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(raw, value) {
+			t.Errorf("%s contains plaintext", info.Name())
+		}
+	}
+}
@@ -49,7 +49,12 @@ type MVCCIncrementalIterator struct {
 	endTime   hlc.Timestamp
 	err       error
 	valid     bool
-	nextkey   bool
+	// positioned is true when the iterator is at a version within the time
+	// range, which must be moved off of before looking for the next one.
+	positioned bool
+	// keepTombstones is set for iterators over every revision, which need
+	// deletion tombstones even without a start time.
+	keepTombstones bool
 
 	// For allocation avoidance.
 	meta enginepb.MVCCMetadata
@@ -69,14 +74,26 @@ func NewMVCCIncrementalIterator(
 	}
 }
 
+// NewMVCCRevisionIterator is like NewMVCCIncrementalIterator, but creates an
+// iterator meant to visit every revision in the time range with Next. Unlike
+// with NewMVCCIncrementalIterator, deletion tombstones are returned even when
+// startTime is zero, as older revisions may be underneath them.
+func NewMVCCRevisionIterator(
+	e engine.Reader, startTime, endTime hlc.Timestamp,
+) *MVCCIncrementalIterator {
+	i := NewMVCCIncrementalIterator(e, startTime, endTime)
+	i.keepTombstones = true
+	return i
+}
+
 // Seek advances the iterator to the first key in the engine which is >= the
 // provided key.
 func (i *MVCCIncrementalIterator) Seek(startKey engine.MVCCKey) {
 	i.iter.Seek(startKey)
 	i.err = nil
 	i.valid = true
-	i.nextkey = false
-	i.NextKey()
+	i.positioned = false
+	i.advance(true /* nextKey */)
 }
 
 // Close frees up resources held by the iterator.
@@ -86,11 +103,10 @@ func (i *MVCCIncrementalIterator) Close() {
 
 // Next advances the iterator to the next key/value in the iteration. After this
 // call, Valid() will be true if the iterator was not positioned at the last
-// key.
+// key. Unlike NextKey, Next visits every version of a key within the time
+// range of the iterator.
 func (i *MVCCIncrementalIterator) Next() {
-	// TODO(dan): Implement Next. We'll need this for `RESTORE ... AS OF SYSTEM
-	// TIME`.
-	panic("unimplemented")
+	i.advance(false /* nextKey */)
 }
 
 // NextKey advances the iterator to the next MVCC key. This operation is
@@ -98,6 +114,13 @@ func (i *MVCCIncrementalIterator) Next() {
 // the next key if the iterator is currently located at the last version for a
 // key.
 func (i *MVCCIncrementalIterator) NextKey() {
+	i.advance(true /* nextKey */)
+}
+
+// advance moves the iterator off of its current position, to the next version
+// of the current key if nextKey is false or to the next key otherwise, and then
+// on to the first version within the time range of the iterator.
+func (i *MVCCIncrementalIterator) advance(nextKey bool) {
 	for {
 		if !i.valid {
 			return
@@ -108,9 +131,13 @@ func (i *MVCCIncrementalIterator) NextKey() {
 			return
 		}
 
-		if i.nextkey {
-			i.nextkey = false
-			i.iter.NextKey()
+		if i.positioned {
+			i.positioned = false
+			if nextKey {
+				i.iter.NextKey()
+			} else {
+				i.iter.Next()
+			}
 			continue
 		}
 
@@ -160,12 +187,12 @@ func (i *MVCCIncrementalIterator) NextKey() {
 		}
 
 		// Skip tombstone (len=0) records when startTime is zero (non-incremental).
-		if (i.startTime == hlc.Timestamp{}) && len(i.iter.UnsafeValue()) == 0 {
+		if !i.keepTombstones && (i.startTime == hlc.Timestamp{}) && len(i.iter.UnsafeValue()) == 0 {
 			i.iter.NextKey()
 			continue
 		}
 
-		i.nextkey = true
+		i.positioned = true
 		break
 	}
 }
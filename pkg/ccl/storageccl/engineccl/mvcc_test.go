@@ -52,12 +52,34 @@ func assertEqualKVs(
 	startKey, endKey roachpb.Key,
 	startTime, endTime hlc.Timestamp,
 	expected []engine.MVCCKeyValue,
+) func(*testing.T) {
+	return assertIteratedKVs(func() *MVCCIncrementalIterator {
+		return NewMVCCIncrementalIterator(e, startTime, endTime)
+	}, (*MVCCIncrementalIterator).NextKey, startKey, endKey, expected)
+}
+
+func assertEqualRevisions(
+	e engine.Engine,
+	startKey, endKey roachpb.Key,
+	startTime, endTime hlc.Timestamp,
+	expected []engine.MVCCKeyValue,
+) func(*testing.T) {
+	return assertIteratedKVs(func() *MVCCIncrementalIterator {
+		return NewMVCCRevisionIterator(e, startTime, endTime)
+	}, (*MVCCIncrementalIterator).Next, startKey, endKey, expected)
+}
+
+func assertIteratedKVs(
+	newIter func() *MVCCIncrementalIterator,
+	next func(*MVCCIncrementalIterator),
+	startKey, endKey roachpb.Key,
+	expected []engine.MVCCKeyValue,
 ) func(*testing.T) {
 	return func(t *testing.T) {
-		iter := NewMVCCIncrementalIterator(e, startTime, endTime)
+		iter := newIter()
 		defer iter.Close()
 		var kvs []engine.MVCCKeyValue
-		for iter.Seek(engine.MakeMVCCMetadataKey(startKey)); ; iter.NextKey() {
+		for iter.Seek(engine.MakeMVCCMetadataKey(startKey)); ; next(iter) {
 			if ok, err := iter.Valid(); err != nil {
 				t.Fatalf("unexpected error: %+v", err)
 			} else if !ok || iter.UnsafeKey().Key.Compare(endKey) >= 0 {
@@ -164,6 +186,15 @@ func TestMVCCIterateIncremental(t *testing.T) {
 	mustFlush()
 	t.Run("del", assertEqualKVs(e, keyMin, keyMax, ts1, tsMax, kvs(kv1_3Deleted, kv2_2_2)))
 	t.Run("no-tombstone", assertEqualKVs(e, keyMin, keyMax, hlc.Timestamp{}, tsMax, kvs(kv2_2_2)))
+	t.Run("del-no-start", assertEqualRevisions(e, keyMin, keyMax, hlc.Timestamp{}, ts3,
+		kvs(kv1_3Deleted, kv1_2_2, kv1_1_1, kv2_2_2)))
+
+	// Exercise iterating over all revisions.
+	t.Run("revs (0-∞]", assertEqualRevisions(e, keyMin, keyMax, tsMin, tsMax,
+		kvs(kv1_3Deleted, kv1_2_2, kv1_1_1, kv2_2_2)))
+	t.Run("revs (1-2]", assertEqualRevisions(e, keyMin, keyMax, ts1, ts2, kvs(kv1_2_2, kv2_2_2)))
+	t.Run("revs (1-∞]", assertEqualRevisions(e, keyMin, keyMax, ts1, tsMax,
+		kvs(kv1_3Deleted, kv1_2_2, kv2_2_2)))
 
 	// Exercise intent handling.
 	txn1ID := uuid.MakeV4()
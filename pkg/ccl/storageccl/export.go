@@ -122,13 +122,22 @@ func evalExport(
 		}
 	}
 
+	// When exporting all revisions, the revisions at or before the gc
+	// threshold may already have been garbage collected, so the exported
+	// history is only complete after it.
+	reply.StartTime = args.StartTime
+	if args.MVCCFilter == roachpb.MVCCFilter_All && reply.StartTime.Less(gcThreshold) {
+		reply.StartTime = gcThreshold
+	}
+
 	if err := exportRequestLimiter.beginLimitedRequest(ctx); err != nil {
 		return storage.EvalResult{}, err
 	}
 	defer exportRequestLimiter.endLimitedRequest()
 	log.Infof(ctx, "export [%s,%s)", args.Key, args.EndKey)
 
-	exportStore, err := MakeExportStorage(ctx, args.Storage)
+	storageConf, localityKV := exportStorageForLocality(args, cArgs.EvalCtx.Locality())
+	exportStore, err := MakeExportStorage(ctx, storageConf)
 	if err != nil {
 		return storage.EvalResult{}, err
 	}
@@ -143,9 +152,16 @@ func evalExport(
 	var rows rowCounter
 	// TODO(dan): Move all this iteration into cpp to avoid the cgo calls.
 	// TODO(dan): Consider checking ctx periodically during the MVCCIterate call.
-	iter := engineccl.NewMVCCIncrementalIterator(batch, args.StartTime, h.Timestamp)
+	var iter *engineccl.MVCCIncrementalIterator
+	next := (*engineccl.MVCCIncrementalIterator).NextKey
+	if args.MVCCFilter == roachpb.MVCCFilter_All {
+		iter = engineccl.NewMVCCRevisionIterator(batch, args.StartTime, h.Timestamp)
+		next = (*engineccl.MVCCIncrementalIterator).Next
+	} else {
+		iter = engineccl.NewMVCCIncrementalIterator(batch, args.StartTime, h.Timestamp)
+	}
 	defer iter.Close()
-	for iter.Seek(engine.MakeMVCCMetadataKey(args.Key)); ; iter.NextKey() {
+	for iter.Seek(engine.MakeMVCCMetadataKey(args.Key)); ; next(iter) {
 		ok, err := iter.Valid()
 		if err != nil {
 			// The error may be a WriteIntentError. In which case, returning it will
@@ -181,6 +197,13 @@ func evalExport(
 		return storage.EvalResult{}, err
 	}
 
+	if args.Encryption != nil {
+		sstContents, err = EncryptFile(sstContents, args.Encryption.Key)
+		if err != nil {
+			return storage.EvalResult{}, err
+		}
+	}
+
 	// Compute the checksum before we upload and remove the local file.
 	checksum, err := SHA512ChecksumData(sstContents)
 	if err != nil {
@@ -193,15 +216,32 @@ func evalExport(
 	}
 
 	reply.Files = []roachpb.ExportResponse_File{{
-		Span:     args.Span,
-		Path:     filename,
-		Exported: rows.BulkOpSummary,
-		Sha512:   checksum,
+		Span:       args.Span,
+		Path:       filename,
+		Exported:   rows.BulkOpSummary,
+		Sha512:     checksum,
+		LocalityKV: localityKV,
 	}}
 
 	return storage.EvalResult{}, nil
 }
 
+// exportStorageForLocality returns the storage that the export requested by
+// args is written to by a node in the given locality, along with its key in
+// args.StorageByLocalityKV. The most specific tier of the locality with a
+// storage wins, and a node without any exports into args.Storage.
+func exportStorageForLocality(
+	args *roachpb.ExportRequest, locality roachpb.Locality,
+) (roachpb.ExportStorage, string) {
+	for i := len(locality.Tiers) - 1; i >= 0; i-- {
+		localityKV := locality.Tiers[i].String()
+		if conf, ok := args.StorageByLocalityKV[localityKV]; ok && conf != nil {
+			return *conf, localityKV
+		}
+	}
+	return args.Storage, ""
+}
+
 // SHA512ChecksumData returns the SHA512 checksum of data.
 func SHA512ChecksumData(data []byte) ([]byte, error) {
 	h := sha512.New()
@@ -39,10 +39,13 @@ func TestExport(t *testing.T) {
 	sqlDB := sqlutils.MakeSQLRunner(t, tc.Conns[0])
 	kvDB := tc.Server(0).KVClient().(*client.DB)
 
-	exportAndSlurp := func(start hlc.Timestamp) (hlc.Timestamp, []string, []engine.MVCCKeyValue) {
+	exportAndSlurp := func(
+		start hlc.Timestamp, mvccFilter roachpb.MVCCFilter,
+	) (hlc.Timestamp, []string, []engine.MVCCKeyValue) {
 		req := &roachpb.ExportRequest{
-			Span:      roachpb.Span{Key: keys.UserTableDataMin, EndKey: keys.MaxKey},
-			StartTime: start,
+			Span:       roachpb.Span{Key: keys.UserTableDataMin, EndKey: keys.MaxKey},
+			StartTime:  start,
+			MVCCFilter: mvccFilter,
 			Storage: roachpb.ExportStorage{
 				Provider:  roachpb.ExportStorageProvider_LocalFile,
 				LocalFile: roachpb.ExportStorage_LocalFilePath{Path: dir},
@@ -85,7 +88,7 @@ func TestExport(t *testing.T) {
 	sqlDB.Exec(`CREATE DATABASE export`)
 	sqlDB.Exec(`CREATE TABLE export.export (id INT PRIMARY KEY)`)
 	sqlDB.Exec(`INSERT INTO export.export VALUES (1), (3)`)
-	ts1, paths1, kvs1 := exportAndSlurp(hlc.Timestamp{})
+	ts1, paths1, kvs1 := exportAndSlurp(hlc.Timestamp{}, roachpb.MVCCFilter_Latest)
 	if expected := 1; len(paths1) != expected {
 		t.Fatalf("expected %d files in export got %d", expected, len(paths1))
 	}
@@ -94,19 +97,19 @@ func TestExport(t *testing.T) {
 	}
 
 	// If nothing has changed, nothing should be exported.
-	ts2, paths2, _ := exportAndSlurp(ts1)
+	ts2, paths2, _ := exportAndSlurp(ts1, roachpb.MVCCFilter_Latest)
 	if expected := 0; len(paths2) != expected {
 		t.Fatalf("expected %d files in export got %d", expected, len(paths2))
 	}
 
 	sqlDB.Exec(`INSERT INTO export.export VALUES (2)`)
-	ts3, _, kvs3 := exportAndSlurp(ts2)
+	ts3, _, kvs3 := exportAndSlurp(ts2, roachpb.MVCCFilter_Latest)
 	if expected := 1; len(kvs3) != expected {
 		t.Fatalf("expected %d kvs in export got %d", expected, len(kvs3))
 	}
 
 	sqlDB.Exec(`DELETE FROM export.export WHERE id = 3`)
-	_, _, kvs4 := exportAndSlurp(ts3)
+	_, _, kvs4 := exportAndSlurp(ts3, roachpb.MVCCFilter_Latest)
 	if expected := 1; len(kvs4) != expected {
 		t.Fatalf("expected %d kvs in export got %d", expected, len(kvs4))
 	}
@@ -116,13 +119,23 @@ func TestExport(t *testing.T) {
 	}
 
 	sqlDB.Exec(`ALTER TABLE export.export SPLIT AT VALUES (2)`)
-	_, paths5, kvs5 := exportAndSlurp(hlc.Timestamp{})
+	_, paths5, kvs5 := exportAndSlurp(hlc.Timestamp{}, roachpb.MVCCFilter_Latest)
 	if expected := 2; len(paths5) != expected {
 		t.Fatalf("expected %d files in export got %d", expected, len(paths5))
 	}
 	if expected := 2; len(kvs5) != expected {
 		t.Fatalf("expected %d kvs in export got %d", expected, len(kvs5))
 	}
+
+	// Exporting all revisions includes the overwritten and deleted ones.
+	_, _, kvs6 := exportAndSlurp(hlc.Timestamp{}, roachpb.MVCCFilter_All)
+	if expected := 4; len(kvs6) != expected {
+		t.Fatalf("expected %d kvs in export got %d", expected, len(kvs6))
+	}
+	_, _, kvs7 := exportAndSlurp(ts2, roachpb.MVCCFilter_All)
+	if expected := 2; len(kvs7) != expected {
+		t.Fatalf("expected %d kvs in export got %d", expected, len(kvs7))
+	}
 }
 
 func TestExportGCThreshold(t *testing.T) {
@@ -142,3 +155,50 @@ func TestExportGCThreshold(t *testing.T) {
 		t.Fatalf(`expected "must be after replica GC threshold" error got: %+v`, pErr)
 	}
 }
+
+func TestExportStorageForLocality(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	storage := func(path string) roachpb.ExportStorage {
+		return roachpb.ExportStorage{
+			Provider:  roachpb.ExportStorageProvider_LocalFile,
+			LocalFile: roachpb.ExportStorage_LocalFilePath{Path: path},
+		}
+	}
+	east, eastA := storage("east"), storage("east-a")
+	args := &roachpb.ExportRequest{
+		Storage: storage("default"),
+		StorageByLocalityKV: map[string]*roachpb.ExportStorage{
+			"region=east": &east,
+			"zone=east-a": &eastA,
+		},
+	}
+
+	testCases := []struct {
+		locality   string
+		path       string
+		localityKV string
+	}{
+		{"", "default", ""},
+		{"region=west", "default", ""},
+		{"region=west,zone=east-c", "default", ""},
+		{"region=east", "east", "region=east"},
+		{"region=east,zone=east-b", "east", "region=east"},
+		{"region=east,zone=east-a", "east-a", "zone=east-a"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.locality, func(t *testing.T) {
+			var locality roachpb.Locality
+			if tc.locality != "" {
+				if err := locality.Set(tc.locality); err != nil {
+					t.Fatal(err)
+				}
+			}
+			conf, localityKV := exportStorageForLocality(args, locality)
+			if conf.LocalFile.Path != tc.path || localityKV != tc.localityKV {
+				t.Fatalf("expected %s (%q), got %s (%q)",
+					tc.path, tc.localityKV, conf.LocalFile.Path, localityKV)
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/storage"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/retry"
@@ -230,6 +231,13 @@ func evalImport(ctx context.Context, cArgs storage.CommandArgs) (*roachpb.Import
 			}
 		}
 
+		if args.Encryption != nil {
+			fileContents, err = DecryptFile(fileContents, args.Encryption.Key)
+			if err != nil {
+				return nil, errors.Wrapf(err, "decrypting %q", file.Path)
+			}
+		}
+
 		iter, err := engineccl.NewMemSSTIterator(fileContents)
 		if err != nil {
 			return nil, err
@@ -264,15 +272,40 @@ func evalImport(ctx context.Context, cArgs storage.CommandArgs) (*roachpb.Import
 	startKeyMVCC, endKeyMVCC := engine.MVCCKey{Key: args.DataSpan.Key}, engine.MVCCKey{Key: args.DataSpan.EndKey}
 	iter := engineccl.MakeMultiIterator(iters)
 	defer iter.Close()
-	var keyScratch, valueScratch []byte
-	for iter.Seek(startKeyMVCC); ; iter.NextKey() {
+	var keyScratch, valueScratch, lastKey []byte
+	advance := iter.NextKey
+	if args.DisallowShadowing {
+		// Visit every version, so that a key with more than one is caught.
+		advance = iter.Next
+	}
+	for iter.Seek(startKeyMVCC); ; advance() {
 		ok, err := iter.Valid()
 		if err != nil {
 			return nil, err
 		}
+		if args.EndTime != (hlc.Timestamp{}) {
+			// Skip any versions newer than EndTime, which leaves the iterator
+			// on the version of the key as of EndTime.
+			for ok && args.EndTime.Less(iter.UnsafeKey().Timestamp) {
+				iter.Next()
+				if ok, err = iter.Valid(); err != nil {
+					return nil, err
+				}
+			}
+		}
 		if !ok || !iter.UnsafeKey().Less(endKeyMVCC) {
 			break
 		}
+		if args.DisallowShadowing {
+			if iter.UnsafeKey().Key.Equal(lastKey) {
+				key, _, err := kr.RewriteKey(lastKey)
+				if err != nil {
+					return nil, err
+				}
+				return nil, errors.Errorf("duplicate key: %s", roachpb.Key(key))
+			}
+			lastKey = append(lastKey[:0], iter.UnsafeKey().Key...)
+		}
 		if len(iter.UnsafeValue()) == 0 {
 			// Value is deleted.
 			continue
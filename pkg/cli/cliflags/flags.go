@@ -412,6 +412,16 @@ memory that the store may consume, for example:
   --store=type=mem,size=20GiB
   --store=type=mem,size=90%
 
+</PRE>
+The "raft-log" field selects where the store keeps raft log entries: "rocksdb"
+(the default) stores them alongside all other data, while "separate" stores
+them in a dedicated append-only log in the store directory, which avoids
+compacting entries that are soon deleted. Existing entries are moved when a
+node is restarted with a different setting, for example:
+<PRE>
+
+  --store=path=/mnt/ssd01,raft-log=separate
+
 </PRE>
 Commas are forbidden in all values, since they are used to separate fields.
 Also, if you use equal signs in the file path to a store, you must use the
@@ -18,6 +18,7 @@ package cli
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"os"
 	"sort"
 	"strconv"
@@ -38,6 +39,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/encoding"
 	"github.com/cockroachdb/cockroach/pkg/util/envutil"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/uuid"
@@ -81,6 +83,8 @@ func openStore(cmd *cobra.Command, dir string, stopper *stop.Stopper) (*engine.R
 			RocksDBSettings: serverCfg.Settings.RocksDBSettings,
 			Dir:             dir,
 			MaxOpenFiles:    maxOpenFiles,
+			// Debug commands must not move raft log entries around.
+			RaftLogFormat: engine.RaftLogExisting,
 		},
 		cache,
 	)
@@ -422,7 +426,8 @@ var debugRaftLogCmd = &cobra.Command{
 	Use:   "raft-log [directory] [range id]",
 	Short: "print the raft log for a range",
 	Long: `
-Prints all log entries in a store for the given range.
+Prints all log entries in a store for the given range, whether they are stored
+in RocksDB or in the store's separate raft log.
 `,
 	RunE: MaybeDecorateGRPCError(runDebugRaftLog),
 }
@@ -492,7 +497,28 @@ func runDebugRaftLog(cmd *cobra.Command, args []string) error {
 	start := engine.MakeMVCCMetadataKey(keys.RaftLogPrefix(rangeID))
 	end := engine.MakeMVCCMetadataKey(keys.RaftLogPrefix(rangeID).PrefixEnd())
 
-	return db.Iterate(start, end, printRaftLogEntry)
+	if err := db.Iterate(start, end, printRaftLogEntry); err != nil {
+		return err
+	}
+	raftLog := db.RaftLog()
+	if raftLog == nil {
+		return nil
+	}
+	// Present the entries of the separate raft log as if they were stored
+	// under their raft log keys.
+	return raftLog.Iterate(rangeID, 0, math.MaxUint64, func(index uint64, ent []byte) (bool, error) {
+		var value roachpb.Value
+		value.SetBytes(ent)
+		meta := enginepb.MVCCMetadata{RawBytes: value.RawBytes}
+		metaBytes, err := protoutil.Marshal(&meta)
+		if err != nil {
+			return false, err
+		}
+		return printRaftLogEntry(engine.MVCCKeyValue{
+			Key:   engine.MakeMVCCMetadataKey(keys.RaftLogKey(rangeID, index)),
+			Value: metaBytes,
+		})
+	})
 }
 
 var debugGCCmd = &cobra.Command{
@@ -569,7 +595,7 @@ func runDebugGCCmd(cmd *cobra.Command, args []string) error {
 		snap := db.NewSnapshot()
 		defer snap.Close()
 		_, info, err := storage.RunGC(context.Background(), &desc, snap, hlc.Timestamp{WallTime: timeutil.Now().UnixNano()},
-			config.GCPolicy{TTLSeconds: 24 * 60 * 60 /* 1 day */}, func(_ hlc.Timestamp, _ *roachpb.Transaction, _ roachpb.PushTxnType) {
+			config.GCPolicy{TTLSeconds: 24 * 60 * 60 /* 1 day */}, hlc.Timestamp{} /* protected */, func(_ hlc.Timestamp, _ *roachpb.Transaction, _ roachpb.PushTxnType) {
 			}, func(_ []roachpb.Intent, _ storage.ResolveOptions) error { return nil })
 		if err != nil {
 			return err
@@ -153,6 +153,25 @@ func varFlag(f *pflag.FlagSet, value pflag.Value, flagInfo cliflags.FlagInfo) {
 	setFlagFromEnv(f, flagInfo)
 }
 
+// VarFlag is exported for use by CCL code which registers its own flags.
+func VarFlag(f *pflag.FlagSet, value pflag.Value, flagInfo cliflags.FlagInfo) {
+	varFlag(f, value, flagInfo)
+}
+
+// AddPersistentPreRunE adds fn as a persistent pre-run function of cmd, to be
+// run after any existing one. It is used by CCL code to process its flags.
+func AddPersistentPreRunE(cmd *cobra.Command, fn func(*cobra.Command, []string) error) {
+	wrapped := cmd.PersistentPreRunE
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if wrapped != nil {
+			if err := wrapped(cmd, args); err != nil {
+				return err
+			}
+		}
+		return fn(cmd, args)
+	}
+}
+
 func init() {
 	// Change the logging defaults for the main cockroach binary.
 	// The value is overridden after command-line parsing.
@@ -59,6 +59,10 @@ import (
 // The function takes a filename to write the profile to.
 var jemallocHeapDump func(string) error
 
+// StartCmd is exported so that CCL code can register additional flags and
+// hooks for the start command.
+var StartCmd = startCmd
+
 // startCmd starts a node by initializing the stores and joining
 // the cluster.
 var startCmd = &cobra.Command{
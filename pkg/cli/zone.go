@@ -422,6 +422,7 @@ The zone config format has the following YAML schema:
 
   num_replicas: <num>
   constraints: [comma-separated attribute list]
+  lease_preferences: [[comma-separated attribute list], ...]
   range_min_bytes: <size-in-bytes>
   range_max_bytes: <size-in-bytes>
   gc:
@@ -431,6 +432,7 @@ For example, to set the zone config for the system database, run:
 $ cockroach zone set system -f - << EOF
 num_replicas: 3
 constraints: [ssd, -mem]
+lease_preferences: [[+region=us-east1], [+region=us-west1]]
 EOF
 
 Note that the specified zone config is merged with the existing zone config for
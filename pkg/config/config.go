@@ -168,6 +168,26 @@ func (z ZoneConfig) Validate() error {
 	case 2:
 		return fmt.Errorf("at least 3 replicas are required for multi-replica configurations")
 	}
+	if z.NumVoters < 0 {
+		return fmt.Errorf("num_voters %d must not be negative", z.NumVoters)
+	}
+	if z.NumVoters > z.NumReplicas {
+		return fmt.Errorf("num_voters %d must not exceed num_replicas %d", z.NumVoters, z.NumReplicas)
+	}
+	if z.NumVoters == 2 {
+		return fmt.Errorf("at least 3 voters are required for multi-voter configurations")
+	}
+	for _, preference := range z.LeasePreferences {
+		if len(preference.Constraints) == 0 {
+			return fmt.Errorf("every lease preference must include at least one constraint")
+		}
+		for _, c := range preference.Constraints {
+			if c.Type == Constraint_POSITIVE {
+				return fmt.Errorf("lease preference constraint %s must be either required (e.g. '+%s') "+
+					"or prohibited (e.g. '-%s')", c, c, c)
+			}
+		}
+	}
 	if z.RangeMaxBytes < minRangeMaxBytes {
 		return fmt.Errorf("RangeMaxBytes %d less than minimum allowed %d",
 			z.RangeMaxBytes, minRangeMaxBytes)
@@ -179,6 +199,21 @@ func (z ZoneConfig) Validate() error {
 	return nil
 }
 
+// GetNumVoters returns the number of replicas which should participate in
+// the Raft quorum of ranges in the zone.
+func (z ZoneConfig) GetNumVoters() int32 {
+	if z.NumVoters == 0 {
+		return z.NumReplicas
+	}
+	return z.NumVoters
+}
+
+// GetNumNonVoters returns the number of non-voting replicas which ranges in
+// the zone should have.
+func (z ZoneConfig) GetNumNonVoters() int32 {
+	return z.NumReplicas - z.GetNumVoters()
+}
+
 // ObjectIDForKey returns the object ID (table or database) for 'key',
 // or (_, false) if not within the structured key space.
 func ObjectIDForKey(key roachpb.RKey) (uint32, bool) {
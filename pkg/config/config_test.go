@@ -465,6 +465,59 @@ func TestZoneConfigValidate(t *testing.T) {
 			},
 			"is greater than or equal to RangeMaxBytes",
 		},
+		{
+			config.ZoneConfig{
+				NumReplicas:   3,
+				NumVoters:     5,
+				RangeMaxBytes: config.DefaultZoneConfig().RangeMaxBytes,
+			},
+			"num_voters 5 must not exceed num_replicas 3",
+		},
+		{
+			config.ZoneConfig{
+				NumReplicas:   5,
+				NumVoters:     2,
+				RangeMaxBytes: config.DefaultZoneConfig().RangeMaxBytes,
+			},
+			"at least 3 voters are required for multi-voter configurations",
+		},
+		{
+			config.ZoneConfig{
+				NumReplicas:   5,
+				NumVoters:     3,
+				RangeMaxBytes: config.DefaultZoneConfig().RangeMaxBytes,
+			},
+			"",
+		},
+		{
+			config.ZoneConfig{
+				NumReplicas:      3,
+				RangeMaxBytes:    config.DefaultZoneConfig().RangeMaxBytes,
+				LeasePreferences: []config.Constraints{{}},
+			},
+			"every lease preference must include at least one constraint",
+		},
+		{
+			config.ZoneConfig{
+				NumReplicas:   3,
+				RangeMaxBytes: config.DefaultZoneConfig().RangeMaxBytes,
+				LeasePreferences: []config.Constraints{
+					{Constraints: []config.Constraint{{Type: config.Constraint_POSITIVE, Value: "ssd"}}},
+				},
+			},
+			"lease preference constraint ssd must be either required",
+		},
+		{
+			config.ZoneConfig{
+				NumReplicas:   3,
+				RangeMaxBytes: config.DefaultZoneConfig().RangeMaxBytes,
+				LeasePreferences: []config.Constraints{
+					{Constraints: []config.Constraint{{Type: config.Constraint_REQUIRED, Key: "region", Value: "us-east1"}}},
+					{Constraints: []config.Constraint{{Type: config.Constraint_PROHIBITED, Value: "mem"}}},
+				},
+			},
+			"",
+		},
 	}
 	for i, c := range testCases {
 		err := c.cfg.Validate()
@@ -504,6 +557,29 @@ func TestZoneConfigMarshalYAML(t *testing.T) {
 				},
 			},
 		},
+		LeasePreferences: []config.Constraints{
+			{
+				Constraints: []config.Constraint{
+					{
+						Type:  config.Constraint_REQUIRED,
+						Key:   "duck",
+						Value: "foo",
+					},
+				},
+			},
+			{
+				Constraints: []config.Constraint{
+					{
+						Type:  config.Constraint_REQUIRED,
+						Value: "foo",
+					},
+					{
+						Type:  config.Constraint_PROHIBITED,
+						Value: "bar",
+					},
+				},
+			},
+		},
 	}
 
 	expected := `range_min_bytes: 1
@@ -512,6 +588,7 @@ gc:
   ttlseconds: 1
 num_replicas: 1
 constraints: [foo, +duck=foo, -duck=foo]
+lease_preferences: [[+duck=foo], [+foo, -bar]]
 `
 
 	body, err := yaml.Marshal(original)
@@ -249,6 +249,9 @@ func (b *Batch) fillResults() error {
 			case *roachpb.LeaseInfoRequest:
 			case *roachpb.PushTxnRequest:
 			case *roachpb.QueryTxnRequest:
+			case *roachpb.QueryIntentRequest:
+			case *roachpb.RecoverTxnRequest:
+			case *roachpb.ClearRangeRequest:
 			case *roachpb.RangeLookupRequest:
 			case *roachpb.ResolveIntentRequest:
 			case *roachpb.ResolveIntentRangeRequest:
@@ -558,6 +561,24 @@ func (b *Batch) DelRange(s, e interface{}, returnKeys bool) {
 	b.initResult(1, 0, notRaw, nil)
 }
 
+// clearRange is only exported on DB.
+func (b *Batch) clearRange(s, e interface{}) {
+	begin, err := marshalKey(s)
+	if err != nil {
+		b.initResult(0, 0, notRaw, err)
+		return
+	}
+	end, err := marshalKey(e)
+	if err != nil {
+		b.initResult(0, 0, notRaw, err)
+		return
+	}
+	b.appendReqs(&roachpb.ClearRangeRequest{
+		Span: roachpb.Span{Key: begin, EndKey: end},
+	})
+	b.initResult(1, 0, notRaw, nil)
+}
+
 // adminMerge is only exported on DB. It is here for symmetry with the
 // other operations.
 func (b *Batch) adminMerge(key interface{}) {
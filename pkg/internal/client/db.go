@@ -354,6 +354,18 @@ func (db *DB) DelRange(ctx context.Context, begin, end interface{}) error {
 	return getOneErr(db.Run(ctx, b), b)
 }
 
+// ClearRange removes all data, including all MVCC versions, between
+// begin (inclusive) and end (exclusive). Unlike DelRange, the data is
+// deleted in place without writing tombstones, so the span must no
+// longer be in use and must not see any further reads or writes.
+//
+// key can be either a byte slice or a string.
+func (db *DB) ClearRange(ctx context.Context, begin, end interface{}) error {
+	b := &Batch{}
+	b.clearRange(begin, end)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
 // AdminMerge merges the range containing key and the subsequent
 // range. After the merge operation is complete, the range containing
 // key will contain all of the key/value pairs of the subsequent range
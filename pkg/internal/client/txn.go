@@ -85,6 +85,12 @@ type Txn struct {
 		// If Txn becomes single-threaded, then the point is moot and this can again
 		// go away.
 		previousIDs map[uuid.UUID]struct{}
+		// admissionClass is attached to every batch sent through the
+		// transaction. See SetAdmissionClass.
+		admissionClass roachpb.AdmissionClass
+		// source identifies the client the transaction runs on behalf of. It
+		// is attached to every batch sent through the transaction.
+		source roachpb.RequestSource
 		// commandCount indicates how many requests have been sent through
 		// this transaction. Reset on retryable txn errors.
 		// TODO(andrei): This is broken for DistSQL, which doesn't account for the
@@ -199,6 +205,31 @@ func (txn *Txn) UserPriority() roachpb.UserPriority {
 	return txn.mu.UserPriority
 }
 
+// SetRequestSource sets the client on whose behalf the transaction's batches
+// are sent, which determines the request quotas they are charged against.
+func (txn *Txn) SetRequestSource(source roachpb.RequestSource) {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	txn.mu.source = source
+}
+
+// RequestSource returns the client on whose behalf the transaction's batches
+// are sent.
+func (txn *Txn) RequestSource() roachpb.RequestSource {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	return txn.mu.source
+}
+
+// SetAdmissionClass sets the class of work the transaction's batches belong
+// to. Stores hold back the writes of low-priority and bulk work while they're
+// falling behind.
+func (txn *Txn) SetAdmissionClass(class roachpb.AdmissionClass) {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	txn.mu.admissionClass = class
+}
+
 // SetDebugName sets the debug name associated with the transaction which will
 // appear in log files and the web UI.
 func (txn *Txn) SetDebugName(name string) {
@@ -816,7 +847,6 @@ func (txn *Txn) Send(
 	if lastIndex < 0 {
 		return nil, nil
 	}
-
 	firstWriteIdx, pErr := firstWriteIndex(ba)
 	if pErr != nil {
 		return nil, pErr
@@ -841,6 +871,10 @@ func (txn *Txn) Send(
 		if txn.mu.UserPriority != 0 {
 			ba.UserPriority = txn.mu.UserPriority
 		}
+		if txn.mu.admissionClass != roachpb.AdmissionClass_FOREGROUND {
+			ba.AdmissionClass = txn.mu.admissionClass
+		}
+		ba.Source = txn.mu.source
 
 		if !txn.mu.active {
 			user := roachpb.MakePriority(ba.UserPriority)
@@ -737,6 +737,37 @@ func TestSetPriority(t *testing.T) {
 	}
 }
 
+// TestSetAdmissionClass verifies that the admission class of a transaction is
+// attached to all of its batches.
+func TestSetAdmissionClass(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	clock := hlc.NewClock(hlc.UnixNano, 0)
+	var expected roachpb.AdmissionClass
+	db := NewDB(newTestSender(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			if ba.AdmissionClass != expected {
+				return nil, roachpb.NewErrorf("expected admission class %s, got %s",
+					expected, ba.AdmissionClass)
+			}
+			return ba.CreateReply(), nil
+		}), clock)
+
+	for _, class := range []roachpb.AdmissionClass{
+		roachpb.AdmissionClass_FOREGROUND, roachpb.AdmissionClass_LOW_PRIORITY,
+	} {
+		expected = class
+		txn := NewTxn(db)
+		txn.SetAdmissionClass(class)
+		if err := txn.Put(context.Background(), "a", "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Commit(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 // Tests that a retryable error for an inner txn doesn't cause the outer txn to
 // be retried.
 func TestWrongTxnRetry(t *testing.T) {
@@ -90,6 +90,9 @@ var (
 	localStoreLastUpSuffix = []byte("uptm")
 	// Windows-specific sync key. See `db.cc:DBSyncWAL` for details.
 	localStoreSyncSuffix = []byte("sync")
+	// localStoreSnapshotIngestionSuffix stores, for each range, the SSTables
+	// of a snapshot which are being ingested by the store.
+	localStoreSnapshotIngestionSuffix = []byte("snpi")
 
 	// LocalRangeIDPrefix is the prefix identifying per-range data
 	// indexed by Range ID. The Range ID is appended to this prefix,
@@ -177,6 +180,14 @@ var (
 	LocalTransactionSuffix = roachpb.RKey("txn-")
 	// LocalQueueLastProcessedSuffix is the suffix for replica queue state keys.
 	LocalQueueLastProcessedSuffix = roachpb.RKey("qlpt")
+	// LocalRangeProtectedTSCheckSuffix is the suffix for the key which jobs
+	// read to order the verification of their protected timestamps against
+	// the range's GC requests. The key is never written.
+	LocalRangeProtectedTSCheckSuffix = roachpb.RKey("rpts")
+	// LocalRangeInconsistentReplicaSuffix is the suffix for keys marking
+	// replicas which a consistency check found to disagree with a majority
+	// of the range. The detail is the replica ID.
+	LocalRangeInconsistentReplicaSuffix = roachpb.RKey("rinc")
 
 	// Meta1Prefix is the first level of key addressing. It is selected such that
 	// all range addressing records sort before any system tables which they
@@ -294,6 +305,7 @@ const (
 	UsersTableID      = 4
 	ZonesTableID      = 5
 	SettingsTableID   = 6
+	QuotasTableID     = 7
 
 	// Reserved IDs for other system tables. Note that some of these IDs refer
 	// to "Ranges" instead of a Table - these IDs are needed to store custom
@@ -308,4 +320,6 @@ const (
 	SystemRangesID     = 17
 	TimeseriesRangesID = 18
 	WebSessionsTableID = 19
+	ProtectedTSTableID = 20
+	SchedulesTableID   = 21
 )
@@ -65,6 +65,30 @@ func StoreSyncKey() roachpb.Key {
 	return MakeStoreKey(localStoreSyncSuffix, nil)
 }
 
+// StoreSnapshotIngestionKey returns a store-local key marking the SSTables of
+// a snapshot of the given range which are being ingested.
+func StoreSnapshotIngestionKey(rangeID roachpb.RangeID) roachpb.Key {
+	return MakeStoreKey(localStoreSnapshotIngestionSuffix,
+		encoding.EncodeUvarintAscending(nil, uint64(rangeID)))
+}
+
+// StoreSnapshotIngestionKeyPrefix returns the prefix of all snapshot ingestion
+// markers.
+func StoreSnapshotIngestionKeyPrefix() roachpb.Key {
+	return MakeStoreKey(localStoreSnapshotIngestionSuffix, nil)
+}
+
+// DecodeStoreSnapshotIngestionKey returns the range ID of a snapshot
+// ingestion marker key.
+func DecodeStoreSnapshotIngestionKey(key roachpb.Key) (roachpb.RangeID, error) {
+	prefix := StoreSnapshotIngestionKeyPrefix()
+	if !bytes.HasPrefix(key, prefix) {
+		return 0, errors.Errorf("key %s does not have %s prefix", key, prefix)
+	}
+	_, rangeID, err := encoding.DecodeUvarintAscending(key[len(prefix):])
+	return roachpb.RangeID(rangeID), err
+}
+
 // NodeLivenessKey returns the key for the node liveness record.
 func NodeLivenessKey(nodeID roachpb.NodeID) roachpb.Key {
 	key := make(roachpb.Key, 0, len(NodeLivenessPrefix)+9)
@@ -345,6 +369,25 @@ func QueueLastProcessedKey(key roachpb.RKey, queue string) roachpb.Key {
 	return MakeRangeKey(key, LocalQueueLastProcessedSuffix, roachpb.RKey(queue))
 }
 
+// RangeProtectedTSCheckKey returns a range-local key which is read, but never
+// written, to order protected timestamp verification with GC requests.
+func RangeProtectedTSCheckKey(key roachpb.RKey) roachpb.Key {
+	return MakeRangeKey(key, LocalRangeProtectedTSCheckSuffix, nil)
+}
+
+// RangeInconsistentReplicaKey returns a range-local key marking the given
+// replica of the range starting at key as inconsistent.
+func RangeInconsistentReplicaKey(key roachpb.RKey, replicaID roachpb.ReplicaID) roachpb.Key {
+	return MakeRangeKey(key, LocalRangeInconsistentReplicaSuffix,
+		encoding.EncodeUvarintAscending(nil, uint64(replicaID)))
+}
+
+// RangeInconsistentReplicaPrefix returns the prefix of the
+// RangeInconsistentReplicaKeys of the range starting at key.
+func RangeInconsistentReplicaPrefix(key roachpb.RKey) roachpb.Key {
+	return MakeRangeKey(key, LocalRangeInconsistentReplicaSuffix, nil)
+}
+
 // IsLocal performs a cheap check that returns true iff a range-local key is
 // passed, that is, a key for which `Addr` would return a non-identical RKey
 // (or a decoding error).
@@ -170,6 +170,8 @@ var (
 		{name: "RangeDescriptor", suffix: LocalRangeDescriptorSuffix, atEnd: true},
 		{name: "Transaction", suffix: LocalTransactionSuffix, atEnd: false},
 		{name: "QueueLastProcessed", suffix: LocalQueueLastProcessedSuffix, atEnd: false},
+		{name: "ProtectedTSCheck", suffix: LocalRangeProtectedTSCheckSuffix, atEnd: true},
+		{name: "InconsistentReplica", suffix: LocalRangeInconsistentReplicaSuffix, atEnd: false},
 	}
 )
 
@@ -181,6 +183,7 @@ var constSubKeyDict = []struct {
 	{"/gossipBootstrap", localStoreGossipSuffix},
 	{"/clusterVersion", localStoreClusterVersionSuffix},
 	{"/sync", localStoreSyncSuffix},
+	{"/snapshotIngestion", localStoreSnapshotIngestionSuffix},
 }
 
 func localStoreKeyPrint(key roachpb.Key) string {
@@ -386,6 +389,12 @@ func localRangeKeyPrint(key roachpb.Key) string {
 						return fmt.Sprintf("/%q/err:%v", key, err)
 					}
 					fmt.Fprintf(&buf, "/%q", txnID)
+				} else if bytes.Equal(s.suffix, LocalRangeInconsistentReplicaSuffix) {
+					_, replicaID, err := encoding.DecodeUvarintAscending(key[(begin + len(s.suffix)):])
+					if err != nil {
+						return fmt.Sprintf("/%q/err:%v", key, err)
+					}
+					fmt.Fprintf(&buf, "/%d", replicaID)
 				} else {
 					id := key[(begin + len(s.suffix)):]
 					fmt.Fprintf(&buf, "/%q", []byte(id))
@@ -533,6 +542,8 @@ func prettyPrintInternal(key roachpb.Key, quoteRawKeys bool) string {
 //			[key]/RangeDescriptor                        "\x01k"+[key]+"rdsc"
 //			[key]/Transaction/[id]	                     "\x01k"+[key]+"txn-"+[txn-id]
 //			[key]/QueueLastProcessed/[queue]             "\x01k"+[key]+"qlpt"+[queue]
+//			[key]/ProtectedTSCheck                       "\x01k"+[key]+"rpts"
+//			[key]/InconsistentReplica/[replicaid]        "\x01k"+[key]+"rinc"+[replicaid]
 // /Local/Max                                        "\x02"
 //
 // /Meta1/[key]                                      "\x02"+[key]
@@ -70,6 +70,8 @@ func TestPrettyPrint(t *testing.T) {
 		{RangeDescriptorKey(roachpb.RKey(MakeTablePrefix(42))), `/Local/Range/Table/42/RangeDescriptor`},
 		{TransactionKey(roachpb.Key(MakeTablePrefix(42)), txnID), fmt.Sprintf(`/Local/Range/Table/42/Transaction/%q`, txnID)},
 		{QueueLastProcessedKey(roachpb.RKey(MakeTablePrefix(42)), "foo"), `/Local/Range/Table/42/QueueLastProcessed/"foo"`},
+		{RangeProtectedTSCheckKey(roachpb.RKey(MakeTablePrefix(42))), `/Local/Range/Table/42/ProtectedTSCheck`},
+		{RangeInconsistentReplicaKey(roachpb.RKey(MakeTablePrefix(42)), 3), `/Local/Range/Table/42/InconsistentReplica/3`},
 
 		{LocalMax, `/Meta1/""`}, // LocalMax == Meta1Prefix
 
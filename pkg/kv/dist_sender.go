@@ -25,6 +25,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
 	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv/requestquota"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
@@ -136,6 +137,11 @@ type DistSender struct {
 	rpcRetryOptions  retry.Options
 	asyncSenderSem   chan struct{}
 	asyncSenderCount int32
+	// requestQuotas charges batches sent on behalf of SQL clients against
+	// their request quotas, and marks them as charged so that the stores
+	// they're sent to don't charge them again. It is nil if no quotas are
+	// enforced.
+	requestQuotas *requestquota.Quotas
 }
 
 var _ client.Sender = &DistSender{}
@@ -162,6 +168,9 @@ type DistSenderConfig struct {
 	// splitting batches into multiple requests when they span ranges.
 	// TODO(spencer): This is per-process. We should add a per-batch limit.
 	SenderConcurrency int32
+	// RequestQuotas, if set, holds the request quotas enforced for batches
+	// sent through this DistSender.
+	RequestQuotas *requestquota.Quotas
 
 	TestingKnobs DistSenderTestingKnobs
 }
@@ -212,6 +221,7 @@ func NewDistSender(cfg DistSenderConfig, g *gossip.Gossip) *DistSender {
 		lcSize = defaultLeaseHolderCacheSize
 	}
 	ds.leaseHolderCache = NewLeaseHolderCache(int(lcSize))
+	ds.requestQuotas = cfg.RequestQuotas
 	if cfg.RangeLookupMaxRanges <= 0 {
 		ds.rangeLookupMaxRanges = defaultRangeLookupMaxRanges
 	}
@@ -585,6 +595,13 @@ func (ds *DistSender) Send(
 	ctx, cleanup := tracing.EnsureContext(ctx, ds.AmbientContext.Tracer, "dist sender")
 	defer cleanup()
 
+	if ds.requestQuotas != nil {
+		if err := ds.requestQuotas.Admit(ctx, ba); err != nil {
+			return nil, roachpb.NewError(err)
+		}
+		ba.Source.Charged = true
+	}
+
 	var rplChunks []*roachpb.BatchResponse
 	parts := ba.Split(false /* don't split ET */)
 	if len(parts) > 1 && ba.MaxSpanRequestKeys != 0 {
@@ -750,10 +767,14 @@ func (ds *DistSender) divideAndSendBatchToRanges(
 			}
 			// If the request is more than but ends with EndTransaction, we
 			// want the caller to come again with the EndTransaction in an
-			// extra call.
-			if l := len(ba.Requests) - 1; l > 0 && ba.Requests[l].GetInner().Method() == roachpb.EndTransaction {
-				responseCh <- response{pErr: errNo1PCTxn}
-				return
+			// extra call. The exception is a parallel commit, which stages
+			// the transaction record concurrently with its final writes.
+			if l := len(ba.Requests) - 1; l > 0 {
+				if et, ok := ba.Requests[l].GetInner().(*roachpb.EndTransactionRequest); ok &&
+					len(et.InFlightWrites) == 0 {
+					responseCh <- response{pErr: errNo1PCTxn}
+					return
+				}
 			}
 		}
 
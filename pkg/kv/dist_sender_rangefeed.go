@@ -0,0 +1,253 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kv
+
+import (
+	"io"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/retry"
+)
+
+// RangeFeed divides a range feed request on range boundaries and establishes
+// a range feed with the lease holder of each of the resulting ranges,
+// re-establishing them as ranges split and leases move. Events from all of
+// the ranges are sent on the supplied channel. Feeds are resumed from the
+// last resolved timestamp received for their span, so values may be
+// delivered more than once, but never after a checkpoint covering them.
+//
+// RangeFeed runs until the context is canceled or a range feed fails with a
+// non-retriable error, which is returned.
+func (ds *DistSender) RangeFeed(
+	ctx context.Context, args *roachpb.RangeFeedRequest, eventCh chan<- *roachpb.RangeFeedEvent,
+) *roachpb.Error {
+	ctx = ds.AnnotateCtx(ctx)
+	start, err := keys.Addr(args.Span.Key)
+	if err != nil {
+		return roachpb.NewError(err)
+	}
+	end, err := keys.AddrUpperBound(args.Span.EndKey)
+	if err != nil {
+		return roachpb.NewError(err)
+	}
+	rs := roachpb.RSpan{Key: start, EndKey: end}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g := &rangeFeedGroup{errCh: make(chan *roachpb.Error, 1)}
+	ds.divideAndStartRangeFeeds(ctx, g, rs, args.Timestamp, eventCh)
+
+	var pErr *roachpb.Error
+	select {
+	case pErr = <-g.errCh:
+	case <-ctx.Done():
+		pErr = roachpb.NewError(ctx.Err())
+	}
+	cancel()
+	g.wg.Wait()
+	return pErr
+}
+
+// rangeFeedGroup tracks the per-range feeds started on behalf of a call to
+// DistSender.RangeFeed.
+type rangeFeedGroup struct {
+	wg sync.WaitGroup
+	// errCh receives the first error encountered by any of the feeds.
+	errCh chan *roachpb.Error
+}
+
+func (g *rangeFeedGroup) fail(pErr *roachpb.Error) {
+	select {
+	case g.errCh <- pErr:
+	default:
+	}
+}
+
+// divideAndStartRangeFeeds starts a range feed for each of the ranges
+// overlapping the span.
+func (ds *DistSender) divideAndStartRangeFeeds(
+	ctx context.Context,
+	g *rangeFeedGroup,
+	rs roachpb.RSpan,
+	ts hlc.Timestamp,
+	eventCh chan<- *roachpb.RangeFeedEvent,
+) {
+	ri := NewRangeIterator(ds)
+	for ri.Seek(ctx, rs.Key, Ascending); ri.Valid(); ri.Next(ctx) {
+		desc := ri.Desc()
+		partialRS, err := rs.Intersect(desc)
+		if err != nil {
+			g.fail(roachpb.NewError(err))
+			return
+		}
+		token := ri.Token()
+		g.wg.Add(1)
+		if err := ds.rpcContext.Stopper.RunAsyncTask(
+			ctx, "kv.DistSender: range feed", func(ctx context.Context) {
+				defer g.wg.Done()
+				ds.partialRangeFeed(ctx, g, partialRS, ts, desc, token, eventCh)
+			},
+		); err != nil {
+			g.wg.Done()
+			g.fail(roachpb.NewError(err))
+			return
+		}
+		if !ri.NeedAnother(rs) {
+			return
+		}
+	}
+	g.fail(ri.Error())
+}
+
+// partialRangeFeed establishes a range feed for the span, which is contained
+// in the range described by desc, and retries it until it fails with a
+// non-retriable error. If the range turns out to have split, the feed is
+// divided and restarted on the new ranges.
+func (ds *DistSender) partialRangeFeed(
+	ctx context.Context,
+	g *rangeFeedGroup,
+	rs roachpb.RSpan,
+	ts hlc.Timestamp,
+	desc *roachpb.RangeDescriptor,
+	evictToken *EvictionToken,
+	eventCh chan<- *roachpb.RangeFeedEvent,
+) {
+	span := roachpb.Span{Key: rs.Key.AsRawKey(), EndKey: rs.EndKey.AsRawKey()}
+	var err error
+	for r := retry.StartWithCtx(ctx, ds.rpcRetryOptions); r.Next(); {
+		if desc == nil {
+			desc, evictToken, err = ds.getDescriptor(ctx, rs.Key, nil, false /* useReverseScan */)
+			if err != nil {
+				log.ErrEventf(ctx, "range descriptor re-lookup failed: %s", err)
+				continue
+			}
+		}
+
+		resolved, pErr := ds.singleRangeFeed(ctx, span, ts, desc, eventCh)
+		if ts.Less(resolved) {
+			// The feed made progress, so whatever went wrong is not a
+			// persistent condition.
+			ts = resolved
+			r.Reset()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.VEventf(ctx, 1, "range feed on %s ended: %s", span, pErr)
+
+		switch tErr := pErr.GetDetail().(type) {
+		case *roachpb.SendError, *roachpb.RangeNotFoundError:
+			if err := evictToken.Evict(ctx); err != nil {
+				g.fail(roachpb.NewError(err))
+				return
+			}
+			desc = nil
+		case *roachpb.RangeKeyMismatchError:
+			// Likely a split; re-divide the span on the new range boundaries.
+			if err := evictToken.Evict(ctx); err != nil {
+				g.fail(roachpb.NewError(err))
+				return
+			}
+			ds.divideAndStartRangeFeeds(ctx, g, rs, ts, eventCh)
+			return
+		case *roachpb.NotLeaseHolderError:
+			if tErr.LeaseHolder != nil {
+				ds.leaseHolderCache.Update(ctx, tErr.RangeID, *tErr.LeaseHolder)
+			} else {
+				ds.leaseHolderCache.Update(ctx, tErr.RangeID, roachpb.ReplicaDescriptor{})
+			}
+		case *roachpb.NodeUnavailableError:
+			// The replica's node is shutting down.
+		case *roachpb.RangeFeedRetryError:
+			// The feed was disconnected for falling behind; resume it from
+			// the last resolved timestamp.
+		default:
+			g.fail(pErr)
+			return
+		}
+	}
+	if pErr := ds.deduceRetryEarlyExitError(ctx); pErr != nil {
+		g.fail(pErr)
+	}
+}
+
+// singleRangeFeed establishes a range feed with the range described by desc,
+// trying its lease holder first, and forwards the feed's events until it
+// fails. It returns the latest resolved timestamp received, from which the
+// feed can be resumed.
+func (ds *DistSender) singleRangeFeed(
+	ctx context.Context,
+	span roachpb.Span,
+	ts hlc.Timestamp,
+	desc *roachpb.RangeDescriptor,
+	eventCh chan<- *roachpb.RangeFeedEvent,
+) (hlc.Timestamp, *roachpb.Error) {
+	replicas := NewReplicaSlice(ds.gossip, desc)
+	replicas.OptimizeReplicaOrder(ds.getNodeDescriptor())
+	if leaseHolder, ok := ds.leaseHolderCache.Lookup(ctx, desc.RangeID); ok {
+		if i := replicas.FindReplica(leaseHolder.StoreID); i >= 0 {
+			replicas.MoveToFront(i)
+		}
+	}
+
+	for _, replica := range replicas {
+		args := roachpb.RangeFeedRequest{Span: span}
+		args.RangeID = desc.RangeID
+		args.Replica = replica.ReplicaDescriptor
+		args.Timestamp = ts
+
+		conn, err := ds.rpcContext.GRPCDial(replica.NodeDesc.Address.String())
+		if err != nil {
+			log.VEventf(ctx, 2, "failed to dial %s: %s", replica.NodeDesc.Address, err)
+			continue
+		}
+		stream, err := roachpb.NewInternalClient(conn).RangeFeed(ctx, &args)
+		if err != nil {
+			log.VEventf(ctx, 2, "failed to start range feed on %s: %s", replica.NodeDesc.Address, err)
+			continue
+		}
+		for {
+			event, err := stream.Recv()
+			if err == io.EOF {
+				return ts, roachpb.NewError(roachpb.NewSendError("range feed closed by server"))
+			} else if err != nil {
+				if ctx.Err() != nil {
+					return ts, roachpb.NewError(ctx.Err())
+				}
+				log.VEventf(ctx, 2, "range feed on %s failed: %s", replica.NodeDesc.Address, err)
+				break
+			}
+			switch t := event.GetValue().(type) {
+			case *roachpb.RangeFeedCheckpoint:
+				ts.Forward(t.ResolvedTS)
+			case *roachpb.RangeFeedError:
+				pErr := t.Error
+				return ts, &pErr
+			}
+			select {
+			case eventCh <- event:
+			case <-ctx.Done():
+				return ts, roachpb.NewError(ctx.Err())
+			}
+		}
+	}
+	return ts, roachpb.NewError(roachpb.NewSendError("failed to establish range feed with any replica"))
+}
@@ -0,0 +1,269 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package requestquota implements request quotas, which limit the rate at
+// which batches sent on behalf of a SQL user or application are evaluated.
+package requestquota
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// The kinds of clients a request quota can be configured for.
+const (
+	KindUser        = "user"
+	KindApplication = "application"
+)
+
+// requestUnitBytes is the amount of write payload charged as one request
+// unit.
+const requestUnitBytes = 1 << 10
+
+// Units returns the number of request units the batch is charged: one per
+// request, plus one per KiB of payload for writes.
+func Units(ba roachpb.BatchRequest) int64 {
+	units := int64(len(ba.Requests))
+	for _, union := range ba.Requests {
+		if req := union.GetInner(); !roachpb.IsReadOnly(req) {
+			units += int64(req.Size()) / requestUnitBytes
+		}
+	}
+	return units
+}
+
+// Key identifies the clients a request quota applies to: either a SQL user
+// or an application_name.
+type Key struct {
+	Kind string
+	Name string
+}
+
+// Config is the allotment of a request quota.
+type Config struct {
+	// UnitsPerSecond is the rate at which request units are allotted.
+	UnitsPerSecond int64
+	// Burst is the number of request units that can be consumed at once
+	// after a period of inactivity.
+	Burst int64
+}
+
+// nodeShare returns the part of a cluster-wide quota which is enforced by
+// each of numNodes nodes. Every share is at least one unit, so that batches
+// are never held back indefinitely.
+func (c Config) nodeShare(numNodes int) Config {
+	if numNodes <= 1 {
+		return c
+	}
+	share := Config{
+		UnitsPerSecond: c.UnitsPerSecond / int64(numNodes),
+		Burst:          c.Burst / int64(numNodes),
+	}
+	if share.UnitsPerSecond < 1 {
+		share.UnitsPerSecond = 1
+	}
+	if share.Burst < 1 {
+		share.Burst = 1
+	}
+	return share
+}
+
+// Status describes the consumption of a request quota on this node.
+type Status struct {
+	Key Key
+	// Config is the cluster-wide allotment of the quota.
+	Config Config
+	// NodeConfig is the part of the allotment enforced by this node.
+	NodeConfig Config
+	// ConsumedUnits is the number of request units charged to the quota.
+	ConsumedUnits int64
+	// ThrottledBatches is the number of batches that had to wait for the
+	// quota.
+	ThrottledBatches int64
+}
+
+// Quotas holds the request quotas configured in system.quotas and enforces
+// them for the batches sent by this node's SQL clients. Batches are charged
+// once, by the DistSender of their gateway node, regardless of how many
+// ranges they end up being sent to. Stores charge the batches which didn't
+// go through a DistSender enforcing the quotas, which are the ones not
+// marked as charged.
+//
+// The quotas are cluster-wide. Each node enforces an equal share of every
+// quota, so the cluster as a whole admits the configured rate as long as the
+// clients spread their load evenly across the gateways.
+type Quotas struct {
+	mu struct {
+		syncutil.Mutex
+		buckets map[Key]*bucket
+	}
+}
+
+// NewQuotas creates a Quotas with no quotas configured.
+func NewQuotas() *Quotas {
+	q := &Quotas{}
+	q.mu.buckets = map[Key]*bucket{}
+	return q
+}
+
+// Update replaces the configured quotas, of which this node enforces its
+// share given the number of live nodes. Quotas with a non-positive rate are
+// ignored, and a non-positive burst defaults to the rate. Buckets of quotas
+// which are still configured keep their consumption.
+func (q *Quotas) Update(configs map[Key]Config, numNodes int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key := range q.mu.buckets {
+		if config, ok := configs[key]; !ok || config.UnitsPerSecond <= 0 {
+			delete(q.mu.buckets, key)
+		}
+	}
+	for key, config := range configs {
+		if config.UnitsPerSecond <= 0 {
+			continue
+		}
+		if config.Burst <= 0 {
+			config.Burst = config.UnitsPerSecond
+		}
+		share := config.nodeShare(numNodes)
+		b, ok := q.mu.buckets[key]
+		if !ok {
+			q.mu.buckets[key] = &bucket{
+				config:  config,
+				share:   share,
+				limiter: rate.NewLimiter(rate.Limit(share.UnitsPerSecond), int(share.Burst)),
+			}
+			continue
+		}
+		b.config = config
+		if b.share != share {
+			b.share = share
+			b.limiter.SetLimit(rate.Limit(share.UnitsPerSecond))
+			b.limiter.SetBurst(int(share.Burst))
+		}
+	}
+}
+
+// Status returns the consumption of every configured quota, sorted by key.
+func (q *Quotas) Status() []Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	statuses := make([]Status, 0, len(q.mu.buckets))
+	for key, b := range q.mu.buckets {
+		statuses = append(statuses, Status{
+			Key:              key,
+			Config:           b.config,
+			NodeConfig:       b.share,
+			ConsumedUnits:    atomic.LoadInt64(&b.consumed),
+			ThrottledBatches: atomic.LoadInt64(&b.throttled),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i].Key, statuses[j].Key
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return statuses
+}
+
+// Admit charges the batch against the quotas of its user and application,
+// waiting until both have enough request units available. Batches without a
+// source, or which were already charged, are always admitted. An error is returned only if the context is
+// canceled while waiting. It is safe to call on a nil Quotas, which admits
+// all batches.
+func (q *Quotas) Admit(ctx context.Context, ba roachpb.BatchRequest) error {
+	if q == nil || ba.Source == (roachpb.RequestSource{}) || ba.Source.Charged {
+		return nil
+	}
+	var buckets []*bucket
+	q.mu.Lock()
+	if len(q.mu.buckets) > 0 {
+		if b, ok := q.mu.buckets[Key{
+			Kind: KindUser, Name: ba.Source.User,
+		}]; ok {
+			buckets = append(buckets, b)
+		}
+		if b, ok := q.mu.buckets[Key{
+			Kind: KindApplication, Name: ba.Source.ApplicationName,
+		}]; ok {
+			buckets = append(buckets, b)
+		}
+	}
+	q.mu.Unlock()
+	if len(buckets) == 0 {
+		// Sizing the batch isn't free, so it's only done for batches which
+		// are charged to a quota.
+		return nil
+	}
+
+	units := Units(ba)
+	for _, b := range buckets {
+		if err := b.wait(ctx, units); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucket is the token bucket of a single quota.
+type bucket struct {
+	// config and share are protected by the mutex of the owning Quotas.
+	config  Config
+	share   Config
+	limiter *rate.Limiter
+	// consumed and throttled are accessed atomically.
+	consumed  int64
+	throttled int64
+}
+
+// wait blocks until the requested units are available.
+func (b *bucket) wait(ctx context.Context, units int64) error {
+	// Batches larger than the burst would never be admitted, so they only
+	// drain the bucket.
+	n := units
+	if burst := int64(b.limiter.Burst()); n > burst {
+		n = burst
+	}
+	r := b.limiter.ReserveN(timeutil.Now(), int(n))
+	// The reservation can only fail if the burst was lowered concurrently, in
+	// which case the batch is let through.
+	if r.OK() {
+		if delay := r.Delay(); delay > 0 {
+			atomic.AddInt64(&b.throttled, 1)
+			log.VEventf(ctx, 2, "waiting %s for request quota", delay)
+			var timer timeutil.Timer
+			defer timer.Stop()
+			timer.Reset(delay)
+			select {
+			case <-timer.C:
+				timer.Read = true
+			case <-ctx.Done():
+				r.Cancel()
+				return ctx.Err()
+			}
+		}
+	}
+	atomic.AddInt64(&b.consumed, units)
+	return nil
+}
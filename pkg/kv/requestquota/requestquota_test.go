@@ -0,0 +1,151 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package requestquota
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func makeBatch(source roachpb.RequestSource, reqs ...roachpb.Request) roachpb.BatchRequest {
+	var ba roachpb.BatchRequest
+	ba.Source = source
+	ba.Add(reqs...)
+	return ba
+}
+
+func TestUnits(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	key := roachpb.Key("a")
+	get := &roachpb.GetRequest{Span: roachpb.Span{Key: key}}
+	put := roachpb.NewPut(key, roachpb.MakeValueFromBytes(make([]byte, 4<<10)))
+
+	testCases := []struct {
+		ba       roachpb.BatchRequest
+		expected int64
+	}{
+		{makeBatch(roachpb.RequestSource{}, get), 1},
+		{makeBatch(roachpb.RequestSource{}, get, get), 2},
+		// The write payload is charged in addition to the request itself.
+		{makeBatch(roachpb.RequestSource{}, put), 5},
+	}
+	for i, tc := range testCases {
+		if units := Units(tc.ba); units != tc.expected {
+			t.Errorf("%d: expected %d units, got %d", i, tc.expected, units)
+		}
+	}
+}
+
+func TestQuotasAdmit(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ctx := context.Background()
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	userKey := Key{Kind: KindUser, Name: "alice"}
+	appKey := Key{Kind: KindApplication, Name: "batch"}
+	get := &roachpb.GetRequest{Span: roachpb.Span{Key: roachpb.Key("a")}}
+
+	q := NewQuotas()
+	q.Update(map[Key]Config{
+		userKey: {UnitsPerSecond: 1, Burst: 2},
+		// Quotas without a rate are ignored.
+		appKey: {UnitsPerSecond: 0, Burst: 10},
+	}, 1 /* numNodes */)
+
+	// Batches without a matching quota, or which were already charged by
+	// their gateway, are admitted right away, even with a canceled context.
+	for _, source := range []roachpb.RequestSource{
+		{},
+		{User: "bob"},
+		{User: "bob", ApplicationName: "batch"},
+		{User: "alice", Charged: true},
+	} {
+		if err := q.Admit(canceledCtx, makeBatch(source, get)); err != nil {
+			t.Fatalf("%+v: %v", source, err)
+		}
+	}
+	var nilQuotas *Quotas
+	if err := nilQuotas.Admit(canceledCtx, makeBatch(roachpb.RequestSource{User: "alice"}, get)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The burst is available right away, after which batches have to wait.
+	alice := makeBatch(roachpb.RequestSource{User: "alice"}, get, get)
+	if err := q.Admit(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Admit(canceledCtx, alice); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	config := Config{UnitsPerSecond: 1, Burst: 2}
+	expected := []Status{
+		{Key: userKey, Config: config, NodeConfig: config,
+			ConsumedUnits: 2, ThrottledBatches: 1},
+	}
+	if status := q.Status(); !reflect.DeepEqual(expected, status) {
+		t.Fatalf("expected %+v, got %+v", expected, status)
+	}
+
+	// Updating a quota retains its consumption, and a missing burst defaults
+	// to the rate. Each node enforces its share of the quota.
+	q.Update(map[Key]Config{userKey: {UnitsPerSecond: 10}}, 3 /* numNodes */)
+	expected = []Status{
+		{Key: userKey, Config: Config{UnitsPerSecond: 10, Burst: 10},
+			NodeConfig:    Config{UnitsPerSecond: 3, Burst: 3},
+			ConsumedUnits: 2, ThrottledBatches: 1},
+	}
+	if status := q.Status(); !reflect.DeepEqual(expected, status) {
+		t.Fatalf("expected %+v, got %+v", expected, status)
+	}
+
+	// Removed quotas are no longer enforced.
+	q.Update(nil, 3 /* numNodes */)
+	if status := q.Status(); len(status) != 0 {
+		t.Fatalf("expected no quotas, got %+v", status)
+	}
+	if err := q.Admit(canceledCtx, alice); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigNodeShare(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		config   Config
+		numNodes int
+		expected Config
+	}{
+		{Config{UnitsPerSecond: 10, Burst: 20}, 0, Config{UnitsPerSecond: 10, Burst: 20}},
+		{Config{UnitsPerSecond: 10, Burst: 20}, 1, Config{UnitsPerSecond: 10, Burst: 20}},
+		{Config{UnitsPerSecond: 10, Burst: 20}, 4, Config{UnitsPerSecond: 2, Burst: 5}},
+		// Every node is allotted at least one unit.
+		{Config{UnitsPerSecond: 2, Burst: 2}, 5, Config{UnitsPerSecond: 1, Burst: 1}},
+	}
+	for i, tc := range testCases {
+		if share := tc.config.nodeShare(tc.numNodes); share != tc.expected {
+			t.Errorf("%d: expected %+v, got %+v", i, tc.expected, share)
+		}
+	}
+}
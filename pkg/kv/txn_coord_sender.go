@@ -58,6 +58,12 @@ type txnMetadata struct {
 	// to update the write intent when the transaction is committed.
 	keys []roachpb.Span
 
+	// inFlightWrites stores the point writes which were pipelined through
+	// consensus and have not yet been proven to have succeeded. Requests
+	// which overlap one of them, as well as the transaction's commit, must
+	// first prove it by querying the corresponding intent.
+	inFlightWrites []roachpb.SequencedWrite
+
 	// lastUpdateNanos is the latest wall time in nanos the client sent
 	// transaction operations to this coordinator. Accessed and updated
 	// atomically.
@@ -109,10 +115,11 @@ type TxnMetrics struct {
 	Restarts *metric.Histogram
 
 	// Counts of restart types.
-	RestartsWriteTooOld    *metric.Counter
-	RestartsDeleteRange    *metric.Counter
-	RestartsSerializable   *metric.Counter
-	RestartsPossibleReplay *metric.Counter
+	RestartsWriteTooOld       *metric.Counter
+	RestartsDeleteRange       *metric.Counter
+	RestartsSerializable      *metric.Counter
+	RestartsPossibleReplay    *metric.Counter
+	RestartsAsyncWriteFailure *metric.Counter
 }
 
 var (
@@ -146,22 +153,26 @@ var (
 	metaRestartsPossibleReplay = metric.Metadata{
 		Name: "txn.restarts.possiblereplay",
 		Help: "Number of restarts due to possible replays of command batches at the storage layer"}
+	metaRestartsAsyncWriteFailure = metric.Metadata{
+		Name: "txn.restarts.asyncwritefailure",
+		Help: "Number of restarts due to pipelined writes which failed to replicate"}
 )
 
 // MakeTxnMetrics returns a TxnMetrics struct that contains metrics whose
 // windowed portions retain data for approximately histogramWindow.
 func MakeTxnMetrics(histogramWindow time.Duration) TxnMetrics {
 	return TxnMetrics{
-		Aborts:                 metric.NewCounterWithRates(metaAbortsRates),
-		Commits:                metric.NewCounterWithRates(metaCommitsRates),
-		Commits1PC:             metric.NewCounterWithRates(metaCommits1PCRates),
-		Abandons:               metric.NewCounterWithRates(metaAbandonsRates),
-		Durations:              metric.NewLatency(metaDurationsHistograms, histogramWindow),
-		Restarts:               metric.NewHistogram(metaRestartsHistogram, histogramWindow, 100, 3),
-		RestartsWriteTooOld:    metric.NewCounter(metaRestartsWriteTooOld),
-		RestartsDeleteRange:    metric.NewCounter(metaRestartsDeleteRange),
-		RestartsSerializable:   metric.NewCounter(metaRestartsSerializable),
-		RestartsPossibleReplay: metric.NewCounter(metaRestartsPossibleReplay),
+		Aborts:                    metric.NewCounterWithRates(metaAbortsRates),
+		Commits:                   metric.NewCounterWithRates(metaCommitsRates),
+		Commits1PC:                metric.NewCounterWithRates(metaCommits1PCRates),
+		Abandons:                  metric.NewCounterWithRates(metaAbandonsRates),
+		Durations:                 metric.NewLatency(metaDurationsHistograms, histogramWindow),
+		Restarts:                  metric.NewHistogram(metaRestartsHistogram, histogramWindow, 100, 3),
+		RestartsWriteTooOld:       metric.NewCounter(metaRestartsWriteTooOld),
+		RestartsDeleteRange:       metric.NewCounter(metaRestartsDeleteRange),
+		RestartsSerializable:      metric.NewCounter(metaRestartsSerializable),
+		RestartsPossibleReplay:    metric.NewCounter(metaRestartsPossibleReplay),
+		RestartsAsyncWriteFailure: metric.NewCounter(metaRestartsAsyncWriteFailure),
 	}
 }
 
@@ -323,6 +334,13 @@ func (tc *TxnCoordSender) Send(
 
 	startNS := tc.clock.PhysicalNow()
 
+	// numQueries is the number of QueryIntent requests prepended to the
+	// batch to prove pipelined writes, and remainingWrites holds the
+	// writes which stay in flight if the batch succeeds.
+	var numQueries int
+	var remainingWrites []roachpb.SequencedWrite
+	var parallelCommit bool
+
 	if ba.Txn != nil {
 		// If this request is part of a transaction...
 		if err := tc.validateTxnForBatch(ctx, &ba); err != nil {
@@ -365,14 +383,29 @@ func (tc *TxnCoordSender) Send(
 				return pErr
 			}
 
+			txnMeta := tc.txnMu.txns[txnID]
+			if txnMeta != nil && len(txnMeta.inFlightWrites) > 0 && (!hasET || et.Commit) {
+				// Prove the pipelined writes which this batch depends on
+				// before it is evaluated. A commit depends on all of them.
+				numInFlight := len(txnMeta.inFlightWrites)
+				ba, remainingWrites = chainInFlightWrites(ba, txnMeta.inFlightWrites, hasET)
+				numQueries = numInFlight - len(remainingWrites)
+			}
+			// Writes can only be pipelined once the transaction record has
+			// been written, that is, once the coordinator tracks the txn.
+			canPipeline := txnMeta != nil &&
+				tc.st.Version.IsActive(cluster.VersionParallelCommits)
+
 			if !hasET {
+				if canPipeline && tc.st.WritePipeliningEnabled.Get() && canPipelineBatch(ba) {
+					ba.AsyncConsensus = true
+				}
 				return nil
 			}
 			// Everything below is carried out only when trying to commit.
 
 			// Populate et.IntentSpans, taking into account both any existing
 			// and new writes, and taking care to perform proper deduplication.
-			txnMeta := tc.txnMu.txns[txnID]
 			distinctSpans := true
 			if txnMeta != nil {
 				et.IntentSpans = txnMeta.keys
@@ -415,6 +448,18 @@ func (tc *TxnCoordSender) Send(
 			if txnMeta != nil {
 				txnMeta.keys = et.IntentSpans
 			}
+			if canPipeline && tc.st.ParallelCommitsEnabled.Get() && et.Commit &&
+				et.InternalCommitTrigger == nil && !et.Require1PC {
+				// Stage the transaction record in parallel with the writes
+				// which remain to be proven. If all of them succeed, the
+				// transaction is implicitly committed.
+				inFlight := append([]roachpb.SequencedWrite(nil), txnMeta.inFlightWrites...)
+				inFlight = append(inFlight, pointWrites(ba)...)
+				if len(inFlight) > 0 {
+					et.InFlightWrites = inFlight
+					parallelCommit = true
+				}
+			}
 			return nil
 		}(); pErr != nil {
 			return nil, pErr
@@ -438,10 +483,20 @@ func (tc *TxnCoordSender) Send(
 			br, pErr = tc.resendWithTxn(ctx, ba)
 		}
 
+		if numQueries > 0 {
+			ba, br, pErr = stripQueryIntents(ba, br, pErr, numQueries)
+		}
+		if parallelCommit && pErr == nil {
+			br, pErr = tc.finishParallelCommit(ctx, ba, br)
+		}
+
 		if pErr = tc.updateState(ctx, startNS, ba, br, pErr); pErr != nil {
 			log.Eventf(ctx, "error: %s", pErr)
 			return nil, pErr
 		}
+		if numQueries > 0 || ba.AsyncConsensus {
+			tc.updateInFlightWrites(ba, numQueries > 0, remainingWrites)
+		}
 	}
 
 	if br.Txn == nil {
@@ -475,7 +530,7 @@ func (tc *TxnCoordSender) Send(
 			time.Sleep(sleepNS)
 		}()
 	}
-	if br.Txn.Status != roachpb.PENDING {
+	if br.Txn.Status.IsFinalized() {
 		tc.txnMu.Lock()
 		tc.cleanupTxnLocked(ctx, *br.Txn)
 		tc.txnMu.Unlock()
@@ -682,7 +737,7 @@ func (tc *TxnCoordSender) tryAsyncAbort(txnID uuid.UUID) {
 	// Since we don't hold the lock continuously, it's possible that two aborts
 	// raced here. That's fine (and probably better than the alternative, which
 	// is missing new intents sometimes).
-	if txn.Status != roachpb.PENDING {
+	if txn.Status.IsFinalized() {
 		return
 	}
 
@@ -720,7 +775,7 @@ func (tc *TxnCoordSender) heartbeat(ctx context.Context, txnID uuid.UUID) bool {
 	hasAbandoned := txnMeta.hasClientAbandonedCoord(tc.clock.PhysicalNow())
 	tc.txnMu.Unlock()
 
-	if txn.Status != roachpb.PENDING {
+	if txn.Status.IsFinalized() {
 		// A previous iteration has already determined that the transaction is
 		// already finalized, so we wait for the client to realize that and
 		// want to keep our state for the time being (to dish out the right
@@ -793,6 +848,85 @@ func (tc *TxnCoordSender) heartbeat(ctx context.Context, txnID uuid.UUID) bool {
 	return true
 }
 
+// finishParallelCommit is called once a batch which staged the
+// transaction record in parallel with the transaction's final writes has
+// succeeded. If all of the writes succeeded at the timestamp at which
+// the record was staged, the transaction is implicitly committed: the
+// client learns about it right away and the record is moved to COMMITTED
+// asynchronously. Otherwise, the transaction has to be committed
+// explicitly at its new timestamp, which may fail.
+func (tc *TxnCoordSender) finishParallelCommit(
+	ctx context.Context, ba roachpb.BatchRequest, br *roachpb.BatchResponse,
+) (*roachpb.BatchResponse, *roachpb.Error) {
+	if br.Txn == nil || br.Txn.Status != roachpb.STAGING {
+		return br, nil
+	}
+	et := ba.Requests[len(ba.Requests)-1].GetInner().(*roachpb.EndTransactionRequest)
+	txn := br.Txn.Clone()
+	commitBa := roachpb.BatchRequest{}
+	commitBa.Txn = &txn
+	commitBa.Add(&roachpb.EndTransactionRequest{
+		Span: roachpb.Span{
+			Key: txn.Key,
+		},
+		Commit:      true,
+		Deadline:    et.Deadline,
+		IntentSpans: et.IntentSpans,
+	})
+
+	if txn.Timestamp == ba.Txn.Timestamp && !txn.WriteTooOld {
+		brShallow := *br
+		brShallow.Txn = &txn
+		brShallow.Txn.Status = roachpb.COMMITTED
+		brShallow.Txn.InFlightWrites = nil
+		// NB: use context.Background() here because the caller's context
+		// may be cancelled once we return. Should this fail, the record is
+		// recovered by the next conflicting pusher.
+		asyncCtx := tc.AnnotateCtx(context.Background())
+		if err := tc.stopper.RunAsyncTask(asyncCtx, "kv.TxnCoordSender: committing staged txn", func(ctx context.Context) {
+			// Use the wrapped sender since the normal Sender does not allow
+			// clients to specify intents.
+			if _, pErr := tc.wrapped.Send(ctx, commitBa); pErr != nil {
+				log.VEventf(ctx, 1, "explicit commit of staged %s failed: %s", txn, pErr)
+			}
+		}); err != nil {
+			log.Warning(ctx, err)
+		}
+		return &brShallow, nil
+	}
+
+	log.Eventf(ctx, "staged %s was pushed; committing explicitly", txn.Short())
+	commitBr, pErr := tc.wrapped.Send(ctx, commitBa)
+	if pErr != nil {
+		return nil, pErr
+	}
+	brShallow := *br
+	brShallow.Txn = &txn
+	brShallow.Txn.Update(commitBr.Txn)
+	return &brShallow, nil
+}
+
+// updateInFlightWrites updates the set of unproven pipelined writes of
+// the transaction after a successful batch. If the batch proved some of
+// them, only the remaining ones stay in flight; if the batch was itself
+// pipelined, its writes are added.
+func (tc *TxnCoordSender) updateInFlightWrites(
+	ba roachpb.BatchRequest, proved bool, remaining []roachpb.SequencedWrite,
+) {
+	tc.txnMu.Lock()
+	defer tc.txnMu.Unlock()
+	txnMeta := tc.txnMu.txns[ba.Txn.ID]
+	if txnMeta == nil {
+		return
+	}
+	if proved {
+		txnMeta.inFlightWrites = remaining
+	}
+	if ba.AsyncConsensus {
+		txnMeta.inFlightWrites = append(txnMeta.inFlightWrites, pointWrites(ba)...)
+	}
+}
+
 // updateState updates the transaction state in both the success and
 // error cases, applying those updates to the corresponding txnMeta
 // object when adequate. It also updates retryable errors with the
@@ -846,6 +980,8 @@ func (tc *TxnCoordSender) updateState(
 					tc.metrics.RestartsSerializable.Inc(1)
 				case roachpb.RETRY_POSSIBLE_REPLAY:
 					tc.metrics.RestartsPossibleReplay.Inc(1)
+				case roachpb.RETRY_ASYNC_WRITE_FAILURE:
+					tc.metrics.RestartsAsyncWriteFailure.Inc(1)
 				}
 			}
 			newTxn = roachpb.PrepareTransactionForRetry(ctx, pErr, ba.UserPriority, tc.clock)
@@ -885,6 +1021,10 @@ func (tc *TxnCoordSender) updateState(
 	}
 
 	txnMeta := tc.txnMu.txns[txnID]
+	if txnMeta != nil && txnMeta.txn.Epoch < newTxn.Epoch {
+		// Writes pipelined in a previous epoch don't need to be proven.
+		txnMeta.inFlightWrites = nil
+	}
 	// For successful transactional requests, keep the written intents and
 	// the updated transaction record to be sent along with the reply.
 	// The transaction metadata is created with the first writing operation.
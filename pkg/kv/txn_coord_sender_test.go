@@ -1733,3 +1733,134 @@ func TestTooManyIntentsAtCommit(t *testing.T) {
 		t.Fatal("did not expect value to exist")
 	}
 }
+
+// TestTxnCoordSenderFinishParallelCommit verifies that a transaction whose
+// record was staged at the timestamp of its final writes is reported as
+// committed right away and committed explicitly in the background, while
+// one which was pushed is committed explicitly before returning.
+func TestTxnCoordSenderFinishParallelCommit(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	manual := hlc.NewManualClock(123)
+	clock := hlc.NewClock(manual.UnixNano, time.Nanosecond)
+
+	commitC := make(chan roachpb.BatchRequest, 1)
+	var senderFn client.SenderFunc = func(_ context.Context, ba roachpb.BatchRequest) (
+		*roachpb.BatchResponse, *roachpb.Error) {
+		commitC <- ba
+		br := ba.CreateReply()
+		txnClone := ba.Txn.Clone()
+		br.Txn = &txnClone
+		br.Txn.Status = roachpb.COMMITTED
+		return br, nil
+	}
+	ambient := log.AmbientContext{Tracer: tracing.NewTracer()}
+	ts := NewTxnCoordSender(
+		ambient, cluster.MakeTestingClusterSettings(),
+		senderFn, clock, false, stopper, MakeTxnMetrics(metric.TestSampleInterval),
+	)
+
+	key := roachpb.Key("a")
+	intents := []roachpb.Span{{Key: key}}
+	txn := roachpb.MakeTransaction("test", key, 0, 0, clock.Now(), 0)
+	var ba roachpb.BatchRequest
+	ba.Txn = &txn
+	ba.Add(&roachpb.PutRequest{Span: roachpb.Span{Key: key}})
+	ba.Add(&roachpb.EndTransactionRequest{
+		Span:           roachpb.Span{Key: key},
+		Commit:         true,
+		IntentSpans:    intents,
+		InFlightWrites: []roachpb.SequencedWrite{{Key: key, Sequence: 1}},
+	})
+
+	committed := txn.Clone()
+	committed.Status = roachpb.COMMITTED
+	staged := txn.Clone()
+	staged.Status = roachpb.STAGING
+	pushed := staged.Clone()
+	pushed.Timestamp = txn.Timestamp.Add(1, 0)
+
+	testCases := []struct {
+		respTxn   roachpb.Transaction
+		expCommit bool
+		expTS     hlc.Timestamp
+	}{
+		// The record was committed right away.
+		{committed, false, txn.Timestamp},
+		// The transaction is implicitly committed.
+		{staged, true, txn.Timestamp},
+		// The transaction has to be committed explicitly at its new timestamp.
+		{pushed, true, pushed.Timestamp},
+	}
+	for i, c := range testCases {
+		br := ba.CreateReply()
+		respTxn := c.respTxn.Clone()
+		br.Txn = &respTxn
+		br, pErr := ts.finishParallelCommit(context.Background(), ba, br)
+		if pErr != nil {
+			t.Fatalf("%d: %s", i, pErr)
+		}
+		if br.Txn.Status != roachpb.COMMITTED || br.Txn.Timestamp != c.expTS {
+			t.Errorf("%d: expected transaction committed at %s, got %s", i, c.expTS, br.Txn)
+		}
+		if len(br.Txn.InFlightWrites) != 0 {
+			t.Errorf("%d: unexpected in-flight writes %+v", i, br.Txn.InFlightWrites)
+		}
+		if !c.expCommit {
+			select {
+			case commitBa := <-commitC:
+				t.Errorf("%d: unexpected commit %s", i, commitBa)
+			default:
+			}
+			continue
+		}
+		commitBa := <-commitC
+		et, ok := commitBa.GetArg(roachpb.EndTransaction)
+		if !ok {
+			t.Fatalf("%d: expected an EndTransaction, got %s", i, commitBa)
+		}
+		if commitBa.Txn.Timestamp != c.expTS {
+			t.Errorf("%d: expected commit at %s, got %s", i, c.expTS, commitBa.Txn)
+		}
+		if et := et.(*roachpb.EndTransactionRequest); !et.Commit || len(et.InFlightWrites) != 0 ||
+			!reflect.DeepEqual(et.IntentSpans, intents) {
+			t.Errorf("%d: expected explicit commit with intents %+v, got %+v", i, intents, et)
+		}
+	}
+}
+
+// TestTxnCoordSenderParallelCommit verifies that a transaction whose writes
+// were pipelined and which committed in parallel with its final writes ends
+// up committed with all its intents resolved.
+func TestTxnCoordSenderParallelCommit(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	ctx := context.Background()
+	s, sender := createTestDB(t)
+	defer s.Stop()
+
+	keys := []roachpb.Key{roachpb.Key("a"), roachpb.Key("b"), roachpb.Key("c")}
+	txn := client.NewTxn(s.DB)
+	for _, key := range keys[:2] {
+		if err := txn.Put(ctx, key, []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := txn.NewBatch()
+	b.Put(keys[2], []byte("value"))
+	if err := txn.CommitInBatch(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if status := txn.Proto().Status; status != roachpb.COMMITTED {
+		t.Fatalf("expected transaction to be committed, got %s", status)
+	}
+
+	for _, key := range keys {
+		verifyCleanup(key, sender, s.Eng, t)
+		if kv, err := s.DB.Get(ctx, key); err != nil {
+			t.Fatal(err)
+		} else if !kv.Exists() {
+			t.Errorf("expected value at %s", key)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kv
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// canPipelineBatch returns whether the batch may be evaluated without
+// waiting for its writes to be replicated. Only batches consisting
+// solely of transactional point writes qualify; range writes don't
+// know which keys they will touch ahead of time and the transaction
+// record must be written synchronously.
+func canPipelineBatch(ba roachpb.BatchRequest) bool {
+	if len(ba.Requests) == 0 {
+		return false
+	}
+	for _, ru := range ba.Requests {
+		req := ru.GetInner()
+		switch req.Method() {
+		case roachpb.BeginTransaction, roachpb.EndTransaction:
+			return false
+		}
+		if !roachpb.IsTransactionWrite(req) || roachpb.IsRange(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// pointWrites returns the transactional point writes in the batch,
+// tagged with the batch's transaction sequence number.
+func pointWrites(ba roachpb.BatchRequest) []roachpb.SequencedWrite {
+	var writes []roachpb.SequencedWrite
+	for _, ru := range ba.Requests {
+		req := ru.GetInner()
+		switch req.Method() {
+		case roachpb.BeginTransaction, roachpb.EndTransaction:
+			continue
+		}
+		if !roachpb.IsTransactionWrite(req) || roachpb.IsRange(req) {
+			continue
+		}
+		writes = append(writes, roachpb.SequencedWrite{
+			Key:      req.Header().Key,
+			Sequence: ba.Txn.Sequence,
+		})
+	}
+	return writes
+}
+
+// overlapsWrite returns whether the request's span covers the key of
+// the in-flight write.
+func overlapsWrite(req roachpb.Request, w roachpb.SequencedWrite) bool {
+	h := req.Header()
+	if len(h.EndKey) == 0 {
+		return h.Key.Equal(w.Key)
+	}
+	return h.Key.Compare(w.Key) <= 0 && w.Key.Compare(h.EndKey) < 0
+}
+
+// chainInFlightWrites prepends a QueryIntent request to the batch for
+// each in-flight write which must be proven before the batch can be
+// evaluated: those overlapping one of its requests or, if all is set,
+// every one of them. The QueryIntents fail with a retryable error if
+// the write they're querying didn't succeed. Returns the new batch and
+// the writes which remain unproven. The number of prepended requests
+// is len(inFlight)-len(remaining).
+//
+// The original batch's requests are not mutated.
+func chainInFlightWrites(
+	ba roachpb.BatchRequest, inFlight []roachpb.SequencedWrite, all bool,
+) (roachpb.BatchRequest, []roachpb.SequencedWrite) {
+	var queries []roachpb.RequestUnion
+	var remaining []roachpb.SequencedWrite
+	for _, w := range inFlight {
+		prove := all
+		for i := 0; !prove && i < len(ba.Requests); i++ {
+			req := ba.Requests[i].GetInner()
+			if req.Method() == roachpb.EndTransaction {
+				continue
+			}
+			prove = overlapsWrite(req, w)
+		}
+		if !prove {
+			remaining = append(remaining, w)
+			continue
+		}
+		meta := ba.Txn.TxnMeta
+		meta.Sequence = w.Sequence
+		var ru roachpb.RequestUnion
+		ru.MustSetInner(&roachpb.QueryIntentRequest{
+			Span:           roachpb.Span{Key: w.Key},
+			Txn:            meta,
+			ErrorIfMissing: true,
+		})
+		queries = append(queries, ru)
+	}
+	if len(queries) == 0 {
+		return ba, inFlight
+	}
+	ba.Requests = append(queries, ba.Requests...)
+	return ba, remaining
+}
+
+// stripQueryIntents removes the first n requests from the batch and the
+// first n responses from the reply, undoing chainInFlightWrites. The
+// error index, if any, is adjusted accordingly.
+func stripQueryIntents(
+	ba roachpb.BatchRequest, br *roachpb.BatchResponse, pErr *roachpb.Error, n int,
+) (roachpb.BatchRequest, *roachpb.BatchResponse, *roachpb.Error) {
+	ba.Requests = ba.Requests[n:]
+	if br != nil {
+		brShallow := *br
+		brShallow.Responses = br.Responses[n:]
+		br = &brShallow
+	}
+	if pErr != nil && pErr.Index != nil {
+		// Avoid changing existing errors because sometimes they escape
+		// into goroutines and data races can occur.
+		pErrShallow := *pErr
+		if idx := pErr.Index.Index - int32(n); idx >= 0 {
+			pErrShallow.SetErrorIndex(idx)
+		} else {
+			pErrShallow.Index = nil
+		}
+		pErr = &pErrShallow
+	}
+	return ba, br, pErr
+}
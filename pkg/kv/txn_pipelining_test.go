@@ -0,0 +1,133 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kv
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestCanPipelineBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	put := &roachpb.PutRequest{Span: roachpb.Span{Key: roachpb.Key("a")}}
+	cput := &roachpb.ConditionalPutRequest{Span: roachpb.Span{Key: roachpb.Key("b")}}
+	get := &roachpb.GetRequest{Span: roachpb.Span{Key: roachpb.Key("a")}}
+	delRng := &roachpb.DeleteRangeRequest{Span: roachpb.Span{Key: roachpb.Key("a"), EndKey: roachpb.Key("c")}}
+	bt := &roachpb.BeginTransactionRequest{Span: roachpb.Span{Key: roachpb.Key("a")}}
+	et := &roachpb.EndTransactionRequest{Commit: true}
+
+	testCases := []struct {
+		reqs []roachpb.Request
+		exp  bool
+	}{
+		{nil, false},
+		{[]roachpb.Request{put}, true},
+		{[]roachpb.Request{put, cput}, true},
+		{[]roachpb.Request{put, get}, false},
+		{[]roachpb.Request{delRng}, false},
+		{[]roachpb.Request{bt, put}, false},
+		{[]roachpb.Request{put, et}, false},
+	}
+	for i, c := range testCases {
+		var ba roachpb.BatchRequest
+		ba.Add(c.reqs...)
+		if act := canPipelineBatch(ba); act != c.exp {
+			t.Errorf("%d: expected %t, got %t", i, c.exp, act)
+		}
+	}
+}
+
+func TestChainInFlightWrites(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	txn := roachpb.MakeTransaction("test", roachpb.Key("a"), 0, 0, hlc.Timestamp{WallTime: 1}, 0)
+	inFlight := []roachpb.SequencedWrite{
+		{Key: roachpb.Key("a"), Sequence: 1},
+		{Key: roachpb.Key("b"), Sequence: 2},
+		{Key: roachpb.Key("d"), Sequence: 3},
+	}
+
+	testCases := []struct {
+		reqs         []roachpb.Request
+		all          bool
+		expQueried   []roachpb.Key
+		expRemaining []roachpb.SequencedWrite
+	}{
+		{
+			reqs:         []roachpb.Request{&roachpb.PutRequest{Span: roachpb.Span{Key: roachpb.Key("c")}}},
+			expRemaining: inFlight,
+		},
+		{
+			reqs:         []roachpb.Request{&roachpb.PutRequest{Span: roachpb.Span{Key: roachpb.Key("b")}}},
+			expQueried:   []roachpb.Key{roachpb.Key("b")},
+			expRemaining: []roachpb.SequencedWrite{inFlight[0], inFlight[2]},
+		},
+		{
+			reqs: []roachpb.Request{&roachpb.ScanRequest{
+				Span: roachpb.Span{Key: roachpb.Key("a"), EndKey: roachpb.Key("d")},
+			}},
+			expQueried:   []roachpb.Key{roachpb.Key("a"), roachpb.Key("b")},
+			expRemaining: []roachpb.SequencedWrite{inFlight[2]},
+		},
+		{
+			reqs:       []roachpb.Request{&roachpb.EndTransactionRequest{Commit: true}},
+			all:        true,
+			expQueried: []roachpb.Key{roachpb.Key("a"), roachpb.Key("b"), roachpb.Key("d")},
+		},
+	}
+	for i, c := range testCases {
+		var ba roachpb.BatchRequest
+		ba.Txn = &txn
+		ba.Txn.Sequence = 4
+		ba.Add(c.reqs...)
+		chained, remaining := chainInFlightWrites(ba, inFlight, c.all)
+		if !reflect.DeepEqual(remaining, c.expRemaining) {
+			t.Errorf("%d: expected remaining writes %v, got %v", i, c.expRemaining, remaining)
+		}
+		numQueries := len(inFlight) - len(remaining)
+		if len(chained.Requests) != numQueries+len(c.reqs) {
+			t.Fatalf("%d: expected %d requests, got %d", i, numQueries+len(c.reqs), len(chained.Requests))
+		}
+		var queried []roachpb.Key
+		for _, ru := range chained.Requests[:numQueries] {
+			qi := ru.GetInner().(*roachpb.QueryIntentRequest)
+			if !qi.ErrorIfMissing {
+				t.Errorf("%d: expected ErrorIfMissing to be set on %s", i, qi)
+			}
+			queried = append(queried, qi.Key)
+		}
+		if !reflect.DeepEqual(queried, c.expQueried) {
+			t.Errorf("%d: expected queried keys %v, got %v", i, c.expQueried, queried)
+		}
+		if len(ba.Requests) != len(c.reqs) {
+			t.Errorf("%d: original batch was mutated", i)
+		}
+
+		br := chained.CreateReply()
+		pErr := roachpb.NewErrorf("boom")
+		pErr.SetErrorIndex(int32(numQueries))
+		stripped, strippedBr, strippedErr := stripQueryIntents(chained, br, pErr, numQueries)
+		if len(stripped.Requests) != len(c.reqs) || len(strippedBr.Responses) != len(c.reqs) {
+			t.Errorf("%d: expected %d requests and responses after stripping, got %d and %d",
+				i, len(c.reqs), len(stripped.Requests), len(strippedBr.Responses))
+		}
+		if strippedErr.Index == nil || strippedErr.Index.Index != 0 {
+			t.Errorf("%d: expected error index 0, got %v", i, strippedErr.Index)
+		}
+	}
+}
@@ -269,6 +269,9 @@ func (er *ExportResponse) combine(c combinable) error {
 			return err
 		}
 		er.Files = append(er.Files, otherER.Files...)
+		if er.StartTime.Less(otherER.StartTime) {
+			er.StartTime = otherER.StartTime
+		}
 	}
 	return nil
 }
@@ -469,6 +472,15 @@ func (*PushTxnRequest) Method() Method { return PushTxn }
 // Method implements the Request interface.
 func (*QueryTxnRequest) Method() Method { return QueryTxn }
 
+// Method implements the Request interface.
+func (*QueryIntentRequest) Method() Method { return QueryIntent }
+
+// Method implements the Request interface.
+func (*RecoverTxnRequest) Method() Method { return RecoverTxn }
+
+// Method implements the Request interface.
+func (*ClearRangeRequest) Method() Method { return ClearRange }
+
 // Method implements the Request interface.
 func (*RangeLookupRequest) Method() Method { return RangeLookup }
 
@@ -637,6 +649,24 @@ func (qtr *QueryTxnRequest) ShallowCopy() Request {
 	return &shallowCopy
 }
 
+// ShallowCopy implements the Request interface.
+func (qir *QueryIntentRequest) ShallowCopy() Request {
+	shallowCopy := *qir
+	return &shallowCopy
+}
+
+// ShallowCopy implements the Request interface.
+func (rtr *RecoverTxnRequest) ShallowCopy() Request {
+	shallowCopy := *rtr
+	return &shallowCopy
+}
+
+// ShallowCopy implements the Request interface.
+func (crr *ClearRangeRequest) ShallowCopy() Request {
+	shallowCopy := *crr
+	return &shallowCopy
+}
+
 // ShallowCopy implements the Request interface.
 func (rlr *RangeLookupRequest) ShallowCopy() Request {
 	shallowCopy := *rlr
@@ -920,7 +950,10 @@ func (*HeartbeatTxnRequest) flags() int        { return isWrite | isTxn }
 func (*GCRequest) flags() int                  { return isWrite | isRange }
 func (*PushTxnRequest) flags() int             { return isWrite | isAlone }
 func (*QueryTxnRequest) flags() int            { return isRead | isAlone }
+func (*QueryIntentRequest) flags() int         { return isRead | isTxn | updatesTSCache }
 func (*RangeLookupRequest) flags() int         { return isRead }
+func (*RecoverTxnRequest) flags() int          { return isWrite | isAlone }
+func (*ClearRangeRequest) flags() int          { return isWrite | isRange | isAlone }
 func (*ResolveIntentRequest) flags() int       { return isWrite }
 func (*ResolveIntentRangeRequest) flags() int  { return isWrite | isRange }
 func (*NoopRequest) flags() int                { return isRead } // slightly special
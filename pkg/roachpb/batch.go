@@ -314,8 +314,10 @@ func (ba BatchRequest) Split(canSplitET bool) [][]RequestUnion {
 			args := union.GetInner()
 			flags := args.flags()
 			method := args.Method()
-			// Regardless of flags, a NoopRequest is always compatible.
-			if method == Noop {
+			// Regardless of flags, a NoopRequest is always compatible. So
+			// is a QueryIntentRequest, which is used to prove pipelined
+			// writes in the same batch as the writes that depend on them.
+			if method == Noop || method == QueryIntent {
 				continue
 			}
 			if !compatible(method, gFlags, flags) {
@@ -33,6 +33,7 @@ func TestBatchSplit(t *testing.T) {
 	et := &EndTransactionRequest{}
 	rv := &ReverseScanRequest{}
 	np := &NoopRequest{}
+	qi := &QueryIntentRequest{}
 	testCases := []struct {
 		reqs       []Request
 		sizes      []int
@@ -57,6 +58,10 @@ func TestBatchSplit(t *testing.T) {
 		{[]Request{np, spl, np}, []int{3}, true},
 		{[]Request{np, rv, np}, []int{3}, true},
 		{[]Request{np, np, et}, []int{3}, true}, // et does not split off
+		// Check that QueryIntent can mix with writes and reads alike.
+		{[]Request{qi, put, qi, put}, []int{4}, true},
+		{[]Request{qi, get, qi, scan}, []int{4}, true},
+		{[]Request{put, qi, put, et}, []int{4}, false},
 	}
 
 	for i, test := range testCases {
@@ -718,6 +718,14 @@ func MakeTransaction(
 	}
 }
 
+// IsFinalized returns true if the status is terminal, i.e. the
+// transaction has been either committed or aborted. A STAGING
+// transaction is not finalized: it may still be explicitly committed
+// or, after recovery, committed or aborted.
+func (s TransactionStatus) IsFinalized() bool {
+	return s == COMMITTED || s == ABORTED
+}
+
 // LastActive returns the last timestamp at which client activity definitely
 // occurred, i.e. the maximum of OrigTimestamp and LastHeartbeat.
 func (t Transaction) LastActive() hlc.Timestamp {
@@ -740,6 +748,7 @@ func (t Transaction) Clone() Transaction {
 	// Note that we're not cloning the span keys under the assumption that the
 	// keys themselves are not mutable.
 	t.Intents = append([]Span(nil), t.Intents...)
+	t.InFlightWrites = append([]SequencedWrite(nil), t.InFlightWrites...)
 	return t
 }
 
@@ -876,7 +885,14 @@ func (t *Transaction) Update(o *Transaction) {
 	if len(t.Key) == 0 {
 		t.Key = o.Key
 	}
-	if o.Status != PENDING {
+	switch o.Status {
+	case PENDING:
+	case STAGING:
+		// A stale STAGING status must not override a finalized one.
+		if !t.Status.IsFinalized() {
+			t.Status = o.Status
+		}
+	default:
 		t.Status = o.Status
 	}
 	if t.Epoch < o.Epoch {
@@ -909,6 +925,9 @@ func (t *Transaction) Update(o *Transaction) {
 	if len(o.Intents) > 0 {
 		t.Intents = o.Intents
 	}
+	if len(o.InFlightWrites) > 0 {
+		t.InFlightWrites = o.InFlightWrites
+	}
 }
 
 // UpgradePriority sets transaction priority to the maximum of current
@@ -936,6 +955,9 @@ func (t Transaction) String() string {
 	if ni := len(t.Intents); t.Status != PENDING && ni > 0 {
 		fmt.Fprintf(&buf, " int=%d", ni)
 	}
+	if nw := len(t.InFlightWrites); t.Status == STAGING && nw > 0 {
+		fmt.Fprintf(&buf, " ifw=%d", nw)
+	}
 	return buf.String()
 }
 
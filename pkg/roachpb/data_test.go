@@ -377,6 +377,7 @@ var nonZeroTxn = Transaction{
 	WriteTooOld:        true,
 	RetryOnPush:        true,
 	Intents:            []Span{{Key: []byte("a"), EndKey: []byte("b")}},
+	InFlightWrites:     []SequencedWrite{{Key: []byte("c"), Sequence: 1}},
 }
 
 func TestTransactionUpdate(t *testing.T) {
@@ -403,6 +404,36 @@ func TestTransactionUpdate(t *testing.T) {
 	}
 }
 
+// TestTransactionUpdateStatus verifies that a STAGING status only
+// replaces a status which is not yet finalized.
+func TestTransactionUpdateStatus(t *testing.T) {
+	testCases := []struct {
+		cur, upd, exp TransactionStatus
+	}{
+		{PENDING, PENDING, PENDING},
+		{PENDING, STAGING, STAGING},
+		{PENDING, COMMITTED, COMMITTED},
+		{STAGING, PENDING, STAGING},
+		{STAGING, COMMITTED, COMMITTED},
+		{STAGING, ABORTED, ABORTED},
+		{COMMITTED, STAGING, COMMITTED},
+		{ABORTED, STAGING, ABORTED},
+	}
+	for i, c := range testCases {
+		txn := nonZeroTxn.Clone()
+		txn.Status = c.cur
+		o := nonZeroTxn.Clone()
+		o.Status = c.upd
+		txn.Update(&o)
+		if txn.Status != c.exp {
+			t.Errorf("%d: %s updated with %s: expected %s, got %s", i, c.cur, c.upd, c.exp, txn.Status)
+		}
+		if a, e := txn.Status.IsFinalized(), c.exp == COMMITTED || c.exp == ABORTED; a != e {
+			t.Errorf("%d: expected IsFinalized()=%t for %s", i, e, txn.Status)
+		}
+	}
+}
+
 func TestTransactionClone(t *testing.T) {
 	txn := nonZeroTxn.Clone()
 
@@ -413,6 +444,7 @@ func TestTransactionClone(t *testing.T) {
 	// listed below. If this test fails, please update the list below and/or
 	// Transaction.Clone().
 	expFields := []string{
+		"InFlightWrites.Key",
 		"Intents.EndKey",
 		"Intents.Key",
 		"TxnMeta.Key",
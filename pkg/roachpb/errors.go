@@ -421,6 +421,22 @@ func (e *TransactionReplayError) message(pErr *Error) string {
 
 var _ ErrorDetailInterface = &TransactionReplayError{}
 
+// NewIndeterminateCommitError initializes a new IndeterminateCommitError.
+// The argument is copied.
+func NewIndeterminateCommitError(stagingTxn Transaction) *IndeterminateCommitError {
+	return &IndeterminateCommitError{StagingTxn: stagingTxn.Clone()}
+}
+
+func (e *IndeterminateCommitError) Error() string {
+	return e.message(nil)
+}
+
+func (e *IndeterminateCommitError) message(pErr *Error) string {
+	return fmt.Sprintf("found txn in indeterminate STAGING state %s", e.StagingTxn)
+}
+
+var _ ErrorDetailInterface = &IndeterminateCommitError{}
+
 // NewTransactionStatusError initializes a new TransactionStatusError from
 // the given message.
 func NewTransactionStatusError(msg string) *TransactionStatusError {
@@ -598,3 +614,20 @@ func (e *StoreNotFoundError) message(_ *Error) string {
 }
 
 var _ ErrorDetailInterface = &StoreNotFoundError{}
+
+// NewRangeFeedRetryError initializes a new RangeFeedRetryError.
+func NewRangeFeedRetryError(reason string) *RangeFeedRetryError {
+	return &RangeFeedRetryError{
+		Reason: reason,
+	}
+}
+
+func (e *RangeFeedRetryError) Error() string {
+	return e.message(nil)
+}
+
+func (e *RangeFeedRetryError) message(_ *Error) string {
+	return fmt.Sprintf("retry range feed: %s", e.Reason)
+}
+
+var _ ErrorDetailInterface = &RangeFeedRetryError{}
@@ -141,6 +141,35 @@ func (r RangeDescriptor) GetReplicaDescriptorByID(replicaID ReplicaID) (ReplicaD
 	return ReplicaDescriptor{}, false
 }
 
+// Voters returns the replicas of the range which are full members of its
+// Raft group.
+func (r RangeDescriptor) Voters() []ReplicaDescriptor {
+	return r.filterReplicas(VOTER)
+}
+
+// Learners returns the replicas of the range which are Raft learners, that
+// is, replicas which have been added to the range but not yet promoted to
+// voters.
+func (r RangeDescriptor) Learners() []ReplicaDescriptor {
+	return r.filterReplicas(LEARNER)
+}
+
+// NonVoters returns the replicas of the range which are permanent members of
+// its Raft group that don't participate in quorum.
+func (r RangeDescriptor) NonVoters() []ReplicaDescriptor {
+	return r.filterReplicas(NON_VOTER)
+}
+
+func (r RangeDescriptor) filterReplicas(typ ReplicaType) []ReplicaDescriptor {
+	var reps []ReplicaDescriptor
+	for _, rep := range r.Replicas {
+		if rep.GetType() == typ {
+			reps = append(reps, rep)
+		}
+	}
+	return reps
+}
+
 // IsInitialized returns false if this descriptor represents an
 // uninitialized range.
 // TODO(bdarnell): unify this with Validate().
@@ -208,9 +237,33 @@ func (r ReplicaDescriptor) String() string {
 	} else {
 		fmt.Fprintf(&buf, "%d", r.ReplicaID)
 	}
+	if typ := r.GetType(); typ != VOTER {
+		buf.WriteString(typ.String())
+	}
 	return buf.String()
 }
 
+// GetType returns the type of the replica. Descriptors which don't specify a
+// type describe voters.
+func (r ReplicaDescriptor) GetType() ReplicaType {
+	if r.Type == nil {
+		return VOTER
+	}
+	return *r.Type
+}
+
+// IsLearner returns whether the replica is a learner which is waiting to be
+// promoted to a voter.
+func (r ReplicaDescriptor) IsLearner() bool {
+	return r.GetType() == LEARNER
+}
+
+// IsVoter returns whether the replica participates in the Raft quorum. All
+// other replicas are learners at the Raft level.
+func (r ReplicaDescriptor) IsVoter() bool {
+	return r.GetType() == VOTER
+}
+
 // Validate performs some basic validation of the contents of a replica descriptor.
 func (r ReplicaDescriptor) Validate() error {
 	if r.NodeID == 0 {
@@ -263,11 +316,12 @@ func (p Percentiles) String() string {
 // String returns a string representation of the StoreCapacity.
 func (sc StoreCapacity) String() string {
 	return fmt.Sprintf("disk (capacity=%s, available=%s, used=%s, logicalBytes=%s), "+
-		"ranges=%d, leases=%d, writes=%.2f, "+
+		"ranges=%d, leases=%d, queries=%.2f, writes=%.2f, writeBytes=%s/s, "+
 		"bytesPerReplica={%s}, writesPerReplica={%s}",
 		humanizeutil.IBytes(sc.Capacity), humanizeutil.IBytes(sc.Available),
 		humanizeutil.IBytes(sc.Used), humanizeutil.IBytes(sc.LogicalBytes),
-		sc.RangeCount, sc.LeaseCount, sc.WritesPerSecond,
+		sc.RangeCount, sc.LeaseCount, sc.QueriesPerSecond, sc.WritesPerSecond,
+		humanizeutil.IBytes(int64(sc.WriteBytesPerSecond)),
 		sc.BytesPerReplica, sc.WritesPerReplica)
 }
 
@@ -132,6 +132,37 @@ func TestRangeDescriptorMissingReplica(t *testing.T) {
 	}
 }
 
+func TestRangeDescriptorVotersAndLearners(t *testing.T) {
+	desc := RangeDescriptor{
+		Replicas: []ReplicaDescriptor{
+			{NodeID: 1, StoreID: 1, ReplicaID: 1},
+			{NodeID: 2, StoreID: 2, ReplicaID: 2, Type: LEARNER.Enum()},
+			{NodeID: 3, StoreID: 3, ReplicaID: 3, Type: VOTER.Enum()},
+			{NodeID: 4, StoreID: 4, ReplicaID: 4, Type: NON_VOTER.Enum()},
+		},
+	}
+	if a, e := desc.Voters(), []ReplicaDescriptor{desc.Replicas[0], desc.Replicas[2]}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected voters %v, got %v", e, a)
+	}
+	if a, e := desc.Learners(), []ReplicaDescriptor{desc.Replicas[1]}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected learners %v, got %v", e, a)
+	}
+	if a, e := desc.NonVoters(), []ReplicaDescriptor{desc.Replicas[3]}; !reflect.DeepEqual(a, e) {
+		t.Errorf("expected non-voters %v, got %v", e, a)
+	}
+	for i, e := range []bool{true, false, true, false} {
+		if a := desc.Replicas[i].IsVoter(); a != e {
+			t.Errorf("%d: expected IsVoter() to be %t, got %t", i, e, a)
+		}
+	}
+	if a, e := desc.Replicas[1].String(), "(n2,s2):2LEARNER"; a != e {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+	if a, e := desc.Replicas[2].String(), "(n3,s3):3"; a != e {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
+
 // TestLocalityConversions verifies that setting the value from the CLI short
 // hand format works correctly.
 func TestLocalityConversions(t *testing.T) {
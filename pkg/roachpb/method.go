@@ -133,4 +133,12 @@ const (
 	AdminScatter
 	// AddSSTable links a file into the RocksDB log-structured merge-tree.
 	AddSSTable
+	// QueryIntent checks whether the specified intent exists.
+	QueryIntent
+	// RecoverTxn finalizes a STAGING transaction whose coordinator has
+	// died, based on whether its in-flight writes were found.
+	RecoverTxn
+	// ClearRange removes all values (including all of their versions)
+	// in a key range without leaving MVCC tombstones.
+	ClearRange
 )
@@ -111,6 +111,12 @@ func (*internalServer) Batch(
 	return nil, nil
 }
 
+func (*internalServer) RangeFeed(
+	_ *roachpb.RangeFeedRequest, _ roachpb.Internal_RangeFeedServer,
+) error {
+	panic("unimplemented")
+}
+
 // TestHeartbeatHealth verifies that the health status changes after
 // heartbeats succeed or fail.
 func TestHeartbeatHealth(t *testing.T) {
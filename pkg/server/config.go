@@ -348,7 +348,9 @@ func SetOpenFileLimitForOneStore() (int, error) {
 // MakeTempStoreSpecFromStoreSpec creates a spec for a temporary store under
 // the given StoreSpec's path. If the given spec specifies an in-memory store,
 // the temporary store will be in-memory as well. The Attributes field of the
-// given spec is intentionally not propagated to the temporary store.
+// given spec is intentionally not propagated to the temporary store, but the
+// ExtraOptions are so that the temporary store is encrypted if the given
+// store is.
 //
 // TODO(arjun): Add a CLI flag to override this.
 func MakeTempStoreSpecFromStoreSpec(spec base.StoreSpec) base.StoreSpec {
@@ -360,7 +362,8 @@ func MakeTempStoreSpecFromStoreSpec(spec base.StoreSpec) base.StoreSpec {
 		}
 	}
 	return base.StoreSpec{
-		Path: filepath.Join(spec.Path, defaultTempStoreRelativePath),
+		Path:         filepath.Join(spec.Path, defaultTempStoreRelativePath),
+		ExtraOptions: spec.ExtraOptions,
 	}
 }
 
@@ -520,6 +523,10 @@ func (cfg *Config) CreateEngines(ctx context.Context) (Engines, error) {
 				MaxOpenFiles:            openFileLimitPerStore,
 				WarnLargeBatchThreshold: 500 * time.Millisecond,
 				RocksDBSettings:         cfg.Settings.RocksDBSettings,
+				ExtraOptions:            spec.ExtraOptions,
+			}
+			if spec.SeparateRaftLog {
+				rocksDBConfig.RaftLogFormat = engine.RaftLogSeparate
 			}
 
 			eng, err := engine.NewRocksDB(rocksDBConfig, cache)
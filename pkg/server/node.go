@@ -887,6 +887,22 @@ func (n *Node) Batch(
 	return br, nil
 }
 
+// RangeFeed implements the roachpb.InternalServer interface. Errors which
+// end the feed are sent as the stream's last event.
+func (n *Node) RangeFeed(
+	args *roachpb.RangeFeedRequest, stream roachpb.Internal_RangeFeedServer,
+) error {
+	growStack()
+
+	ctx := n.storeCfg.AmbientCtx.AnnotateCtx(stream.Context())
+	if pErr := n.stores.RangeFeed(ctx, args, stream); pErr != nil {
+		var event roachpb.RangeFeedEvent
+		event.SetValue(&roachpb.RangeFeedError{Error: *pErr})
+		return stream.Send(&event)
+	}
+	return nil
+}
+
 // setupSpanForIncomingRPC takes a context and returns a derived context with a
 // new span in it. Depending on the input context, that span might be a root
 // span or a child span. If it is a child span, it might be a child span of a
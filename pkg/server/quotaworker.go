@@ -0,0 +1,110 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/requestquota"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+)
+
+// requestQuotasRecomputeInterval is the interval at which each node's share
+// of the request quotas is recomputed from the number of live nodes.
+const requestQuotasRecomputeInterval = 10 * time.Second
+
+// refreshRequestQuotas starts a worker which loads the request quotas from
+// system.quotas whenever the system config is gossiped. The quotas are
+// cluster-wide, so the worker also periodically recomputes the share of
+// them this node enforces as nodes join or leave the cluster.
+func (s *Server) refreshRequestQuotas() {
+	d := makeSystemTableDecoder(&sqlbase.QuotasTable)
+
+	processKV := func(kv roachpb.KeyValue, quotas map[requestquota.Key]requestquota.Config) error {
+		row, ok, err := d.decodeRow(kv)
+		if err != nil || !ok {
+			return err
+		}
+		key := requestquota.Key{
+			Kind: string(parser.MustBeDString(row[0])),
+			Name: string(parser.MustBeDString(row[1])),
+		}
+		var config requestquota.Config
+		if row[2] != parser.DNull {
+			config.UnitsPerSecond = int64(parser.MustBeDInt(row[2]))
+		}
+		if row[3] != parser.DNull {
+			config.Burst = int64(parser.MustBeDInt(row[3]))
+		}
+		quotas[key] = config
+		return nil
+	}
+
+	// numLiveNodes returns the number of nodes sharing the quotas, which
+	// includes this one even if its liveness hasn't been gossiped yet.
+	numLiveNodes := func() int {
+		n := 0
+		for nodeID, live := range s.nodeLiveness.GetIsLiveMap() {
+			if live && nodeID != s.NodeID() {
+				n++
+			}
+		}
+		return n + 1
+	}
+
+	ctx := s.AnnotateCtx(context.Background())
+	s.stopper.RunWorker(ctx, func(ctx context.Context) {
+		gossipUpdateC := s.gossip.RegisterSystemConfigChannel()
+		ticker := time.NewTicker(requestQuotasRecomputeInterval)
+		defer ticker.Stop()
+		var quotas map[requestquota.Key]requestquota.Config
+		numNodes := 1
+		for {
+			select {
+			case <-gossipUpdateC:
+				cfg, _ := s.gossip.GetSystemConfig()
+				updated := make(map[requestquota.Key]requestquota.Config)
+				ok := true
+				for _, kv := range cfg.Values {
+					if err := processKV(kv, updated); err != nil {
+						log.Warningf(ctx, `error decoding request quotas: %+v
+								this likely indicates the quotas table structure or encoding has been altered;
+								skipping request quota updates`, err)
+						ok = false
+						break
+					}
+				}
+				if !ok {
+					continue
+				}
+				quotas = updated
+				numNodes = numLiveNodes()
+				s.requestQuotas.Update(quotas, numNodes)
+			case <-ticker.C:
+				if n := numLiveNodes(); n != numNodes {
+					numNodes = n
+					s.requestQuotas.Update(quotas, numNodes)
+				}
+			case <-s.stopper.ShouldStop():
+				return
+			}
+		}
+	})
+}
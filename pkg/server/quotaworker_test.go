@@ -0,0 +1,73 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package server_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestRequestQuotasRefresh(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	s, rawDB, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(context.TODO())
+
+	db := sqlutils.MakeSQLRunner(t, rawDB)
+	db.Exec(`CREATE DATABASE d`)
+	db.Exec(`CREATE TABLE d.t (k INT PRIMARY KEY)`)
+	db.Exec(`INSERT INTO system.quotas VALUES ('user', 'root', 1000000, 1000000), ('application', 'idle', 10, 0)`)
+
+	// Once the gossiped quotas have been picked up, writes by root are charged
+	// by the gateway.
+	testutils.SucceedsSoon(t, func() error {
+		db.Exec(`UPSERT INTO d.t VALUES (1)`)
+		var count int
+		db.QueryRow(`SELECT count(*) FROM crdb_internal.node_request_quotas
+			WHERE kind = 'user' AND name = 'root' AND consumed_units > 0`).Scan(&count)
+		if count != 1 {
+			return errors.Errorf("expected root to be charged once, got %d", count)
+		}
+		return nil
+	})
+
+	// A quota without a burst is allowed to burst up to its rate. The only
+	// node enforces all of it.
+	var burst, nodeBurst int
+	db.QueryRow(`SELECT burst, node_burst FROM crdb_internal.node_request_quotas
+		WHERE kind = 'application' AND name = 'idle'`).Scan(&burst, &nodeBurst)
+	if burst != 10 || nodeBurst != 10 {
+		t.Fatalf("expected burst 10 and node burst 10, got %d and %d", burst, nodeBurst)
+	}
+
+	// Deleted quotas are no longer enforced.
+	db.Exec(`DELETE FROM system.quotas WHERE true`)
+	testutils.SucceedsSoon(t, func() error {
+		var count int
+		db.QueryRow(`SELECT count(*) FROM crdb_internal.node_request_quotas`).Scan(&count)
+		if count != 0 {
+			return errors.Errorf("expected no quotas, got %d", count)
+		}
+		return nil
+	})
+}
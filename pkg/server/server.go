@@ -44,6 +44,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
 	"github.com/cockroachdb/cockroach/pkg/kv"
+	"github.com/cockroachdb/cockroach/pkg/kv/requestquota"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
 	"github.com/cockroachdb/cockroach/pkg/security"
@@ -118,6 +119,7 @@ type Server struct {
 	leaseMgr           *sql.LeaseManager
 	sessionRegistry    *sql.SessionRegistry
 	jobRegistry        *jobs.Registry
+	requestQuotas      *requestquota.Quotas
 	engines            Engines
 	internalMemMetrics sql.MemoryMetrics
 	adminMemMetrics    sql.MemoryMetrics
@@ -206,11 +208,13 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		retryOpts = base.DefaultRetryOptions()
 	}
 	retryOpts.Closer = s.stopper.ShouldQuiesce()
+	s.requestQuotas = requestquota.NewQuotas()
 	distSenderCfg := kv.DistSenderConfig{
 		AmbientCtx:      s.cfg.AmbientCtx,
 		Clock:           s.clock,
 		RPCContext:      s.rpcContext,
 		RPCRetryOptions: &retryOpts,
+		RequestQuotas:   s.requestQuotas,
 	}
 	if distSenderTestingKnobs := s.cfg.TestingKnobs.DistSender; distSenderTestingKnobs != nil {
 		distSenderCfg.TestingKnobs = *distSenderTestingKnobs.(*kv.DistSenderTestingKnobs)
@@ -317,6 +321,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		SQLExecutor:                    sqlExecutor,
 		LogRangeEvents:                 s.cfg.EventLogEnabled,
 		TimeSeriesDataStore:            s.tsDB,
+		RequestQuotas:                  s.requestQuotas,
 
 		EnableEpochRangeLeases: true,
 	}
@@ -336,7 +341,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 
 	s.sessionRegistry = sql.MakeSessionRegistry()
 	s.jobRegistry = jobs.MakeRegistry(
-		s.clock, s.db, sqlExecutor, s.gossip, &s.nodeIDContainer, s.ClusterID)
+		s.clock, s.db, s.distSender, sqlExecutor, s.gossip, &s.nodeIDContainer, s.ClusterID)
 
 	distSQLMetrics := distsqlrun.MakeDistSQLMetrics(cfg.HistogramWindowInterval())
 	s.registry.AddMetricStruct(distSQLMetrics)
@@ -408,6 +413,8 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		StatusServer:            s.status,
 		SessionRegistry:         s.sessionRegistry,
 		JobRegistry:             s.jobRegistry,
+		Stores:                  s.node.stores,
+		RequestQuotas:           s.requestQuotas,
 		HistogramWindowInterval: s.cfg.HistogramWindowInterval(),
 		RangeDescriptorCache:    s.distSender.RangeDescriptorCache(),
 		LeaseHolderCache:        s.distSender.LeaseHolderCache(),
@@ -829,6 +836,7 @@ func (s *Server) Start(ctx context.Context) error {
 	log.Event(ctx, "started node")
 
 	s.refreshSettings()
+	s.refreshRequestQuotas()
 
 	raven.SetTagsContext(map[string]string{
 		"cluster":   s.ClusterID().String(),
@@ -1020,6 +1028,10 @@ func (s *Server) Start(ctx context.Context) error {
 		log.Fatal(ctx, err)
 	}
 	log.Infof(ctx, "done ensuring all necessary migrations have run")
+
+	// Scheduled jobs are stored in system.schedules, which is created by a
+	// migration, so the scheduler can only be started once migrations have run.
+	s.jobRegistry.StartScheduler(ctx, s.stopper, jobs.DefaultScheduleInterval)
 	close(serveSQL)
 	log.Info(ctx, "serving sql connections")
 
@@ -29,73 +29,103 @@ import (
 	"github.com/pkg/errors"
 )
 
-// RefreshSettings starts a settings-changes listener.
-func (s *Server) refreshSettings() {
-	tbl := sqlbase.SettingsTable
+// systemTableDecoder decodes the rows of a system table stored in the
+// gossiped system config. The table must have a single column family.
+type systemTableDecoder struct {
+	tbl       *sqlbase.TableDescriptor
+	prefix    roachpb.Key
+	colIdxMap map[sqlbase.ColumnID]int
+	alloc     sqlbase.DatumAlloc
+}
 
-	a := &sqlbase.DatumAlloc{}
-	settingsTablePrefix := keys.MakeTablePrefix(uint32(tbl.ID))
-	colIdxMap := sqlbase.ColIDtoRowIndexFromCols(tbl.Columns)
+func makeSystemTableDecoder(tbl *sqlbase.TableDescriptor) *systemTableDecoder {
+	return &systemTableDecoder{
+		tbl:       tbl,
+		prefix:    keys.MakeTablePrefix(uint32(tbl.ID)),
+		colIdxMap: sqlbase.ColIDtoRowIndexFromCols(tbl.Columns),
+	}
+}
 
-	processKV := func(ctx context.Context, kv roachpb.KeyValue, u settings.Updater) error {
-		if !bytes.HasPrefix(kv.Key, settingsTablePrefix) {
-			return nil
+// decodeRow decodes the row stored in kv, indexed like the table's columns.
+// Columns which aren't present in the row are NULL. ok is false if kv
+// doesn't belong to the table.
+func (d *systemTableDecoder) decodeRow(kv roachpb.KeyValue) (_ parser.Datums, ok bool, _ error) {
+	if !bytes.HasPrefix(kv.Key, d.prefix) {
+		return nil, false, nil
+	}
+	row := make(parser.Datums, len(d.tbl.Columns))
+	for i := range row {
+		row[i] = parser.DNull
+	}
+
+	// First we need to decode the primary key columns from the index key.
+	{
+		keyRow := make([]sqlbase.EncDatum, len(d.tbl.PrimaryIndex.ColumnIDs))
+		for i, colID := range d.tbl.PrimaryIndex.ColumnIDs {
+			keyRow[i].Type = d.tbl.Columns[d.colIdxMap[colID]].Type
+		}
+		_, matches, err := sqlbase.DecodeIndexKey(
+			&d.alloc, d.tbl, d.tbl.PrimaryIndex.ID, keyRow, nil, kv.Key)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to decode key")
 		}
+		if !matches {
+			return nil, false, errors.Errorf(
+				"unexpected non-%s KV with %s prefix: %v", d.tbl.Name, d.tbl.Name, kv.Key)
+		}
+		for i, colID := range d.tbl.PrimaryIndex.ColumnIDs {
+			if err := keyRow[i].EnsureDecoded(&d.alloc); err != nil {
+				return nil, false, err
+			}
+			row[d.colIdxMap[colID]] = keyRow[i].Datum
+		}
+	}
 
-		var k, v, t string
-		// First we need to decode the setting name field from the index key.
-		{
-			nameRow := []sqlbase.EncDatum{{Type: tbl.Columns[0].Type}}
-			_, matches, err := sqlbase.DecodeIndexKey(a, &tbl, tbl.PrimaryIndex.ID, nameRow, nil, kv.Key)
+	// The rest of the columns are stored as a family, packed with diff-encoded
+	// column IDs followed by their values.
+	{
+		bytes, err := kv.Value.GetTuple()
+		if err != nil {
+			return nil, false, err
+		}
+		var colIDDiff uint32
+		var lastColID sqlbase.ColumnID
+		for len(bytes) > 0 {
+			_, _, colIDDiff, _, err = encoding.DecodeValueTag(bytes)
 			if err != nil {
-				return errors.Wrap(err, "failed to decode key")
+				return nil, false, err
 			}
-			if !matches {
-				return errors.Errorf("unexpected non-settings KV with settings prefix: %v", kv.Key)
+			colID := lastColID + sqlbase.ColumnID(colIDDiff)
+			lastColID = colID
+			idx, ok := d.colIdxMap[colID]
+			if !ok {
+				return nil, false, errors.Errorf("unknown column: %v", colID)
 			}
-			if err := nameRow[0].EnsureDecoded(a); err != nil {
-				return err
+			row[idx], bytes, err = sqlbase.DecodeTableValue(
+				&d.alloc, d.tbl.Columns[idx].Type.ToDatumType(), bytes)
+			if err != nil {
+				return nil, false, err
 			}
-			k = string(parser.MustBeDString(nameRow[0].Datum))
 		}
+	}
+	return row, true, nil
+}
 
-		// The rest of the columns are stored as a family, packed with diff-encoded
-		// column IDs followed by their values.
-		{
-			// column valueType can be null (missing) so we default it to "s".
-			t = "s"
-			bytes, err := kv.Value.GetTuple()
-			if err != nil {
-				return err
-			}
-			var colIDDiff uint32
-			var lastColID sqlbase.ColumnID
-			var res parser.Datum
-			for len(bytes) > 0 {
-				_, _, colIDDiff, _, err = encoding.DecodeValueTag(bytes)
-				if err != nil {
-					return err
-				}
-				colID := lastColID + sqlbase.ColumnID(colIDDiff)
-				lastColID = colID
-				if idx, ok := colIdxMap[colID]; ok {
-					res, bytes, err = sqlbase.DecodeTableValue(a, tbl.Columns[idx].Type.ToDatumType(), bytes)
-					if err != nil {
-						return err
-					}
-					switch colID {
-					case tbl.Columns[1].ID: // value
-						v = string(parser.MustBeDString(res))
-					case tbl.Columns[3].ID: // valueType
-						t = string(parser.MustBeDString(res))
-					case tbl.Columns[2].ID: // lastUpdated
-						// TODO(dt): we could decode just the len and then seek `bytes` past
-						// it, without allocating/decoding the unused timestamp.
-					default:
-						return errors.Errorf("unknown column: %v", colID)
-					}
-				}
-			}
+// RefreshSettings starts a settings-changes listener.
+func (s *Server) refreshSettings() {
+	d := makeSystemTableDecoder(&sqlbase.SettingsTable)
+
+	processKV := func(ctx context.Context, kv roachpb.KeyValue, u settings.Updater) error {
+		row, ok, err := d.decodeRow(kv)
+		if err != nil || !ok {
+			return err
+		}
+		k := string(parser.MustBeDString(row[0]))
+		v := string(parser.MustBeDString(row[1]))
+		// column valueType can be null (missing) so we default it to "s".
+		t := "s"
+		if row[3] != parser.DNull {
+			t = string(parser.MustBeDString(row[3]))
 		}
 
 		if err := u.Set(k, v, t); err != nil {
@@ -49,6 +49,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/server/status"
 	"github.com/cockroachdb/cockroach/pkg/sql"
 	"github.com/cockroachdb/cockroach/pkg/storage"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/util/httputil"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
@@ -742,6 +743,7 @@ func (s *statusServer) Ranges(
 				Paused:          progress.Paused,
 				PendingSnapshot: progress.PendingSnapshot,
 				State:           progress.State.String(),
+				IsLearner:       progress.IsLearner,
 			}
 		}
 
@@ -1107,6 +1109,50 @@ func (s *statusServer) SpanStats(
 	return output, nil
 }
 
+// Stores returns details for each store on the node, including the IDs of
+// the keys used to encrypt the store's files.
+func (s *statusServer) Stores(
+	ctx context.Context, req *serverpb.StoresRequest,
+) (*serverpb.StoresResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	nodeID, local, err := s.parseNodeID(req.NodeId)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	if !local {
+		status, err := s.dialNode(nodeID)
+		if err != nil {
+			return nil, err
+		}
+		return status.Stores(ctx, req)
+	}
+
+	resp := &serverpb.StoresResponse{}
+	err = s.stores.VisitStores(func(store *storage.Store) error {
+		storeDetails := serverpb.StoreDetails{
+			StoreID: store.Ident.StoreID,
+		}
+		if rocksdb, ok := store.Engine().(*engine.RocksDB); ok {
+			encStatus, err := rocksdb.GetEncryptionStatus()
+			if err != nil {
+				return err
+			}
+			storeDetails.EncryptionStatus = serverpb.EncryptionStatus{
+				ActiveKeyID: encStatus.ActiveKeyID,
+				TotalFiles:  encStatus.TotalFiles,
+				OldKeyFiles: encStatus.OldKeyFiles,
+			}
+		}
+		resp.Stores = append(resp.Stores, storeDetails)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 // jsonWrapper provides a wrapper on any slice data type being
 // marshaled to JSON. This prevents a security vulnerability
 // where a phishing attack can trick a user's browser into
@@ -501,6 +501,33 @@ func TestRaftDebug(t *testing.T) {
 	}
 }
 
+func TestStoresResponse(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	s := startServer(t)
+	defer s.Stopper().Stop(context.TODO())
+
+	var resp serverpb.StoresResponse
+	if err := getStatusJSONProto(s, "stores/local", &resp); err != nil {
+		t.Fatal(err)
+	}
+	if e, a := 3, len(resp.Stores); e != a {
+		t.Fatalf("expected %d stores, got %d: %+v", e, a, resp.Stores)
+	}
+	seen := make(map[roachpb.StoreID]struct{})
+	for _, store := range resp.Stores {
+		seen[store.StoreID] = struct{}{}
+		// The test server's stores are not encrypted.
+		if store.EncryptionStatus.ActiveKeyID != "" {
+			t.Errorf("s%d: expected no active key, got %s", store.StoreID, store.EncryptionStatus.ActiveKeyID)
+		}
+	}
+	for storeID := roachpb.StoreID(1); storeID <= 3; storeID++ {
+		if _, ok := seen[storeID]; !ok {
+			t.Errorf("expected store %d in response: %+v", storeID, resp.Stores)
+		}
+	}
+}
+
 // TestStatusVars verifies that prometheus metrics are available via the
 // /_status/vars endpoint.
 func TestStatusVars(t *testing.T) {
@@ -109,6 +109,7 @@ func makeTestConfigFromParams(params base.TestServerArgs) Config {
 	}
 	cfg.Insecure = params.Insecure
 	cfg.SocketFile = params.SocketFile
+	cfg.Locality = params.Locality
 	cfg.RetryOptions = params.RetryOptions
 	if params.MetricsSampleInterval != 0 {
 		cfg.MetricsSampleInterval = params.MetricsSampleInterval
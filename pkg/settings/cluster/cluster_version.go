@@ -24,7 +24,7 @@ var (
 	BinaryMinimumSupportedVersion = VersionBase
 
 	// BinaryServerVersion is the version of this binary.
-	BinaryServerVersion = VersionStatsBasedRebalancing
+	BinaryServerVersion = VersionSnapshotSSTables
 )
 
 // List all historical versions here in reverse chronological order, with
@@ -33,6 +33,27 @@ var (
 // NB: when adding a version, don't forget to bump ServerVersion above (and
 // perhaps MinimumSupportedVersion, if necessary).
 var (
+	// VersionSnapshotSSTables allows Raft snapshots to be streamed as
+	// checksummed, resumable chunks of SSTables which the recipient ingests.
+	VersionSnapshotSSTables = roachpb.Version{Major: 1, Minor: 0, Unstable: 8}
+
+	// VersionClearRange allows the ClearRange command, used to drop the
+	// data of tables past their GC TTL with RocksDB range deletions.
+	VersionClearRange = roachpb.Version{Major: 1, Minor: 0, Unstable: 7}
+
+	// VersionParallelCommits allows transactional writes to be pipelined
+	// and transactions to be committed in parallel with their final writes
+	// by moving the transaction record to the STAGING state.
+	VersionParallelCommits = roachpb.Version{Major: 1, Minor: 0, Unstable: 6}
+
+	// VersionNonVoterReplicas allows ranges to contain non-voting replicas,
+	// configured via the num_voters zone config field.
+	VersionNonVoterReplicas = roachpb.Version{Major: 1, Minor: 0, Unstable: 5}
+
+	// VersionLearnerReplicas allows replicas to be added to a range as Raft
+	// learners which are promoted to voters once they've caught up.
+	VersionLearnerReplicas = roachpb.Version{Major: 1, Minor: 0, Unstable: 4}
+
 	// VersionStatsBasedRebalancing is https://github.com/cockroachdb/cockroach/pull/16878.
 	VersionStatsBasedRebalancing = roachpb.Version{Major: 1, Minor: 0, Unstable: 3}
 
@@ -103,6 +103,34 @@ func DistSQLExecModeFromString(val string) DistSQLExecMode {
 	}
 }
 
+// LoadBasedRebalancingMode controls whether range leases and replicas are
+// moved between stores in order to even out the load (i.e. QPS) on them.
+type LoadBasedRebalancingMode int64
+
+const (
+	// LBRebalancingOff means that we never rebalance based on store load.
+	LBRebalancingOff LoadBasedRebalancingMode = iota
+	// LBRebalancingLeasesOnly means that we rebalance leases based on store
+	// load, but not replicas.
+	LBRebalancingLeasesOnly
+	// LBRebalancingLeasesAndReplicas means that we rebalance both leases and
+	// replicas based on store load.
+	LBRebalancingLeasesAndReplicas
+)
+
+func (m LoadBasedRebalancingMode) String() string {
+	switch m {
+	case LBRebalancingOff:
+		return "off"
+	case LBRebalancingLeasesOnly:
+		return "leases"
+	case LBRebalancingLeasesAndReplicas:
+		return "leases and replicas"
+	default:
+		return fmt.Sprintf("invalid (%d)", m)
+	}
+}
+
 // TracingSettings is the subset of ClusterSettings affecting tracing.
 type TracingSettings struct {
 	EnableNetTrace  *settings.BoolSetting
@@ -197,6 +225,8 @@ type RebalancingSettings struct {
 	EnableStatsBasedRebalancing     *settings.BoolSetting
 	StatRebalanceThreshold          *settings.FloatSetting
 	RangeRebalanceThreshold         *settings.FloatSetting
+	LoadBasedRebalancingMode        *settings.EnumSetting
+	QPSRebalanceThreshold           *settings.FloatSetting
 
 	TimeUntilStoreDead *settings.DurationSetting
 }
@@ -218,6 +248,17 @@ type StorageSettings struct {
 	ImportBatchSize             *settings.ByteSizeSetting
 	AddSSTableEnabled           *settings.BoolSetting
 	MaxIntents                  *settings.IntSetting
+	LearnerReplicasEnabled      *settings.BoolSetting
+	NonVoterReadsTargetDuration *settings.DurationSetting
+	WritePipeliningEnabled      *settings.BoolSetting
+	ParallelCommitsEnabled      *settings.BoolSetting
+	ConsistencyRepairEnabled    *settings.BoolSetting
+	RangeFeedCheckpointInterval *settings.DurationSetting
+	RangeFeedClosedTSLag        *settings.DurationSetting
+
+	AdmissionControlEnabled             *settings.BoolSetting
+	AdmissionL0FileThreshold            *settings.IntSetting
+	AdmissionPendingCompactionThreshold *settings.ByteSizeSetting
 }
 
 // UISettings is the subset of ClusterSettings affecting the UI.
@@ -525,11 +566,107 @@ func MakeClusterSettings(minVersion, serverVersion roachpb.Version) *Settings {
 		"minimum fraction away from the mean a store's stats (like disk usage or writes per second) can be before it is considered overfull or underfull",
 		0.20)
 
+	// LoadBasedRebalancingMode controls whether the store rebalancer moves
+	// leases (and possibly replicas) away from stores that are serving more
+	// than their fair share of the cluster's queries.
+	s.LoadBasedRebalancingMode = r.RegisterEnumSetting(
+		"kv.allocator.load_based_rebalancing",
+		"whether to rebalance based on the distribution of QPS across stores",
+		"leases",
+		map[int64]string{
+			int64(LBRebalancingOff):               "off",
+			int64(LBRebalancingLeasesOnly):        "leases",
+			int64(LBRebalancingLeasesAndReplicas): "leases and replicas",
+		},
+	)
+
+	// QPSRebalanceThreshold is the same as StatRebalanceThreshold, but for
+	// queries per second. It is intentionally larger than the other thresholds
+	// since QPS fluctuates a lot more than the other stats, and moving leases
+	// and replicas back and forth in response to noise is worse than leaving
+	// a small imbalance in place.
+	s.QPSRebalanceThreshold = r.RegisterNonNegativeFloatSetting(
+		"kv.allocator.qps_rebalance_threshold",
+		"minimum fraction away from the mean a store's QPS (such as queries per second) can be before it is considered overfull or underfull",
+		0.25)
+
 	s.SyncRaftLog = r.RegisterBoolSetting(
 		"kv.raft_log.synchronize",
 		"set to true to synchronize on Raft log writes to persistent storage",
 		true)
 
+	// LearnerReplicasEnabled controls whether replicas are added to ranges as
+	// Raft learners which are caught up with a snapshot before being promoted
+	// to voters, rather than being added as voters right away.
+	s.LearnerReplicasEnabled = r.RegisterBoolSetting(
+		"kv.learner_replicas.enabled",
+		"set to add new replicas as non-voting learners and promote them to voters once they have caught up",
+		true)
+
+	// NonVoterReadsTargetDuration controls how far in the past the
+	// leaseholder of a range with non-voting replicas closes timestamps to
+	// new writes, which in turn lets the non-voters serve reads at or below
+	// those timestamps.
+	s.NonVoterReadsTargetDuration = r.RegisterNonNegativeDurationSetting(
+		"kv.non_voter_reads.target_duration",
+		"if nonzero, the lease holder of a range with non-voting replicas closes timestamps this far in the past to writes, which lets the non-voters serve reads at or below them",
+		30*time.Second)
+
+	// WritePipeliningEnabled controls whether transactional writes are
+	// acknowledged before they have been replicated, to be proven later.
+	s.WritePipeliningEnabled = r.RegisterBoolSetting(
+		"kv.transaction.write_pipelining_enabled",
+		"if enabled, transactional writes are pipelined through Raft consensus",
+		true)
+
+	// ParallelCommitsEnabled controls whether transactions are committed
+	// by staging their record in parallel with their final writes.
+	s.ParallelCommitsEnabled = r.RegisterBoolSetting(
+		"kv.transaction.parallel_commits_enabled",
+		"if enabled, transactional commits are parallelized with transactional writes",
+		true)
+
+	// ConsistencyRepairEnabled controls whether a replica found to be
+	// inconsistent with a majority of its range is replaced with a fresh
+	// replica instead of crashing the node that detected the inconsistency.
+	s.ConsistencyRepairEnabled = r.RegisterBoolSetting(
+		"kv.consistency_check.repair.enabled",
+		"set to replace replicas that disagree with a majority of their range during a consistency check",
+		false)
+
+	// RangeFeedCheckpointInterval controls how often the leaseholder of a
+	// range with active range feeds closes a timestamp to new writes and
+	// publishes it to the feeds as resolved.
+	s.RangeFeedCheckpointInterval = r.RegisterNonNegativeDurationSetting(
+		"kv.rangefeed.checkpoint_interval",
+		"interval at which range feeds are sent resolved timestamp checkpoints",
+		200*time.Millisecond)
+
+	// RangeFeedClosedTSLag controls how far behind the present the timestamps
+	// closed for range feeds are. Writes below a closed timestamp are pushed
+	// above it, so this bounds how long a transaction can take before its
+	// writes are pushed by the feeds on the ranges it touches.
+	s.RangeFeedClosedTSLag = r.RegisterNonNegativeDurationSetting(
+		"kv.rangefeed.closed_timestamp_lag",
+		"minimum lag behind the present of the resolved timestamps sent to range feeds",
+		5*time.Second)
+
+	// AdmissionControlEnabled controls whether stores hold back writes from
+	// low-priority and bulk operations while RocksDB is behind on compactions,
+	// leaving room for foreground traffic before RocksDB stalls all writes.
+	s.AdmissionControlEnabled = r.RegisterBoolSetting(
+		"kv.admission_control.enabled",
+		"set to delay low-priority and bulk writes while RocksDB is behind on compactions",
+		true)
+	s.AdmissionL0FileThreshold = r.RegisterIntSetting(
+		"kv.admission_control.l0_file_threshold",
+		"number of level 0 sstables at which bulk writes are delayed, and at 1.5x which low-priority writes are (0 to disable)",
+		10)
+	s.AdmissionPendingCompactionThreshold = r.RegisterByteSizeSetting(
+		"kv.admission_control.pending_compaction_threshold",
+		"estimated compaction debt at which bulk writes are delayed, and at 1.5x which low-priority writes are (0 to disable)",
+		32<<30)
+
 	s.MaxCommandSize = r.RegisterByteSizeSetting(
 		"kv.raft.command.max_size",
 		"maximum size of a raft command",
@@ -26,12 +26,15 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/build"
 	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/security"
 	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/storage"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/uuid"
 )
 
 const crdbInternalName = "crdb_internal"
@@ -52,6 +55,9 @@ var crdbInternal = virtualSchema{
 		crdbInternalClusterQueriesTable,
 		crdbInternalLocalSessionsTable,
 		crdbInternalClusterSessionsTable,
+		crdbInternalLocalLockWaitsTable,
+		crdbInternalLocalRequestQuotasTable,
+		crdbInternalRangesTable,
 		crdbInternalBuiltinFunctionsTable,
 		crdbInternalCreateStmtsTable,
 		crdbInternalTableColumnsTable,
@@ -756,6 +762,156 @@ func populateSessionsTable(
 	return nil
 }
 
+// crdbInternalLocalLockWaitsTable exposes the transactions waiting on
+// the completion of other transactions in the push txn queues of the
+// stores on the current node.
+var crdbInternalLocalLockWaitsTable = virtualSchemaTable{
+	schema: `
+CREATE TABLE crdb_internal.node_lock_waits (
+  node_id     INT NOT NULL,        -- the node on which the wait is queued
+  store_id    INT NOT NULL,        -- the store on which the wait is queued
+  range_id    INT NOT NULL,        -- the range holding the pushee's txn record
+  pushee_id   STRING NOT NULL,     -- the transaction being waited on
+  pushee_key  STRING NOT NULL,     -- the anchor key of the pushee
+  pusher_id   STRING,              -- the waiting transaction, NULL if not transactional
+  push_type   STRING NOT NULL,     -- the type of the push
+  start       TIMESTAMP NOT NULL   -- when the wait started
+);
+`,
+	populate: func(ctx context.Context, p *planner, _ string, addRow func(...parser.Datum) error) error {
+		if err := p.RequireSuperUser("read crdb_internal.node_lock_waits"); err != nil {
+			return err
+		}
+		stores := p.session.execCfg.Stores
+		if stores == nil {
+			return nil
+		}
+		nodeID := parser.NewDInt(parser.DInt(int64(p.session.execCfg.NodeID.Get())))
+		return stores.VisitStores(func(s *storage.Store) error {
+			storeID := parser.NewDInt(parser.DInt(int64(s.StoreID())))
+			for _, w := range s.TxnWaits() {
+				pusherID := parser.DNull
+				if w.PusherID != (uuid.UUID{}) {
+					pusherID = parser.NewDString(w.PusherID.String())
+				}
+				if err := addRow(
+					nodeID,
+					storeID,
+					parser.NewDInt(parser.DInt(int64(w.RangeID))),
+					parser.NewDString(w.PusheeID.String()),
+					parser.NewDString(w.PusheeKey.String()),
+					pusherID,
+					parser.NewDString(w.PushType.String()),
+					parser.MakeDTimestamp(w.Start, time.Microsecond),
+				); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	},
+}
+
+// crdbInternalLocalRequestQuotasTable exposes the consumption of the request
+// quotas configured in system.quotas on the current node.
+var crdbInternalLocalRequestQuotasTable = virtualSchemaTable{
+	schema: `
+CREATE TABLE crdb_internal.node_request_quotas (
+  node_id               INT NOT NULL,     -- the node enforcing its share of the quota
+  kind                  STRING NOT NULL,  -- 'user' or 'application'
+  name                  STRING NOT NULL,  -- the SQL user or application_name
+  units_per_second      INT NOT NULL,     -- the cluster-wide allotted request units per second
+  burst                 INT NOT NULL,     -- the cluster-wide allotted burst of request units
+  node_units_per_second INT NOT NULL,     -- the request units per second allotted to this node
+  node_burst            INT NOT NULL,     -- the burst of request units allotted to this node
+  consumed_units        INT NOT NULL,     -- the request units consumed on this node so far
+  throttled_batches     INT NOT NULL      -- the number of batches which had to wait for the quota
+);
+`,
+	populate: func(ctx context.Context, p *planner, _ string, addRow func(...parser.Datum) error) error {
+		if err := p.RequireSuperUser("read crdb_internal.node_request_quotas"); err != nil {
+			return err
+		}
+		quotas := p.session.execCfg.RequestQuotas
+		if quotas == nil {
+			return nil
+		}
+		nodeID := parser.NewDInt(parser.DInt(int64(p.session.execCfg.NodeID.Get())))
+		for _, s := range quotas.Status() {
+			if err := addRow(
+				nodeID,
+				parser.NewDString(s.Key.Kind),
+				parser.NewDString(s.Key.Name),
+				parser.NewDInt(parser.DInt(s.Config.UnitsPerSecond)),
+				parser.NewDInt(parser.DInt(s.Config.Burst)),
+				parser.NewDInt(parser.DInt(s.NodeConfig.UnitsPerSecond)),
+				parser.NewDInt(parser.DInt(s.NodeConfig.Burst)),
+				parser.NewDInt(parser.DInt(s.ConsumedUnits)),
+				parser.NewDInt(parser.DInt(s.ThrottledBatches)),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// crdbInternalRangesTable exposes the descriptors of all the ranges in the
+// cluster, including the learners which are being added to them.
+var crdbInternalRangesTable = virtualSchemaTable{
+	schema: `
+CREATE TABLE crdb_internal.ranges (
+  range_id  INT NOT NULL,
+  start_key STRING NOT NULL,
+  end_key   STRING NOT NULL,
+  replicas  INT[] NOT NULL,   -- the store IDs of the voting replicas
+  learners  INT[] NOT NULL    -- the store IDs of the learners
+);
+`,
+	populate: func(ctx context.Context, p *planner, _ string, addRow func(...parser.Datum) error) error {
+		if err := p.RequireSuperUser("read crdb_internal.ranges"); err != nil {
+			return err
+		}
+		kvs, err := p.txn.Scan(ctx, keys.Meta2Prefix, keys.MetaMax, 0)
+		if err != nil {
+			return err
+		}
+		storeIDs := func(replicas []roachpb.ReplicaDescriptor) (parser.Datum, error) {
+			arr := parser.NewDArray(parser.TypeInt)
+			for _, r := range replicas {
+				if err := arr.Append(parser.NewDInt(parser.DInt(r.StoreID))); err != nil {
+					return nil, err
+				}
+			}
+			return arr, nil
+		}
+		for _, kv := range kvs {
+			var desc roachpb.RangeDescriptor
+			if err := kv.ValueProto(&desc); err != nil {
+				return err
+			}
+			voters, err := storeIDs(desc.Voters())
+			if err != nil {
+				return err
+			}
+			learners, err := storeIDs(desc.Learners())
+			if err != nil {
+				return err
+			}
+			if err := addRow(
+				parser.NewDInt(parser.DInt(desc.RangeID)),
+				parser.NewDString(desc.StartKey.String()),
+				parser.NewDString(desc.EndKey.String()),
+				voters,
+				learners,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
 // crdbInternalBuiltinFunctionsTable exposes the built-in function
 // metadata.
 var crdbInternalBuiltinFunctionsTable = virtualSchemaTable{
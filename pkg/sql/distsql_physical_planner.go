@@ -871,6 +871,7 @@ func (l *DistLoader) LoadCSV(
 	comma, comment rune,
 	nullif *string,
 	walltime int64,
+	encryption *roachpb.FileEncryptionOptions,
 ) error {
 	const (
 		splitSize  = 1024 * 1024 * 32 // 32MB
@@ -1053,6 +1054,7 @@ func (l *DistLoader) LoadCSV(
 			Destination:   to,
 			Name:          fmt.Sprintf("%d.sst", i),
 			WalltimeNanos: walltime,
+			Encryption:    encryption,
 		}
 		proc := distsqlplan.Processor{
 			Node: node.NodeID,
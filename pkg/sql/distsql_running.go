@@ -150,6 +150,7 @@ func (dsp *distSQLPlanner) Run(
 			Txn:         *txn.Proto(),
 			Flow:        flowSpec,
 			EvalContext: evalCtxProto,
+			Source:      txn.RequestSource(),
 		}
 		runReq := runnerRequest{
 			ctx:         ctx,
@@ -189,6 +190,7 @@ func (dsp *distSQLPlanner) Run(
 		Txn:         *txn.Proto(),
 		Flow:        flows[thisNodeID],
 		EvalContext: evalCtxProto,
+		Source:      txn.RequestSource(),
 	}
 	ctx, flow, err := dsp.distSQLSrv.SetupSyncFlow(ctx, &localReq, recv)
 	if err != nil {
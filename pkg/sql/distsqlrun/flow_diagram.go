@@ -257,6 +257,10 @@ func (s *SSTWriterSpec) summary() (string, []string) {
 	return "SSTWriter", []string{fmt.Sprintf("%s/%s", s.Destination, s.Name)}
 }
 
+func (s *CSVWriterSpec) summary() (string, []string) {
+	return "CSVWriter", []string{fmt.Sprintf("%s/%s", s.Destination, s.NamePattern)}
+}
+
 type diagramCell struct {
 	Title   string   `json:"title"`
 	Details []string `json:"details"`
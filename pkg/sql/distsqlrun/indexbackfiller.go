@@ -218,6 +218,9 @@ func (ib *indexBackfiller) runChunk(
 	retried := false
 	// Write the new index values.
 	if err := ib.flowCtx.clientDB.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		// Stores hold back the writes of backfills in favor of foreground
+		// traffic when they're falling behind.
+		txn.SetAdmissionClass(roachpb.AdmissionClass_LOW_PRIORITY)
 		batch := txn.NewBatch()
 
 		for _, entry := range entries {
@@ -252,6 +252,9 @@ func (ds *ServerImpl) setupFlow(
 	// DistSQL transactions get retryable errors that would otherwise be handled
 	// by the TxnCoordSender.
 	txn.AcceptUnhandledRetryableErrors()
+	// The flow's batches are charged against the request quotas of the client
+	// the gateway runs it for.
+	txn.SetRequestSource(req.Source)
 
 	location, err := sqlbase.TimeZoneStringToLocation(req.EvalContext.Location)
 	if err != nil {
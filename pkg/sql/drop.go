@@ -28,6 +28,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/privilege"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 )
 
 type dropDatabaseNode struct {
@@ -839,6 +840,7 @@ func (p *planner) initiateDropTable(ctx context.Context, tableDesc *sqlbase.Tabl
 		return err
 	}
 	tableDesc.State = sqlbase.TableDescriptor_DROP
+	tableDesc.DropTime = timeutil.Now().UnixNano()
 	if err := p.writeTableDesc(ctx, tableDesc); err != nil {
 		return err
 	}
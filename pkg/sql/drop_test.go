@@ -223,8 +223,10 @@ INSERT INTO t.kv VALUES ('c', 'e'), ('a', 'c'), ('b', 'd');
 	}
 	tbDesc := desc.GetTable()
 
-	// Add a zone config for both the table and database.
+	// Add a zone config for both the table and database, with a GC TTL
+	// of zero so that the table's data can be cleared right away.
 	cfg := config.DefaultZoneConfig()
+	cfg.GC.TTLSeconds = 0
 	buf, err := protoutil.Marshal(&cfg)
 	if err != nil {
 		t.Fatal(err)
@@ -595,8 +597,10 @@ func TestDropTableDeleteData(t *testing.T) {
 
 	descKey := sqlbase.MakeDescMetadataKey(sqlbase.ID(gr.ValueInt()))
 
-	// Add a zone config for the table.
+	// Add a zone config for the table, with a GC TTL of zero so that its
+	// data can be cleared right away.
 	cfg := config.DefaultZoneConfig()
+	cfg.GC.TTLSeconds = 0
 	buf, err := protoutil.Marshal(&cfg)
 	if err != nil {
 		t.Fatal(err)
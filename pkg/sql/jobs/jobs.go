@@ -22,10 +22,14 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlutil"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
@@ -65,6 +69,8 @@ type Details interface{}
 var _ Details = BackupDetails{}
 var _ Details = RestoreDetails{}
 var _ Details = SchemaChangeDetails{}
+var _ Details = ChangefeedDetails{}
+var _ Details = ImportDetails{}
 
 // Record stores the job fields that are not automatically managed by Job.
 type Record struct {
@@ -113,6 +119,22 @@ func (e *InvalidStatusError) Error() string {
 	return fmt.Sprintf("cannot %s %s job (id %d)", e.op, e.status, e.id)
 }
 
+// retryJobError is the error returned by a job that should be resumed later,
+// potentially on another node, rather than marked as failed.
+type retryJobError string
+
+// NewRetryJobError creates an error that, when passed to FinishedWith, leaves
+// the job running so that the Registry resumes it once its lease expires. Jobs
+// that run indefinitely return it when interrupted by their node shutting
+// down.
+func NewRetryJobError(reason string) error {
+	return retryJobError(reason)
+}
+
+func (e retryJobError) Error() string {
+	return string(e)
+}
+
 // ID returns the ID of the job that this Job is currently tracking. This will
 // be nil if Created has not yet been called.
 func (j *Job) ID() *int64 {
@@ -128,18 +150,15 @@ var WithoutCancel func()
 // remembers the assigned ID of the job in the Job. The job information is read
 // from the Record field at the time Created is called. If cancelFn is not nil,
 // the Registry will automatically acquire a lease for this job and invoke
-// cancelFn if the lease expires.
+// cancelFn if the lease expires. Calling Created again on a job that has
+// already been created, as a job that runs in several phases does, only
+// replaces its cancelFn.
 func (j *Job) Created(ctx context.Context, cancelFn func()) error {
-	payload := &Payload{
-		Description:   j.Record.Description,
-		Username:      j.Record.Username,
-		DescriptorIDs: j.Record.DescriptorIDs,
-		Details:       WrapPayloadDetails(j.Record.Details),
-	}
+	payload := j.newPayload()
 	if cancelFn != nil {
 		payload.Lease = j.registry.newLease()
 	}
-	if err := j.insert(ctx, payload); err != nil {
+	if err := j.insert(ctx, StatusPending, payload); err != nil {
 		return err
 	}
 	if cancelFn != nil {
@@ -151,6 +170,16 @@ func (j *Job) Created(ctx context.Context, cancelFn func()) error {
 	return nil
 }
 
+// newPayload returns the payload of a new job, built from the Record field.
+func (j *Job) newPayload() *Payload {
+	return &Payload{
+		Description:   j.Record.Description,
+		Username:      j.Record.Username,
+		DescriptorIDs: j.Record.DescriptorIDs,
+		Details:       WrapPayloadDetails(j.Record.Details),
+	}
+}
+
 // Started marks the tracked job as started.
 func (j *Job) Started(ctx context.Context) error {
 	return j.update(ctx, func(status *Status, payload *Payload) (bool, error) {
@@ -348,6 +377,15 @@ func (j *Job) FinishedWith(ctx context.Context, err error) error {
 		// many ambiguous results) than false negatives (too few ambiguous results).
 		return roachpb.NewAmbiguousResultError("job lease expired")
 	}
+	if _, ok := errors.Cause(err).(retryJobError); ok {
+		// Leave the job and its lease in place; the Registry will resume the job
+		// once the lease expires.
+		//
+		// NB: Since we're not calling Succeeded or Failed, we need to manually
+		// unregister the job.
+		j.registry.unregister(*j.id)
+		return err
+	}
 	if err, ok := errors.Cause(err).(*InvalidStatusError); ok &&
 		(err.status == StatusPaused || err.status == StatusCanceled) {
 		// If we couldn't operate on the job because it was paused or canceled, send
@@ -369,6 +407,170 @@ func (j *Job) FinishedWith(ctx context.Context, err error) error {
 	return nil
 }
 
+// ProtectTimestamp prevents the GC queue from collecting the MVCC versions of
+// the given spans which are visible at ts until the job succeeds, fails or is
+// canceled. It replaces any timestamps previously protected by the job, so
+// that a resumed job can call it again. An error is returned if the GC
+// threshold of any of the spans has already reached ts, in which case the
+// job's records are released.
+func (j *Job) ProtectTimestamp(ctx context.Context, spans []roachpb.Span, ts hlc.Timestamp) error {
+	if j.id == nil {
+		return errors.New("Job: cannot protect timestamp: job not created")
+	}
+	if err := j.runInTxn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		if err := j.releaseProtectedTimestamps(ctx, txn); err != nil {
+			return err
+		}
+		const stmt = `INSERT INTO system.protected_ts_records
+			(job_id, wall_time, logical, start_key, end_key) VALUES ($1, $2, $3, $4, $5)`
+		for _, span := range spans {
+			endKey := span.EndKey
+			if len(endKey) == 0 {
+				endKey = span.Key.Next()
+			}
+			if _, err := j.registry.ex.ExecuteStatementInTransaction(
+				ctx, "job-protect-ts", txn, stmt,
+				*j.id, ts.WallTime, int64(ts.Logical), []byte(span.Key), []byte(endKey),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// A GC request that read the records before they committed may still be
+	// in flight. checkBelowGCThreshold orders itself after any such request,
+	// or makes it fail, before verifying that the threshold is below ts.
+	if err := j.checkBelowGCThreshold(ctx, spans, ts); err != nil {
+		if err := j.registry.db.Txn(ctx, j.releaseProtectedTimestamps); err != nil {
+			log.Warningf(ctx, "job %d: failed to release protected timestamps: %s", *j.id, err)
+		}
+		return errors.Wrapf(err, "cannot protect timestamp %s", ts)
+	}
+	return nil
+}
+
+// checkBelowGCThreshold returns an error if a read at ts is rejected by any of
+// the ranges overlapping the spans, which is the case if the range's GC
+// threshold is at or above ts. It must be called after the job's protected
+// timestamp records have committed.
+//
+// The GC queue reads the records at some timestamp before it sends the GC
+// request which advances a range's threshold, and it may spend a long time
+// in between. To catch a GC request whose read missed the records, the
+// protected timestamp check key of each range is first read at the current
+// time, which is above the records' commit timestamp. GC requests declare
+// that key, so one still in flight either applies before the read, in which
+// case the read at ts below fails, or is evaluated after it and rejected
+// because its records are older than the read. See
+// storage.Replica.checkProtectedTSReadAt.
+//
+// The range descriptors are read from the meta ranges, not the range cache,
+// so that the check keys of ranges split off since the records were read by
+// the GC queue aren't missed.
+func (j *Job) checkBelowGCThreshold(
+	ctx context.Context, spans []roachpb.Span, ts hlc.Timestamp,
+) error {
+	var descs []roachpb.RangeDescriptor
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		descs = descs[:0]
+		b := txn.NewBatch()
+		for _, span := range spans {
+			rs, err := addrSpan(span)
+			if err != nil {
+				return err
+			}
+			kvs, err := scanMetaKVs(ctx, txn, rs)
+			if err != nil {
+				return err
+			}
+			for _, metaKV := range kvs {
+				var desc roachpb.RangeDescriptor
+				if err := metaKV.ValueProto(&desc); err != nil {
+					return err
+				}
+				if !desc.StartKey.Less(rs.EndKey) || !rs.Key.Less(desc.EndKey) {
+					continue
+				}
+				descs = append(descs, desc)
+				b.Get(keys.RangeProtectedTSCheckKey(desc.StartKey))
+			}
+		}
+		return txn.Run(ctx, b)
+	}); err != nil {
+		return err
+	}
+
+	// Read a single key from each range at ts.
+	b := &client.Batch{}
+	b.Header.Timestamp = ts
+	for _, span := range spans {
+		rs, err := addrSpan(span)
+		if err != nil {
+			return err
+		}
+		for _, desc := range descs {
+			if !desc.StartKey.Less(rs.EndKey) || !rs.Key.Less(desc.EndKey) {
+				continue
+			}
+			key := rs.Key
+			if key.Less(desc.StartKey) {
+				key = desc.StartKey
+			}
+			b.Get(key.AsRawKey())
+		}
+	}
+	return j.registry.db.Run(ctx, b)
+}
+
+// addrSpan returns the addressable span of the keys in span.
+func addrSpan(span roachpb.Span) (roachpb.RSpan, error) {
+	endKey := span.EndKey
+	if len(endKey) == 0 {
+		endKey = span.Key.Next()
+	}
+	start, err := keys.Addr(span.Key)
+	if err != nil {
+		return roachpb.RSpan{}, err
+	}
+	end, err := keys.AddrUpperBound(endKey)
+	if err != nil {
+		return roachpb.RSpan{}, err
+	}
+	return roachpb.RSpan{Key: start, EndKey: end}, nil
+}
+
+// scanMetaKVs returns the meta KVs for the ranges that touch the given span.
+func scanMetaKVs(
+	ctx context.Context, txn *client.Txn, rs roachpb.RSpan,
+) ([]client.KeyValue, error) {
+	metaStart := keys.RangeMetaKey(rs.Key)
+	metaEnd := keys.RangeMetaKey(rs.EndKey)
+
+	kvs, err := txn.Scan(ctx, metaStart, metaEnd, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(kvs) == 0 || !kvs[len(kvs)-1].Key.Equal(metaEnd) {
+		// Normally we need to scan one more KV because the ranges are addressed by
+		// the end key.
+		extraKV, err := txn.Scan(ctx, metaEnd, keys.Meta2Prefix.PrefixEnd(), 1 /* one result */)
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, extraKV...)
+	}
+	return kvs, nil
+}
+
+func (j *Job) releaseProtectedTimestamps(ctx context.Context, txn *client.Txn) error {
+	const stmt = "DELETE FROM system.protected_ts_records WHERE job_id = $1"
+	_, err := j.registry.ex.ExecuteStatementInTransaction(ctx, "job-release-ts", txn, stmt, *j.id)
+	return err
+}
+
 // SetDetails sets the details field of the currently running tracked job.
 func (j *Job) SetDetails(ctx context.Context, details interface{}) error {
 	return j.update(ctx, func(_ *Status, payload *Payload) (bool, error) {
@@ -398,11 +600,22 @@ func (j *Job) DB() *client.DB {
 	return j.registry.db
 }
 
+// DistSender returns the *kv.DistSender associated with this job.
+func (j *Job) DistSender() *kv.DistSender {
+	return j.registry.distSender
+}
+
 // Gossip returns the *gossip.Gossip associated with this job.
 func (j *Job) Gossip() *gossip.Gossip {
 	return j.registry.gossip
 }
 
+// InternalExecutor returns the sqlutil.InternalExecutor associated with this
+// job.
+func (j *Job) InternalExecutor() sqlutil.InternalExecutor {
+	return j.registry.ex
+}
+
 // NodeID returns the roachpb.NodeID associated with this job.
 func (j *Job) NodeID() roachpb.NodeID {
 	return j.registry.nodeID.Get()
@@ -458,7 +671,7 @@ func (j *Job) load(ctx context.Context) error {
 	return j.initialize(payload)
 }
 
-func (j *Job) insert(ctx context.Context, payload *Payload) error {
+func (j *Job) insert(ctx context.Context, status Status, payload *Payload) error {
 	if j.id != nil {
 		// Already created - do nothing.
 		return nil
@@ -473,7 +686,7 @@ func (j *Job) insert(ctx context.Context, payload *Payload) error {
 		}
 
 		const stmt = "INSERT INTO system.jobs (status, payload) VALUES ($1, $2) RETURNING id"
-		row, err = j.registry.ex.QueryRowInTransaction(ctx, "job-insert", txn, stmt, StatusPending, payloadBytes)
+		row, err = j.registry.ex.QueryRowInTransaction(ctx, "job-insert", txn, stmt, status, payloadBytes)
 		return err
 	}); err != nil {
 		return err
@@ -532,6 +745,11 @@ func (j *Job) update(
 		if n != 1 {
 			return errors.Errorf("Job: expected exactly one row affected, but %d rows affected by job update", n)
 		}
+		if status.Terminal() {
+			// A finished job no longer reads at its protected timestamps, so
+			// release them in the same transaction that finishes it.
+			return j.releaseProtectedTimestamps(ctx, txn)
+		}
 		return nil
 	}); err != nil {
 		return err
@@ -577,6 +795,10 @@ func (p *Payload) Type() Type {
 		return TypeRestore
 	case *Payload_SchemaChange:
 		return TypeSchemaChange
+	case *Payload_Changefeed:
+		return TypeChangefeed
+	case *Payload_Import:
+		return TypeImport
 	default:
 		panic("Payload.Type called on a payload with an unknown details type")
 	}
@@ -597,6 +819,10 @@ func WrapPayloadDetails(details Details) interface {
 		return &Payload_Restore{Restore: &d}
 	case SchemaChangeDetails:
 		return &Payload_SchemaChange{SchemaChange: &d}
+	case ChangefeedDetails:
+		return &Payload_Changefeed{Changefeed: &d}
+	case ImportDetails:
+		return &Payload_Import{Import: &d}
 	default:
 		panic(fmt.Sprintf("jobs.WrapPayloadDetails: unknown details type %T", d))
 	}
@@ -616,6 +842,10 @@ func (p *Payload) UnwrapDetails() (Details, error) {
 		return *d.Restore, nil
 	case *Payload_SchemaChange:
 		return *d.SchemaChange, nil
+	case *Payload_Changefeed:
+		return *d.Changefeed, nil
+	case *Payload_Import:
+		return *d.Import, nil
 	default:
 		return nil, errors.Errorf("jobs.Payload: unsupported details type %T", d)
 	}
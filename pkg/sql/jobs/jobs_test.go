@@ -26,6 +26,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/jobs"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
@@ -676,3 +677,89 @@ func TestJobLifecycle(t *testing.T) {
 		}
 	})
 }
+
+func TestJobProtectTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ctx := context.TODO()
+
+	s, sqlDB, kvDB := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	countRecords := func(job *jobs.Job) int {
+		var n int
+		if err := sqlDB.QueryRow(
+			`SELECT count(*) FROM system.protected_ts_records WHERE job_id = $1`, *job.ID(),
+		).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	spans := []roachpb.Span{
+		{Key: roachpb.Key("a"), EndKey: roachpb.Key("b")},
+		{Key: roachpb.Key("c")},
+	}
+	ts := s.Clock().Now()
+
+	for _, finish := range []struct {
+		name string
+		fn   func(*jobs.Job) error
+	}{
+		{"succeeded", func(job *jobs.Job) error { return job.Succeeded(ctx) }},
+		{"failed", func(job *jobs.Job) error { job.Failed(ctx, errors.New("boom")); return nil }},
+		{"canceled", func(job *jobs.Job) error { return job.Canceled(ctx) }},
+	} {
+		t.Run(finish.name, func(t *testing.T) {
+			job := registry.NewJob(jobs.Record{Details: jobs.BackupDetails{}})
+			if err := job.Created(ctx, jobs.WithoutCancel); err != nil {
+				t.Fatal(err)
+			}
+			if err := job.Started(ctx); err != nil {
+				t.Fatal(err)
+			}
+			// Protecting twice replaces the job's records.
+			for i := 0; i < 2; i++ {
+				if err := job.ProtectTimestamp(ctx, spans, ts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n := countRecords(job); n != len(spans) {
+				t.Fatalf("expected %d protected timestamp records, got %d", len(spans), n)
+			}
+			if err := finish.fn(job); err != nil {
+				t.Fatal(err)
+			}
+			if n := countRecords(job); n != 0 {
+				t.Fatalf("expected protected timestamp records to be released, got %d", n)
+			}
+		})
+	}
+
+	t.Run("below GC threshold", func(t *testing.T) {
+		// Advance the GC threshold of the range containing the spans past ts.
+		b := &client.Batch{}
+		b.AddRawRequest(&roachpb.GCRequest{
+			Span:      roachpb.Span{Key: roachpb.Key("a"), EndKey: roachpb.Key("a").Next()},
+			Threshold: s.Clock().Now(),
+		})
+		if err := kvDB.Run(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+
+		job := registry.NewJob(jobs.Record{Details: jobs.BackupDetails{}})
+		if err := job.Created(ctx, jobs.WithoutCancel); err != nil {
+			t.Fatal(err)
+		}
+		if err := job.ProtectTimestamp(
+			ctx, spans, ts,
+		); !testutils.IsError(err, "must be after GC threshold") {
+			t.Fatalf("expected GC threshold error, got %v", err)
+		}
+		if n := countRecords(job); n != 0 {
+			t.Fatalf("expected protected timestamp records to be released, got %d", n)
+		}
+	})
+}
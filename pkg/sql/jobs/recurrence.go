@@ -0,0 +1,179 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package jobs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// recurrenceDescriptors are the shorthands accepted in place of the five
+// fields of a recurrence.
+var recurrenceDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// recurrenceField is the set of values matched by one of the fields of a
+// recurrence, as a bitmask.
+type recurrenceField uint64
+
+func (f recurrenceField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+// recurrenceBounds are the minimum and maximum values of each of the fields
+// of a recurrence, in order.
+var recurrenceBounds = [...]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// Recurrence is a crontab-style specification of the times at which a
+// schedule runs, evaluated in UTC. It has five fields: minute, hour, day of
+// month, month and day of week. Each field is a comma-separated list of
+// values, ranges (1-5) and steps (*/15, 0-30/10), or * for every value. As in
+// cron, a time matches if it matches both day fields or, if neither of them
+// is *, either of them. The descriptors @yearly, @monthly, @weekly, @daily
+// and @hourly are also accepted.
+type Recurrence struct {
+	minute, hour, dayOfMonth, month, dayOfWeek recurrenceField
+	// anyDayOfMonth and anyDayOfWeek record whether the day fields are *.
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// ParseRecurrence parses a Recurrence.
+func ParseRecurrence(spec string) (*Recurrence, error) {
+	expanded := strings.TrimSpace(spec)
+	if d, ok := recurrenceDescriptors[strings.ToLower(expanded)]; ok {
+		expanded = d
+	}
+	fields := strings.Fields(expanded)
+	if len(fields) != len(recurrenceBounds) {
+		return nil, errors.Errorf(
+			"invalid recurrence %q: expected %d fields, found %d", spec, len(recurrenceBounds), len(fields))
+	}
+	var parsed [len(recurrenceBounds)]recurrenceField
+	for i, field := range fields {
+		var err error
+		if parsed[i], err = parseRecurrenceField(field, recurrenceBounds[i].min, recurrenceBounds[i].max); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s in recurrence %q", recurrenceBounds[i].name, spec)
+		}
+	}
+	r := &Recurrence{
+		minute:        parsed[0],
+		hour:          parsed[1],
+		dayOfMonth:    parsed[2],
+		month:         parsed[3],
+		dayOfWeek:     parsed[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}
+	if r.Next(time.Time{}).IsZero() {
+		return nil, errors.Errorf("invalid recurrence %q: never matches", spec)
+	}
+	return r, nil
+}
+
+func parseRecurrenceField(field string, min, max int) (recurrenceField, error) {
+	var f recurrenceField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid step %q", part[i+1:])
+			}
+			rangePart = part[:i]
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			var err error
+			bounds := strings.SplitN(rangePart, "-", 2)
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.Errorf("invalid value %q", bounds[0])
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errors.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step != 1 {
+				// As in cron, a step applies from the value to the maximum.
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("%q is outside of [%d, %d]", rangePart, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+// recurrenceSearchYears bounds the search for the next time matching a
+// recurrence, which otherwise wouldn't terminate for e.g. February 31st.
+const recurrenceSearchYears = 5
+
+// Next returns the first time strictly after t, truncated to the minute,
+// matched by the recurrence. It returns the zero time if there is none.
+func (r *Recurrence) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + recurrenceSearchYears
+	for t.Year() <= limit {
+		if !r.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !r.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !r.hour.has(t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !r.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (r *Recurrence) matchesDay(t time.Time) bool {
+	dom, dow := r.dayOfMonth.has(t.Day()), r.dayOfWeek.has(int(t.Weekday()))
+	if r.anyDayOfMonth || r.anyDayOfWeek {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	parse := func(s string) time.Time {
+		ts, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+
+	// 2017-01-01 was a Sunday.
+	testCases := []struct {
+		spec     string
+		from     string
+		expected string
+	}{
+		{"@hourly", "2017-01-01 00:00", "2017-01-01 01:00"},
+		{"@hourly", "2017-01-01 00:59", "2017-01-01 01:00"},
+		{"@daily", "2017-01-01 00:00", "2017-01-02 00:00"},
+		{"@daily", "2017-12-31 23:00", "2018-01-01 00:00"},
+		{"@weekly", "2017-01-01 00:00", "2017-01-08 00:00"},
+		{"@monthly", "2017-01-15 12:00", "2017-02-01 00:00"},
+		{"@yearly", "2017-01-01 00:00", "2018-01-01 00:00"},
+		{"*/15 * * * *", "2017-01-01 00:07", "2017-01-01 00:15"},
+		{"30 2-4 * * *", "2017-01-01 04:30", "2017-01-02 02:30"},
+		{"0 0 * * 1-5", "2017-01-06 12:00", "2017-01-09 00:00"},
+		{"0 12 1,15 * *", "2017-01-02 00:00", "2017-01-15 12:00"},
+		// Both day fields are restricted, so either matches.
+		{"0 0 13 * 5", "2017-01-01 00:00", "2017-01-06 00:00"},
+		{"0 0 29 2 *", "2017-01-01 00:00", "2020-02-29 00:00"},
+	}
+	for _, tc := range testCases {
+		r, err := ParseRecurrence(tc.spec)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.spec, err)
+		}
+		if next := r.Next(parse(tc.from)); !next.Equal(parse(tc.expected)) {
+			t.Errorf("%s: expected next run after %s at %s, got %s", tc.spec, tc.from, tc.expected, next)
+		}
+	}
+}
+
+func TestParseRecurrenceErrors(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		spec     string
+		expected string
+	}{
+		{"", "expected 5 fields, found 0"},
+		{"@fortnightly", "expected 5 fields, found 1"},
+		{"* * * *", "expected 5 fields, found 4"},
+		{"60 * * * *", `invalid minute in recurrence "60 \* \* \* \*": "60" is outside of \[0, 59\]`},
+		{"* * 0 * *", `invalid day of month .*: "0" is outside of \[1, 31\]`},
+		{"* 5-1 * * *", `invalid hour .*: "5-1" is outside of \[0, 23\]`},
+		{"*/0 * * * *", `invalid minute .*: invalid step "0"`},
+		{"a * * * *", `invalid minute .*: invalid value "a"`},
+		{"0 0 31 2 *", "never matches"},
+	}
+	for _, tc := range testCases {
+		if _, err := ParseRecurrence(tc.spec); !testutils.IsError(err, tc.expected) {
+			t.Errorf("%q: expected error %q, got %v", tc.spec, tc.expected, err)
+		}
+	}
+}
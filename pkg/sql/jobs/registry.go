@@ -24,6 +24,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlutil"
@@ -44,12 +45,13 @@ type nodeLiveness interface {
 
 // Registry creates Jobs and manages their leases and cancelation.
 type Registry struct {
-	db        *client.DB
-	ex        sqlutil.InternalExecutor
-	gossip    *gossip.Gossip
-	clock     *hlc.Clock
-	nodeID    *base.NodeIDContainer
-	clusterID func() uuid.UUID
+	db         *client.DB
+	distSender *kv.DistSender
+	ex         sqlutil.InternalExecutor
+	gossip     *gossip.Gossip
+	clock      *hlc.Clock
+	nodeID     *base.NodeIDContainer
+	clusterID  func() uuid.UUID
 
 	mu struct {
 		syncutil.Mutex
@@ -62,12 +64,16 @@ type Registry struct {
 func MakeRegistry(
 	clock *hlc.Clock,
 	db *client.DB,
+	distSender *kv.DistSender,
 	ex sqlutil.InternalExecutor,
 	gossip *gossip.Gossip,
 	nodeID *base.NodeIDContainer,
 	clusterID func() uuid.UUID,
 ) *Registry {
-	r := &Registry{clock: clock, db: db, ex: ex, gossip: gossip, nodeID: nodeID, clusterID: clusterID}
+	r := &Registry{
+		clock: clock, db: db, distSender: distSender, ex: ex, gossip: gossip, nodeID: nodeID,
+		clusterID: clusterID,
+	}
 	r.mu.epoch = 1
 	r.mu.jobs = make(map[int64]*Job)
 	return r
@@ -268,12 +274,7 @@ func (r *Registry) maybeAdoptJob(ctx context.Context, nl nodeLiveness) error {
 			continue
 		}
 
-		var resumeFn func(context.Context, *Job) error
-		for _, hook := range resumeHooks {
-			if resumeFn = hook(payload.Type()); resumeFn != nil {
-				break
-			}
-		}
+		resumeFn := resumeFn(payload.Type())
 		if resumeFn == nil {
 			if log.V(2) {
 				log.Infof(ctx, "job %d: skipping: no resume functions are available", *id)
@@ -281,40 +282,14 @@ func (r *Registry) maybeAdoptJob(ctx context.Context, nl nodeLiveness) error {
 			continue
 		}
 
-		job := Job{id: id, registry: r}
+		job := &Job{id: id, registry: r}
 		if err := job.adopt(ctx, payload.Lease); err != nil {
 			if log.V(2) {
 				log.Infof(ctx, "skipping job %d: unable to acquire lease: %s", *id, err)
 			}
 			continue
 		}
-
-		go func() {
-			log.Infof(ctx, "job %d: resuming", *id)
-			err := resumeFn(ctx, &job)
-			if _, isDuplicate := errors.Cause(err).(*duplicateRegistrationError); isDuplicate {
-				// Another turn of the adoption loop already resumed this job. Swallow
-				// the error, as the job is properly resumed.
-				//
-				// This happens because job registration is asynchronous. This
-				// goroutine, not the adoption loop's goroutine, is responsible for
-				// calling Registry.register. There's a window where resumeFn has not
-				// yet registered the job with the registry, so the next turn of the
-				// adoption loop will see it holds the lease on a job that's not
-				// running, and attempt to resume it again. This likely never happens in
-				// practice because DefaultAdoptInterval is several orders of magnitude
-				// larger than the delay between resuming a job and that job registering
-				// itself. In tests, though, double resumption is a real possibility, as
-				// the DefaultAdoptInterval gets turned down an order of magnitude.
-				//
-				// TODO(benesch): make the adoption loop synchronously register the jobs
-				// it resumes. This requires API changes to "invert control"; see the
-				// TODOs in jobs.go for details.
-			} else if err := job.FinishedWith(ctx, err); err != nil {
-				// Nowhere to report this error but the log.
-				log.Errorf(ctx, "job %d: ignoring FinishedWith error: %+v", *id, err)
-			}
-		}()
+		r.resume(ctx, job, resumeFn)
 
 		// Only adopt one job per turn to allow other nodes their fair share.
 		break
@@ -323,6 +298,51 @@ func (r *Registry) maybeAdoptJob(ctx context.Context, nl nodeLiveness) error {
 	return nil
 }
 
+// resumeFn returns the resume hook for the given job type, or nil if there is
+// none.
+func resumeFn(typ Type) func(context.Context, *Job) error {
+	for _, hook := range resumeHooks {
+		if fn := hook(typ); fn != nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+// resume runs resumeFn for a job whose lease is held by this node, then marks
+// the job as finished.
+func (r *Registry) resume(
+	ctx context.Context, job *Job, resumeFn func(context.Context, *Job) error,
+) {
+	go func() {
+		id := job.ID()
+		log.Infof(ctx, "job %d: resuming", *id)
+		err := resumeFn(ctx, job)
+		if _, isDuplicate := errors.Cause(err).(*duplicateRegistrationError); isDuplicate {
+			// Another turn of the adoption loop already resumed this job. Swallow
+			// the error, as the job is properly resumed.
+			//
+			// This happens because job registration is asynchronous. This
+			// goroutine, not the adoption loop's goroutine, is responsible for
+			// calling Registry.register. There's a window where resumeFn has not
+			// yet registered the job with the registry, so the next turn of the
+			// adoption loop will see it holds the lease on a job that's not
+			// running, and attempt to resume it again. This likely never happens in
+			// practice because DefaultAdoptInterval is several orders of magnitude
+			// larger than the delay between resuming a job and that job registering
+			// itself. In tests, though, double resumption is a real possibility, as
+			// the DefaultAdoptInterval gets turned down an order of magnitude.
+			//
+			// TODO(benesch): make the adoption loop synchronously register the jobs
+			// it resumes. This requires API changes to "invert control"; see the
+			// TODOs in jobs.go for details.
+		} else if err := job.FinishedWith(ctx, err); err != nil {
+			// Nowhere to report this error but the log.
+			log.Errorf(ctx, "job %d: ignoring FinishedWith error: %+v", *id, err)
+		}
+	}()
+}
+
 func (r *Registry) cancelAll(ctx context.Context) {
 	r.mu.AssertHeld()
 	for jobID, job := range r.mu.jobs {
@@ -353,7 +373,7 @@ func (e *duplicateRegistrationError) Error() string {
 func (r *Registry) register(jobID int64, j *Job) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.mu.jobs[jobID]; ok {
+	if existing, ok := r.mu.jobs[jobID]; ok && existing != j {
 		return &duplicateRegistrationError{jobID: jobID}
 	}
 	r.mu.jobs[jobID] = j
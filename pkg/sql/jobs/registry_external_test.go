@@ -76,7 +76,7 @@ func TestRegistryResumeExpiredLease(t *testing.T) {
 	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
 	nodeID := &base.NodeIDContainer{}
 
-	registry := jobs.MakeRegistry(clock, db, ex, gossip, nodeID, jobs.FakeClusterID)
+	registry := jobs.MakeRegistry(clock, db, nil /* distSender */, ex, gossip, nodeID, jobs.FakeClusterID)
 	nodeLiveness := jobs.NewFakeNodeLiveness(clock, 4)
 
 	const cancelInterval = time.Duration(math.MaxInt64)
@@ -41,7 +41,7 @@ func TestRegistryCancelation(t *testing.T) {
 	var ex sqlutil.InternalExecutor
 	var gossip *gossip.Gossip
 	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
-	registry := MakeRegistry(clock, db, ex, gossip, FakeNodeID, FakeClusterID)
+	registry := MakeRegistry(clock, db, nil /* distSender */, ex, gossip, FakeNodeID, FakeClusterID)
 
 	const nodeCount = 1
 	nodeLiveness := NewFakeNodeLiveness(clock, nodeCount)
@@ -128,9 +128,15 @@ func TestRegistryRegister(t *testing.T) {
 	var ex sqlutil.InternalExecutor
 	var gossip *gossip.Gossip
 	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
-	registry := MakeRegistry(clock, db, ex, gossip, FakeNodeID, FakeClusterID)
+	registry := MakeRegistry(clock, db, nil /* distSender */, ex, gossip, FakeNodeID, FakeClusterID)
 
-	if err := registry.register(42, &Job{}); err != nil {
+	job := &Job{}
+	if err := registry.register(42, job); err != nil {
+		t.Fatal(err)
+	}
+
+	// Registering the same job again is not an error.
+	if err := registry.register(42, job); err != nil {
 		t.Fatal(err)
 	}
 
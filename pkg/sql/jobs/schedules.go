@@ -0,0 +1,304 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package jobs
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// Schedule is a recurring job stored in the system.schedules table. Every
+// time the recurrence is due, one node of the cluster runs the schedule hook
+// registered for its details, which typically starts a job.
+//
+// The fields other than ID and NextRun can be directly modified before
+// Registry.CreateSchedule is called.
+type Schedule struct {
+	ID          int64
+	Description string
+	Owner       string
+	Recurrence  string
+	NextRun     time.Time
+	Details     ScheduleDetails
+
+	registry *Registry
+	// txn is the transaction in which a run of the schedule is claimed. It is
+	// only set while the ScheduleRunFn is being called.
+	txn *client.Txn
+	// started are the jobs started by the current run.
+	started []*Job
+	// afterCommit are the functions to call once the current run is claimed.
+	afterCommit []func(context.Context)
+}
+
+// DefaultScheduleInterval is a reasonable interval at which to poll
+// system.schedules for schedules that are due.
+//
+// DefaultScheduleInterval is mutable for testing. NB: Updates to this value
+// after Registry.StartScheduler has been called will not have any effect.
+var DefaultScheduleInterval = time.Minute
+
+// ScheduleRunFn runs a schedule that is due. It is called in the transaction
+// that claims the run, and changes it makes to the schedule's details are
+// written in that transaction. If it returns an error, the run is skipped.
+type ScheduleRunFn func(ctx context.Context, s *Schedule) error
+
+type scheduleHookFn func(*ScheduleDetails) ScheduleRunFn
+
+var scheduleHooks []scheduleHookFn
+
+// AddScheduleHook adds a schedule hook.
+func AddScheduleHook(fn scheduleHookFn) {
+	scheduleHooks = append(scheduleHooks, fn)
+}
+
+// CreateSchedule validates the schedule's recurrence and inserts the schedule
+// into system.schedules, to first run at the next time matching the
+// recurrence. The ID and NextRun fields are set accordingly.
+func (r *Registry) CreateSchedule(ctx context.Context, s *Schedule) error {
+	recurrence, err := ParseRecurrence(s.Recurrence)
+	if err != nil {
+		return err
+	}
+	nextRun := recurrence.Next(timeutil.Now())
+	detailsBytes, err := protoutil.Marshal(&s.Details)
+	if err != nil {
+		return err
+	}
+
+	var row parser.Datums
+	if err := r.db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		const stmt = `INSERT INTO system.schedules (description, owner, recurrence, next_run, details)
+VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		var err error
+		row, err = r.ex.QueryRowInTransaction(
+			ctx, "schedule-insert", txn, stmt, s.Description, s.Owner, s.Recurrence, nextRun, detailsBytes)
+		return err
+	}); err != nil {
+		return err
+	}
+	s.ID = int64(*row[0].(*parser.DInt))
+	s.NextRun = nextRun
+	s.registry = r
+	return nil
+}
+
+// StartScheduler polls system.schedules for schedules that are due and runs
+// them.
+func (r *Registry) StartScheduler(ctx context.Context, stopper *stop.Stopper, interval time.Duration) {
+	stopper.RunWorker(context.Background(), func(ctx context.Context) {
+		for {
+			select {
+			case <-time.After(interval):
+				if err := r.maybeRunSchedules(ctx); err != nil {
+					log.Errorf(ctx, "error while running schedules: %+v", err)
+				}
+			case <-stopper.ShouldStop():
+				return
+			}
+		}
+	})
+}
+
+func (r *Registry) maybeRunSchedules(ctx context.Context) error {
+	var rows []parser.Datums
+	if err := r.db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		const stmt = `SELECT id FROM system.schedules WHERE next_run <= $1 ORDER BY next_run`
+		var err error
+		rows, err = r.ex.QueryRowsInTransaction(ctx, "schedules-due", txn, stmt, timeutil.Now())
+		return err
+	}); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		id := int64(*row[0].(*parser.DInt))
+		if err := r.runSchedule(ctx, id); err != nil {
+			log.Errorf(ctx, "schedule %d: %+v", id, err)
+		}
+	}
+	return nil
+}
+
+// runSchedule runs the schedule with the given ID if it is still due. The run
+// is claimed by advancing next_run in the same transaction, so that a
+// schedule is run by a single node even if several of them find it due.
+func (r *Registry) runSchedule(ctx context.Context, id int64) error {
+	var s *Schedule
+	var runErr error
+	if err := r.db.Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		var err error
+		s, err = r.loadSchedule(ctx, txn, id)
+		if err != nil || s == nil {
+			return err
+		}
+		now := timeutil.Now()
+		if s.NextRun.After(now) {
+			// Another node already ran the schedule.
+			s = nil
+			return nil
+		}
+		recurrence, err := ParseRecurrence(s.Recurrence)
+		if err != nil {
+			return err
+		}
+		nextRun := recurrence.Next(now)
+
+		var runFn ScheduleRunFn
+		for _, hook := range scheduleHooks {
+			if runFn = hook(&s.Details); runFn != nil {
+				break
+			}
+		}
+		if runFn == nil {
+			runErr = errors.Errorf("no schedule hook for %s", s.Details.String())
+		} else {
+			s.txn, s.started, s.afterCommit = txn, nil, nil
+			runErr = runFn(ctx, s)
+			s.txn = nil
+		}
+		if runErr != nil {
+			// Skip this run, leaving the details as they were.
+			s.started, s.afterCommit = nil, nil
+			const stmt = `UPDATE system.schedules SET next_run = $2 WHERE id = $1`
+			_, err := r.ex.ExecuteStatementInTransaction(ctx, "schedule-skip", txn, stmt, id, nextRun)
+			return err
+		}
+		detailsBytes, err := protoutil.Marshal(&s.Details)
+		if err != nil {
+			return err
+		}
+		const stmt = `UPDATE system.schedules SET next_run = $2, details = $3 WHERE id = $1`
+		_, err = r.ex.ExecuteStatementInTransaction(ctx, "schedule-run", txn, stmt, id, nextRun, detailsBytes)
+		return err
+	}); err != nil {
+		return err
+	}
+	if s == nil {
+		return nil
+	}
+	if runErr != nil {
+		log.Warningf(ctx, "schedule %d: skipping run: %+v", id, runErr)
+	}
+
+	// The jobs started by the run hold a lease from this node, so this node is
+	// responsible for running them.
+	for _, job := range s.started {
+		fn := resumeFn(job.Payload().Type())
+		if fn == nil {
+			log.Warningf(ctx, "schedule %d: no resume function for job %d", id, *job.ID())
+			continue
+		}
+		r.resume(ctx, job, fn)
+	}
+	for _, fn := range s.afterCommit {
+		fn(ctx)
+	}
+	return nil
+}
+
+// loadSchedule reads the schedule with the given ID from system.schedules. It
+// returns nil if there is no such schedule.
+func (r *Registry) loadSchedule(ctx context.Context, txn *client.Txn, id int64) (*Schedule, error) {
+	const stmt = `SELECT description, owner, recurrence, next_run, details
+FROM system.schedules WHERE id = $1`
+	row, err := r.ex.QueryRowInTransaction(ctx, "schedule-load", txn, stmt, id)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	s := &Schedule{
+		ID:          id,
+		Description: string(*row[0].(*parser.DString)),
+		Owner:       string(*row[1].(*parser.DString)),
+		Recurrence:  string(*row[2].(*parser.DString)),
+		NextRun:     row[3].(*parser.DTimestamp).Time,
+		registry:    r,
+	}
+	if err := protoutil.Unmarshal([]byte(*row[4].(*parser.DBytes)), &s.Details); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// StartJob creates a job for the record, in the transaction that claims the
+// current run of the schedule. The job is created as running, with a lease
+// held by this node, and is resumed by this node once the run is claimed. It
+// must only be called from a ScheduleRunFn.
+func (s *Schedule) StartJob(ctx context.Context, record Record) (int64, error) {
+	if s.txn == nil {
+		return 0, errors.Errorf("schedule %d: cannot start job outside of a run", s.ID)
+	}
+	job := s.registry.NewJob(record)
+	payload := job.newPayload()
+	payload.StartedMicros = timeutil.ToUnixMicros(timeutil.Now())
+	payload.Lease = s.registry.newLease()
+	if err := job.WithTxn(s.txn).insert(ctx, StatusRunning, payload); err != nil {
+		return 0, err
+	}
+	// The job must not keep using the transaction once the run is claimed.
+	job.WithTxn(nil)
+	s.started = append(s.started, job)
+	return *job.ID(), nil
+}
+
+// AfterCommit registers a function to be called once the current run of the
+// schedule, along with the changes it made to the schedule's details, has
+// been committed. It is not called if the run is skipped. It must only be
+// called from a ScheduleRunFn.
+func (s *Schedule) AfterCommit(fn func(ctx context.Context)) {
+	s.afterCommit = append(s.afterCommit, fn)
+}
+
+// JobStatus returns the status of the job with the given ID, or the empty
+// status if there is no such job. It must only be called from a
+// ScheduleRunFn.
+func (s *Schedule) JobStatus(ctx context.Context, jobID int64) (Status, error) {
+	if s.txn == nil {
+		return "", errors.Errorf("schedule %d: cannot read job outside of a run", s.ID)
+	}
+	const stmt = "SELECT status FROM system.jobs WHERE id = $1"
+	row, err := s.registry.ex.QueryRowInTransaction(ctx, "schedule-job-status", s.txn, stmt, jobID)
+	if err != nil || row == nil {
+		return "", err
+	}
+	return Status(*row[0].(*parser.DString)), nil
+}
+
+// DB returns the *client.DB associated with the schedule.
+func (s *Schedule) DB() *client.DB {
+	return s.registry.db
+}
+
+// Txn returns the transaction that claims the current run of the schedule. It
+// is only set while the ScheduleRunFn is being called.
+func (s *Schedule) Txn() *client.Txn {
+	return s.txn
+}
+
+// Now returns the current time of the transaction that claims the current
+// run of the schedule.
+func (s *Schedule) Now() hlc.Timestamp {
+	return s.txn.OrigTimestamp()
+}
@@ -16,10 +16,12 @@ package parser
 
 import "bytes"
 
-// Backup represents a BACKUP statement.
+// Backup represents a BACKUP statement. A BACKUP without targets backs up the
+// full cluster. A BACKUP to more than one location is locality-aware: each
+// range is backed up to the location of its leaseholder's locality.
 type Backup struct {
 	Targets         TargetList
-	To              Expr
+	To              Exprs
 	IncrementalFrom Exprs
 	AsOf            AsOfClause
 	Options         KVOptions
@@ -30,9 +32,12 @@ var _ Statement = &Backup{}
 // Format implements the NodeFormatter interface.
 func (node *Backup) Format(buf *bytes.Buffer, f FmtFlags) {
 	buf.WriteString("BACKUP ")
-	FormatNode(buf, f, node.Targets)
-	buf.WriteString(" TO ")
-	FormatNode(buf, f, node.To)
+	if !node.Targets.Empty() {
+		FormatNode(buf, f, node.Targets)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("TO ")
+	formatBackupLocations(buf, f, node.To)
 	if node.AsOf.Expr != nil {
 		buf.WriteString(" ")
 		FormatNode(buf, f, node.AsOf)
@@ -47,10 +52,41 @@ func (node *Backup) Format(buf *bytes.Buffer, f FmtFlags) {
 	}
 }
 
-// Restore represents a RESTORE statement.
+// CreateBackupSchedule represents a CREATE SCHEDULE FOR BACKUP statement.
+type CreateBackupSchedule struct {
+	Targets              TargetList
+	To                   Expr
+	Recurrence           Expr
+	FullBackupRecurrence Expr
+	Options              KVOptions
+}
+
+var _ Statement = &CreateBackupSchedule{}
+
+// Format implements the NodeFormatter interface.
+func (node *CreateBackupSchedule) Format(buf *bytes.Buffer, f FmtFlags) {
+	buf.WriteString("CREATE SCHEDULE FOR BACKUP ")
+	FormatNode(buf, f, node.Targets)
+	buf.WriteString(" TO ")
+	FormatNode(buf, f, node.To)
+	buf.WriteString(" RECURRING ")
+	FormatNode(buf, f, node.Recurrence)
+	if node.FullBackupRecurrence != nil {
+		buf.WriteString(" FULL BACKUP ")
+		FormatNode(buf, f, node.FullBackupRecurrence)
+	}
+	if node.Options != nil {
+		buf.WriteString(" WITH ")
+		FormatNode(buf, f, node.Options)
+	}
+}
+
+// Restore represents a RESTORE statement. A RESTORE without targets restores a
+// full cluster backup. From holds the locations of each of the backups being
+// restored, which are more than one for a locality-aware backup.
 type Restore struct {
 	Targets TargetList
-	From    Exprs
+	From    []Exprs
 	AsOf    AsOfClause
 	Options KVOptions
 }
@@ -60,9 +96,17 @@ var _ Statement = &Restore{}
 // Format implements the NodeFormatter interface.
 func (node *Restore) Format(buf *bytes.Buffer, f FmtFlags) {
 	buf.WriteString("RESTORE ")
-	FormatNode(buf, f, node.Targets)
-	buf.WriteString(" FROM ")
-	FormatNode(buf, f, node.From)
+	if !node.Targets.Empty() {
+		FormatNode(buf, f, node.Targets)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("FROM ")
+	for i, from := range node.From {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		formatBackupLocations(buf, f, from)
+	}
 	if node.AsOf.Expr != nil {
 		buf.WriteString(" ")
 		FormatNode(buf, f, node.AsOf)
@@ -73,6 +117,18 @@ func (node *Restore) Format(buf *bytes.Buffer, f FmtFlags) {
 	}
 }
 
+// formatBackupLocations formats the locations of a backup: a single location
+// on its own, and those of a locality-aware backup as a parenthesized list.
+func formatBackupLocations(buf *bytes.Buffer, f FmtFlags, locations Exprs) {
+	if len(locations) == 1 {
+		FormatNode(buf, f, locations[0])
+		return
+	}
+	buf.WriteByte('(')
+	FormatNode(buf, f, locations)
+	buf.WriteByte(')')
+}
+
 // KVOption is a key-value option.
 type KVOption struct {
 	Key   Name
@@ -40,6 +40,11 @@ type TargetList struct {
 	Tables    TablePatterns
 }
 
+// Empty returns whether the TargetList names no targets at all.
+func (tl TargetList) Empty() bool {
+	return len(tl.Databases) == 0 && len(tl.Tables) == 0
+}
+
 // Format implements the NodeFormatter interface.
 func (tl TargetList) Format(buf *bytes.Buffer, f FmtFlags) {
 	if tl.Databases != nil {
@@ -4,20 +4,20 @@
 package parser
 
 var helpMessages = map[string]HelpMessageBody{
-	//line sql.y: 921
+	//line sql.y: 931
 	`ALTER`: {
-		//line sql.y: 922
+		//line sql.y: 932
 		Category: hGroup,
-		//line sql.y: 923
+		//line sql.y: 933
 		Text: `ALTER TABLE, ALTER INDEX, ALTER VIEW, ALTER DATABASE
 `,
 	},
-	//line sql.y: 931
+	//line sql.y: 941
 	`ALTER TABLE`: {
 		ShortDescription: `change the definition of a table`,
-		//line sql.y: 932
+		//line sql.y: 942
 		Category: hDDL,
-		//line sql.y: 933
+		//line sql.y: 943
 		Text: `
 ALTER TABLE [IF EXISTS] <tablename> <command> [, ...]
 
@@ -41,42 +41,42 @@ Column qualifiers:
   COLLATE <collationname>
 
 `,
-		//line sql.y: 955
+		//line sql.y: 965
 		SeeAlso: `https://www.cockroachlabs.com/docs/alter-table.html
 `,
 	},
-	//line sql.y: 966
+	//line sql.y: 976
 	`ALTER VIEW`: {
 		ShortDescription: `change the definition of a view`,
-		//line sql.y: 967
+		//line sql.y: 977
 		Category: hDDL,
-		//line sql.y: 968
+		//line sql.y: 978
 		Text: `
 ALTER VIEW [IF EXISTS] <name> RENAME TO <newname>
 `,
-		//line sql.y: 970
+		//line sql.y: 980
 		SeeAlso: `https://www.cockroachlabs.com/docs/alter-view.html
 `,
 	},
-	//line sql.y: 977
+	//line sql.y: 987
 	`ALTER DATABASE`: {
 		ShortDescription: `change the definition of a database`,
-		//line sql.y: 978
+		//line sql.y: 988
 		Category: hDDL,
-		//line sql.y: 979
+		//line sql.y: 989
 		Text: `
 ALTER DATABASE <name> RENAME TO <newname>
 `,
-		//line sql.y: 981
+		//line sql.y: 991
 		SeeAlso: `https://www.cockroachlabs.com/docs/alter-database.html
 `,
 	},
-	//line sql.y: 988
+	//line sql.y: 998
 	`ALTER INDEX`: {
 		ShortDescription: `change the definition of an index`,
-		//line sql.y: 989
+		//line sql.y: 999
 		Category: hDDL,
-		//line sql.y: 990
+		//line sql.y: 1000
 		Text: `
 ALTER INDEX [IF EXISTS] <idxname> <command>
 
@@ -86,18 +86,18 @@ Commands:
   ALTER INDEX ... SCATTER [ FROM ( <exprs...> ) TO ( <exprs...> ) ]
 
 `,
-		//line sql.y: 998
+		//line sql.y: 1008
 		SeeAlso: `https://www.cockroachlabs.com/docs/alter-index.html
 `,
 	},
-	//line sql.y: 1214
+	//line sql.y: 1224
 	`BACKUP`: {
 		ShortDescription: `back up data to external storage`,
-		//line sql.y: 1215
+		//line sql.y: 1225
 		Category: hCCL,
-		//line sql.y: 1216
+		//line sql.y: 1226
 		Text: `
-BACKUP <targets...> TO <location...>
+BACKUP [ <targets...> ] TO <location...>
        [ AS OF SYSTEM TIME <expr> ]
        [ INCREMENTAL FROM <location...> ]
        [ WITH <option> [= <value>] [, ...] ]
@@ -105,52 +105,116 @@ BACKUP <targets...> TO <location...>
 Targets:
    TABLE <pattern> [, ...]
    DATABASE <databasename> [, ...]
+   (none: back up the full cluster, including its users, zone configs,
+    cluster settings, UI data and jobs)
 
 Location:
    "[scheme]://[host]/[path to backup]?[parameters]"
+   ( <location with COCKROACH_LOCALITY=default>,
+     <location with COCKROACH_LOCALITY=[key]%3D[value]> [, ...] )
 
 Options:
-   INTO_DB
-   SKIP_MISSING_FOREIGN_KEYS
+   REVISION_HISTORY
+   ENCRYPTION_PASSPHRASE = '...'
 
 `,
-		//line sql.y: 1233
+		//line sql.y: 1247
 		SeeAlso: `RESTORE, https://www.cockroachlabs.com/docs/backup.html
 `,
 	},
-	//line sql.y: 1241
+	//line sql.y: 1259
 	`RESTORE`: {
 		ShortDescription: `restore data from external storage`,
-		//line sql.y: 1242
+		//line sql.y: 1260
 		Category: hCCL,
-		//line sql.y: 1243
+		//line sql.y: 1261
 		Text: `
-RESTORE <targets...> FROM <location...>
+RESTORE [ <targets...> ] FROM <location...>
         [ AS OF SYSTEM TIME <expr> ]
         [ WITH <option> [= <value>] [, ...] ]
 
 Targets:
    TABLE <pattern> [, ...]
    DATABASE <databasename> [, ...]
+   (none: restore a full cluster backup into an empty cluster)
 
 Locations:
    "[scheme]://[host]/[path to backup]?[parameters]"
+   ( <location with COCKROACH_LOCALITY=default>,
+     <location with COCKROACH_LOCALITY=[key]%3D[value]> [, ...] )
 
 Options:
    INTO_DB
    SKIP_MISSING_FOREIGN_KEYS
+   ENCRYPTION_PASSPHRASE = '...'
 
 `,
-		//line sql.y: 1259
+		//line sql.y: 1281
 		SeeAlso: `BACKUP, https://www.cockroachlabs.com/docs/restore.html
 `,
 	},
-	//line sql.y: 1273
+	//line sql.y: 1293
+	`CREATE CHANGEFEED`: {
+		ShortDescription: `stream row changes to external storage`,
+		//line sql.y: 1294
+		Category: hCCL,
+		//line sql.y: 1295
+		Text: `
+CREATE CHANGEFEED FOR <targets...> INTO <sink>
+       [ WITH <option> [= <value>] [, ...] ]
+
+Targets:
+   TABLE <tablename> [, ...]
+
+Sink:
+   "[scheme]://[host]/[path]?[parameters]"
+
+Options:
+   cursor = '...'
+   resolved
+
+`,
+		//line sql.y: 1309
+		SeeAlso: `SHOW JOBS, CANCEL JOB
+`,
+	},
+	//line sql.y: 1317
+	`CREATE SCHEDULE`: {
+		ShortDescription: `take backups on a recurring schedule`,
+		//line sql.y: 1318
+		Category: hCCL,
+		//line sql.y: 1319
+		Text: `
+CREATE SCHEDULE FOR BACKUP <targets...> TO <location>
+       RECURRING <recurrence>
+       [ FULL BACKUP <recurrence> ]
+       [ WITH <option> [= <value>] [, ...] ]
+
+Targets:
+   TABLE <pattern> [, ...]
+   DATABASE <databasename> [, ...]
+
+Location:
+   "[scheme]://[host]/[path to collection]?[parameters]"
+
+Recurrence:
+   "<minute> <hour> <day of month> <month> <day of week>"
+   '@hourly', '@daily', '@weekly', '@monthly', '@yearly'
+
+Options:
+   retention = '<interval>'
+
+`,
+		//line sql.y: 1339
+		SeeAlso: `BACKUP, SHOW JOBS
+`,
+	},
+	//line sql.y: 1367
 	`IMPORT`: {
 		ShortDescription: `load data from file in a distributed manner`,
-		//line sql.y: 1274
+		//line sql.y: 1368
 		Category: hCCL,
-		//line sql.y: 1275
+		//line sql.y: 1369
 		Text: `
 IMPORT TABLE <tablename>
        { ( <elements> ) | CREATE USING <schemafile> }
@@ -158,159 +222,193 @@ IMPORT TABLE <tablename>
        DATA ( <datafile> [, ...] )
        [ WITH <option> [= <value>] [, ...] ]
 
+IMPORT INTO <tablename>
+       <format>
+       DATA ( <datafile> [, ...] )
+       [ WITH <option> [= <value>] [, ...] ]
+
+IMPORT { PGDUMP | MYSQLDUMP }
+       DATA ( <dumpfile> [, ...] )
+       [ WITH <option> [= <value>] [, ...] ]
+
 Formats:
    CSV
+   PGDUMP
+   MYSQLDUMP
 
 Options:
    distributed = '...'
    sstsize = '...'
    temp = '...'
+   encryption_passphrase = '...'
    comma = '...'          [CSV-specific]
    comment = '...'        [CSV-specific]
    nullif = '...'         [CSV-specific]
 
 `,
-		//line sql.y: 1293
+		//line sql.y: 1399
 		SeeAlso: `CREATE TABLE
 `,
 	},
-	//line sql.y: 1390
+	//line sql.y: 1423
+	`EXPORT`: {
+		ShortDescription: `export data to file in a distributed manner`,
+		//line sql.y: 1424
+		Category: hCCL,
+		//line sql.y: 1425
+		Text: `
+EXPORT INTO <format> <datafile> [WITH <option> [= value] [,...]] FROM <query>
+
+Formats:
+   CSV
+
+Options:
+   delimiter = '...'   [CSV-specific]
+   nullas = '...'      [CSV-specific]
+   chunk_rows = '...'
+
+`,
+		//line sql.y: 1436
+		SeeAlso: `SELECT
+`,
+	},
+	//line sql.y: 1547
 	`CANCEL`: {
-		//line sql.y: 1391
+		//line sql.y: 1548
 		Category: hGroup,
-		//line sql.y: 1392
+		//line sql.y: 1549
 		Text: `CANCEL JOB, CANCEL QUERY
 `,
 	},
-	//line sql.y: 1398
+	//line sql.y: 1555
 	`CANCEL JOB`: {
 		ShortDescription: `cancel a background job`,
-		//line sql.y: 1399
+		//line sql.y: 1556
 		Category: hMisc,
-		//line sql.y: 1400
+		//line sql.y: 1557
 		Text: `CANCEL JOB <jobid>
 `,
-		//line sql.y: 1401
+		//line sql.y: 1558
 		SeeAlso: `SHOW JOBS, PAUSE JOBS, RESUME JOB
 `,
 	},
-	//line sql.y: 1410
+	//line sql.y: 1567
 	`CANCEL QUERY`: {
 		ShortDescription: `cancel a running query`,
-		//line sql.y: 1411
+		//line sql.y: 1568
 		Category: hMisc,
-		//line sql.y: 1412
+		//line sql.y: 1569
 		Text: `CANCEL QUERY <queryid>
 `,
-		//line sql.y: 1413
+		//line sql.y: 1570
 		SeeAlso: `SHOW QUERIES
 `,
 	},
-	//line sql.y: 1422
+	//line sql.y: 1579
 	`CREATE`: {
-		//line sql.y: 1423
+		//line sql.y: 1580
 		Category: hGroup,
-		//line sql.y: 1424
+		//line sql.y: 1581
 		Text: `
 CREATE DATABASE, CREATE TABLE, CREATE INDEX, CREATE TABLE AS,
-CREATE USER, CREATE VIEW
+CREATE USER, CREATE VIEW, CREATE CHANGEFEED, CREATE SCHEDULE
 `,
 	},
-	//line sql.y: 1438
+	//line sql.y: 1597
 	`DELETE`: {
 		ShortDescription: `delete rows from a table`,
-		//line sql.y: 1439
+		//line sql.y: 1598
 		Category: hDML,
-		//line sql.y: 1440
+		//line sql.y: 1599
 		Text: `DELETE FROM <tablename> [WHERE <expr>] [RETURNING <exprs...>]
 `,
-		//line sql.y: 1441
+		//line sql.y: 1600
 		SeeAlso: `https://www.cockroachlabs.com/docs/delete.html
 `,
 	},
-	//line sql.y: 1449
+	//line sql.y: 1608
 	`DISCARD`: {
 		ShortDescription: `reset the session to its initial state`,
-		//line sql.y: 1450
+		//line sql.y: 1609
 		Category: hCfg,
-		//line sql.y: 1451
+		//line sql.y: 1610
 		Text: `DISCARD ALL
 `,
 	},
-	//line sql.y: 1463
+	//line sql.y: 1622
 	`DROP`: {
-		//line sql.y: 1464
+		//line sql.y: 1623
 		Category: hGroup,
-		//line sql.y: 1465
+		//line sql.y: 1624
 		Text: `DROP DATABASE, DROP INDEX, DROP TABLE, DROP VIEW, DROP USER
 `,
 	},
-	//line sql.y: 1474
+	//line sql.y: 1633
 	`DROP VIEW`: {
 		ShortDescription: `remove a view`,
-		//line sql.y: 1475
+		//line sql.y: 1634
 		Category: hDDL,
-		//line sql.y: 1476
+		//line sql.y: 1635
 		Text: `DROP VIEW [IF EXISTS] <tablename> [, ...] [CASCADE | RESTRICT]
 `,
-		//line sql.y: 1477
+		//line sql.y: 1636
 		SeeAlso: `https://www.cockroachlabs.com/docs/drop-index.html
 `,
 	},
-	//line sql.y: 1489
+	//line sql.y: 1648
 	`DROP TABLE`: {
 		ShortDescription: `remove a table`,
-		//line sql.y: 1490
+		//line sql.y: 1649
 		Category: hDDL,
-		//line sql.y: 1491
+		//line sql.y: 1650
 		Text: `DROP TABLE [IF EXISTS] <tablename> [, ...] [CASCADE | RESTRICT]
 `,
-		//line sql.y: 1492
+		//line sql.y: 1651
 		SeeAlso: `https://www.cockroachlabs.com/docs/drop-table.html
 `,
 	},
-	//line sql.y: 1504
+	//line sql.y: 1663
 	`DROP INDEX`: {
 		ShortDescription: `remove an index`,
-		//line sql.y: 1505
+		//line sql.y: 1664
 		Category: hDDL,
-		//line sql.y: 1506
+		//line sql.y: 1665
 		Text: `DROP INDEX [IF EXISTS] <idxname> [, ...] [CASCADE | RESTRICT]
 `,
-		//line sql.y: 1507
+		//line sql.y: 1666
 		SeeAlso: `https://www.cockroachlabs.com/docs/drop-index.html
 `,
 	},
-	//line sql.y: 1527
+	//line sql.y: 1686
 	`DROP DATABASE`: {
 		ShortDescription: `remove a database`,
-		//line sql.y: 1528
+		//line sql.y: 1687
 		Category: hDDL,
-		//line sql.y: 1529
+		//line sql.y: 1688
 		Text: `DROP DATABASE [IF EXISTS] <databasename>
 `,
-		//line sql.y: 1530
+		//line sql.y: 1689
 		SeeAlso: `https://www.cockroachlabs.com/docs/drop-database.html
 `,
 	},
-	//line sql.y: 1542
+	//line sql.y: 1701
 	`DROP USER`: {
 		ShortDescription: `remove a user`,
-		//line sql.y: 1543
+		//line sql.y: 1702
 		Category: hPriv,
-		//line sql.y: 1544
+		//line sql.y: 1703
 		Text: `DROP USER [IF EXISTS] <user> [, ...]
 `,
-		//line sql.y: 1545
+		//line sql.y: 1704
 		SeeAlso: `CREATE USER, SHOW USERS
 `,
 	},
-	//line sql.y: 1587
+	//line sql.y: 1746
 	`EXPLAIN`: {
 		ShortDescription: `show the logical plan of a query`,
-		//line sql.y: 1588
+		//line sql.y: 1747
 		Category: hMisc,
-		//line sql.y: 1589
+		//line sql.y: 1748
 		Text: `
 EXPLAIN <statement>
 EXPLAIN [( [PLAN ,] <planoptions...> )] <statement>
@@ -323,52 +421,52 @@ Plan options:
     TYPES, EXPRS, METADATA, QUALIFY, INDENT, VERBOSE, DIST_SQL
 
 `,
-		//line sql.y: 1600
+		//line sql.y: 1759
 		SeeAlso: `https://www.cockroachlabs.com/docs/explain.html
 `,
 	},
-	//line sql.y: 1650
+	//line sql.y: 1809
 	`PREPARE`: {
 		ShortDescription: `prepare a statement for later execution`,
-		//line sql.y: 1651
+		//line sql.y: 1810
 		Category: hMisc,
-		//line sql.y: 1652
+		//line sql.y: 1811
 		Text: `PREPARE <name> [ ( <types...> ) ] AS <query>
 `,
-		//line sql.y: 1653
+		//line sql.y: 1812
 		SeeAlso: `EXECUTE, DEALLOCATE, DISCARD
 `,
 	},
-	//line sql.y: 1675
+	//line sql.y: 1834
 	`EXECUTE`: {
 		ShortDescription: `execute a statement prepared previously`,
-		//line sql.y: 1676
+		//line sql.y: 1835
 		Category: hMisc,
-		//line sql.y: 1677
+		//line sql.y: 1836
 		Text: `EXECUTE <name> [ ( <exprs...> ) ]
 `,
-		//line sql.y: 1678
+		//line sql.y: 1837
 		SeeAlso: `PREPARE, DEALLOCATE, DISCARD
 `,
 	},
-	//line sql.y: 1701
+	//line sql.y: 1860
 	`DEALLOCATE`: {
 		ShortDescription: `remove a prepared statement`,
-		//line sql.y: 1702
+		//line sql.y: 1861
 		Category: hMisc,
-		//line sql.y: 1703
+		//line sql.y: 1862
 		Text: `DEALLOCATE [PREPARE] { <name> | ALL }
 `,
-		//line sql.y: 1704
+		//line sql.y: 1863
 		SeeAlso: `PREPARE, EXECUTE, DISCARD
 `,
 	},
-	//line sql.y: 1724
+	//line sql.y: 1883
 	`GRANT`: {
 		ShortDescription: `define access privileges`,
-		//line sql.y: 1725
+		//line sql.y: 1884
 		Category: hPriv,
-		//line sql.y: 1726
+		//line sql.y: 1885
 		Text: `
 GRANT {ALL | <privileges...> } ON <targets...> TO <grantees...>
 
@@ -380,16 +478,16 @@ Targets:
   [TABLE] [<databasename> .] { <tablename> | * } [, ...]
 
 `,
-		//line sql.y: 1736
+		//line sql.y: 1895
 		SeeAlso: `REVOKE, https://www.cockroachlabs.com/docs/grant.html
 `,
 	},
-	//line sql.y: 1744
+	//line sql.y: 1903
 	`REVOKE`: {
 		ShortDescription: `remove access privileges`,
-		//line sql.y: 1745
+		//line sql.y: 1904
 		Category: hPriv,
-		//line sql.y: 1746
+		//line sql.y: 1905
 		Text: `
 REVOKE {ALL | <privileges...> } ON <targets...> FROM <grantees...>
 
@@ -401,58 +499,58 @@ Targets:
   [TABLE] [<databasename> .] { <tablename> | * } [, ...]
 
 `,
-		//line sql.y: 1756
+		//line sql.y: 1915
 		SeeAlso: `GRANT, https://www.cockroachlabs.com/docs/revoke.html
 `,
 	},
-	//line sql.y: 1839
+	//line sql.y: 1998
 	`RESET`: {
 		ShortDescription: `reset a session variable to its default value`,
-		//line sql.y: 1840
+		//line sql.y: 1999
 		Category: hCfg,
-		//line sql.y: 1841
+		//line sql.y: 2000
 		Text: `RESET [SESSION] <var>
 `,
-		//line sql.y: 1842
+		//line sql.y: 2001
 		SeeAlso: `https://www.cockroachlabs.com/docs/set-vars.html
 `,
 	},
-	//line sql.y: 1872
+	//line sql.y: 2031
 	`SET CLUSTER SETTING`: {
 		ShortDescription: `change a cluster setting`,
-		//line sql.y: 1873
+		//line sql.y: 2032
 		Category: hCfg,
-		//line sql.y: 1874
+		//line sql.y: 2033
 		Text: `SET CLUSTER SETTING <var> { TO | = } <value>
 `,
-		//line sql.y: 1875
+		//line sql.y: 2034
 		SeeAlso: `SHOW CLUSTER SETTING, SET SESSION,
 https://www.cockroachlabs.com/docs/cluster-settings.html
 `,
 	},
-	//line sql.y: 1893
+	//line sql.y: 2052
 	`SET SESSION`: {
 		ShortDescription: `change a session variable`,
-		//line sql.y: 1894
+		//line sql.y: 2053
 		Category: hCfg,
-		//line sql.y: 1895
+		//line sql.y: 2054
 		Text: `
 SET [SESSION] <var> { TO | = } <values...>
 SET [SESSION] TIME ZONE <tz>
 SET [SESSION] CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL { SNAPSHOT | SERIALIZABLE }
 
 `,
-		//line sql.y: 1900
+		//line sql.y: 2059
 		SeeAlso: `SHOW SESSION, RESET, DISCARD, SHOW, SET CLUSTER SETTING, SET TRANSACTION,
 https://www.cockroachlabs.com/docs/set-vars.html
 `,
 	},
-	//line sql.y: 1917
+	//line sql.y: 2076
 	`SET TRANSACTION`: {
 		ShortDescription: `configure the transaction settings`,
-		//line sql.y: 1918
+		//line sql.y: 2077
 		Category: hTxn,
-		//line sql.y: 1919
+		//line sql.y: 2078
 		Text: `
 SET [SESSION] TRANSACTION <txnparameters...>
 
@@ -461,245 +559,245 @@ Transaction parameters:
    PRIORITY { LOW | NORMAL | HIGH }
 
 `,
-		//line sql.y: 1926
+		//line sql.y: 2085
 		SeeAlso: `SHOW TRANSACTION, SET SESSION,
 https://www.cockroachlabs.com/docs/set-transaction.html
 `,
 	},
-	//line sql.y: 2101
+	//line sql.y: 2260
 	`SHOW`: {
-		//line sql.y: 2102
+		//line sql.y: 2261
 		Category: hGroup,
-		//line sql.y: 2103
+		//line sql.y: 2262
 		Text: `
 SHOW SESSION, SHOW CLUSTER SETTING, SHOW DATABASES, SHOW TABLES, SHOW COLUMNS, SHOW INDEXES,
 SHOW CONSTRAINTS, SHOW CREATE TABLE, SHOW CREATE VIEW, SHOW USERS, SHOW TRANSACTION, SHOW BACKUP,
 SHOW JOBS, SHOW QUERIES, SHOW SESSIONS, SHOW TRACE
 `,
 	},
-	//line sql.y: 2128
+	//line sql.y: 2287
 	`SHOW SESSION`: {
 		ShortDescription: `display session variables`,
-		//line sql.y: 2129
+		//line sql.y: 2288
 		Category: hCfg,
-		//line sql.y: 2130
+		//line sql.y: 2289
 		Text: `SHOW [SESSION] { <var> | ALL }
 `,
-		//line sql.y: 2131
+		//line sql.y: 2290
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-vars.html
 `,
 	},
-	//line sql.y: 2152
+	//line sql.y: 2311
 	`SHOW BACKUP`: {
 		ShortDescription: `list backup contents`,
-		//line sql.y: 2153
+		//line sql.y: 2312
 		Category: hCCL,
-		//line sql.y: 2154
-		Text: `SHOW BACKUP <location>
+		//line sql.y: 2313
+		Text: `SHOW BACKUP <location> [ WITH <option> [= <value>] [, ...] ]
 `,
-		//line sql.y: 2155
+		//line sql.y: 2314
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-backup.html
 `,
 	},
-	//line sql.y: 2163
+	//line sql.y: 2322
 	`SHOW CLUSTER SETTING`: {
 		ShortDescription: `display cluster settings`,
-		//line sql.y: 2164
+		//line sql.y: 2323
 		Category: hCfg,
-		//line sql.y: 2165
+		//line sql.y: 2324
 		Text: `
 SHOW CLUSTER SETTING <var>
 SHOW ALL CLUSTER SETTINGS
 `,
-		//line sql.y: 2168
+		//line sql.y: 2327
 		SeeAlso: `https://www.cockroachlabs.com/docs/cluster-settings.html
 `,
 	},
-	//line sql.y: 2185
+	//line sql.y: 2344
 	`SHOW COLUMNS`: {
 		ShortDescription: `list columns in relation`,
-		//line sql.y: 2186
+		//line sql.y: 2345
 		Category: hDDL,
-		//line sql.y: 2187
+		//line sql.y: 2346
 		Text: `SHOW COLUMNS FROM <tablename>
 `,
-		//line sql.y: 2188
+		//line sql.y: 2347
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-columns.html
 `,
 	},
-	//line sql.y: 2196
+	//line sql.y: 2355
 	`SHOW DATABASES`: {
 		ShortDescription: `list databases`,
-		//line sql.y: 2197
+		//line sql.y: 2356
 		Category: hDDL,
-		//line sql.y: 2198
+		//line sql.y: 2357
 		Text: `SHOW DATABASES
 `,
-		//line sql.y: 2199
+		//line sql.y: 2358
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-databases.html
 `,
 	},
-	//line sql.y: 2207
+	//line sql.y: 2366
 	`SHOW GRANTS`: {
 		ShortDescription: `list grants`,
-		//line sql.y: 2208
+		//line sql.y: 2367
 		Category: hPriv,
-		//line sql.y: 2209
+		//line sql.y: 2368
 		Text: `SHOW GRANTS [ON <targets...>] [FOR <users...>]
 `,
-		//line sql.y: 2210
+		//line sql.y: 2369
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-grants.html
 `,
 	},
-	//line sql.y: 2218
+	//line sql.y: 2377
 	`SHOW INDEXES`: {
 		ShortDescription: `list indexes`,
-		//line sql.y: 2219
+		//line sql.y: 2378
 		Category: hDDL,
-		//line sql.y: 2220
+		//line sql.y: 2379
 		Text: `SHOW INDEXES FROM <tablename>
 `,
-		//line sql.y: 2221
+		//line sql.y: 2380
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-indexes.html
 `,
 	},
-	//line sql.y: 2239
+	//line sql.y: 2398
 	`SHOW CONSTRAINTS`: {
 		ShortDescription: `list constraints`,
-		//line sql.y: 2240
+		//line sql.y: 2399
 		Category: hDDL,
-		//line sql.y: 2241
+		//line sql.y: 2400
 		Text: `SHOW CONSTRAINTS FROM <tablename>
 `,
-		//line sql.y: 2242
+		//line sql.y: 2401
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-constraints.html
 `,
 	},
-	//line sql.y: 2255
+	//line sql.y: 2414
 	`SHOW QUERIES`: {
 		ShortDescription: `list running queries`,
-		//line sql.y: 2256
+		//line sql.y: 2415
 		Category: hMisc,
-		//line sql.y: 2257
+		//line sql.y: 2416
 		Text: `SHOW [CLUSTER | LOCAL] QUERIES
 `,
-		//line sql.y: 2258
+		//line sql.y: 2417
 		SeeAlso: `CANCEL QUERY
 `,
 	},
-	//line sql.y: 2274
+	//line sql.y: 2433
 	`SHOW JOBS`: {
 		ShortDescription: `list background jobs`,
-		//line sql.y: 2275
+		//line sql.y: 2434
 		Category: hMisc,
-		//line sql.y: 2276
+		//line sql.y: 2435
 		Text: `SHOW JOBS
 `,
-		//line sql.y: 2277
+		//line sql.y: 2436
 		SeeAlso: `CANCEL JOB, PAUSE JOB, RESUME JOB
 `,
 	},
-	//line sql.y: 2285
+	//line sql.y: 2444
 	`SHOW TRACE`: {
 		ShortDescription: `display an execution trace`,
-		//line sql.y: 2286
+		//line sql.y: 2445
 		Category: hMisc,
-		//line sql.y: 2287
+		//line sql.y: 2446
 		Text: `
 SHOW [KV] TRACE FOR SESSION
 SHOW [KV] TRACE FOR <statement>
 `,
-		//line sql.y: 2290
+		//line sql.y: 2449
 		SeeAlso: `EXPLAIN
 `,
 	},
-	//line sql.y: 2311
+	//line sql.y: 2470
 	`SHOW SESSIONS`: {
 		ShortDescription: `list open client sessions`,
-		//line sql.y: 2312
+		//line sql.y: 2471
 		Category: hMisc,
-		//line sql.y: 2313
+		//line sql.y: 2472
 		Text: `SHOW [CLUSTER | LOCAL] SESSIONS
 `,
 	},
-	//line sql.y: 2329
+	//line sql.y: 2488
 	`SHOW TABLES`: {
 		ShortDescription: `list tables`,
-		//line sql.y: 2330
+		//line sql.y: 2489
 		Category: hDDL,
-		//line sql.y: 2331
+		//line sql.y: 2490
 		Text: `SHOW TABLES [FROM <databasename>]
 `,
-		//line sql.y: 2332
+		//line sql.y: 2491
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-tables.html
 `,
 	},
-	//line sql.y: 2344
+	//line sql.y: 2503
 	`SHOW TRANSACTION`: {
 		ShortDescription: `display current transaction properties`,
-		//line sql.y: 2345
+		//line sql.y: 2504
 		Category: hCfg,
-		//line sql.y: 2346
+		//line sql.y: 2505
 		Text: `SHOW TRANSACTION {ISOLATION LEVEL | PRIORITY | STATUS}
 `,
-		//line sql.y: 2347
+		//line sql.y: 2506
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-transaction.html
 `,
 	},
-	//line sql.y: 2366
+	//line sql.y: 2525
 	`SHOW CREATE TABLE`: {
 		ShortDescription: `display the CREATE TABLE statement for a table`,
-		//line sql.y: 2367
+		//line sql.y: 2526
 		Category: hDDL,
-		//line sql.y: 2368
+		//line sql.y: 2527
 		Text: `SHOW CREATE TABLE <tablename>
 `,
-		//line sql.y: 2369
+		//line sql.y: 2528
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-create-table.html
 `,
 	},
-	//line sql.y: 2377
+	//line sql.y: 2536
 	`SHOW CREATE VIEW`: {
 		ShortDescription: `display the CREATE VIEW statement for a view`,
-		//line sql.y: 2378
+		//line sql.y: 2537
 		Category: hDDL,
-		//line sql.y: 2379
+		//line sql.y: 2538
 		Text: `SHOW CREATE VIEW <viewname>
 `,
-		//line sql.y: 2380
+		//line sql.y: 2539
 		SeeAlso: `https://www.cockroachlabs.com/docs/show-create-view.html
 `,
 	},
-	//line sql.y: 2388
+	//line sql.y: 2547
 	`SHOW USERS`: {
 		ShortDescription: `list defined users`,
-		//line sql.y: 2389
+		//line sql.y: 2548
 		Category: hPriv,
-		//line sql.y: 2390
+		//line sql.y: 2549
 		Text: `SHOW USERS
 `,
-		//line sql.y: 2391
+		//line sql.y: 2550
 		SeeAlso: `CREATE USER, DROP USER, https://www.cockroachlabs.com/docs/show-users.html
 `,
 	},
-	//line sql.y: 2443
+	//line sql.y: 2602
 	`PAUSE JOB`: {
 		ShortDescription: `pause a background job`,
-		//line sql.y: 2444
+		//line sql.y: 2603
 		Category: hMisc,
-		//line sql.y: 2445
+		//line sql.y: 2604
 		Text: `PAUSE JOB <jobid>
 `,
-		//line sql.y: 2446
+		//line sql.y: 2605
 		SeeAlso: `SHOW JOBS, CANCEL JOB, RESUME JOB
 `,
 	},
-	//line sql.y: 2455
+	//line sql.y: 2614
 	`CREATE TABLE`: {
 		ShortDescription: `create a new table`,
-		//line sql.y: 2456
+		//line sql.y: 2615
 		Category: hDDL,
-		//line sql.y: 2457
+		//line sql.y: 2616
 		Text: `
 CREATE TABLE [IF NOT EXISTS] <tablename> ( <elements...> ) [<interleave>]
 CREATE TABLE [IF NOT EXISTS] <tablename> [( <colnames...> )] AS <source>
@@ -727,54 +825,54 @@ Interleave clause:
    INTERLEAVE IN PARENT <tablename> ( <colnames...> ) [CASCADE | RESTRICT]
 
 `,
-		//line sql.y: 2483
+		//line sql.y: 2642
 		SeeAlso: `SHOW TABLES, CREATE VIEW, SHOW CREATE TABLE,
 https://www.cockroachlabs.com/docs/create-table.html
 https://www.cockroachlabs.com/docs/create-table-as.html
 `,
 	},
-	//line sql.y: 2817
+	//line sql.y: 2976
 	`TRUNCATE`: {
 		ShortDescription: `empty one or more tables`,
-		//line sql.y: 2818
+		//line sql.y: 2977
 		Category: hDML,
-		//line sql.y: 2819
+		//line sql.y: 2978
 		Text: `TRUNCATE [TABLE] <tablename> [, ...] [CASCADE | RESTRICT]
 `,
-		//line sql.y: 2820
+		//line sql.y: 2979
 		SeeAlso: `https://www.cockroachlabs.com/docs/truncate.html
 `,
 	},
-	//line sql.y: 2828
+	//line sql.y: 2987
 	`CREATE USER`: {
 		ShortDescription: `define a new user`,
-		//line sql.y: 2829
+		//line sql.y: 2988
 		Category: hPriv,
-		//line sql.y: 2830
+		//line sql.y: 2989
 		Text: `CREATE USER <name> [ [WITH] PASSWORD <passwd> ]
 `,
-		//line sql.y: 2831
+		//line sql.y: 2990
 		SeeAlso: `DROP USER, SHOW USERS, https://www.cockroachlabs.com/docs/create-user.html
 `,
 	},
-	//line sql.y: 2849
+	//line sql.y: 3008
 	`CREATE VIEW`: {
 		ShortDescription: `create a new view`,
-		//line sql.y: 2850
+		//line sql.y: 3009
 		Category: hDDL,
-		//line sql.y: 2851
+		//line sql.y: 3010
 		Text: `CREATE VIEW <viewname> [( <colnames...> )] AS <source>
 `,
-		//line sql.y: 2852
+		//line sql.y: 3011
 		SeeAlso: `CREATE TABLE, SHOW CREATE VIEW, https://www.cockroachlabs.com/docs/create-view.html
 `,
 	},
-	//line sql.y: 2866
+	//line sql.y: 3025
 	`CREATE INDEX`: {
 		ShortDescription: `create a new index`,
-		//line sql.y: 2867
+		//line sql.y: 3026
 		Category: hDDL,
-		//line sql.y: 2868
+		//line sql.y: 3027
 		Text: `
 CREATE [UNIQUE] INDEX [IF NOT EXISTS] [<idxname>]
        ON <tablename> ( <colname> [ASC | DESC] [, ...] )
@@ -784,53 +882,53 @@ Interleave clause:
    INTERLEAVE IN PARENT <tablename> ( <colnames...> ) [CASCADE | RESTRICT]
 
 `,
-		//line sql.y: 2876
+		//line sql.y: 3035
 		SeeAlso: `CREATE TABLE, SHOW INDEXES, SHOW CREATE INDEX,
 https://www.cockroachlabs.com/docs/create-index.html
 `,
 	},
-	//line sql.y: 3015
+	//line sql.y: 3174
 	`RELEASE`: {
 		ShortDescription: `complete a retryable block`,
-		//line sql.y: 3016
+		//line sql.y: 3175
 		Category: hTxn,
-		//line sql.y: 3017
+		//line sql.y: 3176
 		Text: `RELEASE [SAVEPOINT] cockroach_restart
 `,
-		//line sql.y: 3018
+		//line sql.y: 3177
 		SeeAlso: `SAVEPOINT, https://www.cockroachlabs.com/docs/savepoint.html
 `,
 	},
-	//line sql.y: 3026
+	//line sql.y: 3185
 	`RESUME JOB`: {
 		ShortDescription: `resume a background job`,
-		//line sql.y: 3027
+		//line sql.y: 3186
 		Category: hMisc,
-		//line sql.y: 3028
+		//line sql.y: 3187
 		Text: `RESUME JOB <jobid>
 `,
-		//line sql.y: 3029
+		//line sql.y: 3188
 		SeeAlso: `SHOW JOBS, CANCEL JOB, PAUSE JOB
 `,
 	},
-	//line sql.y: 3038
+	//line sql.y: 3197
 	`SAVEPOINT`: {
 		ShortDescription: `start a retryable block`,
-		//line sql.y: 3039
+		//line sql.y: 3198
 		Category: hTxn,
-		//line sql.y: 3040
+		//line sql.y: 3199
 		Text: `SAVEPOINT cockroach_restart
 `,
-		//line sql.y: 3041
+		//line sql.y: 3200
 		SeeAlso: `RELEASE, https://www.cockroachlabs.com/docs/savepoint.html
 `,
 	},
-	//line sql.y: 3055
+	//line sql.y: 3214
 	`BEGIN`: {
 		ShortDescription: `start a transaction`,
-		//line sql.y: 3056
+		//line sql.y: 3215
 		Category: hTxn,
-		//line sql.y: 3057
+		//line sql.y: 3216
 		Text: `
 BEGIN [TRANSACTION] [ <txnparameter> [[,] ...] ]
 START TRANSACTION [ <txnparameter> [[,] ...] ]
@@ -840,97 +938,97 @@ Transaction parameters:
    PRIORITY { LOW | NORMAL | HIGH }
 
 `,
-		//line sql.y: 3065
+		//line sql.y: 3224
 		SeeAlso: `COMMIT, ROLLBACK, https://www.cockroachlabs.com/docs/begin-transaction.html
 `,
 	},
-	//line sql.y: 3078
+	//line sql.y: 3237
 	`COMMIT`: {
 		ShortDescription: `commit the current transaction`,
-		//line sql.y: 3079
+		//line sql.y: 3238
 		Category: hTxn,
-		//line sql.y: 3080
+		//line sql.y: 3239
 		Text: `
 COMMIT [TRANSACTION]
 END [TRANSACTION]
 `,
-		//line sql.y: 3083
+		//line sql.y: 3242
 		SeeAlso: `BEGIN, ROLLBACK, https://www.cockroachlabs.com/docs/commit-transaction.html
 `,
 	},
-	//line sql.y: 3096
+	//line sql.y: 3255
 	`ROLLBACK`: {
 		ShortDescription: `abort the current transaction`,
-		//line sql.y: 3097
+		//line sql.y: 3256
 		Category: hTxn,
-		//line sql.y: 3098
+		//line sql.y: 3257
 		Text: `ROLLBACK [TRANSACTION] [TO [SAVEPOINT] cockroach_restart]
 `,
-		//line sql.y: 3099
+		//line sql.y: 3258
 		SeeAlso: `BEGIN, COMMIT, SAVEPOINT, https://www.cockroachlabs.com/docs/rollback-transaction.html
 `,
 	},
-	//line sql.y: 3213
+	//line sql.y: 3372
 	`CREATE DATABASE`: {
 		ShortDescription: `create a new database`,
-		//line sql.y: 3214
+		//line sql.y: 3373
 		Category: hDDL,
-		//line sql.y: 3215
+		//line sql.y: 3374
 		Text: `CREATE DATABASE [IF NOT EXISTS] <name>
 `,
-		//line sql.y: 3216
+		//line sql.y: 3375
 		SeeAlso: `https://www.cockroachlabs.com/docs/create-database.html
 `,
 	},
-	//line sql.y: 3285
+	//line sql.y: 3444
 	`INSERT`: {
 		ShortDescription: `create new rows in a table`,
-		//line sql.y: 3286
+		//line sql.y: 3445
 		Category: hDML,
-		//line sql.y: 3287
+		//line sql.y: 3446
 		Text: `
 INSERT INTO <tablename> [[AS] <name>] [( <colnames...> )]
        <selectclause>
        [ON CONFLICT [( <colnames...> )] {DO UPDATE SET ... [WHERE <expr>] | DO NOTHING}]
        [RETURNING <exprs...>]
 `,
-		//line sql.y: 3292
+		//line sql.y: 3451
 		SeeAlso: `UPSERT, UPDATE, DELETE, https://www.cockroachlabs.com/docs/insert.html
 `,
 	},
-	//line sql.y: 3309
+	//line sql.y: 3468
 	`UPSERT`: {
 		ShortDescription: `create or replace rows in a table`,
-		//line sql.y: 3310
+		//line sql.y: 3469
 		Category: hDML,
-		//line sql.y: 3311
+		//line sql.y: 3470
 		Text: `
 UPSERT INTO <tablename> [AS <name>] [( <colnames...> )]
        <selectclause>
        [RETURNING <exprs...>]
 `,
-		//line sql.y: 3315
+		//line sql.y: 3474
 		SeeAlso: `INSERT, UPDATE, DELETE, https://www.cockroachlabs.com/docs/upsert.html
 `,
 	},
-	//line sql.y: 3391
+	//line sql.y: 3550
 	`UPDATE`: {
 		ShortDescription: `update rows of a table`,
-		//line sql.y: 3392
+		//line sql.y: 3551
 		Category: hDML,
-		//line sql.y: 3393
+		//line sql.y: 3552
 		Text: `UPDATE <tablename> [[AS] <name>] SET ... [WHERE <expr>] [RETURNING <exprs...>]
 `,
-		//line sql.y: 3394
+		//line sql.y: 3553
 		SeeAlso: `INSERT, UPSERT, DELETE, https://www.cockroachlabs.com/docs/update.html
 `,
 	},
-	//line sql.y: 3562
+	//line sql.y: 3721
 	`<SELECTCLAUSE>`: {
 		ShortDescription: `access tabular data`,
-		//line sql.y: 3563
+		//line sql.y: 3722
 		Category: hDML,
-		//line sql.y: 3564
+		//line sql.y: 3723
 		Text: `
 Select clause:
   TABLE <tablename>
@@ -938,12 +1036,12 @@ Select clause:
   SELECT ... [ { INTERSECT | UNION | EXCEPT } [ ALL | DISTINCT ] <selectclause> ]
 `,
 	},
-	//line sql.y: 3575
+	//line sql.y: 3734
 	`SELECT`: {
 		ShortDescription: `retrieve rows from a data source and compute a result`,
-		//line sql.y: 3576
+		//line sql.y: 3735
 		Category: hDML,
-		//line sql.y: 3577
+		//line sql.y: 3736
 		Text: `
 SELECT [DISTINCT]
        { <expr> [[AS] <name>] | [ [<dbname>.] <tablename>. ] * } [, ...]
@@ -957,40 +1055,40 @@ SELECT [DISTINCT]
        [ LIMIT { <expr> | ALL } ]
        [ OFFSET <expr> [ ROW | ROWS ] ]
 `,
-		//line sql.y: 3589
+		//line sql.y: 3748
 		SeeAlso: `https://www.cockroachlabs.com/docs/select.html
 `,
 	},
-	//line sql.y: 3649
+	//line sql.y: 3808
 	`TABLE`: {
 		ShortDescription: `select an entire table`,
-		//line sql.y: 3650
+		//line sql.y: 3809
 		Category: hDML,
-		//line sql.y: 3651
+		//line sql.y: 3810
 		Text: `TABLE <tablename>
 `,
-		//line sql.y: 3652
+		//line sql.y: 3811
 		SeeAlso: `SELECT, VALUES, https://www.cockroachlabs.com/docs/table-expressions.html
 `,
 	},
-	//line sql.y: 3891
+	//line sql.y: 4050
 	`VALUES`: {
 		ShortDescription: `select a given set of values`,
-		//line sql.y: 3892
+		//line sql.y: 4051
 		Category: hDML,
-		//line sql.y: 3893
+		//line sql.y: 4052
 		Text: `VALUES ( <exprs...> ) [, ...]
 `,
-		//line sql.y: 3894
+		//line sql.y: 4053
 		SeeAlso: `SELECT, TABLE, https://www.cockroachlabs.com/docs/table-expressions.html
 `,
 	},
-	//line sql.y: 3999
+	//line sql.y: 4158
 	`<SOURCE>`: {
 		ShortDescription: `define a data source for SELECT`,
-		//line sql.y: 4000
+		//line sql.y: 4159
 		Category: hDML,
-		//line sql.y: 4001
+		//line sql.y: 4160
 		Text: `
 Data sources:
   <tablename> [ @ { <idxname> | <indexhint> } ]
@@ -1010,7 +1108,7 @@ Index hints:
   '{' NO_INDEX_JOIN [, ...] '}'
 
 `,
-		//line sql.y: 4019
+		//line sql.y: 4178
 		SeeAlso: `https://www.cockroachlabs.com/docs/table-expressions.html
 `,
 	},
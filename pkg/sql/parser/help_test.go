@@ -304,6 +304,17 @@ func TestContextualHelp(t *testing.T) {
 
 		{`IMPORT TABLE foo CREATE USING 'foo.sql' CSV DATA ('foo') ?`, `IMPORT`},
 		{`IMPORT TABLE ?`, `IMPORT`},
+		{`IMPORT INTO foo CSV DATA ('foo') ?`, `IMPORT`},
+		{`IMPORT PGDUMP DATA ('foo') ?`, `IMPORT`},
+
+		{`EXPORT ?`, `EXPORT`},
+		{`EXPORT INTO CSV 'a' ?`, `EXPORT`},
+
+		{`CREATE CHANGEFEED ?`, `CREATE CHANGEFEED`},
+		{`CREATE CHANGEFEED FOR foo INTO 'sink' ?`, `CREATE CHANGEFEED`},
+
+		{`CREATE SCHEDULE ?`, `CREATE SCHEDULE`},
+		{`CREATE SCHEDULE FOR BACKUP foo TO 'bar' RECURRING '@daily' FULL ?`, `CREATE SCHEDULE`},
 	}
 
 	// The following checks that the test definition above exercises all
@@ -17,8 +17,10 @@ var expectedHelpStrings = []string{
 	"CANCEL QUERY",
 	"CANCEL",
 	"COMMIT",
+	"CREATE CHANGEFEED",
 	"CREATE DATABASE",
 	"CREATE INDEX",
+	"CREATE SCHEDULE",
 	"CREATE TABLE",
 	"CREATE USER",
 	"CREATE VIEW",
@@ -34,6 +36,7 @@ var expectedHelpStrings = []string{
 	"DROP",
 	"EXECUTE",
 	"EXPLAIN",
+	"EXPORT",
 	"GRANT",
 	"IMPORT",
 	"INSERT",
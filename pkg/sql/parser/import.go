@@ -16,9 +16,12 @@ package parser
 
 import "bytes"
 
-// Import represents a IMPORT statement.
+// Import represents a IMPORT statement. Table, CreateFile and CreateDefs are
+// unset when importing a dump file, which contains its own schema. Into is set
+// when importing into the existing table Table, which is not created.
 type Import struct {
 	Table      UnresolvedName
+	Into       bool
 	CreateFile Expr
 	CreateDefs TableDefs
 	FileFormat string
@@ -30,17 +33,25 @@ var _ Statement = &Import{}
 
 // Format implements the NodeFormatter interface.
 func (node *Import) Format(buf *bytes.Buffer, f FmtFlags) {
-	buf.WriteString("IMPORT TABLE ")
-	FormatNode(buf, f, node.Table)
+	buf.WriteString("IMPORT ")
 
-	if node.CreateFile != nil {
-		buf.WriteString(" CREATE USING ")
-		FormatNode(buf, f, node.CreateFile)
+	if node.Into {
+		buf.WriteString("INTO ")
+		FormatNode(buf, f, node.Table)
 		buf.WriteString(" ")
-	} else {
-		buf.WriteString(" (")
-		FormatNode(buf, f, node.CreateDefs)
-		buf.WriteString(") ")
+	} else if node.Table != nil {
+		buf.WriteString("TABLE ")
+		FormatNode(buf, f, node.Table)
+
+		if node.CreateFile != nil {
+			buf.WriteString(" CREATE USING ")
+			FormatNode(buf, f, node.CreateFile)
+			buf.WriteString(" ")
+		} else {
+			buf.WriteString(" (")
+			FormatNode(buf, f, node.CreateDefs)
+			buf.WriteString(") ")
+		}
 	}
 
 	buf.WriteString(node.FileFormat)
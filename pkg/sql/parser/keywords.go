@@ -35,6 +35,7 @@ var keywords = map[string]int{
 	"CASCADE":                   CASCADE,
 	"CASE":                      CASE,
 	"CAST":                      CAST,
+	"CHANGEFEED":                CHANGEFEED,
 	"CHAR":                      CHAR,
 	"CHARACTER":                 CHARACTER,
 	"CHARACTERISTICS":           CHARACTERISTICS,
@@ -90,6 +91,7 @@ var keywords = map[string]int{
 	"EXISTS":                    EXISTS,
 	"EXPERIMENTAL_FINGERPRINTS": EXPERIMENTAL_FINGERPRINTS,
 	"EXPLAIN":                   EXPLAIN,
+	"EXPORT":                    EXPORT,
 	"EXTRACT":                   EXTRACT,
 	"EXTRACT_DURATION":          EXTRACT_DURATION,
 	"FALSE":                     FALSE,
@@ -161,6 +163,7 @@ var keywords = map[string]int{
 	"MATCH":                     MATCH,
 	"MINUTE":                    MINUTE,
 	"MONTH":                     MONTH,
+	"MYSQLDUMP":                 MYSQLDUMP,
 	"NAME":                      NAME,
 	"NAMES":                     NAMES,
 	"NAN":                       NAN,
@@ -195,6 +198,7 @@ var keywords = map[string]int{
 	"PARTITION":                 PARTITION,
 	"PASSWORD":                  PASSWORD,
 	"PAUSE":                     PAUSE,
+	"PGDUMP":                    PGDUMP,
 	"PLACING":                   PLACING,
 	"PLANS":                     PLANS,
 	"POSITION":                  POSITION,
@@ -208,6 +212,7 @@ var keywords = map[string]int{
 	"RANGE":                     RANGE,
 	"READ":                      READ,
 	"REAL":                      REAL,
+	"RECURRING":                 RECURRING,
 	"RECURSIVE":                 RECURSIVE,
 	"REF":                       REF,
 	"REFERENCES":                REFERENCES,
@@ -232,6 +237,7 @@ var keywords = map[string]int{
 	"ROWS":                      ROWS,
 	"SAVEPOINT":                 SAVEPOINT,
 	"SCATTER":                   SCATTER,
+	"SCHEDULE":                  SCHEDULE,
 	"SEARCH":                    SEARCH,
 	"SECOND":                    SECOND,
 	"SELECT":                    SELECT,
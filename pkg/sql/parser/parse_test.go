@@ -690,11 +690,16 @@ func TestParse(t *testing.T) {
 		{`BACKUP foo TO 'bar'`},
 		{`BACKUP foo.foo, baz.baz TO 'bar'`},
 		{`SHOW BACKUP 'bar'`},
+		{`SHOW BACKUP 'bar' WITH encryption_passphrase = 'secret'`},
 		{`BACKUP foo TO 'bar' AS OF SYSTEM TIME '1' INCREMENTAL FROM 'baz'`},
 		{`BACKUP foo TO $1 INCREMENTAL FROM 'bar', $2, 'baz'`},
 		{`BACKUP DATABASE foo TO 'bar'`},
 		{`BACKUP DATABASE foo, baz TO 'bar'`},
 		{`BACKUP DATABASE foo TO 'bar' AS OF SYSTEM TIME '1' INCREMENTAL FROM 'baz'`},
+		{`BACKUP TO 'bar'`},
+		{`BACKUP TO $1 AS OF SYSTEM TIME '1' INCREMENTAL FROM 'baz' WITH revision_history`},
+		{`BACKUP foo TO ('bar', 'baz')`},
+		{`BACKUP TO ($1, $2, 'bar') INCREMENTAL FROM 'baz'`},
 		{`RESTORE foo FROM 'bar'`},
 		{`RESTORE foo FROM $1`},
 		{`RESTORE foo FROM $1, $2, 'bar'`},
@@ -703,11 +708,28 @@ func TestParse(t *testing.T) {
 		{`RESTORE DATABASE foo FROM 'bar'`},
 		{`RESTORE DATABASE foo, baz FROM 'bar'`},
 		{`RESTORE DATABASE foo, baz FROM 'bar' AS OF SYSTEM TIME '1'`},
+		{`RESTORE FROM 'bar'`},
+		{`RESTORE FROM $1, 'bar' AS OF SYSTEM TIME '1' WITH key1`},
+		{`RESTORE foo FROM ('bar', 'baz')`},
+		{`RESTORE FROM ($1, 'bar'), 'baz', ('foo', $2)`},
 		{`BACKUP foo TO 'bar' WITH key1, key2 = 'value'`},
 		{`RESTORE foo FROM 'bar' WITH key1, key2 = 'value'`},
+		{`CREATE CHANGEFEED FOR foo INTO 'sink'`},
+		{`CREATE CHANGEFEED FOR foo, db.bar INTO $1 WITH resolved, cursor = '1'`},
+
+		{`CREATE SCHEDULE FOR BACKUP foo TO 'bar' RECURRING '@daily'`},
+		{`CREATE SCHEDULE FOR BACKUP DATABASE foo, baz TO $1 RECURRING $2 FULL BACKUP '@weekly' WITH retention = '720h'`},
 		{`IMPORT TABLE foo CREATE USING 'nodelocal:///some/file' CSV DATA ('path/to/some/file', $1) WITH temp = 'path/to/temp'`},
 		{`IMPORT TABLE foo (id INT PRIMARY KEY, email STRING, age INT) CSV DATA ('path/to/some/file', $1) WITH temp = 'path/to/temp'`},
 		{`IMPORT TABLE foo (id INT, email STRING, age INT) CSV DATA ('path/to/some/file', $1) WITH comma = ',', "nullif" = 'n/a', temp = $2`},
+		{`IMPORT INTO foo CSV DATA ('path/to/some/file', $1) WITH temp = 'path/to/temp'`},
+		{`IMPORT INTO foo.bar CSV DATA ('path/to/some/file') WITH comma = '|', temp = $1`},
+		{`IMPORT PGDUMP DATA ('path/to/some/file', $1) WITH temp = 'path/to/temp'`},
+		{`IMPORT MYSQLDUMP DATA ('path/to/some/file') WITH into_db = 'foo', temp = $1`},
+
+		{`EXPORT INTO CSV 'a' FROM SELECT * FROM a`},
+		{`EXPORT INTO CSV 's3://my/path/%part%.csv' WITH delimiter = '|' FROM SELECT a, sum(b) FROM c WHERE d = 1 ORDER BY sum(b) DESC LIMIT 10`},
+		{`EXPORT INTO CSV $1 WITH nullas = '', chunk_rows = $2 FROM TABLE a`},
 		{`SET ROW (1, true, NULL)`},
 
 		// Regression for #15926
@@ -992,6 +1014,10 @@ func TestParse2(t *testing.T) {
 
 		{`BACKUP DATABASE foo TO bar`,
 			`BACKUP DATABASE foo TO 'bar'`},
+		{`CREATE CHANGEFEED FOR TABLE foo INTO sink`,
+			`CREATE CHANGEFEED FOR foo INTO 'sink'`},
+		{`CREATE SCHEDULE FOR BACKUP TABLE foo TO bar RECURRING '0 * * * *'`,
+			`CREATE SCHEDULE FOR BACKUP foo TO 'bar' RECURRING '0 * * * *'`},
 		{`BACKUP DATABASE foo TO "bar.12" INCREMENTAL FROM "baz.34"`,
 			`BACKUP DATABASE foo TO 'bar.12' INCREMENTAL FROM 'baz.34'`},
 		{`RESTORE DATABASE foo FROM bar`,
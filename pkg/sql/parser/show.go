@@ -42,13 +42,18 @@ func (node *Show) Format(buf *bytes.Buffer, f FmtFlags) {
 
 // ShowBackup represents a SHOW BACKUP statement.
 type ShowBackup struct {
-	Path Expr
+	Path    Expr
+	Options KVOptions
 }
 
 // Format implements the NodeFormatter interface.
 func (node *ShowBackup) Format(buf *bytes.Buffer, f FmtFlags) {
 	buf.WriteString("SHOW BACKUP ")
 	FormatNode(buf, f, node.Path)
+	if node.Options != nil {
+		buf.WriteString(" WITH ")
+		FormatNode(buf, f, node.Options)
+	}
 }
 
 // ShowColumns represents a SHOW COLUMNS statement.
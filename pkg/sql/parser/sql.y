@@ -217,6 +217,9 @@ func (u *sqlSymUnion) expr() Expr {
 func (u *sqlSymUnion) exprs() Exprs {
     return u.val.(Exprs)
 }
+func (u *sqlSymUnion) listOfExprs() []Exprs {
+    return u.val.([]Exprs)
+}
 func (u *sqlSymUnion) selExpr() SelectExpr {
     return u.val.(SelectExpr)
 }
@@ -373,7 +376,7 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %token <str>   BACKUP BEGIN BETWEEN BIGINT BIGSERIAL BIT
 %token <str>   BLOB BOOL BOOLEAN BOTH BY BYTEA BYTES
 
-%token <str>   CANCEL CASCADE CASE CAST CHAR
+%token <str>   CANCEL CASCADE CASE CAST CHANGEFEED CHAR
 %token <str>   CHARACTER CHARACTERISTICS CHECK
 %token <str>   CLUSTER COALESCE COLLATE COLLATION COLUMN COLUMNS COMMIT
 %token <str>   COMMITTED CONCAT CONFLICT CONSTRAINT CONSTRAINTS
@@ -387,7 +390,7 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %token <str>   DISCARD DISTINCT DO DOUBLE DROP
 
 %token <str>   ELSE ENCODING END ESCAPE EXCEPT
-%token <str>   EXISTS EXECUTE EXPERIMENTAL_FINGERPRINTS EXPLAIN EXTRACT EXTRACT_DURATION
+%token <str>   EXISTS EXECUTE EXPERIMENTAL_FINGERPRINTS EXPLAIN EXPORT EXTRACT EXTRACT_DURATION
 
 %token <str>   FALSE FAMILY FETCH FILTER FIRST FLOAT FLOAT4 FLOAT8 FLOORDIV FOLLOWING FOR
 %token <str>   FORCE_INDEX FOREIGN FROM FULL
@@ -409,7 +412,7 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %token <str>   LEADING LEAST LEFT LEVEL LIKE LIMIT LOCAL
 %token <str>   LOCALTIME LOCALTIMESTAMP LOW LSHIFT
 
-%token <str>   MATCH MINUTE MONTH
+%token <str>   MATCH MINUTE MONTH MYSQLDUMP
 
 %token <str>   NAN NAME NAMES NATURAL NEXT NO NO_INDEX_JOIN NORMAL
 %token <str>   NOT NOTHING NULL NULLIF
@@ -418,18 +421,18 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %token <str>   OF OFF OFFSET OID ON ONLY OPTIONS OR
 %token <str>   ORDER ORDINALITY OUT OUTER OVER OVERLAPS OVERLAY
 
-%token <str>   PARENT PARTIAL PARTITION PASSWORD PAUSE PLACING PLANS POSITION
+%token <str>   PARENT PARTIAL PARTITION PASSWORD PAUSE PGDUMP PLACING PLANS POSITION
 %token <str>   PRECEDING PRECISION PREPARE PRIMARY PRIORITY
 
 %token <str>   QUERIES QUERY
 
-%token <str>   RANGE READ REAL RECURSIVE REF REFERENCES
+%token <str>   RANGE READ REAL RECURRING RECURSIVE REF REFERENCES
 %token <str>   REGCLASS REGPROC REGPROCEDURE REGNAMESPACE REGTYPE
 %token <str>   RENAME REPEATABLE
 %token <str>   RELEASE RESET RESTORE RESTRICT RESUME RETURNING REVOKE RIGHT
 %token <str>   ROLLBACK ROLLUP ROW ROWS RSHIFT
 
-%token <str>   SAVEPOINT SCATTER SEARCH SECOND SELECT SEQUENCES
+%token <str>   SAVEPOINT SCATTER SCHEDULE SEARCH SECOND SELECT SEQUENCES
 %token <str>   SERIAL SERIALIZABLE SESSION SESSIONS SESSION_USER SET SETTING SETTINGS
 %token <str>   SHOW SIMILAR SIMPLE SMALLINT SMALLSERIAL SNAPSHOT SOME SPLIT SQL
 %token <str>   START STATUS STDIN STRICT STRING STORE STORING SUBSTRING
@@ -508,6 +511,8 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %type <Statement> copy_from_stmt
 
 %type <Statement> create_stmt
+%type <Statement> create_changefeed_stmt
+%type <Statement> create_schedule_stmt
 %type <Statement> create_database_stmt
 %type <Statement> create_index_stmt
 %type <Statement> create_table_stmt
@@ -534,6 +539,7 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %type <Statement> grant_stmt
 %type <Statement> insert_stmt
 %type <Statement> import_stmt
+%type <Statement> export_stmt
 %type <Statement> pause_stmt
 %type <Statement> release_stmt
 %type <Statement> reset_stmt
@@ -585,6 +591,7 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %type <KVOption> kv_option
 %type <[]KVOption> kv_option_list opt_with_options
 %type <str> import_data_format
+%type <str> import_dump_format
 
 %type <*Select> select_no_parens
 %type <SelectStatement> select_clause select_with_parens simple_select values_clause table_clause simple_select_clause
@@ -757,6 +764,8 @@ func (u *sqlSymUnion) transactionModes() TransactionModes {
 %type <Expr>  zone_value
 %type <Expr> string_or_placeholder
 %type <Expr> string_or_placeholder_list
+%type <Exprs> string_or_placeholder_opt_list
+%type <[]Exprs> list_of_string_or_placeholder_opt_list
 
 %type <str>   unreserved_keyword type_func_name_keyword
 %type <str>   col_name_keyword reserved_keyword
@@ -891,6 +900,7 @@ stmt:
 | drop_stmt       // help texts in sub-rule
 | execute_stmt    // EXTEND WITH HELP: EXECUTE
 | explain_stmt    // EXTEND WITH HELP: EXPLAIN
+| export_stmt     // EXTEND WITH HELP: EXPORT
 | grant_stmt      // EXTEND WITH HELP: GRANT
 | help_stmt
 | insert_stmt     // EXTEND WITH HELP: INSERT
@@ -1214,7 +1224,7 @@ alter_using:
 // %Help: BACKUP - back up data to external storage
 // %Category: CCL
 // %Text:
-// BACKUP <targets...> TO <location...>
+// BACKUP [ <targets...> ] TO <location...>
 //        [ AS OF SYSTEM TIME <expr> ]
 //        [ INCREMENTAL FROM <location...> ]
 //        [ WITH <option> [= <value>] [, ...] ]
@@ -1222,54 +1232,138 @@ alter_using:
 // Targets:
 //    TABLE <pattern> [, ...]
 //    DATABASE <databasename> [, ...]
+//    (none: back up the full cluster, including its users, zone configs,
+//     cluster settings, UI data and jobs)
 //
 // Location:
 //    "[scheme]://[host]/[path to backup]?[parameters]"
+//    ( <location with COCKROACH_LOCALITY=default>,
+//      <location with COCKROACH_LOCALITY=[key]%3D[value]> [, ...] )
 //
 // Options:
-//    INTO_DB
-//    SKIP_MISSING_FOREIGN_KEYS
+//    REVISION_HISTORY
+//    ENCRYPTION_PASSPHRASE = '...'
 //
 // %SeeAlso: RESTORE, https://www.cockroachlabs.com/docs/backup.html
 backup_stmt:
-  BACKUP targets TO string_or_placeholder opt_as_of_clause opt_incremental opt_with_options
+  BACKUP targets TO string_or_placeholder_opt_list opt_as_of_clause opt_incremental opt_with_options
+  {
+    $$.val = &Backup{Targets: $2.targetList(), To: $4.exprs(), IncrementalFrom: $6.exprs(), AsOf: $5.asOfClause(), Options: $7.kvOptions()}
+  }
+| BACKUP TO string_or_placeholder_opt_list opt_as_of_clause opt_incremental opt_with_options
   {
-    $$.val = &Backup{Targets: $2.targetList(), To: $4.expr(), IncrementalFrom: $6.exprs(), AsOf: $5.asOfClause(), Options: $7.kvOptions()}
+    $$.val = &Backup{To: $3.exprs(), IncrementalFrom: $5.exprs(), AsOf: $4.asOfClause(), Options: $6.kvOptions()}
   }
 | BACKUP error // SHOW HELP: BACKUP
 
 // %Help: RESTORE - restore data from external storage
 // %Category: CCL
 // %Text:
-// RESTORE <targets...> FROM <location...>
+// RESTORE [ <targets...> ] FROM <location...>
 //         [ AS OF SYSTEM TIME <expr> ]
 //         [ WITH <option> [= <value>] [, ...] ]
 //
 // Targets:
 //    TABLE <pattern> [, ...]
 //    DATABASE <databasename> [, ...]
+//    (none: restore a full cluster backup into an empty cluster)
 //
 // Locations:
 //    "[scheme]://[host]/[path to backup]?[parameters]"
+//    ( <location with COCKROACH_LOCALITY=default>,
+//      <location with COCKROACH_LOCALITY=[key]%3D[value]> [, ...] )
 //
 // Options:
 //    INTO_DB
 //    SKIP_MISSING_FOREIGN_KEYS
+//    ENCRYPTION_PASSPHRASE = '...'
 //
 // %SeeAlso: BACKUP, https://www.cockroachlabs.com/docs/restore.html
 restore_stmt:
-  RESTORE targets FROM string_or_placeholder_list opt_as_of_clause opt_with_options
+  RESTORE targets FROM list_of_string_or_placeholder_opt_list opt_as_of_clause opt_with_options
   {
-    $$.val = &Restore{Targets: $2.targetList(), From: $4.exprs(), AsOf: $5.asOfClause(), Options: $6.kvOptions()}
+    $$.val = &Restore{Targets: $2.targetList(), From: $4.listOfExprs(), AsOf: $5.asOfClause(), Options: $6.kvOptions()}
+  }
+| RESTORE FROM list_of_string_or_placeholder_opt_list opt_as_of_clause opt_with_options
+  {
+    $$.val = &Restore{From: $3.listOfExprs(), AsOf: $4.asOfClause(), Options: $5.kvOptions()}
   }
 | RESTORE error // SHOW HELP: RESTORE
 
+// %Help: CREATE CHANGEFEED - stream row changes to external storage
+// %Category: CCL
+// %Text:
+// CREATE CHANGEFEED FOR <targets...> INTO <sink>
+//        [ WITH <option> [= <value>] [, ...] ]
+//
+// Targets:
+//    TABLE <tablename> [, ...]
+//
+// Sink:
+//    "[scheme]://[host]/[path]?[parameters]"
+//
+// Options:
+//    cursor = '...'
+//    resolved
+//
+// %SeeAlso: SHOW JOBS, CANCEL JOB
+create_changefeed_stmt:
+  CREATE CHANGEFEED FOR targets INTO string_or_placeholder opt_with_options
+  {
+    $$.val = &CreateChangefeed{Targets: $4.targetList(), SinkURI: $6.expr(), Options: $7.kvOptions()}
+  }
+| CREATE CHANGEFEED error // SHOW HELP: CREATE CHANGEFEED
+
+// %Help: CREATE SCHEDULE - take backups on a recurring schedule
+// %Category: CCL
+// %Text:
+// CREATE SCHEDULE FOR BACKUP <targets...> TO <location>
+//        RECURRING <recurrence>
+//        [ FULL BACKUP <recurrence> ]
+//        [ WITH <option> [= <value>] [, ...] ]
+//
+// Targets:
+//    TABLE <pattern> [, ...]
+//    DATABASE <databasename> [, ...]
+//
+// Location:
+//    "[scheme]://[host]/[path to collection]?[parameters]"
+//
+// Recurrence:
+//    "<minute> <hour> <day of month> <month> <day of week>"
+//    '@hourly', '@daily', '@weekly', '@monthly', '@yearly'
+//
+// Options:
+//    retention = '<interval>'
+//
+// %SeeAlso: BACKUP, SHOW JOBS
+create_schedule_stmt:
+  CREATE SCHEDULE FOR BACKUP targets TO string_or_placeholder RECURRING string_or_placeholder opt_with_options
+  {
+    $$.val = &CreateBackupSchedule{Targets: $5.targetList(), To: $7.expr(), Recurrence: $9.expr(), Options: $10.kvOptions()}
+  }
+| CREATE SCHEDULE FOR BACKUP targets TO string_or_placeholder RECURRING string_or_placeholder FULL BACKUP string_or_placeholder opt_with_options
+  {
+    $$.val = &CreateBackupSchedule{Targets: $5.targetList(), To: $7.expr(), Recurrence: $9.expr(), FullBackupRecurrence: $12.expr(), Options: $13.kvOptions()}
+  }
+| CREATE SCHEDULE error // SHOW HELP: CREATE SCHEDULE
+
 import_data_format:
   CSV
   {
     $$ = "CSV"
   }
 
+import_dump_format:
+  PGDUMP
+  {
+    $$ = "PGDUMP"
+  }
+| MYSQLDUMP
+  {
+    $$ = "MYSQLDUMP"
+  }
+
 // %Help: IMPORT - load data from file in a distributed manner
 // %Category: CCL
 // %Text:
@@ -1279,13 +1373,25 @@ import_data_format:
 //        DATA ( <datafile> [, ...] )
 //        [ WITH <option> [= <value>] [, ...] ]
 //
+// IMPORT INTO <tablename>
+//        <format>
+//        DATA ( <datafile> [, ...] )
+//        [ WITH <option> [= <value>] [, ...] ]
+//
+// IMPORT { PGDUMP | MYSQLDUMP }
+//        DATA ( <dumpfile> [, ...] )
+//        [ WITH <option> [= <value>] [, ...] ]
+//
 // Formats:
 //    CSV
+//    PGDUMP
+//    MYSQLDUMP
 //
 // Options:
 //    distributed = '...'
 //    sstsize = '...'
 //    temp = '...'
+//    encryption_passphrase = '...'
 //    comma = '...'          [CSV-specific]
 //    comment = '...'        [CSV-specific]
 //    nullif = '...'         [CSV-specific]
@@ -1302,8 +1408,39 @@ import_stmt:
     /* SKIP DOC */
     $$.val = &Import{Table: $3.unresolvedName(), CreateDefs: $5.tblDefs(), FileFormat: $7, Files: $10.exprs(), Options: $12.kvOptions()}
   }
+| IMPORT INTO any_name import_data_format DATA '(' string_or_placeholder_list ')' opt_with_options
+  {
+    /* SKIP DOC */
+    $$.val = &Import{Table: $3.unresolvedName(), Into: true, FileFormat: $4, Files: $7.exprs(), Options: $9.kvOptions()}
+  }
+| IMPORT import_dump_format DATA '(' string_or_placeholder_list ')' opt_with_options
+  {
+    /* SKIP DOC */
+    $$.val = &Import{FileFormat: $2, Files: $5.exprs(), Options: $7.kvOptions()}
+  }
 | IMPORT error // SHOW HELP: IMPORT
 
+// %Help: EXPORT - export data to file in a distributed manner
+// %Category: CCL
+// %Text:
+// EXPORT INTO <format> <datafile> [WITH <option> [= value] [,...]] FROM <query>
+//
+// Formats:
+//    CSV
+//
+// Options:
+//    delimiter = '...'   [CSV-specific]
+//    nullas = '...'      [CSV-specific]
+//    chunk_rows = '...'
+//
+// %SeeAlso: SELECT
+export_stmt:
+  EXPORT INTO import_data_format string_or_placeholder opt_with_options FROM select_stmt
+  {
+    $$.val = &Export{Query: $7.slct(), FileFormat: $3, File: $4.expr(), Options: $5.kvOptions()}
+  }
+| EXPORT error // SHOW HELP: EXPORT
+
 string_or_placeholder:
   non_reserved_word_or_sconst
   {
@@ -1324,6 +1461,26 @@ string_or_placeholder_list:
     $$.val = append($1.exprs(), $3.expr())
   }
 
+string_or_placeholder_opt_list:
+  string_or_placeholder
+  {
+    $$.val = Exprs{$1.expr()}
+  }
+| '(' string_or_placeholder_list ')'
+  {
+    $$.val = $2.exprs()
+  }
+
+list_of_string_or_placeholder_opt_list:
+  string_or_placeholder_opt_list
+  {
+    $$.val = []Exprs{$1.exprs()}
+  }
+| list_of_string_or_placeholder_opt_list ',' string_or_placeholder_opt_list
+  {
+    $$.val = append($1.listOfExprs(), $3.exprs())
+  }
+
 opt_incremental:
   INCREMENTAL FROM string_or_placeholder_list
   {
@@ -1423,9 +1580,11 @@ cancel_query_stmt:
 // %Category: Group
 // %Text:
 // CREATE DATABASE, CREATE TABLE, CREATE INDEX, CREATE TABLE AS,
-// CREATE USER, CREATE VIEW
+// CREATE USER, CREATE VIEW, CREATE CHANGEFEED, CREATE SCHEDULE
 create_stmt:
-  create_database_stmt // EXTEND WITH HELP: CREATE DATABASE
+  create_changefeed_stmt // EXTEND WITH HELP: CREATE CHANGEFEED
+| create_schedule_stmt // EXTEND WITH HELP: CREATE SCHEDULE
+| create_database_stmt // EXTEND WITH HELP: CREATE DATABASE
 | create_index_stmt    // EXTEND WITH HELP: CREATE INDEX
 | create_table_stmt    // EXTEND WITH HELP: CREATE TABLE
 | create_table_as_stmt // EXTEND WITH HELP: CREATE TABLE
@@ -2151,12 +2310,12 @@ session_var:
 
 // %Help: SHOW BACKUP - list backup contents
 // %Category: CCL
-// %Text: SHOW BACKUP <location>
+// %Text: SHOW BACKUP <location> [ WITH <option> [= <value>] [, ...] ]
 // %SeeAlso: https://www.cockroachlabs.com/docs/show-backup.html
 show_backup_stmt:
-  SHOW BACKUP string_or_placeholder
+  SHOW BACKUP string_or_placeholder opt_with_options
   {
-    $$.val = &ShowBackup{Path: $3.expr()}
+    $$.val = &ShowBackup{Path: $3.expr(), Options: $4.kvOptions()}
   }
 | SHOW BACKUP error // SHOW HELP: SHOW BACKUP
 
@@ -6204,6 +6363,7 @@ unreserved_keyword:
 | BY
 | CANCEL
 | CASCADE
+| CHANGEFEED
 | CLUSTER
 | COLUMNS
 | COMMIT
@@ -6229,6 +6389,7 @@ unreserved_keyword:
 | EXECUTE
 | EXPERIMENTAL_FINGERPRINTS
 | EXPLAIN
+| EXPORT
 | FILTER
 | FIRST
 | FOLLOWING
@@ -6257,6 +6418,7 @@ unreserved_keyword:
 | MATCH
 | MINUTE
 | MONTH
+| MYSQLDUMP
 | NAMES
 | NAN
 | NEXT
@@ -6275,6 +6437,7 @@ unreserved_keyword:
 | PARTITION
 | PASSWORD
 | PAUSE
+| PGDUMP
 | PLANS
 | PRECEDING
 | PREPARE
@@ -6283,6 +6446,7 @@ unreserved_keyword:
 | QUERY
 | RANGE
 | READ
+| RECURRING
 | RECURSIVE
 | REF
 | REGCLASS
@@ -6306,6 +6470,7 @@ unreserved_keyword:
 | STATUS
 | SAVEPOINT
 | SCATTER
+| SCHEDULE
 | SEARCH
 | SECOND
 | SERIALIZABLE
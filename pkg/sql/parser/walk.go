@@ -622,6 +622,7 @@ func walkReturningClause(v Visitor, clause ReturningClause) (ReturningClause, bo
 // CopyNode makes a copy of this Statement without recursing in any child Statements.
 func (stmt *Backup) CopyNode() *Backup {
 	stmtCopy := *stmt
+	stmtCopy.To = append(Exprs(nil), stmt.To...)
 	stmtCopy.IncrementalFrom = append(Exprs(nil), stmt.IncrementalFrom...)
 	stmtCopy.Options = append(KVOptions(nil), stmt.Options...)
 	return &stmtCopy
@@ -639,13 +640,13 @@ func (stmt *Backup) WalkStmt(v Visitor) Statement {
 			ret.AsOf.Expr = e
 		}
 	}
-	{
-		e, changed := WalkExpr(v, stmt.To)
+	for i, expr := range stmt.To {
+		e, changed := WalkExpr(v, expr)
 		if changed {
 			if ret == stmt {
 				ret = stmt.CopyNode()
 			}
-			ret.To = e
+			ret.To[i] = e
 		}
 	}
 	for i, expr := range stmt.IncrementalFrom {
@@ -669,6 +670,86 @@ func (stmt *Backup) WalkStmt(v Visitor) Statement {
 	return ret
 }
 
+// CopyNode makes a copy of this Statement without recursing in any child Statements.
+func (stmt *CreateBackupSchedule) CopyNode() *CreateBackupSchedule {
+	stmtCopy := *stmt
+	stmtCopy.Options = append(KVOptions(nil), stmt.Options...)
+	return &stmtCopy
+}
+
+// WalkStmt is part of the WalkableStmt interface.
+func (stmt *CreateBackupSchedule) WalkStmt(v Visitor) Statement {
+	ret := stmt
+	{
+		e, changed := WalkExpr(v, stmt.To)
+		if changed {
+			if ret == stmt {
+				ret = stmt.CopyNode()
+			}
+			ret.To = e
+		}
+	}
+	{
+		e, changed := WalkExpr(v, stmt.Recurrence)
+		if changed {
+			if ret == stmt {
+				ret = stmt.CopyNode()
+			}
+			ret.Recurrence = e
+		}
+	}
+	if stmt.FullBackupRecurrence != nil {
+		e, changed := WalkExpr(v, stmt.FullBackupRecurrence)
+		if changed {
+			if ret == stmt {
+				ret = stmt.CopyNode()
+			}
+			ret.FullBackupRecurrence = e
+		}
+	}
+	{
+		opts, changed := walkKVOptions(v, stmt.Options)
+		if changed {
+			if ret == stmt {
+				ret = stmt.CopyNode()
+			}
+			ret.Options = opts
+		}
+	}
+	return ret
+}
+
+// CopyNode makes a copy of this Statement without recursing in any child Statements.
+func (stmt *CreateChangefeed) CopyNode() *CreateChangefeed {
+	stmtCopy := *stmt
+	stmtCopy.Options = append(KVOptions(nil), stmt.Options...)
+	return &stmtCopy
+}
+
+// WalkStmt is part of the WalkableStmt interface.
+func (stmt *CreateChangefeed) WalkStmt(v Visitor) Statement {
+	ret := stmt
+	{
+		e, changed := WalkExpr(v, stmt.SinkURI)
+		if changed {
+			if ret == stmt {
+				ret = stmt.CopyNode()
+			}
+			ret.SinkURI = e
+		}
+	}
+	{
+		opts, changed := walkKVOptions(v, stmt.Options)
+		if changed {
+			if ret == stmt {
+				ret = stmt.CopyNode()
+			}
+			ret.Options = opts
+		}
+	}
+	return ret
+}
+
 // CopyNode makes a copy of this Statement without recursing in any child Statements.
 func (stmt *Delete) CopyNode() *Delete {
 	stmtCopy := *stmt
@@ -797,7 +878,10 @@ func (stmt *ParenSelect) WalkStmt(v Visitor) Statement {
 // CopyNode makes a copy of this Statement without recursing in any child Statements.
 func (stmt *Restore) CopyNode() *Restore {
 	stmtCopy := *stmt
-	stmtCopy.From = append(Exprs(nil), stmt.From...)
+	stmtCopy.From = make([]Exprs, len(stmt.From))
+	for i, from := range stmt.From {
+		stmtCopy.From[i] = append(Exprs(nil), from...)
+	}
 	stmtCopy.Options = append(KVOptions(nil), stmt.Options...)
 	return &stmtCopy
 }
@@ -814,13 +898,15 @@ func (stmt *Restore) WalkStmt(v Visitor) Statement {
 			ret.AsOf.Expr = e
 		}
 	}
-	for i, expr := range stmt.From {
-		e, changed := WalkExpr(v, expr)
-		if changed {
-			if ret == stmt {
-				ret = stmt.CopyNode()
+	for i, from := range stmt.From {
+		for j, expr := range from {
+			e, changed := WalkExpr(v, expr)
+			if changed {
+				if ret == stmt {
+					ret = stmt.CopyNode()
+				}
+				ret.From[i][j] = e
 			}
-			ret.From[i] = e
 		}
 	}
 	{
@@ -1090,6 +1176,8 @@ func (stmt *ValuesClause) WalkStmt(v Visitor) Statement {
 }
 
 var _ WalkableStmt = &Backup{}
+var _ WalkableStmt = &CreateBackupSchedule{}
+var _ WalkableStmt = &CreateChangefeed{}
 var _ WalkableStmt = &Delete{}
 var _ WalkableStmt = &Explain{}
 var _ WalkableStmt = &Insert{}
@@ -17,6 +17,7 @@ package sql
 import (
 	"golang.org/x/net/context"
 
+	"github.com/cockroachdb/cockroach/pkg/sql/distsqlrun"
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
 )
@@ -47,6 +48,13 @@ type PlanHookState interface {
 	EvalContext() parser.EvalContext
 	ExecCfg() *ExecutorConfig
 	DistLoader() *DistLoader
+	DistExport(
+		ctx context.Context,
+		query *parser.Select,
+		writer distsqlrun.ProcessorCoreUnion,
+		writerTypes []sqlbase.ColumnType,
+		resultRows *RowResultWriter,
+	) error
 	TypeAsString(e parser.Expr, op string) (func() (string, error), error)
 	TypeAsStringArray(e parser.Exprs, op string) (func() ([]string, error), error)
 	TypeAsStringOpts(opts parser.KVOptions) (func() (map[string]string, error), error)
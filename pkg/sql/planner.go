@@ -19,6 +19,7 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/sql/distsqlrun"
 	"github.com/cockroachdb/cockroach/pkg/sql/mon"
 	"github.com/cockroachdb/cockroach/pkg/sql/parser"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlbase"
@@ -190,6 +191,74 @@ func (p *planner) DistLoader() *DistLoader {
 	return &DistLoader{distSQLPlanner: p.session.distSQLPlanner}
 }
 
+// DistExport plans query and runs it using DistSQL, in the planner's
+// transaction. A processor with the writer core is added on the node of each
+// of the query's result streams and consumes the query's (non-hidden)
+// columns, in order; writerTypes are the types of the rows it outputs, which
+// are added to resultRows. Any ordering of the query is only preserved within
+// each result stream.
+func (p *planner) DistExport(
+	ctx context.Context,
+	query *parser.Select,
+	writer distsqlrun.ProcessorCoreUnion,
+	writerTypes []sqlbase.ColumnType,
+	resultRows *RowResultWriter,
+) error {
+	plan, err := p.newPlan(ctx, query, nil)
+	if err != nil {
+		return err
+	}
+	plan, err = p.optimizePlan(ctx, plan, allColumns(plan))
+	if err != nil {
+		plan.Close(ctx)
+		return err
+	}
+	defer plan.Close(ctx)
+
+	dsp := p.session.distSQLPlanner
+	if _, err := dsp.CheckSupport(plan); err != nil {
+		return errors.Wrap(err, "query cannot be run using DistSQL")
+	}
+	planCtx := dsp.NewPlanningCtx(ctx, p.txn)
+	physPlan, err := dsp.createPlanForNode(&planCtx, plan)
+	if err != nil {
+		return err
+	}
+
+	var columns []uint32
+	for i, col := range planColumns(plan) {
+		if col.Hidden {
+			continue
+		}
+		columns = append(columns, uint32(physPlan.planToStreamColMap[i]))
+	}
+	physPlan.AddProjection(columns)
+	physPlan.AddNoGroupingStage(
+		writer, distsqlrun.PostProcessSpec{}, writerTypes, distsqlrun.Ordering{},
+	)
+	physPlan.planToStreamColMap = identityMap(nil, len(writerTypes))
+	dsp.FinalizePlan(&planCtx, &physPlan)
+
+	cfg := p.ExecCfg()
+	recv, err := makeDistSQLReceiver(
+		ctx,
+		resultRows,
+		cfg.RangeDescriptorCache,
+		cfg.LeaseHolderCache,
+		p.txn,
+		func(ts hlc.Timestamp) {
+			_ = cfg.Clock.Update(ts)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if err := dsp.Run(&planCtx, p.txn, &physPlan, &recv, p.evalCtx); err != nil {
+		return err
+	}
+	return recv.err
+}
+
 // setTxn resets the current transaction in the planner and
 // initializes the timestamps used by SQL built-in functions from
 // the new txn object, if any.
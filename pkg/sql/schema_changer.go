@@ -23,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 
+	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
 	"github.com/cockroachdb/cockroach/pkg/keys"
@@ -59,7 +60,11 @@ type SchemaChanger struct {
 	leaseMgr   *LeaseManager
 	// The SchemaChangeManager can attempt to execute this schema
 	// changer after this time.
-	execAfter      time.Time
+	execAfter time.Time
+	// For a dropped table, the time after which its data is past its GC
+	// TTL and can be cleared with ClearRange. If zero, the data is
+	// instead deleted row by row as soon as the table is dropped.
+	gcDeadline     time.Time
 	readAsOf       hlc.Timestamp
 	testingKnobs   *SchemaChangerTestingKnobs
 	distSQLPlanner *distSQLPlanner
@@ -95,9 +100,12 @@ var errExistingSchemaChangeLease = errors.New(
 	"an outstanding schema change lease exists")
 var errSchemaChangeNotFirstInLine = errors.New(
 	"schema change not first in line")
+var errTableNotPastGCTTL = errors.New(
+	"dropped table is not yet past its GC TTL")
 
 func shouldLogSchemaChangeError(err error) bool {
-	return err != errExistingSchemaChangeLease && err != errSchemaChangeNotFirstInLine
+	return err != errExistingSchemaChangeLease && err != errSchemaChangeNotFirstInLine &&
+		err != errTableNotPastGCTTL
 }
 
 // AcquireLease acquires a schema change lease on the table if
@@ -281,8 +289,19 @@ func (sc *SchemaChanger) maybeAddDropRename(
 		}
 
 		// Do all the hard work of deleting the table data and the table ID.
-		if err := truncateTableInChunks(ctx, table, &sc.db, false /* traceKV */); err != nil {
-			return false, err
+		if sc.gcDeadline.IsZero() {
+			if err := truncateTableInChunks(ctx, table, &sc.db, false /* traceKV */); err != nil {
+				return false, err
+			}
+		} else {
+			// The data can't be cleared until historical reads can no
+			// longer see it.
+			if timeutil.Now().Before(sc.gcDeadline) {
+				return false, errTableNotPastGCTTL
+			}
+			if err := clearTableData(ctx, table, &sc.db, false /* traceKV */); err != nil {
+				return false, err
+			}
 		}
 
 		return true, DropTableDesc(ctx, table, &sc.db, false /* traceKV */)
@@ -901,6 +920,7 @@ type SchemaChangeManager struct {
 	distSQLPlanner *distSQLPlanner
 	clock          *hlc.Clock
 	jobRegistry    *jobs.Registry
+	settings       *cluster.Settings
 }
 
 // NewSchemaChangeManager returns a new SchemaChangeManager.
@@ -938,7 +958,31 @@ func NewSchemaChangeManager(
 		),
 		jobRegistry: jobRegistry,
 		clock:       clock,
+		settings:    st,
+	}
+}
+
+// gcDeadline returns the time after which the data of a dropped table
+// is no longer visible to historical reads and can be cleared with
+// ClearRange. It returns the zero time if the data must instead be
+// deleted row by row right away: because the table is a view and has
+// no data, is interleaved, had its drop time go unrecorded, or the
+// cluster doesn't support ClearRange yet.
+func (s *SchemaChangeManager) gcDeadline(
+	ctx context.Context, cfg config.SystemConfig, table *sqlbase.TableDescriptor,
+) time.Time {
+	if !table.Dropped() || table.IsView() || table.DropTime == 0 || table.IsInterleaved() ||
+		!s.settings.Version.IsActive(cluster.VersionClearRange) {
+		return time.Time{}
 	}
+	zone, _, err := GetZoneConfig(cfg, uint32(table.ID))
+	if err != nil {
+		log.Warningf(ctx, "table %d: unable to look up zone config, deleting data row by row: %v",
+			table.ID, err)
+		return time.Time{}
+	}
+	ttl := time.Duration(zone.GC.TTLSeconds) * time.Second
+	return time.Unix(0, table.DropTime).Add(ttl)
 }
 
 // Creates a timer that is used by the manager to decide on
@@ -1035,12 +1079,15 @@ func (s *SchemaChangeManager) Start(stopper *stop.Stopper) {
 								schemaChanger.mutationID = table.Mutations[0].MutationID
 							}
 							schemaChanger.execAfter = execAfter
+							schemaChanger.gcDeadline = s.gcDeadline(ctx, cfg, table)
 							// Keep track of this schema change.
 							// Remove from oldSchemaChangers map.
 							delete(oldSchemaChangers, table.ID)
 							if sc, ok := s.schemaChangers[table.ID]; ok {
-								if sc.mutationID == schemaChanger.mutationID {
-									// Ignore duplicate.
+								// Ignore duplicates, unless the GC TTL of a dropped
+								// table has changed.
+								if sc.mutationID == schemaChanger.mutationID &&
+									sc.gcDeadline.Equal(schemaChanger.gcDeadline) {
 									continue
 								}
 							}
@@ -1077,6 +1124,12 @@ func (s *SchemaChangeManager) Start(stopper *stop.Stopper) {
 								// deletion which would remove this schemaChanger.
 								delete(s.schemaChangers, tableID)
 							}
+							if err == errTableNotPastGCTTL {
+								// Don't try again until the table's data can be
+								// cleared.
+								sc.execAfter = sc.gcDeadline
+								s.schemaChangers[tableID] = sc
+							}
 						} else {
 							// We successfully executed the schema change. Delete it.
 							delete(s.schemaChangers, tableID)
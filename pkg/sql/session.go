@@ -993,6 +993,12 @@ func (ts *txnState) resetForNewSQLTxn(
 	} else {
 		ts.mu.txn.SetDebugName(sqlTxnName)
 	}
+	s.mu.RLock()
+	ts.mu.txn.SetRequestSource(roachpb.RequestSource{
+		User:            s.User,
+		ApplicationName: s.mu.ApplicationName,
+	})
+	s.mu.RUnlock()
 	if err := ts.setIsolationLevel(isolation); err != nil {
 		panic(err)
 	}
@@ -17,15 +17,18 @@
 //   SHOW TESTING_RANGES FROM INDEX t@idx
 //
 // These statements show the ranges corresponding to the given table or index,
-// along with the list of replicas and the lease holder.
+// along with the list of replicas, the lease holder and the lease preferences
+// of the zone config that applies to each range.
 
 package sql
 
 import (
+	"bytes"
 	"sort"
 
 	"golang.org/x/net/context"
 
+	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
@@ -81,6 +84,12 @@ var showRangesColumns = sqlbase.ResultColumns{
 		// The store ID for the lease holder.
 		Typ: parser.TypeInt,
 	},
+	{
+		Name: "Lease Preferences",
+		// The ordered lease preferences from the range's zone config, or NULL if
+		// the zone config has none.
+		Typ: parser.TypeString,
+	},
 }
 
 func (n *showRangesNode) Start(params runParams) error {
@@ -138,6 +147,18 @@ func (n *showRangesNode) Next(params runParams) (bool, error) {
 	resp := b.RawResponse().Responses[0].GetInner().(*roachpb.LeaseInfoResponse)
 	n.values[3] = parser.NewDInt(parser.DInt(resp.Lease.Replica.StoreID))
 
+	// Get the lease preferences from the zone config, if the system config is
+	// available.
+	if cfg, ok := params.p.session.execCfg.Gossip.GetSystemConfig(); ok {
+		zone, err := cfg.GetZoneConfigForKey(desc.StartKey)
+		if err != nil {
+			return false, errors.Wrap(err, "error getting zone config")
+		}
+		if len(zone.LeasePreferences) > 0 {
+			n.values[4] = parser.NewDString(formatLeasePreferences(zone.LeasePreferences))
+		}
+	}
+
 	n.rowIdx++
 	return true, nil
 }
@@ -150,6 +171,28 @@ func (n *showRangesNode) Close(_ context.Context) {
 	n.descriptorKVs = nil
 }
 
+// formatLeasePreferences renders lease preferences in the same flow notation
+// used by `cockroach zone get`, e.g. [[+region=us-east1], [+region=us-west1]].
+func formatLeasePreferences(prefs []config.Constraints) string {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, pref := range prefs {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteByte('[')
+		for j, c := range pref.Constraints {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(c.String())
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
 // scanMetaKVs returns the meta KVs for the ranges that touch the given span.
 func scanMetaKVs(
 	ctx context.Context, txn *client.Txn, span roachpb.Span,
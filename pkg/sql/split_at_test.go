@@ -166,14 +166,15 @@ func TestScatter(t *testing.T) {
 	// See showRangesColumns for the schema.
 	if cols, err := rows.Columns(); err != nil {
 		t.Fatal(err)
-	} else if len(cols) != 4 {
-		t.Fatalf("expected 4 columns, got %#v", cols)
+	} else if len(cols) != 5 {
+		t.Fatalf("expected 5 columns, got %#v", cols)
 	}
 	vals := []interface{}{
 		new(interface{}),
 		new(interface{}),
 		new(interface{}),
 		new(int),
+		new(interface{}),
 	}
 	leaseHolders := map[int]int{1: 0, 2: 0, 3: 0, 4: 0}
 	numRows := 0
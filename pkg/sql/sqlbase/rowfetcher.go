@@ -217,6 +217,12 @@ func (rf *RowFetcher) StartScanFrom(ctx context.Context, f kvFetcher) error {
 	return err
 }
 
+// StartScanFromKV starts a scan over a single key/value, such as one received
+// from a range feed. Can be used multiple times.
+func (rf *RowFetcher) StartScanFromKV(ctx context.Context, kv client.KeyValue) error {
+	return rf.StartScanFrom(ctx, &singleKVFetcher{kv: kv})
+}
+
 // NextKey retrieves the next key/value and sets kv/kvEnd. Returns whether a row
 // has been completed.
 // TODO(andrei): change to return error
@@ -67,6 +67,18 @@ CREATE TABLE system.settings (
 	"valueType"       STRING,
 	FAMILY (name, value, "lastUpdated", "valueType")
 );`
+
+	// Request quotas per SQL user or application_name. Being part of the
+	// system config, changes are gossiped to every node.
+	QuotasTableSchema = `
+CREATE TABLE system.quotas (
+	kind             STRING NOT NULL,
+	name             STRING NOT NULL,
+	"unitsPerSecond" INT    NOT NULL,
+	burst            INT    NOT NULL,
+	PRIMARY KEY (kind, name),
+	FAMILY (kind, name, "unitsPerSecond", burst)
+);`
 )
 
 // These system tables are not part of the system config.
@@ -140,6 +152,37 @@ CREATE TABLE system.web_sessions (
 	INDEX("createdAt"),
 	FAMILY(id, "hashedSecret", username, "createdAt", "expiresAt", "revokedAt", "lastUsedAt", "auditInfo")
 );`
+
+	// Protected timestamp records prevent the GC queue from collecting the
+	// MVCC versions of a span which are visible at a timestamp, for instance
+	// while a job reads the span at that timestamp.
+	ProtectedTSTableSchema = `
+CREATE TABLE system.protected_ts_records (
+	id         INT       DEFAULT unique_rowid() PRIMARY KEY,
+	job_id     INT,
+	wall_time  INT       NOT NULL,
+	logical    INT       NOT NULL,
+	start_key  BYTES     NOT NULL,
+	end_key    BYTES     NOT NULL,
+	created    TIMESTAMP NOT NULL DEFAULT now(),
+	INDEX (job_id),
+	FAMILY (id, job_id, wall_time, logical, start_key, end_key, created)
+);`
+
+	// Schedules are recurring jobs, started by the jobs registry when their
+	// next run is due. The details are a jobs.ScheduleDetails proto.
+	SchedulesTableSchema = `
+CREATE TABLE system.schedules (
+	id          INT       DEFAULT unique_rowid() PRIMARY KEY,
+	description STRING    NOT NULL,
+	owner       STRING    NOT NULL,
+	recurrence  STRING    NOT NULL,
+	next_run    TIMESTAMP NOT NULL,
+	details     BYTES     NOT NULL,
+	created     TIMESTAMP NOT NULL DEFAULT now(),
+	INDEX (next_run),
+	FAMILY (id, description, owner, recurrence, next_run, details, created)
+);`
 )
 
 func pk(name string) IndexDescriptor {
@@ -183,6 +226,7 @@ var SystemAllowedPrivileges = map[ID]privilege.Lists{
 	// the use of a validating, logging accessor, so we'll go ahead and tolerate
 	// read-only privs to make that migration possible later.
 	keys.SettingsTableID:   {privilege.ReadWriteData, privilege.ReadData},
+	keys.QuotasTableID:     {privilege.ReadWriteData},
 	keys.LeaseTableID:      {privilege.ReadWriteData, {privilege.ALL}},
 	keys.EventLogTableID:   {privilege.ReadWriteData, {privilege.ALL}},
 	keys.RangeEventTableID: {privilege.ReadWriteData, {privilege.ALL}},
@@ -193,6 +237,8 @@ var SystemAllowedPrivileges = map[ID]privilege.Lists{
 	// compatibility reasons only!
 	keys.JobsTableID:        {privilege.ReadWriteData},
 	keys.WebSessionsTableID: {privilege.ReadWriteData},
+	keys.ProtectedTSTableID: {privilege.ReadWriteData},
+	keys.SchedulesTableID:   {privilege.ReadWriteData},
 }
 
 // SystemDesiredPrivileges returns the desired privilege list (i.e., the
@@ -355,6 +401,42 @@ var (
 		FormatVersion:  InterleavedFormatVersion,
 		NextMutationID: 1,
 	}
+
+	// QuotasTable is the descriptor for the quotas table.
+	QuotasTable = TableDescriptor{
+		Name:     "quotas",
+		ID:       keys.QuotasTableID,
+		ParentID: 1,
+		Version:  1,
+		Columns: []ColumnDescriptor{
+			{Name: "kind", ID: 1, Type: colTypeString},
+			{Name: "name", ID: 2, Type: colTypeString},
+			{Name: "unitsPerSecond", ID: 3, Type: colTypeInt},
+			{Name: "burst", ID: 4, Type: colTypeInt},
+		},
+		NextColumnID: 5,
+		Families: []ColumnFamilyDescriptor{
+			{
+				Name:        "fam_0_kind_name_unitsPerSecond_burst",
+				ID:          0,
+				ColumnNames: []string{"kind", "name", "unitsPerSecond", "burst"},
+				ColumnIDs:   []ColumnID{1, 2, 3, 4},
+			},
+		},
+		NextFamilyID: 1,
+		PrimaryIndex: IndexDescriptor{
+			Name:             "primary",
+			ID:               1,
+			Unique:           true,
+			ColumnNames:      []string{"kind", "name"},
+			ColumnDirections: []IndexDescriptor_Direction{IndexDescriptor_ASC, IndexDescriptor_ASC},
+			ColumnIDs:        []ColumnID{1, 2},
+		},
+		NextIndexID:    2,
+		Privileges:     NewPrivilegeDescriptor(security.RootUser, SystemDesiredPrivileges(keys.QuotasTableID)),
+		FormatVersion:  InterleavedFormatVersion,
+		NextMutationID: 1,
+	}
 )
 
 // These system TableDescriptor literals should match the descriptor that
@@ -606,6 +688,97 @@ var (
 		NextMutationID: 1,
 		FormatVersion:  3,
 	}
+
+	// ProtectedTSTable is the descriptor for the protected timestamp records
+	// table.
+	ProtectedTSTable = TableDescriptor{
+		Name:     "protected_ts_records",
+		ID:       keys.ProtectedTSTableID,
+		ParentID: 1,
+		Version:  1,
+		Columns: []ColumnDescriptor{
+			{Name: "id", ID: 1, Type: colTypeInt, DefaultExpr: &uniqueRowIDString},
+			{Name: "job_id", ID: 2, Type: colTypeInt, Nullable: true},
+			{Name: "wall_time", ID: 3, Type: colTypeInt},
+			{Name: "logical", ID: 4, Type: colTypeInt},
+			{Name: "start_key", ID: 5, Type: colTypeBytes},
+			{Name: "end_key", ID: 6, Type: colTypeBytes},
+			{Name: "created", ID: 7, Type: colTypeTimestamp, DefaultExpr: &nowString},
+		},
+		NextColumnID: 8,
+		Families: []ColumnFamilyDescriptor{
+			{
+				Name: "fam_0_id_job_id_wall_time_logical_start_key_end_key_created",
+				ID:   0,
+				ColumnNames: []string{
+					"id", "job_id", "wall_time", "logical", "start_key", "end_key", "created",
+				},
+				ColumnIDs: []ColumnID{1, 2, 3, 4, 5, 6, 7},
+			},
+		},
+		NextFamilyID: 1,
+		PrimaryIndex: pk("id"),
+		Indexes: []IndexDescriptor{
+			{
+				Name:             "protected_ts_records_job_id_idx",
+				ID:               2,
+				Unique:           false,
+				ColumnNames:      []string{"job_id"},
+				ColumnDirections: []IndexDescriptor_Direction{IndexDescriptor_ASC},
+				ColumnIDs:        []ColumnID{2},
+				ExtraColumnIDs:   []ColumnID{1},
+			},
+		},
+		NextIndexID:    3,
+		Privileges:     NewPrivilegeDescriptor(security.RootUser, SystemDesiredPrivileges(keys.ProtectedTSTableID)),
+		FormatVersion:  InterleavedFormatVersion,
+		NextMutationID: 1,
+	}
+
+	// SchedulesTable is the descriptor for the schedules table.
+	SchedulesTable = TableDescriptor{
+		Name:     "schedules",
+		ID:       keys.SchedulesTableID,
+		ParentID: 1,
+		Version:  1,
+		Columns: []ColumnDescriptor{
+			{Name: "id", ID: 1, Type: colTypeInt, DefaultExpr: &uniqueRowIDString},
+			{Name: "description", ID: 2, Type: colTypeString},
+			{Name: "owner", ID: 3, Type: colTypeString},
+			{Name: "recurrence", ID: 4, Type: colTypeString},
+			{Name: "next_run", ID: 5, Type: colTypeTimestamp},
+			{Name: "details", ID: 6, Type: colTypeBytes},
+			{Name: "created", ID: 7, Type: colTypeTimestamp, DefaultExpr: &nowString},
+		},
+		NextColumnID: 8,
+		Families: []ColumnFamilyDescriptor{
+			{
+				Name: "fam_0_id_description_owner_recurrence_next_run_details_created",
+				ID:   0,
+				ColumnNames: []string{
+					"id", "description", "owner", "recurrence", "next_run", "details", "created",
+				},
+				ColumnIDs: []ColumnID{1, 2, 3, 4, 5, 6, 7},
+			},
+		},
+		NextFamilyID: 1,
+		PrimaryIndex: pk("id"),
+		Indexes: []IndexDescriptor{
+			{
+				Name:             "schedules_next_run_idx",
+				ID:               2,
+				Unique:           false,
+				ColumnNames:      []string{"next_run"},
+				ColumnDirections: []IndexDescriptor_Direction{IndexDescriptor_ASC},
+				ColumnIDs:        []ColumnID{5},
+				ExtraColumnIDs:   []ColumnID{1},
+			},
+		},
+		NextIndexID:    3,
+		Privileges:     NewPrivilegeDescriptor(security.RootUser, SystemDesiredPrivileges(keys.SchedulesTableID)),
+		FormatVersion:  InterleavedFormatVersion,
+		NextMutationID: 1,
+	}
 )
 
 // Create the key/value pair for the default zone config entry.
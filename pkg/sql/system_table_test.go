@@ -117,7 +117,10 @@ func TestSystemTableLiterals(t *testing.T) {
 		{keys.UITableID, sqlbase.UITableSchema, sqlbase.UITable},
 		{keys.JobsTableID, sqlbase.JobsTableSchema, sqlbase.JobsTable},
 		{keys.SettingsTableID, sqlbase.SettingsTableSchema, sqlbase.SettingsTable},
+		{keys.QuotasTableID, sqlbase.QuotasTableSchema, sqlbase.QuotasTable},
 		{keys.WebSessionsTableID, sqlbase.WebSessionsTableSchema, sqlbase.WebSessionsTable},
+		{keys.ProtectedTSTableID, sqlbase.ProtectedTSTableSchema, sqlbase.ProtectedTSTable},
+		{keys.SchedulesTableID, sqlbase.SchedulesTableSchema, sqlbase.SchedulesTable},
 	} {
 		gen, err := sql.CreateTestTableDescriptor(
 			context.TODO(),
@@ -205,6 +205,7 @@ func MustGetTableDesc(
 
 var errTableDropped = errors.New("table is being dropped")
 var errTableAdding = errors.New("table is being added")
+var errTableOffline = errors.New("table is offline")
 
 func filterTableState(tableDesc *sqlbase.TableDescriptor) error {
 	switch {
@@ -212,6 +213,8 @@ func filterTableState(tableDesc *sqlbase.TableDescriptor) error {
 		return errTableDropped
 	case tableDesc.Adding():
 		return errTableAdding
+	case tableDesc.Offline():
+		return errTableOffline
 	case tableDesc.State != sqlbase.TableDescriptor_PUBLIC:
 		return errors.Errorf("table in unknown state: %s", tableDesc.State.String())
 	}
@@ -342,3 +342,18 @@ func truncateTableInChunks(
 	}
 	return nil
 }
+
+// clearTableData removes all of the table's data, including all of its
+// MVCC versions, using ClearRange. Unlike truncateTableInChunks, this
+// doesn't leave tombstones behind for the GC queue, but it also makes
+// the data unavailable to historical reads. It must only be used on
+// dropped, non-interleaved tables which are past their GC TTL.
+func clearTableData(
+	ctx context.Context, tableDesc *sqlbase.TableDescriptor, db *client.DB, traceKV bool,
+) error {
+	span := tableDesc.TableSpan()
+	if traceKV {
+		log.VEventf(ctx, 2, "ClearRange %s - %s", span.Key, span.EndKey)
+	}
+	return db.ClearRange(ctx, span.Key, span.EndKey)
+}
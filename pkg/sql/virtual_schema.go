@@ -292,3 +292,28 @@ func (nilVirtualTabler) getVirtualDatabaseDesc(name string) *sqlbase.DatabaseDes
 func (nilVirtualTabler) getVirtualSchemaEntry(name string) (virtualSchemaEntry, bool) {
 	return virtualSchemaEntry{}, false
 }
+
+// TableDescsVirtualTabler implements VirtualTabler by resolving table names,
+// regardless of database, to the descriptors in the map. Names not in the map
+// resolve to no table. It is used to resolve foreign keys between tables that
+// are created outside of the cluster, such as by IMPORT.
+type TableDescsVirtualTabler map[string]*sqlbase.TableDescriptor
+
+var _ VirtualTabler = TableDescsVirtualTabler(nil)
+
+func (m TableDescsVirtualTabler) getVirtualTableDesc(
+	tn *parser.TableName,
+) (*sqlbase.TableDescriptor, error) {
+	if desc, ok := m[tn.Table()]; ok {
+		return desc, nil
+	}
+	return nil, sqlbase.NewUndefinedRelationError(tn)
+}
+
+func (TableDescsVirtualTabler) getVirtualDatabaseDesc(name string) *sqlbase.DatabaseDescriptor {
+	return nil
+}
+
+func (TableDescsVirtualTabler) getVirtualSchemaEntry(name string) (virtualSchemaEntry, bool) {
+	return virtualSchemaEntry{}, false
+}
@@ -83,6 +83,24 @@ var backwardCompatibleMigrations = []migrationDescriptor{
 		name:   "populate initial version cluster setting table entry",
 		workFn: populateVersionSetting,
 	},
+	{
+		name:           "create system.quotas table",
+		workFn:         createQuotasTable,
+		newDescriptors: 1,
+		newRanges:      0, // it lives in gossip range.
+	},
+	{
+		name:           "create system.protected_ts_records table",
+		workFn:         createProtectedTSTable,
+		newDescriptors: 1,
+		newRanges:      1,
+	},
+	{
+		name:           "create system.schedules table",
+		workFn:         createSchedulesTable,
+		newDescriptors: 1,
+		newRanges:      1,
+	},
 }
 
 // migrationDescriptor describes a single migration hook that's used to modify
@@ -360,6 +378,18 @@ func createWebSessionsTable(ctx context.Context, r runner) error {
 	return createSystemTable(ctx, r, sqlbase.WebSessionsTable)
 }
 
+func createQuotasTable(ctx context.Context, r runner) error {
+	return createSystemTable(ctx, r, sqlbase.QuotasTable)
+}
+
+func createProtectedTSTable(ctx context.Context, r runner) error {
+	return createSystemTable(ctx, r, sqlbase.ProtectedTSTable)
+}
+
+func createSchedulesTable(ctx context.Context, r runner) error {
+	return createSystemTable(ctx, r, sqlbase.SchedulesTable)
+}
+
 func createSystemTable(ctx context.Context, r runner, desc sqlbase.TableDescriptor) error {
 	// We install the table at the KV layer so that we can choose a known ID in
 	// the reserved ID space. (The SQL layer doesn't allow this.)
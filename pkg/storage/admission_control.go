@@ -0,0 +1,290 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+const (
+	// admissionRefreshInterval is how often the engine's health is re-read
+	// while writes are being held back.
+	admissionRefreshInterval = time.Second
+
+	// lowPriorityAdmissionFactor is the multiple of the configured thresholds
+	// at which low-priority writes are held back. Bulk writes are held back
+	// as soon as a threshold is reached.
+	lowPriorityAdmissionFactor = 1.5
+)
+
+// admissionClass is the priority class a batch is admitted under.
+type admissionClass int
+
+const (
+	// admissionClassForeground is used for reads and for regular writes, which
+	// are never held back.
+	admissionClassForeground admissionClass = iota
+	// admissionClassLowPriority is used for writes of batches marked as
+	// low-priority work (such as backfills) and for MVCC garbage collection.
+	admissionClassLowPriority
+	// admissionClassBulk is used for bulk ingestion and deletion of data.
+	admissionClassBulk
+	numAdmissionClasses
+)
+
+var admissionClassNames = [numAdmissionClasses]string{
+	admissionClassForeground:  "foreground",
+	admissionClassLowPriority: "low-priority",
+	admissionClassBulk:        "bulk",
+}
+
+func (c admissionClass) String() string {
+	return admissionClassNames[c]
+}
+
+// admissionClassForBatch returns the class under which the batch is
+// admitted.
+func admissionClassForBatch(ba roachpb.BatchRequest) admissionClass {
+	if !ba.IsWrite() {
+		return admissionClassForeground
+	}
+	if _, ok := ba.GetArg(roachpb.AddSSTable); ok {
+		return admissionClassBulk
+	}
+	if _, ok := ba.GetArg(roachpb.ClearRange); ok {
+		return admissionClassBulk
+	}
+	if ba.AdmissionClass == roachpb.AdmissionClass_BULK {
+		return admissionClassBulk
+	}
+	if _, ok := ba.GetArg(roachpb.GC); ok {
+		return admissionClassLowPriority
+	}
+	if ba.AdmissionClass == roachpb.AdmissionClass_LOW_PRIORITY {
+		return admissionClassLowPriority
+	}
+	return admissionClassForeground
+}
+
+var (
+	metaAdmissionWaitForeground = metric.Metadata{
+		Name: "admission.wait.foreground",
+		Help: "Time foreground writes spent waiting for admission"}
+	metaAdmissionWaitLowPriority = metric.Metadata{
+		Name: "admission.wait.low-priority",
+		Help: "Time low-priority writes spent waiting for admission"}
+	metaAdmissionWaitBulk = metric.Metadata{
+		Name: "admission.wait.bulk",
+		Help: "Time bulk writes spent waiting for admission"}
+	metaAdmissionWaiting = metric.Metadata{
+		Name: "admission.waiting",
+		Help: "Number of writes currently waiting for admission"}
+)
+
+// AdmissionMetrics is the set of metrics for store admission control. The wait
+// histograms record every admitted write, including those which weren't held
+// back. Foreground writes never are, so theirs serves as a baseline.
+type AdmissionMetrics struct {
+	WaitForeground  *metric.Histogram
+	WaitLowPriority *metric.Histogram
+	WaitBulk        *metric.Histogram
+	Waiting         *metric.Gauge
+}
+
+func makeAdmissionMetrics(histogramWindow time.Duration) AdmissionMetrics {
+	return AdmissionMetrics{
+		WaitForeground:  metric.NewLatency(metaAdmissionWaitForeground, histogramWindow),
+		WaitLowPriority: metric.NewLatency(metaAdmissionWaitLowPriority, histogramWindow),
+		WaitBulk:        metric.NewLatency(metaAdmissionWaitBulk, histogramWindow),
+		Waiting:         metric.NewGauge(metaAdmissionWaiting),
+	}
+}
+
+func (m *AdmissionMetrics) wait(class admissionClass) *metric.Histogram {
+	switch class {
+	case admissionClassBulk:
+		return m.WaitBulk
+	case admissionClassLowPriority:
+		return m.WaitLowPriority
+	default:
+		return m.WaitForeground
+	}
+}
+
+// admissionController applies back-pressure to low-priority and bulk writes
+// while the store's engine is falling behind on compactions. The engine
+// starts stalling all writes once too many sstables pile up in level 0 or
+// its compaction debt grows too large; holding back less important work
+// before that point leaves room for foreground traffic.
+type admissionController struct {
+	st      *cluster.Settings
+	statsFn func() (*engine.Stats, error)
+	metrics AdmissionMetrics
+
+	mu struct {
+		syncutil.Mutex
+		// overload is the engine's load relative to the configured thresholds,
+		// as of the last refresh. Values of 1 or more mean a threshold has been
+		// reached.
+		overload    float64
+		lastRefresh time.Time
+		// refreshed is closed and replaced whenever overload is updated, to
+		// wake up waiting writes.
+		refreshed chan struct{}
+	}
+}
+
+// newAdmissionController returns an admissionController which reads the
+// engine's health through the supplied function, typically the engine's
+// GetStats method.
+func newAdmissionController(
+	st *cluster.Settings, statsFn func() (*engine.Stats, error), histogramWindow time.Duration,
+) *admissionController {
+	ac := &admissionController{
+		st:      st,
+		statsFn: statsFn,
+		metrics: makeAdmissionMetrics(histogramWindow),
+	}
+	ac.mu.refreshed = make(chan struct{})
+	return ac
+}
+
+// computeOverload returns the engine's load relative to the configured
+// thresholds. Thresholds which are not positive are ignored.
+func computeOverload(stats engine.Stats, l0Threshold, pendingCompactionThreshold int64) float64 {
+	var overload float64
+	if l0Threshold > 0 {
+		if o := float64(stats.L0FileCount) / float64(l0Threshold); o > overload {
+			overload = o
+		}
+	}
+	if pendingCompactionThreshold > 0 {
+		if o := float64(stats.PendingCompactionBytesEstimate) / float64(pendingCompactionThreshold); o > overload {
+			overload = o
+		}
+	}
+	return overload
+}
+
+// updateStats recomputes the engine's load from the supplied stats and wakes
+// up any waiting writes.
+func (ac *admissionController) updateStats(stats engine.Stats) {
+	overload := computeOverload(stats,
+		ac.st.AdmissionL0FileThreshold.Get(), ac.st.AdmissionPendingCompactionThreshold.Get())
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.mu.overload = overload
+	ac.mu.lastRefresh = timeutil.Now()
+	close(ac.mu.refreshed)
+	ac.mu.refreshed = make(chan struct{})
+}
+
+// refresh re-reads the engine's stats unless that happened recently.
+func (ac *admissionController) refresh() error {
+	ac.mu.Lock()
+	fresh := timeutil.Since(ac.mu.lastRefresh) < admissionRefreshInterval
+	ac.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	stats, err := ac.statsFn()
+	if err != nil {
+		return err
+	}
+	ac.updateStats(*stats)
+	return nil
+}
+
+// admissible returns whether a batch of the given class can be admitted at
+// the supplied load.
+func admissible(class admissionClass, overload float64) bool {
+	switch class {
+	case admissionClassBulk:
+		return overload < 1
+	case admissionClassLowPriority:
+		return overload < lowPriorityAdmissionFactor
+	default:
+		return true
+	}
+}
+
+// admit blocks until a write batch of the given class may be evaluated, the
+// context is canceled or the stopper is quiescing.
+func (ac *admissionController) admit(
+	ctx context.Context, stopper *stop.Stopper, class admissionClass,
+) error {
+	if !ac.st.AdmissionControlEnabled.Get() {
+		return nil
+	}
+	start := timeutil.Now()
+	defer func() {
+		ac.metrics.wait(class).RecordValue(timeutil.Since(start).Nanoseconds())
+	}()
+	if class == admissionClassForeground {
+		return nil
+	}
+	if err := ac.refresh(); err != nil {
+		// Without the engine's stats there's nothing to base back-pressure on.
+		return nil
+	}
+
+	ac.mu.Lock()
+	overload, refreshed := ac.mu.overload, ac.mu.refreshed
+	ac.mu.Unlock()
+	if admissible(class, overload) {
+		return nil
+	}
+
+	ac.metrics.Waiting.Inc(1)
+	defer ac.metrics.Waiting.Dec(1)
+	log.VEventf(ctx, 2, "delaying %s write: engine load %.2f", class, overload)
+
+	ticker := time.NewTicker(admissionRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-refreshed:
+		case <-ticker.C:
+			if err := ac.refresh(); err != nil {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stopper.ShouldQuiesce():
+			return &roachpb.NodeUnavailableError{}
+		}
+		if !ac.st.AdmissionControlEnabled.Get() {
+			return nil
+		}
+		ac.mu.Lock()
+		overload, refreshed = ac.mu.overload, ac.mu.refreshed
+		ac.mu.Unlock()
+		if admissible(class, overload) {
+			log.VEventf(ctx, 2, "admitted %s write after %s", class, timeutil.Since(start))
+			return nil
+		}
+	}
+}
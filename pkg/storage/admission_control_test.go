@@ -0,0 +1,178 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+func TestAdmissionClassForBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	key := roachpb.Key("a")
+	span := roachpb.Span{Key: key, EndKey: key.Next()}
+	// A transaction at the lowest priority isn't low-priority work unless
+	// its batches say so.
+	minPriorityTxn := roachpb.MakeTransaction(
+		"test", key, roachpb.MinUserPriority, enginepb.SERIALIZABLE, makeTS(1, 0), 0)
+
+	const (
+		foreground  = roachpb.AdmissionClass_FOREGROUND
+		lowPriority = roachpb.AdmissionClass_LOW_PRIORITY
+		bulk        = roachpb.AdmissionClass_BULK
+	)
+	testCases := []struct {
+		txn      *roachpb.Transaction
+		class    roachpb.AdmissionClass
+		req      roachpb.Request
+		expected admissionClass
+	}{
+		{nil, foreground, &roachpb.GetRequest{Span: span}, admissionClassForeground},
+		{nil, lowPriority, &roachpb.ScanRequest{Span: span}, admissionClassForeground},
+		{nil, foreground, &roachpb.PutRequest{Span: span}, admissionClassForeground},
+		{&minPriorityTxn, foreground, &roachpb.PutRequest{Span: span}, admissionClassForeground},
+		{&minPriorityTxn, lowPriority, &roachpb.PutRequest{Span: span}, admissionClassLowPriority},
+		{nil, lowPriority, &roachpb.PutRequest{Span: span}, admissionClassLowPriority},
+		{nil, bulk, &roachpb.PutRequest{Span: span}, admissionClassBulk},
+		{nil, foreground, &roachpb.GCRequest{Span: span}, admissionClassLowPriority},
+		{nil, bulk, &roachpb.GCRequest{Span: span}, admissionClassBulk},
+		{nil, foreground, &roachpb.AddSSTableRequest{Span: span}, admissionClassBulk},
+		{nil, foreground, &roachpb.ClearRangeRequest{Span: span}, admissionClassBulk},
+	}
+	for i, tc := range testCases {
+		var ba roachpb.BatchRequest
+		ba.Txn = tc.txn
+		ba.AdmissionClass = tc.class
+		ba.Add(tc.req)
+		if class := admissionClassForBatch(ba); class != tc.expected {
+			t.Errorf("%d: expected %s for %s, got %s", i, tc.expected, ba, class)
+		}
+	}
+}
+
+func TestComputeOverload(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		stats                   engine.Stats
+		l0Threshold, pThreshold int64
+		expected                float64
+	}{
+		{engine.Stats{}, 10, 100, 0},
+		{engine.Stats{L0FileCount: 5, PendingCompactionBytesEstimate: 20}, 10, 100, 0.5},
+		{engine.Stats{L0FileCount: 5, PendingCompactionBytesEstimate: 150}, 10, 100, 1.5},
+		{engine.Stats{L0FileCount: 20, PendingCompactionBytesEstimate: 150}, 10, 100, 2},
+		{engine.Stats{L0FileCount: 20, PendingCompactionBytesEstimate: 150}, 0, 100, 1.5},
+		{engine.Stats{L0FileCount: 20, PendingCompactionBytesEstimate: 150}, 0, 0, 0},
+	}
+	for i, tc := range testCases {
+		if overload := computeOverload(tc.stats, tc.l0Threshold, tc.pThreshold); overload != tc.expected {
+			t.Errorf("%d: expected overload %f, got %f", i, tc.expected, overload)
+		}
+	}
+}
+
+func TestAdmissionControllerAdmit(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+
+	var mu struct {
+		syncutil.Mutex
+		stats engine.Stats
+	}
+	setStats := func(stats engine.Stats) {
+		mu.Lock()
+		defer mu.Unlock()
+		mu.stats = stats
+	}
+	statsFn := func() (*engine.Stats, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stats := mu.stats
+		return &stats, nil
+	}
+
+	st := cluster.MakeTestingClusterSettings()
+	st.AdmissionL0FileThreshold.Override(10)
+	ac := newAdmissionController(st, statsFn, time.Minute)
+	ctx := context.Background()
+
+	// At 1.2 times the threshold, low-priority writes are still admitted but
+	// bulk writes are held back.
+	setStats(engine.Stats{L0FileCount: 12})
+	ac.updateStats(engine.Stats{L0FileCount: 12})
+	for _, class := range []admissionClass{admissionClassForeground, admissionClassLowPriority} {
+		if err := ac.admit(ctx, stopper, class); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := ac.admit(cancelledCtx, stopper, admissionClassBulk); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	// Disabling admission control lets bulk writes through.
+	st.AdmissionControlEnabled.Override(false)
+	if err := ac.admit(ctx, stopper, admissionClassBulk); err != nil {
+		t.Fatal(err)
+	}
+	st.AdmissionControlEnabled.Override(true)
+
+	// A waiting bulk write is admitted once the engine has caught up.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ac.admit(ctx, stopper, admissionClassBulk)
+	}()
+	testutils.SucceedsSoon(t, func() error {
+		if n := ac.metrics.Waiting.Value(); n != 1 {
+			return errors.Errorf("expected 1 waiting write, got %d", n)
+		}
+		return nil
+	})
+	setStats(engine.Stats{L0FileCount: 4})
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	// Every write which went through admission control is recorded, whether
+	// it was held back or not.
+	if n := ac.metrics.WaitForeground.TotalCount(); n != 1 {
+		t.Fatalf("expected 1 recorded foreground wait, got %d", n)
+	}
+	if n := ac.metrics.WaitLowPriority.TotalCount(); n != 1 {
+		t.Fatalf("expected 1 recorded low-priority wait, got %d", n)
+	}
+	if n := ac.metrics.WaitBulk.TotalCount(); n != 2 {
+		t.Fatalf("expected 2 recorded bulk waits, got %d", n)
+	}
+	if n := ac.metrics.Waiting.Value(); n != 0 {
+		t.Fatalf("expected no waiting writes, got %d", n)
+	}
+}
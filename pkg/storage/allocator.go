@@ -51,11 +51,15 @@ const (
 	minReplicaWeight = 0.001
 
 	// priorities for various repair operations.
+	removeLearnerReplicaPriority          float64 = 12000
 	addMissingReplicaPriority             float64 = 10000
 	addDecommissioningReplacementPriority float64 = 5000
+	removeInconsistentReplicaPriority     float64 = 2000
 	removeDeadReplicaPriority             float64 = 1000
 	removeDecommissioningReplicaPriority  float64 = 200
 	removeExtraReplicaPriority            float64 = 100
+	addMissingNonVoterPriority            float64 = 50
+	removeNonVoterPriority                float64 = 75
 )
 
 // MinLeaseTransferStatsDuration configures the minimum amount of time a
@@ -78,6 +82,10 @@ const (
 	AllocatorRemoveDead
 	AllocatorRemoveDecommissioning
 	AllocatorConsiderRebalance
+	AllocatorRemoveLearner
+	AllocatorAddNonVoter
+	AllocatorRemoveNonVoter
+	AllocatorRemoveInconsistent
 )
 
 var allocatorActionNames = map[AllocatorAction]string{
@@ -87,6 +95,10 @@ var allocatorActionNames = map[AllocatorAction]string{
 	AllocatorRemoveDead:            "remove dead",
 	AllocatorRemoveDecommissioning: "remove decommissioning",
 	AllocatorConsiderRebalance:     "consider rebalance",
+	AllocatorRemoveLearner:         "remove learner",
+	AllocatorAddNonVoter:           "add non-voter",
+	AllocatorRemoveNonVoter:        "remove non-voter",
+	AllocatorRemoveInconsistent:    "remove inconsistent",
 }
 
 func (a AllocatorAction) String() string {
@@ -153,18 +165,40 @@ func makeAllocatorRand(source rand.Source) allocatorRand {
 // RangeInfo contains the information needed by the allocator to make
 // rebalancing decisions for a given range.
 type RangeInfo struct {
-	Desc            *roachpb.RangeDescriptor
-	LogicalBytes    int64
-	WritesPerSecond float64
+	Desc             *roachpb.RangeDescriptor
+	LogicalBytes     int64
+	WritesPerSecond  float64
+	QueriesPerSecond float64
+	// InconsistentReplicas are the replicas which a consistency check found
+	// to disagree with a majority of the range.
+	InconsistentReplicas []roachpb.ReplicaDescriptor
+	// AbandonedLearners are the learners of the range which aren't being
+	// added by the leaseholder right now.
+	AbandonedLearners []roachpb.ReplicaDescriptor
 }
 
 func rangeInfoForRepl(repl *Replica, desc *roachpb.RangeDescriptor) RangeInfo {
 	writesPerSecond, _ := repl.writeStats.avgQPS()
-	return RangeInfo{
+	info := RangeInfo{
 		Desc:            desc,
 		LogicalBytes:    repl.GetMVCCStats().Total(),
 		WritesPerSecond: writesPerSecond,
 	}
+	if repl.store.cfg.Settings.ConsistencyRepairEnabled.Get() {
+		ctx := repl.AnnotateCtx(context.TODO())
+		inconsistent, err := repl.inconsistentReplicas(ctx, desc)
+		if err != nil {
+			log.Warningf(ctx, "could not read inconsistent replicas: %s", err)
+		}
+		info.InconsistentReplicas = inconsistent
+	}
+	info.AbandonedLearners = repl.abandonedLearners(desc)
+	if repl.leaseholderStats != nil {
+		if queriesPerSecond, dur := repl.leaseholderStats.avgQPS(); dur >= MinStatsDuration {
+			info.QueriesPerSecond = queriesPerSecond
+		}
+	}
+	return info
 }
 
 // Allocator tries to spread replicas as evenly as possible across the stores
@@ -206,9 +240,27 @@ func (a *Allocator) ComputeAction(
 		return AllocatorNoop, 0
 	}
 
+	if learners := rangeInfo.AbandonedLearners; len(learners) > 0 {
+		// Learners only exist for the short time it takes to catch them up
+		// with a snapshot before they're promoted to voters. Those which the
+		// leaseholder isn't adding belong to an addition that was interrupted,
+		// so they're removed before anything else is done to the range.
+		if log.V(3) {
+			log.Infof(ctx, "AllocatorRemoveLearner - learners=%v, priority=%.2f",
+				learners, removeLearnerReplicaPriority)
+		}
+		return AllocatorRemoveLearner, removeLearnerReplicaPriority
+	}
+	if len(rangeInfo.Desc.Learners()) > 0 {
+		// A replica addition is in progress. Leave the range alone until it's
+		// done rather than adding another replica on top of it.
+		return AllocatorNoop, 0
+	}
+
 	// TODO(mrtracy): Handle non-homogeneous and mismatched attribute sets.
-	need := int(zone.NumReplicas)
-	have := len(rangeInfo.Desc.Replicas)
+	voters := rangeInfo.Desc.Voters()
+	need := int(zone.GetNumVoters())
+	have := len(voters)
 	quorum := computeQuorum(need)
 	if have < need {
 		// Range is under-replicated, and should add an additional replica.
@@ -221,7 +273,7 @@ func (a *Allocator) ComputeAction(
 		return AllocatorAdd, priority
 	}
 
-	decommissioningReplicas := a.storePool.decommissioningReplicas(rangeInfo.Desc.RangeID, rangeInfo.Desc.Replicas)
+	decommissioningReplicas := a.storePool.decommissioningReplicas(rangeInfo.Desc.RangeID, voters)
 	if have == need && len(decommissioningReplicas) > 0 {
 		// Range has decommissioning replica(s). We should up-replicate to add
 		// another replica. The decommissioning replica(s) will be down-replicated
@@ -233,7 +285,7 @@ func (a *Allocator) ComputeAction(
 		return AllocatorAdd, priority
 	}
 
-	liveReplicas, deadReplicas := a.storePool.liveAndDeadReplicas(rangeInfo.Desc.RangeID, rangeInfo.Desc.Replicas)
+	liveReplicas, deadReplicas := a.storePool.liveAndDeadReplicas(rangeInfo.Desc.RangeID, voters)
 	if len(liveReplicas) < quorum {
 		// Do not take any removal action if we do not have a quorum of live
 		// replicas.
@@ -242,6 +294,18 @@ func (a *Allocator) ComputeAction(
 		return AllocatorNoop, 0
 	}
 	// Removal actions follow.
+	if inconsistent := rangeInfo.InconsistentReplicas; len(inconsistent) > 0 && len(deadReplicas) == 0 {
+		// The range has replicas whose data disagrees with a majority of the
+		// range. They're removed so that they can be replaced by fresh replicas
+		// using a snapshot, which is only done while all the replicas are live
+		// so that the removal can't cost the range its quorum.
+		priority := removeInconsistentReplicaPriority
+		if log.V(3) {
+			log.Infof(ctx, "AllocatorRemoveInconsistent - inconsistent=%v, priority=%.2f",
+				inconsistent, priority)
+		}
+		return AllocatorRemoveInconsistent, priority
+	}
 	if len(deadReplicas) > 0 {
 		// The range has dead replicas, which should be removed immediately.
 		removeDead := false
@@ -298,15 +362,43 @@ func (a *Allocator) ComputeAction(
 		return AllocatorRemove, priority
 	}
 
+	// The voters are in order; now look at the non-voters, which are only
+	// ever added or removed (rather than rebalanced) since they don't affect
+	// the range's availability.
+	nonVoters := rangeInfo.Desc.NonVoters()
+	needNonVoters := int(zone.GetNumNonVoters())
+	haveNonVoters := len(nonVoters)
+	_, deadNonVoters := a.storePool.liveAndDeadReplicas(rangeInfo.Desc.RangeID, nonVoters)
+	decommissioningNonVoters := a.storePool.decommissioningReplicas(rangeInfo.Desc.RangeID, nonVoters)
+	if haveNonVoters > needNonVoters || len(deadNonVoters) > 0 || len(decommissioningNonVoters) > 0 {
+		// Dead and decommissioning non-voters are removed outright and
+		// replaced by a new non-voter afterwards.
+		if log.V(3) {
+			log.Infof(ctx, "AllocatorRemoveNonVoter - need=%d, have=%d, dead=%d, decommissioning=%d, priority=%.2f",
+				needNonVoters, haveNonVoters, len(deadNonVoters), len(decommissioningNonVoters),
+				removeNonVoterPriority)
+		}
+		return AllocatorRemoveNonVoter, removeNonVoterPriority
+	}
+	if haveNonVoters < needNonVoters {
+		priority := addMissingNonVoterPriority + float64(needNonVoters-haveNonVoters)
+		if log.V(3) {
+			log.Infof(ctx, "AllocatorAddNonVoter - need=%d, have=%d, priority=%.2f",
+				needNonVoters, haveNonVoters, priority)
+		}
+		return AllocatorAddNonVoter, priority
+	}
+
 	// Nothing needs to be done, but we may want to rebalance.
 	return AllocatorConsiderRebalance, 0
 }
 
 type decisionDetails struct {
-	Target               string
-	Existing             string `json:",omitempty"`
-	RangeBytes           int64
-	RangeWritesPerSecond float64
+	Target                string
+	Existing              string `json:",omitempty"`
+	RangeBytes            int64
+	RangeWritesPerSecond  float64
+	RangeQueriesPerSecond float64
 }
 
 // AllocateTarget returns a suitable store for a new allocation with the
@@ -342,9 +434,10 @@ func (a *Allocator) AllocateTarget(
 			log.Infof(ctx, "add target: %s", target)
 		}
 		details, err := json.Marshal(decisionDetails{
-			Target:               target.String(),
-			RangeBytes:           rangeInfo.LogicalBytes,
-			RangeWritesPerSecond: rangeInfo.WritesPerSecond,
+			Target:                target.String(),
+			RangeBytes:            rangeInfo.LogicalBytes,
+			RangeWritesPerSecond:  rangeInfo.WritesPerSecond,
+			RangeQueriesPerSecond: rangeInfo.QueriesPerSecond,
 		})
 		if err != nil {
 			log.Warningf(ctx, "failed to marshal details for choosing allocate target: %s", err)
@@ -402,9 +495,10 @@ func (a Allocator) RemoveTarget(
 					log.Infof(ctx, "remove target: %s", bad)
 				}
 				details, err := json.Marshal(decisionDetails{
-					Target:               bad.String(),
-					RangeBytes:           rangeInfo.LogicalBytes,
-					RangeWritesPerSecond: rangeInfo.WritesPerSecond,
+					Target:                bad.String(),
+					RangeBytes:            rangeInfo.LogicalBytes,
+					RangeWritesPerSecond:  rangeInfo.WritesPerSecond,
+					RangeQueriesPerSecond: rangeInfo.QueriesPerSecond,
 				})
 				if err != nil {
 					log.Warningf(ctx, "failed to marshal details for choosing remove target: %s", err)
@@ -460,11 +554,12 @@ func (a Allocator) RebalanceTarget(
 	// we'll have to wait for the down node to be declared dead and go through the
 	// dead-node removal dance: remove dead replica, add new replica.
 	//
-	// NB: The len(rangeInfo.Desc.Replicas) > 1 check allows rebalancing of ranges
-	// with only a single replica. This is a corner case which could happen in
-	// practice and also affects tests.
-	newQuorum := computeQuorum(len(rangeInfo.Desc.Replicas) + 1)
-	if len(rangeInfo.Desc.Replicas) > 1 && len(existingCandidates) < newQuorum {
+	// NB: The len(voters) > 1 check allows rebalancing of ranges with only a
+	// single replica. This is a corner case which could happen in practice and
+	// also affects tests. Non-voters don't count towards the quorum.
+	voters := rangeInfo.Desc.Voters()
+	newQuorum := computeQuorum(len(voters) + 1)
+	if len(voters) > 1 && len(existingCandidates) < newQuorum {
 		// Don't rebalance as we won't be able to make quorum after the rebalance
 		// until the new replica has been caught up.
 		return nil, ""
@@ -486,10 +581,11 @@ func (a Allocator) RebalanceTarget(
 		return nil, ""
 	}
 	details, err := json.Marshal(decisionDetails{
-		Target:               target.String(),
-		Existing:             existingCandidates.String(),
-		RangeBytes:           rangeInfo.LogicalBytes,
-		RangeWritesPerSecond: rangeInfo.WritesPerSecond,
+		Target:                target.String(),
+		Existing:              existingCandidates.String(),
+		RangeBytes:            rangeInfo.LogicalBytes,
+		RangeWritesPerSecond:  rangeInfo.WritesPerSecond,
+		RangeQueriesPerSecond: rangeInfo.QueriesPerSecond,
 	})
 	if err != nil {
 		log.Warningf(ctx, "failed to marshal details for choosing rebalance target: %s", err)
@@ -500,9 +596,12 @@ func (a Allocator) RebalanceTarget(
 // TransferLeaseTarget returns a suitable replica to transfer the range lease
 // to from the provided list. It excludes the current lease holder replica
 // unless asked to do otherwise by the checkTransferLeaseSource parameter.
+// If the zone has lease preferences, only replicas satisfying the first
+// preference satisfied by any replica are considered, and the lease is moved
+// to one of them whenever the current lease holder doesn't satisfy it.
 func (a *Allocator) TransferLeaseTarget(
 	ctx context.Context,
-	constraints config.Constraints,
+	zone config.ZoneConfig,
 	existing []roachpb.ReplicaDescriptor,
 	leaseStoreID roachpb.StoreID,
 	rangeID roachpb.RangeID,
@@ -512,7 +611,7 @@ func (a *Allocator) TransferLeaseTarget(
 	alwaysAllowDecisionWithoutStats bool,
 ) roachpb.ReplicaDescriptor {
 	sl, _, _ := a.storePool.getStoreList(rangeID, storeFilterNone)
-	sl = sl.filter(constraints)
+	sl = sl.filter(zone.Constraints)
 
 	// Filter stores that are on nodes containing existing replicas, but leave
 	// the stores containing the existing replicas in place. This excludes stores
@@ -542,6 +641,29 @@ func (a *Allocator) TransferLeaseTarget(
 		return roachpb.ReplicaDescriptor{}
 	}
 
+	// Restrict the candidates to the preferred lease holders, if any.
+	var leaseHolderNotPreferred bool
+	preferred, preference := a.preferredLeaseholders(zone, sl, existing, leaseStoreID)
+	if len(preferred) > 0 {
+		existing = preferred
+		if !storeHasReplica(leaseStoreID, existing) {
+			// The lease holder isn't preferred, so the lease has to move
+			// regardless of how the lease counts are balanced. The candidates
+			// are only compared to the other stores satisfying the preference,
+			// and the lease isn't moved to an overfull one.
+			leaseHolderNotPreferred = true
+			checkTransferLeaseSource = false
+			checkCandidateFullness = false
+			var matching []roachpb.StoreDescriptor
+			for _, s := range sl.stores {
+				if storeMatchesLeasePreference(s, preference) {
+					matching = append(matching, s)
+				}
+			}
+			sl = makeStoreList(matching)
+		}
+	}
+
 	// Try to pick a replica to transfer the lease to while also determining
 	// whether we actually should be transferring the lease. The transfer
 	// decision is only needed if we've been asked to check the source.
@@ -580,9 +702,14 @@ func (a *Allocator) TransferLeaseTarget(
 		if !ok {
 			continue
 		}
-		if !checkCandidateFullness || float64(storeDesc.Capacity.LeaseCount) < sl.candidateLeases.mean-0.5 {
-			candidates = append(candidates, repl)
+		if checkCandidateFullness && float64(storeDesc.Capacity.LeaseCount) >= sl.candidateLeases.mean-0.5 {
+			continue
+		}
+		if leaseHolderNotPreferred &&
+			storeDesc.Capacity.LeaseCount > overfullLeaseThreshold(sl.candidateLeases.mean) {
+			continue
 		}
+		candidates = append(candidates, repl)
 	}
 	if len(candidates) == 0 {
 		return roachpb.ReplicaDescriptor{}
@@ -592,12 +719,13 @@ func (a *Allocator) TransferLeaseTarget(
 	return candidates[a.randGen.Intn(len(candidates))]
 }
 
-// ShouldTransferLease returns true if the specified store is overfull in terms
-// of leases with respect to the other stores matching the specified
-// attributes.
+// ShouldTransferLease returns true if the specified store doesn't satisfy the
+// zone's lease preferences while another replica's store does, or if it is
+// overfull in terms of leases with respect to the other stores matching the
+// zone's constraints.
 func (a *Allocator) ShouldTransferLease(
 	ctx context.Context,
-	constraints config.Constraints,
+	zone config.ZoneConfig,
 	existing []roachpb.ReplicaDescriptor,
 	leaseStoreID roachpb.StoreID,
 	rangeID roachpb.RangeID,
@@ -607,8 +735,24 @@ func (a *Allocator) ShouldTransferLease(
 	if !ok {
 		return false
 	}
+
 	sl, _, _ := a.storePool.getStoreList(rangeID, storeFilterNone)
-	sl = sl.filter(constraints)
+	sl = sl.filter(zone.Constraints)
+
+	if preferred, _ := a.preferredLeaseholders(zone, sl, existing, leaseStoreID); len(preferred) > 0 {
+		if !storeHasReplica(leaseStoreID, preferred) {
+			if log.V(3) {
+				log.Infof(ctx, "ShouldTransferLease (lease-holder=%d): not a preferred lease holder", leaseStoreID)
+			}
+			return true
+		}
+		// Only balance leases among the preferred lease holders.
+		if len(preferred) == 1 {
+			return false
+		}
+		existing = preferred
+	}
+
 	if log.V(3) {
 		log.Infof(ctx, "ShouldTransferLease (lease-holder=%d):\n%s", leaseStoreID, sl)
 	}
@@ -809,14 +953,8 @@ func (a Allocator) shouldTransferLeaseWithoutStats(
 	source roachpb.StoreDescriptor,
 	existing []roachpb.ReplicaDescriptor,
 ) bool {
-	// Allow lease transfer if we're above the overfull threshold, which is
-	// mean*(1+baseLeaseRebalanceThreshold).
-	overfullLeaseThreshold := int32(math.Ceil(sl.candidateLeases.mean * (1 + baseLeaseRebalanceThreshold)))
-	minOverfullThreshold := int32(math.Ceil(sl.candidateLeases.mean + 5))
-	if overfullLeaseThreshold < minOverfullThreshold {
-		overfullLeaseThreshold = minOverfullThreshold
-	}
-	if source.Capacity.LeaseCount > overfullLeaseThreshold {
+	// Allow lease transfer if we're above the overfull threshold.
+	if source.Capacity.LeaseCount > overfullLeaseThreshold(sl.candidateLeases.mean) {
 		return true
 	}
 
@@ -840,6 +978,68 @@ func (a Allocator) shouldTransferLeaseWithoutStats(
 	return false
 }
 
+// overfullLeaseThreshold returns the number of leases above which a store is
+// considered overfull, which is mean*(1+baseLeaseRebalanceThreshold) but at
+// least mean+5.
+func overfullLeaseThreshold(mean float64) int32 {
+	threshold := int32(math.Ceil(mean * (1 + baseLeaseRebalanceThreshold)))
+	if minThreshold := int32(math.Ceil(mean + 5)); threshold < minThreshold {
+		threshold = minThreshold
+	}
+	return threshold
+}
+
+// preferredLeaseholders returns the replicas in existing whose stores satisfy
+// the first of the zone's lease preferences that any of them satisfies, along
+// with that preference, or nil if the zone has no lease preferences or none
+// of them can be satisfied. Apart from the lease holder's, only the replicas
+// on stores in sl are considered, so that a preference isn't satisfied by a
+// dead or throttled store which the lease can't be transferred to.
+func (a Allocator) preferredLeaseholders(
+	zone config.ZoneConfig,
+	sl StoreList,
+	existing []roachpb.ReplicaDescriptor,
+	leaseStoreID roachpb.StoreID,
+) ([]roachpb.ReplicaDescriptor, config.Constraints) {
+	if len(zone.LeasePreferences) == 0 {
+		return nil, config.Constraints{}
+	}
+	storeDescs := make(map[roachpb.StoreID]roachpb.StoreDescriptor, len(sl.stores)+1)
+	for _, s := range sl.stores {
+		storeDescs[s.StoreID] = s
+	}
+	if s, ok := a.storePool.getStoreDescriptor(leaseStoreID); ok {
+		storeDescs[leaseStoreID] = s
+	}
+	for _, preference := range zone.LeasePreferences {
+		var preferred []roachpb.ReplicaDescriptor
+		for _, repl := range existing {
+			storeDesc, ok := storeDescs[repl.StoreID]
+			if !ok {
+				continue
+			}
+			if storeMatchesLeasePreference(storeDesc, preference) {
+				preferred = append(preferred, repl)
+			}
+		}
+		if len(preferred) > 0 {
+			return preferred, preference
+		}
+	}
+	return nil, config.Constraints{}
+}
+
+// storeHasReplica returns true if one of the provided replicas is on the
+// given store.
+func storeHasReplica(storeID roachpb.StoreID, replicas []roachpb.ReplicaDescriptor) bool {
+	for _, repl := range replicas {
+		if repl.StoreID == storeID {
+			return true
+		}
+	}
+	return false
+}
+
 // computeQuorum computes the quorum value for the given number of nodes.
 func computeQuorum(nodes int) int {
 	return (nodes / 2) + 1
@@ -864,6 +1064,11 @@ func filterBehindReplicas(
 	// behind the actual commit index of the range.
 	candidates := make([]roachpb.ReplicaDescriptor, 0, len(replicas))
 	for _, r := range replicas {
+		if !r.IsVoter() {
+			// Learners and non-voters can neither hold the lease nor count
+			// towards quorum.
+			continue
+		}
 		if progress, ok := raftStatus.Progress[uint64(r.ReplicaID)]; ok {
 			if uint64(r.ReplicaID) == raftStatus.Lead ||
 				(progress.State == raft.ProgressStateReplicate &&
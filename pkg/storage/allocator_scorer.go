@@ -46,18 +46,19 @@ func statsBasedRebalancingEnabled(st *cluster.Settings) bool {
 }
 
 type balanceDimensions struct {
-	ranges rangeCountStatus
-	bytes  float64
-	writes float64
+	ranges  rangeCountStatus
+	bytes   float64
+	writes  float64
+	queries float64
 }
 
 func (bd *balanceDimensions) totalScore() float64 {
-	return float64(bd.ranges) + bd.bytes + bd.writes
+	return float64(bd.ranges) + bd.bytes + bd.writes + bd.queries
 }
 
 func (bd balanceDimensions) String() string {
-	return fmt.Sprintf("%.2f(ranges=%d, bytes=%.2f, writes=%.2f)",
-		bd.totalScore(), int(bd.ranges), bd.bytes, bd.writes)
+	return fmt.Sprintf("%.2f(ranges=%d, bytes=%.2f, writes=%.2f, queries=%.2f)",
+		bd.totalScore(), int(bd.ranges), bd.bytes, bd.writes, bd.queries)
 }
 
 // candidate store for allocation.
@@ -664,6 +665,25 @@ func constraintCheck(store roachpb.StoreDescriptor, constraints config.Constrain
 	return true, positive
 }
 
+// storeMatchesLeasePreference returns true iff the store satisfies all of the
+// constraints of a lease preference, which are either required or prohibited.
+func storeMatchesLeasePreference(store roachpb.StoreDescriptor, preference config.Constraints) bool {
+	for _, constraint := range preference.Constraints {
+		hasConstraint := storeHasConstraint(store, constraint)
+		switch constraint.Type {
+		case config.Constraint_REQUIRED:
+			if !hasConstraint {
+				return false
+			}
+		case config.Constraint_PROHIBITED:
+			if hasConstraint {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // diversityScore returns a score between 1 and 0 where higher scores are stores
 // with the fewest locality tiers in common with already existing replicas.
 func diversityScore(
@@ -765,9 +785,32 @@ func balanceScore(
 			sc.WritesPerReplica,
 			rangeInfo.WritesPerSecond)
 	}
+	if cluster.LoadBasedRebalancingMode(st.LoadBasedRebalancingMode.Get()) != cluster.LBRebalancingOff {
+		dimensions.queries = queriesContribution(st, sl, sc, rangeInfo.QueriesPerSecond)
+	}
 	return dimensions
 }
 
+// queriesContribution generates the QPS dimension's contribution to a range's
+// balanceScore. Unlike the other dimensions there are no per-replica
+// percentiles for QPS, so a range serving any queries is simply a bad fit for
+// stores above the QPS threshold used by the store rebalancer and a good fit
+// for stores below the matching lower threshold. This keeps the replicate
+// queue from undoing the moves of the store rebalancer.
+func queriesContribution(
+	st *cluster.Settings, sl StoreList, sc roachpb.StoreCapacity, rangeQPS float64,
+) float64 {
+	if rangeQPS <= 0 {
+		return 0
+	}
+	if sc.QueriesPerSecond > qpsMaxThreshold(st, sl) {
+		return -1
+	} else if sc.QueriesPerSecond < qpsMinThreshold(st, sl) {
+		return 1
+	}
+	return 0
+}
+
 // balanceContribution generates a single dimension's contribution to a range's
 // balanceScore, where larger values mean a store is a better fit for a given
 // range.
@@ -863,7 +906,7 @@ func percentileScore(
 
 func rangeIsGoodFit(bd balanceDimensions) bool {
 	// A score greater than 1 means that more than one dimension improves
-	// without being canceled out by the third, since each dimension can only
+	// without being canceled out by the others, since each dimension can only
 	// contribute a value from [-1,1] to the score.
 	return bd.totalScore() > 1
 }
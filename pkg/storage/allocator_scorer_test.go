@@ -786,6 +786,9 @@ func TestBalanceScore(t *testing.T) {
 		candidateRanges:          stat{mean: 1000},
 		candidateLogicalBytes:    stat{mean: 512 * 1024 * 1024},
 		candidateWritesPerSecond: stat{mean: 1000},
+		// QPS only contributes for ranges that serve queries, so the mean
+		// doesn't affect the cases without them.
+		candidateQueriesPerSecond: stat{mean: 1000},
 	}
 
 	sEmpty := roachpb.StoreCapacity{
@@ -794,11 +797,12 @@ func TestBalanceScore(t *testing.T) {
 		LogicalBytes: 0,
 	}
 	sMean := roachpb.StoreCapacity{
-		Capacity:        1024 * 1024 * 1024,
-		Available:       512 * 1024 * 1024,
-		LogicalBytes:    512 * 1024 * 1024,
-		RangeCount:      1000,
-		WritesPerSecond: 1000,
+		Capacity:         1024 * 1024 * 1024,
+		Available:        512 * 1024 * 1024,
+		LogicalBytes:     512 * 1024 * 1024,
+		RangeCount:       1000,
+		WritesPerSecond:  1000,
+		QueriesPerSecond: 1000,
 		BytesPerReplica: roachpb.Percentiles{
 			P10: 100 * 1024,
 			P25: 250 * 1024,
@@ -844,6 +848,10 @@ func TestBalanceScore(t *testing.T) {
 	sRangesUnderfullBytesOverfullWritesOverfull.WritesPerSecond = 1500
 	sRangesUnderfullBytesUnderfullWritesOverfull := sRangesUnderfullBytesUnderfull
 	sRangesUnderfullBytesUnderfullWritesOverfull.WritesPerSecond = 1500
+	sQueriesOverfull := sMean
+	sQueriesOverfull.QueriesPerSecond = 2000
+	sQueriesUnderfull := sMean
+	sQueriesUnderfull.QueriesPerSecond = 200
 
 	rEmpty := RangeInfo{}
 	rMedian := RangeInfo{
@@ -866,6 +874,8 @@ func TestBalanceScore(t *testing.T) {
 	rHighWrites.WritesPerSecond = 20
 	rLowWrites := rMedian
 	rLowWrites.WritesPerSecond = 0.5
+	rQueries := rMedian
+	rQueries.QueriesPerSecond = 50
 
 	testCases := []struct {
 		sc       roachpb.StoreCapacity
@@ -930,6 +940,11 @@ func TestBalanceScore(t *testing.T) {
 		{sRangesUnderfullBytesUnderfullWritesOverfull, rLowBytesLowWrites, 1.5},
 		{sRangesUnderfullBytesUnderfullWritesOverfull, rHighWrites, 1},
 		{sRangesUnderfullBytesUnderfullWritesOverfull, rLowWrites, 3},
+		{sMean, rQueries, 0},
+		{sQueriesOverfull, rMedian, 0},
+		{sQueriesOverfull, rQueries, -1},
+		{sQueriesUnderfull, rMedian, 0},
+		{sQueriesUnderfull, rQueries, 1},
 	}
 	for i, tc := range testCases {
 		if a, e := balanceScore(st, storeList, tc.sc, tc.ri), tc.expected; a.totalScore() != e {
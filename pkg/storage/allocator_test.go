@@ -1019,7 +1019,7 @@ func TestAllocatorTransferLeaseTarget(t *testing.T) {
 		t.Run("", func(t *testing.T) {
 			target := a.TransferLeaseTarget(
 				context.Background(),
-				config.Constraints{},
+				config.ZoneConfig{},
 				c.existing,
 				c.leaseholder,
 				0,
@@ -1073,7 +1073,7 @@ func TestAllocatorTransferLeaseTargetMultiStore(t *testing.T) {
 		t.Run("", func(t *testing.T) {
 			target := a.TransferLeaseTarget(
 				context.Background(),
-				config.Constraints{},
+				config.ZoneConfig{},
 				existing,
 				c.leaseholder,
 				0,
@@ -1136,7 +1136,7 @@ func TestAllocatorShouldTransferLease(t *testing.T) {
 		t.Run("", func(t *testing.T) {
 			result := a.ShouldTransferLease(
 				context.Background(),
-				config.Constraints{},
+				config.ZoneConfig{},
 				c.existing,
 				c.leaseholder,
 				0,
@@ -1149,6 +1149,143 @@ func TestAllocatorShouldTransferLease(t *testing.T) {
 	}
 }
 
+func TestAllocatorLeasePreferences(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	stopper, g, storePool, a, _ := createTestAllocator( /* deterministic */ true)
+	defer stopper.Stop(context.Background())
+
+	// 4 stores with an equal number of leases, two of them in us-east1 and one
+	// each in us-west1 and eu-west1.
+	regions := []string{"us-east1", "us-east1", "us-west1", "eu-west1"}
+	var stores []*roachpb.StoreDescriptor
+	for i, region := range regions {
+		stores = append(stores, &roachpb.StoreDescriptor{
+			StoreID: roachpb.StoreID(i + 1),
+			Node: roachpb.NodeDescriptor{
+				NodeID: roachpb.NodeID(i + 1),
+				Locality: roachpb.Locality{
+					Tiers: []roachpb.Tier{{Key: "region", Value: region}},
+				},
+			},
+			Capacity: roachpb.StoreCapacity{LeaseCount: 10},
+		})
+	}
+	sg := gossiputil.NewStoreGossiper(g)
+	sg.GossipStores(stores, t)
+
+	existing := []roachpb.ReplicaDescriptor{
+		{StoreID: 1},
+		{StoreID: 2},
+		{StoreID: 3},
+	}
+	pref := func(constraints ...string) config.Constraints {
+		var c config.Constraints
+		for _, short := range constraints {
+			var constraint config.Constraint
+			if err := constraint.FromString(short); err != nil {
+				t.Fatal(err)
+			}
+			c.Constraints = append(c.Constraints, constraint)
+		}
+		return c
+	}
+
+	testCases := []struct {
+		preferences    []config.Constraints
+		leaseholder    roachpb.StoreID
+		expectTransfer bool
+		expectedTarget roachpb.StoreID
+	}{
+		// No preferences, the leases are balanced.
+		{nil, 1, false, 0},
+		{nil, 3, false, 0},
+		// A single preferred replica gets the lease.
+		{[]config.Constraints{pref("+region=us-west1")}, 1, true, 3},
+		{[]config.Constraints{pref("+region=us-west1")}, 2, true, 3},
+		{[]config.Constraints{pref("+region=us-west1")}, 3, false, 0},
+		// Prohibited constraints exclude the matching stores.
+		{[]config.Constraints{pref("-region=us-east1")}, 1, true, 3},
+		// Preferences that no replica satisfies are skipped.
+		{[]config.Constraints{pref("+region=eu-west1"), pref("+region=us-west1")}, 1, true, 3},
+		{[]config.Constraints{pref("+region=eu-west1")}, 1, false, 0},
+		// The leases are balanced among several preferred replicas.
+		{[]config.Constraints{pref("+region=us-east1")}, 1, false, 0},
+		{[]config.Constraints{pref("+region=us-east1")}, 2, false, 0},
+	}
+	for _, c := range testCases {
+		t.Run("", func(t *testing.T) {
+			zone := config.ZoneConfig{LeasePreferences: c.preferences}
+			result := a.ShouldTransferLease(
+				context.Background(),
+				zone,
+				existing,
+				c.leaseholder,
+				0,
+				nil, /* replicaStats */
+			)
+			if c.expectTransfer != result {
+				t.Errorf("expected ShouldTransferLease %v, but found %v", c.expectTransfer, result)
+			}
+			target := a.TransferLeaseTarget(
+				context.Background(),
+				zone,
+				existing,
+				c.leaseholder,
+				0,
+				nil,   /* replicaStats */
+				true,  /* checkTransferLeaseSource */
+				true,  /* checkCandidateFullness */
+				false, /* !alwaysAllowDecisionWithoutStats */
+			)
+			if c.expectedTarget != target.StoreID {
+				t.Errorf("expected TransferLeaseTarget %d, but found %d", c.expectedTarget, target.StoreID)
+			}
+		})
+	}
+
+	transferLeaseTarget := func(zone config.ZoneConfig, leaseholder roachpb.StoreID) roachpb.StoreID {
+		return a.TransferLeaseTarget(
+			context.Background(),
+			zone,
+			existing,
+			leaseholder,
+			0,
+			nil,   /* replicaStats */
+			true,  /* checkTransferLeaseSource */
+			true,  /* checkCandidateFullness */
+			false, /* !alwaysAllowDecisionWithoutStats */
+		).StoreID
+	}
+
+	// A preferred store that is overfull compared to the other stores
+	// satisfying the preference doesn't get the lease.
+	stores[1].Capacity.LeaseCount = 30
+	sg.GossipStores(stores, t)
+	zone := config.ZoneConfig{LeasePreferences: []config.Constraints{pref("+region=us-east1")}}
+	if target := transferLeaseTarget(zone, 3); target != 1 {
+		t.Errorf("expected TransferLeaseTarget 1, but found %d", target)
+	}
+
+	// A dead store doesn't satisfy a preference, so the next one applies.
+	storePool.detailsMu.Lock()
+	storePool.nodeLivenessFn = func(nodeID roachpb.NodeID, _ time.Time, _ time.Duration) nodeStatus {
+		if nodeID == 3 {
+			return nodeStatusDead
+		}
+		return nodeStatusLive
+	}
+	storePool.detailsMu.Unlock()
+	zone = config.ZoneConfig{
+		LeasePreferences: []config.Constraints{pref("+region=us-west1"), pref("+region=us-east1")},
+	}
+	if a.ShouldTransferLease(context.Background(), zone, existing, 1, 0, nil /* replicaStats */) {
+		t.Errorf("expected ShouldTransferLease false, but found true")
+	}
+	if target := transferLeaseTarget(zone, 1); target != 0 {
+		t.Errorf("expected TransferLeaseTarget 0, but found %d", target)
+	}
+}
+
 // Test out the load-based lease transfer algorithm against a variety of
 // request distributions and inter-node latencies.
 func TestAllocatorTransferLeaseTargetLoadBased(t *testing.T) {
@@ -1302,7 +1439,7 @@ func TestAllocatorTransferLeaseTargetLoadBased(t *testing.T) {
 			})
 			target := a.TransferLeaseTarget(
 				context.Background(),
-				config.Constraints{},
+				config.ZoneConfig{},
 				existing,
 				c.leaseholder,
 				0,
@@ -1527,6 +1664,31 @@ func TestAllocatorComputeAction(t *testing.T) {
 		desc           roachpb.RangeDescriptor
 		expectedAction AllocatorAction
 	}{
+		// Has a learner left behind by an interrupted replica addition.
+		{
+			zone: config.ZoneConfig{
+				NumReplicas:   3,
+				Constraints:   config.Constraints{Constraints: []config.Constraint{{Value: "us-east"}}},
+				RangeMinBytes: 0,
+				RangeMaxBytes: 64000,
+			},
+			desc: roachpb.RangeDescriptor{
+				Replicas: []roachpb.ReplicaDescriptor{
+					{
+						StoreID:   1,
+						NodeID:    1,
+						ReplicaID: 1,
+					},
+					{
+						StoreID:   2,
+						NodeID:    2,
+						ReplicaID: 2,
+						Type:      roachpb.LEARNER.Enum(),
+					},
+				},
+			},
+			expectedAction: AllocatorRemoveLearner,
+		},
 		// Needs three replicas, have two
 		{
 			zone: config.ZoneConfig{
@@ -1901,7 +2063,11 @@ func TestAllocatorComputeAction(t *testing.T) {
 
 	lastPriority := float64(999999999)
 	for i, tcase := range testCases {
-		action, priority := a.ComputeAction(ctx, tcase.zone, RangeInfo{Desc: &tcase.desc})
+		// None of the learners in these test cases is being added.
+		action, priority := a.ComputeAction(ctx, tcase.zone, RangeInfo{
+			Desc:              &tcase.desc,
+			AbandonedLearners: tcase.desc.Learners(),
+		})
 		if tcase.expectedAction != action {
 			t.Errorf("Test case %d expected action %q, got action %q",
 				i, allocatorActionNames[tcase.expectedAction], allocatorActionNames[action])
@@ -1998,6 +2164,202 @@ func TestAllocatorComputeActionRemoveDead(t *testing.T) {
 	}
 }
 
+// TestAllocatorComputeActionPendingLearner verifies that a learner which the
+// leaseholder is in the process of adding is neither removed nor replaced.
+func TestAllocatorComputeActionPendingLearner(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	replicas := []roachpb.ReplicaDescriptor{
+		{StoreID: 1, NodeID: 1, ReplicaID: 1},
+		{StoreID: 2, NodeID: 2, ReplicaID: 2},
+		{StoreID: 3, NodeID: 3, ReplicaID: 3, Type: roachpb.LEARNER.Enum()},
+	}
+	desc := roachpb.RangeDescriptor{Replicas: replicas}
+
+	stopper, _, sp, a, _ := createTestAllocator( /* deterministic */ false)
+	ctx := context.Background()
+	defer stopper.Stop(ctx)
+	mockStorePool(sp, []roachpb.StoreID{1, 2, 3, 4}, nil, nil, nil)
+
+	zone := config.ZoneConfig{NumReplicas: 3}
+	if action, _ := a.ComputeAction(ctx, zone, RangeInfo{Desc: &desc}); action != AllocatorNoop {
+		t.Errorf("expected action %s for a pending learner, got %s", AllocatorNoop, action)
+	}
+	if action, _ := a.ComputeAction(ctx, zone, RangeInfo{
+		Desc:              &desc,
+		AbandonedLearners: replicas[2:],
+	}); action != AllocatorRemoveLearner {
+		t.Errorf("expected action %s for an abandoned learner, got %s", AllocatorRemoveLearner, action)
+	}
+}
+
+func TestAllocatorComputeActionRemoveInconsistent(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	replicas := []roachpb.ReplicaDescriptor{
+		{StoreID: 1, NodeID: 1, ReplicaID: 1},
+		{StoreID: 2, NodeID: 2, ReplicaID: 2},
+		{StoreID: 3, NodeID: 3, ReplicaID: 3},
+	}
+
+	testCases := []struct {
+		desc           roachpb.RangeDescriptor
+		inconsistent   []roachpb.ReplicaDescriptor
+		expectedAction AllocatorAction
+		live           []roachpb.StoreID
+		dead           []roachpb.StoreID
+	}{
+		// No replica is inconsistent.
+		{
+			desc:           roachpb.RangeDescriptor{Replicas: replicas},
+			expectedAction: AllocatorConsiderRebalance,
+			live:           []roachpb.StoreID{1, 2, 3, 4},
+		},
+		// One replica is inconsistent and is removed.
+		{
+			desc:           roachpb.RangeDescriptor{Replicas: replicas},
+			inconsistent:   replicas[2:],
+			expectedAction: AllocatorRemoveInconsistent,
+			live:           []roachpb.StoreID{1, 2, 3, 4},
+		},
+		// The inconsistent replica was already removed, so a replacement is
+		// added.
+		{
+			desc:           roachpb.RangeDescriptor{Replicas: replicas[:2]},
+			expectedAction: AllocatorAdd,
+			live:           []roachpb.StoreID{1, 2, 3, 4},
+		},
+		// One replica is inconsistent but another one is dead. The inconsistent
+		// replica is left alone until the dead one has been replaced so that
+		// the range doesn't lose its quorum.
+		{
+			desc:           roachpb.RangeDescriptor{Replicas: replicas},
+			inconsistent:   replicas[2:],
+			expectedAction: AllocatorRemoveDead,
+			live:           []roachpb.StoreID{2, 3, 4},
+			dead:           []roachpb.StoreID{1},
+		},
+	}
+
+	stopper, _, sp, a, _ := createTestAllocator( /* deterministic */ false)
+	ctx := context.Background()
+	defer stopper.Stop(ctx)
+
+	zone := config.ZoneConfig{NumReplicas: 3}
+	for i, tcase := range testCases {
+		mockStorePool(sp, tcase.live, tcase.dead, nil, nil)
+
+		action, _ := a.ComputeAction(ctx, zone, RangeInfo{
+			Desc:                 &tcase.desc,
+			InconsistentReplicas: tcase.inconsistent,
+		})
+		if tcase.expectedAction != action {
+			t.Errorf("Test case %d expected action %s, got action %s", i, tcase.expectedAction, action)
+		}
+	}
+}
+
+func TestAllocatorComputeActionNonVoters(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	threeVoters := []roachpb.ReplicaDescriptor{
+		{StoreID: 1, NodeID: 1, ReplicaID: 1},
+		{StoreID: 2, NodeID: 2, ReplicaID: 2},
+		{StoreID: 3, NodeID: 3, ReplicaID: 3},
+	}
+	nonVoter := func(id int) roachpb.ReplicaDescriptor {
+		return roachpb.ReplicaDescriptor{
+			StoreID:   roachpb.StoreID(id),
+			NodeID:    roachpb.NodeID(id),
+			ReplicaID: roachpb.ReplicaID(id),
+			Type:      roachpb.NON_VOTER.Enum(),
+		}
+	}
+	withNonVoters := func(ids ...int) []roachpb.ReplicaDescriptor {
+		reps := append([]roachpb.ReplicaDescriptor(nil), threeVoters...)
+		for _, id := range ids {
+			reps = append(reps, nonVoter(id))
+		}
+		return reps
+	}
+
+	testCases := []struct {
+		zone            config.ZoneConfig
+		replicas        []roachpb.ReplicaDescriptor
+		expectedAction  AllocatorAction
+		live            []roachpb.StoreID
+		dead            []roachpb.StoreID
+		decommissioning []roachpb.StoreID
+	}{
+		// Five replicas of which three are voters; no non-voters yet.
+		{
+			zone:           config.ZoneConfig{NumReplicas: 5, NumVoters: 3},
+			replicas:       withNonVoters(),
+			expectedAction: AllocatorAddNonVoter,
+			live:           []roachpb.StoreID{1, 2, 3, 4, 5},
+		},
+		// One of two non-voters is missing.
+		{
+			zone:           config.ZoneConfig{NumReplicas: 5, NumVoters: 3},
+			replicas:       withNonVoters(4),
+			expectedAction: AllocatorAddNonVoter,
+			live:           []roachpb.StoreID{1, 2, 3, 4, 5},
+		},
+		// Two voters and three non-voters. Non-voters aren't counted as
+		// voters, so the missing voter is added first.
+		{
+			zone:           config.ZoneConfig{NumReplicas: 5, NumVoters: 3},
+			replicas:       withNonVoters(4, 5, 6)[1:],
+			expectedAction: AllocatorAdd,
+			live:           []roachpb.StoreID{1, 2, 3, 4, 5, 6},
+		},
+		// All replicas are in place.
+		{
+			zone:           config.ZoneConfig{NumReplicas: 5, NumVoters: 3},
+			replicas:       withNonVoters(4, 5),
+			expectedAction: AllocatorConsiderRebalance,
+			live:           []roachpb.StoreID{1, 2, 3, 4, 5},
+		},
+		// The zone config no longer asks for non-voters.
+		{
+			zone:           config.ZoneConfig{NumReplicas: 3},
+			replicas:       withNonVoters(4),
+			expectedAction: AllocatorRemoveNonVoter,
+			live:           []roachpb.StoreID{1, 2, 3, 4},
+		},
+		// A non-voter is dead and needs to be replaced.
+		{
+			zone:           config.ZoneConfig{NumReplicas: 5, NumVoters: 3},
+			replicas:       withNonVoters(4, 5),
+			expectedAction: AllocatorRemoveNonVoter,
+			live:           []roachpb.StoreID{1, 2, 3, 4, 6},
+			dead:           []roachpb.StoreID{5},
+		},
+		// A non-voter is decommissioning and needs to be replaced.
+		{
+			zone:            config.ZoneConfig{NumReplicas: 5, NumVoters: 3},
+			replicas:        withNonVoters(4, 5),
+			expectedAction:  AllocatorRemoveNonVoter,
+			live:            []roachpb.StoreID{1, 2, 3, 4, 6},
+			decommissioning: []roachpb.StoreID{5},
+		},
+	}
+
+	stopper, _, sp, a, _ := createTestAllocator( /* deterministic */ false)
+	ctx := context.Background()
+	defer stopper.Stop(ctx)
+
+	for i, tcase := range testCases {
+		mockStorePool(sp, tcase.live, tcase.dead, tcase.decommissioning, nil)
+
+		desc := roachpb.RangeDescriptor{Replicas: tcase.replicas}
+		action, _ := a.ComputeAction(ctx, tcase.zone, RangeInfo{Desc: &desc})
+		if tcase.expectedAction != action {
+			t.Errorf("Test case %d expected action %s, got action %s", i, tcase.expectedAction, action)
+		}
+	}
+}
+
 func TestAllocatorComputeActionDecommission(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -0,0 +1,123 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/storage"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/testcluster"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+// TestAddReplicaViaLearner verifies that a replica is added to a range as a
+// learner which is visible in crdb_internal.ranges, isn't removed by the
+// replicate queue while the addition is in progress and is promoted to a
+// voter afterwards.
+func TestAddReplicaViaLearner(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// Block the first promotion, which is the one of the learner added below.
+	// Others are let through because the replicate queue adds replicas to
+	// other ranges once it's enabled.
+	var blocked int32
+	blockedC := make(chan roachpb.ReplicaDescriptor)
+	unblockC := make(chan struct{})
+	knobs := &storage.StoreTestingKnobs{
+		BeforeLearnerPromotion: func(learner roachpb.ReplicaDescriptor) {
+			if atomic.CompareAndSwapInt32(&blocked, 0, 1) {
+				blockedC <- learner
+				<-unblockC
+			}
+		},
+	}
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+		ServerArgs: base.TestServerArgs{
+			Knobs: base.TestingKnobs{Store: knobs},
+		},
+	})
+	ctx := context.Background()
+	defer tc.Stopper().Stop(ctx)
+
+	key := roachpb.Key("a")
+	desc, err := tc.LookupRange(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errC := make(chan error)
+	go func() {
+		_, err := tc.AddReplicas(desc.StartKey.AsRawKey(), tc.Target(1))
+		errC <- err
+	}()
+
+	var learner roachpb.ReplicaDescriptor
+	select {
+	case learner = <-blockedC:
+	case err := <-errC:
+		close(unblockC)
+		t.Fatalf("replica added without a learner: %v", err)
+	}
+	if learner.StoreID != tc.Target(1).StoreID {
+		t.Fatalf("expected a learner on store %d, got %+v", tc.Target(1).StoreID, learner)
+	}
+
+	store, err := tc.Servers[0].Stores().GetStore(tc.Servers[0].GetFirstStoreID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repl := store.LookupReplica(desc.StartKey, nil)
+	if learners := repl.Desc().Learners(); len(learners) != 1 || learners[0].ReplicaID != learner.ReplicaID {
+		t.Fatalf("expected learner %+v in the descriptor, got %+v", learner, repl.Desc())
+	}
+
+	sqlDB := sqlutils.MakeSQLRunner(t, tc.Conns[0])
+	var learners string
+	sqlDB.QueryRow(
+		`SELECT learners FROM crdb_internal.ranges WHERE range_id = $1`, desc.RangeID,
+	).Scan(&learners)
+	if expected := fmt.Sprintf("{%d}", learner.StoreID); learners != expected {
+		t.Errorf("expected learners %s in crdb_internal.ranges, got %s", expected, learners)
+	}
+
+	// The learner belongs to an addition in progress, so the replicate queue
+	// leaves it alone.
+	store.SetReplicateQueueActive(true)
+	store.ForceReplicationScanAndProcess()
+	store.SetReplicateQueueActive(false)
+	if learners := repl.Desc().Learners(); len(learners) != 1 {
+		t.Fatalf("expected the learner to remain, got %+v", repl.Desc())
+	}
+
+	close(unblockC)
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+	desc, err = tc.LookupRange(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(desc.Learners()) != 0 || len(desc.Voters()) != 2 {
+		t.Fatalf("expected two voters and no learners, got %+v", desc)
+	}
+}
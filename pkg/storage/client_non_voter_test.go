@@ -0,0 +1,103 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/testcluster"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+// TestNonVoterReadOnIdleRange verifies that the non-voter of a range which
+// isn't written to anymore serves reads at recent timestamps, which requires
+// the leaseholder to close timestamps without writes.
+func TestNonVoterReadOnIdleRange(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	ctx := context.Background()
+	defer tc.Stopper().Stop(ctx)
+
+	sqlDB := sqlutils.MakeSQLRunner(t, tc.Conns[0])
+	sqlDB.Exec(`SET CLUSTER SETTING kv.non_voter_reads.target_duration = '100ms'`)
+
+	key := roachpb.Key("a")
+	db := tc.Servers[0].DB()
+	if err := db.AdminSplit(ctx, key, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put(ctx, key, "value"); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := tc.LookupRange(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := tc.Servers[0].Stores().GetStore(tc.Servers[0].GetFirstStoreID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.LookupReplica(desc.StartKey, nil).AddNonVoterReplica(
+		ctx, tc.Target(1),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing writes to the range from here on.
+	readTS := tc.Servers[0].Clock().Now()
+	nonVoterStore, err := tc.Servers[1].Stores().GetStore(tc.Servers[1].GetFirstStoreID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutils.SucceedsSoon(t, func() error {
+		// Don't send the read before the non-voter has closed its timestamp,
+		// as it would be redirected to the leaseholder.
+		nonVoter, err := nonVoterStore.GetReplica(desc.RangeID)
+		if err != nil {
+			return err
+		}
+		if closedTS := nonVoter.ClosedTimestamp(); closedTS.Less(readTS) {
+			return errors.Errorf("closed timestamp %s is below %s", closedTS, readTS)
+		}
+		return nil
+	})
+
+	var ba roachpb.BatchRequest
+	ba.RangeID = desc.RangeID
+	ba.Timestamp = readTS
+	ba.Add(roachpb.NewGet(key))
+	br, pErr := nonVoterStore.Send(ctx, ba)
+	if pErr != nil {
+		t.Fatal(pErr)
+	}
+	val, err := br.Responses[0].GetInner().(*roachpb.GetResponse).Value.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, []byte("value")) {
+		t.Fatalf("expected to read %q from the non-voter, got %q", "value", val)
+	}
+}
@@ -3284,6 +3284,82 @@ func TestCheckInconsistent(t *testing.T) {
 	}
 }
 
+// TestCheckInconsistentRepair verifies that when consistency repair is
+// enabled, a replica which disagrees with the majority of its range is marked
+// for replacement instead of crashing the leaseholder.
+func TestCheckInconsistentRepair(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	sc := storage.TestStoreConfig(nil)
+	sc.TestingKnobs.DisableReplicateQueue = true
+	sc.Settings.ConsistencyRepairEnabled.Override(true)
+	sc.TestingKnobs.BadChecksumPanic = func(s roachpb.StoreIdent) {
+		t.Errorf("BadChecksumPanic called on store %s despite repair being enabled", s)
+	}
+	mtc := &multiTestContext{storeConfig: &sc}
+
+	const numStores = 3
+	defer mtc.Stop()
+	mtc.Start(t, numStores)
+	// Setup replication of range 1 on store 0 to stores 1 and 2.
+	mtc.replicateRange(1, 1, 2)
+
+	pArgs := putArgs([]byte("a"), []byte("b"))
+	if _, err := client.SendWrapped(context.Background(), rg1(mtc.stores[0]), pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write some arbitrary data only to store 1, leaving stores 0 and 2 as the
+	// consistent majority.
+	var val roachpb.Value
+	val.SetInt(42)
+	if err := engine.MVCCPut(
+		context.Background(), mtc.stores[1].Engine(), nil, []byte("e"), mtc.stores[1].Clock().Now(), val, nil,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	checkArgs := roachpb.CheckConsistencyRequest{
+		Span: roachpb.Span{
+			Key:    []byte("a"),
+			EndKey: []byte("z"),
+		},
+	}
+	if _, err := client.SendWrapped(context.Background(), rg1(mtc.stores[0]), &checkArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	repl := mtc.stores[0].LookupReplica(roachpb.RKey("a"), nil)
+	if repl == nil {
+		t.Fatal("no replica found for key 'a'")
+	}
+	expected, ok := repl.Desc().GetReplicaDescriptor(mtc.stores[1].StoreID())
+	if !ok {
+		t.Fatalf("no replica of %s on store %d", repl, mtc.stores[1].StoreID())
+	}
+	// The marks are replicated, so they're seen by all the consistent
+	// replicas and outlive a restart of the leaseholder.
+	checkMarked := func() {
+		testutils.SucceedsSoon(t, func() error {
+			for _, i := range []int{0, 2} {
+				repl := mtc.stores[i].LookupReplica(roachpb.RKey("a"), nil)
+				inconsistent, err := repl.InconsistentReplicas()
+				if err != nil {
+					return err
+				}
+				if !reflect.DeepEqual([]roachpb.ReplicaDescriptor{expected}, inconsistent) {
+					return errors.Errorf("store %d: expected inconsistent replicas %v, got %v",
+						mtc.stores[i].StoreID(), expected, inconsistent)
+				}
+			}
+			return nil
+		})
+	}
+	checkMarked()
+	mtc.restartStore(0)
+	checkMarked()
+}
+
 func TestTransferRaftLeadership(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -0,0 +1,163 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/storage"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+)
+
+type testRangeFeedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *roachpb.RangeFeedEvent
+}
+
+func (s *testRangeFeedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testRangeFeedStream) Send(event *roachpb.RangeFeedEvent) error {
+	select {
+	case s.events <- event:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// TestStoreRangeFeed verifies that a range feed delivers committed values
+// within its span, written both before and after the feed was registered,
+// followed by a checkpoint resolving them.
+func TestStoreRangeFeed(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	cfg := storage.TestStoreConfig(hlc.NewClock(hlc.UnixNano, time.Nanosecond))
+	defer settings.TestingSetDuration(&cfg.Settings.RangeFeedCheckpointInterval, 10*time.Millisecond)()
+	defer settings.TestingSetDuration(&cfg.Settings.RangeFeedClosedTSLag, 10*time.Millisecond)()
+	store := createTestStoreWithConfig(t, stopper, cfg)
+
+	ctx := context.TODO()
+	startTS := store.Clock().Now()
+	// Written before the feed is registered; delivered by the catch-up scan.
+	if err := store.DB().Put(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	feedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream := &testRangeFeedStream{ctx: feedCtx, events: make(chan *roachpb.RangeFeedEvent, 100)}
+	repl := store.LookupReplica(roachpb.RKey("a"), nil)
+	args := &roachpb.RangeFeedRequest{Span: roachpb.Span{Key: roachpb.Key("a"), EndKey: roachpb.Key("m")}}
+	args.Timestamp = startTS
+	args.RangeID = repl.RangeID
+	errC := make(chan *roachpb.Error, 1)
+	go func() {
+		errC <- store.RangeFeed(feedCtx, args, stream)
+	}()
+
+	// Wait for the first checkpoint, which guarantees the feed is registered.
+	var lastTS hlc.Timestamp
+	for {
+		event := <-stream.events
+		if c := event.Checkpoint; c != nil {
+			lastTS = c.ResolvedTS
+			break
+		}
+	}
+
+	if err := store.DB().Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
+		b := txn.NewBatch()
+		b.Put("b", "2")
+		b.Put("c", "3")
+		return txn.CommitInBatch(ctx, b)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DB().Put(ctx, "d", "4"); err != nil {
+		t.Fatal(err)
+	}
+	// Outside of the feed's span.
+	if err := store.DB().Put(ctx, "x", "5"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}
+	seen := make(map[string]string)
+	var maxValueTS hlc.Timestamp
+	handle := func(event *roachpb.RangeFeedEvent) {
+		if v := event.Val; v != nil {
+			b, err := v.Value.GetBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !startTS.Less(v.Value.Timestamp) {
+				t.Errorf("value %s at %s not above start timestamp %s", v.Key, v.Value.Timestamp, startTS)
+			}
+			seen[string(v.Key)] = string(b)
+			maxValueTS.Forward(v.Value.Timestamp)
+		}
+		if c := event.Checkpoint; c != nil {
+			if c.ResolvedTS.Less(lastTS) {
+				t.Errorf("resolved timestamp regressed from %s to %s", lastTS, c.ResolvedTS)
+			}
+			lastTS = c.ResolvedTS
+		}
+		if e := event.Error; e != nil {
+			t.Fatal(e.Error.GoError())
+		}
+	}
+	testutils.SucceedsSoon(t, func() error {
+		for done := false; !done; {
+			select {
+			case event := <-stream.events:
+				handle(event)
+			default:
+				done = true
+			}
+		}
+		if len(seen) < len(expected) || lastTS.Less(maxValueTS) {
+			return errors.Errorf("saw values %v resolved at %s", seen, lastTS)
+		}
+		return nil
+	})
+	for k, v := range expected {
+		if seen[k] != v {
+			t.Errorf("expected %s=%s, got %q", k, v, seen[k])
+		}
+	}
+	if len(seen) != len(expected) {
+		t.Errorf("expected values %v, got %v", expected, seen)
+	}
+
+	cancel()
+	if pErr := <-errC; !testutils.IsPError(pErr, "context canceled") {
+		t.Fatalf("unexpected error: %v", pErr)
+	}
+}
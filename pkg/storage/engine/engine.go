@@ -197,6 +197,9 @@ type Engine interface {
 	//
 	// Not thread safe.
 	GetAuxiliaryDir() string
+	// RaftLog returns the separate log holding raft log entries, or nil if
+	// they are stored in the engine.
+	RaftLog() RaftLog
 	// NewBatch returns a new instance of a batched engine which wraps
 	// this engine. Batched engines accumulate all mutations and apply
 	// them atomically on a call to Commit().
@@ -224,6 +227,11 @@ type Engine interface {
 	// IngestExternalFile links a file into the RocksDB log-structured
 	// merge-tree.
 	IngestExternalFile(ctx context.Context, path string, move bool) error
+	// ReadFile reads the content of a file in the engine's env.
+	ReadFile(filename string) ([]byte, error)
+	// WriteFile writes data to a file in the engine's env. Files written this
+	// way are encrypted if the engine is.
+	WriteFile(filename string, data []byte) error
 }
 
 // Batch is the interface for batch specific operations.
@@ -270,6 +278,13 @@ type Stats struct {
 	Flushes                  int64
 	Compactions              int64
 	TableReadersMemEstimate  int64
+	// L0FileCount is the number of sstables in level 0. RocksDB slows down and
+	// eventually stops writes when compactions can't keep this number low.
+	L0FileCount int64
+	// PendingCompactionBytesEstimate is RocksDB's estimate of the number of
+	// bytes compactions need to rewrite to bring all levels down to their
+	// target sizes.
+	PendingCompactionBytesEstimate int64
 }
 
 // PutProto sets the given key to the protobuf-serialized byte string
@@ -1893,7 +1893,7 @@ func mvccResolveWriteIntent(
 	// used for resolving), but that costs latency.
 	// TODO(tschottdorf): various epoch-related scenarios here deserve more
 	// testing.
-	pushed := intent.Status == roachpb.PENDING &&
+	pushed := !intent.Status.IsFinalized() &&
 		meta.Timestamp.Less(intent.Txn.Timestamp) &&
 		meta.Txn.Epoch >= intent.Txn.Epoch
 
@@ -1949,8 +1949,8 @@ func mvccResolveWriteIntent(
 
 	// This method shouldn't be called in this instance, but there's
 	// nothing to do if meta's epoch is greater than or equal txn's
-	// epoch and the state is still PENDING.
-	if intent.Status == roachpb.PENDING && meta.Txn.Epoch >= intent.Txn.Epoch {
+	// epoch and the state is still PENDING or STAGING.
+	if !intent.Status.IsFinalized() && meta.Txn.Epoch >= intent.Txn.Epoch {
 		return nil
 	}
 
@@ -0,0 +1,906 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// RaftLog stores the raft log entries of a store's replicas outside of the
+// engine, in a segmented append-only log. Entries are opaque to the log; they
+// are the encoded raftpb.Entry protos which would otherwise be stored as the
+// values of the range's raft log keys.
+//
+// Since the log is not part of the engine, writes to it are not atomic with
+// engine batches. Callers write entries to the log before committing the
+// batch which makes them reachable (i.e. the one updating the last index), and
+// they discard entries only after committing the batch which makes them
+// unreachable. A crash in between leaves unreachable entries behind, which
+// the store discards when it starts.
+//
+// All writes to the log are durable when the method returns.
+type RaftLog interface {
+	// Append durably writes the entries of the range, the first of which has
+	// index firstIndex. Entries of the range at firstIndex and above are
+	// replaced. If there is a gap between the range's last entry and
+	// firstIndex, the range's earlier entries are discarded. Returns the
+	// change in the total size of the range's entries.
+	Append(rangeID roachpb.RangeID, firstIndex uint64, entries [][]byte) (int64, error)
+	// Iterate calls fn with the index and the encoded entry of each of the
+	// range's entries in [lo, hi), in increasing index order, until fn returns
+	// true or an error.
+	Iterate(rangeID roachpb.RangeID, lo, hi uint64, fn func(index uint64, entry []byte) (bool, error)) error
+	// Size returns the total size of the range's entries below hi.
+	Size(rangeID roachpb.RangeID, hi uint64) int64
+	// TruncatePrefix discards the range's entries below index. Returns the
+	// change in the total size of the range's entries.
+	TruncatePrefix(rangeID roachpb.RangeID, index uint64) (int64, error)
+	// Destroy discards all of the range's entries.
+	Destroy(rangeID roachpb.RangeID) error
+	// RangeIDs returns the IDs of the ranges which have entries in the log, in
+	// increasing order.
+	RangeIDs() []roachpb.RangeID
+	// Close closes the log.
+	Close()
+}
+
+const (
+	raftLogSubdir        = "raftlog"
+	raftLogSegmentSuffix = ".log"
+	// raftLogSegmentSize is the size above which a new segment is started. A
+	// segment is removed once none of its entries are live anymore, so smaller
+	// segments reclaim space sooner at the cost of more files.
+	raftLogSegmentSize = 32 << 20
+	// raftLogMinLiveFraction is the fraction of the log's size below which
+	// the live entries at its front are rewritten when a new segment is
+	// started (see maybeCompactLocked).
+	raftLogMinLiveFraction = 0.5
+	// raftLogHeaderSize is the size of a record header: the length of the
+	// record's payload and its CRC-32C checksum.
+	raftLogHeaderSize = 8
+)
+
+// Record types. Each record's payload is its type, followed by the uvarint
+// encoded range ID and index and, for entry records, the encoded entry.
+const (
+	raftLogRecordEntry    byte = 1
+	raftLogRecordTruncate byte = 2
+	raftLogRecordDestroy  byte = 3
+)
+
+var raftLogCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// raftLogDir returns the directory holding the separate raft log of the store
+// in dir.
+func raftLogDir(dir string) string {
+	return filepath.Join(dir, raftLogSubdir)
+}
+
+// raftLogExists returns whether the store in dir has a separate raft log.
+func raftLogExists(dir string) (bool, error) {
+	if _, err := os.Stat(raftLogDir(dir)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type raftLogSegment struct {
+	id   uint64
+	file *os.File
+	size int64
+	// live is the number of entries in the segment which have been neither
+	// replaced nor discarded, and liveBytes is the size of their records.
+	live      int
+	liveBytes int64
+}
+
+type raftLogEntryLoc struct {
+	seg    *raftLogSegment
+	offset int64
+	length int
+}
+
+type raftLogRange struct {
+	rangeID roachpb.RangeID
+	// firstIndex is the index of locs[0].
+	firstIndex uint64
+	locs       []raftLogEntryLoc
+	size       int64
+}
+
+// raftLogRecordSize returns the size of the record of an entry of the given
+// length.
+func raftLogRecordSize(rangeID roachpb.RangeID, index uint64, length int) int64 {
+	var scratch [binary.MaxVarintLen64]byte
+	n := raftLogHeaderSize + 1 + length
+	n += binary.PutUvarint(scratch[:], uint64(rangeID))
+	n += binary.PutUvarint(scratch[:], index)
+	return int64(n)
+}
+
+// add appends the entry at the next index to the range's entries.
+func (rl *raftLogRange) add(loc raftLogEntryLoc) {
+	index := rl.firstIndex + uint64(len(rl.locs))
+	rl.locs = append(rl.locs, loc)
+	rl.size += int64(loc.length)
+	loc.seg.live++
+	loc.seg.liveBytes += raftLogRecordSize(rl.rangeID, index, loc.length)
+}
+
+// release discards the range's entries in locs[i:j].
+func (rl *raftLogRange) release(i, j int) {
+	for k, loc := range rl.locs[i:j] {
+		loc.seg.live--
+		loc.seg.liveBytes -= raftLogRecordSize(rl.rangeID, rl.firstIndex+uint64(i+k), loc.length)
+		rl.size -= int64(loc.length)
+	}
+}
+
+// segmentedRaftLog is the RaftLog implementation. Records are appended to the
+// last of a sequence of segment files, and an in-memory index maps each
+// range's entries to their location. The index is rebuilt by replaying the
+// segments when the log is opened. Segments are removed from the front of the
+// sequence once none of their entries are live, which guarantees that a
+// record replacing or discarding an entry is never replayed without the
+// entry. The live entries of ranges which don't discard them (e.g. because
+// they're idle) would hold on to all of the segments following theirs, so
+// they are rewritten at the end of the log once most of it is dead.
+//
+// Records are written while holding mu, but synced after releasing it. Syncs
+// are shared: a writer waiting for its records to become durable finds them
+// synced already if another writer's sync started after they were written.
+type segmentedRaftLog struct {
+	dir string
+	// segmentSize is raftLogSegmentSize, except in tests.
+	segmentSize int64
+
+	// syncMu serializes syncs and the removal of segments. It is acquired
+	// before mu.
+	syncMu struct {
+		syncutil.Mutex
+		// synced is the sequence number of the last durable write.
+		synced uint64
+	}
+
+	mu struct {
+		syncutil.RWMutex
+		// segments is ordered by ID; the last segment is the one being
+		// appended to.
+		segments []*raftLogSegment
+		ranges   map[roachpb.RangeID]*raftLogRange
+		buf      []byte
+		// written is the sequence number of the last write.
+		written uint64
+	}
+}
+
+var _ RaftLog = &segmentedRaftLog{}
+
+// openRaftLog opens (creating it if necessary) the separate raft log in the
+// given directory.
+func openRaftLog(dir string) (*segmentedRaftLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	l := &segmentedRaftLog{dir: dir, segmentSize: raftLogSegmentSize}
+	l.mu.ranges = make(map[roachpb.RangeID]*raftLogRange)
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasSuffix(name, raftLogSegmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, raftLogSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for i, id := range ids {
+		if err := l.replaySegment(id, i == len(ids)-1); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	if len(l.mu.segments) == 0 {
+		if err := l.newSegmentLocked(1); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	// All the replayed records are durable.
+	if err := l.removeSegmentsLocked(l.deadSegmentsLocked()); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *segmentedRaftLog) segmentPath(id uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%06d%s", id, raftLogSegmentSuffix))
+}
+
+// replaySegment reads the records of the given segment into the in-memory
+// index. A torn record at the end of the last segment is the result of a
+// crash during an append which was never acknowledged, so it is removed; any
+// other corruption is an error.
+func (l *segmentedRaftLog) replaySegment(id uint64, last bool) error {
+	path := l.segmentPath(id)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	seg := &raftLogSegment{id: id, file: f}
+	l.mu.segments = append(l.mu.segments, seg)
+
+	var offset int64
+	for offset < int64(len(data)) {
+		rec := data[offset:]
+		var payload []byte
+		if len(rec) >= raftLogHeaderSize {
+			n := int64(binary.LittleEndian.Uint32(rec[0:4]))
+			if n > 0 && n <= int64(len(rec)-raftLogHeaderSize) {
+				payload = rec[raftLogHeaderSize : raftLogHeaderSize+n]
+				if crc32.Checksum(payload, raftLogCRCTable) != binary.LittleEndian.Uint32(rec[4:8]) {
+					payload = nil
+				}
+			}
+		}
+		if payload == nil {
+			if !last {
+				return errors.Errorf("corrupt raft log record in %s at offset %d", path, offset)
+			}
+			if err := f.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		if err := l.applyRecordLocked(seg, offset+raftLogHeaderSize, payload); err != nil {
+			return errors.Wrapf(err, "raft log record in %s at offset %d", path, offset)
+		}
+		offset += raftLogHeaderSize + int64(len(payload))
+	}
+	seg.size = offset
+	if _, err := f.Seek(offset, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// applyRecordLocked applies the record with the given payload, which starts
+// at offset in seg, to the in-memory index.
+func (l *segmentedRaftLog) applyRecordLocked(
+	seg *raftLogSegment, offset int64, payload []byte,
+) error {
+	typ := payload[0]
+	b := payload[1:]
+	rangeID, n := binary.Uvarint(b)
+	if n <= 0 {
+		return errors.New("malformed range ID")
+	}
+	b = b[n:]
+	index, n := binary.Uvarint(b)
+	if n <= 0 {
+		return errors.New("malformed index")
+	}
+	b = b[n:]
+
+	switch typ {
+	case raftLogRecordEntry:
+		l.addEntryLocked(roachpb.RangeID(rangeID), index, raftLogEntryLoc{
+			seg:    seg,
+			offset: offset + int64(len(payload)-len(b)),
+			length: len(b),
+		})
+	case raftLogRecordTruncate:
+		l.truncateLocked(roachpb.RangeID(rangeID), index)
+	case raftLogRecordDestroy:
+		l.destroyLocked(roachpb.RangeID(rangeID))
+	default:
+		return errors.Errorf("unknown record type %d", typ)
+	}
+	return nil
+}
+
+// addEntryLocked adds the entry at the given index to the range's entries,
+// replacing any entries at or above index. Returns the change in the range's
+// size.
+func (l *segmentedRaftLog) addEntryLocked(
+	rangeID roachpb.RangeID, index uint64, loc raftLogEntryLoc,
+) int64 {
+	rl, ok := l.mu.ranges[rangeID]
+	if !ok {
+		rl = &raftLogRange{rangeID: rangeID, firstIndex: index}
+		l.mu.ranges[rangeID] = rl
+	}
+	prevSize := rl.size
+	i := 0
+	if index >= rl.firstIndex && index <= rl.firstIndex+uint64(len(rl.locs)) {
+		i = int(index - rl.firstIndex)
+	}
+	rl.release(i, len(rl.locs))
+	rl.locs = rl.locs[:i]
+	if len(rl.locs) == 0 {
+		rl.firstIndex = index
+	}
+	rl.add(loc)
+	return rl.size - prevSize
+}
+
+// truncateLocked discards the range's entries below index. Returns the change
+// in the range's size.
+func (l *segmentedRaftLog) truncateLocked(rangeID roachpb.RangeID, index uint64) int64 {
+	rl, ok := l.mu.ranges[rangeID]
+	if !ok || index <= rl.firstIndex {
+		return 0
+	}
+	prevSize := rl.size
+	n := index - rl.firstIndex
+	if n > uint64(len(rl.locs)) {
+		n = uint64(len(rl.locs))
+	}
+	rl.release(0, int(n))
+	rl.locs = append(rl.locs[:0], rl.locs[n:]...)
+	rl.firstIndex += n
+	if len(rl.locs) == 0 {
+		delete(l.mu.ranges, rangeID)
+	}
+	return rl.size - prevSize
+}
+
+func (l *segmentedRaftLog) destroyLocked(rangeID roachpb.RangeID) {
+	if rl, ok := l.mu.ranges[rangeID]; ok {
+		rl.release(0, len(rl.locs))
+		delete(l.mu.ranges, rangeID)
+	}
+}
+
+func (l *segmentedRaftLog) newSegmentLocked(id uint64) error {
+	f, err := os.OpenFile(l.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	l.mu.segments = append(l.mu.segments, &raftLogSegment{id: id, file: f})
+	return nil
+}
+
+// activeSegmentLocked returns the segment to append to, starting a new one if
+// the current one is full.
+func (l *segmentedRaftLog) activeSegmentLocked() (*raftLogSegment, error) {
+	seg := l.mu.segments[len(l.mu.segments)-1]
+	if seg.size < l.segmentSize {
+		return seg, nil
+	}
+	// No record in the new segment may become durable before those in the
+	// full one, which would look corrupt when replayed.
+	if err := seg.file.Sync(); err != nil {
+		return nil, err
+	}
+	if err := l.newSegmentLocked(seg.id + 1); err != nil {
+		return nil, err
+	}
+	if err := l.maybeCompactLocked(); err != nil {
+		return nil, err
+	}
+	return l.mu.segments[len(l.mu.segments)-1], nil
+}
+
+// maybeCompactLocked rewrites the live entries at the front of the log into
+// the active segment while less than raftLogMinLiveFraction of the log is
+// live, which allows the segments they were in, and any dead ones following
+// them, to be removed. All of the entries of a range are rewritten together,
+// since replaying an entry discards the range's entries above it.
+func (l *segmentedRaftLog) maybeCompactLocked() error {
+	for {
+		// Dead segments at the front are removed by the next sync.
+		first := l.deadSegmentsLocked()
+		if first == len(l.mu.segments)-1 {
+			return nil
+		}
+		var size, liveBytes int64
+		for _, seg := range l.mu.segments[first:] {
+			size += seg.size
+			liveBytes += seg.liveBytes
+		}
+		if float64(liveBytes) >= raftLogMinLiveFraction*float64(size) {
+			return nil
+		}
+		front := l.mu.segments[first]
+		var rangeIDs []roachpb.RangeID
+		for rangeID, rl := range l.mu.ranges {
+			for _, loc := range rl.locs {
+				if loc.seg == front {
+					rangeIDs = append(rangeIDs, rangeID)
+					break
+				}
+			}
+		}
+		sort.Slice(rangeIDs, func(i, j int) bool { return rangeIDs[i] < rangeIDs[j] })
+		for _, rangeID := range rangeIDs {
+			if err := l.rewriteRangeLocked(l.mu.ranges[rangeID]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// rewriteRangeLocked appends copies of the range's entries to the active
+// segment, which replace the originals.
+func (l *segmentedRaftLog) rewriteRangeLocked(rl *raftLogRange) error {
+	seg := l.mu.segments[len(l.mu.segments)-1]
+	buf := l.mu.buf[:0]
+	locs := make([]raftLogEntryLoc, len(rl.locs))
+	for i, loc := range rl.locs {
+		ent := make([]byte, loc.length)
+		if _, err := loc.seg.file.ReadAt(ent, loc.offset); err != nil {
+			return errors.Wrapf(err, "reading raft log entry %d of r%d",
+				rl.firstIndex+uint64(i), rl.rangeID)
+		}
+		var dataOffset int
+		recStart := len(buf)
+		buf, dataOffset = appendRaftLogRecord(buf, raftLogRecordEntry, rl.rangeID, rl.firstIndex+uint64(i), ent)
+		locs[i] = raftLogEntryLoc{seg: seg, offset: seg.size + int64(recStart+dataOffset), length: len(ent)}
+	}
+	l.mu.buf = buf
+	if _, err := l.writeLocked(seg, buf); err != nil {
+		return err
+	}
+	rl.release(0, len(rl.locs))
+	rl.locs = rl.locs[:0]
+	for _, loc := range locs {
+		rl.add(loc)
+	}
+	return nil
+}
+
+// deadSegmentsLocked returns the number of segments at the front of the
+// sequence, other than the active one, which don't have any live entries.
+func (l *segmentedRaftLog) deadSegmentsLocked() int {
+	n := 0
+	for n < len(l.mu.segments)-1 && l.mu.segments[n].live == 0 {
+		n++
+	}
+	return n
+}
+
+// removeSegmentsLocked removes the first n segments, which must be dead. The
+// records which discarded their entries must be durable.
+func (l *segmentedRaftLog) removeSegmentsLocked(n int) error {
+	if n == 0 {
+		return nil
+	}
+	for _, seg := range l.mu.segments[:n] {
+		if err := seg.file.Close(); err != nil {
+			return err
+		}
+		if err := os.Remove(l.segmentPath(seg.id)); err != nil {
+			return err
+		}
+	}
+	l.mu.segments = append(l.mu.segments[:0], l.mu.segments[n:]...)
+	return nil
+}
+
+// appendRaftLogRecord appends a record with the given header fields and data to buf.
+// Returns the extended buffer and the offset of data within the record.
+func appendRaftLogRecord(
+	buf []byte, typ byte, rangeID roachpb.RangeID, index uint64, data []byte,
+) ([]byte, int) {
+	start := len(buf)
+	var scratch [2*binary.MaxVarintLen64 + 1]byte
+	scratch[0] = typ
+	n := 1
+	n += binary.PutUvarint(scratch[n:], uint64(rangeID))
+	n += binary.PutUvarint(scratch[n:], index)
+
+	var header [raftLogHeaderSize]byte
+	buf = append(buf, header[:]...)
+	buf = append(buf, scratch[:n]...)
+	buf = append(buf, data...)
+	payload := buf[start+raftLogHeaderSize:]
+	binary.LittleEndian.PutUint32(buf[start:start+4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[start+4:start+8], crc32.Checksum(payload, raftLogCRCTable))
+	return buf, raftLogHeaderSize + n
+}
+
+// writeLocked appends the records in buf to the active segment. Returns the
+// sequence number of the write, which is durable once sync returns for it.
+func (l *segmentedRaftLog) writeLocked(seg *raftLogSegment, buf []byte) (uint64, error) {
+	if _, err := seg.file.Write(buf); err != nil {
+		return 0, err
+	}
+	seg.size += int64(len(buf))
+	l.mu.written++
+	return l.mu.written, nil
+}
+
+// sync makes the write with the given sequence number durable, along with
+// all of the writes preceding it. A single sync of the active segment covers
+// every write made before it started, including those of other ranges.
+// Segments which are dead by then are removed, since the records discarding
+// their entries are durable.
+func (l *segmentedRaftLog) sync(seq uint64) error {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	if seq <= l.syncMu.synced {
+		return nil
+	}
+	// Earlier segments were synced when they were filled (see
+	// activeSegmentLocked).
+	l.mu.RLock()
+	seg := l.mu.segments[len(l.mu.segments)-1]
+	written := l.mu.written
+	dead := l.deadSegmentsLocked()
+	l.mu.RUnlock()
+	if err := seg.file.Sync(); err != nil {
+		return err
+	}
+	l.syncMu.synced = written
+	if dead == 0 {
+		return nil
+	}
+	// Segments are only removed while holding syncMu, so the dead ones are
+	// still at the front.
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.removeSegmentsLocked(dead)
+}
+
+// Append implements the RaftLog interface.
+func (l *segmentedRaftLog) Append(
+	rangeID roachpb.RangeID, firstIndex uint64, entries [][]byte,
+) (int64, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	delta, seq, err := l.append(rangeID, firstIndex, entries)
+	if err != nil {
+		return 0, err
+	}
+	return delta, l.sync(seq)
+}
+
+func (l *segmentedRaftLog) append(
+	rangeID roachpb.RangeID, firstIndex uint64, entries [][]byte,
+) (int64, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seg, err := l.activeSegmentLocked()
+	if err != nil {
+		return 0, 0, err
+	}
+	buf := l.mu.buf[:0]
+	offsets := make([]int64, len(entries))
+	for i, ent := range entries {
+		var dataOffset int
+		recStart := len(buf)
+		buf, dataOffset = appendRaftLogRecord(buf, raftLogRecordEntry, rangeID, firstIndex+uint64(i), ent)
+		offsets[i] = seg.size + int64(recStart+dataOffset)
+	}
+	l.mu.buf = buf
+	seq, err := l.writeLocked(seg, buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	var delta int64
+	for i, ent := range entries {
+		delta += l.addEntryLocked(rangeID, firstIndex+uint64(i), raftLogEntryLoc{
+			seg:    seg,
+			offset: offsets[i],
+			length: len(ent),
+		})
+	}
+	return delta, seq, nil
+}
+
+// Iterate implements the RaftLog interface.
+func (l *segmentedRaftLog) Iterate(
+	rangeID roachpb.RangeID, lo, hi uint64, fn func(index uint64, entry []byte) (bool, error),
+) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	rl, ok := l.mu.ranges[rangeID]
+	if !ok {
+		return nil
+	}
+	if lo < rl.firstIndex {
+		lo = rl.firstIndex
+	}
+	if end := rl.firstIndex + uint64(len(rl.locs)); hi > end {
+		hi = end
+	}
+	for i := lo; i < hi; i++ {
+		loc := rl.locs[i-rl.firstIndex]
+		ent := make([]byte, loc.length)
+		if _, err := loc.seg.file.ReadAt(ent, loc.offset); err != nil {
+			return errors.Wrapf(err, "reading raft log entry %d of r%d", i, rangeID)
+		}
+		if done, err := fn(i, ent); done || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size implements the RaftLog interface.
+func (l *segmentedRaftLog) Size(rangeID roachpb.RangeID, hi uint64) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	rl, ok := l.mu.ranges[rangeID]
+	if !ok || hi <= rl.firstIndex {
+		return 0
+	}
+	if hi >= rl.firstIndex+uint64(len(rl.locs)) {
+		return rl.size
+	}
+	var size int64
+	for _, loc := range rl.locs[:hi-rl.firstIndex] {
+		size += int64(loc.length)
+	}
+	return size
+}
+
+// writeRecord durably writes a record discarding entries of the range and
+// applies it. Returns the change in the range's size.
+func (l *segmentedRaftLog) writeRecord(
+	typ byte, rangeID roachpb.RangeID, index uint64,
+) (int64, error) {
+	delta, seq, err := l.writeRecordLocked(typ, rangeID, index)
+	if err != nil || seq == 0 {
+		return 0, err
+	}
+	return delta, l.sync(seq)
+}
+
+func (l *segmentedRaftLog) writeRecordLocked(
+	typ byte, rangeID roachpb.RangeID, index uint64,
+) (int64, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rl, ok := l.mu.ranges[rangeID]
+	if !ok || (typ == raftLogRecordTruncate && index <= rl.firstIndex) {
+		return 0, 0, nil
+	}
+	seg, err := l.activeSegmentLocked()
+	if err != nil {
+		return 0, 0, err
+	}
+	buf, _ := appendRaftLogRecord(l.mu.buf[:0], typ, rangeID, index, nil)
+	l.mu.buf = buf
+	seq, err := l.writeLocked(seg, buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	var delta int64
+	if typ == raftLogRecordTruncate {
+		delta = l.truncateLocked(rangeID, index)
+	} else {
+		delta = -rl.size
+		l.destroyLocked(rangeID)
+	}
+	return delta, seq, nil
+}
+
+// TruncatePrefix implements the RaftLog interface.
+func (l *segmentedRaftLog) TruncatePrefix(rangeID roachpb.RangeID, index uint64) (int64, error) {
+	return l.writeRecord(raftLogRecordTruncate, rangeID, index)
+}
+
+// Destroy implements the RaftLog interface.
+func (l *segmentedRaftLog) Destroy(rangeID roachpb.RangeID) error {
+	_, err := l.writeRecord(raftLogRecordDestroy, rangeID, 0)
+	return err
+}
+
+// RangeIDs implements the RaftLog interface.
+func (l *segmentedRaftLog) RangeIDs() []roachpb.RangeID {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	rangeIDs := make([]roachpb.RangeID, 0, len(l.mu.ranges))
+	for rangeID := range l.mu.ranges {
+		rangeIDs = append(rangeIDs, rangeID)
+	}
+	sort.Slice(rangeIDs, func(i, j int) bool { return rangeIDs[i] < rangeIDs[j] })
+	return rangeIDs
+}
+
+// Close implements the RaftLog interface.
+func (l *segmentedRaftLog) Close() {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, seg := range l.mu.segments {
+		_ = seg.file.Close()
+	}
+	l.mu.segments = nil
+}
+
+// decodeRaftLogEntry returns the range ID and index of the raft log entry
+// stored under key, and the encoded entry stored in its (inline) value. ok is
+// false if the key isn't a raft log key.
+func decodeRaftLogEntry(
+	kv MVCCKeyValue,
+) (rangeID roachpb.RangeID, index uint64, ent []byte, ok bool, err error) {
+	rangeID, _, suffix, detail, err := keys.DecodeRangeIDKey(kv.Key.Key)
+	if err != nil || !bytes.Equal(suffix, keys.LocalRaftLogSuffix) {
+		return 0, 0, nil, false, err
+	}
+	if _, index, err = encoding.DecodeUint64Ascending(detail); err != nil {
+		return 0, 0, nil, false, err
+	}
+	var meta enginepb.MVCCMetadata
+	if err := meta.Unmarshal(kv.Value); err != nil {
+		return 0, 0, nil, false, err
+	}
+	value := roachpb.Value{RawBytes: meta.RawBytes}
+	if ent, err = value.GetBytes(); err != nil {
+		return 0, 0, nil, false, err
+	}
+	return rangeID, index, ent, true, nil
+}
+
+// migrateRaftLogToSeparate moves the raft log entries stored in eng to the
+// separate raft log. Each range's entries are removed from eng only after they
+// were durably written to l, and entries which are present in both are read
+// from eng, so the migration may be interrupted at any point.
+func migrateRaftLogToSeparate(ctx context.Context, eng Engine, l RaftLog) error {
+	const maxBatchEntries = 1000
+
+	var rangeID roachpb.RangeID
+	var firstIndex uint64
+	var ents [][]byte
+	var clear []MVCCKey
+	var gap bool
+	var moved int
+
+	flush := func() error {
+		if len(ents) > 0 {
+			if _, err := l.Append(rangeID, firstIndex, ents); err != nil {
+				return err
+			}
+		}
+		if len(clear) > 0 {
+			batch := eng.NewWriteOnlyBatch()
+			defer batch.Close()
+			for _, key := range clear {
+				if err := batch.Clear(key); err != nil {
+					return err
+				}
+			}
+			if err := batch.Commit(true /* sync */); err != nil {
+				return err
+			}
+		}
+		moved += len(ents)
+		firstIndex += uint64(len(ents))
+		ents, clear = ents[:0], clear[:0]
+		return nil
+	}
+
+	start := MakeMVCCMetadataKey(roachpb.Key(keys.LocalRangeIDPrefix))
+	end := MakeMVCCMetadataKey(roachpb.Key(keys.LocalRangeIDPrefix.PrefixEnd()))
+	if err := eng.Iterate(start, end, func(kv MVCCKeyValue) (bool, error) {
+		id, index, ent, ok, err := decodeRaftLogEntry(kv)
+		if err != nil || !ok {
+			return false, err
+		}
+		if id != rangeID {
+			if err := flush(); err != nil {
+				return false, err
+			}
+			rangeID, firstIndex, gap = id, index, false
+		} else if index != firstIndex+uint64(len(ents)) {
+			// Entries beyond a gap in the log can't be reached; they're
+			// leftovers of a replaced log tail and are simply removed.
+			gap = true
+		} else if len(ents) >= maxBatchEntries {
+			if err := flush(); err != nil {
+				return false, err
+			}
+		}
+		if !gap {
+			ents = append(ents, append([]byte(nil), ent...))
+		}
+		clear = append(clear, MVCCKey{Key: append(roachpb.Key(nil), kv.Key.Key...)})
+		return false, nil
+	}); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	log.Infof(ctx, "moved %d raft log entries to the separate raft log", moved)
+	return nil
+}
+
+// migrateRaftLogToEngine copies the entries of the separate raft log into eng.
+// Entries which eng already stores take precedence, so the migration may be
+// interrupted at any point; the separate log may be removed once it completes.
+func migrateRaftLogToEngine(ctx context.Context, eng Engine, l RaftLog) error {
+	var moved int
+	for _, rangeID := range l.RangeIDs() {
+		// Only entries above those stored in eng are copied: eng's entries are
+		// either newer (e.g. they were applied from a snapshot) or copies of the
+		// same entries from an earlier, interrupted migration.
+		var lo uint64
+		prefix := keys.RaftLogPrefix(rangeID)
+		if err := eng.Iterate(
+			MakeMVCCMetadataKey(prefix), MakeMVCCMetadataKey(prefix.PrefixEnd()),
+			func(kv MVCCKeyValue) (bool, error) {
+				_, index, _, _, err := decodeRaftLogEntry(kv)
+				lo = index + 1
+				return false, err
+			},
+		); err != nil {
+			return err
+		}
+
+		batch := eng.NewBatch()
+		if err := l.Iterate(rangeID, lo, math.MaxUint64, func(index uint64, ent []byte) (bool, error) {
+			key := keys.RaftLogKey(rangeID, index)
+			var value roachpb.Value
+			value.SetBytes(ent)
+			value.InitChecksum(key)
+			moved++
+			return false, MVCCPut(ctx, batch, nil /* ms */, key, hlc.Timestamp{}, value, nil /* txn */)
+		}); err != nil {
+			batch.Close()
+			return err
+		}
+		err := batch.Commit(true /* sync */)
+		batch.Close()
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof(ctx, "moved %d raft log entries from the separate raft log", moved)
+	return nil
+}
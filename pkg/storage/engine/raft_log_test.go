@@ -0,0 +1,402 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+func raftLogEntries(t *testing.T, l RaftLog, rangeID roachpb.RangeID) []string {
+	var ents []string
+	if err := l.Iterate(rangeID, 0, math.MaxUint64, func(index uint64, ent []byte) (bool, error) {
+		ents = append(ents, fmt.Sprintf("%d:%s", index, ent))
+		return false, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return ents
+}
+
+func raftLogAppend(
+	t *testing.T, l RaftLog, rangeID roachpb.RangeID, firstIndex uint64, ents ...string,
+) int64 {
+	data := make([][]byte, len(ents))
+	for i, ent := range ents {
+		data[i] = []byte(ent)
+	}
+	delta, err := l.Append(rangeID, firstIndex, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delta
+}
+
+func TestRaftLog(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	l, err := openRaftLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delta := raftLogAppend(t, l, 1, 5, "a", "bb", "ccc"); delta != 6 {
+		t.Errorf("expected delta 6, got %d", delta)
+	}
+	raftLogAppend(t, l, 2, 11, "x")
+	// Replace the tail of r1's log.
+	if delta := raftLogAppend(t, l, 1, 6, "dddd"); delta != -1 {
+		t.Errorf("expected delta -1, got %d", delta)
+	}
+	if size := l.Size(1, 6); size != 1 {
+		t.Errorf("expected size 1, got %d", size)
+	}
+
+	expected := []string{"5:a", "6:dddd"}
+	if ents := raftLogEntries(t, l, 1); !reflect.DeepEqual(ents, expected) {
+		t.Fatalf("expected %v, got %v", expected, ents)
+	}
+
+	if delta, err := l.TruncatePrefix(1, 6); err != nil {
+		t.Fatal(err)
+	} else if delta != -1 {
+		t.Errorf("expected delta -1, got %d", delta)
+	}
+	// An append after a gap starts the log over.
+	raftLogAppend(t, l, 2, 20, "y")
+	if err := l.Destroy(3); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(l RaftLog) {
+		if ents, expected := raftLogEntries(t, l, 1), []string{"6:dddd"}; !reflect.DeepEqual(ents, expected) {
+			t.Errorf("expected %v, got %v", expected, ents)
+		}
+		if ents, expected := raftLogEntries(t, l, 2), []string{"20:y"}; !reflect.DeepEqual(ents, expected) {
+			t.Errorf("expected %v, got %v", expected, ents)
+		}
+		if ids, expected := l.RangeIDs(), []roachpb.RangeID{1, 2}; !reflect.DeepEqual(ids, expected) {
+			t.Errorf("expected %v, got %v", expected, ids)
+		}
+	}
+	check(l)
+	l.Close()
+
+	// Reopening the log replays the records.
+	if l, err = openRaftLog(dir); err != nil {
+		t.Fatal(err)
+	}
+	check(l)
+
+	if err := l.Destroy(2); err != nil {
+		t.Fatal(err)
+	}
+	if ents := raftLogEntries(t, l, 2); len(ents) != 0 {
+		t.Errorf("expected no entries, got %v", ents)
+	}
+	l.Close()
+}
+
+func TestRaftLogTornWrite(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	l, err := openRaftLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raftLogAppend(t, l, 1, 1, "a", "b")
+	l.Close()
+
+	// Simulate a crash in the middle of writing the next record.
+	path := l.segmentPath(1)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{20, 0, 0, 0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err = openRaftLog(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	raftLogAppend(t, l, 1, 3, "c")
+	expected := []string{"1:a", "2:b", "3:c"}
+	if ents := raftLogEntries(t, l, 1); !reflect.DeepEqual(ents, expected) {
+		t.Fatalf("expected %v, got %v", expected, ents)
+	}
+}
+
+func TestRaftLogRemovesSegments(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	l, err := openRaftLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.segmentSize = 64
+
+	// r2's entries are larger so that most of the log remains live once
+	// r1's entries are discarded, and the log isn't compacted.
+	for i := uint64(1); i <= 20; i++ {
+		raftLogAppend(t, l, 1, i, fmt.Sprintf("entry-%02d", i))
+		raftLogAppend(t, l, 2, i, fmt.Sprintf("entry-%02d-%s", i, strings.Repeat("x", 20)))
+	}
+	segments := func() int {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(infos)
+	}
+	before := segments()
+	if before < 5 {
+		t.Fatalf("expected several segments, found %d", before)
+	}
+
+	// Segments are only removed once none of their entries are live.
+	if _, err := l.TruncatePrefix(1, 21); err != nil {
+		t.Fatal(err)
+	}
+	if n := segments(); n != before {
+		t.Fatalf("expected %d segments, found %d", before, n)
+	}
+	if _, err := l.TruncatePrefix(2, 19); err != nil {
+		t.Fatal(err)
+	}
+	if n := segments(); n >= before {
+		t.Fatalf("expected fewer than %d segments, found %d", before, n)
+	}
+	x := strings.Repeat("x", 20)
+	expected := []string{"19:entry-19-" + x, "20:entry-20-" + x}
+	if ents := raftLogEntries(t, l, 2); !reflect.DeepEqual(ents, expected) {
+		t.Fatalf("expected %v, got %v", expected, ents)
+	}
+}
+
+// TestRaftLogCompaction verifies that the entries of an idle range don't
+// prevent the removal of the segments following theirs.
+func TestRaftLogCompaction(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	l, err := openRaftLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.segmentSize = 64
+
+	raftLogAppend(t, l, 1, 10, "idle")
+	maxSegments := 0
+	for i := uint64(1); i <= 200; i++ {
+		raftLogAppend(t, l, 2, i, fmt.Sprintf("entry-%03d", i))
+		if _, err := l.TruncatePrefix(2, i); err != nil {
+			t.Fatal(err)
+		}
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) > maxSegments {
+			maxSegments = len(infos)
+		}
+	}
+	if maxSegments > 5 {
+		t.Fatalf("expected the log to remain small, found up to %d segments", maxSegments)
+	}
+
+	// The rewritten entries replace the originals when the log is replayed.
+	l.Close()
+	if l, err = openRaftLog(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if ents, expected := raftLogEntries(t, l, 1), []string{"10:idle"}; !reflect.DeepEqual(ents, expected) {
+		t.Fatalf("expected %v, got %v", expected, ents)
+	}
+	if ents, expected := raftLogEntries(t, l, 2), []string{"200:entry-200"}; !reflect.DeepEqual(ents, expected) {
+		t.Fatalf("expected %v, got %v", expected, ents)
+	}
+}
+
+// TestRaftLogConcurrentAppends verifies that the appends of concurrent
+// writers, which share syncs, are all durable.
+func TestRaftLogConcurrentAppends(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	l, err := openRaftLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.segmentSize = 256
+
+	const numRanges = 10
+	const numEntries = 50
+	var wg sync.WaitGroup
+	errCh := make(chan error, numRanges)
+	for r := 1; r <= numRanges; r++ {
+		wg.Add(1)
+		go func(rangeID roachpb.RangeID) {
+			defer wg.Done()
+			for i := uint64(1); i <= numEntries; i++ {
+				if _, err := l.Append(rangeID, i, [][]byte{[]byte(fmt.Sprintf("%d", i))}); err != nil {
+					errCh <- err
+					return
+				}
+				if _, err := l.TruncatePrefix(rangeID, i-1); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}(roachpb.RangeID(r))
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	if l, err = openRaftLog(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	expected := []string{fmt.Sprintf("%d:%d", numEntries-1, numEntries-1),
+		fmt.Sprintf("%d:%d", numEntries, numEntries)}
+	for r := roachpb.RangeID(1); r <= numRanges; r++ {
+		if ents := raftLogEntries(t, l, r); !reflect.DeepEqual(ents, expected) {
+			t.Fatalf("r%d: expected %v, got %v", r, expected, ents)
+		}
+	}
+}
+
+func TestRaftLogMigration(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	open := func(format RaftLogFormat) *RocksDB {
+		db, err := NewRocksDB(
+			RocksDBConfig{
+				RocksDBSettings: cluster.MakeTestingClusterSettings().RocksDBSettings,
+				Dir:             dir,
+				RaftLogFormat:   format,
+			},
+			RocksDBCache{},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	engineEntries := func(db *RocksDB, rangeID roachpb.RangeID) []string {
+		var ents []string
+		prefix := keys.RaftLogPrefix(rangeID)
+		if err := db.Iterate(
+			MakeMVCCMetadataKey(prefix), MakeMVCCMetadataKey(prefix.PrefixEnd()),
+			func(kv MVCCKeyValue) (bool, error) {
+				_, index, ent, _, err := decodeRaftLogEntry(kv)
+				ents = append(ents, fmt.Sprintf("%d:%s", index, ent))
+				return false, err
+			},
+		); err != nil {
+			t.Fatal(err)
+		}
+		return ents
+	}
+
+	db := open(RaftLogInEngine)
+	if db.RaftLog() != nil {
+		t.Fatal("unexpected separate raft log")
+	}
+	// r1's log has a gap; the entry above it is unreachable.
+	for rangeID, indexes := range map[roachpb.RangeID][]uint64{1: {3, 4, 5, 9}, 2: {7}} {
+		for _, index := range indexes {
+			key := keys.RaftLogKey(rangeID, index)
+			var value roachpb.Value
+			value.SetBytes([]byte(fmt.Sprintf("r%d", rangeID)))
+			value.InitChecksum(key)
+			if err := MVCCPut(ctx, db, nil, key, hlc.Timestamp{}, value, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	db.Close()
+
+	db = open(RaftLogSeparate)
+	if ents := engineEntries(db, 1); len(ents) != 0 {
+		t.Errorf("expected no entries in the engine, got %v", ents)
+	}
+	expected := []string{"3:r1", "4:r1", "5:r1"}
+	if ents := raftLogEntries(t, db.RaftLog(), 1); !reflect.DeepEqual(ents, expected) {
+		t.Errorf("expected %v, got %v", expected, ents)
+	}
+	raftLogAppend(t, db.RaftLog(), 2, 8, "r2")
+	db.Close()
+
+	// Tools open the store without changing its format.
+	db = open(RaftLogExisting)
+	if db.RaftLog() == nil {
+		t.Fatal("expected separate raft log")
+	}
+	db.Close()
+
+	db = open(RaftLogInEngine)
+	if db.RaftLog() != nil {
+		t.Fatal("unexpected separate raft log")
+	}
+	if _, err := os.Stat(filepath.Join(dir, raftLogSubdir)); !os.IsNotExist(err) {
+		t.Errorf("expected separate raft log to be removed, got %v", err)
+	}
+	if ents := engineEntries(db, 1); !reflect.DeepEqual(ents, expected) {
+		t.Errorf("expected %v, got %v", expected, ents)
+	}
+	expected = []string{"7:r2", "8:r2"}
+	if ents := engineEntries(db, 2); !reflect.DeepEqual(ents, expected) {
+		t.Errorf("expected %v, got %v", expected, ents)
+	}
+	db.Close()
+}
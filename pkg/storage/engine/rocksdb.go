@@ -302,8 +302,30 @@ type RocksDBConfig struct {
 	// WriteBatch takes longer than WarnLargeBatchThreshold. If it is set to
 	// zero, no log messages are ever printed.
 	WarnLargeBatchThreshold time.Duration
+	// ExtraOptions is a serialized configuration which is interpreted by CCL
+	// code (e.g. the encryption-at-rest configuration). It must be empty in
+	// non-CCL builds.
+	ExtraOptions []byte
+	// RaftLogFormat determines where raft log entries are stored.
+	RaftLogFormat RaftLogFormat
 }
 
+// RaftLogFormat determines where an engine stores raft log entries.
+type RaftLogFormat int
+
+const (
+	// RaftLogInEngine stores raft log entries in the engine. Entries found in
+	// a separate raft log are moved into the engine when it is opened.
+	RaftLogInEngine RaftLogFormat = iota
+	// RaftLogSeparate stores raft log entries in a separate append-only log in
+	// the store directory. Entries stored in the engine are moved to the
+	// separate log when it is first created.
+	RaftLogSeparate
+	// RaftLogExisting keeps whichever format the store uses. It is meant for
+	// tools which must not rewrite the store.
+	RaftLogExisting
+)
+
 // RocksDB is a wrapper around a RocksDB database instance.
 type RocksDB struct {
 	cfg   RocksDBConfig
@@ -311,6 +333,9 @@ type RocksDB struct {
 	cache RocksDBCache // Shared cache.
 	// auxDir is used for storing auxiliary files. Ideally it is a subdirectory of Dir.
 	auxDir string
+	// raftLog is the separate raft log, if raft log entries aren't stored in
+	// the engine.
+	raftLog *segmentedRaftLog
 
 	commit struct {
 		syncutil.Mutex
@@ -355,9 +380,57 @@ func NewRocksDB(cfg RocksDBConfig, cache RocksDBCache) (*RocksDB, error) {
 	if err := r.open(); err != nil {
 		return nil, err
 	}
+	if err := r.openRaftLog(context.TODO()); err != nil {
+		r.Close()
+		return nil, err
+	}
 	return r, nil
 }
 
+// openRaftLog opens the separate raft log according to the configured raft
+// log format, moving raft log entries between the engine and the separate log
+// if the store was last used with a different format.
+func (r *RocksDB) openRaftLog(ctx context.Context) error {
+	exists, err := raftLogExists(r.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	dir := raftLogDir(r.cfg.Dir)
+	switch r.cfg.RaftLogFormat {
+	case RaftLogInEngine:
+		if !exists {
+			return nil
+		}
+		l, err := openRaftLog(dir)
+		if err != nil {
+			return err
+		}
+		err = migrateRaftLogToEngine(ctx, r, l)
+		l.Close()
+		if err != nil {
+			return errors.Wrap(err, "moving raft log entries into rocksdb")
+		}
+		return os.RemoveAll(dir)
+	case RaftLogSeparate:
+		if r.raftLog, err = openRaftLog(dir); err != nil {
+			return err
+		}
+		if !exists {
+			if err := migrateRaftLogToSeparate(ctx, r, r.raftLog); err != nil {
+				return errors.Wrap(err, "moving raft log entries out of rocksdb")
+			}
+		}
+		return nil
+	case RaftLogExisting:
+		if exists {
+			r.raftLog, err = openRaftLog(dir)
+		}
+		return err
+	default:
+		return errors.Errorf("unknown raft log format %d", r.cfg.RaftLogFormat)
+	}
+}
+
 func newMemRocksDB(
 	attrs roachpb.Attributes, cache RocksDBCache, MaxSizeBytes int64,
 ) (*RocksDB, error) {
@@ -441,6 +514,7 @@ func (r *RocksDB) open() error {
 			logging_enabled: C.bool(log.V(3)),
 			num_cpu:         C.int(runtime.NumCPU()),
 			max_open_files:  C.int(maxOpenFiles),
+			extra_options:   goToCSlice(r.cfg.ExtraOptions),
 		})
 	if err := statusToError(status); err != nil {
 		return errors.Errorf("could not open rocksdb instance: %s", err)
@@ -521,6 +595,10 @@ func (r *RocksDB) Close() {
 	} else {
 		log.Infof(context.TODO(), "closing rocksdb instance at %q", r.cfg.Dir)
 	}
+	if r.raftLog != nil {
+		r.raftLog.Close()
+		r.raftLog = nil
+	}
 	if r.rdb != nil {
 		C.DBClose(r.rdb)
 		r.rdb = nil
@@ -911,6 +989,9 @@ func (r *RocksDB) GetStats() (*Stats, error) {
 		Flushes:                  int64(s.flushes),
 		Compactions:              int64(s.compactions),
 		TableReadersMemEstimate:  int64(s.table_readers_mem_estimate),
+
+		L0FileCount:                    int64(s.l0_file_count),
+		PendingCompactionBytesEstimate: int64(s.pending_compaction_bytes_estimate),
 	}, nil
 }
 
@@ -920,6 +1001,42 @@ func (r *RocksDB) GetCompactionStats() string {
 	return cStringToGoString(C.DBGetCompactionStats(r.rdb))
 }
 
+// EncryptionStatus describes the encryption state of an engine's live
+// sstables.
+type EncryptionStatus struct {
+	// ActiveKeyID is the ID of the key used to encrypt new files. It is empty
+	// if the engine is not encrypted.
+	ActiveKeyID string
+	TotalFiles  int64
+	// OldKeyFiles is the number of live sstables which are encrypted with a
+	// key other than the active key.
+	OldKeyFiles int64
+}
+
+// GetEncryptionStatus returns the encryption status of the engine.
+func (r *RocksDB) GetEncryptionStatus() (EncryptionStatus, error) {
+	var s C.DBEncryptionStatus
+	if err := statusToError(C.DBGetEncryptionStatus(r.rdb, &s)); err != nil {
+		return EncryptionStatus{}, err
+	}
+	return EncryptionStatus{
+		ActiveKeyID: cStringToGoString(s.active_key_id),
+		TotalFiles:  int64(s.total_files),
+		OldKeyFiles: int64(s.old_key_files),
+	}, nil
+}
+
+// ReencryptFiles rewrites the live sstables which are encrypted with a key
+// other than the active key, returning the number of rewritten files. It is
+// a no-op if the engine is not encrypted.
+func (r *RocksDB) ReencryptFiles() (int, error) {
+	var n C.int
+	if err := statusToError(C.DBReencryptFiles(r.rdb, &n)); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
 type rocksDBSnapshot struct {
 	parent *RocksDB
 	handle *C.DBEngine
@@ -963,7 +1080,9 @@ func (r *rocksDBSnapshot) NewIterator(prefix bool) Iterator {
 
 // NewTimeBoundIterator is like NewIterator, but returns a time-bound iterator.
 func (r *rocksDBSnapshot) NewTimeBoundIterator(start, end hlc.Timestamp) Iterator {
-	panic("not implemented")
+	it := &rocksDBIterator{}
+	it.initTimeBound(r.handle, start, end, r)
+	return it
 }
 
 // reusableIterator wraps rocksDBIterator and allows reuse of an iterator
@@ -2119,6 +2238,14 @@ func RunLDB(args []string) {
 	C.DBRunLDB(C.int(len(argv)), &argv[0])
 }
 
+// RaftLog implements the Engine interface.
+func (r *RocksDB) RaftLog() RaftLog {
+	if r.raftLog == nil {
+		return nil
+	}
+	return r.raftLog
+}
+
 // GetAuxiliaryDir returns the auxiliary storage path for this engine.
 func (r *RocksDB) GetAuxiliaryDir() string {
 	return r.auxDir
@@ -2141,3 +2268,12 @@ func (r *RocksDB) IngestExternalFile(ctx context.Context, path string, move bool
 func (r *RocksDB) WriteFile(filename string, data []byte) error {
 	return statusToError(C.DBEnvWriteFile(r.rdb, goToCSlice([]byte(filename)), goToCSlice(data)))
 }
+
+// ReadFile reads the contents of a file in this RocksDB's env.
+func (r *RocksDB) ReadFile(filename string) ([]byte, error) {
+	var data C.DBString
+	if err := statusToError(C.DBEnvReadFile(r.rdb, goToCSlice([]byte(filename)), &data)); err != nil {
+		return nil, err
+	}
+	return cStringToGoBytes(data), nil
+}
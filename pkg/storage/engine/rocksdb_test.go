@@ -621,4 +621,38 @@ func TestRocksDBTimeBound(t *testing.T) {
 	if sst.TsMax == nil || *sst.TsMax != maxTimestamp {
 		t.Fatalf("got max %v expected %v", sst.TsMax, maxTimestamp)
 	}
+
+	snap := rocksdb.NewSnapshot()
+	defer snap.Close()
+
+	// Time-bound iterators skip the sstable when the time range (start, end]
+	// doesn't overlap with its timestamps.
+	testCases := []struct {
+		start, end hlc.Timestamp
+		expected   int
+	}{
+		{hlc.Timestamp{}, minTimestamp, len(times)},
+		{minTimestamp, maxTimestamp, len(times)},
+		{maxTimestamp.Prev(), hlc.Timestamp{WallTime: 5}, len(times)},
+		{maxTimestamp, hlc.Timestamp{WallTime: 5}, 0},
+		{hlc.Timestamp{}, minTimestamp.Prev(), 0},
+	}
+	for _, r := range []Reader{rocksdb, snap} {
+		for _, tc := range testCases {
+			iter := r.NewTimeBoundIterator(tc.start, tc.end)
+			var count int
+			for iter.Seek(NilKey); ; iter.Next() {
+				if ok, err := iter.Valid(); err != nil {
+					t.Fatal(err)
+				} else if !ok {
+					break
+				}
+				count++
+			}
+			iter.Close()
+			if count != tc.expected {
+				t.Errorf("%T (%s,%s]: expected %d keys, got %d", r, tc.start, tc.end, tc.expected, count)
+			}
+		}
+	}
 }
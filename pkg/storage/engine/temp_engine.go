@@ -50,6 +50,7 @@ func NewTempEngine(ctx context.Context, storeCfg base.StoreSpec) (Engine, error)
 		Dir:             storeCfg.Path,
 		MaxSizeBytes:    0,   // TODO(arjun): Revisit this.
 		MaxOpenFiles:    128, // TODO(arjun): Revisit this.
+		ExtraOptions:    storeCfg.ExtraOptions,
 	}
 	rocksDBCache := NewRocksDBCache(0)
 	rocksdb, err := NewRocksDB(rocksDBCfg, rocksDBCache)
@@ -33,10 +33,12 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/uuid"
 )
 
@@ -92,6 +94,15 @@ const (
 // single priority. If any task is overdue, shouldQueue returns true.
 type gcQueue struct {
 	*baseQueue
+
+	// protectedTS caches the records of system.protected_ts_records for
+	// shouldQueue, which runs for every replica on each scanner pass and can't
+	// afford to read them each time. processImpl always reads them afresh.
+	protectedTS struct {
+		syncutil.Mutex
+		readAt  time.Time
+		records []protectedTSRecord
+	}
 }
 
 // newGCQueue returns a new instance of gcQueue.
@@ -159,12 +170,21 @@ func (r gcQueueScore) String() string {
 func (gcq *gcQueue) shouldQueue(
 	ctx context.Context, now hlc.Timestamp, repl *Replica, sysCfg config.SystemConfig,
 ) (bool, float64) {
-	r := makeGCQueueScore(ctx, repl, now, sysCfg)
+	protected := earliestProtectedTimestamp(gcq.cachedProtectedTSRecords(ctx), repl.Desc())
+	r := makeGCQueueScore(ctx, repl, now, sysCfg, protected)
 	return r.ShouldQueue, r.FinalScore
 }
 
+// makeGCQueueScore computes the GC queue score of the replica. If the GC
+// threshold has already been advanced up to the protected timestamp, GC can't
+// collect any more versions and only the intent score is taken into account,
+// so that a protected range isn't queued over and over again.
 func makeGCQueueScore(
-	ctx context.Context, repl *Replica, now hlc.Timestamp, sysCfg config.SystemConfig,
+	ctx context.Context,
+	repl *Replica,
+	now hlc.Timestamp,
+	sysCfg config.SystemConfig,
+	protected hlc.Timestamp,
 ) gcQueueScore {
 	repl.mu.Lock()
 	ms := repl.mu.state.Stats
@@ -186,6 +206,11 @@ func makeGCQueueScore(
 	if (gcThreshold != hlc.Timestamp{}) {
 		r.LikelyLastGC = time.Duration(now.WallTime - gcThreshold.Add(r.TTL.Nanoseconds(), 0).WallTime)
 	}
+	if (protected != hlc.Timestamp{}) && !gcThreshold.Less(protected.Prev()) {
+		r.ValuesScalableScore = 0
+		r.ShouldQueue = r.FuzzFactor*r.IntentScore > gcIntentScoreThreshold
+		r.FinalScore = r.FuzzFactor * r.IntentScore
+	}
 	return r
 }
 
@@ -388,9 +413,10 @@ func processLocalKeyRange(
 
 		// The transaction record should be considered for removal.
 		switch txn.Status {
-		case roachpb.PENDING:
+		case roachpb.PENDING, roachpb.STAGING:
 			// Marked as running, so we need to push it to abort it but won't
-			// try to GC it in this cycle (for convenience).
+			// try to GC it in this cycle (for convenience). Pushing a STAGING
+			// transaction whose coordinator is gone recovers it instead.
 			// TODO(tschottdorf): refactor so that we can GC PENDING entries
 			// in the same cycle, but keeping the calls to pushTxn in a central
 			// location (keeping it easy to batch them up in the future).
@@ -526,7 +552,8 @@ func processAbortCache(
 // 8) send a GCRequest.
 func (gcq *gcQueue) process(ctx context.Context, repl *Replica, sysCfg config.SystemConfig) error {
 	now := repl.store.Clock().Now()
-	r := makeGCQueueScore(ctx, repl, now, sysCfg)
+	protected := earliestProtectedTimestamp(gcq.cachedProtectedTSRecords(ctx), repl.Desc())
+	r := makeGCQueueScore(ctx, repl, now, sysCfg, protected)
 	if !r.ShouldQueue {
 		log.Eventf(ctx, "skipping replica; low score %s", r)
 		return nil
@@ -585,7 +612,18 @@ func (gcq *gcQueue) processImpl(
 		return errors.Errorf("could not find zone config for range %s: %s", repl, err)
 	}
 
-	gcKeys, info, err := RunGC(ctx, desc, snap, now, zone.GC,
+	// Look up the earliest timestamp protected on this range, which the GC
+	// threshold must stay below. The records are read after now was chosen,
+	// so that a record written before the threshold passed its timestamp is
+	// always observed. A record committed after they were read is caught
+	// when the GC request is evaluated, using readAt.
+	records, readAt, err := gcq.readProtectedTSRecords(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "could not load protected timestamps for range %s", repl)
+	}
+	protected := earliestProtectedTimestamp(records, desc)
+
+	gcKeys, info, err := RunGC(ctx, desc, snap, now, zone.GC, protected,
 		func(now hlc.Timestamp, txn *roachpb.Transaction, typ roachpb.PushTxnType) {
 			pushTxn(ctx, gcq.store.DB(), now, txn, typ)
 		},
@@ -604,6 +642,8 @@ func (gcq *gcQueue) processImpl(
 	}()
 
 	batches := chunkGCRequest(desc, &info, gcKeys)
+	// The first batch advances the thresholds.
+	batches[0].ProtectedTSReadAt = readAt
 
 	for i, gcArgs := range batches {
 		var ba roachpb.BatchRequest
@@ -624,7 +664,8 @@ func (gcq *gcQueue) processImpl(
 		}
 	}
 
-	log.Eventf(ctx, "done GC'ing, new score is %s", makeGCQueueScore(ctx, repl, repl.store.Clock().Now(), sysCfg))
+	log.Eventf(ctx, "done GC'ing, new score is %s",
+		makeGCQueueScore(ctx, repl, repl.store.Clock().Now(), sysCfg, protected))
 	return nil
 }
 
@@ -662,8 +703,12 @@ type GCInfo struct {
 	ResolveTotal int
 	// ResolveErrors is the number of successful intent resolutions.
 	ResolveSuccess int
-	// Threshold is the computed expiration timestamp. Equal to `Now - Policy`.
+	// Threshold is the computed expiration timestamp. Equal to `Now - Policy`,
+	// unless held back by Protected.
 	Threshold hlc.Timestamp
+	// Protected is the earliest protected timestamp of a record overlapping
+	// the range, if any. Threshold is kept below it.
+	Protected hlc.Timestamp
 }
 
 func (info *GCInfo) updateMetrics(metrics *StoreMetrics) {
@@ -691,13 +736,15 @@ type lockableGCInfo struct {
 // Engine (which is not mutated). It uses the provided functions pushTxnFn and
 // resolveIntentsFn to clarify the true status of and clean up after encountered
 // transactions. It returns a slice of gc'able keys from the data, transaction,
-// and abort spans.
+// and abort spans. If protected is not zero, no versions visible at it are
+// collected.
 func RunGC(
 	ctx context.Context,
 	desc *roachpb.RangeDescriptor,
 	snap engine.Reader,
 	now hlc.Timestamp,
 	policy config.GCPolicy,
+	protected hlc.Timestamp,
 	pushTxnFn pushFunc,
 	resolveIntentsFn resolveFunc,
 ) ([]roachpb.GCRequest_GCKey, GCInfo, error) {
@@ -739,7 +786,12 @@ func RunGC(
 	abortSpanGCThreshold := now.Add(-int64(abortCacheAgeThreshold), 0)
 
 	gc := engine.MakeGarbageCollector(now, policy)
+	if protected != (hlc.Timestamp{}) && !gc.Threshold.Less(protected) {
+		// Reads are allowed strictly above the threshold.
+		gc.Threshold = protected.Prev()
+	}
 	infoMu.Threshold = gc.Threshold
+	infoMu.Protected = protected
 	infoMu.TxnSpanGCThreshold = txnExp
 
 	var gcKeys []roachpb.GCRequest_GCKey
@@ -843,7 +895,7 @@ func RunGC(
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, gcTaskLimit)
 	for _, txn := range txnMap {
-		if txn.Status != roachpb.PENDING {
+		if txn.Status.IsFinalized() {
 			continue
 		}
 		wg.Add(1)
@@ -864,7 +916,7 @@ func RunGC(
 	log.Eventf(ctx, "resolving up to %d intents", len(txnMap))
 	var intents []roachpb.Intent
 	for txnID, txn := range txnMap {
-		if txn.Status != roachpb.PENDING {
+		if txn.Status.IsFinalized() {
 			for _, intent := range intentSpanMap[txnID] {
 				intents = append(intents, roachpb.Intent{Span: intent, Status: txn.Status, Txn: txn.TxnMeta})
 			}
@@ -882,6 +934,134 @@ func RunGC(
 	return gcKeys, infoMu.GCInfo, nil
 }
 
+// protectedTSCacheInterval is the maximum age of the protected timestamp
+// records consulted by shouldQueue.
+const protectedTSCacheInterval = time.Minute
+
+// The IDs of the system.protected_ts_records columns read by the GC queue,
+// which decodes the table's primary index directly instead of depending on
+// the SQL layer.
+const (
+	protectedTSIndexID       = 1
+	protectedTSWallTimeColID = 3
+	protectedTSLogicalColID  = 4
+	protectedTSStartKeyColID = 5
+	protectedTSEndKeyColID   = 6
+)
+
+// protectedTSRecord is a row of system.protected_ts_records.
+type protectedTSRecord struct {
+	span roachpb.Span
+	ts   hlc.Timestamp
+}
+
+// readProtectedTSRecords scans all the records in system.protected_ts_records
+// and refreshes the cache used by shouldQueue. It returns the timestamp at
+// which they were read: any record missing from the scan commits above it.
+func (gcq *gcQueue) readProtectedTSRecords(
+	ctx context.Context,
+) ([]protectedTSRecord, hlc.Timestamp, error) {
+	prefix := encoding.EncodeUvarintAscending(
+		keys.MakeTablePrefix(keys.ProtectedTSTableID), protectedTSIndexID)
+	readAt := gcq.store.Clock().Now()
+	b := &client.Batch{}
+	b.Header.Timestamp = readAt
+	b.Scan(prefix, roachpb.Key(prefix).PrefixEnd())
+	if err := gcq.store.DB().Run(ctx, b); err != nil {
+		return nil, hlc.Timestamp{}, err
+	}
+	kvs := b.Results[0].Rows
+	records := make([]protectedTSRecord, 0, len(kvs))
+	for _, kv := range kvs {
+		record, err := decodeProtectedTSRecord(kv.Value)
+		if err != nil {
+			return nil, hlc.Timestamp{}, errors.Wrapf(err, "decoding protected timestamp record %s", kv.Key)
+		}
+		records = append(records, record)
+	}
+
+	gcq.protectedTS.Lock()
+	gcq.protectedTS.readAt = timeutil.Now()
+	gcq.protectedTS.records = records
+	gcq.protectedTS.Unlock()
+	return records, readAt, nil
+}
+
+// cachedProtectedTSRecords returns the cached protected timestamp records,
+// reading them again if they are older than protectedTSCacheInterval. If the
+// records can't be read, the stale ones are returned.
+func (gcq *gcQueue) cachedProtectedTSRecords(ctx context.Context) []protectedTSRecord {
+	gcq.protectedTS.Lock()
+	records := gcq.protectedTS.records
+	stale := timeutil.Since(gcq.protectedTS.readAt) > protectedTSCacheInterval
+	gcq.protectedTS.Unlock()
+	if !stale {
+		return records
+	}
+	fresh, _, err := gcq.readProtectedTSRecords(ctx)
+	if err != nil {
+		log.Warningf(ctx, "could not load protected timestamps: %s", err)
+		return records
+	}
+	return fresh
+}
+
+// decodeProtectedTSRecord decodes the value of a system.protected_ts_records
+// row, whose columns all live in a single column family.
+func decodeProtectedTSRecord(v *roachpb.Value) (protectedTSRecord, error) {
+	var record protectedTSRecord
+	b, err := v.GetTuple()
+	if err != nil {
+		return record, err
+	}
+	var colID uint32
+	for len(b) > 0 {
+		_, _, colIDDiff, _, err := encoding.DecodeValueTag(b)
+		if err != nil {
+			return record, err
+		}
+		colID += colIDDiff
+		var i int64
+		switch colID {
+		case protectedTSWallTimeColID:
+			b, record.ts.WallTime, err = encoding.DecodeIntValue(b)
+		case protectedTSLogicalColID:
+			b, i, err = encoding.DecodeIntValue(b)
+			record.ts.Logical = int32(i)
+		case protectedTSStartKeyColID:
+			b, record.span.Key, err = encoding.DecodeBytesValue(b)
+		case protectedTSEndKeyColID:
+			b, record.span.EndKey, err = encoding.DecodeBytesValue(b)
+		default:
+			var n int
+			_, n, err = encoding.PeekValueLength(b)
+			b = b[n:]
+		}
+		if err != nil {
+			return record, err
+		}
+	}
+	return record, nil
+}
+
+// earliestProtectedTimestamp returns the earliest timestamp protected by a
+// record overlapping the range, or the zero timestamp if there is none.
+func earliestProtectedTimestamp(
+	records []protectedTSRecord, desc *roachpb.RangeDescriptor,
+) hlc.Timestamp {
+	span := roachpb.Span{Key: desc.StartKey.AsRawKey(), EndKey: desc.EndKey.AsRawKey()}
+	var protected hlc.Timestamp
+	for _, record := range records {
+		if !record.span.Overlaps(span) {
+			continue
+		}
+		if (protected == hlc.Timestamp{}) || record.ts.Less(protected) {
+			protected = record.ts
+		}
+	}
+	return protected
+}
+
 // timer returns a constant duration to space out GC processing
 // for successive queued replicas.
 func (*gcQueue) timer(_ time.Duration) time.Duration {
@@ -915,6 +1095,15 @@ func pushTxn(
 	b := &client.Batch{}
 	b.AddRawRequest(pushArgs)
 	if err := db.Run(ctx, b); err != nil {
+		if icErr, ok := b.MustPErr().GetDetail().(*roachpb.IndeterminateCommitError); ok {
+			recovered, err := recoverStagingTxn(ctx, db, icErr.StagingTxn)
+			if err != nil {
+				log.Warningf(ctx, "recovery of txn %s failed: %s", txn, err)
+				return
+			}
+			*txn = recovered
+			return
+		}
 		log.Warningf(ctx, "push of txn %s failed: %s", txn, err)
 		return
 	}
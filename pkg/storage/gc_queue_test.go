@@ -26,12 +26,14 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 
+	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
 	"github.com/cockroachdb/cockroach/pkg/storage/storagebase"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
@@ -985,3 +987,130 @@ func TestChunkGCRequest(t *testing.T) {
 		t.Fatalf("expected GC Request's batch size smaller than %v, but got %v", gcChunkKeySize, size)
 	}
 }
+
+// TestRunGCProtectedTimestamp verifies that RunGC keeps the versions visible
+// at a protected timestamp even if they are older than the GC TTL.
+func TestRunGCProtectedTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ctx := context.Background()
+	eng := engine.NewInMem(roachpb.Attributes{}, 1<<20)
+	defer eng.Close()
+
+	key := roachpb.Key("a")
+	for _, sec := range []int64{1, 2, 3} {
+		if err := engine.MVCCPut(
+			ctx, eng, nil, key, makeTS(sec*1E9, 0), roachpb.MakeValueFromString("v"), nil,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	desc := testRangeDescriptor()
+	now := makeTS(10*1E9, 0)
+	policy := config.GCPolicy{TTLSeconds: 1}
+	pushTxnFn := func(hlc.Timestamp, *roachpb.Transaction, roachpb.PushTxnType) {}
+	resolveIntentsFn := func([]roachpb.Intent, ResolveOptions) error { return nil }
+
+	testCases := []struct {
+		protected     hlc.Timestamp
+		expThreshold  hlc.Timestamp
+		expGCKeyTimes []hlc.Timestamp
+	}{
+		// Without a protected timestamp, all but the newest version are GC'able.
+		{hlc.Timestamp{}, makeTS(9*1E9, 0), []hlc.Timestamp{makeTS(2*1E9, 0)}},
+		// A protected timestamp newer than the TTL has no effect.
+		{makeTS(9*1E9, 1), makeTS(9*1E9, 0), []hlc.Timestamp{makeTS(2*1E9, 0)}},
+		// Reads at the protected timestamp must see the version at 2s, so only
+		// the version at 1s can go.
+		{makeTS(2*1E9, 5), makeTS(2*1E9, 4), []hlc.Timestamp{makeTS(1*1E9, 0)}},
+		// Reads at the protected timestamp must see the version at 1s.
+		{makeTS(2*1E9, 0), makeTS(2*1E9, 0).Prev(), nil},
+	}
+	for i, c := range testCases {
+		snap := eng.NewSnapshot()
+		gcKeys, info, err := RunGC(
+			ctx, desc, snap, now, policy, c.protected, pushTxnFn, resolveIntentsFn,
+		)
+		snap.Close()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if info.Threshold != c.expThreshold {
+			t.Errorf("%d: expected threshold %s, got %s", i, c.expThreshold, info.Threshold)
+		}
+		var gcKeyTimes []hlc.Timestamp
+		for _, gcKey := range gcKeys {
+			if gcKey.Key.Equal(key) {
+				gcKeyTimes = append(gcKeyTimes, gcKey.Timestamp)
+			}
+		}
+		if !reflect.DeepEqual(gcKeyTimes, c.expGCKeyTimes) {
+			t.Errorf("%d: expected GC'd versions %v, got %v", i, c.expGCKeyTimes, gcKeyTimes)
+		}
+	}
+}
+
+// TestEarliestProtectedTimestamp verifies that protected timestamp records
+// are decoded from their KV encoding and matched against the range's span.
+func TestEarliestProtectedTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// makeRecord encodes a system.protected_ts_records row the way the SQL
+	// layer does: each column is tagged with the difference to the previous
+	// column ID, and a NULL job_id is omitted from the tuple.
+	makeRecord := func(jobID int64, ts hlc.Timestamp, start, end string) *roachpb.Value {
+		var b []byte
+		var lastColID uint32
+		if jobID != 0 {
+			b = encoding.EncodeIntValue(b, 2, jobID)
+			lastColID = 2
+		}
+		b = encoding.EncodeIntValue(b, protectedTSWallTimeColID-lastColID, ts.WallTime)
+		b = encoding.EncodeIntValue(b, 1, int64(ts.Logical))
+		b = encoding.EncodeBytesValue(b, 1, []byte(start))
+		b = encoding.EncodeBytesValue(b, 1, []byte(end))
+		b = encoding.EncodeTimeValue(b, 1, time.Unix(0, 123))
+		var v roachpb.Value
+		v.SetTuple(b)
+		return &v
+	}
+
+	var records []protectedTSRecord
+	for _, v := range []*roachpb.Value{
+		makeRecord(1, makeTS(5, 1), "a", "c"),
+		makeRecord(2, makeTS(3, 0), "d", "f"),
+		makeRecord(0, makeTS(4, 2), "c", "e"),
+	} {
+		record, err := decodeProtectedTSRecord(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if exp := (protectedTSRecord{
+		span: roachpb.Span{Key: roachpb.Key("c"), EndKey: roachpb.Key("e")},
+		ts:   makeTS(4, 2),
+	}); !reflect.DeepEqual(records[2], exp) {
+		t.Fatalf("expected %+v, got %+v", exp, records[2])
+	}
+
+	testCases := []struct {
+		start, end string
+		expected   hlc.Timestamp
+	}{
+		{"a", "b", makeTS(5, 1)},
+		{"b", "d", makeTS(4, 2)},
+		{"b", "z", makeTS(3, 0)},
+		{"f", "z", hlc.Timestamp{}},
+	}
+	for _, c := range testCases {
+		desc := &roachpb.RangeDescriptor{
+			StartKey: roachpb.RKey(c.start),
+			EndKey:   roachpb.RKey(c.end),
+		}
+		if protected := earliestProtectedTimestamp(records, desc); protected != c.expected {
+			t.Errorf("[%s,%s): expected %s, got %s", c.start, c.end, c.expected, protected)
+		}
+	}
+}
@@ -166,6 +166,11 @@ func (s *Store) SetSplitQueueActive(active bool) {
 	s.setSplitQueueActive(active)
 }
 
+// SetReplicateQueueActive enables or disables the replicate queue.
+func (s *Store) SetReplicateQueueActive(active bool) {
+	s.setReplicateQueueActive(active)
+}
+
 // SetRaftSnapshotQueueActive enables or disables the raft snapshot queue.
 func (s *Store) SetRaftSnapshotQueueActive(active bool) {
 	s.setRaftSnapshotQueueActive(active)
@@ -229,6 +234,12 @@ func NewTestStorePool(cfg StoreConfig) *StorePool {
 	)
 }
 
+// InconsistentReplicas returns the replicas which a consistency check run by
+// this replica found to disagree with a majority of the range.
+func (r *Replica) InconsistentReplicas() ([]roachpb.ReplicaDescriptor, error) {
+	return r.inconsistentReplicas(context.Background(), r.Desc())
+}
+
 func (r *Replica) ReplicaIDLocked() roachpb.ReplicaID {
 	return r.mu.replicaID
 }
@@ -493,6 +504,21 @@ func (r *Replica) RaftTransferLeader(ctx context.Context, target roachpb.Replica
 	r.maybeTransferRaftLeadership(ctx, target)
 }
 
+// AddNonVoterReplica adds a non-voting replica of the range on the target
+// store.
+func (r *Replica) AddNonVoterReplica(
+	ctx context.Context, target roachpb.ReplicationTarget,
+) error {
+	return r.addNonVoterReplica(ctx, target, r.Desc(), ReasonAdminRequest, "")
+}
+
+// ClosedTimestamp returns the closed timestamp the replica has applied.
+func (r *Replica) ClosedTimestamp() hlc.Timestamp {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mu.closedTimestamp
+}
+
 func GetGCQueueTxnCleanupThreshold() time.Duration {
 	return txnCleanupThreshold
 }
@@ -203,11 +203,26 @@ func (ir *intentResolver) maybePushTransactions(
 			PushType: pushType,
 		})
 	}
-	b := &client.Batch{}
-	b.AddRawRequest(pushReqs...)
+	var b *client.Batch
 	var pErr *roachpb.Error
-	if err := ir.store.db.Run(ctx, b); err != nil {
-		pErr = b.MustPErr()
+	for {
+		b = &client.Batch{}
+		b.AddRawRequest(pushReqs...)
+		pErr = nil
+		if err := ir.store.db.Run(ctx, b); err != nil {
+			pErr = b.MustPErr()
+		}
+		icErr, ok := pErr.GetDetail().(*roachpb.IndeterminateCommitError)
+		if !ok {
+			break
+		}
+		// One of the pushees was committing in parallel when its
+		// coordinator died. Recover it and push again, which will find
+		// it finalized.
+		if _, err := recoverStagingTxn(ctx, ir.store.db, icErr.StagingTxn); err != nil {
+			pErr = roachpb.NewError(err)
+			break
+		}
 	}
 	ir.mu.Lock()
 	for _, intent := range pushIntents {
@@ -244,6 +259,51 @@ func (ir *intentResolver) maybePushTransactions(
 	return resolveIntents, nil
 }
 
+// recoverStagingTxn determines the outcome of a STAGING transaction
+// whose coordinator has died and finalizes its transaction record
+// accordingly. Each of the transaction's in-flight writes is queried
+// at the staged timestamp; since the query updates the timestamp
+// cache, a write that is still in flight can no longer succeed at or
+// below that timestamp. The transaction is committed if all of the
+// writes are found and aborted otherwise. Returns the recovered
+// transaction record, which may have been finalized by its
+// coordinator in the meantime.
+func recoverStagingTxn(
+	ctx context.Context, db *client.DB, txn roachpb.Transaction,
+) (roachpb.Transaction, error) {
+	log.VEventf(ctx, 1, "recovering STAGING txn %s", txn.ID.Short())
+	implicitlyCommitted := true
+	for _, w := range txn.InFlightWrites {
+		meta := txn.TxnMeta
+		meta.Sequence = w.Sequence
+		b := &client.Batch{}
+		b.Header.Timestamp = txn.Timestamp
+		b.AddRawRequest(&roachpb.QueryIntentRequest{
+			Span: roachpb.Span{Key: w.Key},
+			Txn:  meta,
+		})
+		if err := db.Run(ctx, b); err != nil {
+			return roachpb.Transaction{}, err
+		}
+		resp := b.RawResponse().Responses[0].GetInner().(*roachpb.QueryIntentResponse)
+		if !resp.FoundIntent {
+			implicitlyCommitted = false
+			break
+		}
+	}
+
+	b := &client.Batch{}
+	b.AddRawRequest(&roachpb.RecoverTxnRequest{
+		Span:                roachpb.Span{Key: txn.Key},
+		Txn:                 txn.TxnMeta,
+		ImplicitlyCommitted: implicitlyCommitted,
+	})
+	if err := db.Run(ctx, b); err != nil {
+		return roachpb.Transaction{}, err
+	}
+	return b.RawResponse().Responses[0].GetInner().(*roachpb.RecoverTxnResponse).RecoveredTxn, nil
+}
+
 // processIntentsAsync asynchronously processes intents which were
 // encountered during another command but did not interfere with the
 // execution of that command. This occurs in two cases: inconsistent
@@ -40,6 +40,8 @@ const (
 	ReasonStoreDecommissioning RangeLogEventReason = "store decommissioning"
 	ReasonRebalance            RangeLogEventReason = "rebalance"
 	ReasonAdminRequest         RangeLogEventReason = "admin request"
+	ReasonAbandonedLearner     RangeLogEventReason = "abandoned learner replica"
+	ReasonReplicaInconsistent  RangeLogEventReason = "replica inconsistent"
 )
 
 func (s *Store) insertRangeLogEvent(
@@ -73,6 +73,17 @@ var (
 		Name: "replicas.commandqueue.combinedreadcount",
 		Help: "Number of read-only commands in all CommandQueues combined"}
 
+	// Replica push txn queue metrics.
+	metaPushTxnQueuePusheeWaiting = metric.Metadata{
+		Name: "txnwaitqueue.pushee.waiting",
+		Help: "Number of pushees with pushers waiting on them in all push txn queues"}
+	metaPushTxnQueuePusherWaiting = metric.Metadata{
+		Name: "txnwaitqueue.pusher.waiting",
+		Help: "Number of pushers waiting in all push txn queues"}
+	metaPushTxnQueueDeadlocks = metric.Metadata{
+		Name: "txnwaitqueue.deadlocks_total",
+		Help: "Number of deadlocks broken by the push txn queues"}
+
 	// Range metrics.
 	metaRangeCount = metric.Metadata{
 		Name: "ranges",
@@ -208,6 +219,12 @@ var (
 	metaRdbNumSSTables = metric.Metadata{
 		Name: "rocksdb.num-sstables",
 		Help: "Number of rocksdb SSTables"}
+	metaRdbL0FileCount = metric.Metadata{
+		Name: "rocksdb.l0-sstables",
+		Help: "Number of rocksdb SSTables in level 0"}
+	metaRdbPendingCompaction = metric.Metadata{
+		Name: "rocksdb.estimated-pending-compaction",
+		Help: "Estimated pending compaction bytes"}
 
 	// Range event metrics.
 	metaRangeSplits = metric.Metadata{
@@ -494,6 +511,11 @@ type StoreMetrics struct {
 	CombinedCommandWriteCount *metric.Gauge
 	CombinedCommandReadCount  *metric.Gauge
 
+	// Replica push txn queue metrics.
+	PushTxnQueuePusheeWaiting *metric.Gauge
+	PushTxnQueuePusherWaiting *metric.Gauge
+	PushTxnQueueDeadlocks     *metric.Counter
+
 	// Range metrics.
 	RangeCount                *metric.Gauge
 	UnavailableRangeCount     *metric.Gauge
@@ -547,6 +569,8 @@ type StoreMetrics struct {
 	RdbTableReadersMemEstimate  *metric.Gauge
 	RdbReadAmplification        *metric.Gauge
 	RdbNumSSTables              *metric.Gauge
+	RdbL0FileCount              *metric.Gauge
+	RdbPendingCompaction        *metric.Gauge
 
 	// TODO(mrtracy): This should be removed as part of #4465. This is only
 	// maintained to keep the current structure of StatusSummaries; it would be
@@ -689,6 +713,11 @@ func newStoreMetrics(histogramWindow time.Duration) *StoreMetrics {
 		CombinedCommandWriteCount: metric.NewGauge(metaCombinedCommandWriteCount),
 		CombinedCommandReadCount:  metric.NewGauge(metaCombinedCommandReadCount),
 
+		// Replica push txn queue metrics.
+		PushTxnQueuePusheeWaiting: metric.NewGauge(metaPushTxnQueuePusheeWaiting),
+		PushTxnQueuePusherWaiting: metric.NewGauge(metaPushTxnQueuePusherWaiting),
+		PushTxnQueueDeadlocks:     metric.NewCounter(metaPushTxnQueueDeadlocks),
+
 		// Range metrics.
 		RangeCount:                metric.NewGauge(metaRangeCount),
 		UnavailableRangeCount:     metric.NewGauge(metaUnavailableRangeCount),
@@ -740,6 +769,8 @@ func newStoreMetrics(histogramWindow time.Duration) *StoreMetrics {
 		RdbTableReadersMemEstimate:  metric.NewGauge(metaRdbTableReadersMemEstimate),
 		RdbReadAmplification:        metric.NewGauge(metaRdbReadAmplification),
 		RdbNumSSTables:              metric.NewGauge(metaRdbNumSSTables),
+		RdbL0FileCount:              metric.NewGauge(metaRdbL0FileCount),
+		RdbPendingCompaction:        metric.NewGauge(metaRdbPendingCompaction),
 
 		// Range event metrics.
 		RangeSplits:                     metric.NewCounter(metaRangeSplits),
@@ -916,6 +947,8 @@ func (sm *StoreMetrics) updateRocksDBStats(stats engine.Stats) {
 	sm.RdbFlushes.Update(stats.Flushes)
 	sm.RdbCompactions.Update(stats.Compactions)
 	sm.RdbTableReadersMemEstimate.Update(stats.TableReadersMemEstimate)
+	sm.RdbL0FileCount.Update(stats.L0FileCount)
+	sm.RdbPendingCompaction.Update(stats.PendingCompactionBytesEstimate)
 }
 
 func (sm *StoreMetrics) leaseRequestComplete(success bool) {
@@ -52,9 +52,12 @@ func shouldPushImmediately(req *roachpb.PushTxnRequest) bool {
 
 // isPushed returns whether the PushTxn request has already been
 // fulfilled by the current transaction state. This may be true
-// for transactions with pushed timestamps.
+// for transactions with pushed timestamps. A STAGING transaction
+// is not considered pushed: waiters keep waiting until it is
+// finalized or, if its coordinator dies, until it expires and
+// can be recovered.
 func isPushed(req *roachpb.PushTxnRequest, txn *roachpb.Transaction) bool {
-	return (txn.Status != roachpb.PENDING ||
+	return (txn.Status.IsFinalized() ||
 		(req.PushType == roachpb.PUSH_TIMESTAMP && req.PushTo.Less(txn.Timestamp)))
 }
 
@@ -73,17 +76,36 @@ func createPushTxnResponse(txn *roachpb.Transaction) *roachpb.PushTxnResponse {
 	return &roachpb.PushTxnResponse{PusheeTxn: txn.Clone()}
 }
 
+// waitsFor identifies an edge of the waits-for graph.
+type waitsFor struct {
+	waiter, waitee uuid.UUID
+}
+
 // A waitingPush represents a PushTxn command that is waiting on the
 // pushee transaction to commit or abort. It maintains a transitive
-// set of all txns which are waiting on this txn in order to detect
-// dependency cycles.
+// set of all txns which are waiting on this txn, along with the edges
+// of the waits-for graph between them, in order to detect dependency
+// cycles.
 type waitingPush struct {
 	req *roachpb.PushTxnRequest
+	// start is the time at which the push started waiting.
+	start time.Time
 	// pending channel receives updated, pushed txn or nil if queue is cleared.
 	pending chan *roachpb.Transaction
 	mu      struct {
 		syncutil.Mutex
 		dependents map[uuid.UUID]struct{} // transitive set of txns waiting on this txn
+		edges      map[waitsFor]int32     // waits-for edges among dependents, to waiter priority
+		// pusherPriority is the highest known priority of the pusher.
+		pusherPriority int32
+	}
+}
+
+// addEdge records the edge, keeping the highest known waiter priority.
+// Priorities only ever increase, so the highest one is the most recent.
+func addEdge(edges map[waitsFor]int32, e waitsFor, priority int32) {
+	if p, ok := edges[e]; !ok || p < priority {
+		edges[e] = priority
 	}
 }
 
@@ -105,6 +127,32 @@ func (pt *pendingTxn) getTxn() *roachpb.Transaction {
 	return pt.txn.Load().(*roachpb.Transaction)
 }
 
+// getWaitsForEdges returns the edges of the waits-for graph leading to
+// the pending txn, both direct and transitive.
+func (pt *pendingTxn) getWaitsForEdges() []roachpb.WaitsForEdge {
+	txnID := pt.getTxn().ID
+	edges := map[waitsFor]int32{}
+	for _, push := range pt.waitingPushes {
+		if id := push.req.PusherTxn.ID; id != (uuid.UUID{}) {
+			push.mu.Lock()
+			addEdge(edges, waitsFor{waiter: id, waitee: txnID}, push.mu.pusherPriority)
+			for e, p := range push.mu.edges {
+				addEdge(edges, e, p)
+			}
+			push.mu.Unlock()
+		}
+	}
+	result := make([]roachpb.WaitsForEdge, 0, len(edges))
+	for e, p := range edges {
+		result = append(result, roachpb.WaitsForEdge{
+			WaiterID:       e.waiter,
+			WaiteeID:       e.waitee,
+			WaiterPriority: p,
+		})
+	}
+	return result
+}
+
 func (pt *pendingTxn) getDependentsSet() map[uuid.UUID]struct{} {
 	set := map[uuid.UUID]struct{}{}
 	for _, push := range pt.waitingPushes {
@@ -303,6 +351,67 @@ func (ptq *pushTxnQueue) GetDependents(txnID uuid.UUID) []uuid.UUID {
 	return nil
 }
 
+// GetWaitsForEdges returns the edges of the waits-for graph between
+// the transactions waiting on the specified txn either directly or
+// indirectly.
+func (ptq *pushTxnQueue) GetWaitsForEdges(txnID uuid.UUID) []roachpb.WaitsForEdge {
+	ptq.mu.Lock()
+	defer ptq.mu.Unlock()
+	if ptq.mu.txns == nil {
+		// Not enabled; do nothing.
+		return nil
+	}
+	if pending, ok := ptq.mu.txns[txnID]; ok {
+		return pending.getWaitsForEdges()
+	}
+	return nil
+}
+
+// TxnWait describes a PushTxn request waiting in a replica's push txn
+// queue for the pushee transaction to finish.
+type TxnWait struct {
+	RangeID   roachpb.RangeID
+	PusheeID  uuid.UUID
+	PusheeKey roachpb.Key
+	// PusherID is unset for non-transactional pushers.
+	PusherID uuid.UUID
+	PushType roachpb.PushTxnType
+	Start    time.Time
+}
+
+// Waits returns the PushTxn requests currently waiting in the queue.
+func (ptq *pushTxnQueue) Waits(rangeID roachpb.RangeID) []TxnWait {
+	ptq.mu.Lock()
+	defer ptq.mu.Unlock()
+	var waits []TxnWait
+	for txnID, pending := range ptq.mu.txns {
+		for _, push := range pending.waitingPushes {
+			waits = append(waits, TxnWait{
+				RangeID:   rangeID,
+				PusheeID:  txnID,
+				PusheeKey: push.req.PusheeTxn.Key,
+				PusherID:  push.req.PusherTxn.ID,
+				PushType:  push.req.PushType,
+				Start:     push.start,
+			})
+		}
+	}
+	return waits
+}
+
+// removeWaitingPush removes the push from the pending txn's waiters
+// once it stops waiting, unless the queue already did so.
+func (ptq *pushTxnQueue) removeWaitingPush(pending *pendingTxn, push *waitingPush) {
+	ptq.mu.Lock()
+	defer ptq.mu.Unlock()
+	for i, w := range pending.waitingPushes {
+		if w == push {
+			pending.waitingPushes = append(pending.waitingPushes[:i], pending.waitingPushes[i+1:]...)
+			return
+		}
+	}
+}
+
 // isTxnUpdated returns whether the transaction specified in
 // the QueryTxnRequest has had its status or priority updated
 // or whether the known set of dependent transactions has
@@ -310,7 +419,7 @@ func (ptq *pushTxnQueue) GetDependents(txnID uuid.UUID) []uuid.UUID {
 func (ptq *pushTxnQueue) isTxnUpdated(pending *pendingTxn, req *roachpb.QueryTxnRequest) bool {
 	// First check whether txn status or priority has changed.
 	txn := pending.getTxn()
-	if txn.Status != roachpb.PENDING || txn.Priority > req.Txn.Priority {
+	if txn.Status.IsFinalized() || txn.Priority > req.Txn.Priority {
 		return true
 	}
 	// Next, see if there is any discrepancy in the set of known dependents.
@@ -344,6 +453,66 @@ func (ptq *pushTxnQueue) clearWaitingQueriesLocked(ctx context.Context, txnID uu
 
 var errDeadlock = roachpb.NewErrorf("deadlock detected")
 
+// findDeadlockVictim determines whether the pusher waiting on the pushee
+// closes a cycle in the waits-for graph described by the supplied edges
+// and, if so, returns the transaction which must be aborted to break it.
+// Every member of a cycle picks the same victim: the transaction with the
+// lowest priority, with ties broken in favor of the lowest ID. This
+// ensures that exactly one transaction is aborted per deadlock.
+func findDeadlockVictim(
+	pusherID uuid.UUID,
+	pusherPriority int32,
+	pusheeID uuid.UUID,
+	pusheePriority int32,
+	edges []roachpb.WaitsForEdge,
+) (uuid.UUID, bool) {
+	priorities := map[uuid.UUID]int32{}
+	raise := func(id uuid.UUID, p int32) {
+		if cur, ok := priorities[id]; !ok || cur < p {
+			priorities[id] = p
+		}
+	}
+	waitees := map[uuid.UUID][]uuid.UUID{}
+	for _, e := range edges {
+		waitees[e.WaiterID] = append(waitees[e.WaiterID], e.WaiteeID)
+		raise(e.WaiterID, e.WaiterPriority)
+	}
+	raise(pusherID, pusherPriority)
+	raise(pusheeID, pusheePriority)
+
+	// Search for a path from the pushee back to the pusher.
+	parents := map[uuid.UUID]uuid.UUID{pusheeID: pusheeID}
+	queue := []uuid.UUID{pusheeID}
+	for len(queue) > 0 && !isDeadlockMember(parents, pusherID) {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range waitees[cur] {
+			if _, ok := parents[next]; !ok {
+				parents[next] = cur
+				queue = append(queue, next)
+			}
+		}
+	}
+	if !isDeadlockMember(parents, pusherID) {
+		return uuid.UUID{}, false
+	}
+
+	victim := pusherID
+	for id := pusherID; id != pusheeID; {
+		id = parents[id]
+		p1, p2 := priorities[id], priorities[victim]
+		if p1 < p2 || (p1 == p2 && bytes.Compare(id.GetBytes(), victim.GetBytes()) < 0) {
+			victim = id
+		}
+	}
+	return victim, true
+}
+
+func isDeadlockMember(parents map[uuid.UUID]uuid.UUID, txnID uuid.UUID) bool {
+	_, ok := parents[txnID]
+	return ok
+}
+
 // MaybeWaitForPush checks whether there is a queue already
 // established for pushing the transaction. If not, or if the PushTxn
 // request isn't queueable, return immediately. If there is a queue,
@@ -387,8 +556,10 @@ func (ptq *pushTxnQueue) MaybeWaitForPush(
 
 	push := &waitingPush{
 		req:     req,
+		start:   timeutil.Now(),
 		pending: make(chan *roachpb.Transaction, 1),
 	}
+	push.mu.pusherPriority = req.PusherTxn.Priority
 	pending.waitingPushes = append(pending.waitingPushes, push)
 	// Because we're adding another dependent on the pending
 	// transaction, send on the waiting queries' channel to
@@ -409,6 +580,7 @@ func (ptq *pushTxnQueue) MaybeWaitForPush(
 		log.VEventf(ctx, 2, "pushing %s (%d pending)", req.PusheeTxn.ID.Short(), len(pending.waitingPushes))
 	}
 	ptq.mu.Unlock()
+	defer ptq.removeWaitingPush(pending, push)
 
 	// Wait for any updates to the pusher txn to be notified when
 	// status, priority, or dependents (for deadlock detection) have
@@ -474,7 +646,7 @@ func (ptq *pushTxnQueue) MaybeWaitForPush(
 			log.Event(ctx, "querying pushee")
 			pusheeTxnTimer.Read = true
 			// Periodically check whether the pushee txn has been abandoned.
-			updatedPushee, _, pErr := ptq.queryTxnStatus(
+			updatedPushee, _, _, pErr := ptq.queryTxnStatus(
 				ctx, req.PusheeTxn, false, nil, ptq.store.Clock().Now(),
 			)
 			if pErr != nil {
@@ -487,7 +659,11 @@ func (ptq *pushTxnQueue) MaybeWaitForPush(
 			pusheePriority = updatedPushee.Priority
 			pending.txn.Store(updatedPushee)
 			if isExpired(ptq.store.Clock().Now(), updatedPushee) {
-				log.VEventf(ctx, 1, "pushing expired txn %s", req.PusheeTxn.ID.Short())
+				// An expired STAGING pushee fails the push with an
+				// IndeterminateCommitError, which the pusher's intent
+				// resolver handles by recovering the transaction.
+				log.VEventf(ctx, 1, "pushing expired %s txn %s",
+					updatedPushee.Status, req.PusheeTxn.ID.Short())
 				return nil, nil
 			}
 
@@ -505,11 +681,22 @@ func (ptq *pushTxnQueue) MaybeWaitForPush(
 
 			// Check for dependency cycle to find and break deadlocks.
 			push.mu.Lock()
+			if pusherPriority > push.mu.pusherPriority {
+				push.mu.pusherPriority = pusherPriority
+			}
 			_, haveDependency := push.mu.dependents[req.PusheeTxn.ID]
 			dependents := make([]string, 0, len(push.mu.dependents))
 			for id := range push.mu.dependents {
 				dependents = append(dependents, id.Short())
 			}
+			edges := make([]roachpb.WaitsForEdge, 0, len(push.mu.edges))
+			for e, p := range push.mu.edges {
+				edges = append(edges, roachpb.WaitsForEdge{
+					WaiterID:       e.waiter,
+					WaiteeID:       e.waitee,
+					WaiterPriority: p,
+				})
+			}
 			log.VEventf(
 				ctx,
 				2,
@@ -529,9 +716,13 @@ func (ptq *pushTxnQueue) MaybeWaitForPush(
 			ptq.mu.Unlock()
 
 			if haveDependency {
-				// Break the deadlock if the pusher has higher priority.
-				p1, p2 := pusheePriority, pusherPriority
-				if p1 < p2 || (p1 == p2 && bytes.Compare(req.PusheeTxn.ID.GetBytes(), req.PusherTxn.ID.GetBytes()) < 0) {
+				// Break the deadlock if the pushee is the victim of the
+				// cycle. The other members of the cycle keep waiting for it
+				// to be aborted.
+				victim, ok := findDeadlockVictim(
+					req.PusherTxn.ID, pusherPriority, req.PusheeTxn.ID, pusheePriority, edges,
+				)
+				if ok && victim == req.PusheeTxn.ID {
 					if log.V(1) {
 						log.Infof(
 							ctx,
@@ -541,8 +732,8 @@ func (ptq *pushTxnQueue) MaybeWaitForPush(
 							dependents,
 						)
 					}
+					ptq.store.metrics.PushTxnQueueDeadlocks.Inc(1)
 					return nil, errDeadlock
-
 				}
 			}
 			// Signal the pusher query txn loop to continue.
@@ -654,7 +845,8 @@ func (ptq *pushTxnQueue) startQueryPusherTxn(
 			for r := retry.StartWithCtx(ctx, base.DefaultRetryOptions()); r.Next(); {
 				var pErr *roachpb.Error
 				var updatedPusher *roachpb.Transaction
-				updatedPusher, waitingTxns, pErr = ptq.queryTxnStatus(
+				var edges []roachpb.WaitsForEdge
+				updatedPusher, waitingTxns, edges, pErr = ptq.queryTxnStatus(
 					ctx, pusher.TxnMeta, true, waitingTxns, ptq.store.Clock().Now(),
 				)
 				if pErr != nil {
@@ -677,6 +869,12 @@ func (ptq *pushTxnQueue) startQueryPusherTxn(
 				for _, txnID := range waitingTxns {
 					push.mu.dependents[txnID] = struct{}{}
 				}
+				if push.mu.edges == nil {
+					push.mu.edges = map[waitsFor]int32{}
+				}
+				for _, e := range edges {
+					addEdge(push.mu.edges, waitsFor{waiter: e.WaiterID, waitee: e.WaiteeID}, e.WaiterPriority)
+				}
 				push.mu.Unlock()
 
 				// Send an update of the pusher txn.
@@ -709,14 +907,15 @@ func (ptq *pushTxnQueue) startQueryPusherTxn(
 // information about their own txns.
 //
 // Returns the updated transaction (or nil if not updated) as well as
-// the list of transactions which are waiting on the updated txn.
+// the list of transactions which are waiting on the updated txn and
+// the edges of the waits-for graph between them.
 func (ptq *pushTxnQueue) queryTxnStatus(
 	ctx context.Context,
 	txnMeta enginepb.TxnMeta,
 	wait bool,
 	dependents []uuid.UUID,
 	now hlc.Timestamp,
-) (*roachpb.Transaction, []uuid.UUID, *roachpb.Error) {
+) (*roachpb.Transaction, []uuid.UUID, []roachpb.WaitsForEdge, *roachpb.Error) {
 	b := &client.Batch{}
 	b.AddRawRequest(&roachpb.QueryTxnRequest{
 		Span: roachpb.Span{
@@ -748,13 +947,13 @@ func (ptq *pushTxnQueue) queryTxnStatus(
 		//
 		// so something is sketchy here, but it should all resolve nicely when we
 		// don't use store.db for these internal requests any more.
-		return nil, nil, roachpb.NewError(err)
+		return nil, nil, nil, roachpb.NewError(err)
 	}
 	br := b.RawResponse()
 	resp := br.Responses[0].GetInner().(*roachpb.QueryTxnResponse)
 	// ID can be nil if no BeginTransaction has been sent yet.
 	if updatedTxn := &resp.QueriedTxn; updatedTxn.ID != (uuid.UUID{}) {
-		return updatedTxn, resp.WaitingTxns, nil
+		return updatedTxn, resp.WaitingTxns, resp.WaitsFor, nil
 	}
-	return nil, nil, nil
+	return nil, nil, nil, nil
 }
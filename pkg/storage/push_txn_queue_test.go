@@ -696,3 +696,59 @@ func TestPushTxnQueueDependencyCycleWithPriorityInversion(t *testing.T) {
 	cancel()
 	<-retCh
 }
+
+// TestFindDeadlockVictim verifies that every member of a dependency
+// cycle chooses the same victim, so that exactly one pusher breaks the
+// deadlock.
+func TestFindDeadlockVictim(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	a, b, c, d := uuid.MakeV4(), uuid.MakeV4(), uuid.MakeV4(), uuid.MakeV4()
+	edge := func(waiter, waitee uuid.UUID, pri int32) roachpb.WaitsForEdge {
+		return roachpb.WaitsForEdge{WaiterID: waiter, WaiteeID: waitee, WaiterPriority: pri}
+	}
+
+	// The cycle a -> b -> c -> a, where b has the lowest priority, and d
+	// waits on a without being part of the cycle.
+	pri := map[uuid.UUID]int32{a: 5, b: 1, c: 3, d: 0}
+	testCases := []struct {
+		pusher, pushee uuid.UUID
+		edges          []roachpb.WaitsForEdge // edges leading to the pusher
+	}{
+		{a, b, []roachpb.WaitsForEdge{edge(c, a, pri[c]), edge(b, c, pri[b]), edge(d, a, pri[d])}},
+		{b, c, []roachpb.WaitsForEdge{edge(a, b, pri[a]), edge(c, a, pri[c]), edge(d, a, pri[d])}},
+		{c, a, []roachpb.WaitsForEdge{edge(b, c, pri[b]), edge(a, b, pri[a]), edge(d, a, pri[d])}},
+	}
+	var breakers int
+	for i, tc := range testCases {
+		victim, ok := findDeadlockVictim(tc.pusher, pri[tc.pusher], tc.pushee, pri[tc.pushee], tc.edges)
+		if !ok {
+			t.Fatalf("%d: expected a cycle", i)
+		}
+		if victim != b {
+			t.Errorf("%d: expected victim %s; got %s", i, b.Short(), victim.Short())
+		}
+		if victim == tc.pushee {
+			breakers++
+		}
+	}
+	if breakers != 1 {
+		t.Errorf("expected exactly one pusher to break the deadlock; got %d", breakers)
+	}
+
+	// d waits on a, but a doesn't wait on d: no cycle.
+	if _, ok := findDeadlockVictim(
+		a, pri[a], d, pri[d], []roachpb.WaitsForEdge{edge(c, a, pri[c])},
+	); ok {
+		t.Errorf("unexpected cycle")
+	}
+
+	// With equal priorities, the lowest ID is the victim.
+	expVictim := a
+	if bytes.Compare(b.GetBytes(), a.GetBytes()) < 0 {
+		expVictim = b
+	}
+	victim, ok := findDeadlockVictim(a, 2, b, 2, []roachpb.WaitsForEdge{edge(b, a, 2)})
+	if !ok || victim != expVictim {
+		t.Errorf("expected victim %s; got %s (cycle=%t)", expVictim.Short(), victim.Short(), ok)
+	}
+}
@@ -179,6 +179,15 @@ func computeTruncatableIndex(
 			truncatableIndex = pendingSnapshotIndex
 		}
 	}
+	// Learners aren't part of the quorum, but they're about to become voters
+	// and a learner which is being caught up through the log must not have it
+	// truncated out from under it regardless of the size of the log. A learner
+	// which hasn't acknowledged anything yet needs a snapshot anyway.
+	for _, progress := range raftStatus.Progress {
+		if progress.IsLearner && progress.Match > 0 && truncatableIndex > progress.Match {
+			truncatableIndex = progress.Match
+		}
+	}
 
 	if truncatableIndex < firstIndex {
 		truncatableIndex = firstIndex
@@ -213,6 +222,10 @@ func computeTruncatableIndex(
 func getQuorumIndex(raftStatus *raft.Status, pendingSnapshotIndex uint64) uint64 {
 	match := make([]uint64, 0, len(raftStatus.Progress)+1)
 	for _, progress := range raftStatus.Progress {
+		// Learners aren't part of the quorum.
+		if progress.IsLearner {
+			continue
+		}
 		match = append(match, progress.Match)
 	}
 	if pendingSnapshotIndex != 0 {
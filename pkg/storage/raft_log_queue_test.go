@@ -91,6 +91,24 @@ func TestGetQuorumIndex(t *testing.T) {
 	}
 }
 
+func TestGetQuorumIndexIgnoresLearners(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	status := &raft.Status{
+		Progress: map[uint64]raft.Progress{
+			1: {Match: 5},
+			2: {Match: 4},
+			3: {Match: 1},
+			// A learner which is still being caught up doesn't hold back the
+			// quorum index.
+			4: {Match: 0, IsLearner: true},
+		},
+	}
+	if a, e := getQuorumIndex(status, 0), uint64(4); a != e {
+		t.Fatalf("expected %d, but got %d", e, a)
+	}
+}
+
 func TestComputeTruncatableIndex(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -136,6 +154,30 @@ func TestComputeTruncatableIndex(t *testing.T) {
 			t.Errorf("%d: computeTruncatableIndex(...) expected %d, but got %d", i, c.expected, out)
 		}
 	}
+
+	// A learner which is being caught up through the log holds back the
+	// truncation even though the log is over the target size and it isn't
+	// part of the quorum. One which still needs a snapshot doesn't.
+	for i, c := range []struct {
+		learnerMatch uint64
+		expected     uint64
+	}{
+		{2, 2},
+		{0, 4},
+	} {
+		status := &raft.Status{
+			Progress: map[uint64]raft.Progress{
+				1: {Match: 5},
+				2: {Match: 4},
+				3: {Match: 1},
+				4: {Match: c.learnerMatch, IsLearner: true},
+			},
+		}
+		out := computeTruncatableIndex(status, 2000, targetSize, 1, 5, 0)
+		if c.expected != out {
+			t.Errorf("learner %d: computeTruncatableIndex(...) expected %d, but got %d", i, c.expected, out)
+		}
+	}
 }
 
 // TestGetTruncatableIndexes verifies that old raft log entries are correctly
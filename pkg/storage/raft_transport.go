@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -607,7 +608,9 @@ func (t *RaftTransport) SendAsync(req *RaftMessageRequest) bool {
 }
 
 // SendSnapshot streams the given outgoing snapshot. The caller is responsible
-// for closing the OutgoingSnapshot.
+// for closing the OutgoingSnapshot. If the snapshot is sent as SSTable chunks
+// and the stream fails while they're being sent, the snapshot is resumed
+// through a new stream.
 func (t *RaftTransport) SendSnapshot(
 	ctx context.Context,
 	storePool *StorePool,
@@ -615,6 +618,27 @@ func (t *RaftTransport) SendSnapshot(
 	snap *OutgoingSnapshot,
 	newBatch func() engine.Batch,
 	sent func(),
+) error {
+	var sentOnce sync.Once
+	for retry := 0; ; retry++ {
+		err := t.sendSnapshotOnce(ctx, storePool, header, snap, newBatch, func() {
+			sentOnce.Do(sent)
+		})
+		if _, ok := err.(*snapshotStreamError); !ok || retry >= snapshotStreamRetries {
+			return err
+		}
+		log.Infof(ctx, "resuming snapshot %s after stream error: %s", snap.SnapUUID.Short(), err)
+		snap.resetIter()
+	}
+}
+
+func (t *RaftTransport) sendSnapshotOnce(
+	ctx context.Context,
+	storePool *StorePool,
+	header SnapshotRequest_Header,
+	snap *OutgoingSnapshot,
+	newBatch func() engine.Batch,
+	sent func(),
 ) error {
 	var stream MultiRaft_RaftSnapshotClient
 	nodeID := header.RaftMessageRequest.ToReplica.NodeID
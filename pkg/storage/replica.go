@@ -131,6 +131,11 @@ const (
 	// have succeeded, an AmbiguousResultError must be returned. The
 	// command should not be retried.
 	proposalRangeNoLongerExists
+	// proposalBelowClosedTimestamp indicates the proposal was not submitted
+	// because it writes at or below a timestamp closed while it was being
+	// evaluated. The command should be retried, which moves it above the
+	// closed timestamp.
+	proposalBelowClosedTimestamp
 )
 
 // proposalResult indicates the result of a proposal. Exactly one of
@@ -212,6 +217,9 @@ type Replica struct {
 	// writeStats tracks the number of keys written by applied raft commands
 	// in order to aid in replica rebalancing decisions.
 	writeStats *replicaStats
+	// writeBytesStats tracks the number of bytes written by applied raft
+	// commands in order to aid in load-based rebalancing decisions.
+	writeBytesStats *replicaStats
 
 	// creatingReplica is set when a replica is created as uninitialized
 	// via a raft message.
@@ -259,6 +267,11 @@ type Replica struct {
 		queues [numSpanScope]*CommandQueue
 	}
 
+	// rangeFeedMu holds the range feeds registered with the replica.
+	//
+	// Locking notes: Replica.raftMu < Replica.rangeFeedMu
+	rangeFeedMu rangeFeedState
+
 	mu struct {
 		// Protects all fields in the mu struct.
 		syncutil.RWMutex
@@ -285,6 +298,16 @@ type Replica struct {
 		// the follower to estimate the number of Raft log entries it is
 		// behind. This field is only valid when the Replica is a follower.
 		estimatedCommitIndex uint64
+		// closedTimestamp is the highest closed timestamp carried by a Raft
+		// command applied by this replica (see RaftCommand.ClosedTimestamp). The
+		// replica contains all the writes at or below it, which lets it serve
+		// follower reads (see canServeFollowerRead). It isn't persisted; a
+		// restarted replica waits for the next closed timestamp.
+		closedTimestamp hlc.Timestamp
+		// proposedClosedTimestamp is the highest closed timestamp this replica
+		// has proposed while holding the lease. Together with closedTimestamp,
+		// it determines which writes the replica may still propose.
+		proposedClosedTimestamp hlc.Timestamp
 		// The raft log index of a pending preemptive snapshot. Used to prohibit
 		// raft log truncation while a preemptive snapshot is in flight. A value of
 		// 0 indicates that there is no pending snapshot.
@@ -387,6 +410,10 @@ type Replica struct {
 		submitProposalFn func(*ProposalData) error
 		// Computed checksum at a snapshot UUID.
 		checksums map[uuid.UUID]replicaChecksum
+		// pendingLearners are the learners being added to the range by calls
+		// to addLearnerReplica on this replica which haven't finished yet. The
+		// replicate queue leaves them alone (see abandonedLearners).
+		pendingLearners map[roachpb.ReplicaID]struct{}
 
 		// proposalQuota is the quota pool maintained by the lease holder where
 		// incoming writes acquire quota from a fixed quota pool before going
@@ -562,6 +589,7 @@ func newReplica(rangeID roachpb.RangeID, store *Store) *Replica {
 		r.leaseholderStats = newReplicaStats(store.Clock(), store.cfg.StorePool.getNodeLocalityString)
 	}
 	r.writeStats = newReplicaStats(store.Clock(), nil)
+	r.writeBytesStats = newReplicaStats(store.Clock(), nil)
 
 	// Init rangeStr with the range ID.
 	r.rangeStr.store(0, &roachpb.RangeDescriptor{RangeID: rangeID})
@@ -728,6 +756,14 @@ func (r *Replica) destroyDataRaftMuLocked(
 			return err
 		}
 	}
+	// If we crash before the entries in the separate raft log are discarded,
+	// the store discards them when it restarts (see Store.cleanupRaftLog).
+	if raftLog := r.store.Engine().RaftLog(); raftLog != nil {
+		if err := raftLog.Destroy(r.RangeID); err != nil {
+			return err
+		}
+	}
+	r.disconnectRangeFeeds(roachpb.NewError(roachpb.NewRangeNotFoundError(r.RangeID)))
 
 	log.Infof(ctx, "removed %d (%d+%d) keys in %0.0fms [clear=%0.0fms commit=%0.0fms]",
 		ms.KeyCount+ms.SysCount, ms.KeyCount, ms.SysCount,
@@ -802,6 +838,7 @@ func (r *Replica) setReplicaIDRaftMuLockedMuLocked(replicaID roachpb.ReplicaID)
 		var err error
 		if r.raftMu.sideloaded, err = newDiskSideloadStorage(
 			r.store.cfg.Settings, r.mu.state.Desc.RangeID, replicaID, r.store.Engine().GetAuxiliaryDir(),
+			r.store.Engine(),
 		); err != nil {
 			return errors.Wrap(err, "while initializing sideloaded storage")
 		}
@@ -2168,6 +2205,189 @@ func (r *Replica) removeCmdsFromCommandQueue(cmds batchCmdSet) {
 // batch is transactional, the txn timestamp and the txn.WriteTooOld
 // bool are updated.
 //
+// minWriteTimestamp returns the timestamp above which writes evaluated by
+// the leaseholder are forwarded (see applyTimestampCache) so that they remain
+// above the closed timestamp once they're proposed. The closed timestamp
+// trails the clock by kv.non_voter_reads.target_duration (see
+// maybeCloseTimestampLocked), which leaves half that duration for the
+// evaluation of the write. Returns the zero timestamp if the range has never
+// closed a timestamp and has no non-voters.
+func (r *Replica) minWriteTimestamp() hlc.Timestamp {
+	r.mu.RLock()
+	ts := r.mu.closedTimestamp
+	ts.Forward(r.mu.proposedClosedTimestamp)
+	hasNonVoters := len(r.mu.state.Desc.NonVoters()) > 0
+	r.mu.RUnlock()
+
+	target := r.store.cfg.Settings.NonVoterReadsTargetDuration.Get()
+	if target != 0 && hasNonVoters {
+		ts.Forward(r.store.Clock().Now().Add(-(target / 2).Nanoseconds(), 0))
+	}
+	return ts
+}
+
+// maybeCloseTimestampLocked is called by the leaseholder for each write
+// before it's proposed. It returns false if the write is at or below a
+// timestamp which the range has already closed, in which case it must not
+// be proposed. Otherwise, if the range has non-voters, it closes the
+// timestamp kv.non_voter_reads.target_duration in the past by attaching it to
+// the command.
+//
+// Writes are proposed in the order of their lease indexes, and a command
+// which fails its lease index check below Raft doesn't close its timestamp.
+// A replica which has applied a command closing a timestamp has thus applied
+// every write at or below it which will ever apply.
+func (r *Replica) maybeCloseTimestampLocked(proposal *ProposalData) bool {
+	closedTS := r.mu.closedTimestamp
+	closedTS.Forward(r.mu.proposedClosedTimestamp)
+	if writeTS, ok := closedTimestampWriteTimestamp(proposal.Request); ok && !closedTS.Less(writeTS) {
+		return false
+	}
+
+	target := r.store.cfg.Settings.NonVoterReadsTargetDuration.Get()
+	if target == 0 || len(r.mu.state.Desc.NonVoters()) == 0 ||
+		!r.store.cfg.Settings.Version.IsActive(cluster.VersionNonVoterReplicas) {
+		return true
+	}
+	newClosedTS := r.store.Clock().Now().Add(-target.Nanoseconds(), 0)
+	if closedTS.Less(newClosedTS) {
+		r.mu.proposedClosedTimestamp = newClosedTS
+		proposal.command.ClosedTimestamp = &newClosedTS
+	}
+	return true
+}
+
+// closedTimestampInterval returns how often the store checks whether its
+// leaseholder replicas need to close a timestamp without a write (see
+// maybeProposeClosedTimestamp). Returns zero if closed timestamps are
+// disabled.
+func closedTimestampInterval(st *cluster.Settings) time.Duration {
+	return st.NonVoterReadsTargetDuration.Get() / 4
+}
+
+// maybeProposeClosedTimestamp proposes an empty command closing a timestamp
+// if the local replica holds the lease of a range with non-voters whose
+// closed timestamp has fallen behind the target duration by more than
+// closedTimestampInterval. Without it, the closed timestamp of a range only
+// advances with its writes, and the non-voters of an idle range couldn't
+// serve reads more recent than its last write.
+func (r *Replica) maybeProposeClosedTimestamp(ctx context.Context) error {
+	interval := closedTimestampInterval(r.store.cfg.Settings)
+	if interval == 0 ||
+		!r.store.cfg.Settings.Version.IsActive(cluster.VersionNonVoterReplicas) {
+		return nil
+	}
+	now := r.store.Clock().Now()
+	if !r.ownsValidLease(now) {
+		return nil
+	}
+
+	r.raftMu.Lock()
+	defer r.raftMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.mu.destroyed; err != nil {
+		return err
+	}
+	desc := r.mu.state.Desc
+	if len(desc.NonVoters()) == 0 {
+		return nil
+	}
+	closedTS := r.mu.closedTimestamp
+	closedTS.Forward(r.mu.proposedClosedTimestamp)
+	target := r.store.cfg.Settings.NonVoterReadsTargetDuration.Get()
+	if !closedTS.Less(now.Add(-(target + interval).Nanoseconds(), 0)) {
+		return nil
+	}
+	repDesc, err := r.getReplicaDescriptorRLocked()
+	if err != nil {
+		return err
+	}
+
+	var ba roachpb.BatchRequest
+	ba.RangeID = r.RangeID
+	ba.Timestamp = now
+	proposal := &ProposalData{
+		ctx:     ctx,
+		idKey:   makeIDKey(),
+		doneCh:  make(chan proposalResult, 1),
+		Local:   &LocalEvalResult{Reply: &roachpb.BatchResponse{}},
+		Request: &ba,
+		command: storagebase.RaftCommand{
+			ReplicatedEvalResult: storagebase.ReplicatedEvalResult{
+				Timestamp: now,
+				StartKey:  desc.StartKey,
+				EndKey:    desc.EndKey,
+			},
+		},
+	}
+	r.maybeCloseTimestampLocked(proposal)
+	if proposal.command.ClosedTimestamp == nil {
+		return nil
+	}
+	r.insertProposalLocked(proposal, repDesc, *r.mu.state.Lease)
+	if err := r.submitProposalLocked(proposal); err != nil {
+		delete(r.mu.proposals, proposal.idKey)
+		return err
+	}
+	return nil
+}
+
+// closedTimestampWriteTimestamp returns the timestamp at which the batch
+// writes, if it contains any request which must not write at or below the
+// closed timestamp. These are the requests which consult the timestamp cache
+// (with the exception of BeginTransaction, which only writes the
+// transaction record), since applyTimestampCache is what forwards them above
+// the closed timestamp.
+func closedTimestampWriteTimestamp(ba *roachpb.BatchRequest) (hlc.Timestamp, bool) {
+	for _, union := range ba.Requests {
+		args := union.GetInner()
+		if _, ok := args.(*roachpb.BeginTransactionRequest); ok {
+			continue
+		}
+		if roachpb.ConsultsTimestampCache(args) {
+			if ba.Txn != nil {
+				return ba.Txn.Timestamp, true
+			}
+			return ba.Timestamp, true
+		}
+	}
+	return hlc.Timestamp{}, false
+}
+
+// canServeFollowerRead returns whether the batch can be served by this
+// replica without holding the range lease, which is the case for consistent
+// read-only batches sent to a non-voter whose timestamp (including the
+// uncertainty interval of a transaction) is at or below the closed timestamp
+// the replica has applied. No write can be proposed at or below that
+// timestamp anymore, and all those which were have been applied (see
+// maybeCloseTimestampLocked), so the read sees the same values as it would on
+// the leaseholder.
+//
+// Note that a non-voter which is partitioned from the rest of the range
+// doesn't learn about new closed timestamps, so it stops serving reads at
+// recent timestamps rather than serving stale ones.
+func (r *Replica) canServeFollowerRead(ba roachpb.BatchRequest) bool {
+	if ba.ReadConsistency != roachpb.CONSISTENT || !ba.IsReadOnly() {
+		return false
+	}
+	if !r.store.cfg.Settings.Version.IsActive(cluster.VersionNonVoterReplicas) {
+		return false
+	}
+	maxTS := ba.Timestamp
+	if ba.Txn != nil {
+		maxTS.Forward(ba.Txn.MaxTimestamp)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	repDesc, err := r.getReplicaDescriptorRLocked()
+	if err != nil || repDesc.GetType() != roachpb.NON_VOTER {
+		return false
+	}
+	return !r.mu.closedTimestamp.Less(maxTS)
+}
+
 // Two important invariants of Cockroach: 1) encountering a more
 // recently written value means transaction restart. 2) values must
 // be written with a greater timestamp than the most recent read to
@@ -2182,6 +2402,7 @@ func (r *Replica) applyTimestampCache(ba *roachpb.BatchRequest) (bool, *roachpb.
 	if err != nil {
 		return false, roachpb.NewError(err)
 	}
+	minWriteTS := r.minWriteTimestamp()
 
 	// TODO(peter): We only need to hold a write lock during the ExpandRequests
 	// calls. Investigate whether using a RWMutex here reduces lock contention.
@@ -2224,6 +2445,11 @@ func (r *Replica) applyTimestampCache(ba *roachpb.BatchRequest) (bool, *roachpb.
 
 			// Forward the timestamp if there's been a more recent read (by someone else).
 			rTS, rTxnID, _ := r.store.tsCacheMu.cache.GetMaxRead(header.Key, header.EndKey)
+			// Non-voters may serve reads at any timestamp up to the closed
+			// timestamp; treat it as a read by someone else.
+			if rTS.Forward(minWriteTS) {
+				rTxnID = nil
+			}
 			if ba.Txn != nil {
 				if rTxnID == nil || ba.Txn.ID != *rTxnID {
 					nextTS := rTS.Next()
@@ -2259,6 +2485,34 @@ func (r *Replica) applyTimestampCache(ba *roachpb.BatchRequest) (bool, *roachpb.
 	return bumped, nil
 }
 
+// checkProtectedTSReadAt returns an error if the batch contains a GC request
+// whose protected timestamp records were read before a job verified a newer
+// record against this range. Jobs read the range's protected timestamp check
+// key after committing their records, at a timestamp above the commit, and
+// GC requests declare that key, so the read and the GC request are ordered
+// by the command queue. If the read came first, the timestamp cache holds a
+// read timestamp above ProtectedTSReadAt, and the GC request may advance the
+// threshold past a record it never saw. If the GC request came first, the
+// job's subsequent read below the new threshold fails instead.
+func (r *Replica) checkProtectedTSReadAt(ba roachpb.BatchRequest) *roachpb.Error {
+	for _, union := range ba.Requests {
+		gcr, ok := union.GetInner().(*roachpb.GCRequest)
+		if !ok || gcr.ProtectedTSReadAt == (hlc.Timestamp{}) {
+			continue
+		}
+		key := keys.RangeProtectedTSCheckKey(r.Desc().StartKey)
+		r.store.tsCacheMu.Lock()
+		rTS, _, _ := r.store.tsCacheMu.cache.GetMaxRead(key, nil)
+		r.store.tsCacheMu.Unlock()
+		if gcr.ProtectedTSReadAt.Less(rTS) {
+			return roachpb.NewErrorf(
+				"protected timestamps read at %s were verified against the range at %s; "+
+					"GC must read them again", gcr.ProtectedTSReadAt, rTS)
+		}
+	}
+	return nil
+}
+
 // executeAdminBatch executes the command directly. There is no interaction
 // with the command queue or the timestamp cache, as admin commands
 // are not meant to consistently access or modify the underlying data.
@@ -2358,8 +2612,9 @@ func (r *Replica) executeAdminBatch(
 func (r *Replica) executeReadOnlyBatch(
 	ctx context.Context, ba roachpb.BatchRequest,
 ) (br *roachpb.BatchResponse, pErr *roachpb.Error) {
-	// If the read is consistent, the read requires the range lease.
-	if ba.ReadConsistency != roachpb.INCONSISTENT {
+	// If the read is consistent, the read requires the range lease, unless
+	// this is a non-voter which can serve it as a follower read.
+	if ba.ReadConsistency != roachpb.INCONSISTENT && !r.canServeFollowerRead(ba) {
 		if _, pErr = r.redirectOnOrAcquireLease(ctx); pErr != nil {
 			return nil, pErr
 		}
@@ -2447,7 +2702,7 @@ func (r *Replica) executeWriteBatch(
 	for count := 0; ; count++ {
 		br, pErr, retry := r.tryExecuteWriteBatch(ctx, ba)
 		switch retry {
-		case proposalIllegalLeaseIndex:
+		case proposalIllegalLeaseIndex, proposalBelowClosedTimestamp:
 			continue // retry
 		case proposalAmbiguousShouldBeReevaluated:
 			ambiguousResult = true
@@ -2591,6 +2846,10 @@ func (r *Replica) tryExecuteWriteBatch(
 
 	log.Event(ctx, "applied timestamp cache")
 
+	if pErr := r.checkProtectedTSReadAt(ba); pErr != nil {
+		return nil, pErr, proposalNoRetry
+	}
+
 	ch, tryAbandon, undoQuotaAcquisition, pErr := r.propose(ctx, lease, ba, endCmds, spans)
 	if pErr != nil {
 		return nil, pErr, proposalNoRetry
@@ -2940,12 +3199,41 @@ func (r *Replica) propose(
 	if err != nil {
 		return nil, nil, undoQuotaAcquisition, roachpb.NewError(err)
 	}
+
+	// With async consensus, the client is answered as soon as the command
+	// has been proposed and becomes responsible for proving that it was
+	// applied (see QueryIntentRequest). The command's context is detached
+	// from the client's, which is going away. Note that the command queue
+	// entries are still released only upon application, so overlapping
+	// requests continue to wait for the write.
+	var asyncReply *roachpb.BatchResponse
+	if ba.AsyncConsensus && proposal.Local.Err == nil && proposal.Local.Reply != nil {
+		asyncReply = protoutil.Clone(proposal.Local.Reply).(*roachpb.BatchResponse)
+		proposal.ctx = r.AnnotateCtx(context.TODO())
+		proposal.repliedAsync = true
+	}
+	if !ba.IsLeaseRequest() && !r.maybeCloseTimestampLocked(proposal) {
+		// The write was evaluated at a timestamp which has since been
+		// closed. Return its quota and have it reevaluated.
+		if r.mu.commandSizes != nil && r.mu.proposalQuota != nil {
+			delete(r.mu.commandSizes, proposal.idKey)
+			r.mu.proposalQuota.add(int64(proposal.command.Size()))
+		}
+		proposal.finishRaftApplication(proposalResult{ProposalRetry: proposalBelowClosedTimestamp})
+		return proposal.doneCh, func() bool { return false }, noop, nil
+	}
 	r.insertProposalLocked(proposal, repDesc, lease)
 
 	if err := r.submitProposalLocked(proposal); err != nil {
 		delete(r.mu.proposals, proposal.idKey)
 		return nil, nil, undoQuotaAcquisition, roachpb.NewError(err)
 	}
+	if asyncReply != nil {
+		ch := make(chan proposalResult, 1)
+		ch <- proposalResult{Reply: asyncReply}
+		close(ch)
+		return ch, func() bool { return false }, undoQuotaAcquisition, nil
+	}
 	// Must not use `proposal` in the closure below as a proposal which is not
 	// present in r.mu.proposals is no longer protected by the mutex. Abandoning
 	// a command only abandons the associated context. As soon as we propose a
@@ -3034,7 +3322,7 @@ func defaultSubmitProposalLocked(r *Replica, p *ProposalData) error {
 			r.unquiesceLocked()
 			return false, /* !unquiesceAndWakeLeader */
 				raftGroup.ProposeConfChange(raftpb.ConfChange{
-					Type:    changeTypeInternalToRaft[crt.ChangeType],
+					Type:    confChangeType(crt),
 					NodeID:  uint64(crt.Replica.ReplicaID),
 					Context: encodedCtx,
 				})
@@ -3260,7 +3548,7 @@ func (r *Replica) handleRaftReadyRaftMuLocked(
 			return stats, err
 		}
 		if lastIndex, lastTerm, raftLogSize, err = r.append(
-			ctx, writer, lastIndex, lastTerm, raftLogSize, thinEntries,
+			ctx, writer, r.store.Engine().RaftLog(), lastIndex, lastTerm, raftLogSize, thinEntries,
 		); err != nil {
 			return stats, err
 		}
@@ -4303,9 +4591,21 @@ func (r *Replica) processRaftCommand(
 		// Note that this must happen after committing (the engine.Batch), but
 		// before notifying a potentially waiting client.
 		r.handleEvalResultRaftMuLocked(ctx, lResult, raftCmd.ReplicatedEvalResult)
+
+		if raftCmd.ClosedTimestamp != nil && pErr == nil {
+			r.mu.Lock()
+			r.mu.closedTimestamp.Forward(*raftCmd.ClosedTimestamp)
+			r.mu.Unlock()
+		}
 	}
 
 	if proposedLocally {
+		if proposal.repliedAsync && len(response.Intents) > 0 {
+			// The client isn't waiting for the result, so resolve the intents
+			// here. Don't block the application of Raft commands on it.
+			r.store.intentResolver.processIntentsAsync(r, response.Intents, false /* allowSync */)
+			response.Intents = nil
+		}
 		proposal.finishRaftApplication(response)
 	} else if response.Err != nil {
 		log.VEventf(ctx, 1, "applying raft command resulted in error: %s", response.Err)
@@ -4406,6 +4706,9 @@ func (r *Replica) applyRaftCommand(
 		log.Fatalf(ctx, "raft command index is <= 0")
 	}
 	r.writeStats.recordCount(math.Max(float64(rResult.Delta.KeyCount), 1), 0)
+	if writeBatch != nil {
+		r.writeBytesStats.recordCount(float64(len(writeBatch.Data)), 0)
+	}
 
 	r.mu.Lock()
 	oldRaftAppliedIndex := r.mu.state.RaftAppliedIndex
@@ -4479,6 +4782,8 @@ func (r *Replica) applyRaftCommand(
 			errors.Wrap(err, "could not commit batch")))
 	}
 
+	r.handleRangeFeedRaftMuLocked(ctx, rResult, writeBatch)
+
 	if assertHS != nil {
 		// Load the HardState that was just committed (if any).
 		newHS, err := loadHardState(ctx, r.store.Engine(), rResult.Split.RightDesc.RangeID)
@@ -5377,12 +5682,12 @@ func calcReplicaMetrics(
 	if m.RangeCounter {
 		var goodReplicas int
 		goodReplicas, m.BehindCount = calcGoodReplicas(raftStatus, desc, livenessMap)
-		if goodReplicas < computeQuorum(len(desc.Replicas)) {
+		if goodReplicas < computeQuorum(len(desc.Voters())) {
 			m.Unavailable = true
 		}
 		if zoneConfig, err := cfg.GetZoneConfigForKey(desc.StartKey); err != nil {
 			log.Error(ctx, err)
-		} else if int32(goodReplicas) < zoneConfig.NumReplicas {
+		} else if int32(goodReplicas) < zoneConfig.GetNumVoters() {
 			m.Underreplicated = true
 		}
 	}
@@ -5400,7 +5705,7 @@ func calcGoodReplicas(
 	leader := isRaftLeader(raftStatus)
 	var goodReplicas int
 	var behindCount int64
-	for _, rd := range desc.Replicas {
+	for _, rd := range desc.Voters() {
 		live := livenessMap[rd.NodeID]
 		if !leader {
 			if live {
@@ -5448,6 +5753,12 @@ func (r *Replica) WritesPerSecond() float64 {
 	return wps
 }
 
+// WriteBytesPerSecond returns the range's average bytes written per second.
+func (r *Replica) WriteBytesPerSecond() float64 {
+	bps, _ := r.writeBytesStats.avgQPS()
+	return bps
+}
+
 // GetLeaseHistory returns the lease history stored on this replica.
 func (r *Replica) GetLeaseHistory() []roachpb.Lease {
 	if r.leaseHistory == nil {
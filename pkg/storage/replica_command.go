@@ -26,6 +26,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/coreos/etcd/raft"
 	"github.com/coreos/etcd/raft/raftpb"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
@@ -38,10 +39,12 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
 	"github.com/cockroachdb/cockroach/pkg/storage/storagebase"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/retry"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/uuid"
 )
@@ -117,6 +120,7 @@ var commands = map[roachpb.Method]Command{
 	roachpb.Increment:          {DeclareKeys: DefaultDeclareKeys, Eval: evalIncrement},
 	roachpb.Delete:             {DeclareKeys: DefaultDeclareKeys, Eval: evalDelete},
 	roachpb.DeleteRange:        {DeclareKeys: DefaultDeclareKeys, Eval: evalDeleteRange},
+	roachpb.ClearRange:         {DeclareKeys: DefaultDeclareKeys, Eval: evalClearRange},
 	roachpb.Scan:               {DeclareKeys: DefaultDeclareKeys, Eval: evalScan},
 	roachpb.ReverseScan:        {DeclareKeys: DefaultDeclareKeys, Eval: evalReverseScan},
 	roachpb.BeginTransaction:   {DeclareKeys: declareKeysBeginTransaction, Eval: evalBeginTransaction},
@@ -126,6 +130,8 @@ var commands = map[roachpb.Method]Command{
 	roachpb.GC:                 {DeclareKeys: declareKeysGC, Eval: evalGC},
 	roachpb.PushTxn:            {DeclareKeys: declareKeysPushTransaction, Eval: evalPushTxn},
 	roachpb.QueryTxn:           {DeclareKeys: DefaultDeclareKeys, Eval: evalQueryTxn},
+	roachpb.QueryIntent:        {DeclareKeys: DefaultDeclareKeys, Eval: evalQueryIntent},
+	roachpb.RecoverTxn:         {DeclareKeys: declareKeysRecoverTransaction, Eval: evalRecoverTxn},
 	roachpb.ResolveIntent:      {DeclareKeys: declareKeysResolveIntent, Eval: evalResolveIntent},
 	roachpb.ResolveIntentRange: {DeclareKeys: declareKeysResolveIntentRange, Eval: evalResolveIntentRange},
 	roachpb.Merge:              {DeclareKeys: DefaultDeclareKeys, Eval: evalMerge},
@@ -400,6 +406,46 @@ func evalDeleteRange(
 	return EvalResult{}, err
 }
 
+// clearRangeBytesThreshold is the total size of the data in a span
+// below which ClearRange clears its keys one at a time rather than
+// with a RocksDB range deletion tombstone. Range tombstones slow down
+// reads until they are compacted away, so they're only worth writing
+// when a lot of data is being removed.
+const clearRangeBytesThreshold = 512 << 10 // 512KiB
+
+// evalClearRange removes all values (including all MVCC versions) in
+// the span. The MVCC stats of the removed data are computed up front
+// and subtracted from the range's stats.
+//
+// Note that this command is not MVCC-safe: it must only be used on
+// spans which will no longer be read or written, such as those of
+// tables which were dropped more than a GC TTL ago.
+func evalClearRange(
+	ctx context.Context, batch engine.ReadWriter, cArgs CommandArgs, resp roachpb.Response,
+) (EvalResult, error) {
+	if cArgs.Header.Txn != nil {
+		return EvalResult{}, errors.New("cannot execute ClearRange within a transaction")
+	}
+	args := cArgs.Args.(*roachpb.ClearRangeRequest)
+	from := engine.MakeMVCCMetadataKey(args.Key)
+	to := engine.MakeMVCCMetadataKey(args.EndKey)
+
+	iter := batch.NewIterator(false /* !prefix */)
+	defer iter.Close()
+	statsDelta, err := iter.ComputeStats(from, to, cArgs.Header.Timestamp.WallTime)
+	if err != nil {
+		return EvalResult{}, errors.Wrap(err, "while computing stats of cleared span")
+	}
+	cArgs.Stats.Subtract(statsDelta)
+
+	if total := statsDelta.Total(); total < clearRangeBytesThreshold {
+		log.VEventf(ctx, 2, "clearing %s with point deletions: %d bytes < %d",
+			args.Span, total, clearRangeBytesThreshold)
+		return EvalResult{}, batch.ClearIterRange(iter, from, to)
+	}
+	return EvalResult{}, batch.ClearRange(from, to)
+}
+
 // evalScan scans the key range specified by start key through end key
 // in ascending order up to some maximum number of results. maxKeys
 // stores the number of scan results remaining for this batch
@@ -717,7 +763,11 @@ func evalEndTransaction(
 			args.IntentSpans, reply.Txn), args, true, /* alwaysReturn */
 		), roachpb.NewTransactionAbortedError()
 
-	case roachpb.PENDING:
+	case roachpb.PENDING, roachpb.STAGING:
+		// A STAGING record is moved to COMMITTED by the coordinator once
+		// it has proven all in-flight writes, or is staged again if the
+		// transaction was restarted in a new epoch. The coordinator may
+		// also abort it if the parallel commit is known to have failed.
 		if h.Txn.Epoch < reply.Txn.Epoch {
 			// TODO(tschottdorf): this leaves the Txn record (and more
 			// importantly, intents) dangling; we can't currently write on
@@ -777,10 +827,14 @@ func evalEndTransaction(
 		if retry, reason := isEndTransactionTriggeringRetryError(h.Txn, reply.Txn); retry {
 			return EvalResult{}, roachpb.NewTransactionRetryError(reason)
 		}
+		if isEndTransactionParallelCommit(*args) {
+			return stageTxnRecord(ctx, batch, ms, *args, reply.Txn)
+		}
 		reply.Txn.Status = roachpb.COMMITTED
 	} else {
 		reply.Txn.Status = roachpb.ABORTED
 	}
+	reply.Txn.InFlightWrites = nil
 
 	desc, err := cArgs.EvalCtx.Desc()
 	if err != nil {
@@ -829,6 +883,34 @@ func evalEndTransaction(
 	return pd, nil
 }
 
+// isEndTransactionParallelCommit returns true if the EndTransaction
+// request commits in parallel with writes that are still in flight.
+// Commit triggers are always committed explicitly.
+func isEndTransactionParallelCommit(args roachpb.EndTransactionRequest) bool {
+	return args.Commit && len(args.InFlightWrites) > 0 && args.InternalCommitTrigger == nil
+}
+
+// stageTxnRecord writes the transaction record in the STAGING state
+// along with the in-flight writes that make up its implicit commit
+// condition. No intents are resolved: that has to wait until the
+// transaction is known to be committed.
+func stageTxnRecord(
+	ctx context.Context,
+	batch engine.ReadWriter,
+	ms *enginepb.MVCCStats,
+	args roachpb.EndTransactionRequest,
+	txn *roachpb.Transaction,
+) (EvalResult, error) {
+	txn.Status = roachpb.STAGING
+	txn.Intents = args.IntentSpans
+	txn.InFlightWrites = args.InFlightWrites
+	key := keys.TransactionKey(txn.Key, txn.ID)
+	if err := engine.MVCCPutProto(ctx, batch, ms, key, hlc.Timestamp{}, nil /* txn */, txn); err != nil {
+		return EvalResult{}, err
+	}
+	return EvalResult{}, nil
+}
+
 // isEndTransactionExceedingDeadline returns true if the transaction
 // exceeded its deadline.
 func isEndTransactionExceedingDeadline(t hlc.Timestamp, args roachpb.EndTransactionRequest) bool {
@@ -1416,7 +1498,7 @@ func evalHeartbeatTxn(
 		return EvalResult{}, errors.Errorf("heartbeat for transaction %s failed; record not present", h.Txn)
 	}
 
-	if txn.Status == roachpb.PENDING {
+	if !txn.Status.IsFinalized() {
 		txn.LastHeartbeat.Forward(args.Now)
 		if err := engine.MVCCPutProto(ctx, batch, cArgs.Stats, key, hlc.Timestamp{}, nil, &txn); err != nil {
 			return EvalResult{}, err
@@ -1442,6 +1524,11 @@ func declareKeysGC(
 	if gcr.Threshold != (hlc.Timestamp{}) {
 		spans.Add(SpanReadWrite, roachpb.Span{Key: keys.RangeLastGCKey(header.RangeID)})
 	}
+	// Serialize with the reads of jobs verifying their protected timestamps
+	// against this range. See Replica.checkProtectedTSReadAt.
+	if gcr.ProtectedTSReadAt != (hlc.Timestamp{}) {
+		spans.Add(SpanReadWrite, roachpb.Span{Key: keys.RangeProtectedTSCheckKey(desc.StartKey)})
+	}
 	if gcr.TxnSpanGCThreshold != (hlc.Timestamp{}) {
 		spans.Add(SpanReadWrite, roachpb.Span{
 			// TODO(bdarnell): since this must be checked by all
@@ -1556,6 +1643,10 @@ func declareKeysPushTransaction(
 // Txn already committed/aborted: If pushee txn is committed or
 // aborted return success.
 //
+// Txn staging: If pushee txn is STAGING, it can't be pushed. If it
+// has also timed out, return IndeterminateCommitError so that the
+// pusher recovers it; otherwise return TransactionPushError.
+//
 // Txn Timeout: If pushee txn entry isn't present or its LastHeartbeat
 // timestamp isn't set, use its as LastHeartbeat. If current time -
 // LastHeartbeat > 2 * DefaultHeartbeatInterval, then the pushee txn
@@ -1653,7 +1744,7 @@ func evalPushTxn(
 	reply.PusheeTxn = existTxn.Clone()
 
 	// If already committed or aborted, return success.
-	if reply.PusheeTxn.Status != roachpb.PENDING {
+	if reply.PusheeTxn.Status.IsFinalized() {
 		// Trivial noop.
 		return EvalResult{}, nil
 	}
@@ -1665,6 +1756,17 @@ func evalPushTxn(
 		return EvalResult{}, nil
 	}
 
+	// A STAGING transaction may already be implicitly committed, so it can
+	// neither be aborted nor have its timestamp pushed. If its coordinator
+	// appears to have died, the pusher has to recover the transaction to
+	// find out whether it committed.
+	if reply.PusheeTxn.Status == roachpb.STAGING {
+		if isExpired(args.Now, &reply.PusheeTxn) {
+			return EvalResult{}, roachpb.NewIndeterminateCommitError(reply.PusheeTxn)
+		}
+		return EvalResult{}, roachpb.NewTransactionPushError(reply.PusheeTxn)
+	}
+
 	// The pusher might be aware of a newer version of the pushee.
 	reply.PusheeTxn.Timestamp.Forward(args.PusheeTxn.Timestamp)
 	if reply.PusheeTxn.Epoch < args.PusheeTxn.Epoch {
@@ -1773,11 +1875,96 @@ func evalQueryTxn(
 	if err != nil || !ok {
 		return EvalResult{}, err
 	}
-	// Get the list of txns waiting on this txn.
+	// Get the list of txns waiting on this txn and the waits-for graph
+	// between them.
 	reply.WaitingTxns = cArgs.EvalCtx.pushTxnQueue().GetDependents(args.Txn.ID)
+	reply.WaitsFor = cArgs.EvalCtx.pushTxnQueue().GetWaitsForEdges(args.Txn.ID)
 	return EvalResult{}, nil
 }
 
+// evalQueryIntent checks whether the specified transaction has an
+// intent at the key which was written at or after the requested
+// sequence number and at or below the transaction's timestamp. It is
+// used to prove that a pipelined write succeeded, and to recover
+// STAGING transactions whose coordinator has died.
+func evalQueryIntent(
+	ctx context.Context, batch engine.ReadWriter, cArgs CommandArgs, resp roachpb.Response,
+) (EvalResult, error) {
+	args := cArgs.Args.(*roachpb.QueryIntentRequest)
+	reply := resp.(*roachpb.QueryIntentResponse)
+
+	var meta enginepb.MVCCMetadata
+	ok, _, _, err := batch.GetProto(engine.MakeMVCCMetadataKey(args.Key), &meta)
+	if err != nil {
+		return EvalResult{}, err
+	}
+	reply.FoundIntent = ok && meta.Txn != nil &&
+		meta.Txn.ID == args.Txn.ID &&
+		meta.Txn.Epoch == args.Txn.Epoch &&
+		meta.Txn.Sequence >= args.Txn.Sequence &&
+		!args.Txn.Timestamp.Less(meta.Timestamp)
+
+	if !reply.FoundIntent && args.ErrorIfMissing {
+		return EvalResult{}, roachpb.NewTransactionRetryError(roachpb.RETRY_ASYNC_WRITE_FAILURE)
+	}
+	return EvalResult{}, nil
+}
+
+func declareKeysRecoverTransaction(
+	_ roachpb.RangeDescriptor, _ roachpb.Header, req roachpb.Request, spans *SpanSet,
+) {
+	rr := req.(*roachpb.RecoverTxnRequest)
+	spans.Add(SpanReadWrite, roachpb.Span{Key: keys.TransactionKey(rr.Txn.Key, rr.Txn.ID)})
+}
+
+// evalRecoverTxn finalizes a STAGING transaction after its in-flight
+// writes have been queried: it is committed if all of them were found
+// and aborted otherwise. If the transaction record is no longer in the
+// state that was queried, because the coordinator finished, restarted
+// or restaged the transaction in the meantime, the record is returned
+// unchanged.
+func evalRecoverTxn(
+	ctx context.Context, batch engine.ReadWriter, cArgs CommandArgs, resp roachpb.Response,
+) (EvalResult, error) {
+	args := cArgs.Args.(*roachpb.RecoverTxnRequest)
+	reply := resp.(*roachpb.RecoverTxnResponse)
+
+	if cArgs.Header.Txn != nil {
+		return EvalResult{}, errTransactionUnsupported
+	}
+	if !bytes.Equal(args.Key, args.Txn.Key) {
+		return EvalResult{}, errors.Errorf("request key %s does not match txn key %s", args.Key, args.Txn.Key)
+	}
+	key := keys.TransactionKey(args.Txn.Key, args.Txn.ID)
+
+	ok, err := engine.MVCCGetProto(ctx, batch, key, hlc.Timestamp{},
+		true /* consistent */, nil /* txn */, &reply.RecoveredTxn)
+	if err != nil {
+		return EvalResult{}, err
+	} else if !ok {
+		return EvalResult{}, roachpb.NewTransactionStatusError("does not exist")
+	}
+	txn := &reply.RecoveredTxn
+	if txn.Status != roachpb.STAGING || txn.Epoch != args.Txn.Epoch ||
+		txn.Timestamp != args.Txn.Timestamp {
+		return EvalResult{}, nil
+	}
+
+	if args.ImplicitlyCommitted {
+		txn.Status = roachpb.COMMITTED
+	} else {
+		txn.Status = roachpb.ABORTED
+	}
+	txn.InFlightWrites = nil
+	if err := engine.MVCCPutProto(ctx, batch, cArgs.Stats, key, hlc.Timestamp{}, nil, txn); err != nil {
+		return EvalResult{}, err
+	}
+	// Resolve the intents only once the new status has been committed.
+	result := intentsToEvalResult(roachpb.AsIntents(txn.Intents, txn), args, false /* !alwaysReturn */)
+	result.Local.updatedTxn = txn
+	return result, nil
+}
+
 // setAbortCache clears any abort cache entry if poison is false.
 // Otherwise, if poison is true, creates an entry for this transaction
 // in the abort cache to prevent future reads or writes from
@@ -2132,7 +2319,8 @@ func evalNewLease(
 	if err != nil {
 		return EvalResult{}, err
 	}
-	if _, ok := desc.GetReplicaDescriptor(lease.Replica.StoreID); !ok {
+	repDesc, ok := desc.GetReplicaDescriptor(lease.Replica.StoreID)
+	if !ok {
 		return newFailedLeaseTrigger(isTransfer),
 			&roachpb.LeaseRejectedError{
 				Existing:  prevLease,
@@ -2140,6 +2328,16 @@ func evalNewLease(
 				Message:   "replica not found",
 			}
 	}
+	// Learners and non-voters aren't part of the Raft quorum and may not have
+	// caught up with the range's state, so they can't hold the lease.
+	if !repDesc.IsVoter() {
+		return newFailedLeaseTrigger(isTransfer),
+			&roachpb.LeaseRejectedError{
+				Existing:  prevLease,
+				Requested: lease,
+				Message:   fmt.Sprintf("replica is a %s", repDesc.GetType()),
+			}
+	}
 
 	// Store the lease to disk & in-memory.
 	if err := makeReplicaStateLoader(rec.RangeID()).setLease(ctx, batch, ms, lease); err != nil {
@@ -2214,6 +2412,15 @@ func (r *Replica) CheckConsistency(
 			roachpb.NewError(errors.Wrap(err, "could not get replica descriptor"))
 	}
 	var inconsistencyCount uint32
+	// The local replica is a voter since it holds the lease, so it starts
+	// out as the only voter known to agree with it.
+	var results struct {
+		syncutil.Mutex
+		consistentVoters int
+		inconsistent     map[roachpb.ReplicaID]string
+	}
+	results.consistentVoters = 1
+	results.inconsistent = map[roachpb.ReplicaID]string{}
 	var wg sync.WaitGroup
 	for _, replica := range desc.Replicas {
 		if replica == localReplica {
@@ -2250,9 +2457,18 @@ func (r *Replica) CheckConsistency(
 					return
 				}
 				if bytes.Equal(c.checksum, resp.Checksum) {
+					if replica.IsVoter() {
+						results.Lock()
+						results.consistentVoters++
+						results.Unlock()
+					}
 					return
 				}
 				atomic.AddUint32(&inconsistencyCount, 1)
+				results.Lock()
+				results.inconsistent[replica.ReplicaID] = fmt.Sprintf(
+					"expected checksum %x, got %x", c.checksum, resp.Checksum)
+				results.Unlock()
 				var buf bytes.Buffer
 				_, _ = fmt.Fprintf(&buf, "replica %s is inconsistent: expected checksum %x, got %x",
 					replica, c.checksum, resp.Checksum)
@@ -2272,8 +2488,42 @@ func (r *Replica) CheckConsistency(
 	}
 	wg.Wait()
 
+	// When repair is enabled, replicas that disagree with a majority of the
+	// voters are handed to the replicate queue, which replaces them with a
+	// fresh replica. Without such a majority there's no telling which replicas
+	// hold the correct data, so the inconsistency is handled as usual.
+	var repairing bool
+	if inconsistencyCount > 0 && r.store.cfg.Settings.ConsistencyRepairEnabled.Get() {
+		if quorum := computeQuorum(len(desc.Voters())); results.consistentVoters >= quorum {
+			repairing = true
+			// Persisting the marks requires a write to this range, which can't
+			// be done while evaluating this request.
+			if err := r.store.stopper.RunAsyncTask(
+				r.AnnotateCtx(context.Background()), "storage.Replica: marking inconsistent replicas",
+				func(ctx context.Context) {
+					if err := r.markInconsistentReplicas(ctx, desc.StartKey, results.inconsistent); err != nil {
+						log.Errorf(ctx, "could not mark inconsistent replicas: %s", err)
+						return
+					}
+					if rq := r.store.replicateQueue; rq != nil {
+						rq.MaybeAdd(r, r.store.Clock().Now())
+					}
+				}); err != nil {
+				log.Error(ctx, errors.Wrap(err, "could not mark inconsistent replicas"))
+			}
+			log.Warningf(ctx, "%d of %d voters agree on the checksum; replacing %d inconsistent replicas",
+				results.consistentVoters, len(desc.Voters()), len(results.inconsistent))
+		} else {
+			log.Errorf(ctx, "only %d of %d voters agree on the checksum; not repairing",
+				results.consistentVoters, len(desc.Voters()))
+		}
+	}
+
 	logFunc := log.Errorf
-	if p := r.store.TestingKnobs().BadChecksumPanic; p != nil {
+	if repairing {
+		// The inconsistent replicas are being replaced, so there's no need to
+		// take down this node.
+	} else if p := r.store.TestingKnobs().BadChecksumPanic; p != nil {
 		p(r.store.Ident)
 	} else if r.store.cfg.ConsistencyCheckPanicOnFailure {
 		logFunc = log.Fatalf
@@ -2302,6 +2552,80 @@ func (r *Replica) CheckConsistency(
 	return roachpb.CheckConsistencyResponse{}, nil
 }
 
+// markInconsistentReplicas marks the given replicas, keyed by replica ID,
+// as disagreeing with a majority of the range. The marks are stored in
+// range-local keys so that they survive restarts and lease transfers.
+func (r *Replica) markInconsistentReplicas(
+	ctx context.Context, startKey roachpb.RKey, inconsistent map[roachpb.ReplicaID]string,
+) error {
+	b := &client.Batch{}
+	for id, details := range inconsistent {
+		b.PutInline(keys.RangeInconsistentReplicaKey(startKey, id), details)
+	}
+	return r.store.DB().Run(ctx, b)
+}
+
+// clearInconsistentReplica removes the mark of the given replica of the
+// range starting at startKey.
+func (r *Replica) clearInconsistentReplica(
+	ctx context.Context, startKey roachpb.RKey, replicaID roachpb.ReplicaID,
+) error {
+	key := keys.RangeInconsistentReplicaKey(startKey, replicaID)
+	b := &client.Batch{}
+	b.AddRawRequest(&roachpb.DeleteRangeRequest{
+		Span: roachpb.Span{
+			Key:    key,
+			EndKey: key.Next(),
+		},
+		Inline: true,
+	})
+	return r.store.DB().Run(ctx, b)
+}
+
+// inconsistentReplicas returns the replicas of the given descriptor which
+// were marked as inconsistent by markInconsistentReplicas. Marks for
+// replicas which are no longer part of the range are ignored; replica IDs
+// are never reused.
+func (r *Replica) inconsistentReplicas(
+	ctx context.Context, desc *roachpb.RangeDescriptor,
+) ([]roachpb.ReplicaDescriptor, error) {
+	prefix := keys.RangeInconsistentReplicaPrefix(desc.StartKey)
+	kvs, _, _, err := engine.MVCCScan(
+		ctx, r.store.Engine(), prefix, prefix.PrefixEnd(), math.MaxInt64, hlc.Timestamp{},
+		true /* consistent */, nil, /* txn */
+	)
+	if err != nil {
+		return nil, err
+	}
+	var inconsistent []roachpb.ReplicaDescriptor
+	for _, kv := range kvs {
+		_, replicaID, err := encoding.DecodeUvarintAscending(kv.Key[len(prefix):])
+		if err != nil {
+			return nil, err
+		}
+		for _, rep := range desc.Replicas {
+			if rep.ReplicaID == roachpb.ReplicaID(replicaID) {
+				inconsistent = append(inconsistent, rep)
+			}
+		}
+	}
+	return inconsistent, nil
+}
+
+// inconsistentReplicaDetails returns the description of the disagreement
+// recorded for the given inconsistent replica.
+func (r *Replica) inconsistentReplicaDetails(
+	ctx context.Context, startKey roachpb.RKey, replicaID roachpb.ReplicaID,
+) (string, error) {
+	key := keys.RangeInconsistentReplicaKey(startKey, replicaID)
+	value, _, err := engine.MVCCGet(ctx, r.store.Engine(), key, hlc.Timestamp{}, true /* consistent */, nil /* txn */)
+	if err != nil || value == nil {
+		return "", err
+	}
+	details, err := value.GetBytes()
+	return string(details), err
+}
+
 const (
 	replicaChecksumVersion    = 2
 	replicaChecksumGCInterval = time.Hour
@@ -3516,6 +3840,11 @@ func IsSnapshotError(err error) bool {
 // to date via Raft log replay. In this scenario, the reservation will be left
 // dangling until it expires. See #7849.
 //
+// When the kv.learner_replicas.enabled setting is on, replicas are instead
+// added as Raft learners which are caught up via the Raft snapshot queue
+// and then promoted to voters in a second ChangeReplicas transaction. See
+// Replica.addLearnerReplica.
+//
 // TODO(peter): Describe preemptive snapshots. Preemptive snapshots are needed
 // for the replicate queue to function properly. Currently the replicate queue
 // will fire off as many replica additions as possible until it starts getting
@@ -3558,7 +3887,6 @@ func (r *Replica) changeReplicas(
 		}
 	}
 
-	rangeID := desc.RangeID
 	updatedDesc := *desc
 	updatedDesc.Replicas = append([]roachpb.ReplicaDescriptor(nil), desc.Replicas...)
 
@@ -3573,6 +3901,10 @@ func (r *Replica) changeReplicas(
 			return errors.Errorf("%s: unable to add replica %v; node already has a replica", r, repDesc)
 		}
 
+		if r.useLearnerReplicas() {
+			return r.addLearnerReplica(ctx, repDesc, desc, reason, details)
+		}
+
 		// Prohibit premature raft log truncation. We set the pending index to 1
 		// here until we determine what it is below. This removes a small window of
 		// opportunity for the raft log to get truncated after the snapshot is
@@ -3622,6 +3954,200 @@ func (r *Replica) changeReplicas(
 		updatedDesc.Replicas = updatedDesc.Replicas[:len(updatedDesc.Replicas)-1]
 	}
 
+	return r.execChangeReplicasTxn(ctx, changeType, repDesc, desc, updatedDesc, reason, details)
+}
+
+// useLearnerReplicas returns whether replicas should be added to the range as
+// learners.
+func (r *Replica) useLearnerReplicas() bool {
+	st := r.store.cfg.Settings
+	return st.LearnerReplicasEnabled.Get() && st.Version.IsActive(cluster.VersionLearnerReplicas)
+}
+
+// addLearnerReplica adds a replica to the range in three steps. The replica
+// is first added to the range descriptor and the Raft group as a learner,
+// which receives the Raft log but doesn't vote and isn't counted towards
+// quorum. It is then caught up with a Raft snapshot sent through the Raft
+// snapshot queue and finally promoted to a voter. Since the learner doesn't
+// affect the range's quorum, the range is as available while the (possibly
+// large) snapshot is in flight as it was before the addition started.
+//
+// If the learner can't be caught up or promoted, an attempt is made to
+// remove it again. Learners left behind when that fails too (or when the
+// leaseholder crashes halfway through) are removed by the replicate queue.
+func (r *Replica) addLearnerReplica(
+	ctx context.Context,
+	repDesc roachpb.ReplicaDescriptor,
+	desc *roachpb.RangeDescriptor,
+	reason RangeLogEventReason,
+	details string,
+) error {
+	// Register the learner before it's added so that the replicate queue
+	// doesn't mistake it for one left behind by an interrupted addition.
+	repDesc.Type = roachpb.LEARNER.Enum()
+	repDesc.ReplicaID = desc.NextReplicaID
+	r.mu.Lock()
+	if r.mu.pendingLearners == nil {
+		r.mu.pendingLearners = make(map[roachpb.ReplicaID]struct{})
+	}
+	r.mu.pendingLearners[repDesc.ReplicaID] = struct{}{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.mu.pendingLearners, repDesc.ReplicaID)
+		r.mu.Unlock()
+	}()
+
+	repDesc, learnerDesc, err := r.addAndCatchUpReplica(ctx, repDesc, desc, reason, details)
+	if err != nil {
+		return err
+	}
+	if fn := r.store.cfg.TestingKnobs.BeforeLearnerPromotion; fn != nil {
+		fn(repDesc)
+	}
+
+	voterDesc := repDesc
+	voterDesc.Type = nil
+	promotedDesc := learnerDesc
+	promotedDesc.Replicas = append([]roachpb.ReplicaDescriptor(nil), learnerDesc.Replicas...)
+	promotedDesc.Replicas[len(promotedDesc.Replicas)-1] = voterDesc
+	if err := r.execChangeReplicasTxn(
+		ctx, roachpb.ADD_REPLICA, voterDesc, &learnerDesc, promotedDesc, reason, details,
+	); err != nil {
+		r.removeLearnerReplica(ctx, repDesc, &learnerDesc)
+		return err
+	}
+	return nil
+}
+
+// addNonVoterReplica adds a non-voting replica on the target store. Like a
+// learner, the non-voter is caught up before this method returns, but it is
+// never promoted: it stays in the range to serve follower reads (see
+// canServeFollowerRead) without affecting the range's quorum.
+func (r *Replica) addNonVoterReplica(
+	ctx context.Context,
+	target roachpb.ReplicationTarget,
+	desc *roachpb.RangeDescriptor,
+	reason RangeLogEventReason,
+	details string,
+) error {
+	for _, existingRep := range desc.Replicas {
+		if existingRep.NodeID == target.NodeID {
+			return errors.Errorf("%s: unable to add non-voter on %v; node already has a replica",
+				r, target)
+		}
+	}
+	repDesc := roachpb.ReplicaDescriptor{
+		NodeID:  target.NodeID,
+		StoreID: target.StoreID,
+		Type:    roachpb.NON_VOTER.Enum(),
+	}
+	_, _, err := r.addAndCatchUpReplica(ctx, repDesc, desc, reason, details)
+	return err
+}
+
+// addAndCatchUpReplica adds repDesc, which must be a learner or non-voter, to
+// the range and waits for Raft to catch it up. It returns the added replica
+// (with its ReplicaID assigned) and the resulting range descriptor. If the
+// replica doesn't catch up, an attempt is made to remove it again.
+func (r *Replica) addAndCatchUpReplica(
+	ctx context.Context,
+	repDesc roachpb.ReplicaDescriptor,
+	desc *roachpb.RangeDescriptor,
+	reason RangeLogEventReason,
+	details string,
+) (roachpb.ReplicaDescriptor, roachpb.RangeDescriptor, error) {
+	updatedDesc := *desc
+	repDesc.ReplicaID = updatedDesc.NextReplicaID
+	updatedDesc.NextReplicaID++
+	updatedDesc.Replicas = append(append([]roachpb.ReplicaDescriptor(nil), desc.Replicas...), repDesc)
+	if err := r.execChangeReplicasTxn(
+		ctx, roachpb.ADD_REPLICA, repDesc, desc, updatedDesc, reason, details,
+	); err != nil {
+		return roachpb.ReplicaDescriptor{}, roachpb.RangeDescriptor{}, err
+	}
+
+	if err := r.waitForReplicaCaughtUp(ctx, repDesc.ReplicaID); err != nil {
+		r.removeLearnerReplica(ctx, repDesc, &updatedDesc)
+		return roachpb.ReplicaDescriptor{}, roachpb.RangeDescriptor{}, err
+	}
+	return repDesc, updatedDesc, nil
+}
+
+// waitForReplicaCaughtUp waits until the Raft progress of the given replica,
+// which was just added to the range, shows that it's receiving the log. Raft
+// asks for the snapshot the new replica needs first, which the Raft snapshot
+// queue sends (see sendRaftMessage), so all this does is wait for it.
+func (r *Replica) waitForReplicaCaughtUp(ctx context.Context, id roachpb.ReplicaID) error {
+	retryOpts := base.DefaultRetryOptions()
+	retryOpts.Closer = r.store.Stopper().ShouldQuiesce()
+	for re := retry.StartWithCtx(ctx, retryOpts); re.Next(); {
+		// The progress is only known to the Raft leader, which is usually but
+		// not necessarily this replica, so keep waiting without it.
+		if status := r.RaftStatus(); status != nil {
+			if progress, ok := status.Progress[uint64(id)]; ok &&
+				progress.State == raft.ProgressStateReplicate && progress.Match > 0 {
+				return nil
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Errorf("%s: stopped waiting for replica %d to catch up", r, id)
+}
+
+// abandonedLearners returns the learners of the range which aren't being
+// added by an addLearnerReplica call in progress on this replica. These were
+// left behind by an addition which was interrupted, for example because the
+// leaseholder crashed, or which is still running on a former leaseholder (in
+// which case the promotion fails once the learner has been removed).
+func (r *Replica) abandonedLearners(desc *roachpb.RangeDescriptor) []roachpb.ReplicaDescriptor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var abandoned []roachpb.ReplicaDescriptor
+	for _, learner := range desc.Learners() {
+		if _, ok := r.mu.pendingLearners[learner.ReplicaID]; !ok {
+			abandoned = append(abandoned, learner)
+		}
+	}
+	return abandoned
+}
+
+// removeLearnerReplica removes a learner (or non-voter) which couldn't be
+// caught up or promoted. Failures are only logged, as the replicate queue
+// removes any learners it comes across and reconciles non-voters with the
+// zone config.
+func (r *Replica) removeLearnerReplica(
+	ctx context.Context, repDesc roachpb.ReplicaDescriptor, desc *roachpb.RangeDescriptor,
+) {
+	updatedDesc := *desc
+	updatedDesc.Replicas = nil
+	for _, rep := range desc.Replicas {
+		if rep.ReplicaID != repDesc.ReplicaID {
+			updatedDesc.Replicas = append(updatedDesc.Replicas, rep)
+		}
+	}
+	if err := r.execChangeReplicasTxn(
+		ctx, roachpb.REMOVE_REPLICA, repDesc, desc, updatedDesc, ReasonAbandonedLearner, "",
+	); err != nil {
+		log.Warningf(ctx, "unable to remove learner %s: %s", repDesc, err)
+	}
+}
+
+// execChangeReplicasTxn runs the transaction which replaces the range
+// descriptor desc with updatedDesc and whose commit trigger carries out the
+// corresponding change to the Raft group.
+func (r *Replica) execChangeReplicasTxn(
+	ctx context.Context,
+	changeType roachpb.ReplicaChangeType,
+	repDesc roachpb.ReplicaDescriptor,
+	desc *roachpb.RangeDescriptor,
+	updatedDesc roachpb.RangeDescriptor,
+	reason RangeLogEventReason,
+	details string,
+) error {
+	rangeID := desc.RangeID
 	descKey := keys.RangeDescriptorKey(desc.StartKey)
 
 	if err := r.store.DB().Txn(ctx, func(ctx context.Context, txn *client.Txn) error {
@@ -3755,6 +4281,7 @@ func (r *Replica) sendSnapshot(
 		// Recipients can choose to decline preemptive snapshots.
 		CanDecline: snapType == snapTypePreemptive,
 		Priority:   priority,
+		SSTChunks:  r.store.cfg.Settings.Version.IsActive(cluster.VersionSnapshotSSTables),
 	}
 	sent := func() {
 		r.store.metrics.RangeSnapshotsGenerated.Inc(1)
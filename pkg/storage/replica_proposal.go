@@ -16,7 +16,6 @@ package storage
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
@@ -82,6 +81,12 @@ type ProposalData struct {
 	// Always use ProposalData.finishRaftApplication().
 	doneCh chan proposalResult
 
+	// repliedAsync is set if the client was answered as soon as the command
+	// was proposed (see BatchRequest.AsyncConsensus). Nobody receives from
+	// doneCh in that case, so the intents which the command leaves to resolve
+	// are processed when it applies.
+	repliedAsync bool
+
 	// Local contains the results of evaluating the request
 	// tying the upstream evaluation of the request to the
 	// downstream application of the command.
@@ -602,7 +607,9 @@ func addSSTablePreApply(
 			}
 		}
 
-		if err := ioutil.WriteFile(path, sst.Data, 0600); err != nil {
+		// Write the file through the engine so that it is encrypted if the
+		// engine is.
+		if err := eng.WriteFile(path, sst.Data); err != nil {
 			log.Fatalf(ctx, "while ingesting %s: %s", path, err)
 		}
 	}
@@ -783,7 +790,12 @@ func (r *Replica) handleReplicatedEvalResult(
 		// that all we need to synchronize is disk i/o, and there is no overlap
 		// between files *removed* during truncation and those active in Raft.
 
-		if r.store.cfg.Settings.Version.IsActive(cluster.VersionRaftLogTruncationBelowRaft) {
+		// The size of the entries freed by the truncation of this replica's
+		// log. It's computed locally rather than upstream of Raft whenever
+		// possible, since replicas may store their log in different formats.
+		var raftLogDelta int64
+		truncatedBelowRaft := r.store.cfg.Settings.Version.IsActive(cluster.VersionRaftLogTruncationBelowRaft)
+		if truncatedBelowRaft {
 			// Truncate the Raft log.
 			batch := r.store.Engine().NewWriteOnlyBatch()
 			// We know that all of the deletions from here forward will be to distinct keys.
@@ -793,6 +805,13 @@ func (r *Replica) handleReplicatedEvalResult(
 				keys.RaftLogKey(r.RangeID, newTruncState.Index).PrefixEnd(),
 			)
 			iter := r.store.Engine().NewIterator(false /* !prefix */)
+			// We can pass zero as nowNanos because we're only interested in
+			// SysBytes.
+			if ms, err := iter.ComputeStats(start, end, 0 /* nowNanos */); err != nil {
+				log.Errorf(ctx, "unable to compute stats of truncated Raft entries for %+v: %s", newTruncState, err)
+			} else {
+				raftLogDelta -= ms.SysBytes
+			}
 			// Clear the log entries. Intentionally don't use range deletion
 			// tombstones (ClearRange()) due to performance concerns connected
 			// to having many range deletion tombstones. There is a chance that
@@ -809,6 +828,21 @@ func (r *Replica) handleReplicatedEvalResult(
 			}
 			batch.Close()
 		}
+		if raftLog := r.store.Engine().RaftLog(); raftLog != nil {
+			delta, err := raftLog.TruncatePrefix(r.RangeID, newTruncState.Index+1)
+			if err != nil {
+				log.Errorf(ctx, "unable to truncate separate Raft log for %+v: %s", newTruncState, err)
+			}
+			raftLogDelta += delta
+		}
+		if truncatedBelowRaft {
+			rResult.RaftLogDelta = &raftLogDelta
+		} else if rResult.RaftLogDelta != nil {
+			// The entries in the engine were deleted by the command itself, and
+			// the delta computed upstream of Raft accounts for them.
+			raftLogDelta += *rResult.RaftLogDelta
+			rResult.RaftLogDelta = &raftLogDelta
+		}
 
 		// Clear any entries in the Raft log entry cache for this range up
 		// to and including the most recently truncated index.
@@ -28,8 +28,10 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
 	"github.com/cockroachdb/cockroach/pkg/storage/storagebase"
+	"github.com/cockroachdb/cockroach/pkg/util/encoding"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/uuid"
 )
@@ -63,12 +65,22 @@ func (r *replicaRaftStorage) InitialState() (raftpb.HardState, raftpb.ConfState,
 	if raft.IsEmptyHardState(hs) || err != nil {
 		return raftpb.HardState{}, raftpb.ConfState{}, err
 	}
+	cs := confStateFromDesc(r.mu.state.Desc)
+	return hs, cs, nil
+}
+
+// confStateFromDesc synthesizes the Raft configuration of a range from its
+// descriptor.
+func confStateFromDesc(desc *roachpb.RangeDescriptor) raftpb.ConfState {
 	var cs raftpb.ConfState
-	for _, rep := range r.mu.state.Desc.Replicas {
-		cs.Nodes = append(cs.Nodes, uint64(rep.ReplicaID))
+	for _, rep := range desc.Replicas {
+		if rep.IsVoter() {
+			cs.Nodes = append(cs.Nodes, uint64(rep.ReplicaID))
+		} else {
+			cs.Learners = append(cs.Learners, uint64(rep.ReplicaID))
+		}
 	}
-
-	return hs, cs, nil
+	return cs
 }
 
 // Entries implements the raft.Storage interface. Note that maxBytes is advisory
@@ -79,7 +91,7 @@ func (r *replicaRaftStorage) Entries(lo, hi, maxBytes uint64) ([]raftpb.Entry, e
 	readonly := r.store.Engine().NewReadOnly()
 	defer readonly.Close()
 	ctx := r.AnnotateCtx(context.TODO())
-	return entries(ctx, readonly, r.RangeID, r.store.raftEntryCache,
+	return entries(ctx, readonly, r.store.Engine().RaftLog(), r.RangeID, r.store.raftEntryCache,
 		r.raftMu.sideloaded, lo, hi, maxBytes)
 }
 
@@ -91,10 +103,12 @@ func (r *Replica) raftEntriesLocked(lo, hi, maxBytes uint64) ([]raftpb.Entry, er
 // entries retrieves entries from the engine. To accommodate loading the term,
 // `sideloaded` can be supplied as nil, in which case sideloaded entries will
 // not be inlined, the raft entry cache not populated with *any* of the
-// loaded entries, and maxBytes not applied to the payloads.
+// loaded entries, and maxBytes not applied to the payloads. raftLog is the
+// store's separate raft log, if it has one.
 func entries(
 	ctx context.Context,
 	e engine.Reader,
+	raftLog engine.RaftLog,
 	rangeID roachpb.RangeID,
 	eCache *raftEntryCache,
 	sideloaded sideloadStorage,
@@ -160,7 +174,7 @@ func entries(
 		return exceededMaxBytes, nil
 	}
 
-	if err := iterateEntries(ctx, e, rangeID, expectedIndex, hi, scanFunc); err != nil {
+	if err := iterateEntries(ctx, e, raftLog, rangeID, expectedIndex, hi, scanFunc); err != nil {
 		return nil, err
 	}
 	// Cache the fetched entries, if we may.
@@ -211,14 +225,35 @@ func entries(
 	return nil, raft.ErrUnavailable
 }
 
+// iterateEntries calls scanFunc with the raft log entries in [lo, hi). If the
+// store has a separate raft log, entries stored in the engine come first: the
+// engine holds the log entries which were applied from a snapshot (and those
+// which weren't moved yet when the store started using the separate log), and
+// the separate log those appended since.
 func iterateEntries(
 	ctx context.Context,
 	e engine.Reader,
+	raftLog engine.RaftLog,
 	rangeID roachpb.RangeID,
 	lo,
 	hi uint64,
 	scanFunc func(roachpb.KeyValue) (bool, error),
 ) error {
+	next := lo
+	var done bool
+	f := scanFunc
+	if raftLog != nil {
+		prefixLen := len(keys.RaftLogPrefix(rangeID))
+		f = func(kv roachpb.KeyValue) (bool, error) {
+			_, index, err := encoding.DecodeUint64Ascending(kv.Key[prefixLen:])
+			if err != nil {
+				return true, err
+			}
+			next = index + 1
+			done, err = scanFunc(kv)
+			return done, err
+		}
+	}
 	_, err := engine.MVCCIterate(
 		ctx, e,
 		keys.RaftLogKey(rangeID, lo),
@@ -227,9 +262,16 @@ func iterateEntries(
 		true,  /* consistent */
 		nil,   /* txn */
 		false, /* !reverse */
-		scanFunc,
+		f,
 	)
-	return err
+	if raftLog == nil || err != nil || done {
+		return err
+	}
+	return raftLog.Iterate(rangeID, next, hi, func(index uint64, ent []byte) (bool, error) {
+		var value roachpb.Value
+		value.SetBytes(ent)
+		return scanFunc(roachpb.KeyValue{Key: keys.RaftLogKey(rangeID, index), Value: value})
+	})
 }
 
 // Term implements the raft.Storage interface.
@@ -244,7 +286,7 @@ func (r *replicaRaftStorage) Term(i uint64) (uint64, error) {
 	readonly := r.store.Engine().NewReadOnly()
 	defer readonly.Close()
 	ctx := r.AnnotateCtx(context.TODO())
-	return term(ctx, readonly, r.RangeID, r.store.raftEntryCache, i)
+	return term(ctx, readonly, r.store.Engine().RaftLog(), r.RangeID, r.store.raftEntryCache, i)
 }
 
 // raftTermLocked requires that r.mu is locked for reading.
@@ -253,11 +295,16 @@ func (r *Replica) raftTermRLocked(i uint64) (uint64, error) {
 }
 
 func term(
-	ctx context.Context, eng engine.Reader, rangeID roachpb.RangeID, eCache *raftEntryCache, i uint64,
+	ctx context.Context,
+	eng engine.Reader,
+	raftLog engine.RaftLog,
+	rangeID roachpb.RangeID,
+	eCache *raftEntryCache,
+	i uint64,
 ) (uint64, error) {
 	// entries() accepts a `nil` sideloaded storage and will skip inlining of
 	// sideloaded entries. We only need the term, so this is what we do.
-	ents, err := entries(ctx, eng, rangeID, eCache, nil /* sideloaded */, i, i+1, 0)
+	ents, err := entries(ctx, eng, raftLog, rangeID, eCache, nil /* sideloaded */, i, i+1, 0)
 	if err == raft.ErrCompacted {
 		ts, err := loadTruncatedState(ctx, eng, rangeID)
 		if err != nil {
@@ -400,7 +447,8 @@ func (r *Replica) GetSnapshot(
 		return fn(r.raftMu.sideloaded)
 	}
 	snapData, err := snapshot(
-		ctx, snapType, snap, rangeID, r.store.raftEntryCache, withSideloaded, startKey,
+		ctx, snapType, snap, r.store.Engine().RaftLog(), rangeID, r.store.raftEntryCache,
+		withSideloaded, startKey,
 	)
 	if err != nil {
 		log.Errorf(ctx, "error generating snapshot: %s", err)
@@ -418,6 +466,10 @@ type OutgoingSnapshot struct {
 	RaftSnap raftpb.Snapshot
 	// The RocksDB snapshot that will be streamed from.
 	EngineSnap engine.Reader
+	// The store's separate raft log, if it has one. Unlike EngineSnap, this
+	// isn't a snapshot: the entries the snapshot needs may be truncated
+	// concurrently, in which case sending the snapshot fails.
+	RaftLog engine.RaftLog
 	// The complete range iterator for the snapshot to stream.
 	Iter *ReplicaDataIterator
 	// The replica state within the snapshot.
@@ -430,6 +482,13 @@ type OutgoingSnapshot struct {
 	RaftEntryCache *raftEntryCache
 }
 
+// resetIter repositions the snapshot's iterator at the start of the range's
+// data so that the snapshot can be streamed again.
+func (s *OutgoingSnapshot) resetIter() {
+	s.Iter.Close()
+	s.Iter = NewReplicaDataIterator(s.State.Desc, s.EngineSnap, true /* replicatedOnly */)
+}
+
 // Close releases the resources associated with the snapshot.
 func (s *OutgoingSnapshot) Close() {
 	s.Iter.Close()
@@ -441,6 +500,10 @@ type IncomingSnapshot struct {
 	SnapUUID uuid.UUID
 	// The RocksDB BatchReprs that make up this snapshot.
 	Batches [][]byte
+	// The files holding the SSTables that make up this snapshot, if it was
+	// sent as SSTable chunks. They're ingested rather than applied through a
+	// batch.
+	SSTPaths []string
 	// The Raft log entries for this snapshot.
 	LogEntries [][]byte
 	// The replica state at the time the snapshot was generated (never nil).
@@ -454,6 +517,7 @@ func snapshot(
 	ctx context.Context,
 	snapType string,
 	snap engine.Reader,
+	raftLog engine.RaftLog,
 	rangeID roachpb.RangeID,
 	eCache *raftEntryCache,
 	withSideloaded func(func(sideloadStorage) error) error,
@@ -484,12 +548,9 @@ func snapshot(
 	}
 
 	// Synthesize our raftpb.ConfState from desc.
-	var cs raftpb.ConfState
-	for _, rep := range desc.Replicas {
-		cs.Nodes = append(cs.Nodes, uint64(rep.ReplicaID))
-	}
+	cs := confStateFromDesc(&desc)
 
-	term, err := term(ctx, snap, rangeID, eCache, appliedIndex)
+	term, err := term(ctx, snap, raftLog, rangeID, eCache, appliedIndex)
 	if err != nil {
 		return OutgoingSnapshot{}, errors.Errorf("failed to fetch term of %d: %s", appliedIndex, err)
 	}
@@ -511,6 +572,7 @@ func snapshot(
 		RaftEntryCache: eCache,
 		WithSideloaded: withSideloaded,
 		EngineSnap:     snap,
+		RaftLog:        raftLog,
 		Iter:           iter,
 		State:          state,
 		SnapUUID:       snapUUID,
@@ -534,9 +596,13 @@ func snapshot(
 // append is intentionally oblivious to the existence of sideloaded proposals.
 // They are managed by the caller, including cleaning up obsolete on-disk
 // payloads in case the log tail is replaced.
+//
+// If raftLog is non-nil, the entries are durably written to it before append
+// returns instead of being added to the batch.
 func (r *Replica) append(
 	ctx context.Context,
 	batch engine.ReadWriter,
+	raftLog engine.RaftLog,
 	prevLastIndex uint64,
 	prevLastTerm uint64,
 	prevRaftLogSize int64,
@@ -545,6 +611,9 @@ func (r *Replica) append(
 	if len(entries) == 0 {
 		return prevLastIndex, prevLastTerm, prevRaftLogSize, nil
 	}
+	if raftLog != nil {
+		return r.appendSeparate(ctx, batch, raftLog, prevRaftLogSize, entries)
+	}
 	var diff enginepb.MVCCStats
 	var value roachpb.Value
 	for i := range entries {
@@ -588,6 +657,55 @@ func (r *Replica) append(
 	return lastIndex, lastTerm, raftLogSize, nil
 }
 
+// appendSeparate is the variant of append for stores with a separate raft
+// log. Replacing entries at or above the first new entry also discards the
+// log's uncommitted tail, so only the last index is written to the batch.
+func (r *Replica) appendSeparate(
+	ctx context.Context,
+	batch engine.ReadWriter,
+	raftLog engine.RaftLog,
+	prevRaftLogSize int64,
+	entries []raftpb.Entry,
+) (uint64, uint64, int64, error) {
+	firstIndex := entries[0].Index
+	ents := make([][]byte, len(entries))
+	for i := range entries {
+		ent, err := protoutil.Marshal(&entries[i])
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		ents[i] = ent
+	}
+	delta, err := raftLog.Append(r.RangeID, firstIndex, ents)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	// Entries stored in the engine take precedence over those in the separate
+	// log (see iterateEntries), so any which are being replaced have to go.
+	// There usually aren't any: the engine only holds entries applied from a
+	// snapshot, which are committed.
+	start := engine.MakeMVCCMetadataKey(r.raftMu.stateLoader.RaftLogKey(firstIndex))
+	end := engine.MakeMVCCMetadataKey(keys.RaftLogPrefix(r.RangeID).PrefixEnd())
+	iter := r.store.Engine().NewIterator(false /* !prefix */)
+	defer iter.Close()
+	iter.Seek(start)
+	if ok, err := iter.Valid(); err != nil {
+		return 0, 0, 0, err
+	} else if ok && iter.UnsafeKey().Less(end) {
+		if err := batch.ClearIterRange(iter, start, end); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	lastIndex := entries[len(entries)-1].Index
+	lastTerm := entries[len(entries)-1].Term
+	if err := r.raftMu.stateLoader.setLastIndex(ctx, batch, lastIndex); err != nil {
+		return 0, 0, 0, err
+	}
+	return lastIndex, lastTerm, prevRaftLogSize + delta, nil
+}
+
 // updateRangeInfo is called whenever a range is updated by ApplySnapshot
 // or is created by range splitting to setup the fields which are
 // uninitialized or need updating.
@@ -702,6 +820,7 @@ func (r *Replica) applySnapshot(
 		batch   time.Time
 		entries time.Time
 		commit  time.Time
+		ingest  time.Time
 	}
 
 	var size int
@@ -713,19 +832,27 @@ func (r *Replica) applySnapshot(
 	}
 
 	log.Infof(ctx, "applying %s snapshot at index %d "+
-		"(id=%s, encoded size=%d, %d rocksdb batches, %d log entries)",
+		"(id=%s, encoded size=%d, %d rocksdb batches, %d sstables, %d log entries)",
 		snapType, snap.Metadata.Index, inSnap.SnapUUID.Short(),
-		size, len(inSnap.Batches), len(inSnap.LogEntries))
+		size, len(inSnap.Batches), len(inSnap.SSTPaths), len(inSnap.LogEntries))
 	defer func(start time.Time) {
 		now := timeutil.Now()
-		log.Infof(ctx, "applied %s snapshot in %0.0fms [clear=%0.0fms batch=%0.0fms entries=%0.0fms commit=%0.0fms]",
+		log.Infof(ctx, "applied %s snapshot in %0.0fms [clear=%0.0fms batch=%0.0fms entries=%0.0fms "+
+			"commit=%0.0fms ingest=%0.0fms]",
 			snapType, now.Sub(start).Seconds()*1000,
 			stats.clear.Sub(start).Seconds()*1000,
 			stats.batch.Sub(stats.clear).Seconds()*1000,
 			stats.entries.Sub(stats.batch).Seconds()*1000,
-			stats.commit.Sub(stats.entries).Seconds()*1000)
+			stats.commit.Sub(stats.entries).Seconds()*1000,
+			stats.ingest.Sub(stats.commit).Seconds()*1000)
 	}(timeutil.Now())
 
+	// The SSTables of the snapshot are ingested once the batch clearing the
+	// range's existing data is committed. A marker listing them is committed
+	// along with the batch, so that their ingestion is completed if the
+	// process crashes before it is done.
+	sstPaths := inSnap.SSTPaths
+
 	// Use a more efficient write-only batch because we don't need to do any
 	// reads from the batch.
 	batch := r.store.Engine().NewWriteOnlyBatch()
@@ -769,9 +896,11 @@ func (r *Replica) applySnapshot(
 		}
 	}
 
-	// Write the snapshot's Raft log into the range.
+	// Write the snapshot's Raft log into the range. The entries are written to
+	// the engine even if the store has a separate raft log so that they're
+	// applied atomically with the rest of the snapshot.
 	_, _, raftLogSize, err = r.append(
-		ctx, distinctBatch, 0, 0, raftLogSize, thinEntries,
+		ctx, distinctBatch, nil /* raftLog */, 0, 0, raftLogSize, thinEntries,
 	)
 	if err != nil {
 		return err
@@ -794,6 +923,14 @@ func (r *Replica) applySnapshot(
 		}
 	}
 
+	if len(sstPaths) > 0 {
+		if err := engine.MVCCPutProto(ctx, distinctBatch, nil,
+			keys.StoreSnapshotIngestionKey(r.RangeID), hlc.Timestamp{}, nil,
+			&SnapshotIngestion{Paths: sstPaths}); err != nil {
+			return err
+		}
+	}
+
 	// We need to close the distinct batch and start using the normal batch for
 	// the read below.
 	distinctBatch.Close()
@@ -805,12 +942,43 @@ func (r *Replica) applySnapshot(
 			s.RaftAppliedIndex, snap.Metadata.Index)
 	}
 
-	// We've written Raft log entries, so we need to sync the WAL.
-	if err := batch.Commit(r.store.cfg.Settings.SyncRaftLog.Get()); err != nil {
+	// Read-only commands must not observe the range between the commit of the
+	// batch clearing its data and the ingestion of the snapshot's SSTables, nor
+	// before its in-memory state reflects the snapshot. Together with the
+	// ingestion marker, which has the store complete the ingestion before it
+	// loads its replicas after a crash, this makes the switch to the
+	// snapshot's data atomic.
+	r.readOnlyCmdMu.Lock()
+	defer r.readOnlyCmdMu.Unlock()
+
+	// We've written Raft log entries, so we need to sync the WAL. The ingestion
+	// marker must also be durable before the SSTables are ingested.
+	if err := batch.Commit(r.store.cfg.Settings.SyncRaftLog.Get() || len(sstPaths) > 0); err != nil {
 		return err
 	}
 	stats.commit = timeutil.Now()
 
+	// The ingested SSTables are newer than the deletions in the batch, so
+	// their data isn't cleared.
+	if len(sstPaths) > 0 {
+		if err := ingestSnapshotSSTs(ctx, r.store.Engine(), r.RangeID, sstPaths); err != nil {
+			return err
+		}
+	}
+	stats.ingest = timeutil.Now()
+
+	// The log entries which predate the snapshot are orphaned in the separate
+	// raft log as well. They can only be discarded after the snapshot was
+	// committed; if we crash before they are, the store discards those below
+	// the snapshot's truncated state when it restarts (see
+	// Store.cleanupRaftLog), and the others are shadowed by the snapshot's
+	// entries in the engine until they are replaced.
+	if raftLog := r.store.Engine().RaftLog(); raftLog != nil {
+		if err := raftLog.Destroy(r.RangeID); err != nil {
+			return err
+		}
+	}
+
 	r.mu.Lock()
 	// We set the persisted last index to the last applied index. This is
 	// not a correctness issue, but means that we may have just transferred
@@ -425,6 +425,13 @@ func (r *Replica) requestLeaseLocked(
 		llChan <- roachpb.NewError(newNotLeaseHolderError(nil, r.store.StoreID(), r.mu.state.Desc))
 		return llChan
 	}
+	if !repDesc.IsVoter() {
+		// Learners and non-voters can't hold the lease, so don't bother asking
+		// for it.
+		llChan := make(chan *roachpb.Error, 1)
+		llChan <- roachpb.NewError(newNotLeaseHolderError(nil, r.store.StoreID(), r.mu.state.Desc))
+		return llChan
+	}
 	return r.mu.pendingLeaseRequest.InitOrJoinRequest(
 		ctx, r, repDesc, status, r.mu.state.Desc.StartKey.AsRawKey(), false /* transfer */)
 }
@@ -470,6 +477,10 @@ func (r *Replica) AdminTransferLease(ctx context.Context, target roachpb.StoreID
 		if nextLeaseHolder, ok = desc.GetReplicaDescriptor(target); !ok {
 			return nil, nil, errors.Errorf("unable to find store %d in range %+v", target, desc)
 		}
+		if !nextLeaseHolder.IsVoter() {
+			return nil, nil, errors.Errorf("unable to transfer lease to %s replica %s of range %+v",
+				nextLeaseHolder.GetType(), nextLeaseHolder, desc)
+		}
 
 		if nextLease, ok := r.mu.pendingLeaseRequest.RequestPending(); ok &&
 			nextLease.Replica != nextLeaseHolder {
@@ -0,0 +1,713 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
+	"github.com/cockroachdb/cockroach/pkg/storage/storagebase"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// rangeFeedBufferSize is the number of events buffered per range feed
+// registration between Raft application and the feed's stream. A feed that
+// falls further behind than this is disconnected; the client is expected to
+// reconnect from its last resolved timestamp.
+const rangeFeedBufferSize = 4096
+
+// rangeFeedStream is the subset of roachpb.Internal_RangeFeedServer used by
+// Replica.RangeFeed.
+type rangeFeedStream interface {
+	Context() context.Context
+	Send(*roachpb.RangeFeedEvent) error
+}
+
+// rangeFeedRegistration is a single range feed attached to a replica.
+type rangeFeedRegistration struct {
+	span    roachpb.Span
+	startTS hlc.Timestamp // exclusive
+	eventC  chan *roachpb.RangeFeedEvent
+	// errC receives at most one error, after which the registration no
+	// longer receives events.
+	errC chan *roachpb.Error
+}
+
+// rangeFeedState is the state a replica keeps on behalf of its range feeds.
+// It is only populated while at least one feed is registered.
+type rangeFeedState struct {
+	syncutil.Mutex
+	registrations []*rangeFeedRegistration
+	// intents maps each key in the range holding an unresolved write intent
+	// to the intent's timestamp. Resolved timestamps published to the feeds
+	// are kept below all of these, since the intents may still commit.
+	intents map[string]hlc.Timestamp
+	// initializing is set while the intents already on disk when the first
+	// feed was registered are being scanned, during which no checkpoints are
+	// published. The intents written in the meantime are tracked as usual,
+	// and those resolved are collected in resolvedDuringInit, as the scan
+	// may still report them.
+	initializing       bool
+	resolvedDuringInit map[string]struct{}
+	// generation is incremented whenever the state is reset, so that the
+	// result of a scan started on behalf of earlier feeds is discarded.
+	generation int64
+}
+
+// RangeFeed registers a feed of the committed values written to the given
+// span above the request timestamp and streams them until the stream's
+// context is canceled or the feed is disconnected. Values are followed by
+// periodic checkpoints promising that no further values at or below a
+// resolved timestamp will be emitted for the span. The feed is
+// disconnected with an error when this replica loses its lease or when the
+// range is split or removed; in all cases the client should reconnect from
+// the last resolved timestamp it received.
+func (r *Replica) RangeFeed(args *roachpb.RangeFeedRequest, stream rangeFeedStream) *roachpb.Error {
+	ctx := r.AnnotateCtx(stream.Context())
+
+	rspan, err := rangeFeedRSpan(args.Span)
+	if err != nil {
+		return roachpb.NewError(err)
+	}
+	startTS := args.Timestamp
+	if startTS == (hlc.Timestamp{}) {
+		startTS = r.store.Clock().Now()
+	}
+	if err := r.requestCanProceed(rspan, startTS); err != nil {
+		return roachpb.NewError(err)
+	}
+	if _, pErr := r.redirectOnOrAcquireLease(ctx); pErr != nil {
+		return pErr
+	}
+
+	reg := &rangeFeedRegistration{
+		span:    args.Span,
+		startTS: startTS,
+		eventC:  make(chan *roachpb.RangeFeedEvent, rangeFeedBufferSize),
+		errC:    make(chan *roachpb.Error, 1),
+	}
+
+	// Register under raftMu so that the catch-up snapshot and the events
+	// delivered to the registration neither overlap nor leave a gap. The
+	// snapshot is only read once raftMu is released.
+	r.raftMu.Lock()
+	snap := r.store.Engine().NewSnapshot()
+	desc := r.Desc()
+	initGen, needsInit, err := r.registerRangeFeedRaftMuLocked(reg)
+	r.raftMu.Unlock()
+	if err != nil {
+		snap.Close()
+		return roachpb.NewError(err)
+	}
+	defer r.unregisterRangeFeed(reg)
+
+	if needsInit {
+		err = r.initRangeFeedIntents(snap, desc, initGen)
+	}
+	if err == nil {
+		err = rangeFeedCatchUpScan(snap, reg.span, reg.startTS, stream)
+	}
+	snap.Close()
+	if err != nil {
+		return roachpb.NewError(err)
+	}
+
+	for {
+		select {
+		case event := <-reg.eventC:
+			if err := stream.Send(event); err != nil {
+				return roachpb.NewError(err)
+			}
+		case pErr := <-reg.errC:
+			// Flush the events which preceded the disconnection; they are
+			// still valid and clients rely on seeing them in order.
+			for {
+				select {
+				case event := <-reg.eventC:
+					if err := stream.Send(event); err != nil {
+						return roachpb.NewError(err)
+					}
+				default:
+					return pErr
+				}
+			}
+		case <-ctx.Done():
+			return roachpb.NewError(ctx.Err())
+		case <-r.store.Stopper().ShouldQuiesce():
+			return roachpb.NewError(&roachpb.NodeUnavailableError{})
+		}
+	}
+}
+
+func rangeFeedRSpan(span roachpb.Span) (roachpb.RSpan, error) {
+	if len(span.EndKey) == 0 || bytes.Compare(span.Key, span.EndKey) >= 0 {
+		return roachpb.RSpan{}, errors.Errorf("invalid range feed span %s", span)
+	}
+	if keys.IsLocal(span.Key) || keys.IsLocal(span.EndKey) {
+		return roachpb.RSpan{}, errors.Errorf("range feeds over local keys are not supported: %s", span)
+	}
+	start, err := keys.Addr(span.Key)
+	if err != nil {
+		return roachpb.RSpan{}, err
+	}
+	end, err := keys.AddrUpperBound(span.EndKey)
+	if err != nil {
+		return roachpb.RSpan{}, err
+	}
+	return roachpb.RSpan{Key: start, EndKey: end}, nil
+}
+
+// rangeFeedCatchUpScan sends the committed values in the span written above
+// startTS, as of the given snapshot.
+func rangeFeedCatchUpScan(
+	snap engine.Reader, span roachpb.Span, startTS hlc.Timestamp, stream rangeFeedStream,
+) error {
+	iter := snap.NewIterator(false /* prefix */)
+	defer iter.Close()
+
+	var meta enginepb.MVCCMetadata
+	var intentKey roachpb.Key
+	var intentTS hlc.Timestamp
+	iter.Seek(engine.MakeMVCCMetadataKey(span.Key))
+	for {
+		if ok, err := iter.Valid(); err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+		unsafeKey := iter.UnsafeKey()
+		if bytes.Compare(unsafeKey.Key, span.EndKey) >= 0 {
+			return nil
+		}
+		if !unsafeKey.IsValue() {
+			if err := iter.ValueProto(&meta); err != nil {
+				return err
+			}
+			intentKey = intentKey[:0]
+			if meta.Txn != nil {
+				intentKey = append(intentKey, unsafeKey.Key...)
+				intentTS = meta.Timestamp
+			}
+			iter.Next()
+			continue
+		}
+		if !startTS.Less(unsafeKey.Timestamp) {
+			// Older versions of this key are at or below startTS too.
+			iter.NextKey()
+			continue
+		}
+		if len(intentKey) > 0 && unsafeKey.Key.Equal(intentKey) && unsafeKey.Timestamp == intentTS {
+			// Provisional value; it is emitted on resolution, if it commits.
+			iter.Next()
+			continue
+		}
+		event := &roachpb.RangeFeedEvent{}
+		event.SetValue(&roachpb.RangeFeedValue{
+			Key: append(roachpb.Key(nil), unsafeKey.Key...),
+			Value: roachpb.Value{
+				RawBytes:  append([]byte(nil), iter.UnsafeValue()...),
+				Timestamp: unsafeKey.Timestamp,
+			},
+		})
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+		iter.Next()
+	}
+}
+
+// registerRangeFeedRaftMuLocked adds the registration to the replica. The
+// first registration starts the loop publishing resolved timestamps and
+// returns needsInit, in which case the caller has to scan the snapshot taken
+// alongside the registration for unresolved intents and pass them to
+// initRangeFeedIntents along with the returned generation.
+func (r *Replica) registerRangeFeedRaftMuLocked(
+	reg *rangeFeedRegistration,
+) (generation int64, needsInit bool, _ error) {
+	r.rangeFeedMu.Lock()
+	defer r.rangeFeedMu.Unlock()
+	if len(r.rangeFeedMu.registrations) > 0 {
+		r.rangeFeedMu.registrations = append(r.rangeFeedMu.registrations, reg)
+		return 0, false, nil
+	}
+
+	r.rangeFeedMu.registrations = append(r.rangeFeedMu.registrations, reg)
+	r.rangeFeedMu.intents = make(map[string]hlc.Timestamp)
+	r.rangeFeedMu.initializing = true
+	r.rangeFeedMu.resolvedDuringInit = make(map[string]struct{})
+
+	generation = r.rangeFeedMu.generation
+	stopper := r.store.Stopper()
+	if err := stopper.RunAsyncTask(
+		r.AnnotateCtx(context.Background()), "storage.Replica: range feed checkpoints",
+		func(ctx context.Context) {
+			r.runRangeFeedCheckpoints(ctx, generation)
+		},
+	); err != nil {
+		r.resetRangeFeedsLocked()
+		return 0, false, err
+	}
+	return generation, true, nil
+}
+
+// initRangeFeedIntents scans the snapshot, which was taken when the first of
+// the current feeds was registered, for unresolved intents and merges them
+// with the ones tracked since. If the scan fails, all feeds are disconnected.
+func (r *Replica) initRangeFeedIntents(
+	snap engine.Reader, desc *roachpb.RangeDescriptor, generation int64,
+) error {
+	intents, err := scanRangeFeedIntents(snap, desc)
+
+	r.rangeFeedMu.Lock()
+	defer r.rangeFeedMu.Unlock()
+	if r.rangeFeedMu.generation != generation {
+		// The feeds the scan was started for have all been disconnected.
+		return err
+	}
+	if err != nil {
+		r.disconnectRangeFeedsLocked(roachpb.NewError(err))
+		return err
+	}
+	for key, ts := range intents {
+		if _, ok := r.rangeFeedMu.intents[key]; ok {
+			// Rewritten since; the tracked intent is newer.
+			continue
+		}
+		if _, ok := r.rangeFeedMu.resolvedDuringInit[key]; ok {
+			continue
+		}
+		r.rangeFeedMu.intents[key] = ts
+	}
+	r.rangeFeedMu.initializing = false
+	r.rangeFeedMu.resolvedDuringInit = nil
+	return nil
+}
+
+// scanRangeFeedIntents returns the unresolved intents in the global keyspace
+// of the range.
+func scanRangeFeedIntents(
+	reader engine.Reader, desc *roachpb.RangeDescriptor,
+) (map[string]hlc.Timestamp, error) {
+	start := desc.StartKey.AsRawKey()
+	if start.Compare(keys.LocalMax) < 0 {
+		start = keys.LocalMax
+	}
+	end := desc.EndKey.AsRawKey()
+
+	intents := make(map[string]hlc.Timestamp)
+	iter := reader.NewIterator(false /* prefix */)
+	defer iter.Close()
+	var meta enginepb.MVCCMetadata
+	for iter.Seek(engine.MakeMVCCMetadataKey(start)); ; iter.NextKey() {
+		if ok, err := iter.Valid(); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+		unsafeKey := iter.UnsafeKey()
+		if bytes.Compare(unsafeKey.Key, end) >= 0 {
+			break
+		}
+		if unsafeKey.IsValue() {
+			continue
+		}
+		if err := iter.ValueProto(&meta); err != nil {
+			return nil, err
+		}
+		if meta.Txn != nil {
+			intents[string(unsafeKey.Key)] = meta.Timestamp
+		}
+	}
+	return intents, nil
+}
+
+func (r *Replica) unregisterRangeFeed(reg *rangeFeedRegistration) {
+	r.rangeFeedMu.Lock()
+	defer r.rangeFeedMu.Unlock()
+	r.removeRangeFeedLocked(reg)
+}
+
+func (r *Replica) removeRangeFeedLocked(reg *rangeFeedRegistration) {
+	regs := r.rangeFeedMu.registrations
+	for i := range regs {
+		if regs[i] == reg {
+			regs[i] = regs[len(regs)-1]
+			regs[len(regs)-1] = nil
+			r.rangeFeedMu.registrations = regs[:len(regs)-1]
+			break
+		}
+	}
+	if len(r.rangeFeedMu.registrations) == 0 {
+		r.resetRangeFeedsLocked()
+	}
+}
+
+// resetRangeFeedsLocked drops all of the replica's range feed state.
+func (r *Replica) resetRangeFeedsLocked() {
+	r.rangeFeedMu.registrations = nil
+	r.rangeFeedMu.intents = nil
+	r.rangeFeedMu.initializing = false
+	r.rangeFeedMu.resolvedDuringInit = nil
+	r.rangeFeedMu.generation++
+}
+
+// disconnectRangeFeedsLocked disconnects all of the replica's range feeds
+// with the given error.
+func (r *Replica) disconnectRangeFeedsLocked(pErr *roachpb.Error) {
+	for _, reg := range r.rangeFeedMu.registrations {
+		reg.errC <- pErr
+	}
+	r.resetRangeFeedsLocked()
+}
+
+// disconnectRangeFeeds is like disconnectRangeFeedsLocked, but acquires the
+// lock itself.
+func (r *Replica) disconnectRangeFeeds(pErr *roachpb.Error) {
+	r.rangeFeedMu.Lock()
+	defer r.rangeFeedMu.Unlock()
+	r.disconnectRangeFeedsLocked(pErr)
+}
+
+// publishRangeFeedEventLocked hands the event to the registration,
+// disconnecting it with a retriable error if its buffer is full.
+func (r *Replica) publishRangeFeedEventLocked(
+	reg *rangeFeedRegistration, event *roachpb.RangeFeedEvent,
+) {
+	select {
+	case reg.eventC <- event:
+	default:
+		reg.errC <- roachpb.NewError(roachpb.NewRangeFeedRetryError(
+			fmt.Sprintf("range feed on %s fell too far behind", reg.span)))
+		r.removeRangeFeedLocked(reg)
+	}
+}
+
+// rangeFeedOps collects the MVCC operations a write batch performed on a
+// single key.
+type rangeFeedOps struct {
+	key         roachpb.Key
+	meta        *enginepb.MVCCMetadata // the metadata written, if any
+	metaDeleted bool
+	value       *roachpb.Value // the version written, if any
+	valueDelete bool           // a version was removed
+}
+
+// handleRangeFeedRaftMuLocked is called after a command was applied to the
+// replica. It translates the command's write batch into the committed
+// values it revealed and publishes them to the registered range feeds, and
+// keeps track of the intents it wrote and resolved.
+func (r *Replica) handleRangeFeedRaftMuLocked(
+	ctx context.Context,
+	rResult storagebase.ReplicatedEvalResult,
+	writeBatch *storagebase.WriteBatch,
+) {
+	r.rangeFeedMu.Lock()
+	defer r.rangeFeedMu.Unlock()
+	if len(r.rangeFeedMu.registrations) == 0 {
+		return
+	}
+
+	if writeBatch != nil {
+		if err := r.handleRangeFeedBatchLocked(ctx, writeBatch.Data); err != nil {
+			log.Errorf(ctx, "disconnecting range feeds: %s", err)
+			r.disconnectRangeFeedsLocked(roachpb.NewError(err))
+			return
+		}
+	}
+
+	if rResult.Split != nil {
+		// The feeds may now span keys this replica no longer serves.
+		desc := rResult.Split.LeftDesc
+		for _, reg := range r.rangeFeedMu.registrations {
+			reg.errC <- roachpb.NewError(
+				roachpb.NewRangeKeyMismatchError(reg.span.Key, reg.span.EndKey, &desc))
+		}
+		r.resetRangeFeedsLocked()
+	}
+}
+
+func (r *Replica) handleRangeFeedBatchLocked(ctx context.Context, repr []byte) error {
+	reader, err := engine.NewRocksDBBatchReader(repr)
+	if err != nil {
+		return err
+	}
+	var order []*rangeFeedOps
+	opsByKey := make(map[string]*rangeFeedOps)
+	for reader.Next() {
+		mvccKey, err := engine.DecodeKey(reader.UnsafeKey())
+		if err != nil {
+			return err
+		}
+		if keys.IsLocal(mvccKey.Key) {
+			continue
+		}
+		ops, ok := opsByKey[string(mvccKey.Key)]
+		if !ok {
+			ops = &rangeFeedOps{key: append(roachpb.Key(nil), mvccKey.Key...)}
+			opsByKey[string(ops.key)] = ops
+			order = append(order, ops)
+		}
+		switch reader.BatchType() {
+		case engine.BatchTypeValue:
+			if !mvccKey.IsValue() {
+				ops.meta = &enginepb.MVCCMetadata{}
+				if err := ops.meta.Unmarshal(reader.UnsafeValue()); err != nil {
+					return err
+				}
+				ops.metaDeleted = false
+			} else {
+				ops.value = &roachpb.Value{
+					RawBytes:  append([]byte(nil), reader.UnsafeValue()...),
+					Timestamp: mvccKey.Timestamp,
+				}
+			}
+		case engine.BatchTypeDeletion:
+			if !mvccKey.IsValue() {
+				ops.meta = nil
+				ops.metaDeleted = true
+			} else {
+				ops.valueDelete = true
+			}
+		default:
+			// Merges only apply to inline values (e.g. time series), which
+			// are not versioned and not published.
+		}
+	}
+	if err := reader.Error(); err != nil {
+		return err
+	}
+
+	for _, ops := range order {
+		switch {
+		case ops.meta != nil && ops.meta.Txn != nil:
+			// A new or rewritten intent.
+			r.rangeFeedMu.intents[string(ops.key)] = ops.meta.Timestamp
+		case ops.meta != nil:
+			// An inline value.
+		case ops.metaDeleted:
+			// An intent was resolved. If it was committed at its provisional
+			// timestamp, the version is left untouched and has to be read
+			// back; if it was committed at a pushed timestamp, the version
+			// was rewritten; if it was aborted, the version was removed.
+			delete(r.rangeFeedMu.intents, string(ops.key))
+			if r.rangeFeedMu.initializing {
+				r.rangeFeedMu.resolvedDuringInit[string(ops.key)] = struct{}{}
+			}
+			if ops.value != nil {
+				r.publishRangeFeedValueLocked(ops.key, *ops.value)
+			} else if !ops.valueDelete {
+				value, ok, err := r.readLatestVersion(ops.key)
+				if err != nil {
+					return err
+				}
+				if ok {
+					r.publishRangeFeedValueLocked(ops.key, value)
+				}
+			}
+		case ops.value != nil:
+			// A non-transactional write.
+			r.publishRangeFeedValueLocked(ops.key, *ops.value)
+		default:
+			// Versions removed by GC.
+		}
+	}
+	return nil
+}
+
+// readLatestVersion returns the newest version of the key, including
+// deletion tombstones.
+func (r *Replica) readLatestVersion(key roachpb.Key) (roachpb.Value, bool, error) {
+	iter := r.store.Engine().NewIterator(true /* prefix */)
+	defer iter.Close()
+	iter.Seek(engine.MakeMVCCMetadataKey(key))
+	for ; ; iter.Next() {
+		if ok, err := iter.Valid(); err != nil || !ok {
+			return roachpb.Value{}, false, err
+		}
+		unsafeKey := iter.UnsafeKey()
+		if !unsafeKey.Key.Equal(key) {
+			return roachpb.Value{}, false, nil
+		}
+		if unsafeKey.IsValue() {
+			return roachpb.Value{
+				RawBytes:  append([]byte(nil), iter.UnsafeValue()...),
+				Timestamp: unsafeKey.Timestamp,
+			}, true, nil
+		}
+	}
+}
+
+func (r *Replica) publishRangeFeedValueLocked(key roachpb.Key, value roachpb.Value) {
+	var event *roachpb.RangeFeedEvent
+	for _, reg := range append([]*rangeFeedRegistration(nil), r.rangeFeedMu.registrations...) {
+		if key.Compare(reg.span.Key) < 0 || key.Compare(reg.span.EndKey) >= 0 ||
+			!reg.startTS.Less(value.Timestamp) {
+			continue
+		}
+		if event == nil {
+			event = &roachpb.RangeFeedEvent{}
+			event.SetValue(&roachpb.RangeFeedValue{Key: key, Value: value})
+		}
+		r.publishRangeFeedEventLocked(reg, event)
+	}
+}
+
+// runRangeFeedCheckpoints periodically publishes resolved timestamps to the
+// replica's range feeds until none of the given generation are left.
+func (r *Replica) runRangeFeedCheckpoints(ctx context.Context, generation int64) {
+	var timer timeutil.Timer
+	defer timer.Stop()
+	for {
+		timer.Reset(r.store.cfg.Settings.RangeFeedCheckpointInterval.Get())
+		select {
+		case <-timer.C:
+			timer.Read = true
+		case <-r.store.Stopper().ShouldQuiesce():
+			r.disconnectRangeFeeds(roachpb.NewError(&roachpb.NodeUnavailableError{}))
+			return
+		}
+		if !r.checkpointRangeFeeds(ctx, generation) {
+			return
+		}
+	}
+}
+
+// checkpointRangeFeeds closes a timestamp on the spans of the replica's
+// range feeds and publishes it, held back by any unresolved intents, as the
+// feeds' resolved timestamp. Returns false once the feeds of the given
+// generation are gone.
+//
+// Closing timestamp T works by recording a read of the feeds' spans at T in
+// the timestamp cache, which forces all future writes to them above T, and
+// then waiting in the command queue behind all writes at or below T, so that
+// they have been applied (and published) by the time the checkpoint is. T
+// lags the present by kv.rangefeed.closed_timestamp_lag, and is never closed
+// above an unresolved intent, since the resolved timestamp could not advance
+// past the intent anyway; transactions are only pushed by the feeds if they
+// take longer than the lag to write to a span that has none of their intents.
+func (r *Replica) checkpointRangeFeeds(ctx context.Context, generation int64) bool {
+	now := r.store.Clock().Now()
+	desc := r.Desc()
+	start := desc.StartKey.AsRawKey()
+	if start.Compare(keys.LocalMax) < 0 {
+		start = keys.LocalMax
+	}
+	end := desc.EndKey.AsRawKey()
+	closed := now.Add(-r.store.cfg.Settings.RangeFeedClosedTSLag.Get().Nanoseconds(), 0)
+
+	// Only the feeds registered at this point are covered by the closed
+	// timestamp; those registered later are checkpointed next time.
+	var spans SpanSet
+	var feedSpans []roachpb.Span
+	r.rangeFeedMu.Lock()
+	if r.rangeFeedMu.generation != generation {
+		r.rangeFeedMu.Unlock()
+		return false
+	}
+	regs := append([]*rangeFeedRegistration(nil), r.rangeFeedMu.registrations...)
+	initializing := r.rangeFeedMu.initializing
+	for _, reg := range regs {
+		span := clampRangeFeedSpan(reg.span, start, end)
+		spans.Add(SpanReadOnly, span)
+		feedSpans = append(feedSpans, span)
+	}
+	for _, ts := range r.rangeFeedMu.intents {
+		if !closed.Less(ts) {
+			closed = ts.Prev()
+		}
+	}
+	r.rangeFeedMu.Unlock()
+	if len(regs) == 0 {
+		return false
+	}
+	if initializing {
+		// The unresolved intents are not known yet.
+		return true
+	}
+
+	if !r.ownsValidLease(now) {
+		lease, _ := r.getLease()
+		r.disconnectRangeFeeds(roachpb.NewError(
+			newNotLeaseHolderError(&lease, r.store.StoreID(), desc)))
+		return false
+	}
+
+	r.store.tsCacheMu.Lock()
+	for _, span := range feedSpans {
+		r.store.tsCacheMu.cache.add(span.Key, span.EndKey, closed, nil, true /* readTSCache */)
+	}
+	r.store.tsCacheMu.Unlock()
+
+	var ba roachpb.BatchRequest
+	ba.Timestamp = closed
+	ec, err := r.beginCmds(ctx, &ba, &spans)
+	if err != nil {
+		return true
+	}
+	r.removeCmdsFromCommandQueue(ec.cmds)
+
+	r.rangeFeedMu.Lock()
+	defer r.rangeFeedMu.Unlock()
+	if r.rangeFeedMu.generation != generation {
+		// The feeds were all disconnected in the meantime.
+		return false
+	}
+	resolved := closed
+	for _, ts := range r.rangeFeedMu.intents {
+		if !resolved.Less(ts) {
+			resolved = ts.Prev()
+		}
+	}
+	for i, reg := range regs {
+		if !r.hasRangeFeedLocked(reg) {
+			continue
+		}
+		event := &roachpb.RangeFeedEvent{}
+		event.SetValue(&roachpb.RangeFeedCheckpoint{Span: feedSpans[i], ResolvedTS: resolved})
+		r.publishRangeFeedEventLocked(reg, event)
+	}
+	return len(r.rangeFeedMu.registrations) > 0
+}
+
+// clampRangeFeedSpan returns the part of the feed's span within the range's
+// global keyspace, which starts at start and ends at end.
+func clampRangeFeedSpan(span roachpb.Span, start, end roachpb.Key) roachpb.Span {
+	if span.Key.Compare(start) < 0 {
+		span.Key = start
+	}
+	if span.EndKey.Compare(end) > 0 {
+		span.EndKey = end
+	}
+	return span
+}
+
+func (r *Replica) hasRangeFeedLocked(reg *rangeFeedRegistration) bool {
+	for _, other := range r.rangeFeedMu.registrations {
+		if other == reg {
+			return true
+		}
+	}
+	return false
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/pkg/errors"
 )
 
@@ -34,14 +35,27 @@ var _ sideloadStorage = &diskSideloadStorage{}
 type diskSideloadStorage struct {
 	st  *cluster.Settings
 	dir string
+	// eng, if set, is used to read and write the sideloaded files so that they
+	// are encrypted along with the rest of the store's data.
+	eng engine.Engine
 }
 
 func newDiskSideloadStorage(
-	st *cluster.Settings, rangeID roachpb.RangeID, replicaID roachpb.ReplicaID, baseDir string,
+	st *cluster.Settings,
+	rangeID roachpb.RangeID,
+	replicaID roachpb.ReplicaID,
+	baseDir string,
+	eng engine.Engine,
 ) (sideloadStorage, error) {
+	if _, ok := eng.(engine.InMem); ok {
+		// In-memory engines have an in-memory env, but the sideloaded files live
+		// on disk.
+		eng = nil
+	}
 	ss := &diskSideloadStorage{
 		dir: filepath.Join(baseDir, fmt.Sprintf("%d.%d", rangeID, replicaID)),
 		st:  st,
+		eng: eng,
 	}
 	if err := ss.createDir(); err != nil {
 		return nil, err
@@ -67,6 +81,12 @@ func (ss *diskSideloadStorage) PutIfNotExists(
 	}
 	// File does not exist yet. There's a chance the whole path is missing (for
 	// example after Clear()), in which case handle that transparently.
+	if ss.eng != nil {
+		if err := ss.createDir(); err != nil {
+			return err
+		}
+		return ss.eng.WriteFile(filename, contents)
+	}
 	for {
 		// Use 0644 since that's what RocksDB uses:
 		// https://github.com/facebook/rocksdb/blob/56656e12d67d8a63f1e4c4214da9feeec2bd442b/env/env_posix.cc#L171
@@ -84,6 +104,13 @@ func (ss *diskSideloadStorage) PutIfNotExists(
 
 func (ss *diskSideloadStorage) Get(ctx context.Context, index, term uint64) ([]byte, error) {
 	filename := ss.filename(ctx, index, term)
+	if ss.eng != nil {
+		// The engine's errors don't allow distinguishing a missing file.
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return nil, errSideloadedFileNotFound
+		}
+		return ss.eng.ReadFile(filename)
+	}
 	b, err := ioutil.ReadFile(filename)
 	if os.IsNotExist(err) {
 		return nil, errSideloadedFileNotFound
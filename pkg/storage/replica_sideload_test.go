@@ -30,6 +30,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/storage/storagebase"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
@@ -80,16 +81,37 @@ func mkEnt(
 func TestSideloadingSideloadedStorage(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	t.Run("Mem", func(t *testing.T) {
-		testSideloadingSideloadedStorage(t, newInMemSideloadStorage)
+		testSideloadingSideloadedStorage(t, newInMemSideloadStorage, nil /* eng */)
 	})
 	t.Run("Disk", func(t *testing.T) {
-		testSideloadingSideloadedStorage(t, newDiskSideloadStorage)
+		testSideloadingSideloadedStorage(t, newDiskSideloadStorage, nil /* eng */)
+	})
+	t.Run("DiskEngine", func(t *testing.T) {
+		dir, cleanup := testutils.TempDir(t)
+		defer cleanup()
+
+		eng, err := engine.NewRocksDB(
+			engine.RocksDBConfig{
+				RocksDBSettings: cluster.MakeTestingClusterSettings().RocksDBSettings,
+				Dir:             dir,
+			},
+			engine.RocksDBCache{},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer eng.Close()
+
+		testSideloadingSideloadedStorage(t, newDiskSideloadStorage, eng)
 	})
 }
 
 func testSideloadingSideloadedStorage(
 	t *testing.T,
-	maker func(*cluster.Settings, roachpb.RangeID, roachpb.ReplicaID, string) (sideloadStorage, error),
+	maker func(
+		*cluster.Settings, roachpb.RangeID, roachpb.ReplicaID, string, engine.Engine,
+	) (sideloadStorage, error),
+	eng engine.Engine,
 ) {
 	dir, cleanup := testutils.TempDir(t)
 	defer cleanup()
@@ -97,7 +119,7 @@ func testSideloadingSideloadedStorage(
 	ctx := context.Background()
 	st := cluster.MakeTestingClusterSettings()
 
-	ss, err := maker(st, 1, 2, dir)
+	ss, err := maker(st, 1, 2, dir, eng)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -196,7 +218,7 @@ func testSideloadingSideloadedStorage(
 	}
 
 	// Verify a sideloaded storage for another ReplicaID doesn't see the files.
-	if otherSS, err := maker(st, 1, 999 /* ReplicaID */, dir); err != nil {
+	if otherSS, err := maker(st, 1, 999 /* ReplicaID */, dir, eng); err != nil {
 		t.Fatal(err)
 	} else if _, err = otherSS.Get(ctx, payloads[0], highTerm); err != errSideloadedFileNotFound {
 		t.Fatal("expected not found")
@@ -206,7 +228,7 @@ func testSideloadingSideloadedStorage(
 	// one), which shouldn't change anything about its state.
 	if !isInMem {
 		var err error
-		ss, err = maker(st, 1, 2, dir)
+		ss, err = maker(st, 1, 2, dir, eng)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -675,7 +697,8 @@ func TestRaftSSTableSideloadingSnapshot(t *testing.T) {
 				ss = tc.repl.raftMu.sideloaded
 			}
 			entries, err := entries(
-				ctx, tc.store.Engine(), tc.repl.RangeID, tc.store.raftEntryCache, ss, sideloadedIndex, sideloadedIndex+1, 1<<20,
+				ctx, tc.store.Engine(), tc.store.Engine().RaftLog(), tc.repl.RangeID, tc.store.raftEntryCache, ss,
+				sideloadedIndex, sideloadedIndex+1, 1<<20,
 			)
 			if err != nil {
 				t.Fatal(err)
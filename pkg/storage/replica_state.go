@@ -625,6 +625,11 @@ func (rec ReplicaEvalContext) StoreID() roachpb.StoreID {
 	return rec.repl.store.StoreID()
 }
 
+// Locality returns the locality of the Replica's node.
+func (rec ReplicaEvalContext) Locality() roachpb.Locality {
+	return rec.repl.store.nodeDesc.Locality
+}
+
 // RangeID returns the Replica's RangeID.
 func (rec ReplicaEvalContext) RangeID() roachpb.RangeID {
 	return rec.repl.RangeID
@@ -41,6 +41,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
 	"github.com/cockroachdb/cockroach/pkg/storage/storagebase"
@@ -1913,6 +1914,68 @@ func TestLeaseConcurrent(t *testing.T) {
 	}
 }
 
+// TestReplicaClosedTimestamp verifies that writes are moved above the
+// closed timestamp of the range and that a write which ends up at or below it
+// is not proposed.
+func TestReplicaClosedTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	tc := testContext{}
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	tc.Start(t, stopper)
+
+	closedTS := tc.Clock().Now().Add(10*time.Second.Nanoseconds(), 0)
+	tc.repl.mu.Lock()
+	tc.repl.mu.closedTimestamp = closedTS
+	tc.repl.mu.Unlock()
+
+	key := roachpb.Key("a")
+	pArgs := putArgs(key, []byte("value"))
+	if _, pErr := tc.SendWrappedWith(roachpb.Header{Timestamp: tc.Clock().Now()}, &pArgs); pErr != nil {
+		t.Fatal(pErr)
+	}
+	gArgs := getArgs(key)
+	resp, pErr := tc.SendWrappedWith(roachpb.Header{Timestamp: closedTS.Add(1, 0)}, &gArgs)
+	if pErr != nil {
+		t.Fatal(pErr)
+	}
+	if ts := resp.(*roachpb.GetResponse).Value.Timestamp; !closedTS.Less(ts) {
+		t.Errorf("expected the write to be moved above the closed timestamp %s, but it's at %s", closedTS, ts)
+	}
+
+	for _, c := range []struct {
+		ts       hlc.Timestamp
+		expected bool
+	}{
+		{closedTS.Add(-1, 0), false},
+		{closedTS, false},
+		{closedTS.Next(), true},
+	} {
+		var ba roachpb.BatchRequest
+		ba.Timestamp = c.ts
+		ba.Add(&pArgs)
+		proposal := &ProposalData{Request: &ba}
+		tc.repl.mu.Lock()
+		ok := tc.repl.maybeCloseTimestampLocked(proposal)
+		tc.repl.mu.Unlock()
+		if ok != c.expected {
+			t.Errorf("%s: expected %t, got %t", c.ts, c.expected, ok)
+		}
+		// The range has no non-voters, so it doesn't close any timestamps.
+		if proposal.command.ClosedTimestamp != nil {
+			t.Errorf("%s: unexpected closed timestamp %s", c.ts, proposal.command.ClosedTimestamp)
+		}
+	}
+
+	// Only non-voters serve follower reads.
+	var ba roachpb.BatchRequest
+	ba.Timestamp = closedTS
+	ba.Add(&gArgs)
+	if tc.repl.canServeFollowerRead(ba) {
+		t.Errorf("expected a voter not to serve follower reads")
+	}
+}
+
 // TestReplicaUpdateTSCache verifies that reads and writes update the
 // timestamp cache.
 func TestReplicaUpdateTSCache(t *testing.T) {
@@ -4706,6 +4769,187 @@ func TestPushTxnQueryPusheeHasNewerVersion(t *testing.T) {
 	}
 }
 
+// stageTxn writes an intent for the transaction at its key and stages its
+// record with that intent as its only in-flight write.
+func stageTxn(t *testing.T, tc *testContext, txn *roachpb.Transaction) *roachpb.Transaction {
+	key := txn.Key
+	_, btH := beginTxnArgs(key, txn)
+	put := putArgs(key, key)
+	if _, pErr := maybeWrapWithBeginTransaction(context.Background(), tc.Sender(), btH, &put); pErr != nil {
+		t.Fatal(pErr)
+	}
+	inFlight := []roachpb.SequencedWrite{{Key: key, Sequence: txn.Sequence}}
+	txn.Sequence++
+	txn.Writing = true
+	args, h := endTxnArgs(txn, true /* commit */)
+	args.InFlightWrites = inFlight
+	resp, pErr := tc.SendWrappedWith(h, &args)
+	if pErr != nil {
+		t.Fatal(pErr)
+	}
+	staged := resp.(*roachpb.EndTransactionResponse).Txn
+	if staged.Status != roachpb.STAGING {
+		t.Fatalf("expected transaction to be staged, got %s", staged)
+	}
+	if !reflect.DeepEqual(staged.InFlightWrites, inFlight) {
+		t.Fatalf("expected in-flight writes %+v, got %+v", inFlight, staged.InFlightWrites)
+	}
+	return staged
+}
+
+// TestEndTransactionStaging verifies that a commit with in-flight writes
+// stages the transaction record, which can't be pushed, leads pushers to
+// recover it once it has expired and is committed explicitly by the
+// coordinator.
+func TestEndTransactionStaging(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer setTxnAutoGC(false)()
+	tc := testContext{}
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	tc.Start(t, stopper)
+
+	key := roachpb.Key("a")
+	txn := newTransaction("test", key, 1, enginepb.SERIALIZABLE, tc.Clock())
+	stageTxn(t, &tc, txn)
+
+	pusher := newTransaction("pusher", key, 1, enginepb.SERIALIZABLE, tc.Clock())
+	pushArgs := pushTxnArgs(pusher, txn, roachpb.PUSH_ABORT)
+	if _, pErr := tc.SendWrapped(&pushArgs); pErr == nil {
+		t.Fatalf("unexpected push success")
+	} else if _, ok := pErr.GetDetail().(*roachpb.TransactionPushError); !ok {
+		t.Fatalf("expected txn push error: %s", pErr)
+	}
+
+	// Once the coordinator stops heartbeating, pushers learn that they have
+	// to recover the transaction.
+	pushArgs.Now = tc.Clock().Now().Add(2*base.DefaultHeartbeatInterval.Nanoseconds()+1, 0)
+	pushArgs.PushTo = pushArgs.Now
+	if _, pErr := tc.SendWrapped(&pushArgs); pErr == nil {
+		t.Fatalf("unexpected push success")
+	} else if icErr, ok := pErr.GetDetail().(*roachpb.IndeterminateCommitError); !ok {
+		t.Fatalf("expected indeterminate commit error: %s", pErr)
+	} else if icErr.StagingTxn.Status != roachpb.STAGING || icErr.StagingTxn.ID != txn.ID {
+		t.Fatalf("expected the staged transaction in the error, got %s", icErr.StagingTxn)
+	}
+
+	// The coordinator proved the in-flight write and commits explicitly.
+	txn.Sequence++
+	args, h := endTxnArgs(txn, true /* commit */)
+	resp, pErr := tc.SendWrappedWith(h, &args)
+	if pErr != nil {
+		t.Fatal(pErr)
+	}
+	if committed := resp.(*roachpb.EndTransactionResponse).Txn; committed.Status != roachpb.COMMITTED ||
+		len(committed.InFlightWrites) != 0 {
+		t.Fatalf("expected committed transaction without in-flight writes, got %s", committed)
+	}
+}
+
+// TestQueryIntent verifies that QueryIntent finds intents written by the
+// queried transaction at or after the queried sequence number only.
+func TestQueryIntent(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	tc := testContext{}
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	tc.Start(t, stopper)
+
+	key := roachpb.Key("a")
+	txn := newTransaction("test", key, 1, enginepb.SERIALIZABLE, tc.Clock())
+	_, btH := beginTxnArgs(key, txn)
+	put := putArgs(key, key)
+	if _, pErr := maybeWrapWithBeginTransaction(context.Background(), tc.Sender(), btH, &put); pErr != nil {
+		t.Fatal(pErr)
+	}
+	other := newTransaction("other", key, 1, enginepb.SERIALIZABLE, tc.Clock())
+
+	laterSeq := txn.TxnMeta
+	laterSeq.Sequence++
+	laterEpoch := txn.TxnMeta
+	laterEpoch.Epoch++
+	earlierTS := txn.TxnMeta
+	earlierTS.Timestamp = txn.Timestamp.Add(-1, 0)
+
+	testCases := []struct {
+		key   roachpb.Key
+		txn   enginepb.TxnMeta
+		found bool
+	}{
+		{key, txn.TxnMeta, true},
+		{key.Next(), txn.TxnMeta, false},
+		{key, other.TxnMeta, false},
+		{key, laterSeq, false},
+		{key, laterEpoch, false},
+		{key, earlierTS, false},
+	}
+	for i, c := range testCases {
+		for _, errorIfMissing := range []bool{false, true} {
+			args := roachpb.QueryIntentRequest{
+				Span:           roachpb.Span{Key: c.key},
+				Txn:            c.txn,
+				ErrorIfMissing: errorIfMissing,
+			}
+			resp, pErr := tc.SendWrapped(&args)
+			if !c.found && errorIfMissing {
+				if _, ok := pErr.GetDetail().(*roachpb.TransactionRetryError); !ok {
+					t.Errorf("%d: expected txn retry error, got %v", i, pErr)
+				}
+				continue
+			}
+			if pErr != nil {
+				t.Fatalf("%d: %s", i, pErr)
+			}
+			if found := resp.(*roachpb.QueryIntentResponse).FoundIntent; found != c.found {
+				t.Errorf("%d: expected found=%t, got %t", i, c.found, found)
+			}
+		}
+	}
+}
+
+// TestRecoverTxn verifies that RecoverTxn commits or aborts a staged
+// transaction depending on whether its in-flight writes were found, and
+// leaves the record alone if it changed since it was queried.
+func TestRecoverTxn(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer setTxnAutoGC(false)()
+	tc := testContext{}
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	tc.Start(t, stopper)
+
+	for i, c := range []struct {
+		implicitlyCommitted bool
+		epochChanged        bool
+		expStatus           roachpb.TransactionStatus
+	}{
+		{true, false, roachpb.COMMITTED},
+		{false, false, roachpb.ABORTED},
+		{true, true, roachpb.STAGING},
+	} {
+		key := roachpb.Key(fmt.Sprintf("key-%d", i))
+		txn := newTransaction("test", key, 1, enginepb.SERIALIZABLE, tc.Clock())
+		staged := stageTxn(t, &tc, txn)
+
+		meta := staged.TxnMeta
+		if c.epochChanged {
+			meta.Epoch++
+		}
+		args := roachpb.RecoverTxnRequest{
+			Span:                roachpb.Span{Key: key},
+			Txn:                 meta,
+			ImplicitlyCommitted: c.implicitlyCommitted,
+		}
+		resp, pErr := tc.SendWrapped(&args)
+		if pErr != nil {
+			t.Fatalf("%d: %s", i, pErr)
+		}
+		if recovered := resp.(*roachpb.RecoverTxnResponse).RecoveredTxn; recovered.Status != c.expStatus {
+			t.Errorf("%d: expected status %s, got %s", i, c.expStatus, recovered)
+		}
+	}
+}
+
 // TestPushTxnHeartbeatTimeout verifies that a txn which
 // hasn't been heartbeat within 2x the heartbeat interval can be
 // pushed/aborted.
@@ -5406,6 +5650,109 @@ func TestTruncateLog(t *testing.T) {
 	}
 }
 
+// TestReplicaSeparateRaftLog verifies that a replica whose store keeps its
+// raft log in a separate log appends, reads and truncates its entries there,
+// and that the store discards entries orphaned by a crash.
+func TestReplicaSeparateRaftLog(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	eng, err := engine.NewRocksDB(
+		engine.RocksDBConfig{
+			RocksDBSettings: cluster.MakeTestingClusterSettings().RocksDBSettings,
+			Dir:             dir,
+			RaftLogFormat:   engine.RaftLogSeparate,
+		},
+		engine.RocksDBCache{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := testContext{engine: eng}
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	stopper.AddCloser(eng)
+	tc.Start(t, stopper)
+	tc.repl.store.SetRaftLogQueueActive(false)
+
+	raftLog := eng.RaftLog()
+	rangeID := tc.repl.RangeID
+
+	var indexes []uint64
+	for i := 0; i < 10; i++ {
+		args := incrementArgs([]byte("a"), int64(i))
+		if _, pErr := tc.SendWrapped(&args); pErr != nil {
+			t.Fatal(pErr)
+		}
+		idx, err := tc.repl.GetLastIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, idx)
+	}
+
+	// None of the entries are stored in the engine.
+	prefix := keys.RaftLogPrefix(rangeID)
+	if kvs, err := engine.Scan(
+		eng, engine.MakeMVCCMetadataKey(prefix), engine.MakeMVCCMetadataKey(prefix.PrefixEnd()), 0,
+	); err != nil {
+		t.Fatal(err)
+	} else if len(kvs) != 0 {
+		t.Fatalf("expected no raft log entries in the engine, found %d", len(kvs))
+	}
+
+	// The entries are read from the separate log once they're evicted from
+	// the entry cache.
+	tc.store.raftEntryCache.clearTo(rangeID, indexes[9]+1)
+	tc.repl.mu.Lock()
+	entries, err := tc.repl.raftEntriesLocked(indexes[0], indexes[9]+1, math.MaxUint64)
+	tc.repl.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != int(indexes[9]+1-indexes[0]) {
+		t.Fatalf("expected %d entries, got %d", indexes[9]+1-indexes[0], len(entries))
+	}
+
+	// Truncating the log discards the entries from the separate log, and the
+	// replica accounts for the freed entries itself.
+	truncateArgs := truncateLogArgs(indexes[5], rangeID)
+	if _, pErr := tc.SendWrappedWith(roachpb.Header{RangeID: 1}, &truncateArgs); pErr != nil {
+		t.Fatal(pErr)
+	}
+	if size := raftLog.Size(rangeID, indexes[5]); size != 0 {
+		t.Errorf("expected truncated entries to be discarded, found %d bytes", size)
+	}
+	testutils.SucceedsSoon(t, func() error {
+		tc.repl.mu.Lock()
+		raftLogSize := tc.repl.mu.raftLogSize
+		tc.repl.mu.Unlock()
+		if size := raftLog.Size(rangeID, math.MaxUint64); raftLogSize != size {
+			return errors.Errorf("expected raft log size %d, got %d", size, raftLogSize)
+		}
+		return nil
+	})
+	tc.repl.mu.Lock()
+	_, err = tc.repl.raftEntriesLocked(indexes[4], indexes[9], math.MaxUint64)
+	tc.repl.mu.Unlock()
+	if err != raft.ErrCompacted {
+		t.Errorf("expected ErrCompacted, got %v", err)
+	}
+
+	// Entries of replicas which were removed before their entries were
+	// discarded are cleaned up when the store starts.
+	if _, err := raftLog.Append(rangeID+1, 1, [][]byte{[]byte("orphan")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.store.cleanupRaftLog(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if ids, expected := raftLog.RangeIDs(), []roachpb.RangeID{rangeID}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected ranges %v in the separate raft log, got %v", expected, ids)
+	}
+}
+
 // TestConditionFailedError tests that a ConditionFailedError correctly
 // bubbles up from MVCC to Range.
 func TestConditionFailedError(t *testing.T) {
@@ -7654,6 +8001,50 @@ func TestCommandTimeThreshold(t *testing.T) {
 	}
 }
 
+// TestGCProtectedTSReadAt verifies that a GC request is rejected if its
+// protected timestamp records were read before the range's protected
+// timestamp check key, and accepted otherwise.
+func TestGCProtectedTSReadAt(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	tc := testContext{}
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	tc.Start(t, stopper)
+
+	readAt := tc.Clock().Now()
+
+	// A job verifies its newly committed record against the range.
+	gArgs := getArgs(keys.RangeProtectedTSCheckKey(tc.repl.Desc().StartKey))
+	if _, pErr := tc.SendWrappedWith(roachpb.Header{
+		Timestamp: tc.Clock().Now(),
+	}, &gArgs); pErr != nil {
+		t.Fatal(pErr)
+	}
+
+	// A GC request whose records were read before the job's read is rejected.
+	gcr := roachpb.GCRequest{
+		Threshold:         readAt,
+		ProtectedTSReadAt: readAt,
+	}
+	if _, pErr := tc.SendWrappedWith(roachpb.Header{RangeID: 1}, &gcr); !testutils.IsPError(
+		pErr, "GC must read them again",
+	) {
+		t.Fatalf("unexpected error: %v", pErr)
+	}
+	if threshold := tc.repl.GetGCThreshold(); threshold != (hlc.Timestamp{}) {
+		t.Fatalf("expected GC threshold to be unchanged, got %s", threshold)
+	}
+
+	// Reading the records again lets the GC request through.
+	gcr.ProtectedTSReadAt = tc.Clock().Now()
+	if _, pErr := tc.SendWrappedWith(roachpb.Header{RangeID: 1}, &gcr); pErr != nil {
+		t.Fatal(pErr)
+	}
+	if threshold := tc.repl.GetGCThreshold(); threshold != readAt {
+		t.Fatalf("expected GC threshold %s, got %s", readAt, threshold)
+	}
+}
+
 func TestDeprecatedRequests(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -8240,3 +8631,74 @@ func TestErrorInRaftApplicationClearsIntents(t *testing.T) {
 		t.Fatal("expected intents to have been cleared")
 	}
 }
+
+// TestEvalClearRange verifies that ClearRange removes all versions of
+// all keys in its span, both below and above the threshold at which it
+// switches to a range deletion, and that it leaves the MVCC stats of
+// the span zeroed out.
+func TestEvalClearRange(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	ctx := context.Background()
+
+	testCases := []struct {
+		numKeys   int
+		valueSize int
+	}{
+		{numKeys: 10, valueSize: 10},                                    // point deletions
+		{numKeys: 1000, valueSize: 2 * clearRangeBytesThreshold / 1000}, // range deletion
+	}
+	for i, c := range testCases {
+		func() {
+			eng := engine.NewInMem(roachpb.Attributes{}, 1<<20)
+			defer eng.Close()
+
+			var ms enginepb.MVCCStats
+			value := roachpb.MakeValueFromBytes(make([]byte, c.valueSize))
+			for j := 0; j < c.numKeys; j++ {
+				key := roachpb.Key(fmt.Sprintf("b%04d", j))
+				for _, wallTime := range []int64{1, 2} {
+					ts := hlc.Timestamp{WallTime: wallTime}
+					if err := engine.MVCCPut(ctx, eng, &ms, key, ts, value, nil); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			// Write keys on either side of the span which must survive.
+			for _, key := range []roachpb.Key{roachpb.Key("a"), roachpb.Key("c")} {
+				if err := engine.MVCCPut(ctx, eng, nil, key, hlc.Timestamp{WallTime: 2}, value, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := evalClearRange(ctx, eng, CommandArgs{
+				Header: roachpb.Header{Timestamp: hlc.Timestamp{WallTime: 2}},
+				Args: &roachpb.ClearRangeRequest{
+					Span: roachpb.Span{Key: roachpb.Key("b"), EndKey: roachpb.Key("c")},
+				},
+				Stats: &ms,
+			}, &roachpb.ClearRangeResponse{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if expMS := (enginepb.MVCCStats{LastUpdateNanos: ms.LastUpdateNanos}); ms != expMS {
+				t.Errorf("%d: expected zero stats, got %s", i, pretty.Diff(expMS, ms))
+			}
+			kvs, _, _, err := engine.MVCCScan(ctx, eng, roachpb.KeyMin, roachpb.KeyMax,
+				math.MaxInt64, hlc.Timestamp{WallTime: 1}, true, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(kvs) != 0 {
+				t.Errorf("%d: expected no values at the first version, found %d", i, len(kvs))
+			}
+			kvs, _, _, err = engine.MVCCScan(ctx, eng, roachpb.KeyMin, roachpb.KeyMax,
+				math.MaxInt64, hlc.Timestamp{WallTime: 2}, true, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(kvs) != 2 || !kvs[0].Key.Equal(roachpb.Key("a")) || !kvs[1].Key.Equal(roachpb.Key("c")) {
+				t.Errorf("%d: expected only the keys outside the span to remain, found %v", i, kvs)
+			}
+		}()
+	}
+}
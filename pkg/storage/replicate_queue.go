@@ -27,6 +27,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/metric"
@@ -55,6 +56,18 @@ var (
 	metaReplicateQueueRemoveDeadReplicaCount = metric.Metadata{
 		Name: "queue.replicate.removedeadreplica",
 		Help: "Number of dead replica removals attempted by the replicate queue (typically in response to a node outage)"}
+	metaReplicateQueueRemoveLearnerReplicaCount = metric.Metadata{
+		Name: "queue.replicate.removelearnerreplica",
+		Help: "Number of learner replica removals attempted by the replicate queue (typically due to an interrupted replica addition)"}
+	metaReplicateQueueRemoveInconsistentReplicaCount = metric.Metadata{
+		Name: "queue.replicate.removeinconsistentreplica",
+		Help: "Number of removals of replicas found to be inconsistent by a consistency check attempted by the replicate queue"}
+	metaReplicateQueueAddNonVoterReplicaCount = metric.Metadata{
+		Name: "queue.replicate.addnonvoterreplica",
+		Help: "Number of non-voting replica additions attempted by the replicate queue"}
+	metaReplicateQueueRemoveNonVoterReplicaCount = metric.Metadata{
+		Name: "queue.replicate.removenonvoterreplica",
+		Help: "Number of non-voting replica removals attempted by the replicate queue"}
 	metaReplicateQueueRebalanceReplicaCount = metric.Metadata{
 		Name: "queue.replicate.rebalancereplica",
 		Help: "Number of replica rebalancer-initiated additions attempted by the replicate queue"}
@@ -65,20 +78,30 @@ var (
 
 // ReplicateQueueMetrics is the set of metrics for the replicate queue.
 type ReplicateQueueMetrics struct {
-	AddReplicaCount        *metric.Counter
-	RemoveReplicaCount     *metric.Counter
-	RemoveDeadReplicaCount *metric.Counter
-	RebalanceReplicaCount  *metric.Counter
-	TransferLeaseCount     *metric.Counter
+	AddReplicaCount            *metric.Counter
+	RemoveReplicaCount         *metric.Counter
+	RemoveDeadReplicaCount     *metric.Counter
+	RemoveLearnerReplicaCount  *metric.Counter
+	AddNonVoterReplicaCount    *metric.Counter
+	RemoveNonVoterReplicaCount *metric.Counter
+	RebalanceReplicaCount      *metric.Counter
+	TransferLeaseCount         *metric.Counter
+
+	RemoveInconsistentReplicaCount *metric.Counter
 }
 
 func makeReplicateQueueMetrics() ReplicateQueueMetrics {
 	return ReplicateQueueMetrics{
-		AddReplicaCount:        metric.NewCounter(metaReplicateQueueAddReplicaCount),
-		RemoveReplicaCount:     metric.NewCounter(metaReplicateQueueRemoveReplicaCount),
-		RemoveDeadReplicaCount: metric.NewCounter(metaReplicateQueueRemoveDeadReplicaCount),
-		RebalanceReplicaCount:  metric.NewCounter(metaReplicateQueueRebalanceReplicaCount),
-		TransferLeaseCount:     metric.NewCounter(metaReplicateQueueTransferLeaseCount),
+		AddReplicaCount:            metric.NewCounter(metaReplicateQueueAddReplicaCount),
+		RemoveReplicaCount:         metric.NewCounter(metaReplicateQueueRemoveReplicaCount),
+		RemoveDeadReplicaCount:     metric.NewCounter(metaReplicateQueueRemoveDeadReplicaCount),
+		RemoveLearnerReplicaCount:  metric.NewCounter(metaReplicateQueueRemoveLearnerReplicaCount),
+		AddNonVoterReplicaCount:    metric.NewCounter(metaReplicateQueueAddNonVoterReplicaCount),
+		RemoveNonVoterReplicaCount: metric.NewCounter(metaReplicateQueueRemoveNonVoterReplicaCount),
+		RebalanceReplicaCount:      metric.NewCounter(metaReplicateQueueRebalanceReplicaCount),
+		TransferLeaseCount:         metric.NewCounter(metaReplicateQueueTransferLeaseCount),
+
+		RemoveInconsistentReplicaCount: metric.NewCounter(metaReplicateQueueRemoveInconsistentReplicaCount),
 	}
 }
 
@@ -183,7 +206,7 @@ func (rq *replicateQueue) shouldQueue(
 	if lease, _ := repl.getLease(); repl.IsLeaseValid(lease, now) {
 		if rq.canTransferLease() &&
 			rq.allocator.ShouldTransferLease(
-				ctx, zone.Constraints, desc.Replicas, lease.Replica.StoreID, desc.RangeID, repl.leaseholderStats) {
+				ctx, zone, desc.Voters(), lease.Replica.StoreID, desc.RangeID, repl.leaseholderStats) {
 			if log.V(2) {
 				log.Infof(ctx, "lease transfer needed, enqueuing")
 			}
@@ -248,9 +271,9 @@ func (rq *replicateQueue) processOneChange(
 
 	// Avoid taking action if the range has too many dead replicas to make
 	// quorum.
-	liveReplicas, deadReplicas := rq.allocator.storePool.liveAndDeadReplicas(desc.RangeID, desc.Replicas)
+	liveReplicas, deadReplicas := rq.allocator.storePool.liveAndDeadReplicas(desc.RangeID, desc.Voters())
 	{
-		quorum := computeQuorum(len(desc.Replicas))
+		quorum := computeQuorum(len(desc.Voters()))
 		if lr := len(liveReplicas); lr < quorum {
 			return false, errors.Errorf(
 				"range requires a replication change, but lacks a quorum of live replicas (%d/%d)", lr, quorum)
@@ -285,8 +308,8 @@ func (rq *replicateQueue) processOneChange(
 			StoreID: newStore.StoreID,
 		}
 
-		need := int(zone.NumReplicas)
-		willHave := len(desc.Replicas) + 1
+		need := int(zone.GetNumVoters())
+		willHave := len(desc.Voters()) + 1
 
 		// Only up-replicate if there are suitable allocation targets such
 		// that, either the replication goal is met, or it is possible to get to the
@@ -338,7 +361,7 @@ func (rq *replicateQueue) processOneChange(
 		if log.V(1) {
 			log.Infof(ctx, "removing a replica")
 		}
-		candidates := filterUnremovableReplicas(repl.RaftStatus(), desc.Replicas)
+		candidates := filterUnremovableReplicas(repl.RaftStatus(), desc.Voters())
 		removeReplica, details, err := rq.allocator.RemoveTarget(ctx, zone.Constraints, candidates, rangeInfo)
 		if err != nil {
 			return false, err
@@ -389,7 +412,7 @@ func (rq *replicateQueue) processOneChange(
 		if log.V(1) {
 			log.Infof(ctx, "removing a decommissioning replica")
 		}
-		decommissioningReplicas := rq.allocator.storePool.decommissioningReplicas(desc.RangeID, desc.Replicas)
+		decommissioningReplicas := rq.allocator.storePool.decommissioningReplicas(desc.RangeID, desc.Voters())
 		if len(decommissioningReplicas) == 0 {
 			if log.V(1) {
 				log.Warningf(ctx, "range of replica %s was identified as having decommissioning replicas, "+
@@ -455,6 +478,114 @@ func (rq *replicateQueue) processOneChange(
 		); err != nil {
 			return false, err
 		}
+	case AllocatorRemoveLearner:
+		if len(rangeInfo.AbandonedLearners) == 0 {
+			return false, nil
+		}
+		learner := rangeInfo.AbandonedLearners[0]
+		rq.metrics.RemoveLearnerReplicaCount.Inc(1)
+		if log.V(1) {
+			log.Infof(ctx, "removing learner replica %+v from store", learner)
+		}
+		target := roachpb.ReplicationTarget{
+			NodeID:  learner.NodeID,
+			StoreID: learner.StoreID,
+		}
+		if err := rq.removeReplica(
+			ctx, repl, target, desc, ReasonAbandonedLearner, "",
+		); err != nil {
+			return false, err
+		}
+	case AllocatorRemoveInconsistent:
+		if len(rangeInfo.InconsistentReplicas) == 0 {
+			break
+		}
+		// The leaseholder's replica is never marked since it's the one the
+		// others are compared against, so the replica to remove isn't ours.
+		inconsistentReplica := rangeInfo.InconsistentReplicas[0]
+		details, err := repl.inconsistentReplicaDetails(ctx, desc.StartKey, inconsistentReplica.ReplicaID)
+		if err != nil {
+			return false, err
+		}
+		rq.metrics.RemoveInconsistentReplicaCount.Inc(1)
+		log.Warningf(ctx, "removing inconsistent replica %+v: %s", inconsistentReplica, details)
+		target := roachpb.ReplicationTarget{
+			NodeID:  inconsistentReplica.NodeID,
+			StoreID: inconsistentReplica.StoreID,
+		}
+		if err := rq.removeReplica(
+			ctx, repl, target, desc, ReasonReplicaInconsistent, details,
+		); err != nil {
+			return false, err
+		}
+		if err := repl.clearInconsistentReplica(
+			ctx, desc.StartKey, inconsistentReplica.ReplicaID,
+		); err != nil {
+			log.Warningf(ctx, "could not clear mark of removed replica %+v: %s", inconsistentReplica, err)
+		}
+	case AllocatorAddNonVoter:
+		if !repl.store.cfg.Settings.Version.IsActive(cluster.VersionNonVoterReplicas) {
+			log.VEventf(ctx, 1, "not adding non-voter until cluster version %s is active",
+				cluster.VersionNonVoterReplicas)
+			return false, nil
+		}
+		newStore, details, err := rq.allocator.AllocateTarget(
+			ctx,
+			zone.Constraints,
+			desc.Replicas,
+			rangeInfo,
+			true, /* relaxConstraints */
+		)
+		if err != nil {
+			return false, err
+		}
+		target := roachpb.ReplicationTarget{
+			NodeID:  newStore.Node.NodeID,
+			StoreID: newStore.StoreID,
+		}
+		rq.metrics.AddNonVoterReplicaCount.Inc(1)
+		if log.V(1) {
+			log.Infof(ctx, "adding non-voter %+v: %s",
+				target, rangeRaftProgress(repl.RaftStatus(), desc.Replicas))
+		}
+		if err := repl.addNonVoterReplica(
+			ctx, target, desc, ReasonRangeUnderReplicated, details,
+		); err != nil {
+			return false, err
+		}
+	case AllocatorRemoveNonVoter:
+		nonVoters := desc.NonVoters()
+		// Prefer removing dead and decommissioning non-voters, which need to be
+		// replaced, over live ones.
+		_, dead := rq.allocator.storePool.liveAndDeadReplicas(desc.RangeID, nonVoters)
+		decommissioning := rq.allocator.storePool.decommissioningReplicas(desc.RangeID, nonVoters)
+		var removeNonVoter roachpb.ReplicaDescriptor
+		reason := ReasonRangeOverReplicated
+		details := ""
+		switch {
+		case len(dead) > 0:
+			removeNonVoter, reason = dead[0], ReasonStoreDead
+		case len(decommissioning) > 0:
+			removeNonVoter, reason = decommissioning[0], ReasonStoreDecommissioning
+		default:
+			var err error
+			removeNonVoter, details, err = rq.allocator.RemoveTarget(
+				ctx, zone.Constraints, nonVoters, rangeInfo)
+			if err != nil {
+				return false, err
+			}
+		}
+		rq.metrics.RemoveNonVoterReplicaCount.Inc(1)
+		if log.V(1) {
+			log.Infof(ctx, "removing non-voter %+v: %s", removeNonVoter, reason)
+		}
+		target := roachpb.ReplicationTarget{
+			NodeID:  removeNonVoter.NodeID,
+			StoreID: removeNonVoter.StoreID,
+		}
+		if err := rq.removeReplica(ctx, repl, target, desc, reason, details); err != nil {
+			return false, err
+		}
 	case AllocatorConsiderRebalance:
 		// The Noop case will result if this replica was queued in order to
 		// rebalance. Attempt to find a rebalancing target.
@@ -533,7 +664,7 @@ func (rq *replicateQueue) transferLease(
 	candidates := filterBehindReplicas(repl.RaftStatus(), desc.Replicas)
 	if target := rq.allocator.TransferLeaseTarget(
 		ctx,
-		zone.Constraints,
+		zone,
 		candidates,
 		repl.store.StoreID(),
 		desc.RangeID,
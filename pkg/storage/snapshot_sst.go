@@ -0,0 +1,362 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
+	"github.com/cockroachdb/cockroach/pkg/util"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/util/uuid"
+)
+
+const (
+	// snapshotSSTChunkSize is the amount of key/value data after which a
+	// snapshot sent as SSTables starts a new chunk. Chunk boundaries only
+	// depend on the snapshot's data, so that a resumed stream produces the
+	// same chunks.
+	snapshotSSTChunkSize = 8 << 20 // 8 MiB
+
+	// snapshotStreamRetries is the number of times the sender of a snapshot
+	// re-establishes a failed stream to resume sending the snapshot's chunks.
+	snapshotStreamRetries = 3
+
+	// partialSnapshotTTL is how long the recipient of a snapshot holds on to
+	// the chunks received through a failed stream, waiting for the sender to
+	// resume it.
+	partialSnapshotTTL = time.Minute
+
+	// snapshotSSTDir is the directory, within the engine's auxiliary
+	// directory, to which the SSTables of snapshots are written to be
+	// ingested.
+	snapshotSSTDir = "snapshots"
+)
+
+// snapshotStreamError wraps an error encountered while streaming the chunks
+// of a snapshot, after which the snapshot can be resumed through a new stream.
+type snapshotStreamError struct {
+	cause error
+}
+
+func (e *snapshotStreamError) Error() string {
+	return e.cause.Error()
+}
+
+// snapshotLogEntriesChecksum computes the checksum of the log entries sent in
+// the final request of a snapshot sent as SSTables.
+func snapshotLogEntriesChecksum(logEntries [][]byte) uint32 {
+	hash := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	for _, ent := range logEntries {
+		if _, err := hash.Write(ent); err != nil {
+			panic(errors.Wrap(err, `"It never returns an error." -- https://golang.org/pkg/hash`))
+		}
+	}
+	return hash.Sum32()
+}
+
+// sendSnapshotSSTs streams the data of the snapshot as a sequence of SSTable
+// chunks, skipping the chunks before next which the recipient already has. It
+// returns the total number of chunks and key/value pairs of the snapshot.
+func sendSnapshotSSTs(
+	ctx context.Context,
+	stream OutgoingSnapshotStream,
+	snap *OutgoingSnapshot,
+	next int32,
+	limiter *rate.Limiter,
+) (int32, int, error) {
+	unreplicatedPrefix := keys.MakeRangeIDUnreplicatedPrefix(snap.State.Desc.RangeID)
+
+	var sst engine.RocksDBSstFileWriter
+	defer sst.Close()
+	var chunk int32
+	var chunkSize, unlimited, n int
+	building := false
+
+	finishChunk := func() error {
+		defer func() {
+			chunk++
+			chunkSize = 0
+		}()
+		if !building {
+			return nil
+		}
+		building = false
+		data, err := sst.Finish()
+		sst.Close()
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&SnapshotRequest{
+			SST:      data,
+			Chunk:    chunk,
+			Checksum: util.CRC32(data),
+		}); err != nil {
+			return &snapshotStreamError{err}
+		}
+		return nil
+	}
+
+	for ; ; snap.Iter.Next() {
+		if ok, err := snap.Iter.Valid(); err != nil {
+			return 0, 0, err
+		} else if !ok {
+			break
+		}
+		key, value := snap.Iter.Key(), snap.Iter.Value()
+		if bytes.HasPrefix(key.Key, unreplicatedPrefix) {
+			continue
+		}
+		n++
+		chunkSize += len(key.Key) + len(value)
+
+		// Chunks which the recipient already has are skipped without being
+		// built, but their data still determines the chunk boundaries.
+		if chunk >= next {
+			if !building {
+				var err error
+				if sst, err = engine.MakeRocksDBSstFileWriter(); err != nil {
+					return 0, 0, err
+				}
+				building = true
+			}
+			if err := sst.Add(engine.MVCCKeyValue{Key: key, Value: value}); err != nil {
+				return 0, 0, err
+			}
+			if unlimited += len(key.Key) + len(value); unlimited >= snapshotBatchSize {
+				if err := limiter.WaitN(ctx, 1); err != nil {
+					return 0, 0, err
+				}
+				unlimited = 0
+			}
+		}
+
+		if chunkSize >= snapshotSSTChunkSize {
+			if err := finishChunk(); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	if chunkSize > 0 {
+		if unlimited > 0 {
+			if err := limiter.WaitN(ctx, 1); err != nil {
+				return 0, 0, err
+			}
+		}
+		if err := finishChunk(); err != nil {
+			return 0, 0, err
+		}
+	}
+	return chunk, n, nil
+}
+
+// partialSnapshot holds the chunks of a snapshot received through a stream
+// which failed before the snapshot was complete.
+type partialSnapshot struct {
+	// paths are the files holding the chunks (see writeSnapshotSST).
+	paths    []string
+	lastUsed time.Time
+}
+
+// partialSnapshots holds on to the chunks of incomplete snapshots so that
+// their senders can resume streaming them after the chunks the store already
+// received. The chunks are held on disk, so only their paths are kept in
+// memory.
+type partialSnapshots struct {
+	syncutil.Mutex
+	m map[uuid.UUID]partialSnapshot
+}
+
+// take removes and returns the paths of the chunks held for the given
+// snapshot, if any. Removing them ensures that only one stream uses them at a
+// time.
+func (p *partialSnapshots) take(snapUUID uuid.UUID) []string {
+	p.Lock()
+	defer p.Unlock()
+	ps, ok := p.m[snapUUID]
+	if !ok {
+		return nil
+	}
+	delete(p.m, snapUUID)
+	return ps.paths
+}
+
+// put holds on to the chunks received for the given snapshot, and discards
+// those of snapshots which haven't been resumed within partialSnapshotTTL.
+// Returns the IDs of the discarded snapshots, whose files the caller removes.
+func (p *partialSnapshots) put(snapUUID uuid.UUID, paths []string) []uuid.UUID {
+	now := timeutil.Now()
+	p.Lock()
+	defer p.Unlock()
+	if p.m == nil {
+		p.m = map[uuid.UUID]partialSnapshot{}
+	}
+	var expired []uuid.UUID
+	for id, ps := range p.m {
+		if now.Sub(ps.lastUsed) > partialSnapshotTTL {
+			delete(p.m, id)
+			expired = append(expired, id)
+		}
+	}
+	if len(paths) > 0 {
+		p.m[snapUUID] = partialSnapshot{paths: paths, lastUsed: now}
+	}
+	return expired
+}
+
+// snapshotSSTDirPath returns the directory holding the chunks of the given
+// incoming snapshot.
+func snapshotSSTDirPath(eng engine.Engine, snapUUID uuid.UUID) string {
+	return filepath.Join(eng.GetAuxiliaryDir(), snapshotSSTDir, snapUUID.String())
+}
+
+// writeSnapshotSST writes a chunk of an incoming snapshot to the file from
+// which it is ingested, and returns its path. Chunks are written out as they
+// are received so that the recipient of a snapshot doesn't hold its data in
+// memory.
+func writeSnapshotSST(
+	eng engine.Engine, snapUUID uuid.UUID, chunk int, data []byte,
+) (string, error) {
+	var path string
+	if _, ok := eng.(engine.InMem); ok {
+		path = fmt.Sprintf("snapshot-%s-%06d.sst", snapUUID, chunk)
+	} else {
+		dir := snapshotSSTDirPath(eng, snapUUID)
+		if chunk == 0 {
+			// Files left behind by an earlier attempt to apply the snapshot may
+			// have been linked into the engine; they must not be overwritten in
+			// place.
+			if err := os.RemoveAll(dir); err != nil {
+				return "", err
+			}
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%06d.sst", chunk))
+	}
+	// Write the file through the engine so that it is encrypted if the engine
+	// is.
+	if err := eng.WriteFile(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// removeSnapshotSSTs removes the chunks of an incoming snapshot which won't
+// be applied.
+func removeSnapshotSSTs(eng engine.Engine, snapUUID uuid.UUID) error {
+	if _, ok := eng.(engine.InMem); ok {
+		return nil
+	}
+	return os.RemoveAll(snapshotSSTDirPath(eng, snapUUID))
+}
+
+// snapshotIngestionPending returns whether the ingestion marker of a snapshot
+// of the given range is present, in which case the files it lists must be
+// kept until the ingestion is completed.
+func snapshotIngestionPending(
+	ctx context.Context, eng engine.Reader, rangeID roachpb.RangeID,
+) (bool, error) {
+	return engine.MVCCGetProto(ctx, eng, keys.StoreSnapshotIngestionKey(rangeID),
+		hlc.Timestamp{}, true /* consistent */, nil /* txn */, &SnapshotIngestion{})
+}
+
+// ingestSnapshotSSTs ingests the SSTables of a snapshot of the given range
+// whose ingestion marker has been committed, then removes the marker and the
+// files. Ingesting the same files again is harmless, as nothing else is
+// written to the range's data until the marker is removed.
+func ingestSnapshotSSTs(
+	ctx context.Context, eng engine.Engine, rangeID roachpb.RangeID, paths []string,
+) error {
+	for _, path := range paths {
+		const move = true
+		if err := eng.IngestExternalFile(ctx, path, move); err != nil {
+			return errors.Wrapf(err, "while ingesting %s", path)
+		}
+	}
+
+	batch := eng.NewBatch()
+	defer batch.Close()
+	if err := engine.MVCCDelete(
+		ctx, batch, nil, keys.StoreSnapshotIngestionKey(rangeID), hlc.Timestamp{}, nil,
+	); err != nil {
+		return err
+	}
+	if err := batch.Commit(true /* sync */); err != nil {
+		return err
+	}
+
+	if _, ok := eng.(engine.InMem); !ok && len(paths) > 0 {
+		// The engine holds hard links to the ingested files.
+		if err := os.RemoveAll(filepath.Dir(paths[0])); err != nil {
+			log.Warningf(ctx, "unable to remove ingested snapshot SSTables: %s", err)
+		}
+	}
+	return nil
+}
+
+// ingestPendingSnapshots completes the ingestion of the SSTables of snapshots
+// which the store was applying when it last stopped, and removes the files of
+// snapshots which were never applied. It must be called before the store's
+// replicas are loaded.
+func ingestPendingSnapshots(ctx context.Context, eng engine.Engine) error {
+	prefix := keys.StoreSnapshotIngestionKeyPrefix()
+	var rangeIDs []roachpb.RangeID
+	var pending []SnapshotIngestion
+	if _, err := engine.MVCCIterate(ctx, eng, prefix, prefix.PrefixEnd(), hlc.Timestamp{},
+		true /* consistent */, nil /* txn */, false /* reverse */, func(kv roachpb.KeyValue) (bool, error) {
+			rangeID, err := keys.DecodeStoreSnapshotIngestionKey(kv.Key)
+			if err != nil {
+				return false, err
+			}
+			var ingestion SnapshotIngestion
+			if err := kv.Value.GetProto(&ingestion); err != nil {
+				return false, err
+			}
+			rangeIDs = append(rangeIDs, rangeID)
+			pending = append(pending, ingestion)
+			return false, nil
+		}); err != nil {
+		return err
+	}
+
+	for i, rangeID := range rangeIDs {
+		log.Infof(ctx, "r%d: completing ingestion of snapshot with %d SSTables",
+			rangeID, len(pending[i].Paths))
+		if err := ingestSnapshotSSTs(ctx, eng, rangeID, pending[i].Paths); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := eng.(engine.InMem); ok {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(eng.GetAuxiliaryDir(), snapshotSSTDir))
+}
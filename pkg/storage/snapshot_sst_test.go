@@ -0,0 +1,254 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/uuid"
+)
+
+// chunkRecorder is an OutgoingSnapshotStream which records the SSTable
+// chunks sent through it and fails to send the chunk failAt.
+type chunkRecorder struct {
+	chunks []*SnapshotRequest
+	failAt int32
+}
+
+func (c *chunkRecorder) Send(req *SnapshotRequest) error {
+	if req.Chunk == c.failAt {
+		return errors.New("injected stream failure")
+	}
+	c.chunks = append(c.chunks, req)
+	return nil
+}
+
+func (c *chunkRecorder) Recv() (*SnapshotResponse, error) {
+	return nil, errors.New("unexpected Recv")
+}
+
+// TestSnapshotSSTChunksResume verifies that a snapshot sent as SSTable chunks
+// can be resumed after the chunks delivered before its stream failed.
+func TestSnapshotSSTChunksResume(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	ctx := context.Background()
+	tc := testContext{}
+	stopper := stop.NewStopper()
+	defer stopper.Stop(ctx)
+	tc.Start(t, stopper)
+
+	// Write enough data for the snapshot to span several chunks.
+	value := bytes.Repeat([]byte("x"), 64<<10)
+	for i := 0; i < 300; i++ {
+		key := roachpb.Key(fmt.Sprintf("key-%03d", i))
+		if err := engine.MVCCPut(ctx, tc.engine, nil, key, hlc.Timestamp{WallTime: 1},
+			roachpb.MakeValueFromBytes(value), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, err := tc.repl.GetSnapshot(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+	limiter := rate.NewLimiter(rate.Inf, 1)
+
+	// The stream fails while sending the second chunk.
+	first := &chunkRecorder{failAt: 1}
+	if _, _, err := sendSnapshotSSTs(ctx, first, snap, 0, limiter); err == nil {
+		t.Fatal("expected an error")
+	} else if _, ok := err.(*snapshotStreamError); !ok {
+		t.Fatalf("expected a snapshotStreamError, got %T: %s", err, err)
+	}
+	if len(first.chunks) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(first.chunks))
+	}
+
+	snap.resetIter()
+	second := &chunkRecorder{failAt: -1}
+	numChunks, n, err := sendSnapshotSSTs(ctx, second, snap, 1, limiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numChunks < 3 {
+		t.Fatalf("expected at least 3 chunks, got %d", numChunks)
+	}
+
+	reader := engine.MakeRocksDBSstFileReader()
+	defer reader.Close()
+	for i, req := range append(first.chunks, second.chunks...) {
+		if req.Chunk != int32(i) {
+			t.Fatalf("expected chunk %d, got chunk %d", i, req.Chunk)
+		}
+		if checksum := util.CRC32(req.SST); checksum != req.Checksum {
+			t.Fatalf("chunk %d: expected checksum %x, got %x", i, checksum, req.Checksum)
+		}
+		if err := reader.IngestExternalFile(req.SST); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Together, the chunks contain all of the snapshot's data.
+	var count int
+	if err := reader.Iterate(engine.MVCCKey{Key: roachpb.KeyMin}, engine.MVCCKeyMax,
+		func(engine.MVCCKeyValue) (bool, error) {
+			count++
+			return false, nil
+		}); err != nil {
+		t.Fatal(err)
+	}
+	if count != n {
+		t.Fatalf("expected %d kv pairs, got %d", n, count)
+	}
+}
+
+func TestPartialSnapshots(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var p partialSnapshots
+	id1, id2 := uuid.MakeV4(), uuid.MakeV4()
+	p.put(id1, []string{"a", "b"})
+	p.put(id2, []string{"c"})
+
+	if chunks := p.take(id1); len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	// The chunks are only handed out once.
+	if chunks := p.take(id1); chunks != nil {
+		t.Fatalf("expected no chunks, got %d", len(chunks))
+	}
+
+	// Chunks which haven't been resumed in time are discarded.
+	p.Lock()
+	ps := p.m[id2]
+	ps.lastUsed = ps.lastUsed.Add(-2 * partialSnapshotTTL)
+	p.m[id2] = ps
+	p.Unlock()
+	if expired := p.put(id1, []string{"a"}); !reflect.DeepEqual(expired, []uuid.UUID{id2}) {
+		t.Fatalf("expected %s to expire, got %v", id2, expired)
+	}
+	if chunks := p.take(id2); chunks != nil {
+		t.Fatalf("expected no chunks, got %d", len(chunks))
+	}
+	if chunks := p.take(id1); len(chunks) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(chunks))
+	}
+}
+
+// TestWriteSnapshotSST verifies that the chunks of an incoming snapshot are
+// written to the engine's auxiliary directory, and removed with the snapshot.
+func TestWriteSnapshotSST(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	eng, err := engine.NewRocksDB(
+		engine.RocksDBConfig{
+			RocksDBSettings: cluster.MakeTestingClusterSettings().RocksDBSettings,
+			Dir:             dir,
+		},
+		engine.RocksDBCache{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+
+	snapUUID := uuid.MakeV4()
+	data, _ := MakeSSTable("key", "value", hlc.Timestamp{WallTime: 1})
+	var paths []string
+	for i := 0; i < 2; i++ {
+		path, err := writeSnapshotSST(eng, snapUUID, i, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if read, err := eng.ReadFile(path); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(read, data) {
+			t.Fatalf("expected %s to hold the chunk", path)
+		}
+	}
+
+	if err := removeSnapshotSSTs(eng, snapUUID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(snapshotSSTDirPath(eng, snapUUID)); !os.IsNotExist(err) {
+		t.Fatalf("expected the chunks to be removed, got %v", err)
+	}
+}
+
+// TestIngestPendingSnapshots verifies that the ingestion of a snapshot's
+// SSTables is completed if the store stopped after committing its marker.
+func TestIngestPendingSnapshots(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	ctx := context.Background()
+	eng := engine.NewInMem(roachpb.Attributes{}, 1<<20)
+	defer eng.Close()
+
+	data, kv := MakeSSTable("key", "value", hlc.Timestamp{WallTime: 1})
+	path, err := writeSnapshotSST(eng, uuid.MakeV4(), 0 /* chunk */, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{path}
+	const rangeID = 7
+	markerKey := keys.StoreSnapshotIngestionKey(rangeID)
+
+	// Completing the ingestion is idempotent, so the store may also have
+	// stopped after ingesting the files but before removing the marker.
+	for i := 0; i < 2; i++ {
+		if err := engine.MVCCPutProto(ctx, eng, nil, markerKey, hlc.Timestamp{}, nil,
+			&SnapshotIngestion{Paths: paths}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ingestPendingSnapshots(ctx, eng); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, err := snapshotIngestionPending(ctx, eng, rangeID); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected the ingestion marker to be removed")
+	}
+	val, _, err := engine.MVCCGet(ctx, eng, kv.Key.Key, hlc.Timestamp{WallTime: 2}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val == nil || !bytes.Equal(val.RawBytes, kv.Value) {
+		t.Fatalf("expected %q to be ingested, got %v", kv.Key.Key, val)
+	}
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
 	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv/requestquota"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
@@ -45,6 +46,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/storage/engine"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
 	"github.com/cockroachdb/cockroach/pkg/storage/storagebase"
+	"github.com/cockroachdb/cockroach/pkg/util"
 	"github.com/cockroachdb/cockroach/pkg/util/bufalloc"
 	"github.com/cockroachdb/cockroach/pkg/util/envutil"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
@@ -87,6 +89,10 @@ const (
 	// gossip update.
 	systemDataGossipInterval = 1 * time.Minute
 
+	// reencryptionInterval is the interval at which an encrypted store
+	// rewrites the files which are encrypted with an old key.
+	reencryptionInterval = 10 * time.Minute
+
 	// prohibitRebalancesBehindThreshold is the maximum number of log entries a
 	// store allows its replicas to be behind before it starts declining incoming
 	// rebalances. We prohibit rebalances in this situation to avoid adding
@@ -104,6 +110,17 @@ var changeTypeInternalToRaft = map[roachpb.ReplicaChangeType]raftpb.ConfChangeTy
 	roachpb.REMOVE_REPLICA: raftpb.ConfChangeRemoveNode,
 }
 
+// confChangeType returns the Raft configuration change which corresponds to
+// the given trigger. Learners and non-voters are added to the Raft group as
+// learner nodes; adding a voter with the replica ID of an existing learner
+// promotes it.
+func confChangeType(crt *roachpb.ChangeReplicasTrigger) raftpb.ConfChangeType {
+	if crt.ChangeType == roachpb.ADD_REPLICA && !crt.Replica.IsVoter() {
+		return raftpb.ConfChangeAddLearnerNode
+	}
+	return changeTypeInternalToRaft[crt.ChangeType]
+}
+
 var storeSchedulerConcurrency = envutil.EnvOrDefaultInt(
 	"COCKROACH_SCHEDULER_CONCURRENCY", 8*runtime.NumCPU())
 
@@ -349,9 +366,11 @@ type Store struct {
 	tsMaintenanceQueue *timeSeriesMaintenanceQueue // Time series maintenance queue
 	scanner            *replicaScanner             // Replica scanner
 	consistencyQueue   *consistencyQueue           // Replica consistency check queue
+	storeRebalancer    *StoreRebalancer            // Store-level load-based rebalancer
 	metrics            *StoreMetrics
 	intentResolver     *intentResolver
 	raftEntryCache     *raftEntryCache
+	admission          *admissionController // Back-pressure on low-priority writes
 
 	// gossipRangeCountdown and leaseRangeCountdown are countdowns of
 	// changes to range and leaseholder counts, after which the store
@@ -387,6 +406,10 @@ type Store struct {
 	// data destruction.
 	snapshotApplySem chan struct{}
 
+	// The chunks of incoming snapshots whose streams failed, held on to so
+	// that their senders can resume them.
+	partialSnapshots partialSnapshots
+
 	// Are rebalances to this store allowed or prohibited. Rebalances are
 	// prohibited while a store is catching up replicas (i.e. recovering) after
 	// being restarted.
@@ -612,6 +635,11 @@ type StoreConfig struct {
 	// which is non-zero.
 	IntentResolverTaskLimit int
 
+	// RequestQuotas, if set, holds the request quotas enforced for batches
+	// evaluated by the store which weren't already charged by their
+	// gateway's DistSender.
+	RequestQuotas *requestquota.Quotas
+
 	TestingKnobs StoreTestingKnobs
 
 	// concurrentSnapshotApplyLimit specifies the maximum number of empty
@@ -718,6 +746,13 @@ type StoreTestingKnobs struct {
 	// DisableReplicaRebalancing disables rebalancing of replicas but otherwise
 	// leaves the replicate queue operational.
 	DisableReplicaRebalancing bool
+	// BeforeLearnerPromotion, if set, is called by the leaseholder once a
+	// learner it added has caught up and before it's promoted to a voter.
+	BeforeLearnerPromotion func(roachpb.ReplicaDescriptor)
+	// DisableStoreRebalancer turns off the store rebalancer which moves leases
+	// and replicas away from stores that are serving a disproportionate share
+	// of the cluster's load.
+	DisableStoreRebalancer bool
 	// DisableSplitQueue disables the split queue.
 	DisableSplitQueue bool
 	// DisableTimeSeriesMaintenanceQueue disables the time series maintenance
@@ -849,6 +884,8 @@ func NewStore(cfg StoreConfig, eng engine.Engine, nodeDesc *roachpb.NodeDescript
 	}
 	s.intentResolver = newIntentResolver(s, cfg.IntentResolverTaskLimit)
 	s.raftEntryCache = newRaftEntryCache(cfg.RaftEntryCacheSize)
+	s.admission = newAdmissionController(cfg.Settings, eng.GetStats, cfg.HistogramWindowInterval)
+	s.metrics.registry.AddMetricStruct(&s.admission.metrics)
 	s.draining.Store(false)
 	s.scheduler = newRaftScheduler(s.cfg.AmbientCtx, s.metrics, s, storeSchedulerConcurrency)
 
@@ -884,6 +921,7 @@ func NewStore(cfg StoreConfig, eng engine.Engine, nodeDesc *roachpb.NodeDescript
 		s.scanner.AddQueues(
 			s.gcQueue, s.splitQueue, s.replicateQueue, s.replicaGCQueue,
 			s.raftLogQueue, s.raftSnapshotQueue, s.consistencyQueue)
+		s.storeRebalancer = NewStoreRebalancer(s.cfg.AmbientCtx, cfg.Settings, s.replicateQueue)
 
 		if s.cfg.TimeSeriesDataStore != nil {
 			s.tsMaintenanceQueue = newTimeSeriesMaintenanceQueue(
@@ -1101,6 +1139,12 @@ func (s *Store) Start(ctx context.Context, stopper *stop.Stopper) error {
 	now := s.cfg.Clock.Now()
 	s.startedAt = now.WallTime
 
+	// Snapshots whose SSTables were being ingested when the store stopped
+	// must be completed before their replicas are loaded.
+	if err := ingestPendingSnapshots(ctx, s.engine); err != nil {
+		return err
+	}
+
 	// Iterate over all range descriptors, ignoring uncommitted versions
 	// (consistent=false). Uncommitted intents which have been abandoned
 	// due to a split crashing halfway will simply be resolved on the
@@ -1153,11 +1197,21 @@ func (s *Store) Start(ctx context.Context, stopper *stop.Stopper) error {
 	if err != nil {
 		return err
 	}
+	if err := s.cleanupRaftLog(ctx); err != nil {
+		return err
+	}
 
 	// Start Raft processing goroutines.
 	s.cfg.Transport.Listen(s.StoreID(), s)
 	s.processRaft(ctx)
 
+	// Start rewriting files encrypted with old keys (if the store is
+	// encrypted).
+	s.startReencryption(ctx)
+
+	// Start closing timestamps on idle ranges with non-voters.
+	s.startClosedTimestamps(ctx)
+
 	// Gossip is only ever nil while bootstrapping a cluster and
 	// in unittests.
 	if s.cfg.Gossip != nil {
@@ -1195,6 +1249,10 @@ func (s *Store) Start(ctx context.Context, stopper *stop.Stopper) error {
 			}
 		})
 
+		// Start the store rebalancer, which moves leases and replicas away from
+		// this store if it is serving considerably more load than its peers.
+		s.storeRebalancer.Start(ctx, s.stopper)
+
 		// Run metrics computation up front to populate initial statistics.
 		if err = s.ComputeMetrics(ctx, -1); err != nil {
 			log.Infof(ctx, "%s: failed initial metrics computation: %s", s, err)
@@ -1216,6 +1274,75 @@ func (s *Store) WaitForInit() {
 	s.initComplete.Wait()
 }
 
+// startReencryption runs a goroutine which periodically rewrites the files
+// which are encrypted with a key other than the store's active encryption key,
+// so that old keys can be retired after a key rotation. It is a no-op if the
+// store is not encrypted.
+func (s *Store) startReencryption(ctx context.Context) {
+	rocksdb, ok := s.engine.(*engine.RocksDB)
+	if !ok {
+		return
+	}
+	status, err := rocksdb.GetEncryptionStatus()
+	if err != nil {
+		log.Warningf(ctx, "unable to retrieve encryption status: %s", err)
+		return
+	}
+	if status.ActiveKeyID == "" {
+		return
+	}
+	s.stopper.RunWorker(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(reencryptionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := rocksdb.ReencryptFiles()
+				if err != nil {
+					log.Warningf(ctx, "unable to re-encrypt files: %s", err)
+				} else if n > 0 {
+					log.Infof(ctx, "re-encrypted %d files with key %s", n, status.ActiveKeyID)
+				}
+			case <-s.stopper.ShouldStop():
+				return
+			}
+		}
+	})
+}
+
+// startClosedTimestamps runs a goroutine which periodically has the
+// leaseholder replicas of ranges with non-voters close a timestamp, so that
+// the non-voters of ranges without writes can serve recent reads (see
+// Replica.maybeProposeClosedTimestamp).
+func (s *Store) startClosedTimestamps(ctx context.Context) {
+	s.stopper.RunWorker(ctx, func(ctx context.Context) {
+		var timer timeutil.Timer
+		defer timer.Stop()
+		for {
+			interval := closedTimestampInterval(s.cfg.Settings)
+			if interval == 0 {
+				// Closed timestamps are disabled; check again later in case
+				// the setting changes.
+				interval = time.Second
+			} else {
+				newStoreReplicaVisitor(s).Visit(func(repl *Replica) bool {
+					if err := repl.maybeProposeClosedTimestamp(ctx); err != nil {
+						log.VEventf(ctx, 1, "%s: unable to close timestamp: %s", repl, err)
+					}
+					return true
+				})
+			}
+			timer.Reset(interval)
+			select {
+			case <-timer.C:
+				timer.Read = true
+			case <-s.stopper.ShouldStop():
+				return
+			}
+		}
+	})
+}
+
 var errPeriodicGossipsDisabled = errors.New("periodic gossip is disabled")
 
 // startGossip runs an infinite loop in a goroutine which regularly checks
@@ -1569,6 +1696,37 @@ func (s *Store) NotifyBootstrapped() {
 	s.idleReplicaElectionTime.Unlock()
 }
 
+// cleanupRaftLog discards the entries of the separate raft log, if the store
+// has one, which were orphaned by a crash: those of ranges the store no longer
+// has a replica of, and those below the truncated state of the others.
+// Entries are only discarded from the separate raft log after the engine
+// batch which makes them unreachable is committed, so they're left behind if
+// the store crashes in between. It must be called after the replicas were
+// loaded, but before they start processing Raft messages.
+func (s *Store) cleanupRaftLog(ctx context.Context) error {
+	raftLog := s.engine.RaftLog()
+	if raftLog == nil {
+		return nil
+	}
+	for _, rangeID := range raftLog.RangeIDs() {
+		if _, ok := s.mu.replicas.Load(int64(rangeID)); !ok {
+			log.Infof(ctx, "discarding separate raft log of removed replica r%d", rangeID)
+			if err := raftLog.Destroy(rangeID); err != nil {
+				return err
+			}
+			continue
+		}
+		truncState, err := loadTruncatedState(ctx, s.engine, rangeID)
+		if err != nil {
+			return err
+		}
+		if _, err := raftLog.TruncatePrefix(rangeID, truncState.Index+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetReplica fetches a replica by Range ID. Returns an error if no replica is found.
 func (s *Store) GetReplica(rangeID roachpb.RangeID) (*Replica, error) {
 	if value, ok := s.mu.replicas.Load(int64(rangeID)); ok {
@@ -1931,6 +2089,7 @@ func (s *Store) SplitRange(ctx context.Context, origRng, newRng *Replica) error
 	// spans that are now owned by the new range.
 	origRng.leaseholderStats.resetRequestCounts()
 	origRng.writeStats.splitRequestCounts(newRng.writeStats)
+	origRng.writeBytesStats.splitRequestCounts(newRng.writeBytesStats)
 
 	if kr := s.mu.replicasByKey.ReplaceOrInsert(origRng); kr != nil {
 		return errors.Errorf("replicasByKey unexpectedly contains %s when inserting replica %s", kr, origRng)
@@ -1992,6 +2151,9 @@ func (s *Store) MergeRange(
 		// logic that depends on them.
 		subsumingRng.writeStats.resetRequestCounts()
 	}
+	if subsumingRng.writeBytesStats != nil {
+		subsumingRng.writeBytesStats.resetRequestCounts()
+	}
 
 	if err := s.maybeMergeTimestampCaches(ctx, subsumingRng, subsumedRng); err != nil {
 		return err
@@ -2291,11 +2453,20 @@ func (s *Store) Capacity() (roachpb.StoreCapacity, error) {
 	var leaseCount int32
 	var logicalBytes int64
 	var totalWritesPerSecond float64
+	var totalQueriesPerSecond float64
+	var totalWriteBytesPerSecond float64
 	bytesPerReplica := make([]float64, 0, capacity.RangeCount)
 	writesPerReplica := make([]float64, 0, capacity.RangeCount)
 	newStoreReplicaVisitor(s).Visit(func(r *Replica) bool {
 		if r.ownsValidLease(now) {
 			leaseCount++
+			// Only the leaseholder knows about the reads being served, so only
+			// count queries for replicas that hold a valid lease.
+			if r.leaseholderStats != nil {
+				if qps, dur := r.leaseholderStats.avgQPS(); dur >= MinStatsDuration {
+					totalQueriesPerSecond += qps
+				}
+			}
 		}
 		mvccStats := r.GetMVCCStats()
 		logicalBytes += mvccStats.Total()
@@ -2307,11 +2478,16 @@ func (s *Store) Capacity() (roachpb.StoreCapacity, error) {
 			totalWritesPerSecond += qps
 			writesPerReplica = append(writesPerReplica, qps)
 		}
+		if bps, dur := r.writeBytesStats.avgQPS(); dur >= MinStatsDuration {
+			totalWriteBytesPerSecond += bps
+		}
 		return true
 	})
 	capacity.LeaseCount = leaseCount
 	capacity.LogicalBytes = logicalBytes
 	capacity.WritesPerSecond = totalWritesPerSecond
+	capacity.QueriesPerSecond = totalQueriesPerSecond
+	capacity.WriteBytesPerSecond = totalWriteBytesPerSecond
 	capacity.BytesPerReplica = roachpb.PercentilesFromData(bytesPerReplica)
 	capacity.WritesPerReplica = roachpb.PercentilesFromData(writesPerReplica)
 	s.recordNewWritesPerSecond(totalWritesPerSecond)
@@ -2427,6 +2603,21 @@ func (s *Store) Send(
 		}
 	}
 
+	// Hold back low-priority and bulk writes while the engine is struggling to
+	// keep up, before it starts stalling all writes. This happens before the
+	// batch is assigned a timestamp so that the wait doesn't push it into the
+	// past.
+	if ba.IsWrite() {
+		if err := s.admission.admit(ctx, s.stopper, admissionClassForBatch(ba)); err != nil {
+			return nil, roachpb.NewError(err)
+		}
+	}
+	// Batches which reached the store without going through their gateway's
+	// DistSender (or one that doesn't enforce quotas) are charged here.
+	if err := s.cfg.RequestQuotas.Admit(ctx, ba); err != nil {
+		return nil, roachpb.NewError(err)
+	}
+
 	if err := ba.SetActiveTimestamp(s.Clock().Now); err != nil {
 		return nil, roachpb.NewError(err)
 	}
@@ -2637,6 +2828,39 @@ func (s *Store) Send(
 	}
 }
 
+// RangeFeed registers a range feed with the replica addressed by the
+// request and streams its events until the feed is disconnected.
+func (s *Store) RangeFeed(
+	ctx context.Context, args *roachpb.RangeFeedRequest, stream roachpb.Internal_RangeFeedServer,
+) *roachpb.Error {
+	ctx = s.AnnotateCtx(ctx)
+	if err := verifyKeys(args.Span.Key, args.Span.EndKey, true); err != nil {
+		return roachpb.NewError(err)
+	}
+	repl, err := s.GetReplica(args.RangeID)
+	if err != nil {
+		return roachpb.NewError(err)
+	}
+	if !repl.IsInitialized() {
+		return roachpb.NewError(roachpb.NewRangeNotFoundError(args.RangeID))
+	}
+	return repl.RangeFeed(args, rangeFeedStreamWithContext{ctx: ctx, stream: stream})
+}
+
+// rangeFeedStreamWithContext overrides the context of a range feed stream.
+type rangeFeedStreamWithContext struct {
+	ctx    context.Context
+	stream roachpb.Internal_RangeFeedServer
+}
+
+func (s rangeFeedStreamWithContext) Context() context.Context {
+	return s.ctx
+}
+
+func (s rangeFeedStreamWithContext) Send(event *roachpb.RangeFeedEvent) error {
+	return s.stream.Send(event)
+}
+
 // maybeWaitInPushTxnQueue potentially diverts the incoming request to
 // the push txn queue, where it will wait for updates to the target
 // transaction.
@@ -2769,11 +2993,39 @@ func (s *Store) HandleSnapshot(
 		)
 	}
 
-	if err := stream.Send(&SnapshotResponse{Status: SnapshotResponse_ACCEPTED}); err != nil {
+	snapUUID, err := uuid.FromBytes(header.RaftMessageRequest.Message.Snapshot.Data)
+	if err != nil {
+		return sendSnapError(errors.Wrap(err, "invalid snapshot"))
+	}
+
+	// If an earlier stream of this snapshot failed, resume after the chunks
+	// it delivered.
+	var sstPaths []string
+	if header.SSTChunks {
+		sstPaths = s.partialSnapshots.take(snapUUID)
+	}
+	// The chunks received so far are discarded if the snapshot fails for any
+	// reason other than the stream breaking, in which case they are held on
+	// to for the sender to resume the snapshot.
+	holdChunks := false
+	defer func() {
+		if holdChunks {
+			s.holdPartialSnapshot(ctx, snapUUID, sstPaths)
+		} else if len(sstPaths) > 0 {
+			s.discardSnapshotSSTs(ctx, header.State.Desc.RangeID, snapUUID)
+		}
+	}()
+
+	if err := stream.Send(&SnapshotResponse{
+		Status:    SnapshotResponse_ACCEPTED,
+		NextChunk: int32(len(sstPaths)),
+	}); err != nil {
+		holdChunks = true
 		return err
 	}
 	if log.V(2) {
-		log.Infof(ctx, "accepted snapshot reservation for r%d", header.State.Desc.RangeID)
+		log.Infof(ctx, "accepted snapshot reservation for r%d (resuming at chunk %d)",
+			header.State.Desc.RangeID, len(sstPaths))
 	}
 
 	var batches [][]byte
@@ -2781,6 +3033,8 @@ func (s *Store) HandleSnapshot(
 	for {
 		req, err := stream.Recv()
 		if err != nil {
+			// The sender may resume the snapshot through a new stream.
+			holdChunks = true
 			return err
 		}
 		if req.Header != nil {
@@ -2790,18 +3044,40 @@ func (s *Store) HandleSnapshot(
 		if req.KVBatch != nil {
 			batches = append(batches, req.KVBatch)
 		}
+		if req.SST != nil {
+			if req.Chunk != int32(len(sstPaths)) {
+				return sendSnapError(errors.Errorf("client error: expected chunk %d, got chunk %d",
+					len(sstPaths), req.Chunk))
+			}
+			if checksum := util.CRC32(req.SST); checksum != req.Checksum {
+				return sendSnapError(errors.Errorf("checksum mismatch for chunk %d: expected %x, got %x",
+					req.Chunk, req.Checksum, checksum))
+			}
+			path, err := writeSnapshotSST(s.engine, snapUUID, len(sstPaths), req.SST)
+			if err != nil {
+				return sendSnapError(errors.Wrapf(err, "writing chunk %d", req.Chunk))
+			}
+			sstPaths = append(sstPaths, path)
+		}
 		if req.LogEntries != nil {
 			logEntries = append(logEntries, req.LogEntries...)
 		}
 		if req.Final {
-			snapUUID, err := uuid.FromBytes(header.RaftMessageRequest.Message.Snapshot.Data)
-			if err != nil {
-				return sendSnapError(errors.Wrap(err, "invalid snapshot"))
+			if header.SSTChunks {
+				if req.NumChunks != int32(len(sstPaths)) {
+					return sendSnapError(errors.Errorf("client error: expected %d chunks, got %d",
+						req.NumChunks, len(sstPaths)))
+				}
+				if checksum := snapshotLogEntriesChecksum(logEntries); checksum != req.Checksum {
+					return sendSnapError(errors.Errorf("checksum mismatch for log entries: expected %x, got %x",
+						req.Checksum, checksum))
+				}
 			}
 
 			inSnap := IncomingSnapshot{
 				SnapUUID:   snapUUID,
 				Batches:    batches,
+				SSTPaths:   sstPaths,
 				LogEntries: logEntries,
 				State:      &header.State,
 				snapType:   snapTypeRaft,
@@ -2818,6 +3094,33 @@ func (s *Store) HandleSnapshot(
 	}
 }
 
+// holdPartialSnapshot holds on to the chunks of a snapshot received through a
+// failed stream, and removes those of snapshots which weren't resumed in time.
+func (s *Store) holdPartialSnapshot(ctx context.Context, snapUUID uuid.UUID, sstPaths []string) {
+	for _, id := range s.partialSnapshots.put(snapUUID, sstPaths) {
+		if err := removeSnapshotSSTs(s.engine, id); err != nil {
+			log.Warningf(ctx, "unable to remove chunks of snapshot %s: %s", id.Short(), err)
+		}
+	}
+}
+
+// discardSnapshotSSTs removes the chunks of a snapshot which won't be
+// resumed. If the snapshot failed after its ingestion marker was committed,
+// they're left for the store to ingest when it restarts.
+func (s *Store) discardSnapshotSSTs(
+	ctx context.Context, rangeID roachpb.RangeID, snapUUID uuid.UUID,
+) {
+	if pending, err := snapshotIngestionPending(ctx, s.engine, rangeID); err != nil {
+		log.Warningf(ctx, "unable to check for pending ingestion of snapshot %s: %s", snapUUID.Short(), err)
+		return
+	} else if pending {
+		return
+	}
+	if err := removeSnapshotSSTs(s.engine, snapUUID); err != nil {
+		log.Warningf(ctx, "unable to remove chunks of snapshot %s: %s", snapUUID.Short(), err)
+	}
+}
+
 func (s *Store) uncoalesceBeats(
 	ctx context.Context,
 	beats []RaftHeartbeat,
@@ -3258,6 +3561,9 @@ func snapshotRateLimit(
 	}
 }
 
+// The size of batches to send. This is the granularity of rate limiting.
+const snapshotBatchSize = 256 << 10 // 256 KB
+
 var errMustRetrySnapshotDueToTruncation = errors.New("log truncation during snapshot removed sideloaded SSTable")
 
 // sendSnapshot sends an outgoing snapshot via a pre-opened GRPC stream.
@@ -3307,8 +3613,6 @@ func sendSnapshot(
 			to, resp.Status)
 	}
 
-	// The size of batches to send. This is the granularity of rate limiting.
-	const batchSize = 256 << 10 // 256 KB
 	targetRate, err := snapshotRateLimit(st, header.Priority)
 	if err != nil {
 		return errors.Wrapf(err, "%s", to)
@@ -3321,59 +3625,17 @@ func sendSnapshot(
 	// which seems to disable the rate limiting, or call WaitN in smaller than
 	// burst size chunks which caused excessive slowness in testing. Would be
 	// nice to figure this out, but the batches/sec rate limit works for now.
-	limiter := rate.NewLimiter(targetRate/batchSize, 1 /* burst size */)
+	limiter := rate.NewLimiter(targetRate/snapshotBatchSize, 1 /* burst size */)
 
-	// Determine the unreplicated key prefix so we can drop any
-	// unreplicated keys from the snapshot.
-	unreplicatedPrefix := keys.MakeRangeIDUnreplicatedPrefix(header.State.Desc.RangeID)
-	var alloc bufalloc.ByteAllocator
-	n := 0
-	var b engine.Batch
-	for ; ; snap.Iter.Next() {
-		if ok, err := snap.Iter.Valid(); err != nil {
-			return err
-		} else if !ok {
-			break
-		}
-		var key engine.MVCCKey
-		var value []byte
-		alloc, key, value = snap.Iter.allocIterKeyValue(alloc)
-		if bytes.HasPrefix(key.Key, unreplicatedPrefix) {
-			continue
-		}
-		n++
-		mvccKey := engine.MVCCKey{
-			Key:       key.Key,
-			Timestamp: key.Timestamp,
-		}
-		if b == nil {
-			b = newBatch()
-		}
-		if err := b.Put(mvccKey, value); err != nil {
-			b.Close()
-			return err
-		}
-
-		if len(b.Repr()) >= batchSize {
-			if err := limiter.WaitN(ctx, 1); err != nil {
-				return err
-			}
-			if err := sendBatch(stream, b); err != nil {
-				return err
-			}
-			b = nil
-			// We no longer need the keys and values in the batch we just sent,
-			// so reset alloc and allow them to be garbage collected.
-			alloc = bufalloc.ByteAllocator{}
-		}
+	var n int
+	var numChunks int32
+	if header.SSTChunks {
+		numChunks, n, err = sendSnapshotSSTs(ctx, stream, snap, resp.NextChunk, limiter)
+	} else {
+		n, err = sendSnapshotBatches(ctx, stream, snap, newBatch, limiter)
 	}
-	if b != nil {
-		if err := limiter.WaitN(ctx, 1); err != nil {
-			return err
-		}
-		if err := sendBatch(stream, b); err != nil {
-			return err
-		}
+	if err != nil {
+		return err
 	}
 
 	firstIndex := header.State.TruncatedState.Index + 1
@@ -3390,9 +3652,17 @@ func sendSnapshot(
 
 	rangeID := header.State.Desc.RangeID
 
-	if err := iterateEntries(ctx, snap.EngineSnap, rangeID, firstIndex, endIndex, scanFunc); err != nil {
+	if err := iterateEntries(
+		ctx, snap.EngineSnap, snap.RaftLog, rangeID, firstIndex, endIndex, scanFunc,
+	); err != nil {
 		return err
 	}
+	// Unlike the engine snapshot, the separate raft log may have been
+	// truncated since the snapshot was created.
+	if n := uint64(len(logEntries)); snap.RaftLog != nil && n != endIndex-firstIndex {
+		return errors.Errorf("log truncation during snapshot removed raft log entries: found %d of [%d, %d)",
+			n, firstIndex, endIndex)
+	}
 
 	// Inline the payloads for all sideloaded proposals.
 	//
@@ -3452,6 +3722,10 @@ func sendSnapshot(
 		LogEntries: logEntries,
 		Final:      true,
 	}
+	if header.SSTChunks {
+		req.NumChunks = numChunks
+		req.Checksum = snapshotLogEntriesChecksum(logEntries)
+	}
 	// Notify the sent callback before the final snapshot request is sent so that
 	// the snapshots generated metric gets incremented before the snapshot is
 	// applied.
@@ -3459,8 +3733,9 @@ func sendSnapshot(
 	if err := stream.Send(req); err != nil {
 		return err
 	}
-	log.Infof(ctx, "streamed snapshot to %s: kv pairs: %d, log entries: %d, rate-limit: %s/sec, %0.0fms",
-		to, n, len(logEntries), humanizeutil.IBytes(int64(targetRate)),
+	log.Infof(ctx, "streamed snapshot to %s: kv pairs: %d, chunks: %d (resumed at %d), log entries: %d, "+
+		"rate-limit: %s/sec, %0.0fms",
+		to, n, numChunks, resp.NextChunk, len(logEntries), humanizeutil.IBytes(int64(targetRate)),
 		timeutil.Since(start).Seconds()*1000)
 
 	resp, err = stream.Recv()
@@ -3484,6 +3759,70 @@ func sendSnapshot(
 	}
 }
 
+// sendSnapshotBatches streams the data of the snapshot as a sequence of
+// RocksDB batches, and returns the number of key/value pairs sent.
+func sendSnapshotBatches(
+	ctx context.Context,
+	stream OutgoingSnapshotStream,
+	snap *OutgoingSnapshot,
+	newBatch func() engine.Batch,
+	limiter *rate.Limiter,
+) (int, error) {
+	// Determine the unreplicated key prefix so we can drop any
+	// unreplicated keys from the snapshot.
+	unreplicatedPrefix := keys.MakeRangeIDUnreplicatedPrefix(snap.State.Desc.RangeID)
+	var alloc bufalloc.ByteAllocator
+	n := 0
+	var b engine.Batch
+	for ; ; snap.Iter.Next() {
+		if ok, err := snap.Iter.Valid(); err != nil {
+			return 0, err
+		} else if !ok {
+			break
+		}
+		var key engine.MVCCKey
+		var value []byte
+		alloc, key, value = snap.Iter.allocIterKeyValue(alloc)
+		if bytes.HasPrefix(key.Key, unreplicatedPrefix) {
+			continue
+		}
+		n++
+		mvccKey := engine.MVCCKey{
+			Key:       key.Key,
+			Timestamp: key.Timestamp,
+		}
+		if b == nil {
+			b = newBatch()
+		}
+		if err := b.Put(mvccKey, value); err != nil {
+			b.Close()
+			return 0, err
+		}
+
+		if len(b.Repr()) >= snapshotBatchSize {
+			if err := limiter.WaitN(ctx, 1); err != nil {
+				return 0, err
+			}
+			if err := sendBatch(stream, b); err != nil {
+				return 0, err
+			}
+			b = nil
+			// We no longer need the keys and values in the batch we just sent,
+			// so reset alloc and allow them to be garbage collected.
+			alloc = bufalloc.ByteAllocator{}
+		}
+	}
+	if b != nil {
+		if err := limiter.WaitN(ctx, 1); err != nil {
+			return 0, err
+		}
+		if err := sendBatch(stream, b); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
 func sendBatch(stream OutgoingSnapshotStream, batch engine.Batch) error {
 	repr := batch.Repr()
 	batch.Close()
@@ -4109,6 +4448,28 @@ func (s *Store) updateCommandQueueGauges() error {
 	return nil
 }
 
+func (s *Store) updatePushTxnQueueGauges() {
+	pushees := map[uuid.UUID]struct{}{}
+	var pushers int64
+	for _, w := range s.TxnWaits() {
+		pushees[w.PusheeID] = struct{}{}
+		pushers++
+	}
+	s.metrics.PushTxnQueuePusheeWaiting.Update(int64(len(pushees)))
+	s.metrics.PushTxnQueuePusherWaiting.Update(pushers)
+}
+
+// TxnWaits returns the PushTxn requests currently waiting in the push
+// txn queues of the store's replicas.
+func (s *Store) TxnWaits() []TxnWait {
+	var waits []TxnWait
+	newStoreReplicaVisitor(s).Visit(func(repl *Replica) bool {
+		waits = append(waits, repl.pushTxnQueue.Waits(repl.RangeID)...)
+		return true // more
+	})
+	return waits
+}
+
 // ComputeMetrics immediately computes the current value of store metrics which
 // cannot be computed incrementally. This method should be invoked periodically
 // by a higher-level system which records store metrics.
@@ -4123,6 +4484,7 @@ func (s *Store) ComputeMetrics(ctx context.Context, tick int) error {
 	if err := s.updateCommandQueueGauges(); err != nil {
 		return err
 	}
+	s.updatePushTxnQueueGauges()
 
 	// Get the latest RocksDB stats.
 	stats, err := s.engine.GetStats()
@@ -4130,6 +4492,7 @@ func (s *Store) ComputeMetrics(ctx context.Context, tick int) error {
 		return err
 	}
 	s.metrics.updateRocksDBStats(*stats)
+	s.admission.updateStats(*stats)
 
 	// If we're using RocksDB, log the sstable overview.
 	if rocksdb, ok := s.engine.(*engine.RocksDB); ok {
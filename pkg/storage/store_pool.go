@@ -448,6 +448,14 @@ type StoreList struct {
 	// candidateWritesPerSecond tracks writes-per-second stats for stores that are
 	// eligible to be rebalance targets.
 	candidateWritesPerSecond stat
+
+	// candidateQueriesPerSecond tracks queries-per-second stats for stores that
+	// are eligible to be rebalance targets.
+	candidateQueriesPerSecond stat
+
+	// candidateWriteBytesPerSecond tracks write-bytes-per-second stats for
+	// stores that are eligible to be rebalance targets.
+	candidateWriteBytesPerSecond stat
 }
 
 // Generates a new store list based on the passed in descriptors. It will
@@ -461,6 +469,8 @@ func makeStoreList(descriptors []roachpb.StoreDescriptor) StoreList {
 		sl.candidateLeases.update(float64(desc.Capacity.LeaseCount))
 		sl.candidateLogicalBytes.update(float64(desc.Capacity.LogicalBytes))
 		sl.candidateWritesPerSecond.update(desc.Capacity.WritesPerSecond)
+		sl.candidateQueriesPerSecond.update(desc.Capacity.QueriesPerSecond)
+		sl.candidateWriteBytesPerSecond.update(desc.Capacity.WriteBytesPerSecond)
 	}
 	return sl
 }
@@ -468,20 +478,25 @@ func makeStoreList(descriptors []roachpb.StoreDescriptor) StoreList {
 func (sl StoreList) String() string {
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf,
-		"  candidate: avg-ranges=%v avg-leases=%v avg-disk-usage=%v avg-writes-per-second=%v",
+		"  candidate: avg-ranges=%v avg-leases=%v avg-disk-usage=%v avg-writes-per-second=%v "+
+			"avg-queries-per-second=%v avg-write-bytes-per-second=%v",
 		sl.candidateRanges.mean,
 		sl.candidateLeases.mean,
 		humanizeutil.IBytes(int64(sl.candidateLogicalBytes.mean)),
-		sl.candidateWritesPerSecond.mean)
+		sl.candidateWritesPerSecond.mean,
+		sl.candidateQueriesPerSecond.mean,
+		humanizeutil.IBytes(int64(sl.candidateWriteBytesPerSecond.mean)))
 	if len(sl.stores) > 0 {
 		fmt.Fprintf(&buf, "\n")
 	} else {
 		fmt.Fprintf(&buf, " <no candidates>")
 	}
 	for _, desc := range sl.stores {
-		fmt.Fprintf(&buf, "  %d: ranges=%d leases=%d disk-usage=%s writes-per-second=%.2f\n",
+		fmt.Fprintf(&buf, "  %d: ranges=%d leases=%d disk-usage=%s writes-per-second=%.2f "+
+			"queries-per-second=%.2f\n",
 			desc.StoreID, desc.Capacity.RangeCount,
-			desc.Capacity.LeaseCount, humanizeutil.IBytes(desc.Capacity.LogicalBytes), desc.Capacity.WritesPerSecond)
+			desc.Capacity.LeaseCount, humanizeutil.IBytes(desc.Capacity.LogicalBytes),
+			desc.Capacity.WritesPerSecond, desc.Capacity.QueriesPerSecond)
 	}
 	return buf.String()
 }
@@ -0,0 +1,445 @@
+// Copyright 2017 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cockroachdb/cockroach/pkg/config"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+const (
+	// storeRebalancerTimerDuration is how frequently to check the store-level
+	// balance of the cluster.
+	storeRebalancerTimerDuration = time.Minute
+
+	// minQPSThresholdDifference is the minimum QPS difference from the cluster
+	// mean that this system should care about. In other words, we won't worry
+	// about rebalancing for QPS reasons if a store's QPS differs from the mean
+	// by less than this amount even if the amount is greater than the
+	// percentage threshold. This avoids too many lease transfers in lightly
+	// loaded clusters.
+	minQPSThresholdDifference = 100
+
+	// minWriteBytesThresholdDifference is the equivalent of
+	// minQPSThresholdDifference for the bytes written per second by a store.
+	minWriteBytesThresholdDifference = 1 << 20 // 1 MiB/s
+
+	// maxHotRangesToConsider bounds the number of ranges that a single pass of
+	// the store rebalancer will try to move.
+	maxHotRangesToConsider = 128
+)
+
+var (
+	metaStoreRebalancerLeaseTransferCount = metric.Metadata{
+		Name: "rebalancing.lease.transfers",
+		Help: "Number of lease transfers motivated by store-level load imbalances"}
+	metaStoreRebalancerRangeRebalanceCount = metric.Metadata{
+		Name: "rebalancing.range.rebalances",
+		Help: "Number of range rebalance operations motivated by store-level load imbalances"}
+)
+
+// StoreRebalancerMetrics is the set of metrics for the store-level rebalancer.
+type StoreRebalancerMetrics struct {
+	LeaseTransferCount  *metric.Counter
+	RangeRebalanceCount *metric.Counter
+}
+
+func makeStoreRebalancerMetrics() StoreRebalancerMetrics {
+	return StoreRebalancerMetrics{
+		LeaseTransferCount:  metric.NewCounter(metaStoreRebalancerLeaseTransferCount),
+		RangeRebalanceCount: metric.NewCounter(metaStoreRebalancerRangeRebalanceCount),
+	}
+}
+
+// StoreRebalancer is responsible for examining how the associated store's load
+// compares to the load on other stores in the cluster and transferring leases
+// or replicas away if the local store is overloaded.
+//
+// This isn't implemented as a Queue because the Queues all operate on one
+// replica at a time, making a local decision about each replica. Queues don't
+// really know how the replica they're looking at compares to other replicas on
+// the store. Our goal is balancing stores, though, so it's preferable to make
+// decisions about the store as a whole and then figure out which replicas to
+// move based on which contribute the most to the store's load.
+type StoreRebalancer struct {
+	log.AmbientContext
+	metrics StoreRebalancerMetrics
+	st      *cluster.Settings
+	store   *Store
+	rq      *replicateQueue
+}
+
+// NewStoreRebalancer creates a StoreRebalancer to work in tandem with the
+// provided replicateQueue.
+func NewStoreRebalancer(
+	ambientCtx log.AmbientContext, st *cluster.Settings, rq *replicateQueue,
+) *StoreRebalancer {
+	ambientCtx.AddLogTag("store-rebalancer", nil)
+	sr := &StoreRebalancer{
+		AmbientContext: ambientCtx,
+		metrics:        makeStoreRebalancerMetrics(),
+		st:             st,
+		store:          rq.store,
+		rq:             rq,
+	}
+	sr.store.metrics.registry.AddMetricStruct(&sr.metrics)
+	return sr
+}
+
+// Start runs an infinite loop in a goroutine which regularly checks whether
+// the store is overloaded along any important dimension (e.g. range count,
+// QPS, disk usage), and if so attempts to correct that by moving leases or
+// replicas elsewhere.
+//
+// This worker acts on store-level imbalances, whereas the replicate queue
+// makes decisions based on the zone config constraints and diversity of
+// individual ranges. This means that there are two different workers that
+// could potentially be making decisions about a given range, so they have to
+// be careful to avoid stepping on each others' toes.
+func (sr *StoreRebalancer) Start(ctx context.Context, stopper *stop.Stopper) {
+	ctx = sr.AnnotateCtx(ctx)
+
+	stopper.RunWorker(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(storeRebalancerTimerDuration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopper.ShouldStop():
+				return
+			case <-ticker.C:
+			}
+
+			mode := cluster.LoadBasedRebalancingMode(sr.st.LoadBasedRebalancingMode.Get())
+			if mode == cluster.LBRebalancingOff {
+				continue
+			}
+			if sr.store.TestingKnobs().DisableStoreRebalancer {
+				continue
+			}
+
+			storeList, _, _ := sr.rq.allocator.storePool.getStoreList(roachpb.RangeID(0), storeFilterNone)
+			sr.rebalanceStore(ctx, mode, storeList)
+		}
+	})
+}
+
+// replicaWithStats pairs a replica with the load it is currently serving.
+// Every replica of a range applies its writes, so writeBytes moves along with
+// a replica but not with the lease.
+type replicaWithStats struct {
+	repl       *Replica
+	desc       *roachpb.RangeDescriptor
+	qps        float64
+	writeBytes float64
+}
+
+// qpsMaxThreshold returns the QPS above which a store is considered overfull.
+func qpsMaxThreshold(st *cluster.Settings, sl StoreList) float64 {
+	mean := sl.candidateQueriesPerSecond.mean
+	return math.Max(mean*(1+st.QPSRebalanceThreshold.Get()), mean+minQPSThresholdDifference)
+}
+
+// qpsMinThreshold returns the QPS below which a store is considered underfull.
+func qpsMinThreshold(st *cluster.Settings, sl StoreList) float64 {
+	mean := sl.candidateQueriesPerSecond.mean
+	return math.Min(mean*(1-st.QPSRebalanceThreshold.Get()), mean-minQPSThresholdDifference)
+}
+
+// writeBytesMaxThreshold returns the bytes written per second above which a
+// store is too busy to take on more replicas.
+func writeBytesMaxThreshold(st *cluster.Settings, sl StoreList) float64 {
+	mean := sl.candidateWriteBytesPerSecond.mean
+	return math.Max(overfullStatThreshold(st, mean), mean+minWriteBytesThresholdDifference)
+}
+
+func (sr *StoreRebalancer) rebalanceStore(
+	ctx context.Context, mode cluster.LoadBasedRebalancingMode, storeList StoreList,
+) {
+	storeDesc, ok := sr.rq.allocator.storePool.getStoreDescriptor(sr.store.StoreID())
+	if !ok {
+		log.VEventf(ctx, 1, "unable to find local store descriptor for s%d", sr.store.StoreID())
+		return
+	}
+
+	maxQPS := qpsMaxThreshold(sr.st, storeList)
+	if storeDesc.Capacity.QueriesPerSecond <= maxQPS {
+		log.VEventf(ctx, 1, "local QPS %.2f is below max threshold %.2f (mean=%.2f); no rebalancing needed",
+			storeDesc.Capacity.QueriesPerSecond, maxQPS, storeList.candidateQueriesPerSecond.mean)
+		return
+	}
+	log.VEventf(ctx, 1,
+		"considering load-based rebalancing because local QPS %.2f is above max threshold %.2f (mean=%.2f)",
+		storeDesc.Capacity.QueriesPerSecond, maxQPS, storeList.candidateQueriesPerSecond.mean)
+
+	storeMap := storeListToMap(storeList)
+	localDesc := storeDesc
+	storeMap[localDesc.StoreID] = &localDesc
+	sysCfg, haveSysCfg := sr.store.cfg.Gossip.GetSystemConfig()
+
+	hottestRanges := sr.hottestRanges()
+	var replicasToMaybeRebalance []replicaWithStats
+	for len(hottestRanges) > 0 && localDesc.Capacity.QueriesPerSecond > maxQPS {
+		hr := hottestRanges[0]
+		hottestRanges = hottestRanges[1:]
+
+		candidates := filterBehindReplicas(hr.repl.RaftStatus(), hr.desc.Replicas)
+		if haveSysCfg {
+			// Don't move the lease away from the zone's preferred lease holders.
+			if zone, err := sysCfg.GetZoneConfigForKey(hr.desc.StartKey); err == nil {
+				if preferred, _ := sr.rq.allocator.preferredLeaseholders(
+					zone, storeList, candidates, localDesc.StoreID,
+				); len(preferred) > 0 {
+					candidates = preferred
+				}
+			}
+		}
+		target, ok := chooseLeaseTarget(hr, candidates, &localDesc, storeMap, maxQPS)
+		if !ok {
+			replicasToMaybeRebalance = append(replicasToMaybeRebalance, hr)
+			continue
+		}
+		log.VEventf(ctx, 1, "transferring r%d (%.2f qps) to s%d to better balance load",
+			hr.desc.RangeID, hr.qps, target.StoreID)
+		if err := hr.repl.AdminTransferLease(ctx, target.StoreID); err != nil {
+			log.Errorf(ctx, "unable to transfer lease to s%d: %s", target.StoreID, err)
+			continue
+		}
+		sr.metrics.LeaseTransferCount.Inc(1)
+		sr.rq.lastLeaseTransfer.Store(timeutil.Now())
+		localDesc.Capacity.LeaseCount--
+		localDesc.Capacity.QueriesPerSecond -= hr.qps
+		if targetDesc, ok := storeMap[target.StoreID]; ok {
+			targetDesc.Capacity.LeaseCount++
+			targetDesc.Capacity.QueriesPerSecond += hr.qps
+		}
+	}
+
+	if localDesc.Capacity.QueriesPerSecond <= maxQPS {
+		log.VEventf(ctx, 1, "load-based lease transfers successfully brought s%d down to %.2f qps "+
+			"(mean=%.2f, max=%.2f)", localDesc.StoreID, localDesc.Capacity.QueriesPerSecond,
+			storeList.candidateQueriesPerSecond.mean, maxQPS)
+		return
+	}
+	if mode != cluster.LBRebalancingLeasesAndReplicas {
+		log.VEventf(ctx, 1, "ran out of leases worth transferring and qps (%.2f) is still above "+
+			"the max threshold (%.2f)", localDesc.Capacity.QueriesPerSecond, maxQPS)
+		return
+	}
+
+	// Leases alone weren't enough. Try moving replicas of the remaining hot
+	// ranges off of this store entirely.
+	if !haveSysCfg {
+		log.VEventf(ctx, 1, "no system config available, unable to rebalance replicas")
+		return
+	}
+	replicasToMaybeRebalance = append(replicasToMaybeRebalance, hottestRanges...)
+	maxWriteBytes := writeBytesMaxThreshold(sr.st, storeList)
+	for _, hr := range replicasToMaybeRebalance {
+		if localDesc.Capacity.QueriesPerSecond <= maxQPS {
+			break
+		}
+		zone, err := sysCfg.GetZoneConfigForKey(hr.desc.StartKey)
+		if err != nil {
+			log.Error(ctx, err)
+			continue
+		}
+		target, ok := chooseReplicaTarget(
+			hr, zone.Constraints, &localDesc, storeList, storeMap,
+			sr.rq.allocator.storePool.getLocalities(hr.desc.Replicas), maxQPS, maxWriteBytes,
+		)
+		if !ok {
+			continue
+		}
+		log.VEventf(ctx, 1, "relocating replica of r%d (%.2f qps) from s%d to s%d to better balance load",
+			hr.desc.RangeID, hr.qps, localDesc.StoreID, target.StoreID)
+		if err := sr.relocateReplica(ctx, hr, target); err != nil {
+			log.Errorf(ctx, "unable to relocate replica of r%d to s%d: %s", hr.desc.RangeID, target.StoreID, err)
+			continue
+		}
+		sr.metrics.RangeRebalanceCount.Inc(1)
+		localDesc.Capacity.RangeCount--
+		localDesc.Capacity.LeaseCount--
+		localDesc.Capacity.QueriesPerSecond -= hr.qps
+		localDesc.Capacity.WriteBytesPerSecond -= hr.writeBytes
+		target.Capacity.RangeCount++
+		target.Capacity.LeaseCount++
+		target.Capacity.QueriesPerSecond += hr.qps
+		target.Capacity.WriteBytesPerSecond += hr.writeBytes
+	}
+}
+
+// hottestRanges returns the ranges for which the local store holds a valid
+// lease, sorted by descending QPS. Ranges whose stats haven't been collected
+// for long enough to be trusted are excluded.
+func (sr *StoreRebalancer) hottestRanges() []replicaWithStats {
+	now := sr.store.Clock().Now()
+	var hot []replicaWithStats
+	newStoreReplicaVisitor(sr.store).Visit(func(r *Replica) bool {
+		if r.leaseholderStats == nil || !r.ownsValidLease(now) {
+			return true
+		}
+		if qps, dur := r.leaseholderStats.avgQPS(); dur >= MinStatsDuration && qps > 0 {
+			hot = append(hot, replicaWithStats{
+				repl:       r,
+				desc:       r.Desc(),
+				qps:        qps,
+				writeBytes: r.WriteBytesPerSecond(),
+			})
+		}
+		return true
+	})
+	sort.Slice(hot, func(i, j int) bool { return hot[i].qps > hot[j].qps })
+	if len(hot) > maxHotRangesToConsider {
+		hot = hot[:maxHotRangesToConsider]
+	}
+	return hot
+}
+
+// chooseLeaseTarget picks the coldest of the range's other replicas to which
+// its lease can be transferred without pushing the receiving store above
+// maxQPS. Requiring the receiver to stay below the threshold that caused the
+// transfer in the first place is what prevents leases from bouncing back and
+// forth between stores.
+func chooseLeaseTarget(
+	hr replicaWithStats,
+	candidates []roachpb.ReplicaDescriptor,
+	localDesc *roachpb.StoreDescriptor,
+	storeMap map[roachpb.StoreID]*roachpb.StoreDescriptor,
+	maxQPS float64,
+) (roachpb.ReplicaDescriptor, bool) {
+	var best roachpb.ReplicaDescriptor
+	bestQPS := math.MaxFloat64
+	for _, repl := range candidates {
+		if repl.StoreID == localDesc.StoreID {
+			continue
+		}
+		storeDesc, ok := storeMap[repl.StoreID]
+		if !ok {
+			continue
+		}
+		newQPS := storeDesc.Capacity.QueriesPerSecond + hr.qps
+		if newQPS > maxQPS || newQPS >= localDesc.Capacity.QueriesPerSecond {
+			continue
+		}
+		if storeDesc.Capacity.QueriesPerSecond < bestQPS {
+			best = repl
+			bestQPS = storeDesc.Capacity.QueriesPerSecond
+		}
+	}
+	return best, bestQPS != math.MaxFloat64
+}
+
+// chooseReplicaTarget picks the coldest store that doesn't already hold a
+// replica of the range and that could take over the local replica (and its
+// lease) without violating the zone's constraints, reducing the range's
+// locality diversity, or pushing the store above maxQPS or above
+// maxWriteBytes written per second.
+func chooseReplicaTarget(
+	hr replicaWithStats,
+	constraints config.Constraints,
+	localDesc *roachpb.StoreDescriptor,
+	sl StoreList,
+	storeMap map[roachpb.StoreID]*roachpb.StoreDescriptor,
+	existingNodeLocalities map[roachpb.NodeID]roachpb.Locality,
+	maxQPS float64,
+	maxWriteBytes float64,
+) (*roachpb.StoreDescriptor, bool) {
+	localDiversity := diversityRemovalScore(localDesc.Node.NodeID, existingNodeLocalities)
+	otherLocalities := make(map[roachpb.NodeID]roachpb.Locality, len(existingNodeLocalities))
+	for nodeID, locality := range existingNodeLocalities {
+		if nodeID != localDesc.Node.NodeID {
+			otherLocalities[nodeID] = locality
+		}
+	}
+
+	var best *roachpb.StoreDescriptor
+	for _, s := range sl.stores {
+		storeDesc, ok := storeMap[s.StoreID]
+		if !ok || !preexistingReplicaCheck(storeDesc.Node.NodeID, hr.desc.Replicas) {
+			continue
+		}
+		if constraintsOk, _ := constraintCheck(*storeDesc, constraints); !constraintsOk {
+			continue
+		}
+		if !maxCapacityCheck(*storeDesc) {
+			continue
+		}
+		if diversityScore(*storeDesc, otherLocalities) < localDiversity {
+			continue
+		}
+		newQPS := storeDesc.Capacity.QueriesPerSecond + hr.qps
+		if newQPS > maxQPS || newQPS >= localDesc.Capacity.QueriesPerSecond {
+			continue
+		}
+		if storeDesc.Capacity.WriteBytesPerSecond+hr.writeBytes > maxWriteBytes {
+			continue
+		}
+		if best == nil || storeDesc.Capacity.QueriesPerSecond < best.Capacity.QueriesPerSecond {
+			best = storeDesc
+		}
+	}
+	return best, best != nil
+}
+
+// relocateReplica moves the local replica of the given range (which must hold
+// the range's lease) to the target store by adding a replica on the target,
+// transferring the lease to it and then removing the local replica. Once the
+// lease has moved the local replica can no longer change the range's
+// replicas, so the removal is sent to the new leaseholder.
+func (sr *StoreRebalancer) relocateReplica(
+	ctx context.Context, hr replicaWithStats, target *roachpb.StoreDescriptor,
+) error {
+	repl := hr.repl
+	addTarget := roachpb.ReplicationTarget{
+		NodeID:  target.Node.NodeID,
+		StoreID: target.StoreID,
+	}
+	if err := sr.rq.addReplica(
+		ctx, repl, addTarget, repl.Desc(), SnapshotRequest_REBALANCE, ReasonRebalance, "",
+	); err != nil {
+		return err
+	}
+	if err := repl.AdminTransferLease(ctx, target.StoreID); err != nil {
+		return err
+	}
+	sr.rq.lastLeaseTransfer.Store(timeutil.Now())
+	removeTarget := roachpb.ReplicationTarget{
+		NodeID:  sr.store.Ident.NodeID,
+		StoreID: sr.store.StoreID(),
+	}
+	return sr.store.DB().AdminChangeReplicas(
+		ctx, hr.desc.StartKey.AsRawKey(), roachpb.REMOVE_REPLICA,
+		[]roachpb.ReplicationTarget{removeTarget},
+	)
+}
+
+func storeListToMap(sl StoreList) map[roachpb.StoreID]*roachpb.StoreDescriptor {
+	storeMap := make(map[roachpb.StoreID]*roachpb.StoreDescriptor, len(sl.stores))
+	for i := range sl.stores {
+		storeMap[sl.stores[i].StoreID] = &sl.stores[i]
+	}
+	return storeMap
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/config"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+)
+
+// makeQPSStores returns one store descriptor per QPS value, with store and
+// node IDs assigned sequentially starting at 1.
+func makeQPSStores(qps ...float64) []roachpb.StoreDescriptor {
+	stores := make([]roachpb.StoreDescriptor, len(qps))
+	for i, q := range qps {
+		id := i + 1
+		stores[i] = roachpb.StoreDescriptor{
+			StoreID: roachpb.StoreID(id),
+			Node:    roachpb.NodeDescriptor{NodeID: roachpb.NodeID(id)},
+			Capacity: roachpb.StoreCapacity{
+				Capacity:         100,
+				Available:        50,
+				QueriesPerSecond: q,
+			},
+		}
+	}
+	return stores
+}
+
+func TestQPSMaxThreshold(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	st := cluster.MakeTestingClusterSettings()
+	testCases := []struct {
+		qps      []float64
+		expected float64
+	}{
+		// A small mean is dominated by the absolute minimum difference.
+		{[]float64{10, 20, 30}, 120},
+		// A large mean is dominated by the relative threshold.
+		{[]float64{1000, 2000, 3000}, 2500},
+	}
+	for _, tc := range testCases {
+		sl := makeStoreList(makeQPSStores(tc.qps...))
+		if a, e := qpsMaxThreshold(st, sl), tc.expected; a != e {
+			t.Errorf("%v: expected threshold %f, got %f", tc.qps, e, a)
+		}
+	}
+}
+
+func TestWriteBytesMaxThreshold(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	st := cluster.MakeTestingClusterSettings()
+	testCases := []struct {
+		writeBytes []float64
+		expected   float64
+	}{
+		// A small mean is dominated by the absolute minimum difference.
+		{[]float64{1000, 2000, 3000}, 2000 + minWriteBytesThresholdDifference},
+		// A large mean is dominated by the relative threshold.
+		{[]float64{1 << 30, 1 << 30, 1 << 30}, (1 << 30) * (1 + st.StatRebalanceThreshold.Get())},
+	}
+	for _, tc := range testCases {
+		stores := makeQPSStores(make([]float64, len(tc.writeBytes))...)
+		for i, wb := range tc.writeBytes {
+			stores[i].Capacity.WriteBytesPerSecond = wb
+		}
+		if a, e := writeBytesMaxThreshold(st, makeStoreList(stores)), tc.expected; a != e {
+			t.Errorf("%v: expected threshold %f, got %f", tc.writeBytes, e, a)
+		}
+	}
+}
+
+func TestChooseLeaseTarget(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	stores := makeQPSStores(3000, 1000, 1500, 2400)
+	storeMap := storeListToMap(makeStoreList(stores))
+	localDesc := storeMap[1]
+	var replicas []roachpb.ReplicaDescriptor
+	for _, s := range stores {
+		replicas = append(replicas, roachpb.ReplicaDescriptor{
+			NodeID:    s.Node.NodeID,
+			StoreID:   s.StoreID,
+			ReplicaID: roachpb.ReplicaID(s.StoreID),
+		})
+	}
+
+	testCases := []struct {
+		qps      float64
+		maxQPS   float64
+		expected roachpb.StoreID
+	}{
+		// The coldest store is preferred.
+		{100, 2000, 2},
+		// A range hot enough to push the coldest store over the threshold
+		// can't be moved anywhere.
+		{1200, 2000, 0},
+		// Moving the lease must not make the receiver hotter than we are.
+		{2000, 5000, 0},
+	}
+	for _, tc := range testCases {
+		hr := replicaWithStats{qps: tc.qps}
+		target, ok := chooseLeaseTarget(hr, replicas, localDesc, storeMap, tc.maxQPS)
+		if tc.expected == 0 {
+			if ok {
+				t.Errorf("qps=%f: expected no target, got s%d", tc.qps, target.StoreID)
+			}
+			continue
+		}
+		if !ok || target.StoreID != tc.expected {
+			t.Errorf("qps=%f: expected target s%d, got s%d (ok=%t)",
+				tc.qps, tc.expected, target.StoreID, ok)
+		}
+	}
+}
+
+func TestChooseReplicaTarget(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	stores := makeQPSStores(3000, 1000, 1500, 500, 200)
+	// s5 is the coldest store but is nearly full.
+	stores[4].Capacity.Available = 1
+	stores[3].Capacity.WriteBytesPerSecond = 1000
+	for i := range stores {
+		stores[i].Node.Locality = roachpb.Locality{
+			Tiers: []roachpb.Tier{{Key: "dc", Value: string('a' + rune(i))}},
+		}
+	}
+	sl := makeStoreList(stores)
+	storeMap := storeListToMap(sl)
+	localDesc := storeMap[1]
+
+	desc := &roachpb.RangeDescriptor{
+		Replicas: []roachpb.ReplicaDescriptor{
+			{NodeID: 1, StoreID: 1, ReplicaID: 1},
+			{NodeID: 2, StoreID: 2, ReplicaID: 2},
+			{NodeID: 3, StoreID: 3, ReplicaID: 3},
+		},
+	}
+	existing := make(map[roachpb.NodeID]roachpb.Locality)
+	for _, r := range desc.Replicas {
+		existing[r.NodeID] = storeMap[r.StoreID].Node.Locality
+	}
+
+	const maxWriteBytes = 2000
+	testCases := []struct {
+		qps         float64
+		writeBytes  float64
+		maxQPS      float64
+		constraints config.Constraints
+		expected    roachpb.StoreID
+	}{
+		// s4 is the coldest store with room that doesn't already have a replica.
+		{100, 500, 2000, config.Constraints{}, 4},
+		// The range is too hot for any of the candidates.
+		{1600, 500, 2000, config.Constraints{}, 0},
+		// The range writes too much for s4 to take it on.
+		{100, 1500, 2000, config.Constraints{}, 0},
+		// A required constraint no candidate satisfies rules them all out.
+		{100, 500, 2000, config.Constraints{
+			Constraints: []config.Constraint{
+				{Type: config.Constraint_REQUIRED, Key: "dc", Value: "a"},
+			},
+		}, 0},
+	}
+	for _, tc := range testCases {
+		hr := replicaWithStats{desc: desc, qps: tc.qps, writeBytes: tc.writeBytes}
+		target, ok := chooseReplicaTarget(
+			hr, tc.constraints, localDesc, sl, storeMap, existing, tc.maxQPS, maxWriteBytes,
+		)
+		if tc.expected == 0 {
+			if ok {
+				t.Errorf("qps=%f: expected no target, got s%d", tc.qps, target.StoreID)
+			}
+			continue
+		}
+		if !ok || target.StoreID != tc.expected {
+			t.Errorf("qps=%f: expected target s%d, got %v", tc.qps, tc.expected, target)
+		}
+	}
+}
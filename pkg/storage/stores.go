@@ -190,6 +190,18 @@ func (ls *Stores) Send(
 	return br, pErr
 }
 
+// RangeFeed registers a range feed with the replica addressed by the
+// request's header, on the store addressed by that header.
+func (ls *Stores) RangeFeed(
+	ctx context.Context, args *roachpb.RangeFeedRequest, stream roachpb.Internal_RangeFeedServer,
+) *roachpb.Error {
+	store, err := ls.GetStore(args.Replica.StoreID)
+	if err != nil {
+		return roachpb.NewError(err)
+	}
+	return store.RangeFeed(ctx, args, stream)
+}
+
 // LookupReplica looks up replica by key [range]. Lookups are done
 // by consulting each store in turn via Store.LookupReplica(key).
 // Returns RangeID and replica on success; RangeKeyMismatch error